@@ -128,6 +128,16 @@ func Convert_v1alpha4_NetworkSpec_To_v1alpha3_NetworkSpec(in *v1alpha4.NetworkSp
 	return nil
 }
 
+// Convert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec converts from the Hub version (v1alpha4) of the SubnetSpec to this version.
+func Convert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(in *v1alpha4.SubnetSpec, out *SubnetSpec, s apiconversion.Scope) error { //nolint
+	return autoConvert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(in, out, s)
+}
+
+// Convert_v1alpha4_Network_To_v1alpha3_Network.
+func Convert_v1alpha4_Network_To_v1alpha3_Network(in *v1alpha4.Network, out *Network, s apiconversion.Scope) error { //nolint
+	return autoConvert_v1alpha4_Network_To_v1alpha3_Network(in, out, s)
+}
+
 // Convert_v1alpha3_APIEndpoint_To_v1alpha4_APIEndpoint is an autogenerated conversion function.
 func Convert_v1alpha3_APIEndpoint_To_v1alpha4_APIEndpoint(in *apiv1alpha3.APIEndpoint, out *apiv1alpha4.APIEndpoint, s apiconversion.Scope) error {
 	return apiv1alpha3.Convert_v1alpha3_APIEndpoint_To_v1alpha4_APIEndpoint(in, out, s)
@@ -79,6 +79,11 @@ func Convert_v1alpha4_GCPMachineSpec_To_v1alpha3_GCPMachineSpec(in *v1alpha4.GCP
 	return nil
 }
 
+// Convert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec converts from the Hub version (v1alpha4) of the AttachedDiskSpec to this version.
+func Convert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(in *v1alpha4.AttachedDiskSpec, out *AttachedDiskSpec, s apiconversion.Scope) error { // nolint
+	return autoConvert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(in, out, s)
+}
+
 // Convert_v1alpha3_GCPMachineStatus_To_v1alpha4_GCPMachineStatus converts this GCPMachineStatus to the Hub version (v1alpha4).
 func Convert_v1alpha3_GCPMachineStatus_To_v1alpha4_GCPMachineStatus(in *GCPMachineStatus, out *v1alpha4.GCPMachineStatus, s apiconversion.Scope) error { // nolint
 	if err := autoConvert_v1alpha3_GCPMachineStatus_To_v1alpha4_GCPMachineStatus(in, out, s); err != nil {
@@ -44,11 +44,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1alpha4.AttachedDiskSpec)(nil), (*AttachedDiskSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(a.(*v1alpha4.AttachedDiskSpec), b.(*AttachedDiskSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*BuildParams)(nil), (*v1alpha4.BuildParams)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_BuildParams_To_v1alpha4_BuildParams(a.(*BuildParams), b.(*v1alpha4.BuildParams), scope)
 	}); err != nil {
@@ -164,11 +159,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1alpha4.Network)(nil), (*Network)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1alpha4_Network_To_v1alpha3_Network(a.(*v1alpha4.Network), b.(*Network), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddGeneratedConversionFunc((*ServiceAccount)(nil), (*v1alpha4.ServiceAccount)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_ServiceAccount_To_v1alpha4_ServiceAccount(a.(*ServiceAccount), b.(*v1alpha4.ServiceAccount), scope)
 	}); err != nil {
@@ -184,11 +174,6 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
-	if err := s.AddGeneratedConversionFunc((*v1alpha4.SubnetSpec)(nil), (*SubnetSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
-		return Convert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(a.(*v1alpha4.SubnetSpec), b.(*SubnetSpec), scope)
-	}); err != nil {
-		return err
-	}
 	if err := s.AddConversionFunc((*apiv1alpha3.APIEndpoint)(nil), (*apiv1alpha4.APIEndpoint)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha3_APIEndpoint_To_v1alpha4_APIEndpoint(a.(*apiv1alpha3.APIEndpoint), b.(*apiv1alpha4.APIEndpoint), scope)
 	}); err != nil {
@@ -224,6 +209,11 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1alpha4.AttachedDiskSpec)(nil), (*AttachedDiskSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(a.(*v1alpha4.AttachedDiskSpec), b.(*AttachedDiskSpec), scope)
+	}); err != nil {
+		return err
+	}
 	if err := s.AddConversionFunc((*v1alpha4.GCPClusterSpec)(nil), (*GCPClusterSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
 		return Convert_v1alpha4_GCPClusterSpec_To_v1alpha3_GCPClusterSpec(a.(*v1alpha4.GCPClusterSpec), b.(*GCPClusterSpec), scope)
 	}); err != nil {
@@ -249,6 +239,16 @@ func RegisterConversions(s *runtime.Scheme) error {
 	}); err != nil {
 		return err
 	}
+	if err := s.AddConversionFunc((*v1alpha4.Network)(nil), (*Network)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha4_Network_To_v1alpha3_Network(a.(*v1alpha4.Network), b.(*Network), scope)
+	}); err != nil {
+		return err
+	}
+	if err := s.AddGeneratedConversionFunc((*v1alpha4.SubnetSpec)(nil), (*SubnetSpec)(nil), func(a, b interface{}, scope conversion.Scope) error {
+		return Convert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(a.(*v1alpha4.SubnetSpec), b.(*SubnetSpec), scope)
+	}); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -266,14 +266,12 @@ func Convert_v1alpha3_AttachedDiskSpec_To_v1alpha4_AttachedDiskSpec(in *Attached
 func autoConvert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(in *v1alpha4.AttachedDiskSpec, out *AttachedDiskSpec, s conversion.Scope) error {
 	out.DeviceType = (*DiskType)(unsafe.Pointer(in.DeviceType))
 	out.Size = (*int64)(unsafe.Pointer(in.Size))
+	// WARNING: in.DeviceName requires manual conversion: does not exist in peer-type
+	// WARNING: in.Interface requires manual conversion: does not exist in peer-type
+	// WARNING: in.AutoDelete requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec is an autogenerated conversion function.
-func Convert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(in *v1alpha4.AttachedDiskSpec, out *AttachedDiskSpec, s conversion.Scope) error {
-	return autoConvert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(in, out, s)
-}
-
 func autoConvert_v1alpha3_BuildParams_To_v1alpha4_BuildParams(in *BuildParams, out *v1alpha4.BuildParams, s conversion.Scope) error {
 	out.Lifecycle = v1alpha4.ResourceLifecycle(in.Lifecycle)
 	out.ClusterName = in.ClusterName
@@ -418,11 +416,25 @@ func autoConvert_v1alpha4_GCPClusterSpec_To_v1alpha3_GCPClusterSpec(in *v1alpha4
 	if err := Convert_v1alpha4_APIEndpoint_To_v1alpha3_APIEndpoint(&in.ControlPlaneEndpoint, &out.ControlPlaneEndpoint, s); err != nil {
 		return err
 	}
+	// WARNING: in.ControlPlaneDNS requires manual conversion: does not exist in peer-type
 	if err := Convert_v1alpha4_NetworkSpec_To_v1alpha3_NetworkSpec(&in.Network, &out.Network, s); err != nil {
 		return err
 	}
 	out.FailureDomains = *(*[]string)(unsafe.Pointer(&in.FailureDomains))
+	// WARNING: in.ExcludedFailureDomains requires manual conversion: does not exist in peer-type
+	// WARNING: in.AdditionalControlPlaneRegions requires manual conversion: does not exist in peer-type
 	out.AdditionalLabels = *(*Labels)(unsafe.Pointer(&in.AdditionalLabels))
+	// WARNING: in.ResourceNamePrefix requires manual conversion: does not exist in peer-type
+	// WARNING: in.SecurityProfile requires manual conversion: does not exist in peer-type
+	// WARNING: in.Bastion requires manual conversion: does not exist in peer-type
+	// WARNING: in.IAPAccess requires manual conversion: does not exist in peer-type
+	// WARNING: in.BreakGlassSSH requires manual conversion: does not exist in peer-type
+	// WARNING: in.RegionalAPIEndpoint requires manual conversion: does not exist in peer-type
+	// WARNING: in.LoadBalancer requires manual conversion: does not exist in peer-type
+	// WARNING: in.Reservations requires manual conversion: does not exist in peer-type
+	// WARNING: in.SoleTenantNodeGroups requires manual conversion: does not exist in peer-type
+	// WARNING: in.MachineDefaults requires manual conversion: does not exist in peer-type
+	// WARNING: in.MaintenanceWindow requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -440,6 +452,14 @@ func autoConvert_v1alpha4_GCPClusterStatus_To_v1alpha3_GCPClusterStatus(in *v1al
 	if err := Convert_v1alpha4_Network_To_v1alpha3_Network(&in.Network, &out.Network, s); err != nil {
 		return err
 	}
+	// WARNING: in.Operations requires manual conversion: does not exist in peer-type
+	// WARNING: in.OwnedResources requires manual conversion: does not exist in peer-type
+	// WARNING: in.Bastion requires manual conversion: does not exist in peer-type
+	// WARNING: in.BreakGlassSSH requires manual conversion: does not exist in peer-type
+	// WARNING: in.Reservations requires manual conversion: does not exist in peer-type
+	// WARNING: in.SoleTenantNodeGroups requires manual conversion: does not exist in peer-type
+	// WARNING: in.ObservedGeneration requires manual conversion: does not exist in peer-type
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	out.Ready = in.Ready
 	return nil
 }
@@ -530,7 +550,17 @@ func autoConvert_v1alpha3_GCPMachineSpec_To_v1alpha4_GCPMachineSpec(in *GCPMachi
 	out.AdditionalNetworkTags = *(*[]string)(unsafe.Pointer(&in.AdditionalNetworkTags))
 	out.RootDeviceSize = in.RootDeviceSize
 	out.RootDeviceType = (*v1alpha4.DiskType)(unsafe.Pointer(in.RootDeviceType))
-	out.AdditionalDisks = *(*[]v1alpha4.AttachedDiskSpec)(unsafe.Pointer(&in.AdditionalDisks))
+	if in.AdditionalDisks != nil {
+		in, out := &in.AdditionalDisks, &out.AdditionalDisks
+		*out = make([]v1alpha4.AttachedDiskSpec, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha3_AttachedDiskSpec_To_v1alpha4_AttachedDiskSpec(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.AdditionalDisks = nil
+	}
 	out.ServiceAccount = (*v1alpha4.ServiceAccount)(unsafe.Pointer(in.ServiceAccount))
 	out.Preemptible = in.Preemptible
 	return nil
@@ -539,18 +569,53 @@ func autoConvert_v1alpha3_GCPMachineSpec_To_v1alpha4_GCPMachineSpec(in *GCPMachi
 func autoConvert_v1alpha4_GCPMachineSpec_To_v1alpha3_GCPMachineSpec(in *v1alpha4.GCPMachineSpec, out *GCPMachineSpec, s conversion.Scope) error {
 	out.InstanceType = in.InstanceType
 	out.Subnet = (*string)(unsafe.Pointer(in.Subnet))
+	// WARNING: in.InstanceNameTemplate requires manual conversion: does not exist in peer-type
 	out.ProviderID = (*string)(unsafe.Pointer(in.ProviderID))
 	out.ImageFamily = (*string)(unsafe.Pointer(in.ImageFamily))
 	out.Image = (*string)(unsafe.Pointer(in.Image))
+	// WARNING: in.ImageLookup requires manual conversion: does not exist in peer-type
 	out.AdditionalLabels = *(*Labels)(unsafe.Pointer(&in.AdditionalLabels))
 	out.AdditionalMetadata = *(*[]MetadataItem)(unsafe.Pointer(&in.AdditionalMetadata))
 	out.PublicIP = (*bool)(unsafe.Pointer(in.PublicIP))
 	out.AdditionalNetworkTags = *(*[]string)(unsafe.Pointer(&in.AdditionalNetworkTags))
+	// WARNING: in.AdditionalInstanceGroups requires manual conversion: does not exist in peer-type
+	// WARNING: in.TargetPools requires manual conversion: does not exist in peer-type
 	out.RootDeviceSize = in.RootDeviceSize
 	out.RootDeviceType = (*DiskType)(unsafe.Pointer(in.RootDeviceType))
-	out.AdditionalDisks = *(*[]AttachedDiskSpec)(unsafe.Pointer(&in.AdditionalDisks))
+	// WARNING: in.RootDeviceAutoDelete requires manual conversion: does not exist in peer-type
+	// WARNING: in.RootDeviceName requires manual conversion: does not exist in peer-type
+	if in.AdditionalDisks != nil {
+		in, out := &in.AdditionalDisks, &out.AdditionalDisks
+		*out = make([]AttachedDiskSpec, len(*in))
+		for i := range *in {
+			if err := Convert_v1alpha4_AttachedDiskSpec_To_v1alpha3_AttachedDiskSpec(&(*in)[i], &(*out)[i], s); err != nil {
+				return err
+			}
+		}
+	} else {
+		out.AdditionalDisks = nil
+	}
+	// WARNING: in.EtcdDisk requires manual conversion: does not exist in peer-type
+	// WARNING: in.DiskEncryption requires manual conversion: does not exist in peer-type
 	out.ServiceAccount = (*ServiceAccount)(unsafe.Pointer(in.ServiceAccount))
 	out.Preemptible = in.Preemptible
+	// WARNING: in.Reservation requires manual conversion: does not exist in peer-type
+	// WARNING: in.SoleTenantNodeGroup requires manual conversion: does not exist in peer-type
+	// WARNING: in.NodeAffinities requires manual conversion: does not exist in peer-type
+	// WARNING: in.MinCPUPlatform requires manual conversion: does not exist in peer-type
+	// WARNING: in.OnHostMaintenance requires manual conversion: does not exist in peer-type
+	// WARNING: in.AutomaticRestart requires manual conversion: does not exist in peer-type
+	// WARNING: in.ZoneFallback requires manual conversion: does not exist in peer-type
+	// WARNING: in.ExistingInstance requires manual conversion: does not exist in peer-type
+	// WARNING: in.RepairPolicy requires manual conversion: does not exist in peer-type
+	// WARNING: in.GuestAccelerators requires manual conversion: does not exist in peer-type
+	// WARNING: in.InstallGPUDriver requires manual conversion: does not exist in peer-type
+	// WARNING: in.EnableOSConfig requires manual conversion: does not exist in peer-type
+	// WARNING: in.InstallOpsAgent requires manual conversion: does not exist in peer-type
+	// WARNING: in.ContainerOptimizedOS requires manual conversion: does not exist in peer-type
+	// WARNING: in.ShieldedInstanceConfig requires manual conversion: does not exist in peer-type
+	// WARNING: in.ConfidentialCompute requires manual conversion: does not exist in peer-type
+	// WARNING: in.BootstrapDataBucket requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -566,9 +631,15 @@ func autoConvert_v1alpha3_GCPMachineStatus_To_v1alpha4_GCPMachineStatus(in *GCPM
 func autoConvert_v1alpha4_GCPMachineStatus_To_v1alpha3_GCPMachineStatus(in *v1alpha4.GCPMachineStatus, out *GCPMachineStatus, s conversion.Scope) error {
 	out.Ready = in.Ready
 	out.Addresses = *(*[]v1.NodeAddress)(unsafe.Pointer(&in.Addresses))
+	// WARNING: in.Zone requires manual conversion: does not exist in peer-type
 	out.InstanceStatus = (*InstanceStatus)(unsafe.Pointer(in.InstanceStatus))
+	// WARNING: in.Image requires manual conversion: does not exist in peer-type
+	// WARNING: in.Operation requires manual conversion: does not exist in peer-type
+	// WARNING: in.Scheduling requires manual conversion: does not exist in peer-type
+	// WARNING: in.LabelsHash requires manual conversion: does not exist in peer-type
 	out.FailureReason = (*errors.MachineStatusError)(unsafe.Pointer(in.FailureReason))
 	out.FailureMessage = (*string)(unsafe.Pointer(in.FailureMessage))
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -731,25 +802,48 @@ func Convert_v1alpha3_Network_To_v1alpha4_Network(in *Network, out *v1alpha4.Net
 func autoConvert_v1alpha4_Network_To_v1alpha3_Network(in *v1alpha4.Network, out *Network, s conversion.Scope) error {
 	out.SelfLink = (*string)(unsafe.Pointer(in.SelfLink))
 	out.FirewallRules = *(*map[string]string)(unsafe.Pointer(&in.FirewallRules))
+	// WARNING: in.Routes requires manual conversion: does not exist in peer-type
+	// WARNING: in.PrivateServicesAccessRange requires manual conversion: does not exist in peer-type
+	// WARNING: in.DNSPolicy requires manual conversion: does not exist in peer-type
+	// WARNING: in.DNSForwardingZones requires manual conversion: does not exist in peer-type
+	// WARNING: in.ControlPlaneDNSZone requires manual conversion: does not exist in peer-type
 	out.Router = (*string)(unsafe.Pointer(in.Router))
+	// WARNING: in.RouterNat requires manual conversion: does not exist in peer-type
+	// WARNING: in.NATIPs requires manual conversion: does not exist in peer-type
+	// WARNING: in.Subnets requires manual conversion: does not exist in peer-type
 	out.APIServerAddress = (*string)(unsafe.Pointer(in.APIServerAddress))
+	// WARNING: in.APIServerAddressSelfLink requires manual conversion: does not exist in peer-type
 	out.APIServerHealthCheck = (*string)(unsafe.Pointer(in.APIServerHealthCheck))
 	out.APIServerInstanceGroups = *(*map[string]string)(unsafe.Pointer(&in.APIServerInstanceGroups))
+	// WARNING: in.APIServerNetworkEndpointGroups requires manual conversion: does not exist in peer-type
 	out.APIServerBackendService = (*string)(unsafe.Pointer(in.APIServerBackendService))
 	out.APIServerTargetProxy = (*string)(unsafe.Pointer(in.APIServerTargetProxy))
 	out.APIServerForwardingRule = (*string)(unsafe.Pointer(in.APIServerForwardingRule))
+	// WARNING: in.APIServerIPv6Address requires manual conversion: does not exist in peer-type
+	// WARNING: in.APIServerIPv6ForwardingRule requires manual conversion: does not exist in peer-type
 	return nil
 }
 
-// Convert_v1alpha4_Network_To_v1alpha3_Network is an autogenerated conversion function.
-func Convert_v1alpha4_Network_To_v1alpha3_Network(in *v1alpha4.Network, out *Network, s conversion.Scope) error {
-	return autoConvert_v1alpha4_Network_To_v1alpha3_Network(in, out, s)
-}
-
 func autoConvert_v1alpha3_NetworkSpec_To_v1alpha4_NetworkSpec(in *NetworkSpec, out *v1alpha4.NetworkSpec, s conversion.Scope) error {
 	out.Name = (*string)(unsafe.Pointer(in.Name))
 	out.AutoCreateSubnetworks = (*bool)(unsafe.Pointer(in.AutoCreateSubnetworks))
-	out.Subnets = *(*v1alpha4.Subnets)(unsafe.Pointer(&in.Subnets))
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make(v1alpha4.Subnets, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(v1alpha4.SubnetSpec)
+				if err := Convert_v1alpha3_SubnetSpec_To_v1alpha4_SubnetSpec(*in, *out, s); err != nil {
+					return err
+				}
+			} else {
+				(*out)[i] = nil
+			}
+		}
+	} else {
+		out.Subnets = nil
+	}
 	out.LoadBalancerBackendPort = (*int32)(unsafe.Pointer(in.LoadBalancerBackendPort))
 	return nil
 }
@@ -757,8 +851,30 @@ func autoConvert_v1alpha3_NetworkSpec_To_v1alpha4_NetworkSpec(in *NetworkSpec, o
 func autoConvert_v1alpha4_NetworkSpec_To_v1alpha3_NetworkSpec(in *v1alpha4.NetworkSpec, out *NetworkSpec, s conversion.Scope) error {
 	out.Name = (*string)(unsafe.Pointer(in.Name))
 	out.AutoCreateSubnetworks = (*bool)(unsafe.Pointer(in.AutoCreateSubnetworks))
-	out.Subnets = *(*Subnets)(unsafe.Pointer(&in.Subnets))
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make(Subnets, len(*in))
+		for i := range *in {
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = new(SubnetSpec)
+				if err := Convert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(*in, *out, s); err != nil {
+					return err
+				}
+			} else {
+				(*out)[i] = nil
+			}
+		}
+	} else {
+		out.Subnets = nil
+	}
 	out.LoadBalancerBackendPort = (*int32)(unsafe.Pointer(in.LoadBalancerBackendPort))
+	// WARNING: in.Routes requires manual conversion: does not exist in peer-type
+	// WARNING: in.PrivateServicesAccess requires manual conversion: does not exist in peer-type
+	// WARNING: in.DNS requires manual conversion: does not exist in peer-type
+	// WARNING: in.NAT requires manual conversion: does not exist in peer-type
+	// WARNING: in.AdditionalFirewallRules requires manual conversion: does not exist in peer-type
+	// WARNING: in.FirewallPolicy requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -808,10 +924,8 @@ func autoConvert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(in *v1alpha4.SubnetS
 	out.Region = in.Region
 	out.PrivateGoogleAccess = (*bool)(unsafe.Pointer(in.PrivateGoogleAccess))
 	out.EnableFlowLogs = (*bool)(unsafe.Pointer(in.EnableFlowLogs))
+	// WARNING: in.Roles requires manual conversion: does not exist in peer-type
+	// WARNING: in.StackType requires manual conversion: does not exist in peer-type
+	// WARNING: in.IPv6AccessType requires manual conversion: does not exist in peer-type
 	return nil
 }
-
-// Convert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec is an autogenerated conversion function.
-func Convert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(in *v1alpha4.SubnetSpec, out *SubnetSpec, s conversion.Scope) error {
-	return autoConvert_v1alpha4_SubnetSpec_To_v1alpha3_SubnetSpec(in, out, s)
-}
@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DryRunAnnotation is the annotation set on a GCPCluster or a GCPMachine to have
+	// the controllers record the GCP operations they would perform without executing them.
+	// Set on a GCPCluster, it applies to the machines of the cluster as well.
+	DryRunAnnotation = "infrastructure.cluster.x-k8s.io/dry-run"
+
+	// AdoptAnnotation is the annotation set on a GCPCluster to have the controllers take ownership
+	// of the pre-existing GCP resources matching the cluster spec, e.g. a network created by Terraform
+	// or a previous install, and manage them thereafter, including their deletion.
+	// The adopted resources are recorded in the inventory of owned resources of the GCPCluster status, their
+	// description is left untouched. Without it, the reconcile fails on a pre-existing resource of the name of one
+	// of the cluster which isn't owned by the cluster, e.g. a firewall rule or a health check, and leaves it as is,
+	// but for the subnetworks which are used as they are.
+	// Set on a GCPManagedControlPlane, it has the controllers take ownership of the pre-existing GKE cluster of the
+	// same name, which is labelled as owned by the cluster.
+	AdoptAnnotation = "infrastructure.cluster.x-k8s.io/adopt"
+
+	// DeletionProtectionAnnotation is the annotation set on a GCPCluster to have the webhook reject its deletion,
+	// protecting the GCP resources of the cluster from an accidental delete. The Cluster owning it can still be
+	// deleted, but the controllers keep the GCP resources of the cluster and the instances of its machines and
+	// machine pools until the annotation is removed.
+	// Set on a GCPManagedControlPlane, the webhook rejects its deletion and the GKE cluster and its node pools are
+	// kept while the Cluster is deleted, until the annotation is removed.
+	DeletionProtectionAnnotation = "infrastructure.cluster.x-k8s.io/deletion-protection"
+
+	// RequestReasonAnnotation is the annotation set on a GCPCluster to attribute the GCP API calls made for the
+	// cluster and its machines in the audit logs, e.g. to a change ticket. The value is sent as the request reason
+	// of the calls, whose user agent also names the cluster.
+	RequestReasonAnnotation = "infrastructure.cluster.x-k8s.io/request-reason"
+
+	// ExportAnnotation is the annotation set on a GCPCluster to have the controllers export the GCP resources of
+	// its inventory of owned resources, e.g. for an audit or a migration to another tool. Its value is the format
+	// of the export, terraform for Terraform import blocks or config-connector for the Config Connector export
+	// commands. The export is written to the <cluster>-gcp-export ConfigMap, owned by the GCPCluster.
+	ExportAnnotation = "infrastructure.cluster.x-k8s.io/export"
+)
+
+const (
+	// RetainAnnotation is the annotation set on a GCPCluster to retain some of its GCP resources on deletion
+	// instead of destroying them, e.g. for clusters being recreated in place. Its value is a comma separated
+	// list of RetainedResource.
+	RetainAnnotation = "infrastructure.cluster.x-k8s.io/retain"
+)
+
+// RetainedResource is a GCP resource which can be retained on cluster deletion.
+type RetainedResource string
+
+const (
+	// RetainNetwork retains the network and its router.
+	RetainNetwork = RetainedResource("network")
+
+	// RetainFirewallRules retains the firewall rules, it implies RetainNetwork
+	// as a network can't be deleted while it has firewall rules.
+	RetainFirewallRules = RetainedResource("firewall-rules")
+
+	// RetainAPIServerAddress retains the static IP address of the API server load balancer.
+	RetainAPIServerAddress = RetainedResource("apiserver-address")
+)
+
+// RetainedResources returns the resources to retain on deletion from the RetainAnnotation.
+func (c *GCPCluster) RetainedResources() ([]RetainedResource, error) {
+	value, ok := c.Annotations[RetainAnnotation]
+	if !ok {
+		return nil, nil
+	}
+
+	res := []RetainedResource{}
+	for _, r := range strings.Split(value, ",") {
+		switch r := RetainedResource(strings.TrimSpace(r)); r {
+		case "":
+		case RetainNetwork, RetainAPIServerAddress:
+			res = append(res, r)
+		case RetainFirewallRules:
+			res = append(res, r, RetainNetwork)
+		default:
+			return nil, errors.Errorf("unknown resource %q, expected one of %q, %q or %q", r, RetainNetwork, RetainFirewallRules, RetainAPIServerAddress)
+		}
+	}
+
+	return res, nil
+}
+
+const (
+	// BlockMoveAnnotation is set by the controllers on the GCPClusters with operations in progress and on the
+	// GCPClusters and GCPMachines whose GCP resources are being deleted, to prevent clusterctl move, or any tooling
+	// honoring it, from moving them halfway through. It's removed from the GCPClusters once their operations
+	// complete, unless they are being deleted.
+	BlockMoveAnnotation = "clusterctl.cluster.x-k8s.io/block-move"
+
+	// OperationsAnnotation is set by the controllers on the GCPClusters with operations in progress, recorded in
+	// the operations of their status, to a JSON map of the same operations. Unlike the status, the annotation is
+	// moved by clusterctl move, the moved GCPCluster waiting for the operations started before the move.
+	OperationsAnnotation = "infrastructure.cluster.x-k8s.io/operations"
+)
+
+const (
+	// InstanceEventAnnotation is set by the controllers on the Machines whose instance has been disrupted,
+	// e.g. preempted, as reported by the instance events subscription. Its value is the reason of the last event
+	// followed by its RFC 3339 time, e.g. "Preempted 2021-07-01T10:00:00Z", so that remediation tooling can
+	// act on the Machine before its node is reported unhealthy.
+	InstanceEventAnnotation = "infrastructure.cluster.x-k8s.io/instance-event"
+
+	// PendingRestartAnnotation is set by the controllers on the GCPMachines whose running instance has been stopped
+	// to change its machine type, until the instance is started again. The stopped instance is restarted by the next
+	// reconciles, rather than failing the GCPMachine as a terminated instance, if the change or the start failed.
+	PendingRestartAnnotation = "infrastructure.cluster.x-k8s.io/pending-restart"
+
+	// ManagedMetadataAnnotation is set by the controllers on the GCPMachines to the comma-separated keys of the
+	// AdditionalMetadata set on their instance, so that the keys later removed from the AdditionalMetadata are
+	// removed from the instance too. Unlike the status, the annotation is moved by clusterctl move.
+	ManagedMetadataAnnotation = "infrastructure.cluster.x-k8s.io/managed-metadata"
+)
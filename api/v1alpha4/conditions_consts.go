@@ -0,0 +1,198 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+const (
+	// InstanceReadyCondition reports on the current status of the GCE instance. Ready indicates the instance is running.
+	InstanceReadyCondition clusterv1.ConditionType = "InstanceReady"
+
+	// InstanceProvisioningReason used when the instance is being provisioned.
+	InstanceProvisioningReason = "InstanceProvisioning"
+	// InstanceProvisioningTimedOutReason used when the instance hasn't started running within the provisioning timeout.
+	InstanceProvisioningTimedOutReason = "InstanceProvisioningTimedOut"
+	// InstanceRepairingReason used when the instance is being repaired by GCE, e.g. after a host failure.
+	InstanceRepairingReason = "InstanceRepairing"
+	// InstanceStoppedReason used when the instance is stopping or stopped.
+	InstanceStoppedReason = "InstanceStopped"
+	// InstanceSuspendedReason used when the instance is suspending or suspended.
+	InstanceSuspendedReason = "InstanceSuspended"
+	// InstanceTerminatedReason used when the instance has been terminated.
+	InstanceTerminatedReason = "InstanceTerminated"
+	// InstanceRestartingReason used when the terminated instance is being started again by its repair policy.
+	InstanceRestartingReason = "InstanceRestarting"
+	// InstanceOrgPolicyViolationReason used when the instance can't be created because it violates an org policy constraint.
+	InstanceOrgPolicyViolationReason = "OrgPolicyViolation"
+	// DefaultServiceAccountNotAllowedReason used when the instance can't be created because it would use the
+	// default compute service account while the controller requires an explicit service account.
+	DefaultServiceAccountNotAllowedReason = "DefaultServiceAccountNotAllowed"
+	// InstanceTypeUnavailableReason used when the instance can't be created because its machine type, or an accelerator
+	// type with its count per instance, isn't offered in its zone.
+	InstanceTypeUnavailableReason = "InstanceTypeUnavailable"
+	// AcceleratorsUnavailableReason used when the instance can't be created because no zone of the region offers its
+	// accelerator types, with their count per instance.
+	AcceleratorsUnavailableReason = "AcceleratorsUnavailable"
+	// ExistingInstanceNotFoundReason used when the existing instance to adopt doesn't exist.
+	ExistingInstanceNotFoundReason = "ExistingInstanceNotFound"
+	// InstanceDeletedReason used when the instance has been deleted outside of Cluster API.
+	InstanceDeletedReason = "InstanceDeleted"
+	// InstanceImmutableFieldChangedReason used when the instance differs from its GCPMachine in a field which can't be
+	// updated in place.
+	InstanceImmutableFieldChangedReason = "InstanceImmutableFieldChanged"
+	// InstanceNotRunningReason used when the instance is in an unexpected state.
+	InstanceNotRunningReason = "InstanceNotRunning"
+)
+
+const (
+	// BootstrapDataReadyCondition reports whether the bootstrap data secret of the Machine is available, the instance
+	// being created once it is. It's true for the GCPMachines adopting an existing instance.
+	BootstrapDataReadyCondition clusterv1.ConditionType = "BootstrapDataReady"
+
+	// WaitingForBootstrapDataReason used when the bootstrap data secret of the Machine isn't available yet.
+	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+)
+
+const (
+	// BootstrapSucceededCondition reports whether the bootstrap data has been successfully
+	// applied on the GCE instance, as reported by the instance itself through the
+	// BootstrapStatusGuestAttribute guest attribute. It doesn't depend on the node
+	// registering with the workload cluster.
+	BootstrapSucceededCondition clusterv1.ConditionType = "BootstrapSucceeded"
+
+	// WaitingForBootstrapStatusReason used when the instance hasn't reported its bootstrap status yet.
+	WaitingForBootstrapStatusReason = "WaitingForBootstrapStatus"
+	// BootstrapFailedReason used when the instance reported that its bootstrap failed.
+	BootstrapFailedReason = "BootstrapFailed"
+	// BootstrapTimedOutReason used when the instance hasn't reported its bootstrap status within the bootstrap timeout.
+	BootstrapTimedOutReason = "BootstrapTimedOut"
+	// BootstrapStatusUnreportedReason used when the instance hasn't reported its bootstrap status within the
+	// bootstrap status window, without bootstrap timeout, its status being no longer polled.
+	BootstrapStatusUnreportedReason = "BootstrapStatusUnreported"
+)
+
+const (
+	// InstanceUndisruptedCondition reports whether the GCE instance has been disrupted, e.g. preempted or
+	// restarted after a host error, as reported by the instance events subscription. It's only set, to false,
+	// once an event has been reported for the instance and isn't reset: the Machine is expected to be remediated.
+	InstanceUndisruptedCondition clusterv1.ConditionType = "InstanceUndisrupted"
+
+	// InstancePreemptedReason used when the instance has been preempted.
+	InstancePreemptedReason = "InstancePreempted"
+	// InstanceHostMaintenanceReason used when the instance has been terminated because of a host maintenance.
+	InstanceHostMaintenanceReason = "InstanceHostMaintenance"
+	// InstanceHostErrorReason used when the instance has been restarted after a host error.
+	InstanceHostErrorReason = "InstanceHostError"
+	// InstanceAutohealingReason used when the instance has been recreated by the autohealing of its instance group.
+	InstanceAutohealingReason = "InstanceAutohealing"
+)
+
+const (
+	// APIServerBackendHealthyCondition reports whether the instance of a control plane machine is registered in the
+	// API server instance group or network endpoint group of its zone and reported healthy by the load balancer.
+	// It's only set with the Proxy load balancers.
+	APIServerBackendHealthyCondition clusterv1.ConditionType = "APIServerBackendHealthy"
+
+	// InstanceGroupRegistrationFailedReason used when the instance can't be registered in the API server instance group.
+	InstanceGroupRegistrationFailedReason = "InstanceGroupRegistrationFailed"
+	// NetworkEndpointRegistrationFailedReason used when the instance can't be attached to the API server network endpoint group.
+	NetworkEndpointRegistrationFailedReason = "NetworkEndpointRegistrationFailed"
+	// WaitingForBackendHealthReason used when the load balancer hasn't reported the health of the instance yet.
+	WaitingForBackendHealthReason = "WaitingForBackendHealth"
+	// APIServerBackendUnhealthyReason used when the load balancer reports the instance as unhealthy.
+	APIServerBackendUnhealthyReason = "APIServerBackendUnhealthy"
+)
+
+const (
+	// APIServerBackendDrainedCondition reports whether the instance of a deleted control plane machine has left the
+	// API server load balancer, and its connections have been drained, so that it can be deleted. It's only set with
+	// the Proxy load balancers.
+	APIServerBackendDrainedCondition clusterv1.ConditionType = "APIServerBackendDrained"
+
+	// WaitingForHealthyAPIServerBackendReason used when the instance is kept in the load balancer until another
+	// control plane instance is reported healthy by it, so that the API server stays reachable.
+	WaitingForHealthyAPIServerBackendReason = "WaitingForHealthyAPIServerBackend"
+	// DrainingAPIServerConnectionsReason used when the instance has left the load balancer and is deleted once the
+	// connection draining timeout of the backend service elapsed.
+	DrainingAPIServerConnectionsReason = "DrainingAPIServerConnections"
+)
+
+const (
+	// FeaturesAvailableCondition reports whether the features requested by the spec of a GCPCluster or a GCPMachine
+	// are available in the environment of the controllers, e.g. when the GCP APIs are only reachable through the
+	// Private or Restricted Google Access. The unavailable features are listed in its message.
+	FeaturesAvailableCondition clusterv1.ConditionType = "FeaturesAvailable"
+
+	// GoogleAccessRestrictedReason used when features require a public egress unavailable with the Google access
+	// of the controllers.
+	GoogleAccessRestrictedReason = "GoogleAccessRestricted"
+)
+
+const (
+	// DisruptiveChangesAppliedCondition reports whether the disruptive changes of the GCP resources of a GCPCluster
+	// with a maintenance window have been applied, or are deferred to its next window. The deferred changes are listed
+	// in its message. It's only set on the GCPClusters with a maintenance window.
+	DisruptiveChangesAppliedCondition clusterv1.ConditionType = "DisruptiveChangesApplied"
+
+	// WaitingForMaintenanceWindowReason used when disruptive changes are deferred to the next maintenance window.
+	WaitingForMaintenanceWindowReason = "WaitingForMaintenanceWindow"
+)
+
+const (
+	// PreflightChecksPassedCondition reports whether the project of a GCPCluster has the APIs enabled, and the quotas
+	// available, that its resources need. It's checked before the resources are created, and only set if the
+	// preflight checks are enabled.
+	PreflightChecksPassedCondition clusterv1.ConditionType = "PreflightChecksPassed"
+
+	// PreflightCheckFailedReason used when an API is disabled, or a quota is exhausted, in the project of the cluster.
+	PreflightCheckFailedReason = "PreflightCheckFailed"
+)
+
+const (
+	// NetworkReadyCondition reports whether the network of a GCPCluster, with its subnetworks, routers and
+	// Cloud NAT, has been reconciled.
+	NetworkReadyCondition clusterv1.ConditionType = "NetworkReady"
+	// FirewallsReadyCondition reports whether the firewall rules of a GCPCluster have been reconciled.
+	FirewallsReadyCondition clusterv1.ConditionType = "FirewallsReady"
+	// LoadBalancerReadyCondition reports whether the control plane load balancer of a GCPCluster, with its
+	// backend groups, has been reconciled and its address allocated.
+	LoadBalancerReadyCondition clusterv1.ConditionType = "LoadBalancerReady"
+
+	// NetworkReconciliationFailedReason used when the network can't be reconciled.
+	NetworkReconciliationFailedReason = "NetworkReconciliationFailed"
+	// FirewallsReconciliationFailedReason used when the firewall rules can't be reconciled.
+	FirewallsReconciliationFailedReason = "FirewallsReconciliationFailed"
+	// LoadBalancerReconciliationFailedReason used when the load balancer can't be reconciled.
+	LoadBalancerReconciliationFailedReason = "LoadBalancerReconciliationFailed"
+	// WaitingForAPIServerAddressReason used when the address of the load balancer isn't allocated yet.
+	WaitingForAPIServerAddressReason = "WaitingForAPIServerAddress"
+)
+
+const (
+	// DeletionProtectedReason used when the GCP resources of a GCPCluster with the DeletionProtectionAnnotation, or
+	// the instances of its machines and machine pools, aren't deleted along with the Cluster.
+	DeletionProtectedReason = "DeletionProtected"
+)
+
+const (
+	// BootstrapStatusGuestAttribute is the guest attribute, in the <namespace>/<key> form,
+	// the bootstrap process writes on the instance once it has completed.
+	BootstrapStatusGuestAttribute = "capi/bootstrap"
+	// BootstrapStatusSuccess is the value of the BootstrapStatusGuestAttribute reporting
+	// a successful bootstrap. Any other value reports a failure.
+	BootstrapStatusSuccess = "success"
+)
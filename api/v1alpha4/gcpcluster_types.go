@@ -25,6 +25,17 @@ const (
 	// ClusterFinalizer allows ReconcileGCPCluster to clean up GCP resources associated with GCPCluster before
 	// removing it from the apiserver.
 	ClusterFinalizer = "gcpcluster.infrastructure.cluster.x-k8s.io"
+
+	// LoadBalancerFinalizer, FirewallFinalizer and NetworkFinalizer are removed from the GCPCluster once its
+	// load balancer and instance groups, its firewall rules, and its network respectively are deleted, so that
+	// the finalizers left on a GCPCluster stuck in deletion show which of them blocks it.
+	LoadBalancerFinalizer = "gcpcluster.infrastructure.cluster.x-k8s.io/load-balancer"
+	FirewallFinalizer     = "gcpcluster.infrastructure.cluster.x-k8s.io/firewall"
+	NetworkFinalizer      = "gcpcluster.infrastructure.cluster.x-k8s.io/network"
+
+	// IneligibleReasonAttribute is the attribute of the failure domains made ineligible for the control plane
+	// because of an incident in their zone, e.g. the zone being down or out of resources, set to the reason.
+	IneligibleReasonAttribute = "ineligibleReason"
 )
 
 // GCPClusterSpec defines the desired state of GCPCluster.
@@ -36,9 +47,18 @@ type GCPClusterSpec struct {
 	Region string `json:"region"`
 
 	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane.
+	// It defaults to the IP address of the API server load balancer. Its host can be set to a DNS
+	// name resolving to this address instead, the certificates of the control plane are then issued
+	// for the name. It can't be changed once set.
 	// +optional
 	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
 
+	// ControlPlaneDNS, if set, publishes the address of the API server load balancer in a Cloud DNS record and
+	// sets the host of the ControlPlaneEndpoint to its name, so that the endpoint is stable across the re-creation
+	// of the load balancer. It can't be changed once set.
+	// +optional
+	ControlPlaneDNS *ControlPlaneDNSSpec `json:"controlPlaneDNS,omitempty"`
+
 	// NetworkSpec encapsulates all things related to GCP network.
 	// +optional
 	Network NetworkSpec `json:"network"`
@@ -49,18 +69,188 @@ type GCPClusterSpec struct {
 	// +optional
 	FailureDomains []string `json:"failureDomains,omitempty"`
 
+	// ExcludedFailureDomains is an optional list of zones of the region which are never used as
+	// failure domains, e.g. because they lack a machine type. It applies after FailureDomains.
+	// +optional
+	ExcludedFailureDomains []string `json:"excludedFailureDomains,omitempty"`
+
+	// AdditionalControlPlaneRegions are the regions the control plane machines can run in besides Region,
+	// for a control plane stretched across regions. Their zones are failure domains too, whose control plane
+	// instances are backends of the global anycast address of the Proxy load balancer, like those of Region.
+	// The network needs a subnet in each of them unless it auto creates its subnetworks, and the Cloud NAT
+	// is only created in Region. It's only supported with the Proxy load balancer.
+	// +optional
+	AdditionalControlPlaneRegions []string `json:"additionalControlPlaneRegions,omitempty"`
+
 	// AdditionalLabels is an optional set of tags to add to GCP resources managed by the GCP provider, in addition to the
-	// ones added by default.
+	// ones added by default. They are applied to the instances, their persistent disks and the forwarding rule of the
+	// API server, and restored on these resources when changed. The addresses don't support labels.
 	// +optional
 	AdditionalLabels Labels `json:"additionalLabels,omitempty"`
+
+	// ResourceNamePrefix overrides the cluster name as the prefix of the names of the load balancer
+	// components, instance groups and firewall rules of the cluster, e.g. to follow naming conventions.
+	// The network and router names derive from Network.Name instead. The resources remain owned by
+	// the cluster through their labels or description, and the field can't be changed once set.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	ResourceNamePrefix *string `json:"resourceNamePrefix,omitempty"`
+
+	// SecurityProfile selects the defaults applied to the instances of the cluster when their
+	// GCPMachine doesn't configure them explicitly, defaults to Default.
+	// +kubebuilder:validation:Enum=Default;Hardened
+	// +optional
+	SecurityProfile SecurityProfile `json:"securityProfile,omitempty"`
+
+	// Bastion, if set, creates a bastion host in the network of the cluster, from which the
+	// instances of the cluster can be reached over SSH.
+	// +optional
+	Bastion *BastionSpec `json:"bastion,omitempty"`
+
+	// IAPAccess, if true, allows SSH to the instances of the cluster through the IAP TCP forwarding,
+	// e.g. with gcloud compute ssh --tunnel-through-iap, without public IPs nor SSH open to the internet.
+	// The instances are tagged with <cluster name>-iap-ssh, targeted by the firewall rule allowing the
+	// IAP range.
+	// +optional
+	IAPAccess bool `json:"iapAccess,omitempty"`
+
+	// BreakGlassSSH, if set, generates an SSH key pair for the emergency access to the instances of the cluster.
+	// The private key is stored in the <cluster name>-break-glass-ssh Secret of the namespace of the GCPCluster,
+	// and the public key is added to the ssh-keys metadata of the instances created afterwards. The key isn't
+	// honored by the instances with OS Login enabled.
+	// +optional
+	BreakGlassSSH *BreakGlassSSHSpec `json:"breakGlassSSH,omitempty"`
+
+	// RegionalAPIEndpoint, if true, sends the compute API calls on the resources of the region of the cluster,
+	// and of its zones, to the regional service endpoint of the region, reducing their latency and their
+	// exposure to the incidents of the global endpoint. The global resources, e.g. the load balancer
+	// components and firewall rules, are still managed through the global endpoint.
+	// +optional
+	RegionalAPIEndpoint bool `json:"regionalAPIEndpoint,omitempty"`
+
+	// LoadBalancer configures the load balancer of the API server.
+	// +optional
+	LoadBalancer LoadBalancerSpec `json:"loadBalancer,omitempty"`
+
+	// Reservations are the zonal capacity reservations of the cluster, guaranteeing the capacity of
+	// the machines consuming them, e.g. to scale up critical machine pools. A reservation is resized
+	// when its count changes and deleted when removed from the list.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Reservations []ReservationSpec `json:"reservations,omitempty"`
+
+	// SoleTenantNodeGroups are the sole-tenant node groups of the cluster, dedicated hosts on which the
+	// GCPMachines targeting them run. A node group is resized when its size changes and deleted, with its
+	// node template, when removed from the list.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	SoleTenantNodeGroups []SoleTenantNodeGroupSpec `json:"soleTenantNodeGroups,omitempty"`
+
+	// MachineDefaults are inherited by the GCPMachines of the cluster which don't set them, to avoid
+	// repeating them in the GCPMachineTemplates of each MachineDeployment. Their changes apply to the
+	// instances created afterwards, except the labels which are also updated on the existing instances.
+	// +optional
+	MachineDefaults *MachineDefaults `json:"machineDefaults,omitempty"`
+
+	// MaintenanceWindow, if set, defers the disruptive changes of the GCP resources of the cluster to the
+	// recurring window: the recreation of the forwarding rule of the load balancer, the updates of its backend
+	// service, e.g. of its instance groups, of the firewall rules and the recreation of the routes. The pending
+	// changes are reported by the DisruptiveChangesApplied condition. The creations are never deferred.
+	// +optional
+	MaintenanceWindow *MaintenanceWindowSpec `json:"maintenanceWindow,omitempty"`
 }
 
+// MachineDefaults are the defaults of the GCPMachines of a cluster.
+type MachineDefaults struct {
+	// Image is the full reference to the image of the instances, used unless the GCPMachine sets an Image,
+	// ImageFamily or ImageLookup. Takes precedence over ImageFamily.
+	// +optional
+	Image *string `json:"image,omitempty"`
+
+	// ImageFamily is the full reference to the image family of the instances, used unless the GCPMachine
+	// sets an Image, ImageFamily or ImageLookup.
+	// +optional
+	ImageFamily *string `json:"imageFamily,omitempty"`
+
+	// ServiceAccount is the service account of the instances, used unless the GCPMachine sets one.
+	// +optional
+	ServiceAccount *ServiceAccount `json:"serviceAccount,omitempty"`
+
+	// AdditionalNetworkTags are the network tags of the instances, used unless the GCPMachine sets its own.
+	// +optional
+	AdditionalNetworkTags []string `json:"additionalNetworkTags,omitempty"`
+
+	// AdditionalLabels are added to the instances and their persistent disks, the AdditionalLabels of the
+	// GCPMachine taking precedence.
+	// +optional
+	AdditionalLabels Labels `json:"additionalLabels,omitempty"`
+
+	// Subnet is the subnetwork of the instances, used unless the GCPMachine sets one.
+	// +optional
+	Subnet *string `json:"subnet,omitempty"`
+
+	// PublicIP specifies whether the instances get a public IP, used unless the GCPMachine sets it. Defaults
+	// to false: the instances egress through the Cloud NAT of the network, and are reached over SSH through
+	// the IAP TCP forwarding, see IAPAccess, or the bastion host.
+	// +optional
+	PublicIP *bool `json:"publicIP,omitempty"`
+}
+
+// SecurityProfile is a set of defaults applied to the instances of a cluster.
+type SecurityProfile string
+
+const (
+	// SecurityProfileDefault creates the instances with the GCE defaults: the default compute
+	// service account with the cloud-platform scope, without Shielded VM nor OS Login.
+	SecurityProfileDefault SecurityProfile = "Default"
+
+	// SecurityProfileHardened creates the instances with Shielded VM and OS Login enabled, and the
+	// default compute service account with the minimal logging, monitoring and storage read-only scopes.
+	// The images must support Shielded VM, i.e. have the UEFI_COMPATIBLE guest OS feature.
+	SecurityProfileHardened SecurityProfile = "Hardened"
+)
+
 // GCPClusterStatus defines the observed state of GCPCluster.
 type GCPClusterStatus struct {
 	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
 	Network        Network                  `json:"network,omitempty"`
 
-	// Bastion Instance `json:"bastion,omitempty"`
+	// Operations is a map from the path of a GCP resource, e.g. global/firewalls/my-rule,
+	// to the full reference of the operation in progress on it, e.g. its insert or update.
+	// +optional
+	Operations map[string]string `json:"operations,omitempty"`
+
+	// OwnedResources is the inventory of the GCP resources created or adopted by the cluster,
+	// by their path, e.g. global/firewalls/my-rule.
+	// +optional
+	OwnedResources []string `json:"ownedResources,omitempty"`
+
+	// Bastion is the bastion host of the cluster, if any.
+	// +optional
+	Bastion *BastionStatus `json:"bastion,omitempty"`
+
+	// BreakGlassSSH is the break-glass SSH key pair of the cluster, if any.
+	// +optional
+	BreakGlassSSH *BreakGlassSSHStatus `json:"breakGlassSSH,omitempty"`
+
+	// Reservations are the capacity reservations of the cluster.
+	// +optional
+	Reservations []ReservationStatus `json:"reservations,omitempty"`
+
+	// SoleTenantNodeGroups are the sole-tenant node groups of the cluster.
+	// +optional
+	SoleTenantNodeGroups []SoleTenantNodeGroupStatus `json:"soleTenantNodeGroups,omitempty"`
+
+	// ObservedGeneration is the generation of the GCPCluster the GCP resources were last reconciled for.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Conditions defines current service state of the GCPCluster.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+
 	Ready bool `json:"ready"`
 }
 
@@ -91,6 +281,16 @@ type GCPClusterList struct {
 	Items           []GCPCluster `json:"items"`
 }
 
+// GetConditions returns the observations of the operational state of the GCPCluster resource.
+func (r *GCPCluster) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the GCPCluster to the predescribed clusterv1.Conditions.
+func (r *GCPCluster) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 func init() {
 	SchemeBuilder.Register(&GCPCluster{}, &GCPClusterList{})
 }
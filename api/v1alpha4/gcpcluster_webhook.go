@@ -17,11 +17,20 @@ limitations under the License.
 package v1alpha4
 
 import (
+	"fmt"
+	"net"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
@@ -30,6 +39,17 @@ import (
 // clusterlog is for logging in this package.
 var clusterlog = logf.Log.WithName("gcpcluster-resource")
 
+var (
+	// projectPattern matches the ID of a project, e.g. my-project, optionally scoped to a domain, e.g. example.com:my-project.
+	projectPattern = regexp.MustCompile(`^([a-z0-9.-]+:)?[a-z][-a-z0-9]{4,28}[a-z0-9]$`)
+
+	// regionPattern matches the name of a region, e.g. us-central1 or northamerica-northeast1.
+	regionPattern = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+$`)
+
+	// zonePattern matches the name of a zone, e.g. us-central1-a, capturing its region.
+	zonePattern = regexp.MustCompile(`^([a-z]+-[a-z]+[0-9]+)-[a-z]$`)
+)
+
 // SetupWebhookWithManager sets up and registers the webhook with the manager.
 func (c *GCPCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
@@ -37,7 +57,7 @@ func (c *GCPCluster) SetupWebhookWithManager(mgr ctrl.Manager) error {
 		Complete()
 }
 
-// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha4-gcpcluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=gcpclusters,versions=v1alpha4,name=validation.gcpcluster.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha4-gcpcluster,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=gcpclusters,versions=v1alpha4,name=validation.gcpcluster.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
 // +kubebuilder:webhook:verbs=create;update,path=/mutate-infrastructure-cluster-x-k8s-io-v1alpha4-gcpcluster,mutating=true,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=gcpclusters,versions=v1alpha4,name=default.gcpcluster.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
 
 var _ webhook.Validator = &GCPCluster{}
@@ -46,32 +66,135 @@ var _ webhook.Defaulter = &GCPCluster{}
 // Default implements webhook.Defaulter so a webhook will be registered for the type.
 func (c *GCPCluster) Default() {
 	clusterlog.Info("default", "name", c.Name)
+
+	// The instances are private unless the defaults, or their GCPMachine, ask for a public IP.
+	if c.Spec.MachineDefaults != nil && c.Spec.MachineDefaults.PublicIP == nil {
+		c.Spec.MachineDefaults.PublicIP = pointer.BoolPtr(false)
+	}
+	if c.Spec.MachineDefaults != nil {
+		defaultServiceAccount(c.Spec.MachineDefaults.ServiceAccount)
+	}
+
+	// The default network is used unless set, the name being recorded so that its changes are detected.
+	if c.Spec.Network.Name == nil {
+		c.Spec.Network.Name = pointer.StringPtr(networkName(c.Spec.Network))
+	}
+
+	// The subnetworks are created in the region of the cluster unless set.
+	for _, subnet := range c.Spec.Network.Subnets {
+		if subnet != nil && subnet.Region == "" {
+			subnet.Region = c.Spec.Region
+		}
+	}
 }
 
 // ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
 func (c *GCPCluster) ValidateCreate() error {
 	clusterlog.Info("validate create", "name", c.Name)
 
+	var allErrs field.ErrorList
+	for _, v := range clusterValidations {
+		allErrs = append(allErrs, v.validate(c)...)
+	}
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPCluster").GroupKind(), c.Name, allErrs)
+	}
+
 	return nil
 }
 
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
 func (c *GCPCluster) ValidateUpdate(oldRaw runtime.Object) error {
 	clusterlog.Info("validate update", "name", c.Name)
-	var allErrs field.ErrorList
 	old := oldRaw.(*GCPCluster)
 
+	// Only the fields changed by the update are checked again, the GCPClusters created before a check was
+	// introduced can still be updated.
+	var allErrs field.ErrorList
+	for _, v := range clusterValidations {
+		if !reflect.DeepEqual(v.fields(c), v.fields(old)) {
+			allErrs = append(allErrs, v.validate(c)...)
+		}
+	}
+
+	// The certificates of the control plane are issued for the endpoint, it can't change once set,
+	// except for the port of a DNS name which is defaulted by the controller.
+	if oldEndpoint, endpoint := old.Spec.ControlPlaneEndpoint, c.Spec.ControlPlaneEndpoint; oldEndpoint.Host != "" &&
+		(endpoint.Host != oldEndpoint.Host || (oldEndpoint.Port != 0 && endpoint.Port != oldEndpoint.Port)) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "ControlPlaneEndpoint"),
+				c.Spec.ControlPlaneEndpoint, "field is immutable once set, the certificates of the control plane are issued for it"),
+		)
+	}
+
+	// The name of the record is the host of the endpoint, which can't change either.
+	if old.Spec.ControlPlaneDNS != nil && !reflect.DeepEqual(c.Spec.ControlPlaneDNS, old.Spec.ControlPlaneDNS) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "ControlPlaneDNS"),
+				c.Spec.ControlPlaneDNS, "field is immutable once set"),
+		)
+	}
+
+	// The GCP resources of a cluster can't be moved across projects, regions or networks, changing
+	// them would orphan the existing resources, so the errors explain how to migrate instead.
 	if !reflect.DeepEqual(c.Spec.Project, old.Spec.Project) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "Project"),
-				c.Spec.Project, "field is immutable"),
+				c.Spec.Project, "field is immutable, the GCP resources of a cluster can't be moved to another project: "+
+					"create a new cluster in the project and migrate the workloads to it"),
 		)
 	}
 
 	if !reflect.DeepEqual(c.Spec.Region, old.Spec.Region) {
 		allErrs = append(allErrs,
 			field.Invalid(field.NewPath("spec", "Region"),
-				c.Spec.Region, "field is immutable"),
+				c.Spec.Region, "field is immutable, the GCP resources of a cluster can't be moved to another region: "+
+					"create a new cluster in the region and migrate the workloads to it"),
+		)
+	}
+
+	if networkName(c.Spec.Network) != networkName(old.Spec.Network) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "Network", "Name"),
+				networkName(c.Spec.Network), fmt.Sprintf("field is immutable, the instances and load balancer of a cluster can't be moved to another network: "+
+					"keep %q, or create a new cluster in the network and migrate the workloads to it", networkName(old.Spec.Network))),
+		)
+	}
+
+	// The existing rules of the cluster wouldn't be migrated to the new kind of rules or to the new policy.
+	if firewallPolicyMode(c.Spec.Network) != firewallPolicyMode(old.Spec.Network) ||
+		(firewallPolicyMode(c.Spec.Network) == FirewallPolicyModeNetwork && c.Spec.Network.FirewallPolicy.Name != old.Spec.Network.FirewallPolicy.Name) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "Network", "FirewallPolicy"),
+				c.Spec.Network.FirewallPolicy, "the mode and name are immutable, the existing firewall rules of the cluster wouldn't be migrated"),
+		)
+	}
+
+	if loadBalancerType(c.Spec.LoadBalancer) != loadBalancerType(old.Spec.LoadBalancer) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "Type"),
+				c.Spec.LoadBalancer.Type, "field is immutable, the address of the load balancer would change"),
+		)
+	}
+
+	if loadBalancerBackendType(c.Spec.LoadBalancer) != loadBalancerBackendType(old.Spec.LoadBalancer) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "BackendType"),
+				c.Spec.LoadBalancer.BackendType, "field is immutable, the backends of the existing control plane instances wouldn't be migrated"),
+		)
+	}
+
+	if loadBalancerScheme(c.Spec.LoadBalancer) != loadBalancerScheme(old.Spec.LoadBalancer) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "Scheme"),
+				c.Spec.LoadBalancer.Scheme, "field is immutable, the address of the load balancer would change"),
+		)
+	}
+
+	if !reflect.DeepEqual(c.Spec.ResourceNamePrefix, old.Spec.ResourceNamePrefix) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "ResourceNamePrefix"),
+				c.Spec.ResourceNamePrefix, "field is immutable"),
 		)
 	}
 
@@ -86,5 +209,555 @@ func (c *GCPCluster) ValidateUpdate(oldRaw runtime.Object) error {
 func (c *GCPCluster) ValidateDelete() error {
 	clusterlog.Info("validate delete", "name", c.Name)
 
+	if _, ok := c.Annotations[DeletionProtectionAnnotation]; ok {
+		return apierrors.NewForbidden(GroupVersion.WithResource("gcpclusters").GroupResource(), c.Name,
+			errors.Errorf("deletion protection is enabled, remove the %s annotation to delete the cluster", DeletionProtectionAnnotation))
+	}
+
 	return nil
 }
+
+// clusterValidation is a check of a GCPCluster with the fields it checks.
+type clusterValidation struct {
+	validate func(*GCPCluster) field.ErrorList
+	fields   func(*GCPCluster) []interface{}
+}
+
+// clusterValidations are the checks of the GCPClusters.
+var clusterValidations = []clusterValidation{
+	{
+		validate: (*GCPCluster).validateAnnotations,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Annotations[RetainAnnotation]} },
+	},
+	{
+		validate: (*GCPCluster).validateControlPlaneEndpoint,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.ControlPlaneEndpoint} },
+	},
+	{
+		validate: (*GCPCluster).validateLocation,
+		fields: func(c *GCPCluster) []interface{} {
+			return []interface{}{c.Spec.Project, c.Spec.Region, c.Spec.AdditionalControlPlaneRegions, c.Spec.FailureDomains,
+				c.Spec.ExcludedFailureDomains, c.Spec.Network.Name}
+		},
+	},
+	{
+		validate: (*GCPCluster).validateLoadBalancer,
+		fields: func(c *GCPCluster) []interface{} {
+			return []interface{}{c.Spec.LoadBalancer, c.Spec.ControlPlaneEndpoint, c.Spec.ControlPlaneDNS}
+		},
+	},
+	{
+		validate: (*GCPCluster).validateLoadBalancerTuning,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.LoadBalancer} },
+	},
+	{
+		validate: (*GCPCluster).validateControlPlaneRegions,
+		fields: func(c *GCPCluster) []interface{} {
+			return []interface{}{c.Spec.Region, c.Spec.AdditionalControlPlaneRegions, c.Spec.LoadBalancer}
+		},
+	},
+	{
+		validate: (*GCPCluster).validateSubnets,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.Region, c.Spec.Network.Subnets} },
+	},
+	{
+		validate: (*GCPCluster).validateRoutes,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.Network.Routes} },
+	},
+	{
+		validate: (*GCPCluster).validateFirewallRules,
+		fields: func(c *GCPCluster) []interface{} {
+			return []interface{}{c.Spec.Network.AdditionalFirewallRules, c.Spec.Network.FirewallPolicy}
+		},
+	},
+	{
+		validate: (*GCPCluster).validateDNS,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.Network.DNS} },
+	},
+	{
+		validate: (*GCPCluster).validateControlPlaneDNS,
+		fields: func(c *GCPCluster) []interface{} {
+			return []interface{}{c.Spec.ControlPlaneDNS, c.Spec.ControlPlaneEndpoint}
+		},
+	},
+	{
+		validate: (*GCPCluster).validateNAT,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.Network.NAT} },
+	},
+	{
+		validate: (*GCPCluster).validateMaintenanceWindow,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.MaintenanceWindow} },
+	},
+	{
+		validate: (*GCPCluster).validateMachineDefaults,
+		fields:   func(c *GCPCluster) []interface{} { return []interface{}{c.Spec.MachineDefaults} },
+	},
+}
+
+// networkName returns the name of the network, which defaults to the default network.
+func networkName(spec NetworkSpec) string {
+	if spec.Name != nil {
+		return *spec.Name
+	}
+
+	return "default"
+}
+
+// loadBalancerType returns the type of the load balancer, which defaults to a proxy.
+func loadBalancerType(spec LoadBalancerSpec) LoadBalancerType {
+	if spec.Type == "" {
+		return LoadBalancerTypeProxy
+	}
+
+	return spec.Type
+}
+
+// loadBalancerBackendType returns the type of the backends of the load balancer, which defaults to instance groups.
+func loadBalancerBackendType(spec LoadBalancerSpec) LoadBalancerBackendType {
+	if spec.BackendType == "" {
+		return LoadBalancerBackendInstanceGroup
+	}
+
+	return spec.BackendType
+}
+
+// loadBalancerScheme returns the scheme of the load balancer, which defaults to external.
+func loadBalancerScheme(spec LoadBalancerSpec) LoadBalancerScheme {
+	if spec.Scheme == "" {
+		return LoadBalancerSchemeExternal
+	}
+
+	return spec.Scheme
+}
+
+// validateLocation checks the syntax of the project, the regions, the failure domains and the network, so that
+// a typo is reported when the GCPCluster is applied instead of as a GCE API error, and the failure domains are
+// zones of the control plane regions.
+func (c *GCPCluster) validateLocation() field.ErrorList {
+	var allErrs field.ErrorList
+	if !projectPattern.MatchString(c.Spec.Project) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "Project"), c.Spec.Project, "must be the ID of a project, e.g. my-project"))
+	}
+	if !regionPattern.MatchString(c.Spec.Region) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "Region"), c.Spec.Region, "must be the name of a region, e.g. us-central1"))
+	}
+
+	regions := map[string]bool{c.Spec.Region: true}
+	for i, region := range c.Spec.AdditionalControlPlaneRegions {
+		if !regionPattern.MatchString(region) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "AdditionalControlPlaneRegions").Index(i), region, "must be the name of a region, e.g. us-east1"))
+		}
+		regions[region] = true
+	}
+	for _, zones := range []struct {
+		path  *field.Path
+		zones []string
+	}{
+		{field.NewPath("spec", "FailureDomains"), c.Spec.FailureDomains},
+		{field.NewPath("spec", "ExcludedFailureDomains"), c.Spec.ExcludedFailureDomains},
+	} {
+		for i, zone := range zones.zones {
+			m := zonePattern.FindStringSubmatch(zone)
+			switch {
+			case m == nil:
+				allErrs = append(allErrs, field.Invalid(zones.path.Index(i), zone, "must be the name of a zone, e.g. us-central1-a"))
+			case !regions[m[1]]:
+				allErrs = append(allErrs, field.Invalid(zones.path.Index(i), zone, "must be a zone of the region of the cluster or of an additional control plane region"))
+			}
+		}
+	}
+
+	if c.Spec.Network.Name != nil {
+		for _, msg := range validation.IsDNS1035Label(*c.Spec.Network.Name) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "Network", "Name"), *c.Spec.Network.Name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// validateLoadBalancer checks the backend type and the scheme are only set on a Proxy load balancer, an Internal
+// load balancer balances the traffic to instance groups, the control plane endpoint is set without load balancer,
+// and only an External Proxy load balancer is dual-stack.
+func (c *GCPCluster) validateLoadBalancer() field.ErrorList {
+	var allErrs field.ErrorList
+	if c.Spec.LoadBalancer.BackendType != "" && loadBalancerType(c.Spec.LoadBalancer) != LoadBalancerTypeProxy {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "BackendType"),
+				c.Spec.LoadBalancer.BackendType, "only a Proxy load balancer has backends"),
+		)
+	}
+	if c.Spec.LoadBalancer.Scheme != "" && loadBalancerType(c.Spec.LoadBalancer) != LoadBalancerTypeProxy {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "Scheme"),
+				c.Spec.LoadBalancer.Scheme, "only a Proxy load balancer has a scheme"),
+		)
+	}
+	if loadBalancerType(c.Spec.LoadBalancer) == LoadBalancerTypeNone && c.Spec.ControlPlaneEndpoint.Host == "" {
+		allErrs = append(allErrs,
+			field.Required(field.NewPath("spec", "ControlPlaneEndpoint", "Host"), "the control plane endpoint is managed outside of the provider without load balancer"),
+		)
+	}
+	if loadBalancerType(c.Spec.LoadBalancer) == LoadBalancerTypeNone && c.Spec.ControlPlaneDNS != nil {
+		allErrs = append(allErrs,
+			field.Forbidden(field.NewPath("spec", "ControlPlaneDNS"), "there is no load balancer address to publish"),
+		)
+	}
+	if c.Spec.LoadBalancer.StackType == StackTypeIPv4IPv6 && (loadBalancerType(c.Spec.LoadBalancer) != LoadBalancerTypeProxy ||
+		loadBalancerScheme(c.Spec.LoadBalancer) != LoadBalancerSchemeExternal) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "StackType"),
+				c.Spec.LoadBalancer.StackType, "only an External Proxy load balancer has an IPv6 frontend"),
+		)
+	}
+	if loadBalancerScheme(c.Spec.LoadBalancer) == LoadBalancerSchemeInternal && loadBalancerBackendType(c.Spec.LoadBalancer) != LoadBalancerBackendInstanceGroup {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "BackendType"),
+				c.Spec.LoadBalancer.BackendType, "an Internal load balancer only balances the traffic to instance groups"),
+		)
+	}
+
+	return allErrs
+}
+
+// validateLoadBalancerTuning checks the tuning of the health check and the backend service is only set on a Proxy
+// load balancer, the only one with both, the timeout of the health check doesn't exceed its interval, and the security
+// policy is only set on an External load balancer.
+func (c *GCPCluster) validateLoadBalancerTuning() field.ErrorList {
+	var allErrs field.ErrorList
+	spec := c.Spec.LoadBalancer
+	if loadBalancerType(spec) != LoadBalancerTypeProxy {
+		if spec.HealthCheck != nil {
+			allErrs = append(allErrs,
+				field.Forbidden(field.NewPath("spec", "LoadBalancer", "HealthCheck"), "only a Proxy load balancer has a health check"),
+			)
+		}
+		if spec.SessionAffinity != nil || spec.ConnectionDrainingTimeoutSec != nil || spec.SecurityPolicy != nil {
+			allErrs = append(allErrs,
+				field.Forbidden(field.NewPath("spec", "LoadBalancer"), "only a Proxy load balancer has a backend service to tune"),
+			)
+		}
+	}
+	if spec.SecurityPolicy != nil && loadBalancerScheme(spec) != LoadBalancerSchemeExternal {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "LoadBalancer", "SecurityPolicy"),
+				*spec.SecurityPolicy, "only the backend service of an External load balancer has a security policy"),
+		)
+	}
+	if hc := spec.HealthCheck; hc != nil {
+		interval, timeout := int64(10), int64(5)
+		if hc.CheckIntervalSec != nil {
+			interval = *hc.CheckIntervalSec
+		}
+		if hc.TimeoutSec != nil {
+			timeout = *hc.TimeoutSec
+		}
+		if timeout > interval {
+			allErrs = append(allErrs,
+				field.Invalid(field.NewPath("spec", "LoadBalancer", "HealthCheck", "TimeoutSec"),
+					timeout, fmt.Sprintf("the timeout can't exceed the check interval of %d seconds", interval)),
+			)
+		}
+	}
+
+	return allErrs
+}
+
+// validateControlPlaneRegions checks the additional control plane regions are distinct from the region of the
+// cluster, and behind an External Proxy load balancer, the only one with backends in several regions.
+func (c *GCPCluster) validateControlPlaneRegions() field.ErrorList {
+	var allErrs field.ErrorList
+	if len(c.Spec.AdditionalControlPlaneRegions) > 0 && (loadBalancerType(c.Spec.LoadBalancer) != LoadBalancerTypeProxy ||
+		loadBalancerScheme(c.Spec.LoadBalancer) != LoadBalancerSchemeExternal) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "AdditionalControlPlaneRegions"),
+				c.Spec.AdditionalControlPlaneRegions, "only an External Proxy load balancer has backends in several regions"),
+		)
+	}
+	for i, region := range c.Spec.AdditionalControlPlaneRegions {
+		if region == c.Spec.Region {
+			allErrs = append(allErrs,
+				field.Invalid(field.NewPath("spec", "AdditionalControlPlaneRegions").Index(i),
+					region, "the region of the cluster is already a control plane region"),
+			)
+		}
+	}
+
+	return allErrs
+}
+
+// validateSubnets checks the subnetworks have distinct names, a primary range in CIDR notation, if set, named
+// secondary IPv4 ranges in CIDR notation, a valid region, distinct roles in a region, and an IPv6 access type only
+// if they are dual-stack.
+func (c *GCPCluster) validateSubnets() field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	roles := map[string]bool{}
+	for i, subnet := range c.Spec.Network.Subnets {
+		if subnet == nil {
+			continue
+		}
+		path := field.NewPath("spec", "Network", "Subnets").Index(i)
+		if names[subnet.Name] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("Name"), subnet.Name))
+		}
+		names[subnet.Name] = true
+		if _, _, err := net.ParseCIDR(subnet.CidrBlock); subnet.CidrBlock != "" && err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("CidrBlock"), subnet.CidrBlock, "must be an IP range in CIDR notation"))
+		}
+		for name, cidr := range subnet.SecondaryCidrBlocks {
+			for _, msg := range validation.IsDNS1035Label(name) {
+				allErrs = append(allErrs, field.Invalid(path.Child("SecondaryCidrBlocks").Key(name), name, "the name of the range "+msg))
+			}
+			if ip, _, err := net.ParseCIDR(cidr); err != nil || ip.To4() == nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("SecondaryCidrBlocks").Key(name), cidr, "must be an IPv4 range in CIDR notation"))
+			}
+		}
+		region := subnet.Region
+		if region == "" {
+			region = c.Spec.Region
+		}
+		if !regionPattern.MatchString(region) {
+			allErrs = append(allErrs, field.Invalid(path.Child("Region"), region, "must be the name of a region, e.g. us-central1"))
+		}
+		for j, role := range subnet.Roles {
+			if roles[region+"/"+string(role)] {
+				allErrs = append(allErrs, field.Invalid(path.Child("Roles").Index(j), role, "another subnetwork of the region has the role"))
+			}
+			roles[region+"/"+string(role)] = true
+		}
+		if subnet.IPv6AccessType != "" && subnet.StackType != StackTypeIPv4IPv6 {
+			allErrs = append(allErrs, field.Invalid(path.Child("IPv6AccessType"), subnet.IPv6AccessType, "only an IPV4_IPV6 subnetwork has an IPv6 range"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateRoutes checks the static routes have distinct names, a destination range in CIDR notation and exactly one next hop.
+func (c *GCPCluster) validateRoutes() field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, route := range c.Spec.Network.Routes {
+		path := field.NewPath("spec", "Network", "Routes").Index(i)
+		if names[route.Name] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("Name"), route.Name))
+		}
+		names[route.Name] = true
+		if _, _, err := net.ParseCIDR(route.DestRange); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("DestRange"), route.DestRange, "must be an IP range in CIDR notation"))
+		}
+		nextHops := 0
+		for _, nextHop := range []*string{route.NextHopIP, route.NextHopInstance, route.NextHopILB, route.NextHopGateway} {
+			if nextHop != nil {
+				nextHops++
+			}
+		}
+		if nextHops != 1 {
+			allErrs = append(allErrs, field.Invalid(path, route.Name, "exactly one next hop must be set"))
+		}
+	}
+
+	return allErrs
+}
+
+// firewallProtocolsWithPorts are the protocols whose firewall rules can restrict the ports.
+var firewallProtocolsWithPorts = map[string]bool{"tcp": true, "udp": true, "sctp": true}
+
+// validateFirewallRules checks the additional firewall rules have distinct names, IP ranges in CIDR notation matching
+// their direction, and ports only for the protocols having ports.
+func (c *GCPCluster) validateFirewallRules() field.ErrorList {
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, rule := range c.Spec.Network.AdditionalFirewallRules {
+		path := field.NewPath("spec", "Network", "AdditionalFirewallRules").Index(i)
+		if names[rule.Name] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("Name"), rule.Name))
+		}
+		names[rule.Name] = true
+
+		egress := rule.Direction == FirewallRuleDirectionEgress
+		if egress && len(rule.SourceRanges) > 0 {
+			allErrs = append(allErrs, field.Forbidden(path.Child("SourceRanges"), "an egress rule applies to destination ranges"))
+		}
+		if !egress && len(rule.DestinationRanges) > 0 {
+			allErrs = append(allErrs, field.Forbidden(path.Child("DestinationRanges"), "an ingress rule applies to source ranges"))
+		}
+		for j, r := range rule.SourceRanges {
+			if _, _, err := net.ParseCIDR(r); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("SourceRanges").Index(j), r, "must be an IP range in CIDR notation"))
+			}
+		}
+		for j, r := range rule.DestinationRanges {
+			if _, _, err := net.ParseCIDR(r); err != nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("DestinationRanges").Index(j), r, "must be an IP range in CIDR notation"))
+			}
+		}
+
+		for j, allowed := range rule.Allowed {
+			if len(allowed.Ports) > 0 && !firewallProtocolsWithPorts[strings.ToLower(allowed.Protocol)] {
+				allErrs = append(allErrs, field.Forbidden(path.Child("Allowed").Index(j).Child("Ports"), "ports can only be set for the tcp, udp and sctp protocols"))
+			}
+			for k, port := range allowed.Ports {
+				if !isPortRange(port) {
+					allErrs = append(allErrs, field.Invalid(path.Child("Allowed").Index(j).Child("Ports").Index(k), port, "must be a port or a port range, e.g. 30000-32767"))
+				}
+			}
+		}
+
+		// The rules of a network firewall policy apply to the whole network.
+		if firewallPolicyMode(c.Spec.Network) == FirewallPolicyModeNetwork && len(rule.TargetTags) > 0 {
+			allErrs = append(allErrs, field.Forbidden(path.Child("TargetTags"), "the rules of a network firewall policy can't target network tags"))
+		}
+	}
+
+	if firewallPolicyMode(c.Spec.Network) == FirewallPolicyModeNetwork && c.Spec.Network.FirewallPolicy.Name != "" {
+		for _, msg := range validation.IsDNS1035Label(c.Spec.Network.FirewallPolicy.Name) {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "Network", "FirewallPolicy", "Name"), c.Spec.Network.FirewallPolicy.Name, msg))
+		}
+	}
+
+	return allErrs
+}
+
+// firewallPolicyMode returns the kind of the firewall rules of the network, defaults to Classic.
+func firewallPolicyMode(spec NetworkSpec) FirewallPolicyMode {
+	if spec.FirewallPolicy == nil || spec.FirewallPolicy.Mode == "" {
+		return FirewallPolicyModeClassic
+	}
+
+	return spec.FirewallPolicy.Mode
+}
+
+// isPortRange returns true if the string is a port, e.g. 443, or a range of ports, e.g. 30000-32767.
+func isPortRange(s string) bool {
+	ports := strings.SplitN(s, "-", 2)
+	var previous int
+	for i, port := range ports {
+		p, err := strconv.Atoi(port)
+		if err != nil || p < 0 || p > 65535 || (i > 0 && p < previous) {
+			return false
+		}
+		previous = p
+	}
+
+	return true
+}
+
+// validateDNS checks the forwarding zones have distinct names, a valid domain and IPv4 target name servers.
+func (c *GCPCluster) validateDNS() field.ErrorList {
+	if c.Spec.Network.DNS == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, zone := range c.Spec.Network.DNS.ForwardingZones {
+		path := field.NewPath("spec", "Network", "DNS", "ForwardingZones").Index(i)
+		if names[zone.Name] {
+			allErrs = append(allErrs, field.Duplicate(path.Child("Name"), zone.Name))
+		}
+		names[zone.Name] = true
+		for _, msg := range validation.IsDNS1123Subdomain(strings.TrimSuffix(zone.DNSName, ".")) {
+			allErrs = append(allErrs, field.Invalid(path.Child("DNSName"), zone.DNSName, msg))
+		}
+		for j, ip := range zone.TargetNameServers {
+			if parsed := net.ParseIP(ip); parsed == nil || parsed.To4() == nil {
+				allErrs = append(allErrs, field.Invalid(path.Child("TargetNameServers").Index(j), ip, "must be an IPv4 address"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+// validateControlPlaneDNS checks the name of the control plane record is a valid domain, and the host of the control
+// plane endpoint, if set, is this name.
+func (c *GCPCluster) validateControlPlaneDNS() field.ErrorList {
+	if c.Spec.ControlPlaneDNS == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	name := strings.TrimSuffix(c.Spec.ControlPlaneDNS.Name, ".")
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "ControlPlaneDNS", "Name"), c.Spec.ControlPlaneDNS.Name, msg))
+	}
+	if host := c.Spec.ControlPlaneEndpoint.Host; host != "" && host != name {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "ControlPlaneEndpoint", "Host"), host, "must be the name of the control plane DNS record"),
+		)
+	}
+
+	return allErrs
+}
+
+// validateNAT checks the subnetworks of the cloud nat gateway are distinct.
+func (c *GCPCluster) validateNAT() field.ErrorList {
+	if c.Spec.Network.NAT == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	names := map[string]bool{}
+	for i, subnet := range c.Spec.Network.NAT.Subnets {
+		if names[subnet.Name] {
+			allErrs = append(allErrs, field.Duplicate(field.NewPath("spec", "Network", "NAT", "Subnets").Index(i).Child("Name"), subnet.Name))
+		}
+		names[subnet.Name] = true
+	}
+
+	return allErrs
+}
+
+// validateMaintenanceWindow checks the duration of the maintenance window is positive and at most a day,
+// the windows of consecutive days would overlap otherwise.
+func (c *GCPCluster) validateMaintenanceWindow() field.ErrorList {
+	window := c.Spec.MaintenanceWindow
+	if window == nil || (window.Duration.Duration > 0 && window.Duration.Duration <= 24*time.Hour) {
+		return nil
+	}
+
+	return field.ErrorList{
+		field.Invalid(field.NewPath("spec", "MaintenanceWindow", "Duration"), window.Duration.Duration.String(), "must be positive and at most 24h"),
+	}
+}
+
+// validateMachineDefaults returns the errors of the defaults of the GCPMachines.
+func (c *GCPCluster) validateMachineDefaults() field.ErrorList {
+	if c.Spec.MachineDefaults == nil {
+		return nil
+	}
+
+	return validateServiceAccount(field.NewPath("spec", "machineDefaults", "serviceAccount"), c.Spec.MachineDefaults.ServiceAccount)
+}
+
+// validateControlPlaneEndpoint checks the host of the control plane endpoint is an IP address or a DNS name.
+func (c *GCPCluster) validateControlPlaneEndpoint() field.ErrorList {
+	var allErrs field.ErrorList
+
+	host := c.Spec.ControlPlaneEndpoint.Host
+	if host == "" || net.ParseIP(host) != nil {
+		return nil
+	}
+	for _, msg := range validation.IsDNS1123Subdomain(host) {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("spec", "ControlPlaneEndpoint", "Host"), host, "must be an IP address or a DNS name: "+msg),
+		)
+	}
+
+	return allErrs
+}
+
+func (c *GCPCluster) validateAnnotations() field.ErrorList {
+	var allErrs field.ErrorList
+
+	if _, err := c.RetainedResources(); err != nil {
+		allErrs = append(allErrs,
+			field.Invalid(field.NewPath("metadata", "annotations").Key(RetainAnnotation),
+				c.Annotations[RetainAnnotation], err.Error()),
+		)
+	}
+
+	return allErrs
+}
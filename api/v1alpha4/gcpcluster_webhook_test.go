@@ -0,0 +1,271 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+)
+
+func newTestGCPCluster() *GCPCluster {
+	return &GCPCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec: GCPClusterSpec{
+			Project: "my-project",
+			Region:  "us-central1",
+			Network: NetworkSpec{Name: pointer.StringPtr("my-network")},
+		},
+	}
+}
+
+func TestGCPClusterDefault(t *testing.T) {
+	g := NewWithT(t)
+
+	c := newTestGCPCluster()
+	c.Spec.Network = NetworkSpec{Subnets: Subnets{{Name: "my-subnet", CidrBlock: "10.0.0.0/20"}, {Name: "my-other-subnet", Region: "us-east1"}}}
+	c.Spec.MachineDefaults = &MachineDefaults{ServiceAccount: &ServiceAccount{}}
+	c.Default()
+
+	g.Expect(c.Spec.Network.Name).To(Equal(pointer.StringPtr("default")))
+	g.Expect(c.Spec.Network.Subnets[0].Region).To(Equal("us-central1"))
+	g.Expect(c.Spec.Network.Subnets[1].Region).To(Equal("us-east1"))
+	g.Expect(c.Spec.MachineDefaults.PublicIP).To(Equal(pointer.BoolPtr(false)))
+	g.Expect(c.Spec.MachineDefaults.ServiceAccount).To(Equal(&ServiceAccount{Email: DefaultServiceAccountEmail, Scopes: []string{CloudPlatformScope}}))
+
+	// The defaulted GCPCluster is unchanged for the immutable fields.
+	g.Expect(c.ValidateUpdate(c.DeepCopy())).To(Succeed())
+}
+
+func TestGCPClusterValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(*GCPCluster)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			update: func(*GCPCluster) {},
+		},
+		{
+			name:    "with an invalid project",
+			update:  func(c *GCPCluster) { c.Spec.Project = "My Project" },
+			wantErr: "spec.Project",
+		},
+		{
+			name:    "with an invalid region",
+			update:  func(c *GCPCluster) { c.Spec.Region = "us-central1-a" },
+			wantErr: "spec.Region",
+		},
+		{
+			name:    "with a failure domain of another region",
+			update:  func(c *GCPCluster) { c.Spec.FailureDomains = []string{"us-east1-b"} },
+			wantErr: "must be a zone of the region of the cluster",
+		},
+		{
+			name:    "with an invalid network name",
+			update:  func(c *GCPCluster) { c.Spec.Network.Name = pointer.StringPtr("My_Network") },
+			wantErr: "spec.Network.Name",
+		},
+		{
+			name: "with subnetworks",
+			update: func(c *GCPCluster) {
+				c.Spec.Network.Subnets = Subnets{{Name: "my-subnet", CidrBlock: "10.0.0.0/20", SecondaryCidrBlocks: map[string]string{"pods": "10.1.0.0/16"}}}
+			},
+		},
+		{
+			name:    "with an invalid subnetwork range",
+			update:  func(c *GCPCluster) { c.Spec.Network.Subnets = Subnets{{Name: "my-subnet", CidrBlock: "10.0.0.0"}} },
+			wantErr: "spec.Network.Subnets[0].CidrBlock",
+		},
+		{
+			name: "with an invalid secondary range",
+			update: func(c *GCPCluster) {
+				c.Spec.Network.Subnets = Subnets{{Name: "my-subnet", SecondaryCidrBlocks: map[string]string{"pods": "fd00::/64"}}}
+			},
+			wantErr: "must be an IPv4 range in CIDR notation",
+		},
+		{
+			name:    "with duplicate subnetworks",
+			update:  func(c *GCPCluster) { c.Spec.Network.Subnets = Subnets{{Name: "my-subnet"}, {Name: "my-subnet"}} },
+			wantErr: "spec.Network.Subnets[1].Name",
+		},
+		{
+			name: "with a scheme without Proxy load balancer",
+			update: func(c *GCPCluster) {
+				c.Spec.LoadBalancer = LoadBalancerSpec{Type: LoadBalancerTypeTargetInstance, Scheme: LoadBalancerSchemeInternal}
+			},
+			wantErr: "only a Proxy load balancer has a scheme",
+		},
+		{
+			name:    "without load balancer nor control plane endpoint",
+			update:  func(c *GCPCluster) { c.Spec.LoadBalancer = LoadBalancerSpec{Type: LoadBalancerTypeNone} },
+			wantErr: "spec.ControlPlaneEndpoint.Host",
+		},
+		{
+			name:    "with an invalid control plane endpoint",
+			update:  func(c *GCPCluster) { c.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "my_host", Port: 6443} },
+			wantErr: "must be an IP address or a DNS name",
+		},
+		{
+			name: "with an invalid machine defaults service account",
+			update: func(c *GCPCluster) {
+				c.Spec.MachineDefaults = &MachineDefaults{ServiceAccount: &ServiceAccount{Email: "my-sa"}}
+			},
+			wantErr: "spec.machineDefaults.serviceAccount.email",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := newTestGCPCluster()
+			tt.update(c)
+			err := c.ValidateCreate()
+			if tt.wantErr != "" {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGCPClusterValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		old     func(*GCPCluster)
+		update  func(*GCPCluster)
+		wantErr string
+	}{
+		{
+			name:   "without change",
+			update: func(*GCPCluster) {},
+		},
+		{
+			name:   "with additional labels",
+			update: func(c *GCPCluster) { c.Spec.AdditionalLabels = Labels{"team": "my-team"} },
+		},
+		{
+			name:   "with a control plane endpoint",
+			update: func(c *GCPCluster) { c.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443} },
+		},
+		{
+			name:    "with another control plane endpoint",
+			old:     func(c *GCPCluster) { c.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: "10.0.0.1", Port: 6443} },
+			update:  func(c *GCPCluster) { c.Spec.ControlPlaneEndpoint.Host = "10.0.0.2" },
+			wantErr: "field is immutable once set, the certificates of the control plane are issued for it",
+		},
+		{
+			name:    "with another project",
+			update:  func(c *GCPCluster) { c.Spec.Project = "my-other-project" },
+			wantErr: "the GCP resources of a cluster can't be moved to another project: create a new cluster in the project",
+		},
+		{
+			name:    "with another region",
+			update:  func(c *GCPCluster) { c.Spec.Region = "us-east1" },
+			wantErr: "the GCP resources of a cluster can't be moved to another region: create a new cluster in the region",
+		},
+		{
+			name:    "with another network",
+			update:  func(c *GCPCluster) { c.Spec.Network.Name = pointer.StringPtr("my-other-network") },
+			wantErr: `keep "my-network", or create a new cluster in the network`,
+		},
+		{
+			name:    "with another load balancer scheme",
+			update:  func(c *GCPCluster) { c.Spec.LoadBalancer.Scheme = LoadBalancerSchemeInternal },
+			wantErr: "spec.LoadBalancer.Scheme: Invalid value: \"Internal\": field is immutable",
+		},
+		{
+			name:    "with another resource name prefix",
+			update:  func(c *GCPCluster) { c.Spec.ResourceNamePrefix = pointer.StringPtr("my-prefix") },
+			wantErr: "spec.ResourceNamePrefix",
+		},
+		{
+			// The GCPCluster was created before the subnetworks were validated.
+			name:   "with an unchanged invalid subnetwork",
+			old:    func(c *GCPCluster) { c.Spec.Network.Subnets = Subnets{{Name: "my-subnet", CidrBlock: "10.0.0.0"}} },
+			update: func(c *GCPCluster) { c.Spec.AdditionalLabels = Labels{"team": "my-team"} },
+		},
+		{
+			name:    "with a changed invalid subnetwork",
+			old:     func(c *GCPCluster) { c.Spec.Network.Subnets = Subnets{{Name: "my-subnet", CidrBlock: "10.0.0.0"}} },
+			update:  func(c *GCPCluster) { c.Spec.Network.Subnets[0].CidrBlock = "10.0.0.0/33" },
+			wantErr: "spec.Network.Subnets[0].CidrBlock",
+		},
+		{
+			name:    "with an invalid route",
+			update:  func(c *GCPCluster) { c.Spec.Network.Routes = []RouteSpec{{Name: "my-route", DestRange: "10.1.0.0/16"}} },
+			wantErr: "exactly one next hop must be set",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			old := newTestGCPCluster()
+			if tt.old != nil {
+				tt.old(old)
+			}
+			c := old.DeepCopy()
+			tt.update(c)
+
+			err := c.ValidateUpdate(old)
+			if tt.wantErr != "" {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGCPClusterValidateDelete(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		wantErr     bool
+	}{
+		{
+			name: "without deletion protection",
+		},
+		{
+			name:        "with deletion protection",
+			annotations: map[string]string{DeletionProtectionAnnotation: ""},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := &GCPCluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Annotations: tt.annotations}}
+			err := c.ValidateDelete()
+			if tt.wantErr {
+				g.Expect(apierrors.IsForbidden(err)).To(BeTrue())
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
@@ -19,6 +19,7 @@ package v1alpha4
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/errors"
 )
 
@@ -40,6 +41,16 @@ const (
 	LocalSsdDiskType DiskType = "local-ssd"
 )
 
+// DiskInterface is the interface attaching a disk to an instance.
+type DiskInterface string
+
+const (
+	// SCSIDiskInterface attaches the disk with SCSI.
+	SCSIDiskInterface DiskInterface = "SCSI"
+	// NVMEDiskInterface attaches the disk with NVMe.
+	NVMEDiskInterface DiskInterface = "NVME"
+)
+
 // AttachedDiskSpec degined GCP machine disk.
 type AttachedDiskSpec struct {
 	// DeviceType is a device type of the attached disk.
@@ -54,6 +65,74 @@ type AttachedDiskSpec struct {
 	// Defaults to 30GB. For "local-ssd" size is always 375GB.
 	// +optional
 	Size *int64 `json:"size,omitempty"`
+	// DeviceName is the device name of the disk, exposed as /dev/disk/by-id/google-<device name>, so that
+	// the bootstrap data can format and mount it, e.g. on /var/lib/containerd. Defaults to a name generated by GCE.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	DeviceName *string `json:"deviceName,omitempty"`
+	// Interface is the interface attaching the disk, "SCSI" or "NVME". Defaults to "NVME" for "local-ssd",
+	// faster with most images, and to "SCSI" otherwise.
+	// +kubebuilder:validation:Enum=SCSI;NVME
+	// +optional
+	Interface *DiskInterface `json:"interface,omitempty"`
+	// AutoDelete, if false, keeps the persistent disk when the instance is deleted. It can't be false for
+	// "local-ssd", which are always deleted with the instance. Defaults to true.
+	// +optional
+	AutoDelete *bool `json:"autoDelete,omitempty"`
+}
+
+// EtcdDiskDeviceName is the device name of the etcd disk, exposed as /dev/disk/by-id/google-etcd.
+const EtcdDiskDeviceName = "etcd"
+
+// EtcdDisk is a dedicated persistent disk for etcd.
+type EtcdDisk struct {
+	// DeviceType is the type of the disk, "pd-standard" or "pd-ssd". Defaults to "pd-ssd".
+	// +kubebuilder:validation:Enum=pd-standard;pd-ssd
+	// +optional
+	DeviceType *DiskType `json:"deviceType,omitempty"`
+
+	// Size is the size of the disk in GB. Defaults to 30.
+	// +optional
+	Size *int64 `json:"size,omitempty"`
+
+	// KMSKeyName is the Cloud KMS key encrypting the disk, in the
+	// projects/<project>/locations/<location>/keyRings/<key-ring>/cryptoKeys/<key> form. The Compute Engine
+	// service agent of the project needs the Encrypter/Decrypter role on the key. Defaults to a Google-managed key.
+	// +optional
+	KMSKeyName *string `json:"kmsKeyName,omitempty"`
+}
+
+// DiskEncryption is the customer-managed encryption key (CMEK) of the persistent disks of an instance.
+type DiskEncryption struct {
+	// KMSKeyName is the Cloud KMS key encrypting the disks, in the
+	// projects/<project>/locations/<location>/keyRings/<key-ring>/cryptoKeys/<key> form. The key must be in
+	// the region of the instance, or global.
+	KMSKeyName string `json:"kmsKeyName"`
+
+	// KMSKeyServiceAccount is the email of the service account used to access the key, which needs the
+	// Encrypter/Decrypter role on it. Defaults to the Compute Engine service agent of the project.
+	// +optional
+	KMSKeyServiceAccount *string `json:"kmsKeyServiceAccount,omitempty"`
+}
+
+// ShieldedInstanceConfig is the Shielded VM configuration of an instance. The image must support Shielded VM,
+// i.e. have the UEFI_COMPATIBLE guest OS feature.
+type ShieldedInstanceConfig struct {
+	// SecureBoot, if true, only lets the instance boot with verified bootloaders and kernels.
+	// Defaults to true with the Hardened security profile of the GCPCluster, false otherwise.
+	// +optional
+	SecureBoot *bool `json:"secureBoot,omitempty"`
+
+	// VirtualizedTrustedPlatformModule, if true, attaches a vTPM to the instance for measured boot.
+	// Defaults to true.
+	// +optional
+	VirtualizedTrustedPlatformModule *bool `json:"virtualizedTrustedPlatformModule,omitempty"`
+
+	// IntegrityMonitoring, if true, compares the measurements of the boot of the instance with its baseline,
+	// which requires the VirtualizedTrustedPlatformModule. Defaults to the VirtualizedTrustedPlatformModule.
+	// +optional
+	IntegrityMonitoring *bool `json:"integrityMonitoring,omitempty"`
 }
 
 // GCPMachineSpec defines the desired state of GCPMachine.
@@ -66,6 +145,15 @@ type GCPMachineSpec struct {
 	// +optional
 	Subnet *string `json:"subnet,omitempty"`
 
+	// InstanceNameTemplate is the Go template of the name of the instance, rendered with the
+	// .ClusterName, .Name (of the GCPMachine), .Namespace and .Role fields, e.g.
+	// "{{ .ClusterName }}-{{ .Name }}". The rendered name is lower-cased, its invalid characters
+	// are replaced with dashes, and it is truncated to 63 characters with a hash suffix if longer.
+	// The template must render a name unique in the project, e.g. by including .Name.
+	// Defaults to the name of the GCPMachine, truncated the same way.
+	// +optional
+	InstanceNameTemplate *string `json:"instanceNameTemplate,omitempty"`
+
 	// ProviderID is the unique identifier as specified by the cloud provider.
 	// +optional
 	ProviderID *string `json:"providerID,omitempty"`
@@ -79,6 +167,11 @@ type GCPMachineSpec struct {
 	// +optional
 	Image *string `json:"image,omitempty"`
 
+	// ImageLookup selects the newest image of a project in a family or matching labels, e.g. the images built by
+	// image-builder for the Kubernetes version of the Machine. Image and ImageFamily take precedence over ImageLookup.
+	// +optional
+	ImageLookup *ImageLookup `json:"imageLookup,omitempty"`
+
 	// AdditionalLabels is an optional set of tags to add to an instance, in addition to the ones added by default by the
 	// GCP provider. If both the GCPCluster and the GCPMachine specify the same tag name with different values, the
 	// GCPMachine's value takes precedence.
@@ -98,6 +191,7 @@ type GCPMachineSpec struct {
 
 	// PublicIP specifies whether the instance should get a public IP.
 	// Set this to true if you don't have a NAT instances or Cloud Nat setup.
+	// Defaults to the PublicIP of the MachineDefaults of the GCPCluster, or false.
 	// +optional
 	PublicIP *bool `json:"publicIP,omitempty"`
 
@@ -107,8 +201,19 @@ type GCPMachineSpec struct {
 	// +optional
 	AdditionalNetworkTags []string `json:"additionalNetworkTags,omitempty"`
 
+	// AdditionalInstanceGroups are the names of existing unmanaged instance groups, in the zone of the
+	// instance, the instance is added to, e.g. the backends of load balancers managed outside of Cluster API.
+	// +optional
+	AdditionalInstanceGroups []string `json:"additionalInstanceGroups,omitempty"`
+
+	// TargetPools are the names of existing target pools, in the region of the instance, the instance is added to,
+	// e.g. the backends of network load balancers managed outside of Cluster API. The instance is removed from
+	// them, and from the AdditionalInstanceGroups, before it's deleted.
+	// +optional
+	TargetPools []string `json:"targetPools,omitempty"`
+
 	// RootDeviceSize is the size of the root volume in GB.
-	// Defaults to 30.
+	// Defaults to 30. It can be increased, the root volume of the instance is then resized in place.
 	// +optional
 	RootDeviceSize int64 `json:"rootDeviceSize,omitempty"`
 
@@ -120,18 +225,158 @@ type GCPMachineSpec struct {
 	// +optional
 	RootDeviceType *DiskType `json:"rootDeviceType,omitempty"`
 
+	// RootDeviceAutoDelete, if false, keeps the root volume when the instance is deleted, so that it can be
+	// attached to the instance of another GCPMachine with the same RootDeviceName. Defaults to true.
+	// The root volumes kept are labelled as retained once detached, and aren't deleted with the cluster.
+	// +optional
+	RootDeviceAutoDelete *bool `json:"rootDeviceAutoDelete,omitempty"`
+
+	// RootDeviceName is the name of the root volume, defaults to the name of the instance. An existing disk
+	// with the name in the zone of the instance is attached as the root volume instead of being created
+	// from the image, e.g. to preserve the state of a node across the recreation of its Machine.
+	// +optional
+	RootDeviceName *string `json:"rootDeviceName,omitempty"`
+
 	// AdditionalDisks are optional non-boot attached disks.
 	// +optional
 	AdditionalDisks []AttachedDiskSpec `json:"additionalDisks,omitempty"`
 
-	// ServiceAccount specifies the service account email and which scopes to assign to the machine.
-	// Defaults to: email: "default", scope: []{compute.CloudPlatformScope}
+	// EtcdDisk is a dedicated persistent disk for the etcd data of a control plane machine, isolating the IOPS
+	// of etcd from the root volume. It's attached with the etcd device name, as /dev/disk/by-id/google-etcd,
+	// and is expected to be formatted and mounted on /var/lib/etcd by the bootstrap data, e.g. with the
+	// diskSetup and mounts of the KubeadmControlPlane. It's ignored for the other machines.
+	// +optional
+	EtcdDisk *EtcdDisk `json:"etcdDisk,omitempty"`
+
+	// DiskEncryption encrypts the root volume and the additional persistent disks with a customer-managed key,
+	// required by many regulated environments, instead of a Google-managed key. It's also the key of the
+	// EtcdDisk, unless it sets its own KMSKeyName. The local SSDs, and the existing root volumes attached
+	// through RootDeviceName, aren't encrypted with it.
+	// +optional
+	DiskEncryption *DiskEncryption `json:"diskEncryption,omitempty"`
+
+	// ServiceAccount specifies the service account email and which scopes to assign to the machine, e.g. a
+	// least-privilege service account with only the scopes the nodes need. The email defaults to "default",
+	// the default compute service account of the project, and the scopes to the cloud-platform scope.
+	// Defaults to the ServiceAccount of the MachineDefaults of the GCPCluster, or else to the default compute
+	// service account with the cloud-platform scope.
 	// +optional
 	ServiceAccount *ServiceAccount `json:"serviceAccounts,omitempty"`
 
 	// Preemptible defines if instance is preemptible
 	// +optional
 	Preemptible bool `json:"preemptible,omitempty"`
+
+	// Reservation is the name of a reservation of the GCPCluster the instance consumes, required to consume
+	// the reservations with SpecificReservationRequired. Otherwise, the instance consumes any matching
+	// reservation with automatic consumption.
+	// +optional
+	Reservation *string `json:"reservation,omitempty"`
+
+	// SoleTenantNodeGroup is the name of a sole-tenant node group of the GCPCluster the instance runs on.
+	// The instance must be in the zone of the node group.
+	// +optional
+	SoleTenantNodeGroup *string `json:"soleTenantNodeGroup,omitempty"`
+
+	// NodeAffinities select the sole-tenant nodes the instance runs on, by the labels of their node templates or
+	// the compute.googleapis.com/node-group-name and compute.googleapis.com/node-name labels, e.g. to run on the
+	// node groups dedicated to BYOL licenses managed outside of Cluster API. They're added to the affinity of
+	// the SoleTenantNodeGroup, if any.
+	// +optional
+	NodeAffinities []NodeAffinity `json:"nodeAffinities,omitempty"`
+
+	// MinCPUPlatform is the minimum CPU platform of the instance, e.g. "Intel Cascade Lake", which must be
+	// available in its zone. Defaults to the CPU platform of the zone for the machine type.
+	// +optional
+	MinCPUPlatform *string `json:"minCpuPlatform,omitempty"`
+
+	// OnHostMaintenance is the behavior of the instance on host maintenance: Migrate live migrates it to another
+	// host, Terminate stops it. Defaults to Migrate, or to Terminate for the preemptible instances, the instances
+	// with accelerators and the Confidential VMs, which can't be live migrated.
+	// +kubebuilder:validation:Enum=Migrate;Terminate
+	// +optional
+	OnHostMaintenance *HostMaintenancePolicy `json:"onHostMaintenance,omitempty"`
+
+	// AutomaticRestart, if false, doesn't let GCE restart the instance once it's terminated by a host event.
+	// Defaults to true, or to false for the preemptible instances, which can't be restarted automatically.
+	// +optional
+	AutomaticRestart *bool `json:"automaticRestart,omitempty"`
+
+	// ZoneFallback, if true and the Machine has no failure domain, creates the instance in a failure domain
+	// of the cluster, and retries in the next ones when a zone is out of resources or lacks the machine type.
+	// The zones without recent incidents are tried first. The chosen zone is recorded in the status.
+	// +optional
+	ZoneFallback bool `json:"zoneFallback,omitempty"`
+
+	// ExistingInstance is the providerID, or the self link, of an existing instance of the project of the cluster,
+	// adopted by the GCPMachine instead of creating one, e.g. to bring a node built outside of Cluster API under
+	// its management. The instance gets the labels, network tags and instance groups of the GCPMachine and is
+	// deleted with it, its bootstrap isn't checked. The Machine is failed if the instance doesn't exist.
+	// +optional
+	ExistingInstance *string `json:"existingInstance,omitempty"`
+
+	// RepairPolicy is applied when the instance is found terminated, e.g. after it was stopped to save costs:
+	// Fail fails the Machine, Restart starts the instance again. Defaults to Fail.
+	// +kubebuilder:validation:Enum=Fail;Restart
+	// +optional
+	RepairPolicy RepairPolicy `json:"repairPolicy,omitempty"`
+
+	// GuestAccelerators are the accelerators, e.g. GPUs, attached to the instance.
+	// The instances with accelerators are terminated on host maintenance.
+	// +optional
+	GuestAccelerators []Accelerator `json:"guestAccelerators,omitempty"`
+
+	// InstallGPUDriver, if true, installs the NVIDIA driver on the instances with guest accelerators
+	// at boot, depending on the image: with the cos-extensions of Container-Optimized OS images, through
+	// the install-nvidia-driver metadata of Deep Learning VM images, or with the GPU driver installation
+	// script of GCP for the other Linux images. It's ignored if the startup-script or install-nvidia-driver
+	// metadata are set in the AdditionalMetadata.
+	// +optional
+	InstallGPUDriver bool `json:"installGPUDriver,omitempty"`
+
+	// EnableOSConfig, if true, enables the OS Config agent of VM Manager on the instance for OS patch
+	// management and inventory. The cloud-platform scope, needed by the agent, is added to the scopes
+	// of the service account of the instance.
+	// +optional
+	EnableOSConfig bool `json:"enableOSConfig,omitempty"`
+
+	// InstallOpsAgent, if true, sends the system logs and metrics of the instance to Cloud Logging and
+	// Cloud Monitoring. The Ops Agent is installed by a startup script, run after the GPU driver installation
+	// if any, except on Container-Optimized OS images whose own logging and monitoring agents are enabled.
+	// The logging and monitoring write scopes are added to the scopes of the service account of the instance.
+	// The metadata set in the AdditionalMetadata, e.g. a startup-script, takes precedence.
+	// +optional
+	InstallOpsAgent bool `json:"installOpsAgent,omitempty"`
+
+	// ContainerOptimizedOS, if true, configures the instance for a Container-Optimized OS image: the bootstrap
+	// data must be a cloud-config, the automatic updates of the read-only root partition are disabled unless
+	// the cos-update-strategy metadata is set, and the OS Config agent, unsupported, can't be enabled.
+	// Defaults to true for the images of the cos-cloud project and of the cos- image families.
+	// +optional
+	ContainerOptimizedOS *bool `json:"containerOptimizedOS,omitempty"`
+
+	// ShieldedInstanceConfig configures the Shielded VM features of the instance, defaulting to the ones
+	// of the security profile of the GCPCluster.
+	// +optional
+	ShieldedInstanceConfig *ShieldedInstanceConfig `json:"shieldedInstanceConfig,omitempty"`
+
+	// ConfidentialCompute, if true, creates a Confidential VM whose memory is encrypted by the CPU.
+	// It requires an N2D machine type and a supporting image, and the instance is terminated on host
+	// maintenance. Guest accelerators can't be attached.
+	// +optional
+	ConfidentialCompute bool `json:"confidentialCompute,omitempty"`
+
+	// BootstrapDataBucket is the name of an existing Cloud Storage bucket the bootstrap data is uploaded to,
+	// e.g. when it exceeds the 256KB limit of a metadata value. The user-data metadata of the instance is then
+	// a cloud-init shim downloading the bootstrap data at boot with the service account of the instance, which
+	// needs to read the objects of the bucket. The bootstrap data is uploaded with the private predefined ACL,
+	// which a bucket with uniform bucket-level access rejects, and deleted once the instance is deleted.
+	// It isn't supported by Container-Optimized OS images.
+	// +kubebuilder:validation:MinLength=3
+	// +kubebuilder:validation:MaxLength=222
+	// +kubebuilder:validation:Pattern=`^[a-z0-9][-a-z0-9_.]*[a-z0-9]$`
+	// +optional
+	BootstrapDataBucket *string `json:"bootstrapDataBucket,omitempty"`
 }
 
 // MetadataItem defines a single piece of metadata associated with an instance.
@@ -142,6 +387,99 @@ type MetadataItem struct {
 	Value *string `json:"value,omitempty"`
 }
 
+const (
+	// ProvisioningModelStandard is the provisioning model of the instances running until deleted.
+	ProvisioningModelStandard = "Standard"
+	// ProvisioningModelPreemptible is the provisioning model of the instances GCE can stop at any time.
+	ProvisioningModelPreemptible = "Preemptible"
+)
+
+// HostMaintenancePolicy is the behavior of an instance on host maintenance.
+type HostMaintenancePolicy string
+
+const (
+	// HostMaintenancePolicyMigrate live migrates the instance to another host.
+	HostMaintenancePolicyMigrate HostMaintenancePolicy = "Migrate"
+	// HostMaintenancePolicyTerminate stops the instance.
+	HostMaintenancePolicyTerminate HostMaintenancePolicy = "Terminate"
+)
+
+// NodeAffinityOperator is the operator of a node affinity.
+type NodeAffinityOperator string
+
+const (
+	// NodeAffinityOperatorIn selects the nodes whose label has one of the values.
+	NodeAffinityOperatorIn NodeAffinityOperator = "In"
+	// NodeAffinityOperatorNotIn selects the nodes whose label has none of the values.
+	NodeAffinityOperatorNotIn NodeAffinityOperator = "NotIn"
+)
+
+// NodeAffinity selects sole-tenant nodes by a label of their node template.
+type NodeAffinity struct {
+	// Key is the key of the label, e.g. compute.googleapis.com/node-group-name.
+	// +kubebuilder:validation:MinLength=1
+	Key string `json:"key"`
+
+	// Operator is In to select the nodes with one of the values, or NotIn for the nodes without any of them.
+	// +kubebuilder:validation:Enum=In;NotIn
+	Operator NodeAffinityOperator `json:"operator"`
+
+	// Values are the values of the label, required by the In operator.
+	// +optional
+	Values []string `json:"values,omitempty"`
+}
+
+// InstanceScheduling is the scheduling of an instance and its last disruptions.
+type InstanceScheduling struct {
+	// ProvisioningModel is Preemptible if the instance can be stopped by GCE at any time, Standard otherwise.
+	ProvisioningModel string `json:"provisioningModel"`
+
+	// OnHostMaintenance is the behavior of the instance on host maintenance, MIGRATE or TERMINATE.
+	// +optional
+	OnHostMaintenance string `json:"onHostMaintenance,omitempty"`
+
+	// AutomaticRestart is true if the instance is restarted by GCE once terminated by a host event.
+	AutomaticRestart bool `json:"automaticRestart"`
+
+	// LastPreemptionTime is the last time the instance was preempted.
+	// +optional
+	LastPreemptionTime *metav1.Time `json:"lastPreemptionTime,omitempty"`
+
+	// LastHostMaintenanceTime is the last time the instance was migrated or terminated by a host maintenance.
+	// +optional
+	LastHostMaintenanceTime *metav1.Time `json:"lastHostMaintenanceTime,omitempty"`
+}
+
+// ImageLookup selects the newest image in a family, matching labels. At least one of Family and Labels must be set.
+type ImageLookup struct {
+	// Project is the project of the images, defaults to the project of the cluster.
+	// +optional
+	Project *string `json:"project,omitempty"`
+
+	// Family is the family of the images, a template rendered like the values of the Labels,
+	// e.g. "capi-ubuntu-2004-k8s-{{ .KubernetesMinorVersion }}" for "capi-ubuntu-2004-k8s-v1-21".
+	// +optional
+	Family string `json:"family,omitempty"`
+
+	// Labels are the labels of the images. The values are templates rendered with the KubernetesVersion
+	// and KubernetesMinorVersion of the Machine, e.g. "{{ .KubernetesMinorVersion }}" for "v1-21", the
+	// dots being replaced with dashes in the label values.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// RepairPolicy is the policy applied to the terminated instance of a GCPMachine.
+type RepairPolicy string
+
+const (
+	// RepairPolicyFail fails the Machine of the terminated instance, so that it can be remediated.
+	RepairPolicyFail RepairPolicy = "Fail"
+
+	// RepairPolicyRestart starts the terminated instance again, e.g. on the dev clusters whose instances
+	// are stopped outside of working hours.
+	RepairPolicyRestart RepairPolicy = "Restart"
+)
+
 // GCPMachineStatus defines the observed state of GCPMachine.
 type GCPMachineStatus struct {
 	// Ready is true when the provider resource is ready.
@@ -151,10 +489,34 @@ type GCPMachineStatus struct {
 	// Addresses contains the GCP instance associated addresses.
 	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
 
+	// Zone is the zone of the instance chosen by the ZoneFallback, as the Machine has no failure domain.
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
 	// InstanceStatus is the status of the GCP instance for this machine.
 	// +optional
 	InstanceStatus *InstanceStatus `json:"instanceState,omitempty"`
 
+	// Image is the full reference of the image the boot disk of the instance was created from, as resolved
+	// by the ImageLookup, or the image family otherwise.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// Operation is the full reference of the operation in progress on the instance or its disks, e.g. its
+	// insert, delete or the change of its machine type, which is polled by the next reconciles instead of
+	// being waited for.
+	// +optional
+	Operation string `json:"operation,omitempty"`
+
+	// Scheduling is the scheduling of the instance and its last disruptions, so that they can be anticipated.
+	// +optional
+	Scheduling *InstanceScheduling `json:"scheduling,omitempty"`
+
+	// LabelsHash is the hash of the labels last set on the instance and its persistent disks. The disks are
+	// only read again once the labels of the spec change or the ones of the instance drift.
+	// +optional
+	LabelsHash string `json:"labelsHash,omitempty"`
+
 	// FailureReason will be set in the event that there is a terminal problem
 	// reconciling the Machine and will contain a succinct value suitable
 	// for machine interpretation.
@@ -192,6 +554,10 @@ type GCPMachineStatus struct {
 	// controller's output.
 	// +optional
 	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the GCPMachine.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -213,6 +579,16 @@ type GCPMachine struct {
 	Status GCPMachineStatus `json:"status,omitempty"`
 }
 
+// GetConditions returns the observations of the operational state of the GCPMachine resource.
+func (r *GCPMachine) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the GCPMachine to the predescribed clusterv1.Conditions.
+func (r *GCPMachine) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // GCPMachineList contains a list of GCPMachine.
@@ -18,19 +18,29 @@ package v1alpha4
 
 import (
 	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/util/validation/field"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
 )
 
 // log is for logging in this package.
 var _ = logf.Log.WithName("gcpmachine-resource")
 
+// machineTypePattern matches the name of a predefined machine type, e.g. n1-standard-4, or of a custom one, e.g.
+// custom-4-16384 or n2-custom-4-16384-ext.
+var machineTypePattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)+$`)
+
 func (m *GCPMachine) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(m).
@@ -46,11 +56,191 @@ var _ webhook.Validator = &GCPMachine{}
 func (m *GCPMachine) ValidateCreate() error {
 	clusterlog.Info("validate create", "name", m.Name)
 
+	if errs := m.Spec.validate(field.NewPath("spec"), names.InstanceData{
+		ClusterName: m.Labels[clusterv1.ClusterLabelName],
+		Name:        m.Name,
+		Namespace:   m.Namespace,
+		Role:        "node",
+	}); len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPMachine").GroupKind(), m.Name, errs)
+	}
+
 	return nil
 }
 
+// validate returns the errors of the spec preventing the instance from being created, the instance
+// name template being rendered with the data of the GCPMachine.
+func (s *GCPMachineSpec) validate(fldPath *field.Path, data names.InstanceData) field.ErrorList {
+	allErrs := s.validateInstanceType(fldPath.Child("instanceType"))
+
+	if s.InstanceNameTemplate != nil {
+		if _, err := names.Format(*s.InstanceNameTemplate, data); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("instanceNameTemplate"), *s.InstanceNameTemplate, err.Error()))
+		}
+	}
+
+	if s.ExistingInstance != nil {
+		if _, err := names.ParseInstanceReference(*s.ExistingInstance); err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("existingInstance"), *s.ExistingInstance, err.Error()))
+		}
+	}
+
+	if s.ImageLookup != nil && s.ImageLookup.Family == "" && len(s.ImageLookup.Labels) == 0 {
+		allErrs = append(allErrs, field.Required(fldPath.Child("imageLookup"), "the family or the labels of the images must be set"))
+	}
+
+	allErrs = append(allErrs, validateServiceAccount(fldPath.Child("serviceAccounts"), s.ServiceAccount)...)
+	allErrs = append(allErrs, s.validateAdditionalDisks(fldPath.Child("additionalDisks"))...)
+	allErrs = append(allErrs, s.validateGuestAccelerators(fldPath.Child("guestAccelerators"))...)
+
+	allErrs = append(allErrs, s.validateConfidentialCompute(fldPath)...)
+	allErrs = append(allErrs, s.validateScheduling(fldPath)...)
+
+	if shielded := s.ShieldedInstanceConfig; shielded != nil && shielded.VirtualizedTrustedPlatformModule != nil &&
+		!*shielded.VirtualizedTrustedPlatformModule && shielded.IntegrityMonitoring != nil && *shielded.IntegrityMonitoring {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("shieldedInstanceConfig", "integrityMonitoring"), true, "integrity monitoring requires the virtualized trusted platform module"))
+	}
+
+	if s.EnableOSConfig && s.containerOptimizedOS() {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("enableOSConfig"), "the OS Config agent isn't supported by Container-Optimized OS"))
+	}
+
+	return allErrs
+}
+
+// validateInstanceType returns the error of a machine type which isn't the name of a machine type. Its
+// availability in the zone of the instance is checked when the instance is created.
+func (s *GCPMachineSpec) validateInstanceType(fldPath *field.Path) field.ErrorList {
+	if machineTypePattern.MatchString(s.InstanceType) {
+		return nil
+	}
+
+	return field.ErrorList{field.Invalid(fldPath, s.InstanceType, "must be the name of a machine type, e.g. n1-standard-4 or custom-4-16384")}
+}
+
+// validateAdditionalDisks returns the errors of the additional disks: the local SSDs are deleted with the
+// instance, and the device names must be unique among the disks of the instance.
+func (s *GCPMachineSpec) validateAdditionalDisks(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	deviceNames := map[string]bool{}
+	if s.EtcdDisk != nil {
+		deviceNames[EtcdDiskDeviceName] = true
+	}
+	for i, d := range s.AdditionalDisks {
+		if d.DeviceType != nil && *d.DeviceType == LocalSsdDiskType && d.AutoDelete != nil && !*d.AutoDelete {
+			allErrs = append(allErrs, field.Invalid(fldPath.Index(i).Child("autoDelete"), *d.AutoDelete, "local SSDs are always deleted with the instance"))
+		}
+		if d.DeviceName != nil {
+			if deviceNames[*d.DeviceName] {
+				allErrs = append(allErrs, field.Duplicate(fldPath.Index(i).Child("deviceName"), *d.DeviceName))
+			}
+			deviceNames[*d.DeviceName] = true
+		}
+	}
+
+	return allErrs
+}
+
+// validateConfidentialCompute returns the errors of a Confidential VM: only the N2D machine types, predefined or
+// custom, support it, and accelerators can't be attached.
+func (s *GCPMachineSpec) validateConfidentialCompute(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if !s.ConfidentialCompute {
+		return nil
+	}
+
+	if !strings.HasPrefix(s.InstanceType, "n2d-") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("instanceType"), s.InstanceType, "Confidential VMs require an N2D machine type"))
+	}
+	if len(s.GuestAccelerators) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("guestAccelerators"), "accelerators can't be attached to Confidential VMs"))
+	}
+
+	return allErrs
+}
+
+// validateScheduling returns the errors of the scheduling of the instance: the preemptible instances, the
+// instances with accelerators and the Confidential VMs can't be live migrated, and the preemptible instances
+// can't be restarted automatically.
+func (s *GCPMachineSpec) validateScheduling(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if s.OnHostMaintenance != nil && *s.OnHostMaintenance == HostMaintenancePolicyMigrate {
+		switch {
+		case s.Preemptible:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("onHostMaintenance"), *s.OnHostMaintenance, "preemptible instances can't be live migrated"))
+		case len(s.GuestAccelerators) > 0:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("onHostMaintenance"), *s.OnHostMaintenance, "instances with accelerators can't be live migrated"))
+		case s.ConfidentialCompute:
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("onHostMaintenance"), *s.OnHostMaintenance, "Confidential VMs can't be live migrated"))
+		}
+	}
+	if s.AutomaticRestart != nil && *s.AutomaticRestart && s.Preemptible {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("automaticRestart"), *s.AutomaticRestart, "preemptible instances can't be restarted automatically"))
+	}
+
+	for i, affinity := range s.NodeAffinities {
+		if affinity.Operator == NodeAffinityOperatorIn && len(affinity.Values) == 0 {
+			allErrs = append(allErrs, field.Required(fldPath.Child("nodeAffinities").Index(i).Child("values"), "the In operator requires values"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateGuestAccelerators returns the errors of the accelerators that GCE would reject whatever the zone
+// of the instance: the availability of the accelerator types in the zone is checked when the instance is created.
+func (s *GCPMachineSpec) validateGuestAccelerators(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(s.GuestAccelerators) == 0 {
+		return nil
+	}
+
+	// Only the N1 machine types, predefined or custom, can have GPUs attached: the A2 machine types
+	// come with their GPUs.
+	if !strings.HasPrefix(s.InstanceType, "n1-") && !strings.HasPrefix(s.InstanceType, "custom-") {
+		allErrs = append(allErrs, field.Invalid(fldPath, s.InstanceType, "accelerators can only be attached to N1 machine types"))
+	}
+
+	types := make(map[string]bool, len(s.GuestAccelerators))
+	for i, a := range s.GuestAccelerators {
+		if types[a.Type] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Index(i).Child("type"), a.Type))
+		}
+		types[a.Type] = true
+	}
+
+	return allErrs
+}
+
+// containerOptimizedOS returns true if the instance runs Container-Optimized OS, as far as it can be told
+// from the spec: the image may also be looked up when the instance is created.
+func (s *GCPMachineSpec) containerOptimizedOS() bool {
+	if s.ContainerOptimizedOS != nil {
+		return *s.ContainerOptimizedOS
+	}
+	for _, image := range []*string{s.Image, s.ImageFamily} {
+		if image != nil && (strings.Contains(*image, "projects/cos-cloud/") || strings.Contains(*image, "/family/cos-")) {
+			return true
+		}
+	}
+
+	return false
+}
+
 // ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
 func (m *GCPMachine) ValidateUpdate(old runtime.Object) error {
+	// The defaults are applied to the updates too, the objects created before they were introduced
+	// are defaulted the same way so that the defaults aren't taken for a change of the immutable fields.
+	if oldMachine, ok := old.(*GCPMachine); ok {
+		oldMachine = oldMachine.DeepCopy()
+		defaultServiceAccount(oldMachine.Spec.ServiceAccount)
+		old = oldMachine
+	}
+
 	newGCPMachine, err := runtime.DefaultUnstructuredConverter.ToUnstructured(m)
 	if err != nil {
 		return apierrors.NewInvalid(GroupVersion.WithKind("GCPMachine").GroupKind(), m.Name, field.ErrorList{
@@ -79,15 +269,102 @@ func (m *GCPMachine) ValidateUpdate(old runtime.Object) error {
 	delete(oldGCPMachineSpec, "additionalNetworkTags")
 	delete(newGCPMachineSpec, "additionalNetworkTags")
 
-	if !reflect.DeepEqual(oldGCPMachineSpec, newGCPMachineSpec) {
-		return apierrors.NewInvalid(GroupVersion.WithKind("GCPMachine").GroupKind(), m.Name, field.ErrorList{
-			field.Forbidden(field.NewPath("spec"), "cannot be modified"),
-		})
+	// allow changes to additionalMetadata
+	delete(oldGCPMachineSpec, "additionalMetadata")
+	delete(newGCPMachineSpec, "additionalMetadata")
+
+	// allow changes to instanceType, the instance is stopped for the change
+	delete(oldGCPMachineSpec, "instanceType")
+	delete(newGCPMachineSpec, "instanceType")
+
+	// allow changes to repairPolicy
+	delete(oldGCPMachineSpec, "repairPolicy")
+	delete(newGCPMachineSpec, "repairPolicy")
+
+	// allow increases of rootDeviceSize, the disks can't be shrunk
+	if oldMachine, ok := old.(*GCPMachine); ok && m.Spec.RootDeviceSize != oldMachine.Spec.RootDeviceSize {
+		if m.Spec.RootDeviceSize < oldMachine.Spec.RootDeviceSize {
+			return apierrors.NewInvalid(GroupVersion.WithKind("GCPMachine").GroupKind(), m.Name, field.ErrorList{
+				field.Forbidden(field.NewPath("spec", "rootDeviceSize"), "can only be increased"),
+			})
+		}
+		delete(oldGCPMachineSpec, "rootDeviceSize")
+		delete(newGCPMachineSpec, "rootDeviceSize")
+	}
+
+	// The fields left are immutable, the changes are listed so that the error tells what to revert.
+	allErrs := m.Spec.validateInstanceType(field.NewPath("spec", "instanceType"))
+	for _, key := range changedKeys(oldGCPMachineSpec, newGCPMachineSpec) {
+		allErrs = append(allErrs, field.Forbidden(field.NewPath("spec", key),
+			"field is immutable, the instance can't be updated in place: change the GCPMachineTemplate of the Machine to roll out a new instance"))
+	}
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPMachine").GroupKind(), m.Name, allErrs)
 	}
 
 	return nil
 }
 
+// changedKeys returns the sorted keys whose values differ between the old and the new object.
+func changedKeys(oldObj, newObj map[string]interface{}) []string {
+	var keys []string
+	for key, value := range newObj {
+		if !reflect.DeepEqual(oldObj[key], value) {
+			keys = append(keys, key)
+		}
+	}
+	for key := range oldObj {
+		if _, ok := newObj[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// defaultServiceAccount defaults the email of the service account to the default compute service account,
+// and its scopes to the cloud-platform scope.
+func defaultServiceAccount(sa *ServiceAccount) {
+	if sa == nil {
+		return
+	}
+	if sa.Email == "" {
+		sa.Email = DefaultServiceAccountEmail
+	}
+	if len(sa.Scopes) == 0 {
+		sa.Scopes = []string{CloudPlatformScope}
+	}
+}
+
+// validateServiceAccount returns the errors of the service account: its email is either the one of a service
+// account or default, and its scopes are distinct OAuth scopes of the Google APIs.
+func validateServiceAccount(fldPath *field.Path, sa *ServiceAccount) field.ErrorList {
+	var allErrs field.ErrorList
+	if sa == nil {
+		return allErrs
+	}
+
+	if sa.Email != "" && sa.Email != DefaultServiceAccountEmail {
+		if i := strings.Index(sa.Email, "@"); i <= 0 || i == len(sa.Email)-1 || strings.ContainsAny(sa.Email, " \t\n") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("email"), sa.Email, "must be the email of a service account or default"))
+		}
+	}
+
+	scopes := map[string]bool{}
+	for i, scope := range sa.Scopes {
+		if !strings.HasPrefix(scope, scopePrefix) || scope == scopePrefix {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("scopes").Index(i), scope, "must be an OAuth scope URL starting with "+scopePrefix))
+		}
+		if scopes[scope] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child("scopes").Index(i), scope))
+		}
+		scopes[scope] = true
+	}
+
+	return allErrs
+}
+
 // ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
 func (m *GCPMachine) ValidateDelete() error {
 	clusterlog.Info("validate delete", "name", m.Name)
@@ -98,4 +375,6 @@ func (m *GCPMachine) ValidateDelete() error {
 // Default implements webhookutil.defaulter so a webhook will be registered for the type.
 func (m *GCPMachine) Default() {
 	clusterlog.Info("default", "name", m.Name)
+
+	defaultServiceAccount(m.Spec.ServiceAccount)
 }
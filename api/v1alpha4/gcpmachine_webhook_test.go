@@ -0,0 +1,324 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestGCPMachineDefault(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceAccount *ServiceAccount
+		want           *ServiceAccount
+	}{
+		{
+			name: "without service account",
+		},
+		{
+			name:           "with an empty service account",
+			serviceAccount: &ServiceAccount{},
+			want:           &ServiceAccount{Email: DefaultServiceAccountEmail, Scopes: []string{CloudPlatformScope}},
+		},
+		{
+			name:           "with the email of a service account",
+			serviceAccount: &ServiceAccount{Email: "sa@my-project.iam.gserviceaccount.com"},
+			want:           &ServiceAccount{Email: "sa@my-project.iam.gserviceaccount.com", Scopes: []string{CloudPlatformScope}},
+		},
+		{
+			name:           "with scopes",
+			serviceAccount: &ServiceAccount{Scopes: []string{"https://www.googleapis.com/auth/devstorage.read_only"}},
+			want:           &ServiceAccount{Email: DefaultServiceAccountEmail, Scopes: []string{"https://www.googleapis.com/auth/devstorage.read_only"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &GCPMachine{Spec: GCPMachineSpec{ServiceAccount: tt.serviceAccount}}
+			m.Default()
+			g.Expect(m.Spec.ServiceAccount).To(Equal(tt.want))
+		})
+	}
+}
+
+func TestGCPMachineValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    GCPMachineSpec
+		wantErr string
+	}{
+		{
+			name: "valid",
+			spec: GCPMachineSpec{InstanceType: "n1-standard-2"},
+		},
+		{
+			name: "with a custom machine type",
+			spec: GCPMachineSpec{InstanceType: "n2-custom-4-16384-ext"},
+		},
+		{
+			name:    "with an invalid machine type",
+			spec:    GCPMachineSpec{InstanceType: "N1 standard"},
+			wantErr: "spec.instanceType",
+		},
+		{
+			name:    "with an image lookup without family nor labels",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", ImageLookup: &ImageLookup{}},
+			wantErr: "spec.imageLookup",
+		},
+		{
+			name:    "with an invalid existing instance",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", ExistingInstance: pointer.StringPtr("my-instance")},
+			wantErr: "spec.existingInstance",
+		},
+		{
+			name: "with a service account",
+			spec: GCPMachineSpec{InstanceType: "n1-standard-2", ServiceAccount: &ServiceAccount{
+				Email:  "sa@my-project.iam.gserviceaccount.com",
+				Scopes: []string{CloudPlatformScope},
+			}},
+		},
+		{
+			name:    "with an invalid service account email",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", ServiceAccount: &ServiceAccount{Email: "my-sa"}},
+			wantErr: "spec.serviceAccounts.email",
+		},
+		{
+			name:    "with an invalid scope",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", ServiceAccount: &ServiceAccount{Scopes: []string{"cloud-platform"}}},
+			wantErr: "spec.serviceAccounts.scopes[0]",
+		},
+		{
+			name: "with duplicate scopes",
+			spec: GCPMachineSpec{InstanceType: "n1-standard-2", ServiceAccount: &ServiceAccount{
+				Scopes: []string{CloudPlatformScope, CloudPlatformScope},
+			}},
+			wantErr: "spec.serviceAccounts.scopes[1]",
+		},
+		{
+			name: "with accelerators",
+			spec: GCPMachineSpec{InstanceType: "n1-standard-4", GuestAccelerators: []Accelerator{
+				{Type: "nvidia-tesla-t4", Count: 1},
+			}},
+		},
+		{
+			name: "with accelerators on another machine type than N1",
+			spec: GCPMachineSpec{InstanceType: "e2-standard-4", GuestAccelerators: []Accelerator{
+				{Type: "nvidia-tesla-t4", Count: 1},
+			}},
+			wantErr: "accelerators can only be attached to N1 machine types",
+		},
+		{
+			name: "with duplicate accelerator types",
+			spec: GCPMachineSpec{InstanceType: "n1-standard-4", GuestAccelerators: []Accelerator{
+				{Type: "nvidia-tesla-t4", Count: 1},
+				{Type: "nvidia-tesla-t4", Count: 1},
+			}},
+			wantErr: "spec.guestAccelerators[1].type",
+		},
+		{
+			name: "with live migrated accelerators",
+			spec: GCPMachineSpec{
+				InstanceType:      "n1-standard-4",
+				GuestAccelerators: []Accelerator{{Type: "nvidia-tesla-t4", Count: 1}},
+				OnHostMaintenance: hostMaintenancePolicy(HostMaintenancePolicyMigrate),
+			},
+			wantErr: "instances with accelerators can't be live migrated",
+		},
+		{
+			name: "with a live migrated preemptible instance",
+			spec: GCPMachineSpec{
+				InstanceType:      "n1-standard-2",
+				Preemptible:       true,
+				OnHostMaintenance: hostMaintenancePolicy(HostMaintenancePolicyMigrate),
+			},
+			wantErr: "preemptible instances can't be live migrated",
+		},
+		{
+			name: "with a preemptible instance restarted automatically",
+			spec: GCPMachineSpec{
+				InstanceType:     "n1-standard-2",
+				Preemptible:      true,
+				AutomaticRestart: pointer.BoolPtr(true),
+			},
+			wantErr: "preemptible instances can't be restarted automatically",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			m := &GCPMachine{ObjectMeta: metav1.ObjectMeta{Name: "my-machine"}, Spec: tt.spec}
+			err := m.ValidateCreate()
+			if tt.wantErr != "" {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGCPMachineValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(*GCPMachineSpec)
+		wantErr string
+	}{
+		{
+			name:   "without change",
+			update: func(*GCPMachineSpec) {},
+		},
+		{
+			name: "with a provider ID",
+			update: func(s *GCPMachineSpec) {
+				s.ProviderID = pointer.StringPtr("gce://my-project/us-central1-a/my-instance")
+			},
+		},
+		{
+			name:   "with additional labels",
+			update: func(s *GCPMachineSpec) { s.AdditionalLabels = Labels{"team": "my-team"} },
+		},
+		{
+			name:   "with additional network tags",
+			update: func(s *GCPMachineSpec) { s.AdditionalNetworkTags = []string{"my-tag"} },
+		},
+		{
+			name: "with additional metadata",
+			update: func(s *GCPMachineSpec) {
+				s.AdditionalMetadata = []MetadataItem{{Key: "my-key", Value: pointer.StringPtr("my-value")}}
+			},
+		},
+		{
+			name:   "with another machine type",
+			update: func(s *GCPMachineSpec) { s.InstanceType = "n1-standard-4" },
+		},
+		{
+			name:    "with an invalid machine type",
+			update:  func(s *GCPMachineSpec) { s.InstanceType = "N1 standard" },
+			wantErr: "spec.instanceType",
+		},
+		{
+			name:   "with a repair policy",
+			update: func(s *GCPMachineSpec) { s.RepairPolicy = RepairPolicyRestart },
+		},
+		{
+			name:   "with a larger root device",
+			update: func(s *GCPMachineSpec) { s.RootDeviceSize = 50 },
+		},
+		{
+			name:    "with a smaller root device",
+			update:  func(s *GCPMachineSpec) { s.RootDeviceSize = 20 },
+			wantErr: "spec.rootDeviceSize: Forbidden: can only be increased",
+		},
+		{
+			name:    "with another image",
+			update:  func(s *GCPMachineSpec) { s.Image = pointer.StringPtr("my-other-image") },
+			wantErr: "spec.image: Forbidden: field is immutable, the instance can't be updated in place",
+		},
+		{
+			name: "with a service account",
+			update: func(s *GCPMachineSpec) {
+				s.ServiceAccount = &ServiceAccount{Email: "sa@my-project.iam.gserviceaccount.com"}
+			},
+			wantErr: "spec.serviceAccounts: Forbidden: field is immutable",
+		},
+		{
+			name:    "without preemption",
+			update:  func(s *GCPMachineSpec) { s.Preemptible = false },
+			wantErr: "spec.preemptible: Forbidden: field is immutable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			old := &GCPMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-machine"},
+				Spec: GCPMachineSpec{
+					InstanceType:   "n1-standard-2",
+					Image:          pointer.StringPtr("my-image"),
+					RootDeviceSize: 30,
+					Preemptible:    true,
+				},
+			}
+			m := old.DeepCopy()
+			tt.update(&m.Spec)
+
+			err := m.ValidateUpdate(old)
+			if tt.wantErr != "" {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGCPMachineDefaultValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name           string
+		serviceAccount *ServiceAccount
+	}{
+		{
+			name: "without service account",
+		},
+		{
+			name:           "with a service account created before the defaults",
+			serviceAccount: &ServiceAccount{},
+		},
+		{
+			name:           "with a service account without scopes",
+			serviceAccount: &ServiceAccount{Email: "sa@my-project.iam.gserviceaccount.com"},
+		},
+		{
+			name:           "with a defaulted service account",
+			serviceAccount: &ServiceAccount{Email: DefaultServiceAccountEmail, Scopes: []string{CloudPlatformScope}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			old := &GCPMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-machine"},
+				Spec: GCPMachineSpec{
+					InstanceType:   "n1-standard-2",
+					ServiceAccount: tt.serviceAccount,
+				},
+			}
+
+			// The finalizer is removed from the old object, which is defaulted on the way.
+			m := old.DeepCopy()
+			m.Finalizers = nil
+			m.Default()
+
+			g.Expect(m.ValidateUpdate(old)).To(Succeed())
+		})
+	}
+}
+
+func hostMaintenancePolicy(policy HostMaintenancePolicy) *HostMaintenancePolicy {
+	return &policy
+}
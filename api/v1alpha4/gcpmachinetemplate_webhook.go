@@ -17,15 +17,85 @@ limitations under the License.
 package v1alpha4
 
 import (
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	ctrl "sigs.k8s.io/controller-runtime"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
 )
 
 // log is for logging in this package.
-var _ = logf.Log.WithName("gcpmachinetemplate-resource")
+var machinetemplatelog = logf.Log.WithName("gcpmachinetemplate-resource")
 
 func (r *GCPMachineTemplate) SetupWebhookWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewWebhookManagedBy(mgr).
 		For(r).
 		Complete()
 }
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha4-gcpmachinetemplate,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=gcpmachinetemplates,versions=v1alpha4,name=validation.gcpmachinetemplate.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &GCPMachineTemplate{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPMachineTemplate) ValidateCreate() error {
+	machinetemplatelog.Info("validate create", "name", r.Name)
+
+	return r.validate()
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPMachineTemplate) ValidateUpdate(oldRaw runtime.Object) error {
+	machinetemplatelog.Info("validate update", "name", r.Name)
+
+	// The GCPMachines created from the template aren't updated with it, the changes are rolled out by
+	// referencing a new template instead, like the other infrastructure templates of Cluster API.
+	if old, ok := oldRaw.(*GCPMachineTemplate); ok && !reflect.DeepEqual(r.Spec, old.Spec) {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPMachineTemplate").GroupKind(), r.Name, field.ErrorList{
+			field.Forbidden(field.NewPath("spec"), "GCPMachineTemplate spec is immutable: create a new template and reference it from the "+
+				"MachineDeployment or the control plane to roll out the change"),
+		})
+	}
+
+	// The spec is unchanged, it was validated when the template was created.
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPMachineTemplate) ValidateDelete() error {
+	return nil
+}
+
+// validate validates the spec of the GCPMachines created from the template, so that the errors surface
+// when the template is applied instead of when a rollout creates the Machines.
+func (r *GCPMachineTemplate) validate() error {
+	fldPath := field.NewPath("spec", "template", "spec")
+	spec := &r.Spec.Template.Spec
+
+	// The name of the GCPMachines isn't known yet, the instance name template is rendered with the one
+	// of the template.
+	allErrs := spec.validate(fldPath, names.InstanceData{
+		ClusterName: r.Labels[clusterv1.ClusterLabelName],
+		Name:        r.Name,
+		Namespace:   r.Namespace,
+		Role:        "node",
+	})
+	if spec.ExistingInstance != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("existingInstance"), "an instance can only be adopted by a single GCPMachine"))
+	}
+	if spec.ProviderID != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("providerID"), "the provider ID is set on the GCPMachines"))
+	}
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPMachineTemplate").GroupKind(), r.Name, allErrs)
+	}
+
+	return nil
+}
@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestGCPMachineTemplateValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    GCPMachineSpec
+		wantErr string
+	}{
+		{
+			name: "valid",
+			spec: GCPMachineSpec{InstanceType: "n1-standard-2"},
+		},
+		{
+			name:    "with an invalid machine type",
+			spec:    GCPMachineSpec{InstanceType: "N1 standard"},
+			wantErr: "spec.template.spec.instanceType",
+		},
+		{
+			name:    "with an invalid instance name template",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", InstanceNameTemplate: pointer.StringPtr("{{ .Unknown }}")},
+			wantErr: "spec.template.spec.instanceNameTemplate",
+		},
+		{
+			name: "with accelerators on another machine type than N1",
+			spec: GCPMachineSpec{InstanceType: "e2-standard-4", GuestAccelerators: []Accelerator{
+				{Type: "nvidia-tesla-t4", Count: 1},
+			}},
+			wantErr: "accelerators can only be attached to N1 machine types",
+		},
+		{
+			name:    "with an invalid service account email",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", ServiceAccount: &ServiceAccount{Email: "my-sa"}},
+			wantErr: "spec.template.spec.serviceAccounts.email",
+		},
+		{
+			name:    "with an existing instance",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", ExistingInstance: pointer.StringPtr("gce://my-project/us-central1-a/my-instance")},
+			wantErr: "an instance can only be adopted by a single GCPMachine",
+		},
+		{
+			name:    "with a provider ID",
+			spec:    GCPMachineSpec{InstanceType: "n1-standard-2", ProviderID: pointer.StringPtr("gce://my-project/us-central1-a/my-instance")},
+			wantErr: "spec.template.spec.providerID",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			r := &GCPMachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-template"},
+				Spec:       GCPMachineTemplateSpec{Template: GCPMachineTemplateResource{Spec: tt.spec}},
+			}
+			err := r.ValidateCreate()
+			if tt.wantErr != "" {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring(tt.wantErr))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
+
+func TestGCPMachineTemplateValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		oldSpec GCPMachineSpec
+		update  func(*GCPMachineTemplate)
+		wantErr bool
+	}{
+		{
+			name:    "without change",
+			oldSpec: GCPMachineSpec{InstanceType: "n1-standard-2"},
+			update:  func(*GCPMachineTemplate) {},
+		},
+		{
+			name:    "with labels",
+			oldSpec: GCPMachineSpec{InstanceType: "n1-standard-2"},
+			update:  func(r *GCPMachineTemplate) { r.Labels = map[string]string{"team": "my-team"} },
+		},
+		{
+			// The template was created before its spec was validated.
+			name:    "with an unchanged invalid spec",
+			oldSpec: GCPMachineSpec{InstanceType: "n1-standard-2", ServiceAccount: &ServiceAccount{Email: "my-sa"}},
+			update:  func(r *GCPMachineTemplate) { r.Finalizers = nil },
+		},
+		{
+			name:    "with another machine type",
+			oldSpec: GCPMachineSpec{InstanceType: "n1-standard-2"},
+			update:  func(r *GCPMachineTemplate) { r.Spec.Template.Spec.InstanceType = "n1-standard-4" },
+			wantErr: true,
+		},
+		{
+			name:    "with additional labels",
+			oldSpec: GCPMachineSpec{InstanceType: "n1-standard-2"},
+			update:  func(r *GCPMachineTemplate) { r.Spec.Template.Spec.AdditionalLabels = Labels{"team": "my-team"} },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			old := &GCPMachineTemplate{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-template", Finalizers: []string{"my-finalizer"}},
+				Spec:       GCPMachineTemplateSpec{Template: GCPMachineTemplateResource{Spec: tt.oldSpec}},
+			}
+			r := old.DeepCopy()
+			tt.update(r)
+
+			err := r.ValidateUpdate(old)
+			if tt.wantErr {
+				g.Expect(apierrors.IsInvalid(err)).To(BeTrue())
+				g.Expect(err.Error()).To(ContainSubstring("GCPMachineTemplate spec is immutable"))
+			} else {
+				g.Expect(err).NotTo(HaveOccurred())
+			}
+		})
+	}
+}
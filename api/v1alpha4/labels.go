@@ -106,6 +106,18 @@ const (
 	// dedicated to this cluster api provider implementation.
 	NameGCPClusterAPIRole = NameGCPProviderPrefix + "role"
 
+	// NameGCPClusterNamespace is the tag name we use to mark the namespace of the cluster
+	// owning a resource, the name of a cluster being unique within its namespace only.
+	NameGCPClusterNamespace = NameGCPProviderPrefix + "namespace"
+
+	// NameGCPClusterUID is the tag name we use to mark the UID of the Cluster owning a
+	// resource, which tells apart the successive clusters of the same name.
+	NameGCPClusterUID = NameGCPProviderPrefix + "uid"
+
+	// NameGCPRetained is the tag name we use to mark the resources of a cluster detached on
+	// purpose, e.g. the root volumes kept once their instance is deleted, which aren't orphans.
+	NameGCPRetained = NameGCPProviderPrefix + "retained"
+
 	// APIServerRoleTagValue describes the value for the apiserver role.
 	APIServerRoleTagValue = "apiserver"
 )
@@ -128,6 +140,14 @@ type BuildParams struct {
 	// ClusterName is the cluster associated with the resource.
 	ClusterName string
 
+	// ClusterNamespace is the namespace of the cluster associated with the resource.
+	// +optional
+	ClusterNamespace string
+
+	// ClusterUID is the UID of the Cluster associated with the resource.
+	// +optional
+	ClusterUID string
+
 	// ResourceID is the unique identifier of the resource to be tagged.
 	ResourceID string
 
@@ -148,6 +168,12 @@ func Build(params BuildParams) Labels {
 	}
 
 	tags[ClusterTagKey(params.ClusterName)] = string(params.Lifecycle)
+	if params.ClusterNamespace != "" {
+		tags[NameGCPClusterNamespace] = params.ClusterNamespace
+	}
+	if params.ClusterUID != "" {
+		tags[NameGCPClusterUID] = params.ClusterUID
+	}
 	if params.Role != nil {
 		tags[NameGCPClusterAPIRole] = strings.ToLower(*params.Role)
 	}
@@ -18,6 +18,8 @@ package v1alpha4
 
 import (
 	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // GCPMachineTemplateResource describes the data needed to create am GCPMachine from a template.
@@ -44,16 +46,56 @@ type Network struct {
 	// +optional
 	FirewallRules map[string]string `json:"firewallRules,omitempty"`
 
+	// Routes is a map from the name of the static routes of the network spec to their full reference.
+	// +optional
+	Routes map[string]string `json:"routes,omitempty"`
+
+	// PrivateServicesAccessRange is the full reference to the global address of the range allocated to the
+	// Google managed services, if the private services access is configured.
+	// +optional
+	PrivateServicesAccessRange *string `json:"privateServicesAccessRange,omitempty"`
+
+	// DNSPolicy is the name of the DNS policy of the network, if configured.
+	// +optional
+	DNSPolicy *string `json:"dnsPolicy,omitempty"`
+
+	// DNSForwardingZones is a map from the name of the forwarding zones of the network spec to the name of their
+	// managed zone.
+	// +optional
+	DNSForwardingZones map[string]string `json:"dnsForwardingZones,omitempty"`
+
+	// ControlPlaneDNSZone is the name of the managed zone created for the name of the control plane endpoint, if any.
+	// +optional
+	ControlPlaneDNSZone *string `json:"controlPlaneDNSZone,omitempty"`
+
 	// Router is the full reference to the router created within the network
 	// it'll contain the cloud nat gateway
 	// +optional
 	Router *string `json:"router,omitempty"`
 
+	// RouterNat is the name of the cloud nat gateway configured in the router.
+	// +optional
+	RouterNat *string `json:"routerNat,omitempty"`
+
+	// NATIPs are the external IP addresses the cloud nat gateway translates
+	// the egress traffic of the nodes to.
+	// +optional
+	NATIPs []string `json:"natIPs,omitempty"`
+
+	// Subnets are the subnetworks of the network in the region of the cluster.
+	// +optional
+	Subnets []SubnetStatus `json:"subnets,omitempty"`
+
 	// APIServerAddress is the IPV4 global address assigned to the load balancer
 	// created for the API Server.
 	// +optional
 	APIServerAddress *string `json:"apiServerIpAddress,omitempty"`
 
+	// APIServerAddressSelfLink is the full reference to the global address
+	// reserved for the API Server.
+	// +optional
+	APIServerAddressSelfLink *string `json:"apiServerAddressSelfLink,omitempty"`
+
 	// APIServerHealthCheck is the full reference to the health check
 	// created for the API Server.
 	// +optional
@@ -64,6 +106,11 @@ type Network struct {
 	// +optional
 	APIServerInstanceGroups map[string]string `json:"apiServerInstanceGroups,omitempty"`
 
+	// APIServerNetworkEndpointGroups is a map from zone to the full reference to the network endpoint
+	// group of the control plane nodes created in the same zone, with the NetworkEndpointGroup backends.
+	// +optional
+	APIServerNetworkEndpointGroups map[string]string `json:"apiServerNetworkEndpointGroups,omitempty"`
+
 	// APIServerBackendService is the full reference to the backend service
 	// created for the API Server.
 	// +optional
@@ -78,6 +125,36 @@ type Network struct {
 	// created for the API Server.
 	// +optional
 	APIServerForwardingRule *string `json:"apiServerForwardingRule,omitempty"`
+
+	// APIServerIPv6Address is the global IPv6 address of a dual-stack load balancer.
+	// +optional
+	APIServerIPv6Address *string `json:"apiServerIPv6Address,omitempty"`
+
+	// APIServerIPv6ForwardingRule is the full reference to the forwarding rule of the IPv6 address of a dual-stack
+	// load balancer.
+	// +optional
+	APIServerIPv6ForwardingRule *string `json:"apiServerIPv6ForwardingRule,omitempty"`
+}
+
+// SubnetStatus summarizes a subnetwork of the network.
+type SubnetStatus struct {
+	// Name is the name of the subnetwork.
+	Name string `json:"name"`
+
+	// SelfLink is the full reference to the subnetwork.
+	SelfLink string `json:"selfLink"`
+
+	// CidrBlock is the primary IP range of the subnetwork.
+	CidrBlock string `json:"cidrBlock"`
+
+	// SecondaryCidrBlocks is a map from the name of the secondary IP ranges
+	// of the subnetwork to their range.
+	// +optional
+	SecondaryCidrBlocks map[string]string `json:"secondaryCidrBlocks,omitempty"`
+
+	// IPv6CidrBlock is the IPv6 range of a dual-stack subnetwork.
+	// +optional
+	IPv6CidrBlock string `json:"ipv6CidrBlock,omitempty"`
 }
 
 // NetworkSpec encapsulates all things related to a GCP network.
@@ -105,6 +182,253 @@ type NetworkSpec struct {
 	// Allow for configuration of load balancer backend (useful for changing apiserver port)
 	// +optional
 	LoadBalancerBackendPort *int32 `json:"loadBalancerBackendPort,omitempty"`
+
+	// Routes are the static routes of the network, e.g. to the pod ranges of a CNI or to an on-premises
+	// network. They are only created in the networks created or adopted by the cluster, and recreated
+	// when changed, the routes of GCP being immutable.
+	// +optional
+	Routes []RouteSpec `json:"routes,omitempty"`
+
+	// PrivateServicesAccess, if set, allocates a range of the network to the Google managed services, e.g.
+	// Cloud SQL or Memorystore, and peers the network with their networks, for the workloads to reach them
+	// on internal addresses. It is only configured in the networks created or adopted by the cluster.
+	// +optional
+	PrivateServicesAccess *PrivateServicesAccessSpec `json:"privateServicesAccess,omitempty"`
+
+	// DNS configures the Cloud DNS of the network, e.g. to resolve the corporate domains from the nodes.
+	// It is only configured in the networks created or adopted by the cluster.
+	// +optional
+	DNS *DNSSpec `json:"dns,omitempty"`
+
+	// NAT configures the cloud nat gateway created in the network, which translates all the ranges of all
+	// the subnetworks of the region of the cluster by default.
+	// +optional
+	NAT *NATSpec `json:"nat,omitempty"`
+
+	// AdditionalFirewallRules are the firewall rules of the network besides the ones of the cluster, e.g. to
+	// open the NodePort range or the traffic of a VPN. They are updated in place when changed, and deleted
+	// when removed from the spec.
+	// +optional
+	AdditionalFirewallRules []FirewallRuleSpec `json:"additionalFirewallRules,omitempty"`
+
+	// FirewallPolicy, if set to the Network mode, creates the firewall rules of the cluster, including the
+	// additional ones, as rules of a network firewall policy associated with the network instead of VPC firewall
+	// rules, e.g. when the organization policy denies the creation of VPC firewall rules. It can't be changed once set.
+	// +optional
+	FirewallPolicy *FirewallPolicySpec `json:"firewallPolicy,omitempty"`
+}
+
+// FirewallPolicyMode is the kind of the firewall rules of the cluster.
+type FirewallPolicyMode string
+
+const (
+	// FirewallPolicyModeClassic creates the firewall rules of the cluster as VPC firewall rules.
+	FirewallPolicyModeClassic = FirewallPolicyMode("Classic")
+
+	// FirewallPolicyModeNetwork creates the firewall rules of the cluster as rules of a network firewall
+	// policy, which is only available with the compute alpha API, i.e. the ComputeAlphaAPI feature gate.
+	FirewallPolicyModeNetwork = FirewallPolicyMode("Network")
+)
+
+// FirewallPolicySpec configures the firewall policy the firewall rules of the cluster are created in.
+type FirewallPolicySpec struct {
+	// Mode is the kind of the firewall rules of the cluster, Classic VPC firewall rules, or rules of the
+	// network firewall policy of the cluster in the Network mode. Defaults to Classic.
+	// +kubebuilder:validation:Enum=Classic;Network
+	// +optional
+	Mode FirewallPolicyMode `json:"mode,omitempty"`
+
+	// Name is the name of the network firewall policy created by the cluster in its project and associated with
+	// its network. Defaults to <resource name prefix>-firewall-policy. The policy applies to all the instances of
+	// the network, the network tags being unsupported by the firewall policies, and the traffic between the
+	// instances of the cluster is allowed from the ranges of the subnetworks of the region of the cluster.
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// Priority is the priority of the first rule of the cluster in the policy, the rules taking the free
+	// priorities from there. Defaults to 1000.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Priority *int32 `json:"priority,omitempty"`
+}
+
+// NATSpec configures the cloud nat gateway of the network.
+type NATSpec struct {
+	// Subnets, if set, restricts the gateway to these subnetworks of the region of the cluster, e.g. when the
+	// network is shared with workloads which must not egress through the gateway of the cluster.
+	// +optional
+	Subnets []NATSubnetSpec `json:"subnets,omitempty"`
+}
+
+// NATSubnetSpec configures a subnetwork translated by the cloud nat gateway.
+type NATSubnetSpec struct {
+	// Name is the name of the subnetwork.
+	Name string `json:"name"`
+
+	// SecondaryRangeNames, if set, restricts the translated ranges to the primary range and these secondary
+	// ranges of the subnetwork, e.g. the pod range. All the ranges of the subnetwork are translated otherwise.
+	// +optional
+	SecondaryRangeNames []string `json:"secondaryRangeNames,omitempty"`
+}
+
+// DNSSpec configures the Cloud DNS policy and forwarding zones of the network.
+type DNSSpec struct {
+	// Policy, if set, is the DNS policy of the network, there is at most one per network.
+	// +optional
+	Policy *DNSPolicySpec `json:"policy,omitempty"`
+
+	// ForwardingZones are the private zones forwarding the queries of their domain to name servers,
+	// e.g. the corporate domains to the on-premises name servers.
+	// +optional
+	ForwardingZones []DNSForwardingZoneSpec `json:"forwardingZones,omitempty"`
+}
+
+// DNSPolicySpec configures the DNS policy of the network.
+type DNSPolicySpec struct {
+	// EnableInboundForwarding allocates an inbound forwarder address in the subnetworks of the network,
+	// for the on-premises systems to resolve the names of the network.
+	// +optional
+	EnableInboundForwarding bool `json:"enableInboundForwarding,omitempty"`
+
+	// EnableLogging logs the DNS queries of the network to Cloud Logging.
+	// +optional
+	EnableLogging bool `json:"enableLogging,omitempty"`
+}
+
+// DNSForwardingZoneSpec configures a private zone forwarding the queries of its domain to name servers.
+type DNSForwardingZoneSpec struct {
+	// Name is the name of the zone, prefixed with the resource name prefix of the cluster.
+	Name string `json:"name"`
+
+	// DNSName is the domain of the zone, e.g. corp.example.com.
+	DNSName string `json:"dnsName"`
+
+	// TargetNameServers are the IPv4 addresses of the name servers the queries are forwarded to.
+	// +kubebuilder:validation:MinItems=1
+	TargetNameServers []string `json:"targetNameServers"`
+}
+
+// ControlPlaneDNSSpec configures the Cloud DNS record of the control plane endpoint.
+type ControlPlaneDNSSpec struct {
+	// Name is the DNS name of the control plane endpoint, e.g. api.my-cluster.example.com.
+	Name string `json:"name"`
+
+	// ManagedZone is the name of the existing managed zone of the project of the cluster the record is created in.
+	// If unset, a managed zone <cluster name>-control-plane is created for the name, private to the network of the
+	// cluster with an internal load balancer, public otherwise. A public zone must be delegated from its parent domain.
+	// +optional
+	ManagedZone *string `json:"managedZone,omitempty"`
+
+	// TTL is the time to live of the record in seconds, defaults to 300.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTL *int64 `json:"ttl,omitempty"`
+}
+
+// PrivateServicesAccessSpec configures the range allocated to the Google managed services.
+type PrivateServicesAccessSpec struct {
+	// PrefixLength is the prefix length of the allocated range. Defaults to 16.
+	// +kubebuilder:validation:Minimum=8
+	// +kubebuilder:validation:Maximum=24
+	// +optional
+	PrefixLength *int64 `json:"prefixLength,omitempty"`
+
+	// Address is the first address of the allocated range, e.g. 10.100.0.0, chosen by GCP if empty.
+	// +optional
+	Address *string `json:"address,omitempty"`
+}
+
+// RouteSpec configures a static route of the network. Exactly one next hop must be set.
+type RouteSpec struct {
+	// Name is the name of the route, prefixed with the resource name prefix of the cluster.
+	Name string `json:"name"`
+
+	// DestRange is the range of the destination addresses the route applies to, e.g. 192.168.0.0/16.
+	DestRange string `json:"destRange"`
+
+	// NextHopIP is the internal IP address of the instance or internal load balancer the packets are
+	// forwarded to.
+	// +optional
+	NextHopIP *string `json:"nextHopIP,omitempty"`
+
+	// NextHopInstance is the instance the packets are forwarded to, e.g. zones/us-central1-a/instances/my-vpn.
+	// +optional
+	NextHopInstance *string `json:"nextHopInstance,omitempty"`
+
+	// NextHopILB is the forwarding rule of the internal TCP/UDP load balancer the packets are forwarded to,
+	// e.g. regions/us-central1/forwardingRules/my-ilb.
+	// +optional
+	NextHopILB *string `json:"nextHopILB,omitempty"`
+
+	// NextHopGateway is the gateway the packets are forwarded to, only default-internet-gateway is supported.
+	// +kubebuilder:validation:Enum=default-internet-gateway
+	// +optional
+	NextHopGateway *string `json:"nextHopGateway,omitempty"`
+
+	// Priority breaks the ties between the routes of the same destination range, the lowest value winning.
+	// Defaults to 1000.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Priority *int64 `json:"priority,omitempty"`
+
+	// Tags are the network tags of the instances the route applies to, all the instances of the network
+	// if empty.
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+}
+
+// FirewallRuleDirection is the direction of the traffic a firewall rule applies to.
+type FirewallRuleDirection string
+
+const (
+	// FirewallRuleDirectionIngress applies the rule to the traffic received by the instances.
+	FirewallRuleDirectionIngress = FirewallRuleDirection("INGRESS")
+
+	// FirewallRuleDirectionEgress applies the rule to the traffic sent by the instances.
+	FirewallRuleDirectionEgress = FirewallRuleDirection("EGRESS")
+)
+
+// FirewallRuleSpec configures an additional firewall rule of the network of the cluster.
+type FirewallRuleSpec struct {
+	// Name is the name of the rule, prefixed with the resource name prefix of the cluster.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Direction is the direction of the traffic the rule applies to. Defaults to INGRESS.
+	// +kubebuilder:validation:Enum=INGRESS;EGRESS
+	// +optional
+	Direction FirewallRuleDirection `json:"direction,omitempty"`
+
+	// Allowed are the protocols and ports the rule allows.
+	// +kubebuilder:validation:MinItems=1
+	Allowed []FirewallAllowedSpec `json:"allowed"`
+
+	// SourceRanges are the ranges of the source addresses of the ingress traffic, e.g. 10.8.0.0/16.
+	// Defaults to all the addresses.
+	// +optional
+	SourceRanges []string `json:"sourceRanges,omitempty"`
+
+	// DestinationRanges are the ranges of the destination addresses of the egress traffic.
+	// Defaults to all the addresses.
+	// +optional
+	DestinationRanges []string `json:"destinationRanges,omitempty"`
+
+	// TargetTags are the network tags of the instances the rule applies to. Defaults to the control
+	// plane and node instances of the cluster.
+	// +optional
+	TargetTags []string `json:"targetTags,omitempty"`
+}
+
+// FirewallAllowedSpec is a protocol, and optionally its ports, allowed by a firewall rule.
+type FirewallAllowedSpec struct {
+	// Protocol is the name of the IP protocol, e.g. tcp, udp or icmp, or its number.
+	Protocol string `json:"protocol"`
+
+	// Ports are the ports or port ranges of the tcp, udp or sctp protocol, e.g. 30000-32767.
+	// Defaults to all the ports.
+	// +optional
+	Ports []string `json:"ports,omitempty"`
 }
 
 // SubnetSpec configures an GCP Subnet.
@@ -128,7 +452,7 @@ type SubnetSpec struct {
 	// +optional
 	SecondaryCidrBlocks map[string]string `json:"secondaryCidrBlocks,omitempty"`
 
-	// Region is the name of the region where the Subnetwork resides.
+	// Region is the name of the region where the Subnetwork resides, defaults to the region of the cluster.
 	Region string `json:"region,omitempty"`
 
 	// PrivateGoogleAccess defines whether VMs in this subnet can access
@@ -141,6 +465,75 @@ type SubnetSpec struct {
 	// listings. If not set the default behavior is to disable flow logging.
 	// +optional
 	EnableFlowLogs *bool `json:"routeTableId"`
+
+	// Roles are the roles of the subnetwork in the region of the cluster. The instances of the machines which don't
+	// set their subnet are placed in the subnetwork of their role, the ControlPlane or the Worker one, and the address
+	// of an Internal load balancer is reserved in the InternalLoadBalancer one, or else the ControlPlane one.
+	// +optional
+	Roles []SubnetRole `json:"roles,omitempty"`
+
+	// StackType is the IP stack of the subnetwork, defaults to IPV4_ONLY. The instances of an IPV4_IPV6 subnetwork
+	// are dual-stack, with an IPv6 address of its IPv6 range besides their IPv4 one. IPv6 is enabled on an existing
+	// subnetwork, but never disabled.
+	// +optional
+	StackType StackType `json:"stackType,omitempty"`
+
+	// IPv6AccessType is the access type of the IPv6 range of an IPV4_IPV6 subnetwork, defaults to EXTERNAL.
+	// It's applied when IPv6 is enabled, its later changes are ignored.
+	// +optional
+	IPv6AccessType IPv6AccessType `json:"ipv6AccessType,omitempty"`
+}
+
+// StackType is the IP stack of a subnetwork or a load balancer.
+// +kubebuilder:validation:Enum=IPV4_ONLY;IPV4_IPV6
+type StackType string
+
+const (
+	// StackTypeIPv4Only is an IPv4 only stack.
+	StackTypeIPv4Only = StackType("IPV4_ONLY")
+
+	// StackTypeIPv4IPv6 is an IPv4 and IPv6 dual stack.
+	StackTypeIPv4IPv6 = StackType("IPV4_IPV6")
+)
+
+// IPv6AccessType is the access type of the IPv6 range of a dual-stack subnetwork.
+// +kubebuilder:validation:Enum=INTERNAL;EXTERNAL
+type IPv6AccessType string
+
+const (
+	// IPv6AccessTypeExternal is a range of external IPv6 addresses, reachable from the internet subject to the
+	// firewall rules. The instances get their external IPv6 address through an IPv6 access config.
+	IPv6AccessTypeExternal = IPv6AccessType("EXTERNAL")
+
+	// IPv6AccessTypeInternal is a range of internal IPv6 addresses, only reachable from the network. The internal
+	// IPv6 range of the network must have been enabled outside of the provider.
+	IPv6AccessTypeInternal = IPv6AccessType("INTERNAL")
+)
+
+// SubnetRole is the role of a subnetwork in the cluster.
+// +kubebuilder:validation:Enum=ControlPlane;Worker;InternalLoadBalancer
+type SubnetRole string
+
+const (
+	// SubnetRoleControlPlane is the role of the subnetwork of the control plane instances.
+	SubnetRoleControlPlane = SubnetRole("ControlPlane")
+
+	// SubnetRoleWorker is the role of the subnetwork of the worker instances.
+	SubnetRoleWorker = SubnetRole("Worker")
+
+	// SubnetRoleInternalLoadBalancer is the role of the subnetwork of the address of an Internal load balancer.
+	SubnetRoleInternalLoadBalancer = SubnetRole("InternalLoadBalancer")
+)
+
+// HasRole returns true if the subnet has the role.
+func (s *SubnetSpec) HasRole(role SubnetRole) bool {
+	for _, r := range s.Roles {
+		if r == role {
+			return true
+		}
+	}
+
+	return false
 }
 
 // String returns a string representation of the subnet.
@@ -183,6 +576,17 @@ func (s Subnets) FilterByRegion(region string) (res Subnets) {
 	return
 }
 
+// FindByRole returns the first subnet of the region having the role, or nil.
+func (s Subnets) FindByRole(region string, role SubnetRole) *SubnetSpec {
+	for _, x := range s {
+		if x.Region == region && x.HasRole(role) {
+			return x
+		}
+	}
+
+	return nil
+}
+
 // InstanceStatus describes the state of an GCP instance.
 type InstanceStatus string
 
@@ -228,3 +632,314 @@ type ServiceAccount struct {
 	// account.
 	Scopes []string `json:"scopes,omitempty"`
 }
+
+const (
+	// DefaultServiceAccountEmail designates the default compute service account of the project.
+	DefaultServiceAccountEmail = "default"
+
+	// CloudPlatformScope is the OAuth scope of all the Google Cloud APIs, the access of the service
+	// account being restricted by its IAM roles instead. It's the default scope of the instances.
+	CloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+	// scopePrefix is the prefix of the OAuth scopes of the Google APIs.
+	scopePrefix = "https://www.googleapis.com/auth/"
+)
+
+// BastionSpec defines the bastion host of a cluster.
+type BastionSpec struct {
+	// InstanceType is the machine type of the bastion, defaults to e2-micro.
+	// +optional
+	InstanceType string `json:"instanceType,omitempty"`
+
+	// Image is the image of the bastion boot disk, defaults to the Debian image family.
+	// +optional
+	Image *string `json:"image,omitempty"`
+
+	// Zone is the zone of the bastion, defaults to the first zone of the region.
+	// +optional
+	Zone *string `json:"zone,omitempty"`
+
+	// Subnet is the name of the subnetwork of the bastion, required in networks without
+	// automatically created subnetworks.
+	// +optional
+	Subnet *string `json:"subnet,omitempty"`
+
+	// IAPOnly, if true, creates the bastion without an external IP, reachable only through
+	// the IAP TCP forwarding, e.g. with gcloud compute ssh --tunnel-through-iap.
+	// +optional
+	IAPOnly bool `json:"iapOnly,omitempty"`
+
+	// AllowedSourceRanges are the CIDR blocks allowed to reach the bastion over SSH through
+	// its external IP, defaults to 0.0.0.0/0. It's ignored by IAP-only bastions.
+	// +optional
+	AllowedSourceRanges []string `json:"allowedSourceRanges,omitempty"`
+}
+
+// BastionStatus describes the bastion host of a cluster.
+type BastionStatus struct {
+	// SelfLink is the full reference to the bastion instance.
+	SelfLink string `json:"selfLink"`
+
+	// PrivateIP is the internal IP of the bastion.
+	// +optional
+	PrivateIP string `json:"privateIP,omitempty"`
+
+	// PublicIP is the external IP of the bastion, empty for IAP-only bastions.
+	// +optional
+	PublicIP string `json:"publicIP,omitempty"`
+}
+
+// BreakGlassSSHSpec defines the break-glass SSH key pair of a cluster.
+type BreakGlassSSHSpec struct {
+	// User is the user the public key is authorized for on the instances, defaults to break-glass.
+	// +kubebuilder:validation:Pattern=`^[a-z_][a-z0-9_-]*$`
+	// +optional
+	User string `json:"user,omitempty"`
+}
+
+// BreakGlassSSHStatus describes the break-glass SSH key pair of a cluster.
+type BreakGlassSSHStatus struct {
+	// SecretName is the name of the Secret holding the private key.
+	SecretName string `json:"secretName"`
+
+	// PublicKey is the public key, in the authorized_keys format.
+	PublicKey string `json:"publicKey"`
+
+	// Fingerprint is the SHA256 fingerprint of the public key, as printed by ssh-keygen -l.
+	Fingerprint string `json:"fingerprint"`
+}
+
+// MaintenanceWindowSpec defines the recurring window within which the disruptive changes of a cluster are applied.
+type MaintenanceWindowSpec struct {
+	// Days are the days of the week the window starts on, every day if empty.
+	// +optional
+	Days []Weekday `json:"days,omitempty"`
+
+	// StartTime is the time of the day the window starts at, in the HH:MM format, in UTC.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	StartTime string `json:"startTime"`
+
+	// Duration is the duration of the window, e.g. 4h, at most 24h.
+	Duration metav1.Duration `json:"duration"`
+}
+
+// Weekday is a day of the week.
+// +kubebuilder:validation:Enum=Monday;Tuesday;Wednesday;Thursday;Friday;Saturday;Sunday
+type Weekday string
+
+// Accelerator is a guest accelerator attached to an instance.
+type Accelerator struct {
+	// Type is the type of the accelerator, e.g. nvidia-tesla-t4.
+	Type string `json:"type"`
+
+	// Count is the number of accelerators of the type.
+	// +kubebuilder:validation:Minimum=1
+	Count int64 `json:"count"`
+}
+
+// ReservationSpec describes a zonal capacity reservation of a cluster.
+type ReservationSpec struct {
+	// Name is the name of the reservation in the cluster. The GCP reservation is named after the
+	// resource name prefix of the cluster and this name.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Zone is the zone of the reservation.
+	Zone string `json:"zone"`
+
+	// InstanceType is the machine type of the reserved instances, e.g. n1-standard-2.
+	// It can't be changed once the reservation is created.
+	InstanceType string `json:"instanceType"`
+
+	// Count is the number of reserved instances.
+	// +kubebuilder:validation:Minimum=1
+	Count int64 `json:"count"`
+
+	// GuestAccelerators are the accelerators of the reserved instances.
+	// They can't be changed once the reservation is created.
+	// +optional
+	GuestAccelerators []Accelerator `json:"guestAccelerators,omitempty"`
+
+	// SpecificReservationRequired, if true, reserves the capacity to the instances of the GCPMachines
+	// consuming the reservation by name. Otherwise, the matching instances of the project consume it.
+	// +optional
+	SpecificReservationRequired bool `json:"specificReservationRequired,omitempty"`
+}
+
+// ReservationStatus describes a capacity reservation of a cluster.
+type ReservationStatus struct {
+	// Name is the name of the reservation in the cluster.
+	Name string `json:"name"`
+
+	// SelfLink is the full reference to the GCP reservation.
+	SelfLink string `json:"selfLink"`
+
+	// Count is the number of reserved instances.
+	Count int64 `json:"count"`
+
+	// InUseCount is the number of reserved instances in use.
+	// +optional
+	InUseCount int64 `json:"inUseCount,omitempty"`
+}
+
+// SoleTenantNodeGroupSpec describes a sole-tenant node group of a cluster, and the node template it's created from.
+type SoleTenantNodeGroupSpec struct {
+	// Name is the name of the node group in the cluster. The GCP node group and node template are named
+	// after the resource name prefix of the cluster and this name.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// Zone is the zone of the node group, in the region of the cluster.
+	Zone string `json:"zone"`
+
+	// NodeType is the type of the nodes, e.g. n1-node-96-624. It can't be changed once the node group is created.
+	NodeType string `json:"nodeType"`
+
+	// Size is the number of nodes of the group. The group is scaled in by deleting its nodes without instances only.
+	// +kubebuilder:validation:Minimum=1
+	Size int64 `json:"size"`
+
+	// MaintenancePolicy is the maintenance policy of the node group, defaults to the GCP default.
+	// It can't be changed once the node group is created.
+	// +kubebuilder:validation:Enum=DEFAULT;RESTART_IN_PLACE;MIGRATE_WITHIN_NODE_GROUP
+	// +optional
+	MaintenancePolicy *string `json:"maintenancePolicy,omitempty"`
+}
+
+// SoleTenantNodeGroupStatus describes a sole-tenant node group of a cluster.
+type SoleTenantNodeGroupStatus struct {
+	// Name is the name of the node group in the cluster.
+	Name string `json:"name"`
+
+	// SelfLink is the full reference to the GCP node group.
+	SelfLink string `json:"selfLink"`
+
+	// Size is the number of nodes of the group.
+	Size int64 `json:"size"`
+}
+
+// LoadBalancerType is the type of the API server load balancer.
+type LoadBalancerType string
+
+const (
+	// LoadBalancerTypeProxy is a global TCP proxy load balancer in front of the instance groups
+	// of the control plane instances.
+	LoadBalancerTypeProxy LoadBalancerType = "Proxy"
+
+	// LoadBalancerTypeTargetInstance is a regional forwarding rule to a target instance of one
+	// control plane instance, without health check, instance groups nor backend service. It's meant
+	// for the ephemeral clusters with a single control plane machine, e.g. in tests.
+	LoadBalancerTypeTargetInstance LoadBalancerType = "TargetInstance"
+
+	// LoadBalancerTypeNone is no load balancer, the control plane endpoint being managed outside of the provider,
+	// e.g. an existing load balancer, DNS round-robin or kube-vip. The endpoint must be set, and routed to the API
+	// server port of the control plane instances.
+	LoadBalancerTypeNone LoadBalancerType = "None"
+)
+
+// LoadBalancerSpec defines the load balancer of the API server.
+type LoadBalancerSpec struct {
+	// Type is the type of the load balancer, defaults to Proxy. The control plane endpoint of a
+	// TargetInstance load balancer listens on the API server port of the instances, as the traffic
+	// is forwarded to them as is. With None, no load balancer nor its firewall rules are created, the
+	// control plane endpoint must be set. It can't be changed once set.
+	// +kubebuilder:validation:Enum=Proxy;TargetInstance;None
+	// +optional
+	Type LoadBalancerType `json:"type,omitempty"`
+
+	// BackendType is the type of the backends of a Proxy load balancer, defaults to InstanceGroup.
+	// It can't be changed once set.
+	// +kubebuilder:validation:Enum=InstanceGroup;NetworkEndpointGroup
+	// +optional
+	BackendType LoadBalancerBackendType `json:"backendType,omitempty"`
+
+	// Scheme is the scheme of a Proxy load balancer, defaults to External. The control plane endpoint
+	// of an Internal load balancer is only reachable from the network of the cluster, and listens on
+	// the API server port of the instances. It can't be changed once set.
+	// +kubebuilder:validation:Enum=External;Internal
+	// +optional
+	Scheme LoadBalancerScheme `json:"scheme,omitempty"`
+
+	// HealthCheck tunes the health check of the API server backends of a Proxy load balancer.
+	// +optional
+	HealthCheck *LoadBalancerHealthCheckSpec `json:"healthCheck,omitempty"`
+
+	// SessionAffinity is the session affinity of the backend service of a Proxy load balancer, defaults to NONE.
+	// With CLIENT_IP, the connections of a client are sent to the same control plane instance while it's healthy.
+	// +kubebuilder:validation:Enum=NONE;CLIENT_IP
+	// +optional
+	SessionAffinity *string `json:"sessionAffinity,omitempty"`
+
+	// ConnectionDrainingTimeoutSec is how long the established connections to a backend removed from a Proxy load
+	// balancer are kept, defaults to 0, i.e. they are closed.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	ConnectionDrainingTimeoutSec *int64 `json:"connectionDrainingTimeoutSec,omitempty"`
+
+	// SecurityPolicy is the name of a Cloud Armor security policy of the project, attached to the backend service
+	// of an External Proxy load balancer. When unset, the security policy of the backend service is left as is.
+	// +optional
+	SecurityPolicy *string `json:"securityPolicy,omitempty"`
+
+	// StackType is the IP stack of the frontend of an External Proxy load balancer, defaults to IPV4_ONLY. With
+	// IPV4_IPV6, a global IPv6 address is forwarded to the TCP proxy besides the IPv4 one, which remains the control
+	// plane endpoint. The proxy reaches the backends over IPv4, whatever the IP version of the client.
+	// +optional
+	StackType StackType `json:"stackType,omitempty"`
+}
+
+// LoadBalancerHealthCheckSpec tunes the health check of the API server backends. The unset fields keep their default.
+type LoadBalancerHealthCheckSpec struct {
+	// CheckIntervalSec is the interval between two probes of a backend, defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=300
+	// +optional
+	CheckIntervalSec *int64 `json:"checkIntervalSec,omitempty"`
+
+	// TimeoutSec is how long a probe waits for the backend, defaults to 5. It can't exceed the check interval.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=300
+	// +optional
+	TimeoutSec *int64 `json:"timeoutSec,omitempty"`
+
+	// HealthyThreshold is the number of consecutive successful probes for an unhealthy backend to be healthy, defaults to 5.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	HealthyThreshold *int64 `json:"healthyThreshold,omitempty"`
+
+	// UnhealthyThreshold is the number of consecutive failed probes for a healthy backend to be unhealthy, defaults to 3.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	// +optional
+	UnhealthyThreshold *int64 `json:"unhealthyThreshold,omitempty"`
+}
+
+// LoadBalancerScheme is the scheme of a Proxy load balancer.
+type LoadBalancerScheme string
+
+const (
+	// LoadBalancerSchemeExternal is a global external TCP proxy load balancer, reachable from the internet.
+	LoadBalancerSchemeExternal LoadBalancerScheme = "External"
+
+	// LoadBalancerSchemeInternal is a regional internal TCP load balancer, reachable from the network
+	// of the cluster. The traffic is forwarded to the instance groups of the control plane instances as is,
+	// so they must be in the region of the cluster.
+	LoadBalancerSchemeInternal LoadBalancerScheme = "Internal"
+)
+
+// LoadBalancerBackendType is the type of the backends of a Proxy load balancer.
+type LoadBalancerBackendType string
+
+const (
+	// LoadBalancerBackendInstanceGroup balances the traffic to an unmanaged instance group of the control
+	// plane instances per zone.
+	LoadBalancerBackendInstanceGroup LoadBalancerBackendType = "InstanceGroup"
+
+	// LoadBalancerBackendNetworkEndpointGroup balances the traffic to a zonal network endpoint group of the
+	// API server endpoints of the control plane instances per zone. The health of each endpoint is reported
+	// on its own, and an endpoint is detached before its instance is deleted, without changing the membership
+	// of an instance group.
+	LoadBalancerBackendNetworkEndpointGroup LoadBalancerBackendType = "NetworkEndpointGroup"
+)
@@ -27,6 +27,21 @@ import (
 	"sigs.k8s.io/cluster-api/errors"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Accelerator) DeepCopyInto(out *Accelerator) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Accelerator.
+func (in *Accelerator) DeepCopy() *Accelerator {
+	if in == nil {
+		return nil
+	}
+	out := new(Accelerator)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AttachedDiskSpec) DeepCopyInto(out *AttachedDiskSpec) {
 	*out = *in
@@ -40,6 +55,21 @@ func (in *AttachedDiskSpec) DeepCopyInto(out *AttachedDiskSpec) {
 		*out = new(int64)
 		**out = **in
 	}
+	if in.DeviceName != nil {
+		in, out := &in.DeviceName, &out.DeviceName
+		*out = new(string)
+		**out = **in
+	}
+	if in.Interface != nil {
+		in, out := &in.Interface, &out.Interface
+		*out = new(DiskInterface)
+		**out = **in
+	}
+	if in.AutoDelete != nil {
+		in, out := &in.AutoDelete, &out.AutoDelete
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AttachedDiskSpec.
@@ -52,6 +82,86 @@ func (in *AttachedDiskSpec) DeepCopy() *AttachedDiskSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BastionSpec) DeepCopyInto(out *BastionSpec) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.Zone != nil {
+		in, out := &in.Zone, &out.Zone
+		*out = new(string)
+		**out = **in
+	}
+	if in.Subnet != nil {
+		in, out := &in.Subnet, &out.Subnet
+		*out = new(string)
+		**out = **in
+	}
+	if in.AllowedSourceRanges != nil {
+		in, out := &in.AllowedSourceRanges, &out.AllowedSourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BastionSpec.
+func (in *BastionSpec) DeepCopy() *BastionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BastionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BastionStatus) DeepCopyInto(out *BastionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BastionStatus.
+func (in *BastionStatus) DeepCopy() *BastionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BastionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BreakGlassSSHSpec) DeepCopyInto(out *BreakGlassSSHSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BreakGlassSSHSpec.
+func (in *BreakGlassSSHSpec) DeepCopy() *BreakGlassSSHSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BreakGlassSSHSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BreakGlassSSHStatus) DeepCopyInto(out *BreakGlassSSHStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BreakGlassSSHStatus.
+func (in *BreakGlassSSHStatus) DeepCopy() *BreakGlassSSHStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BreakGlassSSHStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BuildParams) DeepCopyInto(out *BuildParams) {
 	*out = *in
@@ -79,6 +189,143 @@ func (in *BuildParams) DeepCopy() *BuildParams {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ControlPlaneDNSSpec) DeepCopyInto(out *ControlPlaneDNSSpec) {
+	*out = *in
+	if in.ManagedZone != nil {
+		in, out := &in.ManagedZone, &out.ManagedZone
+		*out = new(string)
+		**out = **in
+	}
+	if in.TTL != nil {
+		in, out := &in.TTL, &out.TTL
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ControlPlaneDNSSpec.
+func (in *ControlPlaneDNSSpec) DeepCopy() *ControlPlaneDNSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ControlPlaneDNSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSForwardingZoneSpec) DeepCopyInto(out *DNSForwardingZoneSpec) {
+	*out = *in
+	if in.TargetNameServers != nil {
+		in, out := &in.TargetNameServers, &out.TargetNameServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSForwardingZoneSpec.
+func (in *DNSForwardingZoneSpec) DeepCopy() *DNSForwardingZoneSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSForwardingZoneSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSPolicySpec) DeepCopyInto(out *DNSPolicySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSPolicySpec.
+func (in *DNSPolicySpec) DeepCopy() *DNSPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DNSSpec) DeepCopyInto(out *DNSSpec) {
+	*out = *in
+	if in.Policy != nil {
+		in, out := &in.Policy, &out.Policy
+		*out = new(DNSPolicySpec)
+		**out = **in
+	}
+	if in.ForwardingZones != nil {
+		in, out := &in.ForwardingZones, &out.ForwardingZones
+		*out = make([]DNSForwardingZoneSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DNSSpec.
+func (in *DNSSpec) DeepCopy() *DNSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DNSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskEncryption) DeepCopyInto(out *DiskEncryption) {
+	*out = *in
+	if in.KMSKeyServiceAccount != nil {
+		in, out := &in.KMSKeyServiceAccount, &out.KMSKeyServiceAccount
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskEncryption.
+func (in *DiskEncryption) DeepCopy() *DiskEncryption {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskEncryption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EtcdDisk) DeepCopyInto(out *EtcdDisk) {
+	*out = *in
+	if in.DeviceType != nil {
+		in, out := &in.DeviceType, &out.DeviceType
+		*out = new(DiskType)
+		**out = **in
+	}
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		*out = new(int64)
+		**out = **in
+	}
+	if in.KMSKeyName != nil {
+		in, out := &in.KMSKeyName, &out.KMSKeyName
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EtcdDisk.
+func (in *EtcdDisk) DeepCopy() *EtcdDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(EtcdDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Filter) DeepCopyInto(out *Filter) {
 	*out = *in
@@ -99,6 +346,83 @@ func (in *Filter) DeepCopy() *Filter {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallAllowedSpec) DeepCopyInto(out *FirewallAllowedSpec) {
+	*out = *in
+	if in.Ports != nil {
+		in, out := &in.Ports, &out.Ports
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallAllowedSpec.
+func (in *FirewallAllowedSpec) DeepCopy() *FirewallAllowedSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallAllowedSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallPolicySpec) DeepCopyInto(out *FirewallPolicySpec) {
+	*out = *in
+	if in.Priority != nil {
+		in, out := &in.Priority, &out.Priority
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallPolicySpec.
+func (in *FirewallPolicySpec) DeepCopy() *FirewallPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirewallRuleSpec) DeepCopyInto(out *FirewallRuleSpec) {
+	*out = *in
+	if in.Allowed != nil {
+		in, out := &in.Allowed, &out.Allowed
+		*out = make([]FirewallAllowedSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SourceRanges != nil {
+		in, out := &in.SourceRanges, &out.SourceRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DestinationRanges != nil {
+		in, out := &in.DestinationRanges, &out.DestinationRanges
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetTags != nil {
+		in, out := &in.TargetTags, &out.TargetTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirewallRuleSpec.
+func (in *FirewallRuleSpec) DeepCopy() *FirewallRuleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FirewallRuleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *GCPCluster) DeepCopyInto(out *GCPCluster) {
 	*out = *in
@@ -162,12 +486,27 @@ func (in *GCPClusterList) DeepCopyObject() runtime.Object {
 func (in *GCPClusterSpec) DeepCopyInto(out *GCPClusterSpec) {
 	*out = *in
 	out.ControlPlaneEndpoint = in.ControlPlaneEndpoint
+	if in.ControlPlaneDNS != nil {
+		in, out := &in.ControlPlaneDNS, &out.ControlPlaneDNS
+		*out = new(ControlPlaneDNSSpec)
+		(*in).DeepCopyInto(*out)
+	}
 	in.Network.DeepCopyInto(&out.Network)
 	if in.FailureDomains != nil {
 		in, out := &in.FailureDomains, &out.FailureDomains
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.ExcludedFailureDomains != nil {
+		in, out := &in.ExcludedFailureDomains, &out.ExcludedFailureDomains
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalControlPlaneRegions != nil {
+		in, out := &in.AdditionalControlPlaneRegions, &out.AdditionalControlPlaneRegions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.AdditionalLabels != nil {
 		in, out := &in.AdditionalLabels, &out.AdditionalLabels
 		*out = make(Labels, len(*in))
@@ -175,6 +514,46 @@ func (in *GCPClusterSpec) DeepCopyInto(out *GCPClusterSpec) {
 			(*out)[key] = val
 		}
 	}
+	if in.ResourceNamePrefix != nil {
+		in, out := &in.ResourceNamePrefix, &out.ResourceNamePrefix
+		*out = new(string)
+		**out = **in
+	}
+	if in.Bastion != nil {
+		in, out := &in.Bastion, &out.Bastion
+		*out = new(BastionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BreakGlassSSH != nil {
+		in, out := &in.BreakGlassSSH, &out.BreakGlassSSH
+		*out = new(BreakGlassSSHSpec)
+		**out = **in
+	}
+	in.LoadBalancer.DeepCopyInto(&out.LoadBalancer)
+	if in.Reservations != nil {
+		in, out := &in.Reservations, &out.Reservations
+		*out = make([]ReservationSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.SoleTenantNodeGroups != nil {
+		in, out := &in.SoleTenantNodeGroups, &out.SoleTenantNodeGroups
+		*out = make([]SoleTenantNodeGroupSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MachineDefaults != nil {
+		in, out := &in.MachineDefaults, &out.MachineDefaults
+		*out = new(MachineDefaults)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MaintenanceWindow != nil {
+		in, out := &in.MaintenanceWindow, &out.MaintenanceWindow
+		*out = new(MaintenanceWindowSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPClusterSpec.
@@ -198,6 +577,45 @@ func (in *GCPClusterStatus) DeepCopyInto(out *GCPClusterStatus) {
 		}
 	}
 	in.Network.DeepCopyInto(&out.Network)
+	if in.Operations != nil {
+		in, out := &in.Operations, &out.Operations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.OwnedResources != nil {
+		in, out := &in.OwnedResources, &out.OwnedResources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Bastion != nil {
+		in, out := &in.Bastion, &out.Bastion
+		*out = new(BastionStatus)
+		**out = **in
+	}
+	if in.BreakGlassSSH != nil {
+		in, out := &in.BreakGlassSSH, &out.BreakGlassSSH
+		*out = new(BreakGlassSSHStatus)
+		**out = **in
+	}
+	if in.Reservations != nil {
+		in, out := &in.Reservations, &out.Reservations
+		*out = make([]ReservationStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.SoleTenantNodeGroups != nil {
+		in, out := &in.SoleTenantNodeGroups, &out.SoleTenantNodeGroups
+		*out = make([]SoleTenantNodeGroupStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1alpha4.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPClusterStatus.
@@ -277,6 +695,11 @@ func (in *GCPMachineSpec) DeepCopyInto(out *GCPMachineSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.InstanceNameTemplate != nil {
+		in, out := &in.InstanceNameTemplate, &out.InstanceNameTemplate
+		*out = new(string)
+		**out = **in
+	}
 	if in.ProviderID != nil {
 		in, out := &in.ProviderID, &out.ProviderID
 		*out = new(string)
@@ -292,6 +715,11 @@ func (in *GCPMachineSpec) DeepCopyInto(out *GCPMachineSpec) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.ImageLookup != nil {
+		in, out := &in.ImageLookup, &out.ImageLookup
+		*out = new(ImageLookup)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.AdditionalLabels != nil {
 		in, out := &in.AdditionalLabels, &out.AdditionalLabels
 		*out = make(Labels, len(*in))
@@ -316,11 +744,31 @@ func (in *GCPMachineSpec) DeepCopyInto(out *GCPMachineSpec) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.AdditionalInstanceGroups != nil {
+		in, out := &in.AdditionalInstanceGroups, &out.AdditionalInstanceGroups
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.TargetPools != nil {
+		in, out := &in.TargetPools, &out.TargetPools
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	if in.RootDeviceType != nil {
 		in, out := &in.RootDeviceType, &out.RootDeviceType
 		*out = new(DiskType)
 		**out = **in
 	}
+	if in.RootDeviceAutoDelete != nil {
+		in, out := &in.RootDeviceAutoDelete, &out.RootDeviceAutoDelete
+		*out = new(bool)
+		**out = **in
+	}
+	if in.RootDeviceName != nil {
+		in, out := &in.RootDeviceName, &out.RootDeviceName
+		*out = new(string)
+		**out = **in
+	}
 	if in.AdditionalDisks != nil {
 		in, out := &in.AdditionalDisks, &out.AdditionalDisks
 		*out = make([]AttachedDiskSpec, len(*in))
@@ -328,11 +776,78 @@ func (in *GCPMachineSpec) DeepCopyInto(out *GCPMachineSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.EtcdDisk != nil {
+		in, out := &in.EtcdDisk, &out.EtcdDisk
+		*out = new(EtcdDisk)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DiskEncryption != nil {
+		in, out := &in.DiskEncryption, &out.DiskEncryption
+		*out = new(DiskEncryption)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ServiceAccount != nil {
 		in, out := &in.ServiceAccount, &out.ServiceAccount
 		*out = new(ServiceAccount)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Reservation != nil {
+		in, out := &in.Reservation, &out.Reservation
+		*out = new(string)
+		**out = **in
+	}
+	if in.SoleTenantNodeGroup != nil {
+		in, out := &in.SoleTenantNodeGroup, &out.SoleTenantNodeGroup
+		*out = new(string)
+		**out = **in
+	}
+	if in.NodeAffinities != nil {
+		in, out := &in.NodeAffinities, &out.NodeAffinities
+		*out = make([]NodeAffinity, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.MinCPUPlatform != nil {
+		in, out := &in.MinCPUPlatform, &out.MinCPUPlatform
+		*out = new(string)
+		**out = **in
+	}
+	if in.OnHostMaintenance != nil {
+		in, out := &in.OnHostMaintenance, &out.OnHostMaintenance
+		*out = new(HostMaintenancePolicy)
+		**out = **in
+	}
+	if in.AutomaticRestart != nil {
+		in, out := &in.AutomaticRestart, &out.AutomaticRestart
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ExistingInstance != nil {
+		in, out := &in.ExistingInstance, &out.ExistingInstance
+		*out = new(string)
+		**out = **in
+	}
+	if in.GuestAccelerators != nil {
+		in, out := &in.GuestAccelerators, &out.GuestAccelerators
+		*out = make([]Accelerator, len(*in))
+		copy(*out, *in)
+	}
+	if in.ContainerOptimizedOS != nil {
+		in, out := &in.ContainerOptimizedOS, &out.ContainerOptimizedOS
+		*out = new(bool)
+		**out = **in
+	}
+	if in.ShieldedInstanceConfig != nil {
+		in, out := &in.ShieldedInstanceConfig, &out.ShieldedInstanceConfig
+		*out = new(ShieldedInstanceConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BootstrapDataBucket != nil {
+		in, out := &in.BootstrapDataBucket, &out.BootstrapDataBucket
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMachineSpec.
@@ -358,6 +873,11 @@ func (in *GCPMachineStatus) DeepCopyInto(out *GCPMachineStatus) {
 		*out = new(InstanceStatus)
 		**out = **in
 	}
+	if in.Scheduling != nil {
+		in, out := &in.Scheduling, &out.Scheduling
+		*out = new(InstanceScheduling)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.FailureReason != nil {
 		in, out := &in.FailureReason, &out.FailureReason
 		*out = new(errors.MachineStatusError)
@@ -368,6 +888,13 @@ func (in *GCPMachineStatus) DeepCopyInto(out *GCPMachineStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make(apiv1alpha4.Conditions, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMachineStatus.
@@ -444,69 +971,304 @@ func (in *GCPMachineTemplateResource) DeepCopyInto(out *GCPMachineTemplateResour
 	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMachineTemplateResource.
-func (in *GCPMachineTemplateResource) DeepCopy() *GCPMachineTemplateResource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMachineTemplateResource.
+func (in *GCPMachineTemplateResource) DeepCopy() *GCPMachineTemplateResource {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPMachineTemplateResource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GCPMachineTemplateSpec) DeepCopyInto(out *GCPMachineTemplateSpec) {
+	*out = *in
+	in.Template.DeepCopyInto(&out.Template)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMachineTemplateSpec.
+func (in *GCPMachineTemplateSpec) DeepCopy() *GCPMachineTemplateSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(GCPMachineTemplateSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageLookup) DeepCopyInto(out *ImageLookup) {
+	*out = *in
+	if in.Project != nil {
+		in, out := &in.Project, &out.Project
+		*out = new(string)
+		**out = **in
+	}
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageLookup.
+func (in *ImageLookup) DeepCopy() *ImageLookup {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageLookup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstanceScheduling) DeepCopyInto(out *InstanceScheduling) {
+	*out = *in
+	if in.LastPreemptionTime != nil {
+		in, out := &in.LastPreemptionTime, &out.LastPreemptionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastHostMaintenanceTime != nil {
+		in, out := &in.LastHostMaintenanceTime, &out.LastHostMaintenanceTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstanceScheduling.
+func (in *InstanceScheduling) DeepCopy() *InstanceScheduling {
+	if in == nil {
+		return nil
+	}
+	out := new(InstanceScheduling)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in Labels) DeepCopyInto(out *Labels) {
+	{
+		in := &in
+		*out = make(Labels, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Labels.
+func (in Labels) DeepCopy() Labels {
+	if in == nil {
+		return nil
+	}
+	out := new(Labels)
+	in.DeepCopyInto(out)
+	return *out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerHealthCheckSpec) DeepCopyInto(out *LoadBalancerHealthCheckSpec) {
+	*out = *in
+	if in.CheckIntervalSec != nil {
+		in, out := &in.CheckIntervalSec, &out.CheckIntervalSec
+		*out = new(int64)
+		**out = **in
+	}
+	if in.TimeoutSec != nil {
+		in, out := &in.TimeoutSec, &out.TimeoutSec
+		*out = new(int64)
+		**out = **in
+	}
+	if in.HealthyThreshold != nil {
+		in, out := &in.HealthyThreshold, &out.HealthyThreshold
+		*out = new(int64)
+		**out = **in
+	}
+	if in.UnhealthyThreshold != nil {
+		in, out := &in.UnhealthyThreshold, &out.UnhealthyThreshold
+		*out = new(int64)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerHealthCheckSpec.
+func (in *LoadBalancerHealthCheckSpec) DeepCopy() *LoadBalancerHealthCheckSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerHealthCheckSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LoadBalancerSpec) DeepCopyInto(out *LoadBalancerSpec) {
+	*out = *in
+	if in.HealthCheck != nil {
+		in, out := &in.HealthCheck, &out.HealthCheck
+		*out = new(LoadBalancerHealthCheckSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SessionAffinity != nil {
+		in, out := &in.SessionAffinity, &out.SessionAffinity
+		*out = new(string)
+		**out = **in
+	}
+	if in.ConnectionDrainingTimeoutSec != nil {
+		in, out := &in.ConnectionDrainingTimeoutSec, &out.ConnectionDrainingTimeoutSec
+		*out = new(int64)
+		**out = **in
+	}
+	if in.SecurityPolicy != nil {
+		in, out := &in.SecurityPolicy, &out.SecurityPolicy
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LoadBalancerSpec.
+func (in *LoadBalancerSpec) DeepCopy() *LoadBalancerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LoadBalancerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MachineDefaults) DeepCopyInto(out *MachineDefaults) {
+	*out = *in
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(string)
+		**out = **in
+	}
+	if in.ImageFamily != nil {
+		in, out := &in.ImageFamily, &out.ImageFamily
+		*out = new(string)
+		**out = **in
+	}
+	if in.ServiceAccount != nil {
+		in, out := &in.ServiceAccount, &out.ServiceAccount
+		*out = new(ServiceAccount)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdditionalNetworkTags != nil {
+		in, out := &in.AdditionalNetworkTags, &out.AdditionalNetworkTags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdditionalLabels != nil {
+		in, out := &in.AdditionalLabels, &out.AdditionalLabels
+		*out = make(Labels, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Subnet != nil {
+		in, out := &in.Subnet, &out.Subnet
+		*out = new(string)
+		**out = **in
+	}
+	if in.PublicIP != nil {
+		in, out := &in.PublicIP, &out.PublicIP
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MachineDefaults.
+func (in *MachineDefaults) DeepCopy() *MachineDefaults {
+	if in == nil {
+		return nil
+	}
+	out := new(MachineDefaults)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowSpec) DeepCopyInto(out *MaintenanceWindowSpec) {
+	*out = *in
+	if in.Days != nil {
+		in, out := &in.Days, &out.Days
+		*out = make([]Weekday, len(*in))
+		copy(*out, *in)
+	}
+	out.Duration = in.Duration
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowSpec.
+func (in *MaintenanceWindowSpec) DeepCopy() *MaintenanceWindowSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(GCPMachineTemplateResource)
+	out := new(MaintenanceWindowSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *GCPMachineTemplateSpec) DeepCopyInto(out *GCPMachineTemplateSpec) {
+func (in *MetadataItem) DeepCopyInto(out *MetadataItem) {
 	*out = *in
-	in.Template.DeepCopyInto(&out.Template)
+	if in.Value != nil {
+		in, out := &in.Value, &out.Value
+		*out = new(string)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GCPMachineTemplateSpec.
-func (in *GCPMachineTemplateSpec) DeepCopy() *GCPMachineTemplateSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataItem.
+func (in *MetadataItem) DeepCopy() *MetadataItem {
 	if in == nil {
 		return nil
 	}
-	out := new(GCPMachineTemplateSpec)
+	out := new(MetadataItem)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in Labels) DeepCopyInto(out *Labels) {
-	{
-		in := &in
-		*out = make(Labels, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
+func (in *NATSpec) DeepCopyInto(out *NATSpec) {
+	*out = *in
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]NATSubnetSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Labels.
-func (in Labels) DeepCopy() Labels {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NATSpec.
+func (in *NATSpec) DeepCopy() *NATSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Labels)
+	out := new(NATSpec)
 	in.DeepCopyInto(out)
-	return *out
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MetadataItem) DeepCopyInto(out *MetadataItem) {
+func (in *NATSubnetSpec) DeepCopyInto(out *NATSubnetSpec) {
 	*out = *in
-	if in.Value != nil {
-		in, out := &in.Value, &out.Value
-		*out = new(string)
-		**out = **in
+	if in.SecondaryRangeNames != nil {
+		in, out := &in.SecondaryRangeNames, &out.SecondaryRangeNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataItem.
-func (in *MetadataItem) DeepCopy() *MetadataItem {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NATSubnetSpec.
+func (in *NATSubnetSpec) DeepCopy() *NATSubnetSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MetadataItem)
+	out := new(NATSubnetSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -526,16 +1288,67 @@ func (in *Network) DeepCopyInto(out *Network) {
 			(*out)[key] = val
 		}
 	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.PrivateServicesAccessRange != nil {
+		in, out := &in.PrivateServicesAccessRange, &out.PrivateServicesAccessRange
+		*out = new(string)
+		**out = **in
+	}
+	if in.DNSPolicy != nil {
+		in, out := &in.DNSPolicy, &out.DNSPolicy
+		*out = new(string)
+		**out = **in
+	}
+	if in.DNSForwardingZones != nil {
+		in, out := &in.DNSForwardingZones, &out.DNSForwardingZones
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.ControlPlaneDNSZone != nil {
+		in, out := &in.ControlPlaneDNSZone, &out.ControlPlaneDNSZone
+		*out = new(string)
+		**out = **in
+	}
 	if in.Router != nil {
 		in, out := &in.Router, &out.Router
 		*out = new(string)
 		**out = **in
 	}
+	if in.RouterNat != nil {
+		in, out := &in.RouterNat, &out.RouterNat
+		*out = new(string)
+		**out = **in
+	}
+	if in.NATIPs != nil {
+		in, out := &in.NATIPs, &out.NATIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]SubnetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.APIServerAddress != nil {
 		in, out := &in.APIServerAddress, &out.APIServerAddress
 		*out = new(string)
 		**out = **in
 	}
+	if in.APIServerAddressSelfLink != nil {
+		in, out := &in.APIServerAddressSelfLink, &out.APIServerAddressSelfLink
+		*out = new(string)
+		**out = **in
+	}
 	if in.APIServerHealthCheck != nil {
 		in, out := &in.APIServerHealthCheck, &out.APIServerHealthCheck
 		*out = new(string)
@@ -548,6 +1361,13 @@ func (in *Network) DeepCopyInto(out *Network) {
 			(*out)[key] = val
 		}
 	}
+	if in.APIServerNetworkEndpointGroups != nil {
+		in, out := &in.APIServerNetworkEndpointGroups, &out.APIServerNetworkEndpointGroups
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 	if in.APIServerBackendService != nil {
 		in, out := &in.APIServerBackendService, &out.APIServerBackendService
 		*out = new(string)
@@ -563,6 +1383,16 @@ func (in *Network) DeepCopyInto(out *Network) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.APIServerIPv6Address != nil {
+		in, out := &in.APIServerIPv6Address, &out.APIServerIPv6Address
+		*out = new(string)
+		**out = **in
+	}
+	if in.APIServerIPv6ForwardingRule != nil {
+		in, out := &in.APIServerIPv6ForwardingRule, &out.APIServerIPv6ForwardingRule
+		*out = new(string)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Network.
@@ -604,6 +1434,40 @@ func (in *NetworkSpec) DeepCopyInto(out *NetworkSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.Routes != nil {
+		in, out := &in.Routes, &out.Routes
+		*out = make([]RouteSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PrivateServicesAccess != nil {
+		in, out := &in.PrivateServicesAccess, &out.PrivateServicesAccess
+		*out = new(PrivateServicesAccessSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DNS != nil {
+		in, out := &in.DNS, &out.DNS
+		*out = new(DNSSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NAT != nil {
+		in, out := &in.NAT, &out.NAT
+		*out = new(NATSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AdditionalFirewallRules != nil {
+		in, out := &in.AdditionalFirewallRules, &out.AdditionalFirewallRules
+		*out = make([]FirewallRuleSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.FirewallPolicy != nil {
+		in, out := &in.FirewallPolicy, &out.FirewallPolicy
+		*out = new(FirewallPolicySpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkSpec.
@@ -616,6 +1480,131 @@ func (in *NetworkSpec) DeepCopy() *NetworkSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodeAffinity) DeepCopyInto(out *NodeAffinity) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodeAffinity.
+func (in *NodeAffinity) DeepCopy() *NodeAffinity {
+	if in == nil {
+		return nil
+	}
+	out := new(NodeAffinity)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReservationSpec) DeepCopyInto(out *ReservationSpec) {
+	*out = *in
+	if in.GuestAccelerators != nil {
+		in, out := &in.GuestAccelerators, &out.GuestAccelerators
+		*out = make([]Accelerator, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReservationSpec.
+func (in *ReservationSpec) DeepCopy() *ReservationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ReservationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReservationStatus) DeepCopyInto(out *ReservationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReservationStatus.
+func (in *ReservationStatus) DeepCopy() *ReservationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ReservationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PrivateServicesAccessSpec) DeepCopyInto(out *PrivateServicesAccessSpec) {
+	*out = *in
+	if in.PrefixLength != nil {
+		in, out := &in.PrefixLength, &out.PrefixLength
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Address != nil {
+		in, out := &in.Address, &out.Address
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PrivateServicesAccessSpec.
+func (in *PrivateServicesAccessSpec) DeepCopy() *PrivateServicesAccessSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PrivateServicesAccessSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteSpec) DeepCopyInto(out *RouteSpec) {
+	*out = *in
+	if in.NextHopIP != nil {
+		in, out := &in.NextHopIP, &out.NextHopIP
+		*out = new(string)
+		**out = **in
+	}
+	if in.NextHopInstance != nil {
+		in, out := &in.NextHopInstance, &out.NextHopInstance
+		*out = new(string)
+		**out = **in
+	}
+	if in.NextHopILB != nil {
+		in, out := &in.NextHopILB, &out.NextHopILB
+		*out = new(string)
+		**out = **in
+	}
+	if in.NextHopGateway != nil {
+		in, out := &in.NextHopGateway, &out.NextHopGateway
+		*out = new(string)
+		**out = **in
+	}
+	if in.Priority != nil {
+		in, out := &in.Priority, &out.Priority
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Tags != nil {
+		in, out := &in.Tags, &out.Tags
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteSpec.
+func (in *RouteSpec) DeepCopy() *RouteSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServiceAccount) DeepCopyInto(out *ServiceAccount) {
 	*out = *in
@@ -636,6 +1625,93 @@ func (in *ServiceAccount) DeepCopy() *ServiceAccount {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ShieldedInstanceConfig) DeepCopyInto(out *ShieldedInstanceConfig) {
+	*out = *in
+	if in.SecureBoot != nil {
+		in, out := &in.SecureBoot, &out.SecureBoot
+		*out = new(bool)
+		**out = **in
+	}
+	if in.VirtualizedTrustedPlatformModule != nil {
+		in, out := &in.VirtualizedTrustedPlatformModule, &out.VirtualizedTrustedPlatformModule
+		*out = new(bool)
+		**out = **in
+	}
+	if in.IntegrityMonitoring != nil {
+		in, out := &in.IntegrityMonitoring, &out.IntegrityMonitoring
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ShieldedInstanceConfig.
+func (in *ShieldedInstanceConfig) DeepCopy() *ShieldedInstanceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ShieldedInstanceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SoleTenantNodeGroupSpec) DeepCopyInto(out *SoleTenantNodeGroupSpec) {
+	*out = *in
+	if in.MaintenancePolicy != nil {
+		in, out := &in.MaintenancePolicy, &out.MaintenancePolicy
+		*out = new(string)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SoleTenantNodeGroupSpec.
+func (in *SoleTenantNodeGroupSpec) DeepCopy() *SoleTenantNodeGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SoleTenantNodeGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SoleTenantNodeGroupStatus) DeepCopyInto(out *SoleTenantNodeGroupStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SoleTenantNodeGroupStatus.
+func (in *SoleTenantNodeGroupStatus) DeepCopy() *SoleTenantNodeGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SoleTenantNodeGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SubnetStatus) DeepCopyInto(out *SubnetStatus) {
+	*out = *in
+	if in.SecondaryCidrBlocks != nil {
+		in, out := &in.SecondaryCidrBlocks, &out.SecondaryCidrBlocks
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetStatus.
+func (in *SubnetStatus) DeepCopy() *SubnetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SubnetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SubnetSpec) DeepCopyInto(out *SubnetSpec) {
 	*out = *in
@@ -661,6 +1737,11 @@ func (in *SubnetSpec) DeepCopyInto(out *SubnetSpec) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Roles != nil {
+		in, out := &in.Roles, &out.Roles
+		*out = make([]SubnetRole, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SubnetSpec.
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// GoogleAccess is the way the GCP APIs are reached.
+type GoogleAccess string
+
+const (
+	// PublicGoogleAccess reaches the GCP APIs on their public endpoints.
+	PublicGoogleAccess GoogleAccess = "public"
+	// PrivateGoogleAccess reaches the GCP APIs through the private.googleapis.com VIP, without public egress.
+	PrivateGoogleAccess GoogleAccess = "private"
+	// RestrictedGoogleAccess reaches the GCP APIs through the restricted.googleapis.com VIP, which only serves
+	// the APIs supported by VPC Service Controls.
+	RestrictedGoogleAccess GoogleAccess = "restricted"
+)
+
+// googleAPIsDomain is the domain of the GCP APIs routed to the VIPs.
+const googleAPIsDomain = ".googleapis.com"
+
+// privateServiceConnectDomain is the domain of the Private Service Connect endpoints of the GCP APIs, which
+// resolve to their own addresses and are not routed to the VIPs.
+const privateServiceConnectDomain = ".p.googleapis.com"
+
+// lookupIPAddr resolves the VIPs, it is overridden in tests.
+var lookupIPAddr = net.DefaultResolver.LookupIPAddr
+
+// ParseGoogleAccess parses a GoogleAccess, the empty string being the public access.
+func ParseGoogleAccess(s string) (GoogleAccess, error) {
+	switch a := GoogleAccess(s); a {
+	case "", PublicGoogleAccess:
+		return PublicGoogleAccess, nil
+	case PrivateGoogleAccess, RestrictedGoogleAccess:
+		return a, nil
+	}
+
+	return "", errors.Errorf("unknown google access %q, expected public, private or restricted", s)
+}
+
+// Public returns true if the GCP APIs are reached on their public endpoints.
+func (a GoogleAccess) Public() bool {
+	return a == "" || a == PublicGoogleAccess
+}
+
+// vip returns the host of the VIP of the access and its IPv4 and IPv6 ranges,
+// see https://cloud.google.com/vpc/docs/configure-private-google-access#domain-options.
+func (a GoogleAccess) vip() (string, []string) {
+	switch a {
+	case PrivateGoogleAccess:
+		return "private.googleapis.com", []string{"199.36.153.8/30", "2600:2d00:2:2000::/64"}
+	case RestrictedGoogleAccess:
+		return "restricted.googleapis.com", []string{"199.36.153.4/30", "2600:2d00:2:1000::/64"}
+	}
+
+	return "", nil
+}
+
+// UseGoogleAccess validates that the VIP of the access resolves to its ranges, and connects the clients of the
+// GCP APIs to the VIP instead of the public endpoints of the *.googleapis.com APIs, their TLS server name being
+// left unchanged. It must be called before any client is created, and does nothing for the public access.
+//
+// Like TrustCABundle, it configures http.DefaultTransport, which the clients of the GCP APIs and of the token
+// endpoint of the credentials are based on. The metadata server isn't affected.
+func UseGoogleAccess(ctx context.Context, a GoogleAccess) error {
+	if a.Public() {
+		return nil
+	}
+
+	host, ranges := a.vip()
+	addrs, err := lookupIPAddr(ctx, host)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resolve %s", host)
+	}
+	for _, addr := range addrs {
+		if !inRanges(addr.IP, ranges) {
+			return errors.Errorf("%s resolves to %s, out of the ranges of the %s VIP %s", host, addr.IP, a, strings.Join(ranges, ", "))
+		}
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.Errorf("unexpected default transport %T", http.DefaultTransport)
+	}
+	transport.DialContext = dialVIP(host, transport.DialContext)
+
+	return nil
+}
+
+// dialVIP returns a dial function connecting to the VIP host instead of the hosts of the GCP APIs.
+func dialVIP(host string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if h, port, err := net.SplitHostPort(addr); err == nil && strings.HasSuffix(h, googleAPIsDomain) && !strings.HasSuffix(h, privateServiceConnectDomain) {
+			addr = net.JoinHostPort(host, port)
+		}
+
+		return dial(ctx, network, addr)
+	}
+}
+
+func inRanges(ip net.IP, ranges []string) bool {
+	for _, r := range ranges {
+		if _, cidr, err := net.ParseCIDR(r); err == nil && cidr.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
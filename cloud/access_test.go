@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestUseGoogleAccess(t *testing.T) {
+	g := NewWithT(t)
+
+	access, err := cloud.ParseGoogleAccess("")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(access.Public()).To(BeTrue())
+	_, err = cloud.ParseGoogleAccess("vpc-sc")
+	g.Expect(err).To(HaveOccurred())
+
+	resolved := map[string][]net.IPAddr{
+		"restricted.googleapis.com": {{IP: net.ParseIP("199.36.153.5")}},
+		"private.googleapis.com":    {{IP: net.ParseIP("142.250.0.1")}},
+	}
+	cloud.SetLookupIPAddr(func(_ context.Context, host string) ([]net.IPAddr, error) {
+		return resolved[host], nil
+	})
+	defer cloud.SetLookupIPAddr(net.DefaultResolver.LookupIPAddr)
+
+	transport := http.DefaultTransport.(*http.Transport)
+	defer func(dial func(ctx context.Context, network, addr string) (net.Conn, error)) {
+		transport.DialContext = dial
+	}(transport.DialContext)
+
+	g.Expect(cloud.UseGoogleAccess(context.TODO(), cloud.PrivateGoogleAccess)).To(MatchError(
+		"private.googleapis.com resolves to 142.250.0.1, out of the ranges of the private VIP 199.36.153.8/30, 2600:2d00:2:2000::/64"))
+	g.Expect(cloud.UseGoogleAccess(context.TODO(), cloud.RestrictedGoogleAccess)).To(Succeed())
+}
+
+func TestDialVIP(t *testing.T) {
+	g := NewWithT(t)
+
+	var dialed []string
+	dial := cloud.DialVIP("restricted.googleapis.com", func(_ context.Context, _, addr string) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, nil
+	})
+	for _, addr := range []string{"compute.googleapis.com:443", "oauth2.googleapis.com:443", "compute-myendpoint.p.googleapis.com:443", "169.254.169.254:80", "example.com:443"} {
+		_, _ = dial(context.TODO(), "tcp", addr)
+	}
+
+	g.Expect(dialed).To(Equal([]string{"restricted.googleapis.com:443", "restricted.googleapis.com:443", "compute-myendpoint.p.googleapis.com:443", "169.254.169.254:80", "example.com:443"}))
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"net/http"
+)
+
+// RequestReasonHeader is the header of the GCP API calls carrying their reason,
+// which is recorded in the audit logs of the calls.
+const RequestReasonHeader = "X-Goog-Request-Reason"
+
+// RequestAttribution attributes the GCP API calls to a cluster, by adding the
+// cluster to their user agent and setting their request reason.
+type RequestAttribution struct {
+	// Cluster is the namespaced name of the cluster the calls are made for.
+	Cluster string
+	// Reason is the reason of the calls, e.g. a change ticket.
+	Reason string
+}
+
+// Wrap is a WrapTransportFunc attributing the API calls.
+func (a *RequestAttribution) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &attributingTransport{attribution: *a, base: base}
+}
+
+type attributingTransport struct {
+	attribution RequestAttribution
+	base        http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *attributingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	if t.attribution.Cluster != "" {
+		userAgent := "capg-cluster/" + t.attribution.Cluster
+		if ua := req.Header.Get("User-Agent"); ua != "" {
+			userAgent = ua + " " + userAgent
+		}
+		req.Header.Set("User-Agent", userAgent)
+	}
+	if t.attribution.Reason != "" {
+		req.Header.Set(RequestReasonHeader, t.attribution.Reason)
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestRequestAttribution(t *testing.T) {
+	g := NewWithT(t)
+
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+	}))
+	defer server.Close()
+
+	attribution := &cloud.RequestAttribution{Cluster: "default/my-cluster", Reason: "CHG-1234"}
+	client := &http.Client{Transport: attribution.Wrap(http.DefaultTransport)}
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	req.Header.Set("User-Agent", "google-api-go-client/0.5")
+
+	resp, err := client.Do(req)
+	g.Expect(err).NotTo(HaveOccurred())
+	resp.Body.Close()
+	g.Expect(header.Get("User-Agent")).To(Equal("google-api-go-client/0.5 capg-cluster/default/my-cluster"))
+	g.Expect(header.Get(cloud.RequestReasonHeader)).To(Equal("CHG-1234"))
+	g.Expect(req.Header.Get(cloud.RequestReasonHeader)).To(BeEmpty())
+}
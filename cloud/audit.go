@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// AuditRecord is a mutating GCP API call made for a cluster.
+type AuditRecord struct {
+	// Time is the time the call was made.
+	Time time.Time `json:"time"`
+	// Verb is the operation, e.g. insert, put, patch, delete or a custom method like addInstances.
+	Verb string `json:"verb"`
+	// Resource is the path of the target resource, e.g. projects/my-project/global/networks/my-network.
+	Resource string `json:"resource"`
+	// Fields summarizes the change: the fields of the resource set by the call.
+	Fields []string `json:"fields,omitempty"`
+	// Operation is the name of the compute operation started by the call.
+	Operation string `json:"operation,omitempty"`
+	// OperationID is the ID of the compute operation started by the call.
+	OperationID string `json:"operationId,omitempty"`
+	// Code is the HTTP status code of the call, 0 if no response was received.
+	Code int `json:"code"`
+	// Error is the error of the call, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// AuditSink records the mutating GCP API calls.
+type AuditSink interface {
+	// Record records a mutating call made for the cluster.
+	Record(ctx context.Context, cluster types.NamespacedName, record AuditRecord)
+}
+
+// Audit records the mutating GCP API calls made for a cluster in a sink. The read-only
+// calls are not recorded.
+type Audit struct {
+	// Cluster is the namespaced name of the Cluster the calls are made for.
+	Cluster types.NamespacedName
+	// Sink is where the calls are recorded.
+	Sink AuditSink
+}
+
+// Wrap is a WrapTransportFunc recording the mutating API calls.
+func (a *Audit) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &auditTransport{audit: *a, base: base}
+}
+
+type auditTransport struct {
+	audit Audit
+	base  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *auditTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := strings.Index(req.URL.Path, "/projects/")
+	if i < 0 || req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return t.base.RoundTrip(req)
+	}
+	p := strings.Trim(req.URL.Path[i+1:], "/")
+	parent, verb := path.Split(p)
+	parent = strings.TrimSuffix(parent, "/")
+	if req.Method == http.MethodPost && readOnlyVerbs[verb] {
+		return t.base.RoundTrip(req)
+	}
+
+	req, data, err := copyBody(req)
+	if err != nil {
+		return nil, err
+	}
+	body := map[string]interface{}{}
+	_ = json.Unmarshal(data, &body)
+
+	record := AuditRecord{Time: time.Now(), Fields: changedFields(body)}
+	switch req.Method {
+	case http.MethodPost:
+		if name, ok := body["name"].(string); ok && name != "" && isInsert(p) {
+			record.Verb, record.Resource = "insert", p+"/"+name
+		} else {
+			record.Verb, record.Resource = verb, parent
+		}
+	default:
+		record.Verb, record.Resource = strings.ToLower(req.Method), p
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		record.Error = err.Error()
+		t.audit.Sink.Record(req.Context(), t.audit.Cluster, record)
+		return resp, err
+	}
+
+	record.Code = resp.StatusCode
+	if resp.Body != nil {
+		data, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(data))
+		if err == nil {
+			var res struct {
+				Name  string `json:"name"`
+				ID    string `json:"id"`
+				Error struct {
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			_ = json.Unmarshal(data, &res)
+			if resp.StatusCode < http.StatusBadRequest {
+				record.Operation, record.OperationID = res.Name, res.ID
+			} else {
+				record.Error = res.Error.Message
+			}
+		}
+	}
+	t.audit.Sink.Record(req.Context(), t.audit.Cluster, record)
+
+	return resp, nil
+}
+
+// changedFields returns the sorted fields of the request body, the fingerprints guarding the
+// concurrent updates aside.
+func changedFields(body map[string]interface{}) []string {
+	var fields []string
+	for k := range body {
+		if k != "fingerprint" && k != "labelFingerprint" {
+			fields = append(fields, k)
+		}
+	}
+	sort.Strings(fields)
+
+	return fields
+}
+
+// LogAuditSink is an AuditSink writing the mutating calls to a logger.
+type LogAuditSink struct {
+	Logger logr.Logger
+}
+
+// Record implements AuditSink.
+func (s *LogAuditSink) Record(_ context.Context, cluster types.NamespacedName, record AuditRecord) {
+	keysAndValues := []interface{}{
+		"cluster", cluster.String(),
+		"verb", record.Verb,
+		"resource", record.Resource,
+		"fields", record.Fields,
+		"operation", record.Operation,
+		"operationId", record.OperationID,
+		"code", record.Code,
+	}
+	if record.Error != "" {
+		keysAndValues = append(keysAndValues, "error", record.Error)
+	}
+	s.Logger.Info("GCP API call", keysAndValues...)
+}
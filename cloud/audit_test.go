@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	"k8s.io/apimachinery/pkg/types"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+type recordingSink struct {
+	records []cloud.AuditRecord
+}
+
+func (s *recordingSink) Record(_ context.Context, _ types.NamespacedName, record cloud.AuditRecord) {
+	s.records = append(s.records, record)
+}
+
+func TestAudit(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	sink := &recordingSink{}
+	audit := &cloud.Audit{Cluster: types.NamespacedName{Namespace: "default", Name: "my-cluster"}, Sink: sink}
+	audited, err := c.WithTransport(context.TODO(), audit.Wrap)
+	g.Expect(err).NotTo(HaveOccurred())
+	svc := audited.Compute()
+
+	op, err := svc.Networks.Insert("my-project", &compute.Network{Name: "my-network", Description: "capg"}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = svc.Networks.Get("my-project", "my-network").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	c.SetError(http.MethodDelete, "projects/my-project/global/networks/my-network", &googleapi.Error{Code: http.StatusBadRequest, Message: "in use"})
+	_, err = svc.Networks.Delete("my-project", "my-network").Do()
+	g.Expect(err).To(HaveOccurred())
+
+	// The read-only call is not recorded.
+	g.Expect(sink.records).To(HaveLen(2))
+	g.Expect(sink.records[0].Verb).To(Equal("insert"))
+	g.Expect(sink.records[0].Resource).To(Equal("projects/my-project/global/networks/my-network"))
+	g.Expect(sink.records[0].Fields).To(Equal([]string{"description", "name"}))
+	g.Expect(sink.records[0].Operation).To(Equal(op.Name))
+	g.Expect(sink.records[0].Code).To(Equal(http.StatusOK))
+	g.Expect(sink.records[1].Verb).To(Equal("delete"))
+	g.Expect(sink.records[1].Code).To(Equal(http.StatusBadRequest))
+	g.Expect(sink.records[1].Error).To(Equal("in use"))
+}
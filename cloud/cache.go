@@ -0,0 +1,75 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/cache"
+)
+
+const (
+	// DefaultLookupCacheTTL is the default duration the lookups are cached for.
+	DefaultLookupCacheTTL = 10 * time.Minute
+
+	lookupCacheSize = 1024
+)
+
+// LookupCache caches the results of the GCP lookups which rarely change, e.g. the zones of a region,
+// so that they are not repeated on every reconcile. It is safe for concurrent use.
+// A nil LookupCache doesn't cache anything.
+type LookupCache struct {
+	cache *cache.LRUExpireCache
+	ttl   time.Duration
+}
+
+// NewLookupCache returns a LookupCache expiring the entries after the given TTL.
+func NewLookupCache(ttl time.Duration) *LookupCache {
+	return &LookupCache{
+		cache: cache.NewLRUExpireCache(lookupCacheSize),
+		ttl:   ttl,
+	}
+}
+
+// Get returns the cached value for the key, calling fetch and caching its result on miss.
+// Errors are not cached.
+func (c *LookupCache) Get(key string, fetch func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return fetch()
+	}
+
+	return c.GetWithTTL(key, c.ttl, fetch)
+}
+
+// GetWithTTL is like Get, but caches the result of fetch for the given TTL
+// instead of the TTL of the cache.
+func (c *LookupCache) GetWithTTL(key string, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	if c == nil {
+		return fetch()
+	}
+	if v, ok := c.cache.Get(key); ok {
+		return v, nil
+	}
+
+	v, err := fetch()
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(key, v, ttl)
+
+	return v, nil
+}
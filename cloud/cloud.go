@@ -0,0 +1,306 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cloud implements the GCP cloud backend used by the scopes.
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	computebeta "google.golang.org/api/compute/v0.beta"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/api/option"
+	"google.golang.org/api/servicenetworking/v1"
+	"google.golang.org/api/serviceusage/v1"
+	"google.golang.org/api/storage/v1"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// WrapTransportFunc wraps the transport used to talk to the GCP APIs.
+type WrapTransportFunc func(base http.RoundTripper) http.RoundTripper
+
+// Cloud is the GCP backend the scopes and services talk to.
+// It allows the controllers to run against an in-memory fake in tests.
+type Cloud interface {
+	// Compute returns the compute API client.
+	Compute() *compute.Service
+
+	// ComputeBeta returns the compute beta API client. It must only be used by the features
+	// gated by feature.ComputeBetaAPI.
+	ComputeBeta() *computebeta.Service
+
+	// ComputeAlpha returns the compute alpha API client. It must only be used by the features
+	// gated by feature.ComputeAlphaAPI.
+	ComputeAlpha() *computealpha.Service
+
+	// ServiceNetworking returns the service networking API client, which peers the networks with the
+	// networks of the Google managed services.
+	ServiceNetworking() *servicenetworking.APIService
+
+	// ServiceUsage returns the service usage API client, which tells the APIs enabled in the projects.
+	ServiceUsage() *serviceusage.Service
+
+	// DNS returns the Cloud DNS API client.
+	DNS() *dns.Service
+
+	// Container returns the GKE API client. It must only be used by the features gated by feature.GKE.
+	Container() *container.Service
+
+	// Storage returns the Cloud Storage API client, which stores the bootstrap data of the instances
+	// too large for their metadata.
+	Storage() *storage.Service
+
+	// WithTransport returns a copy of the Cloud whose API calls go through the wrapped transport.
+	WithTransport(ctx context.Context, wrap WrapTransportFunc) (Cloud, error)
+}
+
+// Services are the GCP APIs whose endpoints can be overridden, by the name of their default host.
+var Services = []string{"compute", "container", "dns", "servicenetworking", "serviceusage", "storage"}
+
+// Options configures the clients of the GCP APIs of a Cloud.
+type Options struct {
+	// Endpoints overrides the root URLs of the GCP APIs by service, e.g. https://compute-example.p.googleapis.com/
+	// for compute, to reach them through a Private Service Connect endpoint, the endpoints of a sovereign cloud or
+	// an emulator. The path of the API, e.g. compute/v1/, is appended to the root URL.
+	Endpoints map[string]string
+
+	// UserAgent, if set, is appended to the user agent of the API calls.
+	UserAgent string
+
+	// TokenSource, if set, provides the access tokens of the API calls instead of the application default
+	// credentials, e.g. those of an impersonated service account.
+	TokenSource oauth2.TokenSource
+
+	// ClientOptions are the options of all the API clients, e.g. their credentials.
+	ClientOptions []option.ClientOption
+}
+
+// ParseEndpoints parses a comma separated list of root URLs by service, e.g. "compute=https://compute.example.com/",
+// in the <service>=<url> format.
+func ParseEndpoints(spec string) (map[string]string, error) {
+	res := map[string]string{}
+	if spec == "" {
+		return res, nil
+	}
+
+	for _, kv := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid endpoint %q, expected <service>=<url>", kv)
+		}
+		if i := sort.SearchStrings(Services, parts[0]); i == len(Services) || Services[i] != parts[0] {
+			return nil, errors.Errorf("unknown service %q, expected one of %s", parts[0], strings.Join(Services, ", "))
+		}
+		u, err := url.Parse(parts[1])
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, errors.Errorf("invalid endpoint %q of service %q, expected an http or https URL", parts[1], parts[0])
+		}
+		res[parts[0]] = parts[1]
+	}
+
+	return res, nil
+}
+
+// clientOptions returns the options of the client of the service, whose API is at path from the root URL.
+func (o Options) clientOptions(service, path string, opts []option.ClientOption) []option.ClientOption {
+	root, ok := o.Endpoints[service]
+	if !ok {
+		return opts
+	}
+
+	return append(append([]option.ClientOption{}, opts...), option.WithEndpoint(strings.TrimSuffix(root, "/")+"/"+path))
+}
+
+// authOptions returns the options authenticating the API calls.
+func (o Options) authOptions() []option.ClientOption {
+	if o.TokenSource == nil {
+		return o.ClientOptions
+	}
+
+	return append(append([]option.ClientOption{}, o.ClientOptions...), option.WithTokenSource(o.TokenSource))
+}
+
+type gcpCloud struct {
+	compute      *compute.Service
+	computeBeta  *computebeta.Service
+	computeAlpha *computealpha.Service
+	serviceNet   *servicenetworking.APIService
+	serviceUsage *serviceusage.Service
+	dns          *dns.Service
+	container    *container.Service
+	storage      *storage.Service
+	options      Options
+	wrap         WrapTransportFunc
+}
+
+// NewCloud returns a Cloud backed by the GCP APIs.
+func NewCloud(ctx context.Context, options Options) (Cloud, error) {
+	c, err := newGCPCloud(ctx, options, options.authOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// newGCPCloud creates the API clients of a Cloud with the client options, options being the options
+// the copies of the Cloud start from.
+func newGCPCloud(ctx context.Context, options Options, clientOpts []option.ClientOption) (*gcpCloud, error) {
+	computeSvc, err := compute.NewService(ctx, options.clientOptions("compute", "compute/v1/", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp compute client: %v", err)
+	}
+	// The clients of the beta and alpha APIs are created eagerly, which doesn't involve any API call,
+	// so that the Cloud doesn't need to be locked.
+	computeBetaSvc, err := computebeta.NewService(ctx, options.clientOptions("compute", "compute/beta/", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp compute beta client: %v", err)
+	}
+	computeAlphaSvc, err := computealpha.NewService(ctx, options.clientOptions("compute", "compute/alpha/", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp compute alpha client: %v", err)
+	}
+	serviceNetSvc, err := servicenetworking.NewService(ctx, options.clientOptions("servicenetworking", "", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp service networking client: %v", err)
+	}
+	serviceUsageSvc, err := serviceusage.NewService(ctx, options.clientOptions("serviceusage", "", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp service usage client: %v", err)
+	}
+	dnsSvc, err := dns.NewService(ctx, options.clientOptions("dns", "", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp dns client: %v", err)
+	}
+	containerSvc, err := container.NewService(ctx, options.clientOptions("container", "", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp container client: %v", err)
+	}
+	storageSvc, err := storage.NewService(ctx, options.clientOptions("storage", "storage/v1/", clientOpts)...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp storage client: %v", err)
+	}
+
+	// The user agent of the clients is set on the services, the option being ignored with a custom HTTP client.
+	if options.UserAgent != "" {
+		computeSvc.UserAgent = options.UserAgent
+		computeBetaSvc.UserAgent = options.UserAgent
+		computeAlphaSvc.UserAgent = options.UserAgent
+		serviceNetSvc.UserAgent = options.UserAgent
+		serviceUsageSvc.UserAgent = options.UserAgent
+		dnsSvc.UserAgent = options.UserAgent
+		containerSvc.UserAgent = options.UserAgent
+		storageSvc.UserAgent = options.UserAgent
+	}
+
+	return &gcpCloud{
+		compute:      computeSvc,
+		computeBeta:  computeBetaSvc,
+		computeAlpha: computeAlphaSvc,
+		serviceNet:   serviceNetSvc,
+		serviceUsage: serviceUsageSvc,
+		dns:          dnsSvc,
+		container:    containerSvc,
+		storage:      storageSvc,
+		options:      options,
+	}, nil
+}
+
+// Compute returns the compute API client.
+func (c *gcpCloud) Compute() *compute.Service {
+	return c.compute
+}
+
+// ComputeBeta returns the compute beta API client.
+func (c *gcpCloud) ComputeBeta() *computebeta.Service {
+	return c.computeBeta
+}
+
+// ComputeAlpha returns the compute alpha API client.
+func (c *gcpCloud) ComputeAlpha() *computealpha.Service {
+	return c.computeAlpha
+}
+
+// ServiceNetworking returns the service networking API client.
+func (c *gcpCloud) ServiceNetworking() *servicenetworking.APIService {
+	return c.serviceNet
+}
+
+// ServiceUsage returns the service usage API client.
+func (c *gcpCloud) ServiceUsage() *serviceusage.Service {
+	return c.serviceUsage
+}
+
+// DNS returns the Cloud DNS API client.
+func (c *gcpCloud) DNS() *dns.Service {
+	return c.dns
+}
+
+// Container returns the GKE API client.
+func (c *gcpCloud) Container() *container.Service {
+	return c.container
+}
+
+// Storage returns the Cloud Storage API client.
+func (c *gcpCloud) Storage() *storage.Service {
+	return c.storage
+}
+
+// WithTransport returns a copy of the Cloud whose API calls go through the wrapped transport.
+func (c *gcpCloud) WithTransport(ctx context.Context, wrap WrapTransportFunc) (Cloud, error) {
+	if c.wrap != nil {
+		inner, outer := c.wrap, wrap
+		wrap = func(base http.RoundTripper) http.RoundTripper {
+			return outer(inner(base))
+		}
+	}
+
+	authOpts := append([]option.ClientOption{option.WithScopes(compute.CloudPlatformScope)}, c.options.authOptions()...)
+	base, err := htransport.NewTransport(ctx, http.DefaultTransport, authOpts...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp transport: %v", err)
+	}
+
+	opts := append(append([]option.ClientOption{}, c.options.ClientOptions...), option.WithHTTPClient(&http.Client{Transport: wrap(base)}))
+	wrapped, err := newGCPCloud(ctx, c.options, opts)
+	if err != nil {
+		return nil, err
+	}
+	wrapped.wrap = wrap
+
+	return wrapped, nil
+}
+
+// DefaultProject returns the project of the application default credentials,
+// empty if the credentials are not bound to a project.
+func DefaultProject(ctx context.Context) (string, error) {
+	creds, err := google.FindDefaultCredentials(ctx, compute.CloudPlatformScope)
+	if err != nil {
+		return "", errors.Errorf("failed to find gcp default credentials: %v", err)
+	}
+
+	return creds.ProjectID, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestParseEndpoints(t *testing.T) {
+	g := NewWithT(t)
+
+	endpoints, err := cloud.ParseEndpoints("")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(endpoints).To(BeEmpty())
+
+	endpoints, err = cloud.ParseEndpoints("compute=https://compute.example.com/, storage=http://localhost:8080")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(endpoints).To(Equal(map[string]string{
+		"compute": "https://compute.example.com/",
+		"storage": "http://localhost:8080",
+	}))
+
+	for _, spec := range []string{"compute", "pubsub=https://pubsub.example.com", "compute=compute.example.com", "compute=ftp://compute.example.com"} {
+		_, err := cloud.ParseEndpoints(spec)
+		g.Expect(err).To(HaveOccurred(), spec)
+	}
+}
+
+func TestNewCloudOptions(t *testing.T) {
+	g := NewWithT(t)
+
+	var paths, userAgents, authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		userAgents = append(userAgents, r.UserAgent())
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	c, err := cloud.NewCloud(context.TODO(), cloud.Options{
+		Endpoints:   map[string]string{"compute": server.URL, "dns": server.URL + "/dns-emulator/"},
+		UserAgent:   "capg-test/1.0",
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "my-token"}),
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	_, err = c.Compute().Networks.Get("my-project", "my-network").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.ComputeBeta().Networks.Get("my-project", "my-network").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = c.DNS().ManagedZones.Get("my-project", "my-zone").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The copies of the Cloud keep the endpoints, the user agent and the token source.
+	wrapped, err := c.WithTransport(context.TODO(), func(base http.RoundTripper) http.RoundTripper { return base })
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = wrapped.Compute().Networks.Get("my-project", "my-network").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	g.Expect(paths).To(Equal([]string{
+		"/compute/v1/projects/my-project/global/networks/my-network",
+		"/compute/beta/projects/my-project/global/networks/my-network",
+		"/dns-emulator/dns/v1/projects/my-project/managedZones/my-zone",
+		"/compute/v1/projects/my-project/global/networks/my-network",
+	}))
+	for i := range paths {
+		g.Expect(userAgents[i]).To(HaveSuffix(" capg-test/1.0"))
+		g.Expect(authorizations[i]).To(Equal("Bearer my-token"))
+	}
+}
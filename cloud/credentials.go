@@ -0,0 +1,210 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// CredentialsFileEnv is the environment variable pointing to the key file of the application default credentials.
+const CredentialsFileEnv = "GOOGLE_APPLICATION_CREDENTIALS"
+
+// RequiredPermissions are the IAM permissions on the project the credentials need to manage the clusters.
+var RequiredPermissions = []string{
+	"compute.backendServices.create",
+	"compute.firewalls.create",
+	"compute.globalAddresses.create",
+	"compute.globalForwardingRules.create",
+	"compute.healthChecks.create",
+	"compute.instanceGroups.create",
+	"compute.instances.create",
+	"compute.instances.delete",
+	"compute.networks.create",
+	"compute.routers.create",
+	"compute.targetTcpProxies.create",
+	"iam.serviceAccounts.actAs",
+}
+
+// IgnoreEmptyCredentialsFile unsets GOOGLE_APPLICATION_CREDENTIALS if it points to an empty file, e.g. the
+// key mounted from the credentials Secret of a manager deployed without a key, for the application default
+// credentials to be looked up on the metadata server. It returns true if the variable was unset.
+func IgnoreEmptyCredentialsFile() (bool, error) {
+	path := os.Getenv(CredentialsFileEnv)
+	if path == "" {
+		return false, nil
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > 0 {
+		// A missing file is reported by the lookup of the credentials.
+		return false, nil
+	}
+
+	return true, os.Unsetenv(CredentialsFileEnv)
+}
+
+// CheckCredentials verifies that the application default credentials of the metadata server, e.g. those
+// of GKE Workload Identity, have a scope of the compute API and the RequiredPermissions on the project.
+// The credentials of a key file are not checked, they are not restricted by scopes.
+func CheckCredentials(ctx context.Context, project string) error {
+	creds, err := google.FindDefaultCredentials(ctx, compute.CloudPlatformScope)
+	if err != nil {
+		return errors.Errorf("failed to find gcp default credentials: %v", err)
+	}
+	if creds.JSON != nil || !metadata.OnGCE() {
+		return nil
+	}
+
+	scopes, err := metadata.Scopes("default")
+	if err != nil {
+		return errors.Wrap(err, "failed to get the scopes of the metadata server credentials")
+	}
+	switch {
+	case hasScope(scopes, compute.CloudPlatformScope):
+	case hasScope(scopes, compute.ComputeScope):
+		// The permissions can't be tested without the cloud-platform scope.
+		return nil
+	default:
+		return errors.Errorf("the metadata server credentials have neither the %s nor the %s scope, only %s",
+			compute.CloudPlatformScope, compute.ComputeScope, strings.Join(scopes, ", "))
+	}
+
+	return checkPermissions(ctx, project, option.WithCredentials(creds))
+}
+
+// ImpersonatedTokenSource returns the access tokens of the service account, impersonated with the application
+// default credentials through the chain of delegates, if any. The credentials need the Service Account Token
+// Creator role on the first delegate, or on the service account without delegates.
+func ImpersonatedTokenSource(ctx context.Context, serviceAccount string, delegates []string) (oauth2.TokenSource, error) {
+	ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+		TargetPrincipal: serviceAccount,
+		Scopes:          []string{compute.CloudPlatformScope},
+		Delegates:       delegates,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to impersonate service account %s", serviceAccount)
+	}
+
+	return ts, nil
+}
+
+// GKEUserScope is the scope of the access tokens of the kubeconfigs of the GKE clusters: the tokens only prove the
+// identity of their service account to the clusters, they can't be used with the GCP APIs.
+const GKEUserScope = "https://www.googleapis.com/auth/userinfo.email"
+
+// GKETokenRenewalMargin is how long before they expire the access tokens of the kubeconfigs of the GKE clusters are
+// renewed, the kubeconfigs never being written with a token about to expire.
+const GKETokenRenewalMargin = 10 * time.Minute
+
+// GKETokenSource returns the access tokens of the service account with the GKEUserScope, impersonated with the
+// application default credentials, for the kubeconfigs of the GKE clusters. The credentials need the Service
+// Account Token Creator role on the service account. The tokens are reported as expiring GKETokenRenewalMargin
+// before they actually do, a new one being generated then.
+func GKETokenSource(ctx context.Context, serviceAccount string, opts ...option.ClientOption) (oauth2.TokenSource, error) {
+	svc, err := iamcredentials.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to impersonate service account %s", serviceAccount)
+	}
+
+	return oauth2.ReuseTokenSource(nil, &gkeTokenSource{
+		ctx:      ctx,
+		accounts: svc.Projects.ServiceAccounts,
+		name:     "projects/-/serviceAccounts/" + serviceAccount,
+	}), nil
+}
+
+// gkeTokenSource generates a new access token of the service account on each call, unlike the impersonated token
+// sources which reuse theirs until they expire.
+type gkeTokenSource struct {
+	ctx      context.Context
+	accounts *iamcredentials.ProjectsServiceAccountsService
+	name     string
+}
+
+// Token generates an access token of the service account, reported as expiring GKETokenRenewalMargin early.
+func (ts *gkeTokenSource) Token() (*oauth2.Token, error) {
+	res, err := ts.accounts.GenerateAccessToken(ts.name, &iamcredentials.GenerateAccessTokenRequest{
+		Scope: []string{GKEUserScope},
+	}).Context(ts.ctx).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to generate an access token of %s", ts.name)
+	}
+	expiry, err := time.Parse(time.RFC3339, res.ExpireTime)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse the expiration time of the access token of %s", ts.name)
+	}
+
+	return &oauth2.Token{AccessToken: res.AccessToken, TokenType: "Bearer", Expiry: expiry.Add(-GKETokenRenewalMargin)}, nil
+}
+
+// CheckTokenSource verifies that the access tokens of the token source, e.g. those of an impersonated service
+// account, have the RequiredPermissions on the project.
+func CheckTokenSource(ctx context.Context, project string, ts oauth2.TokenSource) error {
+	return checkPermissions(ctx, project, option.WithTokenSource(ts))
+}
+
+// checkPermissions verifies that the credentials of the client options have the RequiredPermissions on the project.
+func checkPermissions(ctx context.Context, project string, opts ...option.ClientOption) error {
+	crm, err := cloudresourcemanager.NewService(ctx, opts...)
+	if err != nil {
+		return errors.Errorf("failed to create gcp resource manager client: %v", err)
+	}
+	res, err := crm.Projects.TestIamPermissions(project, &cloudresourcemanager.TestIamPermissionsRequest{
+		Permissions: RequiredPermissions,
+	}).Context(ctx).Do()
+	if err != nil {
+		return errors.Wrapf(err, "failed to test the permissions of the credentials on project %s", project)
+	}
+
+	granted := make(map[string]bool, len(res.Permissions))
+	for _, p := range res.Permissions {
+		granted[p] = true
+	}
+	var missing []string
+	for _, p := range RequiredPermissions {
+		if !granted[p] {
+			missing = append(missing, p)
+		}
+	}
+	if len(missing) > 0 {
+		return errors.Errorf("the credentials lack the permissions %s on project %s", strings.Join(missing, ", "), project)
+	}
+
+	return nil
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
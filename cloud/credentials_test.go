@@ -0,0 +1,117 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/option"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestCheckPermissions(t *testing.T) {
+	g := NewWithT(t)
+
+	granted := cloud.RequiredPermissions
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.URL.Path).To(HaveSuffix("/projects/my-project:testIamPermissions"))
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"permissions": granted})
+	}))
+	defer server.Close()
+
+	opts := []option.ClientOption{option.WithEndpoint(server.URL), option.WithoutAuthentication()}
+	g.Expect(cloud.CheckPermissions(context.TODO(), "my-project", opts...)).To(Succeed())
+
+	granted = cloud.RequiredPermissions[1:]
+	err := cloud.CheckPermissions(context.TODO(), "my-project", opts...)
+	g.Expect(err).To(MatchError("the credentials lack the permissions " + cloud.RequiredPermissions[0] + " on project my-project"))
+}
+
+func TestGKETokenSource(t *testing.T) {
+	g := NewWithT(t)
+
+	expiry := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.Expect(r.URL.Path).To(HaveSuffix("/projects/-/serviceAccounts/kubeconfig@my-project.iam.gserviceaccount.com:generateAccessToken"))
+		calls++
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"accessToken": fmt.Sprintf("my-token-%d", calls),
+			"expireTime":  expiry.Format(time.RFC3339),
+		})
+	}))
+	defer server.Close()
+
+	ts, err := cloud.GKETokenSource(context.TODO(), "kubeconfig@my-project.iam.gserviceaccount.com",
+		option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The token is reported as expiring early, to be renewed before it actually expires, and reused until then.
+	token, err := ts.Token()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(token.AccessToken).To(Equal("my-token-1"))
+	g.Expect(token.Expiry).To(Equal(expiry.Add(-cloud.GKETokenRenewalMargin)))
+	token, err = ts.Token()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(token.AccessToken).To(Equal("my-token-1"))
+
+	// A token within the renewal margin is replaced.
+	expiry = time.Now().Add(cloud.GKETokenRenewalMargin / 2).UTC().Truncate(time.Second)
+	ts, err = cloud.GKETokenSource(context.TODO(), "kubeconfig@my-project.iam.gserviceaccount.com",
+		option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = ts.Token()
+	g.Expect(err).NotTo(HaveOccurred())
+	token, err = ts.Token()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(token.AccessToken).To(Equal("my-token-3"))
+}
+
+func TestIgnoreEmptyCredentialsFile(t *testing.T) {
+	g := NewWithT(t)
+
+	dir, err := ioutil.TempDir("", "credentials")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	defer os.Setenv(cloud.CredentialsFileEnv, os.Getenv(cloud.CredentialsFileEnv))
+
+	key := filepath.Join(dir, "key.json")
+	g.Expect(ioutil.WriteFile(key, []byte("{}"), 0600)).To(Succeed())
+	os.Setenv(cloud.CredentialsFileEnv, key)
+	g.Expect(cloud.IgnoreEmptyCredentialsFile()).To(BeFalse())
+	g.Expect(os.Getenv(cloud.CredentialsFileEnv)).To(Equal(key))
+
+	empty := filepath.Join(dir, "empty.json")
+	g.Expect(ioutil.WriteFile(empty, nil, 0600)).To(Succeed())
+	os.Setenv(cloud.CredentialsFileEnv, empty)
+	g.Expect(cloud.IgnoreEmptyCredentialsFile()).To(BeTrue())
+	_, ok := os.LookupEnv(cloud.CredentialsFileEnv)
+	g.Expect(ok).To(BeFalse())
+}
@@ -0,0 +1,142 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+// ClientStats collects the statistics of the GCP API calls, per project, to troubleshoot slow reconciles
+// in large installations. It serves them as JSON.
+type ClientStats struct {
+	mu       sync.Mutex
+	projects map[string]*ProjectStats
+}
+
+// ProjectStats are the statistics of the GCP API calls to a project.
+type ProjectStats struct {
+	// InFlight is the number of calls in progress.
+	InFlight int `json:"inFlight"`
+	// Calls is the number of calls made.
+	Calls int64 `json:"calls"`
+	// Errors is the number of calls which failed, the throttled calls included.
+	Errors int64 `json:"errors"`
+	// Throttled is the number of calls rejected because a rate quota of the project was exceeded.
+	Throttled int64 `json:"throttled"`
+	// LastThrottled is the time the last throttled call was rejected.
+	LastThrottled *time.Time `json:"lastThrottled,omitempty"`
+	// CallSeconds is the cumulated duration of the calls.
+	CallSeconds float64 `json:"callSeconds"`
+	// Operations are the statistics of the compute operations waited for.
+	Operations wait.OperationStats `json:"operations"`
+}
+
+// Wrap is a WrapTransportFunc recording the statistics of the API calls.
+func (s *ClientStats) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &statsTransport{stats: s, base: base}
+}
+
+// Snapshot returns the statistics of the calls, per project.
+func (s *ClientStats) Snapshot() map[string]ProjectStats {
+	s.mu.Lock()
+	res := make(map[string]ProjectStats, len(s.projects))
+	for project, stats := range s.projects {
+		res[project] = *stats
+	}
+	s.mu.Unlock()
+
+	for project, ops := range wait.Stats() {
+		stats := res[project]
+		stats.Operations = ops
+		res[project] = stats
+	}
+
+	return res
+}
+
+// ServeHTTP implements http.Handler.
+func (s *ClientStats) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(s.Snapshot())
+}
+
+// start records the start of a call to the project, and returns the function recording its end.
+func (s *ClientStats) start(project string) func(resp *http.Response, err error) {
+	start := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.projects == nil {
+		s.projects = map[string]*ProjectStats{}
+	}
+	stats, ok := s.projects[project]
+	if !ok {
+		stats = &ProjectStats{}
+		s.projects[project] = stats
+	}
+	stats.InFlight++
+	stats.Calls++
+
+	return func(resp *http.Response, err error) {
+		end := time.Now()
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		stats.InFlight--
+		stats.CallSeconds += end.Sub(start).Seconds()
+		if err != nil || resp.StatusCode >= http.StatusBadRequest {
+			stats.Errors++
+		}
+		if resp != nil && resp.StatusCode == http.StatusTooManyRequests {
+			stats.Throttled++
+			stats.LastThrottled = &end
+		}
+	}
+}
+
+type statsTransport struct {
+	stats *ClientStats
+	base  http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *statsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done := t.stats.start(projectOf(req.URL.Path))
+	resp, err := t.base.RoundTrip(req)
+	done(resp, err)
+
+	return resp, err
+}
+
+// projectOf returns the project of the resource the API call is made on, e.g. my-project for
+// /compute/v1/projects/my-project/zones/us-central1-a/instances, empty if it's not in a project.
+func projectOf(path string) string {
+	parts := strings.Split(path, "/")
+	for i := 0; i < len(parts)-1; i++ {
+		if parts[i] == "projects" {
+			return parts[i+1]
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+// statusTransport responds to the requests with the status of their path.
+type statusTransport struct{}
+
+func (statusTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	status := http.StatusOK
+	switch {
+	case strings.HasSuffix(req.URL.Path, "/throttled"):
+		status = http.StatusTooManyRequests
+	case strings.HasSuffix(req.URL.Path, "/missing"):
+		status = http.StatusNotFound
+	}
+	return &http.Response{StatusCode: status, Body: http.NoBody, Request: req}, nil
+}
+
+func TestClientStats(t *testing.T) {
+	g := NewWithT(t)
+
+	stats := &cloud.ClientStats{}
+	client := &http.Client{Transport: stats.Wrap(statusTransport{})}
+
+	for _, url := range []string{
+		"https://compute.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/my-machine",
+		"https://compute.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/missing",
+		"https://compute.googleapis.com/compute/v1/projects/my-project/global/firewalls/throttled",
+		"https://compute.googleapis.com/compute/v1/projects/other-project/global/networks/default",
+	} {
+		resp, err := client.Get(url)
+		g.Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+	}
+
+	snapshot := stats.Snapshot()
+	g.Expect(snapshot).To(HaveKey("my-project"))
+	g.Expect(snapshot["my-project"].Calls).To(BeEquivalentTo(3))
+	g.Expect(snapshot["my-project"].Errors).To(BeEquivalentTo(2))
+	g.Expect(snapshot["my-project"].Throttled).To(BeEquivalentTo(1))
+	g.Expect(snapshot["my-project"].LastThrottled).NotTo(BeNil())
+	g.Expect(snapshot["my-project"].InFlight).To(BeZero())
+	g.Expect(snapshot).To(HaveKey("other-project"))
+	g.Expect(snapshot["other-project"].Calls).To(BeEquivalentTo(1))
+	g.Expect(snapshot["other-project"].Errors).To(BeZero())
+
+	rec := httptest.NewRecorder()
+	stats.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/gcp", nil))
+	g.Expect(rec.Code).To(Equal(http.StatusOK))
+	served := map[string]cloud.ProjectStats{}
+	g.Expect(json.Unmarshal(rec.Body.Bytes(), &served)).To(Succeed())
+	g.Expect(served["my-project"].Throttled).To(BeEquivalentTo(1))
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+)
+
+// readOnlyVerbs are the custom methods sent as POST requests which do not mutate resources.
+var readOnlyVerbs = map[string]bool{
+	"getHealth":              true,
+	"listInstances":          true,
+	"listManagedInstances":   true,
+	"listErrors":             true,
+	"listNetworkEndpoints":   true,
+	"listPerInstanceConfigs": true,
+}
+
+// PlannedOperation is a mutating GCP API call skipped in dry-run mode.
+type PlannedOperation struct {
+	// Verb is the operation, e.g. insert, update, patch, delete or a custom method like addInstances.
+	Verb string
+	// Resource is the path of the target resource, e.g. projects/my-project/global/networks/my-network.
+	Resource string
+}
+
+// String returns the human readable representation of the operation.
+func (o PlannedOperation) String() string {
+	return fmt.Sprintf("%s %s", o.Verb, o.Resource)
+}
+
+// DryRun records the mutating GCP API calls instead of executing them.
+//
+// Read-only calls are forwarded to the GCP APIs. Resources planned for creation
+// or update are served back from memory and planned deletions are reported as
+// not found, so that the reconcilers can carry on computing the rest of the plan.
+// A DryRun is meant to be used for a single reconcile iteration.
+type DryRun struct {
+	mu         sync.Mutex
+	base       http.RoundTripper
+	objects    map[string]map[string]interface{}
+	deleted    map[string]bool
+	operations []PlannedOperation
+}
+
+// Wrap is a WrapTransportFunc routing the API calls through the DryRun.
+func (d *DryRun) Wrap(base http.RoundTripper) http.RoundTripper {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.base = base
+	if d.objects == nil {
+		d.objects = make(map[string]map[string]interface{})
+		d.deleted = make(map[string]bool)
+	}
+
+	return d
+}
+
+// Operations returns the operations planned so far.
+func (d *DryRun) Operations() []PlannedOperation {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return append([]PlannedOperation{}, d.operations...)
+}
+
+// RoundTrip implements http.RoundTripper.
+func (d *DryRun) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := strings.Index(req.URL.Path, "/projects/")
+	if i < 0 {
+		if j := strings.Index(req.URL.Path, "/services/"); j >= 0 && req.Method != http.MethodGet {
+			return d.planServiceOperation(req, strings.Trim(req.URL.Path[j+1:], "/"))
+		}
+		if j := strings.Index(req.URL.Path, "/storage/v1/b/"); j >= 0 && req.Method != http.MethodGet && req.Method != http.MethodHead {
+			return d.planStorageOperation(req, strings.Trim(req.URL.Path[j+len("/storage/v1/"):], "/"))
+		}
+		return d.base.RoundTrip(req)
+	}
+	prefix := req.URL.Scheme + "://" + req.URL.Host + req.URL.Path[:i+1]
+	p := strings.Trim(req.URL.Path[i+1:], "/")
+	if strings.Contains(p, "/locations/") && req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return d.planContainerOperation(req, prefix, p)
+	}
+	parent, verb := path.Split(p)
+	parent = strings.TrimSuffix(parent, "/")
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		switch {
+		case d.deleted[p]:
+			return jsonResponse(req, http.StatusNotFound, map[string]interface{}{
+				"error": map[string]interface{}{
+					"code":    http.StatusNotFound,
+					"message": fmt.Sprintf("The resource '%s' was not found", p),
+					"errors":  []map[string]string{{"reason": "notFound"}},
+				},
+			})
+		case d.objects[p] != nil:
+			return jsonResponse(req, http.StatusOK, d.objects[p])
+		}
+
+		return d.base.RoundTrip(req)
+	case http.MethodPost:
+		if readOnlyVerbs[verb] {
+			return d.base.RoundTrip(req)
+		}
+		body, err := decodeBody(req)
+		if err != nil {
+			return nil, err
+		}
+		if name, ok := body["name"].(string); ok && name != "" && isInsert(p) {
+			target := p + "/" + name
+			body["selfLink"] = prefix + target
+			d.objects[target] = body
+			delete(d.deleted, target)
+
+			return d.plan(req, prefix, "insert", target)
+		}
+
+		return d.plan(req, prefix, verb, parent)
+	case http.MethodPut, http.MethodPatch:
+		body, err := decodeBody(req)
+		if err != nil {
+			return nil, err
+		}
+		obj := d.objects[p]
+		if obj == nil || req.Method == http.MethodPut {
+			obj = map[string]interface{}{"selfLink": prefix + p}
+		}
+		for k, v := range body {
+			obj[k] = v
+		}
+		d.objects[p] = obj
+
+		return d.plan(req, prefix, strings.ToLower(req.Method), p)
+	case http.MethodDelete:
+		delete(d.objects, p)
+		d.deleted[p] = true
+
+		return d.plan(req, prefix, "delete", p)
+	}
+
+	return d.base.RoundTrip(req)
+}
+
+// plan records the operation and answers with a completed compute operation.
+func (d *DryRun) plan(req *http.Request, prefix, verb, target string) (*http.Response, error) {
+	d.operations = append(d.operations, PlannedOperation{Verb: verb, Resource: target})
+
+	op := map[string]interface{}{
+		"kind":          "compute#operation",
+		"name":          fmt.Sprintf("dry-run-%d", len(d.operations)),
+		"operationType": verb,
+		"targetLink":    prefix + target,
+		"status":        "DONE",
+		"progress":      100,
+	}
+	if parts := strings.Split(target, "/"); len(parts) > 3 && (parts[2] == "zones" || parts[2] == "regions") {
+		op[strings.TrimSuffix(parts[2], "s")] = prefix + strings.Join(parts[:4], "/")
+	}
+
+	return jsonResponse(req, http.StatusOK, op)
+}
+
+// planServiceOperation records the mutation of a service networking connection, e.g.
+// services/servicenetworking.googleapis.com/connections, and answers with a done long-running operation.
+func (d *DryRun) planServiceOperation(req *http.Request, target string) (*http.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	verb := strings.ToLower(req.Method)
+	switch {
+	case strings.Contains(target, ":"):
+		i := strings.LastIndex(target, ":")
+		target, verb = target[:i], target[i+1:]
+	case req.Method == http.MethodPost && strings.Contains(target, "/connections/"):
+		// The client sends connections.deleteConnection without its custom verb.
+		verb = "deleteConnection"
+	case req.Method == http.MethodPost:
+		verb = "insert"
+	}
+	d.operations = append(d.operations, PlannedOperation{Verb: verb, Resource: target})
+
+	return jsonResponse(req, http.StatusOK, map[string]interface{}{
+		"name": fmt.Sprintf("operations/dry-run-%d", len(d.operations)),
+		"done": true,
+	})
+}
+
+// planContainerOperation records the mutation of a GKE cluster or node pool, e.g. the resize of
+// projects/my-project/locations/us-central1/clusters/my-cluster/nodePools/my-pool, and answers with a done
+// operation. The clusters and node pools planned for creation aren't served back, they are never ready.
+func (d *DryRun) planContainerOperation(req *http.Request, prefix, target string) (*http.Response, error) {
+	verb := strings.ToLower(req.Method)
+	switch {
+	case strings.Contains(path.Base(target), ":"):
+		i := strings.LastIndex(target, ":")
+		target, verb = target[:i], target[i+1:]
+	case req.Method == http.MethodPost:
+		body, err := decodeBody(req)
+		if err != nil {
+			return nil, err
+		}
+		verb = "insert"
+		for _, kind := range []string{"cluster", "nodePool"} {
+			if obj, ok := body[kind].(map[string]interface{}); ok {
+				if name, ok := obj["name"].(string); ok && name != "" {
+					target += "/" + name
+				}
+			}
+		}
+	case req.Method == http.MethodPut:
+		verb = "update"
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.operations = append(d.operations, PlannedOperation{Verb: verb, Resource: target})
+
+	return jsonResponse(req, http.StatusOK, map[string]interface{}{
+		"name":          fmt.Sprintf("dry-run-%d", len(d.operations)),
+		"operationType": verb,
+		"targetLink":    prefix + target,
+		"status":        "DONE",
+	})
+}
+
+// planStorageOperation records the mutation of a Cloud Storage object, e.g. the upload of the bootstrap data to
+// b/my-bucket/o, and answers with the object. The bodies of the uploads are media, they aren't decoded.
+func (d *DryRun) planStorageOperation(req *http.Request, target string) (*http.Response, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	verb := strings.ToLower(req.Method)
+	if req.Method == http.MethodPost {
+		verb = "insert"
+		if name := req.URL.Query().Get("name"); name != "" {
+			target += "/" + name
+		}
+	}
+	d.operations = append(d.operations, PlannedOperation{Verb: verb, Resource: target})
+
+	if req.Method == http.MethodDelete {
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", http.StatusNoContent, http.StatusText(http.StatusNoContent)),
+			StatusCode: http.StatusNoContent,
+			Proto:      "HTTP/1.1",
+			ProtoMajor: 1,
+			ProtoMinor: 1,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(bytes.NewReader(nil)),
+			Request:    req,
+		}, nil
+	}
+	parts := strings.Split(target, "/")
+
+	return jsonResponse(req, http.StatusOK, map[string]interface{}{
+		"kind":   "storage#object",
+		"bucket": parts[1],
+	})
+}
+
+func decodeBody(req *http.Request) (map[string]interface{}, error) {
+	body := map[string]interface{}{}
+	if req.Body == nil {
+		return body, nil
+	}
+	defer req.Body.Close()
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil || len(data) == 0 {
+		return body, err
+	}
+
+	return body, json.Unmarshal(data, &body)
+}
+
+// copyBody reads the body of the request. A RoundTripper must not modify the request, it returns a
+// copy of the request to send, with the body.
+func copyBody(req *http.Request) (*http.Request, []byte, error) {
+	if req.Body == nil {
+		return req, nil, nil
+	}
+	data, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = ioutil.NopCloser(bytes.NewReader(data))
+	req.GetBody = func() (io.ReadCloser, error) { return ioutil.NopCloser(bytes.NewReader(data)), nil }
+
+	return req, data, nil
+}
+
+func jsonResponse(req *http.Request, code int, obj interface{}) (*http.Response, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+		StatusCode:    code,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Request:       req,
+	}, nil
+}
+
+// isInsert reports whether a POST request to the path creates a resource, i.e. whether
+// the path refers to a global, regional or zonal collection rather than a custom method.
+func isInsert(p string) bool {
+	parts := strings.Split(p, "/")
+	if len(parts) < 3 {
+		return false
+	}
+	switch parts[2] {
+	case "global":
+		return len(parts) == 4
+	case "regions", "zones":
+		return len(parts) == 5
+	}
+
+	return false
+}
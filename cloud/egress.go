@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// TrustCABundle makes the clients of the GCP APIs trust the CA certificates of the PEM bundle, besides the
+// certificates of the system, e.g. the CA of a TLS-inspecting proxy. It must be called before any client is
+// created.
+//
+// The clients of the GCP APIs, and of the token endpoint of the credentials, are based on http.DefaultTransport,
+// which is the one configured. Its proxy is already set from the HTTPS_PROXY and NO_PROXY environment variables.
+// The metadata server is never reached through the proxy.
+func TrustCABundle(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to read the CA bundle")
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return errors.Errorf("no PEM certificate found in the CA bundle %s", path)
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return errors.Errorf("unexpected default transport %T", http.DefaultTransport)
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	transport.TLSClientConfig.RootCAs = pool
+
+	return nil
+}
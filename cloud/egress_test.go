@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestTrustCABundle(t *testing.T) {
+	g := NewWithT(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	transport := http.DefaultTransport.(*http.Transport)
+	defer func(tlsConfig *tls.Config) {
+		transport.TLSClientConfig = tlsConfig
+	}(transport.TLSClientConfig)
+
+	client := &http.Client{Transport: transport.Clone()}
+	_, err := client.Get(server.URL)
+	g.Expect(err).To(HaveOccurred())
+
+	dir, err := ioutil.TempDir("", "ca")
+	g.Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	bundle := filepath.Join(dir, "ca.pem")
+	g.Expect(ioutil.WriteFile(bundle, []byte("not a certificate"), 0600)).To(Succeed())
+	g.Expect(cloud.TrustCABundle(bundle)).NotTo(Succeed())
+
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	g.Expect(ioutil.WriteFile(bundle, data, 0600)).To(Succeed())
+	g.Expect(cloud.TrustCABundle(bundle)).To(Succeed())
+
+	// The clients created from the default transport trust the bundle.
+	client = &http.Client{Transport: transport.Clone()}
+	resp, err := client.Get(server.URL)
+	g.Expect(err).NotTo(HaveOccurred())
+	resp.Body.Close()
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ComputeGlobalHost is the host of the global service endpoint of the compute API.
+const ComputeGlobalHost = "compute.googleapis.com"
+
+// RegionalEndpoint routes the compute API calls on the resources of a region, and of its zones, to the
+// regional service endpoint of the region. The calls on the global resources, e.g. the backend services
+// and firewall rules, which the regional endpoints don't serve, keep going to the global endpoint.
+type RegionalEndpoint struct {
+	// Region is the region of the resources.
+	Region string
+}
+
+// Host returns the host of the regional service endpoint of the compute API.
+func (e *RegionalEndpoint) Host() string {
+	return fmt.Sprintf("compute.%s.rep.googleapis.com", e.Region)
+}
+
+// Wrap is a WrapTransportFunc routing the API calls to the regional endpoint.
+func (e *RegionalEndpoint) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &regionalTransport{endpoint: *e, base: base}
+}
+
+type regionalTransport struct {
+	endpoint RegionalEndpoint
+	base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *regionalTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The calls to a custom endpoint, e.g. a private service connect endpoint, are left alone.
+	if req.URL.Host != ComputeGlobalHost || !t.regional(req.URL.Path) {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request.
+	req = req.Clone(req.Context())
+	req.URL.Host = t.endpoint.Host()
+	req.Host = ""
+
+	return t.base.RoundTrip(req)
+}
+
+// regional returns true if the path targets a resource of the region or of one of its zones.
+func (t *regionalTransport) regional(path string) bool {
+	return strings.Contains(path, "/regions/"+t.endpoint.Region+"/") || strings.HasSuffix(path, "/regions/"+t.endpoint.Region) ||
+		strings.Contains(path, "/zones/"+t.endpoint.Region+"-")
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+// recordingTransport records the hosts of the requests it receives.
+type recordingTransport struct {
+	hosts []string
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.hosts = append(t.hosts, req.URL.Host)
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestRegionalEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	base := &recordingTransport{}
+	endpoint := &cloud.RegionalEndpoint{Region: "us-central1"}
+	client := &http.Client{Transport: endpoint.Wrap(base)}
+
+	for _, url := range []string{
+		"https://compute.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/my-machine",
+		"https://compute.googleapis.com/compute/v1/projects/my-project/regions/us-central1/forwardingRules",
+		"https://compute.googleapis.com/compute/v1/projects/my-project/regions/us-central1",
+		"https://compute.googleapis.com/compute/v1/projects/my-project/zones/us-central10-a/instances/my-machine",
+		"https://compute.googleapis.com/compute/v1/projects/my-project/global/backendServices/my-cluster-apiserver",
+		"https://my-endpoint.p.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/my-machine",
+	} {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		g.Expect(err).NotTo(HaveOccurred())
+		resp, err := client.Do(req)
+		g.Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		g.Expect(req.URL.Host).NotTo(Equal(endpoint.Host()))
+	}
+
+	g.Expect(base.hosts).To(Equal([]string{
+		"compute.us-central1.rep.googleapis.com",
+		"compute.us-central1.rep.googleapis.com",
+		"compute.us-central1.rep.googleapis.com",
+		cloud.ComputeGlobalHost,
+		cloud.ComputeGlobalHost,
+		"my-endpoint.p.googleapis.com",
+	}))
+}
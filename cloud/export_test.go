@@ -0,0 +1,66 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/api/option"
+)
+
+// SetClock overrides the clock of the HealthChecker in tests.
+func (h *HealthChecker) SetClock(now func() time.Time) {
+	h.now = now
+}
+
+// SetClock overrides the clock of the ZoneIncidents in tests.
+func (z *ZoneIncidents) SetClock(now func() time.Time) {
+	z.now = now
+}
+
+// SetExporterClock overrides the clock of a MetricsExporter returned by NewMonitoringExporter in tests.
+func SetExporterClock(e MetricsExporter, now func() time.Time) {
+	e.(*monitoringExporter).now = now
+}
+
+// CheckPermissions verifies the credentials of the client options have the RequiredPermissions on the project.
+func CheckPermissions(ctx context.Context, project string, opts ...option.ClientOption) error {
+	return checkPermissions(ctx, project, opts...)
+}
+
+// SetLookupIPAddr overrides the resolution of the VIPs of the Google access in tests.
+func SetLookupIPAddr(lookup func(ctx context.Context, host string) ([]net.IPAddr, error)) {
+	lookupIPAddr = lookup
+}
+
+// DialVIP returns the dial function routing the GCP APIs to the VIP host.
+func DialVIP(host string, dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return dialVIP(host, dial)
+}
+
+// SetSleep overrides the wait of the RateLimiter before retrying the throttled calls in tests.
+func (r *RateLimiter) SetSleep(sleep func(ctx context.Context, d time.Duration) error) {
+	r.sleep = sleep
+}
+
+// APIMethod returns the service and the operation of the API call, as reported in the metrics.
+func APIMethod(req *http.Request) (string, string) {
+	return apiMethod(req)
+}
@@ -0,0 +1,524 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// defaultObject fills the output only fields GCP sets when a resource is inserted in a collection.
+func defaultObject(c *Cloud, collection string, obj map[string]interface{}) {
+	switch collection {
+	case "instances":
+		obj["status"] = "RUNNING"
+		if nics, ok := obj["networkInterfaces"].([]interface{}); ok {
+			for i, nic := range nics {
+				if nic, ok := nic.(map[string]interface{}); ok {
+					nic["networkIP"] = fmt.Sprintf("10.0.%d.%d", i, c.counter%250+2)
+					if acs, ok := nic["accessConfigs"].([]interface{}); ok {
+						for _, ac := range acs {
+							if ac, ok := ac.(map[string]interface{}); ok {
+								ac["natIP"] = fmt.Sprintf("203.0.113.%d", c.counter%250+2)
+							}
+						}
+					}
+				}
+			}
+		}
+	case "addresses", "globalAddresses":
+		if _, ok := obj["address"]; !ok && obj["ipVersion"] == "IPV6" {
+			obj["address"] = fmt.Sprintf("2001:db8::%x", c.counter)
+		} else if !ok {
+			obj["address"] = fmt.Sprintf("198.51.100.%d", c.counter%250+2)
+		}
+		obj["status"] = "RESERVED"
+	case "subnetworks":
+		// The IPv6 range of a dual-stack subnetwork is allocated by GCP, external unless internal is requested.
+		if obj["stackType"] == "IPV4_IPV6" && obj["ipv6AccessType"] == "INTERNAL" {
+			obj["ipv6CidrRange"] = fmt.Sprintf("fd20:0:0:%x::/64", c.counter)
+		} else if obj["stackType"] == "IPV4_IPV6" {
+			obj["externalIpv6Prefix"] = fmt.Sprintf("2600:1900:4000:%x::/64", c.counter)
+		}
+	case "instanceGroups", "networkEndpointGroups":
+		obj["size"] = 0
+	case "instanceGroupManagers":
+		// The instances are created at once, the group is stable on insert.
+		obj["status"] = map[string]interface{}{
+			"isStable":      true,
+			"versionTarget": map[string]interface{}{"isReached": true},
+		}
+	case "nodeGroups":
+		// The initial size is a query parameter of the insert.
+		size, _ := obj["initialNodeCount"].(string)
+		delete(obj, "initialNodeCount")
+		n, _ := strconv.Atoi(size)
+		obj["nodes"] = []interface{}{}
+		addNodes(obj, n)
+	}
+}
+
+// defaultVerbs returns the custom methods implemented by the in-memory cloud.
+func defaultVerbs() map[string]VerbFunc {
+	return map[string]VerbFunc{
+		"addInstances": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			members, _ := obj["members"].([]interface{})
+			for _, i := range refs(req["instances"]) {
+				members = appendUnique(members, i)
+			}
+			obj["members"] = members
+			obj["size"] = len(members)
+			return nil, nil
+		},
+		"removeInstances": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			members, _ := obj["members"].([]interface{})
+			for _, i := range refs(req["instances"]) {
+				members = remove(members, i)
+			}
+			obj["members"] = members
+			obj["size"] = len(members)
+			return nil, nil
+		},
+		"addInstance": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			// The target pools list the self links of their instances.
+			instances, _ := obj["instances"].([]interface{})
+			for _, i := range refs(req["instances"]) {
+				instances = appendUnique(instances, i)
+			}
+			obj["instances"] = instances
+			return nil, nil
+		},
+		"removeInstance": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			instances, _ := obj["instances"].([]interface{})
+			for _, i := range refs(req["instances"]) {
+				instances = remove(instances, i)
+			}
+			obj["instances"] = instances
+			return nil, nil
+		},
+		"addRule": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			// The rules of the firewall policies are identified by their priority.
+			rules, _ := obj["rules"].([]interface{})
+			if ruleIndex(rules, req["priority"]) >= 0 {
+				return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: fmt.Sprintf("A rule with priority %v already exists", req["priority"])}
+			}
+			obj["rules"] = append(rules, req)
+			return nil, nil
+		},
+		"patchRule": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			rules, _ := obj["rules"].([]interface{})
+			i := ruleIndex(rules, req["priority"])
+			if i < 0 {
+				return nil, notFound(fmt.Sprintf("rule %v", req["priority"]))
+			}
+			rules[i] = req
+			return nil, nil
+		},
+		"removeRule": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			rules, _ := obj["rules"].([]interface{})
+			i := ruleIndex(rules, req["priority"])
+			if i < 0 {
+				return nil, notFound(fmt.Sprintf("rule %v", req["priority"]))
+			}
+			obj["rules"] = append(rules[:i], rules[i+1:]...)
+			return nil, nil
+		},
+		"addAssociation": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			associations, _ := obj["associations"].([]interface{})
+			for _, x := range associations {
+				if x, ok := x.(map[string]interface{}); ok && x["name"] == req["name"] {
+					return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: fmt.Sprintf("An association with name %v already exists", req["name"])}
+				}
+			}
+			obj["associations"] = append(associations, req)
+			return nil, nil
+		},
+		"removeAssociation": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			associations, _ := obj["associations"].([]interface{})
+			for i, x := range associations {
+				if x, ok := x.(map[string]interface{}); ok && x["name"] == req["name"] {
+					obj["associations"] = append(associations[:i], associations[i+1:]...)
+					return nil, nil
+				}
+			}
+			return nil, notFound(fmt.Sprintf("association %v", req["name"]))
+		},
+		"listInstances": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			members, _ := obj["members"].([]interface{})
+			items := make([]interface{}, 0, len(members))
+			for _, m := range members {
+				items = append(items, map[string]interface{}{"instance": m, "status": "RUNNING"})
+			}
+			return map[string]interface{}{"items": items}, nil
+		},
+		"attachNetworkEndpoints": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			endpoints, _ := obj["endpoints"].([]interface{})
+			added, _ := req["networkEndpoints"].([]interface{})
+			for _, e := range added {
+				if e, ok := e.(map[string]interface{}); ok && endpointIndex(endpoints, e) < 0 {
+					endpoints = append(endpoints, e)
+				}
+			}
+			obj["endpoints"] = endpoints
+			obj["size"] = len(endpoints)
+			return nil, nil
+		},
+		"detachNetworkEndpoints": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			endpoints, _ := obj["endpoints"].([]interface{})
+			removed, _ := req["networkEndpoints"].([]interface{})
+			for _, e := range removed {
+				if e, ok := e.(map[string]interface{}); ok {
+					if i := endpointIndex(endpoints, e); i >= 0 {
+						endpoints = append(endpoints[:i], endpoints[i+1:]...)
+					}
+				}
+			}
+			obj["endpoints"] = endpoints
+			obj["size"] = len(endpoints)
+			return nil, nil
+		},
+		"listNetworkEndpoints": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			endpoints, _ := obj["endpoints"].([]interface{})
+			items := make([]interface{}, 0, len(endpoints))
+			for _, e := range endpoints {
+				items = append(items, map[string]interface{}{"networkEndpoint": e})
+			}
+			return map[string]interface{}{"items": items}, nil
+		},
+		"changes": applyDNSChange,
+		"rrsets":  listResourceRecordSets,
+		"setPrivateIpGoogleAccess": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["privateIpGoogleAccess"] = req["privateIpGoogleAccess"]
+			return nil, nil
+		},
+		"setLabels": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			if err := checkFingerprint(obj["labelFingerprint"], req["labelFingerprint"]); err != nil {
+				return nil, err
+			}
+			obj["labels"] = req["labels"]
+			obj["labelFingerprint"] = fmt.Sprintf("%d", c.counter)
+			return nil, nil
+		},
+		"setTags": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			tags, _ := obj["tags"].(map[string]interface{})
+			if err := checkFingerprint(tags["fingerprint"], req["fingerprint"]); err != nil {
+				return nil, err
+			}
+			req["fingerprint"] = fmt.Sprintf("%d", c.counter)
+			obj["tags"] = req
+			return nil, nil
+		},
+		"setMetadata": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			metadata, _ := obj["metadata"].(map[string]interface{})
+			if err := checkFingerprint(metadata["fingerprint"], req["fingerprint"]); err != nil {
+				return nil, err
+			}
+			req["fingerprint"] = fmt.Sprintf("%d", c.counter)
+			obj["metadata"] = req
+			return nil, nil
+		},
+		"setMachineType": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["machineType"] = req["machineType"]
+			return nil, nil
+		},
+		"setTarget": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["target"] = req["target"]
+			return nil, nil
+		},
+		"setBackendService": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["service"] = req["service"]
+			return nil, nil
+		},
+		"setSecurityPolicy": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["securityPolicy"] = req["securityPolicy"]
+			return nil, nil
+		},
+		"setProxyHeader": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["proxyHeader"] = req["proxyHeader"]
+			return nil, nil
+		},
+		"getHealth": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			// The instances of the group are healthy unless their "health" field says otherwise.
+			group, _ := req["group"].(string)
+			members, _ := c.objects[c.path(group)]["members"].([]interface{})
+			// The endpoints of a network endpoint group reference their instance by name, in the zone of the group.
+			endpoints, _ := c.objects[c.path(group)]["endpoints"].([]interface{})
+			for _, e := range endpoints {
+				if e, ok := e.(map[string]interface{}); ok {
+					zone := path.Dir(path.Dir(c.path(group)))
+					members = append(members, c.SelfLink(path.Join(zone, "instances", fmt.Sprint(e["instance"]))))
+				}
+			}
+			states := make([]interface{}, 0, len(members))
+			for _, m := range members {
+				link, _ := m.(string)
+				state := "HEALTHY"
+				if health, ok := c.objects[c.path(link)]["health"].(string); ok {
+					state = health
+				}
+				states = append(states, map[string]interface{}{"instance": m, "healthState": state})
+			}
+			return map[string]interface{}{"healthStatus": states}, nil
+		},
+		"getGuestAttributes": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			key, _ := req["variableKey"].(string)
+			attrs, _ := obj["guestAttributes"].(map[string]interface{})
+			value, ok := attrs[key]
+			if !ok {
+				return nil, notFound(key)
+			}
+			return map[string]interface{}{"variableKey": key, "variableValue": value}, nil
+		},
+		"serialPort": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			output, _ := obj["serialPortOutput"].(string)
+			return map[string]interface{}{"contents": output}, nil
+		},
+		"resize": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			if sku, ok := obj["specificReservation"].(map[string]interface{}); ok {
+				sku["count"] = req["specificSkuCount"]
+			}
+			if size, ok := req["sizeGb"]; ok {
+				obj["sizeGb"] = size
+			}
+			if size, ok := req["size"].(string); ok {
+				obj["targetSize"], _ = strconv.Atoi(size)
+			}
+			return nil, nil
+		},
+		"addNodes": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			n, _ := strconv.Atoi(fmt.Sprint(req["additionalNodeCount"]))
+			addNodes(obj, n)
+			return nil, nil
+		},
+		"deleteNodes": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			nodes, _ := obj["nodes"].([]interface{})
+			names, _ := req["nodes"].([]interface{})
+			for _, name := range names {
+				for i, node := range nodes {
+					if node.(map[string]interface{})["name"] == name {
+						nodes = append(nodes[:i], nodes[i+1:]...)
+						break
+					}
+				}
+			}
+			obj["nodes"] = nodes
+			obj["size"] = len(nodes)
+			return nil, nil
+		},
+		"listManagedInstances": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			// The instances of the regional instance group managers are spread across the zones of their
+			// distribution policy, all of them created with the current template.
+			zones := []string{c.path(fmt.Sprint(obj["zone"]))}
+			if policy, ok := obj["distributionPolicy"].(map[string]interface{}); ok {
+				if items, ok := policy["zones"].([]interface{}); ok && len(items) > 0 {
+					zones = zones[:0]
+					for _, item := range items {
+						item, _ := item.(map[string]interface{})
+						zones = append(zones, c.path(fmt.Sprint(item["zone"])))
+					}
+				}
+			}
+			var instances []interface{}
+			for _, i := range managedInstances(obj) {
+				i := i.(map[string]interface{})
+				index, _ := strconv.Atoi(fmt.Sprint(i["index"]))
+				instances = append(instances, map[string]interface{}{
+					"instance":       c.SelfLink(path.Join(zones[index%len(zones)], "instances", fmt.Sprint(i["name"]))),
+					"instanceStatus": i["status"],
+					"version":        map[string]interface{}{"instanceTemplate": obj["instanceTemplate"]},
+				})
+			}
+			return map[string]interface{}{"managedInstances": instances}, nil
+		},
+		"deleteInstances": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			// The target size of the instance group managers is decreased with the instances deleted.
+			all := managedInstances(obj)
+			instances := all[:0]
+			for _, i := range all {
+				if !containsInstance(req["instances"], i) {
+					instances = append(instances, i)
+				}
+			}
+			obj["managedInstances"], obj["targetSize"] = instances, len(instances)
+			return nil, nil
+		},
+		"stopInstances":    setInstancesStatus("TERMINATED"),
+		"startInstances":   setInstancesStatus("RUNNING"),
+		"suspendInstances": setInstancesStatus("SUSPENDED"),
+		"resumeInstances":  setInstancesStatus("RUNNING"),
+		"listNodes": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			return map[string]interface{}{"items": obj["nodes"]}, nil
+		},
+		"getRouterStatus": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			// The auto allocated IPs of the nat gateways are derived from their position in the router.
+			nats, _ := obj["nats"].([]interface{})
+			statuses := make([]interface{}, 0, len(nats))
+			for i, nat := range nats {
+				nat, _ := nat.(map[string]interface{})
+				status := map[string]interface{}{"name": nat["name"]}
+				if nat["natIpAllocateOption"] == "AUTO_ONLY" {
+					status["autoAllocatedNatIps"] = []interface{}{fmt.Sprintf("192.0.2.%d", i+1)}
+				}
+				statuses = append(statuses, status)
+			}
+			return map[string]interface{}{"result": map[string]interface{}{"natStatus": statuses}}, nil
+		},
+		"start": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["status"] = "RUNNING"
+			return nil, nil
+		},
+		"stop": func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+			obj["status"] = "TERMINATED"
+			return nil, nil
+		},
+	}
+}
+
+// checkFingerprint rejects the update of a resource, or of one of its fields, with a fingerprint other than
+// the current one. Like GCP, the updates without a fingerprint are applied unconditionally.
+func checkFingerprint(current, requested interface{}) error {
+	if requested == nil || requested == "" {
+		return nil
+	}
+	if current == nil {
+		current = ""
+	}
+	if current != requested {
+		return &googleapi.Error{
+			Code:    http.StatusPreconditionFailed,
+			Message: fmt.Sprintf("Supplied fingerprint %v does not match the current fingerprint %v", requested, current),
+			Errors:  []googleapi.ErrorItem{{Reason: "conditionNotMet"}},
+		}
+	}
+
+	return nil
+}
+
+// refs returns the "instance" fields of a list of references.
+func refs(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+	res := make([]interface{}, 0, len(list))
+	for _, r := range list {
+		if r, ok := r.(map[string]interface{}); ok {
+			res = append(res, r["instance"])
+		}
+	}
+
+	return res
+}
+
+// endpointIndex returns the index of the network endpoint of the instance in the list, or -1.
+func endpointIndex(endpoints []interface{}, e map[string]interface{}) int {
+	for i, x := range endpoints {
+		if x, ok := x.(map[string]interface{}); ok && x["instance"] == e["instance"] {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// ruleIndex returns the index of the rule of the firewall policy with the priority in the list, or -1.
+func ruleIndex(rules []interface{}, priority interface{}) int {
+	for i, x := range rules {
+		if x, ok := x.(map[string]interface{}); ok && fmt.Sprint(x["priority"]) == fmt.Sprint(priority) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func appendUnique(list []interface{}, v interface{}) []interface{} {
+	for _, x := range list {
+		if x == v {
+			return list
+		}
+	}
+
+	return append(list, v)
+}
+
+func remove(list []interface{}, v interface{}) []interface{} {
+	res := list[:0]
+	for _, x := range list {
+		if x != v {
+			res = append(res, x)
+		}
+	}
+
+	return res
+}
+
+// addNodes adds n nodes to the node group.
+func addNodes(obj map[string]interface{}, n int) {
+	nodes, _ := obj["nodes"].([]interface{})
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, map[string]interface{}{"name": fmt.Sprintf("%s-node-%d", obj["name"], len(nodes))})
+	}
+	obj["nodes"] = nodes
+	obj["size"] = len(nodes)
+}
+
+// managedInstances returns the instances of the instance group manager, the ones created or deleted since its target
+// size changed being added or removed last.
+func managedInstances(obj map[string]interface{}) []interface{} {
+	instances, _ := obj["managedInstances"].([]interface{})
+	size, _ := strconv.Atoi(fmt.Sprint(obj["targetSize"]))
+	next, _ := strconv.Atoi(fmt.Sprint(obj["nextInstance"]))
+	for len(instances) < size {
+		instances = append(instances, map[string]interface{}{
+			"name":   fmt.Sprintf("%s-%d", strings.TrimSuffix(fmt.Sprint(obj["name"]), "-grp"), next),
+			"index":  next,
+			"status": "RUNNING",
+		})
+		next++
+	}
+	obj["managedInstances"], obj["nextInstance"] = instances[:size], next
+
+	return instances[:size]
+}
+
+// setInstancesStatus returns the custom method setting the status of the instances of an instance group manager,
+// e.g. when they are stopped.
+func setInstancesStatus(status string) VerbFunc {
+	return func(c *Cloud, obj, req map[string]interface{}) (interface{}, error) {
+		for _, i := range managedInstances(obj) {
+			if containsInstance(req["instances"], i) {
+				i.(map[string]interface{})["status"] = status
+			}
+		}
+		return nil, nil
+	}
+}
+
+// containsInstance returns true if the instance of an instance group manager is one of the instance URLs.
+func containsInstance(urls interface{}, instance interface{}) bool {
+	list, _ := urls.([]interface{})
+	for _, url := range list {
+		if path.Base(fmt.Sprint(url)) == instance.(map[string]interface{})["name"] {
+			return true
+		}
+	}
+
+	return false
+}
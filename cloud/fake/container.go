@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// containerEndpoint is the endpoint of the GKE API client, whose paths include the version of the API.
+const containerEndpoint = "/container/"
+
+// containerBasePath is the base path of the GKE API, whose objects are stored like the compute objects,
+// e.g. "projects/my-project/locations/us-central1/clusters/my-cluster".
+const containerBasePath = containerEndpoint + "v1/"
+
+// DefaultGKEVersion is the version of the GKE clusters created without an initial version.
+const DefaultGKEVersion = "1.21.5-gke.1302"
+
+// GKEEndpoint is the endpoint of the GKE clusters.
+const GKEEndpoint = "203.0.113.10"
+
+// GKECACertificate is the CA certificate of the GKE clusters.
+const GKECACertificate = "fake-ca"
+
+// serveContainer serves the clusters and node pools of the GKE API, their operations complete immediately.
+// The clusters and node pools are running once created. The instance group managers of the node pools are
+// stored with the compute objects, one per zone of the node pool.
+func (c *Cloud) serveContainer(w http.ResponseWriter, r *http.Request) {
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, containerBasePath), "/")
+
+	var body map[string]interface{}
+	if r.Body != nil && r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err.Error() != "EOF" {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err, ok := c.errors[r.Method+" "+p]; ok {
+		writeError(w, err)
+		return
+	}
+	res, err := c.handleContainer(r.Method, p, body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (c *Cloud) handleContainer(method, p string, body map[string]interface{}) (interface{}, *googleapi.Error) {
+	verb := ""
+	if i := strings.LastIndex(p, ":"); i >= 0 {
+		p, verb = p[:i], p[i+1:]
+	}
+
+	switch {
+	case method == http.MethodGet && strings.Contains(p, "/operations/"):
+		return map[string]interface{}{"name": path.Base(p), "status": "DONE"}, nil
+	case method == http.MethodGet:
+		obj, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		if path.Base(path.Dir(p)) != "clusters" {
+			return obj, nil
+		}
+		cluster := make(map[string]interface{}, len(obj)+1)
+		for k, v := range obj {
+			cluster[k] = v
+		}
+		nodePools := []interface{}{}
+		for _, np := range c.list(p + "/nodePools") {
+			nodePools = append(nodePools, c.objects[np])
+		}
+		cluster["nodePools"] = nodePools
+		return cluster, nil
+	case method == http.MethodPost && strings.HasSuffix(p, "/clusters"):
+		cluster, _ := body["cluster"].(map[string]interface{})
+		name, _ := cluster["name"].(string)
+		target := p + "/" + name
+		if err := c.checkNotExists(target); err != nil {
+			return nil, err
+		}
+		nodePools, _ := cluster["nodePools"].([]interface{})
+		delete(cluster, "nodePools")
+		location := path.Base(path.Dir(p))
+		if _, ok := cluster["locations"]; !ok {
+			cluster["locations"] = c.locationZones(path.Dir(path.Dir(path.Dir(p))), location)
+		}
+		cluster["location"] = location
+		cluster["status"] = "RUNNING"
+		cluster["endpoint"] = GKEEndpoint
+		cluster["masterAuth"] = map[string]interface{}{"clusterCaCertificate": base64.StdEncoding.EncodeToString([]byte(GKECACertificate))}
+		cluster["currentMasterVersion"] = DefaultGKEVersion
+		if v, ok := cluster["initialClusterVersion"].(string); ok && v != "" {
+			cluster["currentMasterVersion"] = v
+		}
+		c.objects[target] = cluster
+		for _, np := range nodePools {
+			if np, ok := np.(map[string]interface{}); ok {
+				c.createNodePool(target, np)
+			}
+		}
+		return c.containerOperation("CREATE_CLUSTER", target), nil
+	case method == http.MethodPut && path.Base(path.Dir(p)) == "clusters":
+		cluster, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		update, _ := body["update"].(map[string]interface{})
+		if v, ok := update["desiredMasterVersion"].(string); ok && v != "" {
+			cluster["currentMasterVersion"] = v
+		}
+		if v, ok := update["desiredClusterAutoscaling"]; ok {
+			cluster["autoscaling"] = v
+		}
+		return c.containerOperation("UPDATE_CLUSTER", p), nil
+	case method == http.MethodPost && verb == "setResourceLabels":
+		cluster, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		cluster["resourceLabels"] = body["resourceLabels"]
+		return c.containerOperation("SET_LABELS", p), nil
+	case method == http.MethodDelete && path.Base(path.Dir(p)) == "clusters":
+		if _, ok := c.objects[p]; !ok {
+			return nil, notFound(p)
+		}
+		for _, np := range c.list(p + "/nodePools") {
+			c.deleteNodePool(np)
+		}
+		delete(c.objects, p)
+		return c.containerOperation("DELETE_CLUSTER", p), nil
+	case method == http.MethodPost && strings.HasSuffix(p, "/nodePools"):
+		if _, ok := c.objects[path.Dir(p)]; !ok {
+			return nil, notFound(path.Dir(p))
+		}
+		np, _ := body["nodePool"].(map[string]interface{})
+		name, _ := np["name"].(string)
+		if err := c.checkNotExists(p + "/" + name); err != nil {
+			return nil, err
+		}
+		c.createNodePool(path.Dir(p), np)
+		return c.containerOperation("CREATE_NODE_POOL", p+"/"+name), nil
+	case method == http.MethodPost && verb == "setSize":
+		np, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		urls, _ := np["instanceGroupUrls"].([]interface{})
+		for _, u := range urls {
+			if igm, ok := c.objects[c.path(fmt.Sprint(u))]; ok {
+				igm["targetSize"] = body["nodeCount"]
+			}
+		}
+		return c.containerOperation("SET_NODE_POOL_SIZE", p), nil
+	case method == http.MethodPut && path.Base(path.Dir(p)) == "nodePools":
+		np, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		if v, ok := body["nodeVersion"].(string); ok && v != "" {
+			np["version"] = v
+		}
+		return c.containerOperation("UPGRADE_NODES", p), nil
+	case method == http.MethodPost && verb == "setAutoscaling":
+		np, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		np["autoscaling"] = body["autoscaling"]
+		return c.containerOperation("SET_NODE_POOL_MANAGEMENT", p), nil
+	case method == http.MethodDelete && path.Base(path.Dir(p)) == "nodePools":
+		if _, ok := c.objects[p]; !ok {
+			return nil, notFound(p)
+		}
+		c.deleteNodePool(p)
+		return c.containerOperation("DELETE_NODE_POOL", p), nil
+	}
+
+	return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "unknown method " + method + " " + p}
+}
+
+func (c *Cloud) checkNotExists(p string) *googleapi.Error {
+	if _, ok := c.objects[p]; ok {
+		return &googleapi.Error{
+			Code:    http.StatusConflict,
+			Message: fmt.Sprintf("Already exists: %s", p),
+			Errors:  []googleapi.ErrorItem{{Reason: "alreadyExists"}},
+		}
+	}
+
+	return nil
+}
+
+// locationZones returns the zones of a GKE cluster in the location: the zone itself, or the zones seeded for the
+// region, three zones by default.
+func (c *Cloud) locationZones(project, location string) []interface{} {
+	if strings.Count(location, "-") == 2 {
+		return []interface{}{location}
+	}
+	zones := []interface{}{}
+	if region, ok := c.objects[path.Join(project, "regions", location)]; ok {
+		links, _ := region["zones"].([]interface{})
+		for _, link := range links {
+			zones = append(zones, path.Base(fmt.Sprint(link)))
+		}
+	}
+	if len(zones) == 0 {
+		zones = []interface{}{location + "-a", location + "-b", location + "-c"}
+	}
+
+	return zones
+}
+
+// createNodePool stores the node pool of the cluster and creates its instance group managers, sized with the
+// initial node count of the node pool.
+func (c *Cloud) createNodePool(clusterPath string, np map[string]interface{}) {
+	cluster := c.objects[clusterPath]
+	project := path.Dir(path.Dir(path.Dir(path.Dir(clusterPath))))
+	locations, ok := np["locations"].([]interface{})
+	if !ok {
+		locations, _ = cluster["locations"].([]interface{})
+		np["locations"] = locations
+	}
+	size, _ := strconv.Atoi(fmt.Sprint(np["initialNodeCount"]))
+	urls := make([]interface{}, 0, len(locations))
+	for _, zone := range locations {
+		name := fmt.Sprintf("gke-%s-%s-grp", path.Base(clusterPath), np["name"])
+		igm := path.Join(project, "zones", fmt.Sprint(zone), "instanceGroupManagers", name)
+		c.store(igm, map[string]interface{}{
+			"name":       name,
+			"zone":       c.SelfLink(path.Join(project, "zones", fmt.Sprint(zone))),
+			"targetSize": size,
+		})
+		urls = append(urls, c.SelfLink(igm))
+	}
+	np["instanceGroupUrls"] = urls
+	np["status"] = "RUNNING"
+	np["version"] = cluster["currentMasterVersion"]
+	c.objects[clusterPath+"/nodePools/"+fmt.Sprint(np["name"])] = np
+}
+
+// deleteNodePool deletes the node pool and its instance group managers.
+func (c *Cloud) deleteNodePool(p string) {
+	urls, _ := c.objects[p]["instanceGroupUrls"].([]interface{})
+	for _, u := range urls {
+		delete(c.objects, c.path(fmt.Sprint(u)))
+	}
+	delete(c.objects, p)
+}
+
+func (c *Cloud) containerOperation(opType, target string) map[string]interface{} {
+	c.counter++
+
+	return map[string]interface{}{
+		"name":          fmt.Sprintf("operation-%d", c.counter),
+		"operationType": opType,
+		"status":        "DONE",
+		"targetLink":    target,
+	}
+}
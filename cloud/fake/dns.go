@@ -0,0 +1,96 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/api/googleapi"
+)
+
+// The record sets of a managed zone are stored in the zone, they are only changed through the changes of the zone,
+// e.g. projects/my-project/managedZones/my-zone/changes, and listed with projects/my-project/managedZones/my-zone/rrsets.
+
+// applyDNSChange applies the deletions then the additions of a change to the record sets of the zone. Like Cloud DNS,
+// the deleted record sets must match the existing ones and the added ones must not exist, the change is done at once.
+func applyDNSChange(c *Cloud, zone, req map[string]interface{}) (interface{}, error) {
+	rrsets, _ := zone["rrsets"].([]interface{})
+	deletions, _ := req["deletions"].([]interface{})
+	for _, d := range deletions {
+		i := rrsetIndex(rrsets, d)
+		if i < 0 || fmt.Sprint(rrsets[i]) != fmt.Sprint(d) {
+			return nil, &googleapi.Error{
+				Code:    http.StatusPreconditionFailed,
+				Message: fmt.Sprintf("The resource record set '%v' doesn't match the existing one", d),
+				Errors:  []googleapi.ErrorItem{{Reason: "conditionNotMet"}},
+			}
+		}
+		rrsets = append(rrsets[:i], rrsets[i+1:]...)
+	}
+	additions, _ := req["additions"].([]interface{})
+	for _, a := range additions {
+		if rrsetIndex(rrsets, a) >= 0 {
+			return nil, &googleapi.Error{
+				Code:    http.StatusConflict,
+				Message: fmt.Sprintf("The resource record set '%v' already exists", a),
+				Errors:  []googleapi.ErrorItem{{Reason: "alreadyExists"}},
+			}
+		}
+		rrsets = append(rrsets, a)
+	}
+	zone["rrsets"] = rrsets
+
+	c.counter++
+	return map[string]interface{}{
+		"kind":      "dns#change",
+		"id":        fmt.Sprintf("%d", c.counter),
+		"status":    "done",
+		"additions": additions,
+		"deletions": deletions,
+	}, nil
+}
+
+// listResourceRecordSets lists the record sets of the zone, filtered by the name and the type of the request.
+func listResourceRecordSets(c *Cloud, zone, req map[string]interface{}) (interface{}, error) {
+	rrsets, _ := zone["rrsets"].([]interface{})
+	items := []interface{}{}
+	for _, r := range rrsets {
+		rrset, _ := r.(map[string]interface{})
+		if name, _ := req["name"].(string); name != "" && rrset["name"] != name {
+			continue
+		}
+		if typ, _ := req["type"].(string); typ != "" && rrset["type"] != typ {
+			continue
+		}
+		items = append(items, rrset)
+	}
+
+	return map[string]interface{}{"kind": "dns#resourceRecordSetsListResponse", "rrsets": items}, nil
+}
+
+// rrsetIndex returns the index of the record set with the name and the type of r, -1 if there is none.
+func rrsetIndex(rrsets []interface{}, r interface{}) int {
+	rrset, _ := r.(map[string]interface{})
+	for i, existing := range rrsets {
+		if existing, ok := existing.(map[string]interface{}); ok && existing["name"] == rrset["name"] && existing["type"] == rrset["type"] {
+			return i
+		}
+	}
+
+	return -1
+}
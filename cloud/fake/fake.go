@@ -0,0 +1,850 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package fake implements an in-memory GCP cloud backend for tests.
+package fake
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	computebeta "google.golang.org/api/compute/v0.beta"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	"google.golang.org/api/servicenetworking/v1"
+	"google.golang.org/api/serviceusage/v1"
+	"google.golang.org/api/storage/v1"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+const computeBasePath = "/compute/v1/"
+
+// dnsBasePath is the base path of the Cloud DNS API, whose objects are stored like the compute objects,
+// e.g. "projects/my-project/managedZones/my-zone".
+const dnsBasePath = "/dns/v1/"
+
+// The beta and alpha APIs are served from the same objects as the GA API.
+const (
+	computeBetaBasePath  = "/compute/beta/"
+	computeAlphaBasePath = "/compute/alpha/"
+)
+
+// VerbFunc handles a custom method (e.g. instanceGroups.addInstances) on the object
+// stored at the given path. The request body, if any, is decoded into req.
+type VerbFunc func(c *Cloud, obj map[string]interface{}, req map[string]interface{}) (interface{}, error)
+
+// Cloud is an in-memory implementation of the GCP APIs backing a real compute client,
+// so that scopes and services can be exercised without talking to GCP.
+//
+// Objects are stored by their path relative to the compute API base path,
+// e.g. "projects/my-project/global/networks/my-network".
+type Cloud struct {
+	mu      sync.Mutex
+	server  *httptest.Server
+	clients *clients
+	objects map[string]map[string]interface{}
+	errors  map[string]*googleapi.Error
+	opErrs  map[string]string
+	media   map[string]string
+	verbs   map[string]VerbFunc
+	counter int
+
+	// pageSize is the maximum number of items of the list responses, all the items are returned if zero.
+	pageSize int
+
+	// inProgress makes the operations be returned in progress, until CompleteOperations is called.
+	inProgress bool
+}
+
+// NewCloud starts a new in-memory cloud. Close must be called to release its resources.
+func NewCloud() *Cloud {
+	c := &Cloud{
+		objects: make(map[string]map[string]interface{}),
+		errors:  make(map[string]*googleapi.Error),
+		opErrs:  make(map[string]string),
+		media:   make(map[string]string),
+		verbs:   defaultVerbs(),
+	}
+	c.server = httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+
+	clients, err := c.newClients(c.server.Client().Transport)
+	if err != nil {
+		panic(fmt.Sprintf("failed to create fake compute client: %v", err))
+	}
+	c.clients = clients
+
+	return c
+}
+
+// clients are the API clients talking to the in-memory cloud through a transport.
+type clients struct {
+	compute      *compute.Service
+	computeBeta  *computebeta.Service
+	computeAlpha *computealpha.Service
+	serviceNet   *servicenetworking.APIService
+	serviceUsage *serviceusage.Service
+	dns          *dns.Service
+	container    *container.Service
+	storage      *storage.Service
+}
+
+func (c *Cloud) newClients(transport http.RoundTripper) (*clients, error) {
+	httpClient := option.WithHTTPClient(&http.Client{Transport: transport})
+	computeSvc, err := compute.NewService(context.Background(), option.WithEndpoint(c.server.URL+computeBasePath), httpClient)
+	if err != nil {
+		return nil, err
+	}
+	computeBetaSvc, err := computebeta.NewService(context.Background(), option.WithEndpoint(c.server.URL+computeBetaBasePath), httpClient)
+	if err != nil {
+		return nil, err
+	}
+	computeAlphaSvc, err := computealpha.NewService(context.Background(), option.WithEndpoint(c.server.URL+computeAlphaBasePath), httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceNetSvc, err := servicenetworking.NewService(context.Background(), option.WithEndpoint(c.server.URL+serviceNetworkingBasePath), httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	serviceUsageSvc, err := serviceusage.NewService(context.Background(), option.WithEndpoint(c.server.URL+serviceUsageBasePath), httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	// The paths of the Cloud DNS API include its base path.
+	dnsSvc, err := dns.NewService(context.Background(), option.WithEndpoint(c.server.URL+"/"), httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	containerSvc, err := container.NewService(context.Background(), option.WithEndpoint(c.server.URL+containerEndpoint), httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	storageSvc, err := storage.NewService(context.Background(), option.WithEndpoint(c.server.URL+storageBasePath), httpClient)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clients{
+		compute:      computeSvc,
+		computeBeta:  computeBetaSvc,
+		computeAlpha: computeAlphaSvc,
+		serviceNet:   serviceNetSvc,
+		serviceUsage: serviceUsageSvc,
+		dns:          dnsSvc,
+		container:    containerSvc,
+		storage:      storageSvc,
+	}, nil
+}
+
+// Compute returns a compute API client talking to the in-memory cloud.
+func (c *Cloud) Compute() *compute.Service {
+	return c.clients.compute
+}
+
+// ComputeBeta returns a compute beta API client talking to the in-memory cloud.
+func (c *Cloud) ComputeBeta() *computebeta.Service {
+	return c.clients.computeBeta
+}
+
+// ComputeAlpha returns a compute alpha API client talking to the in-memory cloud.
+func (c *Cloud) ComputeAlpha() *computealpha.Service {
+	return c.clients.computeAlpha
+}
+
+// ServiceNetworking returns a service networking API client talking to the in-memory cloud.
+func (c *Cloud) ServiceNetworking() *servicenetworking.APIService {
+	return c.clients.serviceNet
+}
+
+// ServiceUsage returns a service usage API client talking to the in-memory cloud.
+func (c *Cloud) ServiceUsage() *serviceusage.Service {
+	return c.clients.serviceUsage
+}
+
+// DNS returns a Cloud DNS API client talking to the in-memory cloud.
+func (c *Cloud) DNS() *dns.Service {
+	return c.clients.dns
+}
+
+// Container returns a GKE API client talking to the in-memory cloud.
+func (c *Cloud) Container() *container.Service {
+	return c.clients.container
+}
+
+// Storage returns a Cloud Storage API client talking to the in-memory cloud.
+func (c *Cloud) Storage() *storage.Service {
+	return c.clients.storage
+}
+
+// WithTransport returns a view of the in-memory cloud whose API calls go through the wrapped transport.
+func (c *Cloud) WithTransport(_ context.Context, wrap cloud.WrapTransportFunc) (cloud.Cloud, error) {
+	return newView(c, wrap)
+}
+
+// view is a Cloud sharing the objects of the in-memory cloud through a wrapped transport.
+type view struct {
+	cloud   *Cloud
+	wrap    cloud.WrapTransportFunc
+	clients *clients
+}
+
+func newView(c *Cloud, wrap cloud.WrapTransportFunc) (*view, error) {
+	clients, err := c.newClients(wrap(c.server.Client().Transport))
+	if err != nil {
+		return nil, err
+	}
+
+	return &view{cloud: c, wrap: wrap, clients: clients}, nil
+}
+
+func (v *view) Compute() *compute.Service {
+	return v.clients.compute
+}
+
+func (v *view) ComputeBeta() *computebeta.Service {
+	return v.clients.computeBeta
+}
+
+func (v *view) ComputeAlpha() *computealpha.Service {
+	return v.clients.computeAlpha
+}
+
+func (v *view) ServiceNetworking() *servicenetworking.APIService {
+	return v.clients.serviceNet
+}
+
+func (v *view) ServiceUsage() *serviceusage.Service {
+	return v.clients.serviceUsage
+}
+
+func (v *view) DNS() *dns.Service {
+	return v.clients.dns
+}
+
+func (v *view) Container() *container.Service {
+	return v.clients.container
+}
+
+func (v *view) Storage() *storage.Service {
+	return v.clients.storage
+}
+
+func (v *view) WithTransport(_ context.Context, wrap cloud.WrapTransportFunc) (cloud.Cloud, error) {
+	return newView(v.cloud, func(base http.RoundTripper) http.RoundTripper {
+		return wrap(v.wrap(base))
+	})
+}
+
+// Close shuts down the in-memory cloud.
+func (c *Cloud) Close() {
+	c.server.Close()
+}
+
+// SelfLink returns the self link of an object stored at the given path.
+func (c *Cloud) SelfLink(p string) string {
+	return c.server.URL + computeBasePath + strings.Trim(p, "/")
+}
+
+// path returns the path of an object from its self link.
+func (c *Cloud) path(selfLink string) string {
+	return strings.TrimPrefix(selfLink, c.server.URL+computeBasePath)
+}
+
+// AddRegion seeds a region and its zones into the project.
+func (c *Cloud) AddRegion(project, region string, zones ...string) {
+	regionPath := fmt.Sprintf("projects/%s/regions/%s", project, region)
+	zoneLinks := make([]interface{}, 0, len(zones))
+	for _, zone := range zones {
+		zonePath := fmt.Sprintf("projects/%s/zones/%s", project, zone)
+		c.Put(zonePath, map[string]interface{}{
+			"name":   zone,
+			"region": c.SelfLink(regionPath),
+			"status": "UP",
+		})
+		zoneLinks = append(zoneLinks, c.SelfLink(zonePath))
+	}
+	c.Put(regionPath, map[string]interface{}{
+		"name":   region,
+		"status": "UP",
+		"zones":  zoneLinks,
+	})
+}
+
+// Put stores an object at the given path, overwriting any existing object.
+func (c *Cloud) Put(p string, obj interface{}) {
+	m, err := toMap(obj)
+	if err != nil {
+		panic(fmt.Sprintf("failed to store object %q: %v", p, err))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(strings.Trim(p, "/"), m)
+}
+
+// Get decodes the object stored at the given path into out.
+// It returns false if no object exists.
+func (c *Cloud) Get(p string, out interface{}) bool {
+	c.mu.Lock()
+	obj, ok := c.objects[strings.Trim(p, "/")]
+	c.mu.Unlock()
+	if !ok {
+		return false
+	}
+	if out != nil {
+		if err := fromMap(obj, out); err != nil {
+			panic(fmt.Sprintf("failed to decode object %q: %v", p, err))
+		}
+	}
+
+	return true
+}
+
+// Delete removes the object stored at the given path.
+func (c *Cloud) Delete(p string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.objects, strings.Trim(p, "/"))
+}
+
+// List returns the sorted paths of the objects stored in the given collection,
+// e.g. "projects/my-project/global/firewalls".
+func (c *Cloud) List(collection string) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.list(strings.Trim(collection, "/"))
+}
+
+// SetError makes every request with the given HTTP method on the given path
+// fail with the error until it is cleared with a nil error.
+func (c *Cloud) SetError(method, p string, err *googleapi.Error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := method + " " + strings.Trim(p, "/")
+	if err == nil {
+		delete(c.errors, key)
+		return
+	}
+	c.errors[key] = err
+}
+
+// SetPageSize limits the number of items of each list response, the next items being returned with the next page
+// token, e.g. to check that all the pages of a list are read. All the items are returned at once if zero.
+func (c *Cloud) SetPageSize(size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pageSize = size
+}
+
+// SetOperationError makes the requests with the method to the path return an operation failed with the
+// error code, e.g. ZONE_RESOURCE_POOL_EXHAUSTED, instead of being applied. An empty code clears the error.
+func (c *Cloud) SetOperationError(method, p, code string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := method + " " + strings.Trim(p, "/")
+	if code == "" {
+		delete(c.opErrs, key)
+		return
+	}
+	c.opErrs[key] = code
+}
+
+// SetOperationsInProgress makes the operations of the next requests be returned in progress, as the long running
+// operations are, until CompleteOperations is called. Their changes are applied right away nonetheless.
+func (c *Cloud) SetOperationsInProgress(inProgress bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.inProgress = inProgress
+}
+
+// CompleteOperations completes the operations in progress.
+func (c *Cloud) CompleteOperations() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for p, obj := range c.objects {
+		if path.Base(path.Dir(p)) == "operations" && obj["status"] == "RUNNING" {
+			obj["status"] = "DONE"
+			obj["progress"] = 100
+		}
+	}
+}
+
+// SetGuestAttribute sets a guest attribute of the instance stored at the given path,
+// as a workload running on the instance would through the metadata server.
+func (c *Cloud) SetGuestAttribute(p, key, value string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	obj, ok := c.objects[strings.Trim(p, "/")]
+	if !ok {
+		panic(fmt.Sprintf("failed to set guest attribute: object %q not found", p))
+	}
+	attrs, _ := obj["guestAttributes"].(map[string]interface{})
+	if attrs == nil {
+		attrs = map[string]interface{}{}
+		obj["guestAttributes"] = attrs
+	}
+	attrs[key] = value
+}
+
+// SetSerialPortOutput sets the serial port output of the instance stored at the given path,
+// as the guest would by writing to its console.
+func (c *Cloud) SetSerialPortOutput(p, output string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	obj, ok := c.objects[strings.Trim(p, "/")]
+	if !ok {
+		panic(fmt.Sprintf("failed to set serial port output: object %q not found", p))
+	}
+	obj["serialPortOutput"] = output
+}
+
+// HandleVerb registers the handler for a custom method.
+func (c *Cloud) HandleVerb(verb string, fn VerbFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verbs[verb] = fn
+}
+
+func (c *Cloud) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, serviceNetworkingBasePath) {
+		c.serveServiceNetworking(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, serviceUsageBasePath) {
+		c.serveServiceUsage(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, containerBasePath) {
+		c.serveContainer(w, r)
+		return
+	}
+	if strings.HasPrefix(r.URL.Path, storageBasePath) || strings.HasPrefix(r.URL.Path, storageUploadPath) {
+		c.serveStorage(w, r)
+		return
+	}
+
+	var p string
+	for _, basePath := range []string{computeBasePath, computeBetaBasePath, computeAlphaBasePath, dnsBasePath} {
+		if strings.HasPrefix(r.URL.Path, basePath) {
+			p = strings.Trim(strings.TrimPrefix(r.URL.Path, basePath), "/")
+		}
+	}
+	if p == "" {
+		writeError(w, &googleapi.Error{Code: http.StatusNotFound, Message: "unknown api"})
+		return
+	}
+
+	if name := r.URL.Query().Get("autoscaler"); name != "" && (r.Method == http.MethodPut || r.Method == http.MethodPatch) {
+		// The autoscalers are updated through their collection, named by a query parameter.
+		p = path.Join(p, name)
+	}
+
+	var body map[string]interface{}
+	if r.Body != nil && (r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch) {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err.Error() != "EOF" {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+	}
+	if count := r.URL.Query().Get("initialNodeCount"); count != "" && body != nil {
+		body["initialNodeCount"] = count
+	}
+	if size := r.URL.Query().Get("size"); size != "" && r.Method == http.MethodPost {
+		// The size of the instance group managers is resized with a query parameter, without a body.
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+		body["size"] = size
+	}
+	if priority := r.URL.Query().Get("priority"); priority != "" && r.Method == http.MethodPost {
+		// The rules of the firewall policies are patched and removed by priority, a query parameter.
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+		if _, ok := body["priority"]; !ok {
+			body["priority"], _ = strconv.ParseFloat(priority, 64)
+		}
+	}
+	if name := r.URL.Query().Get("name"); name != "" && r.Method == http.MethodPost {
+		// The associations of the firewall policies are removed by name, a query parameter.
+		if body == nil {
+			body = map[string]interface{}{}
+		}
+		body["name"] = name
+	}
+	if r.Method == http.MethodGet {
+		// Custom methods served with GET take their parameters from the query.
+		body = map[string]interface{}{}
+		for k := range r.URL.Query() {
+			body[k] = r.URL.Query().Get(k)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err, ok := c.errors[r.Method+" "+p]; ok {
+		writeError(w, err)
+		return
+	}
+
+	var res interface{}
+	var err error
+	if code, ok := c.opErrs[r.Method+" "+p]; ok {
+		res = c.failedOperation(r.Method, p, body, code)
+	} else {
+		res, err = c.handle(r.Method, p, r.URL.Query().Get("filter"), body)
+	}
+	if err != nil {
+		gerr, ok := err.(*googleapi.Error)
+		if !ok {
+			gerr = &googleapi.Error{Code: http.StatusInternalServerError, Message: err.Error()}
+		}
+		writeError(w, gerr)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (c *Cloud) handle(method, p, filter string, body map[string]interface{}) (interface{}, error) {
+	parent, last := path.Split(p)
+	parent = strings.TrimSuffix(parent, "/")
+
+	if fn, ok := c.verbs[last]; ok {
+		obj, exists := c.objects[parent]
+		if !exists && !isCollection(parent) {
+			return nil, notFound(parent)
+		}
+		res, err := fn(c, obj, body)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			return c.operation(last, parent), nil
+		}
+		return res, nil
+	}
+
+	switch method {
+	case http.MethodGet:
+		if isCollection(p) {
+			pageToken, _ := body["pageToken"].(string)
+			return c.listResponse(p, filter, pageToken), nil
+		}
+		obj, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		return obj, nil
+	case http.MethodPost:
+		name, _ := body["name"].(string)
+		if name == "" {
+			return nil, &googleapi.Error{Code: http.StatusBadRequest, Message: "missing resource name"}
+		}
+		target := p + "/" + name
+		if _, ok := c.objects[target]; ok {
+			return nil, &googleapi.Error{
+				Code:    http.StatusConflict,
+				Message: fmt.Sprintf("The resource '%s' already exists", target),
+				Errors:  []googleapi.ErrorItem{{Reason: "alreadyExists"}},
+			}
+		}
+		c.counter++
+		body["id"] = strconv.Itoa(c.counter)
+		body["creationTimestamp"] = time.Now().Format(time.RFC3339)
+		body["fingerprint"] = strconv.Itoa(c.counter)
+		body["labelFingerprint"] = strconv.Itoa(c.counter)
+		defaultObject(c, last, body)
+		c.store(target, body)
+		return c.operation("insert", target), nil
+	case http.MethodPut, http.MethodPatch:
+		obj, ok := c.objects[p]
+		if !ok {
+			return nil, notFound(p)
+		}
+		if err := checkFingerprint(obj["fingerprint"], body["fingerprint"]); err != nil {
+			return nil, err
+		}
+		if method == http.MethodPut {
+			for k := range obj {
+				if k != "selfLink" && k != "id" && k != "creationTimestamp" {
+					delete(obj, k)
+				}
+			}
+		}
+		for k, v := range body {
+			if k != "selfLink" && k != "id" {
+				obj[k] = v
+			}
+		}
+		obj["fingerprint"] = fmt.Sprintf("%d", c.counter)
+		opType := "patch"
+		if method == http.MethodPut {
+			opType = "update"
+		}
+		return c.operation(opType, p), nil
+	case http.MethodDelete:
+		if _, ok := c.objects[p]; !ok {
+			return nil, notFound(p)
+		}
+		delete(c.objects, p)
+		return c.operation("delete", p), nil
+	}
+
+	return nil, &googleapi.Error{Code: http.StatusMethodNotAllowed, Message: method}
+}
+
+func (c *Cloud) store(p string, obj map[string]interface{}) {
+	if _, ok := obj["name"]; !ok {
+		obj["name"] = path.Base(p)
+	}
+	obj["selfLink"] = c.SelfLink(p)
+	c.objects[p] = obj
+}
+
+func (c *Cloud) list(collection string) []string {
+	res := []string{}
+	for p := range c.objects {
+		parent, _ := path.Split(p)
+		if strings.TrimSuffix(parent, "/") == collection {
+			res = append(res, p)
+		}
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func (c *Cloud) listResponse(collection, filter, pageToken string) interface{} {
+	// Aggregated lists return the items grouped by scope, e.g. "zones/us-central1-a".
+	if parts := strings.Split(collection, "/"); len(parts) == 4 && parts[2] == "aggregated" {
+		prefix := strings.Join(parts[:2], "/") + "/"
+		matches := []string{}
+		for p, obj := range c.objects {
+			rest := strings.TrimPrefix(p, prefix)
+			segments := strings.Split(rest, "/")
+			if !strings.HasPrefix(p, prefix) || len(segments) != 4 || segments[2] != parts[3] || !matchFilter(obj, filter) {
+				continue
+			}
+			matches = append(matches, p)
+		}
+		sort.Strings(matches)
+		page, next := c.page(matches, pageToken)
+		items := map[string]interface{}{}
+		for _, p := range page {
+			segments := strings.Split(strings.TrimPrefix(p, prefix), "/")
+			key := segments[0] + "/" + segments[1]
+			scoped, _ := items[key].(map[string]interface{})
+			if scoped == nil {
+				scoped = map[string]interface{}{parts[3]: []interface{}{}}
+				items[key] = scoped
+			}
+			scoped[parts[3]] = append(scoped[parts[3]].([]interface{}), c.objects[p])
+		}
+		return map[string]interface{}{"items": items, "nextPageToken": next}
+	}
+
+	matches := []string{}
+	for _, p := range c.list(collection) {
+		if matchFilter(c.objects[p], filter) {
+			matches = append(matches, p)
+		}
+	}
+	page, next := c.page(matches, pageToken)
+	items := []interface{}{}
+	for _, p := range page {
+		items = append(items, c.objects[p])
+	}
+
+	return map[string]interface{}{"items": items, "nextPageToken": next}
+}
+
+// page returns the page of the sorted paths starting at the page token, an offset, and the token of the next page,
+// empty if it's the last one.
+func (c *Cloud) page(paths []string, pageToken string) ([]string, string) {
+	start, _ := strconv.Atoi(pageToken)
+	if start > len(paths) {
+		start = len(paths)
+	}
+	if c.pageSize == 0 || start+c.pageSize >= len(paths) {
+		return paths[start:], ""
+	}
+
+	return paths[start : start+c.pageSize], strconv.Itoa(start + c.pageSize)
+}
+
+// operation records a completed operation and returns it.
+// failedOperation returns a done operation with the error code for the request, leaving the objects untouched.
+func (c *Cloud) failedOperation(method, p string, body map[string]interface{}, code string) map[string]interface{} {
+	target, opType := p, strings.ToLower(method)
+	if name, ok := body["name"].(string); ok && method == http.MethodPost {
+		target, opType = p+"/"+name, "insert"
+	}
+	op := c.operation(opType, target)
+	op["error"] = map[string]interface{}{
+		"errors": []interface{}{
+			map[string]interface{}{"code": code, "message": fmt.Sprintf("operation failed with %s", code)},
+		},
+	}
+
+	return op
+}
+
+func (c *Cloud) operation(opType, target string) map[string]interface{} {
+	c.counter++
+	parts := strings.Split(target, "/")
+	scope := "global"
+	op := map[string]interface{}{
+		"kind":          "compute#operation",
+		"name":          fmt.Sprintf("operation-%d", c.counter),
+		"id":            strconv.Itoa(c.counter),
+		"operationType": opType,
+		"targetLink":    c.SelfLink(target),
+		"status":        "DONE",
+		"progress":      100,
+	}
+	if c.inProgress {
+		op["status"] = "RUNNING"
+		op["progress"] = 0
+	}
+	if len(parts) > 3 && (parts[2] == "zones" || parts[2] == "regions") {
+		scope = strings.Join(parts[2:4], "/")
+		op[strings.TrimSuffix(parts[2], "s")] = c.SelfLink(strings.Join(parts[:4], "/"))
+	}
+	opPath := fmt.Sprintf("%s/%s/operations/%s", strings.Join(parts[:2], "/"), scope, op["name"])
+	c.store(opPath, op)
+
+	return op
+}
+
+// isCollection reports whether the path refers to a collection rather than a single object.
+// Paths alternate between collection and object names after the project, with the
+// exception of the "global" and "aggregated" scopes.
+func isCollection(p string) bool {
+	parts := strings.Split(p, "/")
+	if len(parts) < 2 {
+		return true
+	}
+	rest := parts[2:]
+	if len(rest) > 0 && (rest[0] == "global" || rest[0] == "aggregated") {
+		rest = rest[1:]
+	}
+
+	return len(rest)%2 == 1
+}
+
+var filterRegexp = regexp.MustCompile(`([\w.\-/]+)\s*(=|!=|eq|ne)\s*"?([^"\s)]*)"?`)
+
+// matchFilter supports the simple conjunction of comparisons used by the provider,
+// e.g. `region = "..."` or `(labels.key = "value") (labels.other = "value")`.
+func matchFilter(obj map[string]interface{}, filter string) bool {
+	for _, m := range filterRegexp.FindAllStringSubmatch(filter, -1) {
+		var value interface{} = obj
+		for _, key := range strings.Split(m[1], ".") {
+			nested, ok := value.(map[string]interface{})
+			if !ok {
+				value = nil
+				break
+			}
+			value = nested[key]
+		}
+		equal := fmt.Sprint(value) == m[3]
+		if value == nil {
+			equal = m[3] == ""
+		}
+		if (m[2] == "=" || m[2] == "eq") != equal {
+			return false
+		}
+	}
+
+	return true
+}
+
+func notFound(p string) *googleapi.Error {
+	return &googleapi.Error{
+		Code:    http.StatusNotFound,
+		Message: fmt.Sprintf("The resource '%s' was not found", p),
+		Errors:  []googleapi.ErrorItem{{Reason: "notFound"}},
+	}
+}
+
+func writeError(w http.ResponseWriter, err *googleapi.Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Code)
+	errs := make([]map[string]string, 0, len(err.Errors))
+	for _, e := range err.Errors {
+		errs = append(errs, map[string]string{"reason": e.Reason, "message": e.Message})
+	}
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    err.Code,
+			"message": err.Message,
+			"errors":  errs,
+		},
+	})
+}
+
+func toMap(obj interface{}) (map[string]interface{}, error) {
+	if m, ok := obj.(map[string]interface{}); ok {
+		return m, nil
+	}
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]interface{}{}
+
+	return res, json.Unmarshal(data, &res)
+}
+
+func fromMap(obj map[string]interface{}, out interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
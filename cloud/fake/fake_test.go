@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake_test
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	computebeta "google.golang.org/api/compute/v0.beta"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+func TestCloud(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fake.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b")
+	c.AddRegion("my-project", "europe-west1", "europe-west1-b")
+
+	_, err := c.Compute().Instances.Get("my-project", "us-central1-a", "my-instance").Do()
+	g.Expect(gcperrors.IsNotFound(err)).To(BeTrue())
+
+	op, err := c.Compute().Instances.Insert("my-project", "us-central1-a", &compute.Instance{
+		Name:   "my-instance",
+		Labels: map[string]string{"role": "node"},
+	}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(op.Status).To(Equal("DONE"))
+
+	_, err = c.Compute().ZoneOperations.Get("my-project", "us-central1-a", op.Name).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	instance, err := c.Compute().Instances.Get("my-project", "us-central1-a", "my-instance").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance.Status).To(Equal("RUNNING"))
+	g.Expect(instance.SelfLink).To(Equal(c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-instance")))
+
+	zones, err := c.Compute().Zones.List("my-project").Filter(`region = "` + c.SelfLink("projects/my-project/regions/us-central1") + `"`).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(zones.Items).To(HaveLen(2))
+
+	aggregated, err := c.Compute().Instances.AggregatedList("my-project").Filter(`(labels.role = "node")`).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(aggregated.Items).To(HaveKey("zones/us-central1-a"))
+	g.Expect(aggregated.Items["zones/us-central1-a"].Instances).To(HaveLen(1))
+
+	c.SetError(http.MethodDelete, "projects/my-project/zones/us-central1-a/instances/my-instance", &googleapi.Error{Code: http.StatusForbidden})
+	_, err = c.Compute().Instances.Delete("my-project", "us-central1-a", "my-instance").Do()
+	g.Expect(err).To(HaveOccurred())
+
+	c.SetError(http.MethodDelete, "projects/my-project/zones/us-central1-a/instances/my-instance", nil)
+	_, err = c.Compute().Instances.Delete("my-project", "us-central1-a", "my-instance").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instances")).To(BeEmpty())
+}
+
+func TestCloudBetaAPI(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fake.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a")
+
+	_, err := c.ComputeBeta().Instances.Insert("my-project", "us-central1-a", &computebeta.Instance{Name: "my-instance"}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The objects are shared by the GA, beta and alpha APIs.
+	instance, err := c.Compute().Instances.Get("my-project", "us-central1-a", "my-instance").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance.Status).To(Equal("RUNNING"))
+	_, err = c.ComputeAlpha().Instances.Get("my-project", "us-central1-a", "my-instance").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+const serviceNetworkingBasePath = "/servicenetworking/"
+
+// servicePeering is the name of the peering created by the service networking connections.
+const servicePeering = "servicenetworking-googleapis-com"
+
+// connectionPath returns the path the private connection of the network is stored at,
+// there is at most one connection per network.
+func connectionPath(network string) string {
+	return "services/servicenetworking.googleapis.com/connections/" + url.PathEscape(network)
+}
+
+// GetConnection decodes the private service connection of the network, e.g. projects/123/global/networks/my-network,
+// into out. It returns false if the network isn't connected.
+func (c *Cloud) GetConnection(network string, out interface{}) bool {
+	return c.Get(connectionPath(network), out)
+}
+
+// serveServiceNetworking serves the connections of the service networking API, their operations complete immediately.
+func (c *Cloud) serveServiceNetworking(w http.ResponseWriter, r *http.Request) {
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, serviceNetworkingBasePath+"v1/"), "/")
+
+	var body map[string]interface{}
+	if r.Body != nil && r.Method != http.MethodGet {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && err.Error() != "EOF" {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err, ok := c.errors[r.Method+" "+p]; ok {
+		writeError(w, err)
+		return
+	}
+	res, err := c.handleServiceNetworking(r.Method, p, r.URL.Query(), body)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
+
+func (c *Cloud) handleServiceNetworking(method, p string, query url.Values, body map[string]interface{}) (interface{}, *googleapi.Error) {
+	switch {
+	case method == http.MethodGet && strings.HasPrefix(p, "operations/"):
+		return map[string]interface{}{"name": p, "done": true}, nil
+	case method == http.MethodGet && strings.HasSuffix(p, "/connections"):
+		connections := []interface{}{}
+		if obj, ok := c.objects[connectionPath(query.Get("network"))]; ok {
+			connections = append(connections, obj)
+		}
+		return map[string]interface{}{"connections": connections}, nil
+	case method == http.MethodPost && strings.HasSuffix(p, "/connections"):
+		network, _ := body["network"].(string)
+		if _, ok := c.objects[connectionPath(network)]; ok {
+			return nil, &googleapi.Error{
+				Code:    http.StatusConflict,
+				Message: fmt.Sprintf("The network '%s' is already connected", network),
+				Errors:  []googleapi.ErrorItem{{Reason: "alreadyExists"}},
+			}
+		}
+		body["peering"] = servicePeering
+		body["service"] = strings.TrimSuffix(p, "/connections")
+		c.objects[connectionPath(network)] = body
+	case method == http.MethodPatch:
+		network, _ := body["network"].(string)
+		obj, ok := c.objects[connectionPath(network)]
+		if !ok {
+			return nil, notFound(connectionPath(network))
+		}
+		obj["reservedPeeringRanges"] = body["reservedPeeringRanges"]
+	case method == http.MethodPost && strings.Contains(p, "/connections/"):
+		// The client sends connections.deleteConnection without its custom verb.
+		network, _ := body["consumerNetwork"].(string)
+		if _, ok := c.objects[connectionPath(network)]; !ok {
+			return nil, notFound(connectionPath(network))
+		}
+		delete(c.objects, connectionPath(network))
+	default:
+		return nil, &googleapi.Error{Code: http.StatusNotFound, Message: "unknown method " + method + " " + p}
+	}
+
+	c.counter++
+
+	return map[string]interface{}{"name": fmt.Sprintf("operations/%d", c.counter), "done": true}, nil
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+const serviceUsageBasePath = "/serviceusage/"
+
+// DisableService disables the API of the project, e.g. compute.googleapis.com. The APIs are enabled by default.
+func (c *Cloud) DisableService(project, service string) {
+	c.Put("projects/"+project+"/services/"+service, map[string]interface{}{
+		"name":  "projects/" + project + "/services/" + service,
+		"state": "DISABLED",
+	})
+}
+
+// serveServiceUsage serves the state of the APIs of the projects, the APIs not disabled being enabled.
+func (c *Cloud) serveServiceUsage(w http.ResponseWriter, r *http.Request) {
+	p := strings.Trim(strings.TrimPrefix(r.URL.Path, serviceUsageBasePath+"v1/"), "/")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err, ok := c.errors[r.Method+" "+p]; ok {
+		writeError(w, err)
+		return
+	}
+	if r.Method != http.MethodGet || !strings.Contains(p, "/services/") {
+		writeError(w, &googleapi.Error{Code: http.StatusNotFound, Message: "unknown method " + r.Method + " " + p})
+		return
+	}
+
+	res, ok := c.objects[p]
+	if !ok {
+		res = map[string]interface{}{"name": p, "state": "ENABLED"}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
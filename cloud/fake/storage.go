@@ -0,0 +1,163 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fake
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/googleapi"
+)
+
+// storageBasePath is the base path of the Cloud Storage JSON API, storageUploadPath the one of its media uploads.
+const (
+	storageBasePath   = "/storage/v1/"
+	storageUploadPath = "/upload/storage/v1/"
+)
+
+// objectPath returns the path the Cloud Storage object is stored at, its name being escaped
+// so that the objects of a bucket are listed as a collection.
+func objectPath(bucket, name string) string {
+	return "b/" + bucket + "/o/" + url.PathEscape(name)
+}
+
+// GetObject decodes the Cloud Storage object of the bucket into out, and returns its content.
+// It returns false if the object doesn't exist.
+func (c *Cloud) GetObject(bucket, name string, out interface{}) (string, bool) {
+	if !c.Get(objectPath(bucket, name), out) {
+		return "", false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.media[objectPath(bucket, name)], true
+}
+
+// serveStorage serves the objects of the Cloud Storage API, uploaded in a single multipart request.
+// The buckets aren't modeled: any bucket exists.
+func (c *Cloud) serveStorage(w http.ResponseWriter, r *http.Request) {
+	p := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, storageUploadPath), storageBasePath)
+	bucket, name := strings.TrimPrefix(p, "b/"), ""
+	if i := strings.Index(bucket, "/o"); i >= 0 {
+		bucket, name = bucket[:i], strings.TrimPrefix(strings.TrimPrefix(bucket[i:], "/o"), "/")
+	}
+
+	var (
+		obj     map[string]interface{}
+		content []byte
+	)
+	if r.Method == http.MethodPost {
+		var err error
+		if obj, content, err = readUpload(r); err != nil {
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: err.Error()})
+			return
+		}
+		name = r.URL.Query().Get("name")
+		if n, ok := obj["name"].(string); ok && n != "" {
+			name = n
+		}
+		// Only the private predefined ACL is modeled, granting the owner of the object access to it.
+		switch acl := r.URL.Query().Get("predefinedAcl"); acl {
+		case "":
+		case "private":
+			obj["acl"] = []interface{}{map[string]interface{}{"entity": "project-owners", "role": "OWNER"}}
+		default:
+			writeError(w, &googleapi.Error{Code: http.StatusBadRequest, Message: "unsupported predefined ACL " + acl})
+			return
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := objectPath(bucket, name)
+	if err, ok := c.errors[r.Method+" "+key]; ok {
+		writeError(w, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		obj["name"] = name
+		obj["bucket"] = bucket
+		obj["size"] = strconv.Itoa(len(content))
+		c.counter++
+		obj["generation"] = strconv.Itoa(c.counter)
+		c.objects[key] = obj
+		c.media[key] = string(content)
+	case http.MethodGet:
+		obj = c.objects[key]
+		if obj == nil {
+			writeError(w, notFound(key))
+			return
+		}
+		if r.URL.Query().Get("alt") == "media" {
+			_, _ = io.WriteString(w, c.media[key])
+			return
+		}
+	case http.MethodDelete:
+		if _, ok := c.objects[key]; !ok {
+			writeError(w, notFound(key))
+			return
+		}
+		delete(c.objects, key)
+		delete(c.media, key)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		writeError(w, &googleapi.Error{Code: http.StatusNotFound, Message: "unknown method " + r.Method + " " + p})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(obj)
+}
+
+// readUpload reads the metadata and the content of an object uploaded with a multipart request,
+// or only its content with a media request.
+func readUpload(r *http.Request) (map[string]interface{}, []byte, error) {
+	obj := map[string]interface{}{}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") {
+		content, err := ioutil.ReadAll(r.Body)
+		return obj, content, err
+	}
+
+	reader := multipart.NewReader(r.Body, params["boundary"])
+	part, err := reader.NextPart()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := json.NewDecoder(part).Decode(&obj); err != nil {
+		return nil, nil, err
+	}
+	part, err = reader.NextPart()
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := ioutil.ReadAll(part)
+
+	return obj, content, err
+}
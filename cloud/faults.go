@@ -0,0 +1,184 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FaultInjection makes the GCP API calls fail at the configured rates, to exercise the retries and
+// the error handling of the controllers in CI and soak tests. It must not be used in production.
+type FaultInjection struct {
+	// QuotaRate is the rate of the calls rejected as exceeding a rate quota of the project.
+	QuotaRate float64
+	// ServerErrorRate is the rate of the calls failing with a server error.
+	ServerErrorRate float64
+	// NotFoundAfterInsertRate is the rate of the resources reported as not found by the first read
+	// following their creation, like the eventually consistent reads of the GCP APIs.
+	NotFoundAfterInsertRate float64
+
+	mu       sync.Mutex
+	rand     *rand.Rand
+	inserted map[string]bool
+}
+
+// ParseFaultInjection parses a comma separated list of fault rates, e.g. "quota=0.05,server-error=0.01".
+// The faults are quota, server-error and not-found-after-insert, their rates are between 0 and 1.
+// It returns nil if the spec is empty.
+func ParseFaultInjection(spec string) (*FaultInjection, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	f := &FaultInjection{}
+	for _, kv := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("invalid fault %q, expected <fault>=<rate>", kv)
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, errors.Errorf("invalid rate %q of fault %q, expected a number between 0 and 1", parts[1], parts[0])
+		}
+		switch parts[0] {
+		case "quota":
+			f.QuotaRate = rate
+		case "server-error":
+			f.ServerErrorRate = rate
+		case "not-found-after-insert":
+			f.NotFoundAfterInsertRate = rate
+		default:
+			return nil, errors.Errorf("unknown fault %q", parts[0])
+		}
+	}
+
+	return f, nil
+}
+
+// Seed makes the injected faults reproducible.
+func (f *FaultInjection) Seed(seed int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.rand = rand.New(rand.NewSource(seed)) // nolint:gosec
+}
+
+// Wrap is a WrapTransportFunc injecting the faults in the API calls.
+func (f *FaultInjection) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &faultTransport{faults: f, base: base}
+}
+
+type faultTransport struct {
+	faults *FaultInjection
+	base   http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	i := strings.Index(req.URL.Path, "/projects/")
+	if i < 0 {
+		return t.base.RoundTrip(req)
+	}
+	p := strings.Trim(req.URL.Path[i+1:], "/")
+
+	f := t.faults
+	if f.inject(f.QuotaRate) {
+		return faultResponse(req, http.StatusTooManyRequests, "rateLimitExceeded",
+			"Quota exceeded for quota metric 'Queries' and limit 'Queries per 100 seconds' (injected fault)")
+	}
+	if f.inject(f.ServerErrorRate) {
+		return faultResponse(req, http.StatusServiceUnavailable, "backendError", "Backend Error (injected fault)")
+	}
+	if req.Method == http.MethodGet && f.firstReadAfterInsert(p) && f.inject(f.NotFoundAfterInsertRate) {
+		return faultResponse(req, http.StatusNotFound, "notFound", fmt.Sprintf("The resource '%s' was not found (injected fault)", p))
+	}
+
+	var name string
+	if req.Method == http.MethodPost && f.NotFoundAfterInsertRate > 0 && isInsert(p) {
+		var data []byte
+		var err error
+		if req, data, err = copyBody(req); err != nil {
+			return nil, err
+		}
+		var body struct {
+			Name string `json:"name"`
+		}
+		_ = json.Unmarshal(data, &body)
+		name = body.Name
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && name != "" && resp.StatusCode < http.StatusBadRequest {
+		f.mu.Lock()
+		if f.inserted == nil {
+			f.inserted = make(map[string]bool)
+		}
+		f.inserted[path.Join(p, name)] = true
+		f.mu.Unlock()
+	}
+
+	return resp, err
+}
+
+// inject returns true with the probability rate.
+func (f *FaultInjection) inject(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.rand == nil {
+		f.rand = rand.New(rand.NewSource(time.Now().UnixNano())) // nolint:gosec
+	}
+
+	return f.rand.Float64() < rate
+}
+
+// firstReadAfterInsert returns true if the resource was created and not read since.
+func (f *FaultInjection) firstReadAfterInsert(p string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.inserted[p] {
+		return false
+	}
+	delete(f.inserted, p)
+
+	return true
+}
+
+func faultResponse(req *http.Request, code int, reason, message string) (*http.Response, error) {
+	return jsonResponse(req, code, map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": message,
+			"errors":  []map[string]string{{"reason": reason, "message": message}},
+		},
+	})
+}
@@ -0,0 +1,81 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+func TestParseFaultInjection(t *testing.T) {
+	g := NewWithT(t)
+
+	faults, err := cloud.ParseFaultInjection("")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(faults).To(BeNil())
+
+	faults, err = cloud.ParseFaultInjection("quota=0.05, server-error=0.01,not-found-after-insert=1")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(faults.QuotaRate).To(Equal(0.05))
+	g.Expect(faults.ServerErrorRate).To(Equal(0.01))
+	g.Expect(faults.NotFoundAfterInsertRate).To(Equal(1.0))
+
+	for _, spec := range []string{"quota", "quota=2", "quota=x", "timeout=0.1"} {
+		_, err := cloud.ParseFaultInjection(spec)
+		g.Expect(err).To(HaveOccurred(), spec)
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	faults := &cloud.FaultInjection{QuotaRate: 1}
+	faults.Seed(1)
+	faulty, err := c.WithTransport(context.TODO(), faults.Wrap)
+	g.Expect(err).NotTo(HaveOccurred())
+	svc := faulty.Compute()
+
+	_, err = svc.Networks.Insert("my-project", &compute.Network{Name: "my-network"}).Do()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.(*googleapi.Error).Code).To(Equal(http.StatusTooManyRequests))
+	g.Expect(c.Get("projects/my-project/global/networks/my-network", nil)).To(BeFalse())
+
+	faults.QuotaRate, faults.ServerErrorRate = 0, 1
+	_, err = svc.Networks.Get("my-project", "my-network").Do()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.(*googleapi.Error).Code).To(Equal(http.StatusServiceUnavailable))
+
+	// Only the first read following the creation reports the resource as not found.
+	faults.ServerErrorRate, faults.NotFoundAfterInsertRate = 0, 1
+	_, err = svc.Networks.Insert("my-project", &compute.Network{Name: "my-network"}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = svc.Networks.Get("my-project", "my-network").Do()
+	g.Expect(gcperrors.IsNotFound(err)).To(BeTrue())
+	_, err = svc.Networks.Get("my-project", "my-network").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+}
@@ -19,10 +19,40 @@ package gcperrors
 
 import (
 	"net/http"
+	"regexp"
 
+	"github.com/pkg/errors"
 	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+const (
+	// ZoneResourcePoolExhausted is the error code of the compute operations failing because the zone ran out of resources.
+	ZoneResourcePoolExhausted = "ZONE_RESOURCE_POOL_EXHAUSTED"
+	// zoneResourcePoolExhaustedWithDetails is the variant of ZoneResourcePoolExhausted detailing the missing resources.
+	zoneResourcePoolExhaustedWithDetails = "ZONE_RESOURCE_POOL_EXHAUSTED_WITH_DETAILS"
+)
+
+const (
+	// VMExternalIPAccessConstraint is the org policy constraint restricting the instances allowed to have an external IP.
+	VMExternalIPAccessConstraint = "compute.vmExternalIpAccess"
+	// RequireShieldedVMConstraint is the org policy constraint requiring the instances to be Shielded VMs.
+	RequireShieldedVMConstraint = "compute.requireShieldedVm"
+	// TrustedImageProjectsConstraint is the org policy constraint restricting the projects the images can come from.
+	TrustedImageProjectsConstraint = "compute.trustedImageProjects"
+	// RequireOSLoginConstraint is the org policy constraint requiring OS Login on the instances.
+	RequireOSLoginConstraint = "compute.requireOsLogin"
 )
 
+// fingerprintPattern matches the error of a request carrying an outdated fingerprint, e.g.
+// "Labels fingerprint either invalid or resource labels have changed".
+var fingerprintPattern = regexp.MustCompile(`(?i)fingerprint`)
+
+// constraintPattern matches the org policy constraint named in the error of a denied request,
+// e.g. "Constraint constraints/compute.requireShieldedVm violated for project my-project.".
+var constraintPattern = regexp.MustCompile(`constraints/([a-zA-Z]+\.[a-zA-Z]+) violated`)
+
 // IsNotFound reports whether err is a Google API error
 // with http.StatusNotFround.
 func IsNotFound(err error) bool {
@@ -33,3 +63,31 @@ func IsNotFound(err error) bool {
 
 	return ok && ae.Code == http.StatusNotFound
 }
+
+// IsZoneResourcePoolExhausted reports whether err is a compute operation error
+// caused by the zone running out of the requested resources.
+func IsZoneResourcePoolExhausted(err error) bool {
+	return wait.HasErrorCode(err, ZoneResourcePoolExhausted) || wait.HasErrorCode(err, zoneResourcePoolExhaustedWithDetails)
+}
+
+// IsFingerprintMismatch reports whether err is a Google API error rejecting an update because the
+// fingerprint of the request doesn't match the one of the resource, i.e. the resource has changed since
+// it was read. The org policy violations share the http.StatusPreconditionFailed code.
+func IsFingerprintMismatch(err error) bool {
+	ae, ok := errors.Cause(err).(*googleapi.Error)
+
+	return ok && ae.Code == http.StatusPreconditionFailed && fingerprintPattern.MatchString(ae.Message)
+}
+
+// ViolatedConstraint returns the org policy constraint, e.g. RequireShieldedVMConstraint,
+// which denied the request or compute operation failing with err, an empty string otherwise.
+func ViolatedConstraint(err error) string {
+	switch err := errors.Cause(err).(type) {
+	case *googleapi.Error, *wait.OperationError:
+		if m := constraintPattern.FindStringSubmatch(err.Error()); m != nil {
+			return m[1]
+		}
+	}
+
+	return ""
+}
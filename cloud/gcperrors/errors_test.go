@@ -0,0 +1,62 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package gcperrors
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+func TestViolatedConstraint(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(ViolatedConstraint(nil)).To(BeEmpty())
+	g.Expect(ViolatedConstraint(&googleapi.Error{Code: http.StatusNotFound})).To(BeEmpty())
+	g.Expect(ViolatedConstraint(errors.New("Constraint constraints/compute.requireOsLogin violated"))).To(BeEmpty())
+
+	err := errors.Wrap(&googleapi.Error{
+		Code:    http.StatusPreconditionFailed,
+		Message: "Constraint constraints/compute.trustedImageProjects violated for project my-project. Use of images from project other-project is prohibited.",
+	}, "failed to create gcp instance")
+	g.Expect(ViolatedConstraint(err)).To(Equal(TrustedImageProjectsConstraint))
+
+	err = errors.Wrap(&wait.OperationError{Codes: []string{"CONDITION_NOT_MET"}}, "failed to create gcp instance")
+	g.Expect(ViolatedConstraint(err)).To(BeEmpty())
+}
+
+func TestIsFingerprintMismatch(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(IsFingerprintMismatch(nil)).To(BeFalse())
+	g.Expect(IsFingerprintMismatch(&googleapi.Error{Code: http.StatusNotFound})).To(BeFalse())
+	g.Expect(IsFingerprintMismatch(&googleapi.Error{
+		Code:    http.StatusPreconditionFailed,
+		Message: "Constraint constraints/compute.requireOsLogin violated for project my-project.",
+	})).To(BeFalse())
+
+	err := errors.Wrap(&googleapi.Error{
+		Code:    http.StatusPreconditionFailed,
+		Message: "Labels fingerprint either invalid or resource labels have changed",
+	}, "failed to set instance labels")
+	g.Expect(IsFingerprintMismatch(err)).To(BeTrue())
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultHealthCheckInterval is the minimum interval between two calls to the GCP APIs
+	// made by the HealthChecker, the result of the last call is reported in between.
+	DefaultHealthCheckInterval = 30 * time.Second
+
+	healthCheckTimeout = 10 * time.Second
+)
+
+// HealthChecker verifies the configured credentials can call the compute API,
+// which also implies the API endpoint is reachable.
+type HealthChecker struct {
+	cloud    Cloud
+	project  string
+	interval time.Duration
+	now      func() time.Time
+
+	mu        sync.Mutex
+	lastCheck time.Time
+	lastErr   error
+}
+
+// NewHealthChecker returns a HealthChecker listing the regions of the given project.
+func NewHealthChecker(c Cloud, project string) *HealthChecker {
+	return &HealthChecker{
+		cloud:    c,
+		project:  project,
+		interval: DefaultHealthCheckInterval,
+		now:      time.Now,
+	}
+}
+
+// Check implements healthz.Checker.
+func (h *HealthChecker) Check(req *http.Request) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if !h.lastCheck.IsZero() && now.Sub(h.lastCheck) < h.interval {
+		return h.lastErr
+	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), healthCheckTimeout)
+	defer cancel()
+
+	h.lastCheck = now
+	h.lastErr = nil
+	if _, err := h.cloud.Compute().Regions.List(h.project).MaxResults(1).Fields("items/name").Context(ctx).Do(); err != nil {
+		h.lastErr = errors.Wrapf(err, "failed to call the compute API for project %s", h.project)
+	}
+
+	return h.lastErr
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestHealthChecker(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fake.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a")
+
+	now := time.Now()
+	checker := cloud.NewHealthChecker(c, "my-project")
+	checker.SetClock(func() time.Time { return now })
+	req := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+
+	g.Expect(checker.Check(req)).To(Succeed())
+
+	c.SetError(http.MethodGet, "projects/my-project/regions", &googleapi.Error{Code: http.StatusForbidden, Message: "permission denied"})
+	g.Expect(checker.Check(req)).To(Succeed(), "the last result is reported until the interval elapsed")
+
+	now = now.Add(cloud.DefaultHealthCheckInterval)
+	g.Expect(checker.Check(req)).To(MatchError(ContainSubstring("permission denied")))
+
+	c.SetError(http.MethodGet, "projects/my-project/regions", nil)
+	now = now.Add(cloud.DefaultHealthCheckInterval)
+	g.Expect(checker.Check(req)).To(Succeed())
+}
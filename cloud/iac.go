@@ -0,0 +1,136 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ExportFormat is the format the GCP resources of a cluster are exported in.
+type ExportFormat string
+
+const (
+	// TerraformExport renders Terraform import blocks, from which terraform plan -generate-config-out
+	// generates the configuration of the resources.
+	TerraformExport ExportFormat = "terraform"
+	// ConfigConnectorExport renders the config-connector export commands writing the Config Connector
+	// manifests of the resources.
+	ConfigConnectorExport ExportFormat = "config-connector"
+)
+
+// ParseExportFormat parses an ExportFormat.
+func ParseExportFormat(s string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.TrimSpace(s)); f {
+	case TerraformExport, ConfigConnectorExport:
+		return f, nil
+	}
+
+	return "", errors.Errorf("unknown export format %q, expected %s or %s", s, TerraformExport, ConfigConnectorExport)
+}
+
+// Key returns the name of the file the export is written to.
+func (f ExportFormat) Key() string {
+	if f == TerraformExport {
+		return "import.tf"
+	}
+
+	return "export.sh"
+}
+
+// terraformTypes are the Terraform resource types of the compute collections, by scope, global, regions or zones.
+var terraformTypes = map[string]map[string]string{
+	"global": {
+		"addresses":        "google_compute_global_address",
+		"backendServices":  "google_compute_backend_service",
+		"firewalls":        "google_compute_firewall",
+		"forwardingRules":  "google_compute_global_forwarding_rule",
+		"healthChecks":     "google_compute_health_check",
+		"networks":         "google_compute_network",
+		"routes":           "google_compute_route",
+		"targetTcpProxies": "google_compute_target_tcp_proxy",
+	},
+	"regions": {
+		"addresses":       "google_compute_address",
+		"backendServices": "google_compute_region_backend_service",
+		"forwardingRules": "google_compute_forwarding_rule",
+		"nodeTemplates":   "google_compute_node_template",
+		"routers":         "google_compute_router",
+		"subnetworks":     "google_compute_subnetwork",
+	},
+	"zones": {
+		"instanceGroups":        "google_compute_instance_group",
+		"instances":             "google_compute_instance",
+		"networkEndpointGroups": "google_compute_network_endpoint_group",
+		"nodeGroups":            "google_compute_node_group",
+		"reservations":          "google_compute_reservation",
+		"targetInstances":       "google_compute_target_instance",
+	},
+}
+
+// invalidTerraformName matches the characters not allowed in the names of the Terraform resources.
+var invalidTerraformName = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// Export renders the GCP resources of the project at the paths, e.g. global/firewalls/my-rule, in the format.
+// The resources of unknown collections are skipped.
+func Export(format ExportFormat, project string, resources []string) string {
+	resources = append([]string(nil), resources...)
+	sort.Strings(resources)
+
+	var b strings.Builder
+	switch format {
+	case TerraformExport:
+		for _, r := range resources {
+			parts := strings.Split(r, "/")
+			typ, ok := terraformType(parts)
+			if !ok {
+				continue
+			}
+			scope := parts[0]
+			// The names of the zonal resources are only unique within their zone.
+			name := parts[len(parts)-1]
+			if scope != "global" {
+				name = parts[1] + "_" + name
+			}
+			fmt.Fprintf(&b, "import {\n  to = %s.%s\n  id = %q\n}\n\n", typ, invalidTerraformName.ReplaceAllString(name, "_"), fmt.Sprintf("projects/%s/%s", project, r))
+		}
+	case ConfigConnectorExport:
+		b.WriteString("#!/bin/sh\nset -e\n")
+		for _, r := range resources {
+			if _, ok := terraformType(strings.Split(r, "/")); !ok {
+				continue
+			}
+			fmt.Fprintf(&b, "config-connector export //compute.googleapis.com/projects/%s/%s --output %q\n", project, r, strings.ReplaceAll(r, "/", "_")+".yaml")
+		}
+	}
+
+	return b.String()
+}
+
+// terraformType returns the Terraform resource type of the resource path split into its parts.
+func terraformType(parts []string) (string, bool) {
+	if len(parts) < 3 {
+		return "", false
+	}
+	typ, ok := terraformTypes[parts[0]][parts[len(parts)-2]]
+
+	return typ, ok
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestExport(t *testing.T) {
+	g := NewWithT(t)
+
+	_, err := cloud.ParseExportFormat("pulumi")
+	g.Expect(err).To(HaveOccurred())
+	format, err := cloud.ParseExportFormat("terraform")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(format.Key()).To(Equal("import.tf"))
+
+	resources := []string{
+		"zones/us-central1-a/instances/my-cluster-bastion",
+		"global/forwardingRules/my-cluster-apiserver",
+		"regions/us-central1/routers/my-cluster-router",
+		"global/unknown/my-cluster",
+		"invalid",
+	}
+	g.Expect(cloud.Export(format, "my-project", resources)).To(Equal(`import {
+  to = google_compute_global_forwarding_rule.my_cluster_apiserver
+  id = "projects/my-project/global/forwardingRules/my-cluster-apiserver"
+}
+
+import {
+  to = google_compute_router.us_central1_my_cluster_router
+  id = "projects/my-project/regions/us-central1/routers/my-cluster-router"
+}
+
+import {
+  to = google_compute_instance.us_central1_a_my_cluster_bastion
+  id = "projects/my-project/zones/us-central1-a/instances/my-cluster-bastion"
+}
+
+`))
+
+	g.Expect(cloud.Export(cloud.ConfigConnectorExport, "my-project", resources[:1])).To(Equal(`#!/bin/sh
+set -e
+config-connector export //compute.googleapis.com/projects/my-project/zones/us-central1-a/instances/my-cluster-bastion --output "zones_us-central1-a_instances_my-cluster-bastion.yaml"
+`))
+}
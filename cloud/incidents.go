@@ -0,0 +1,95 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultZoneIncidentWindow is the default duration a zone incident is considered recent for.
+const DefaultZoneIncidentWindow = 30 * time.Minute
+
+// ZoneIncidents tracks the recent incidents observed in the zones, e.g. the instance creations
+// failing because the zone ran out of resources, so that the controllers can steer away from them.
+// It is safe for concurrent use. A nil ZoneIncidents doesn't track anything.
+type ZoneIncidents struct {
+	mu        sync.Mutex
+	window    time.Duration
+	now       func() time.Time
+	incidents map[string]zoneIncident
+	last      time.Time
+}
+
+type zoneIncident struct {
+	reason string
+	at     time.Time
+}
+
+// NewZoneIncidents returns a ZoneIncidents forgetting the incidents after the given window.
+func NewZoneIncidents(window time.Duration) *ZoneIncidents {
+	return &ZoneIncidents{
+		window:    window,
+		now:       time.Now,
+		incidents: make(map[string]zoneIncident),
+	}
+}
+
+// Record records an incident in the zone of the project.
+func (z *ZoneIncidents) Record(project, zone, reason string) {
+	if z == nil {
+		return
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	z.last = z.now()
+	z.incidents[project+"/"+zone] = zoneIncident{reason: reason, at: z.last}
+}
+
+// Last returns the time the last incident was recorded, in any zone, zero if none was recorded.
+func (z *ZoneIncidents) Last() time.Time {
+	if z == nil {
+		return time.Time{}
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	return z.last
+}
+
+// Recent returns the reason of the last incident recorded in the zone of the project,
+// if it happened within the window.
+func (z *ZoneIncidents) Recent(project, zone string) (string, bool) {
+	if z == nil {
+		return "", false
+	}
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	key := project + "/" + zone
+	incident, ok := z.incidents[key]
+	if !ok {
+		return "", false
+	}
+	if z.now().Sub(incident.at) > z.window {
+		delete(z.incidents, key)
+		return "", false
+	}
+
+	return incident.reason, true
+}
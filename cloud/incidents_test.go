@@ -0,0 +1,56 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestZoneIncidents(t *testing.T) {
+	g := NewWithT(t)
+
+	now := time.Now()
+	z := cloud.NewZoneIncidents(10 * time.Minute)
+	z.SetClock(func() time.Time { return now })
+
+	_, ok := z.Recent("my-project", "us-central1-a")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(z.Last().IsZero()).To(BeTrue())
+
+	z.Record("my-project", "us-central1-a", "ZONE_RESOURCE_POOL_EXHAUSTED")
+	reason, ok := z.Recent("my-project", "us-central1-a")
+	g.Expect(ok).To(BeTrue())
+	g.Expect(reason).To(Equal("ZONE_RESOURCE_POOL_EXHAUSTED"))
+	g.Expect(z.Last()).To(Equal(now))
+	_, ok = z.Recent("other-project", "us-central1-a")
+	g.Expect(ok).To(BeFalse())
+
+	now = now.Add(11 * time.Minute)
+	_, ok = z.Recent("my-project", "us-central1-a")
+	g.Expect(ok).To(BeFalse())
+
+	var disabled *cloud.ZoneIncidents
+	disabled.Record("my-project", "us-central1-a", "ZONE_RESOURCE_POOL_EXHAUSTED")
+	_, ok = disabled.Recent("my-project", "us-central1-a")
+	g.Expect(ok).To(BeFalse())
+	g.Expect(disabled.Last().IsZero()).To(BeTrue())
+}
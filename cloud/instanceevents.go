@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/option"
+	"google.golang.org/api/pubsub/v1"
+)
+
+// The reasons of the instance events.
+const (
+	// InstanceEventPreempted is the reason of the event of a preempted instance.
+	InstanceEventPreempted = "Preempted"
+	// InstanceEventHostMaintenance is the reason of the event of an instance terminated because of a
+	// maintenance of its host. The live migrations are transparent and not reported.
+	InstanceEventHostMaintenance = "HostMaintenance"
+	// InstanceEventHostError is the reason of the event of an instance restarted after a failure of its host.
+	InstanceEventHostError = "HostError"
+	// InstanceEventAutohealing is the reason of the event of an instance recreated by the autohealing
+	// of its managed instance group.
+	InstanceEventAutohealing = "Autohealing"
+)
+
+// instanceEventReasons are the reasons of the instance events by method name of their audit log.
+var instanceEventReasons = map[string]string{
+	"compute.instances.preempted":                  InstanceEventPreempted,
+	"compute.instances.terminateOnHostMaintenance": InstanceEventHostMaintenance,
+	"compute.instances.hostError":                  InstanceEventHostError,
+	"compute.instances.repair.recreateInstance":    InstanceEventAutohealing,
+}
+
+// InstanceEvent is a disruption of a GCE instance, e.g. a preemption.
+type InstanceEvent struct {
+	// Reason is the reason of the event, e.g. InstanceEventPreempted.
+	Reason string
+
+	// Project, Zone and Instance identify the instance.
+	Project  string
+	Zone     string
+	Instance string
+
+	// Time is the time of the event.
+	Time time.Time
+}
+
+// ParseInstanceEvent parses an audit log entry of Compute Engine, as routed to Pub/Sub by a log sink.
+// It returns nil if the entry isn't an instance event.
+func ParseInstanceEvent(data []byte) (*InstanceEvent, error) {
+	var entry struct {
+		ProtoPayload struct {
+			MethodName   string `json:"methodName"`
+			ResourceName string `json:"resourceName"`
+		} `json:"protoPayload"`
+		Timestamp time.Time `json:"timestamp"`
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, errors.Wrap(err, "failed to parse log entry")
+	}
+
+	reason, ok := instanceEventReasons[entry.ProtoPayload.MethodName]
+	if !ok {
+		return nil, nil
+	}
+
+	// The resource name has the format projects/<project>/zones/<zone>/instances/<name>.
+	parts := strings.Split(entry.ProtoPayload.ResourceName, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "zones" || parts[4] != "instances" {
+		return nil, errors.Errorf("unexpected resource name %q of %s log entry", entry.ProtoPayload.ResourceName, entry.ProtoPayload.MethodName)
+	}
+
+	return &InstanceEvent{
+		Reason:   reason,
+		Project:  parts[1],
+		Zone:     parts[3],
+		Instance: parts[5],
+		Time:     entry.Timestamp,
+	}, nil
+}
+
+// InstanceEventReceiver receives the instance events.
+type InstanceEventReceiver interface {
+	// Receive calls the handler with the received instance events until the context is done or the
+	// events can't be received. An event is received again later if the handler returns an error.
+	Receive(ctx context.Context, handler func(context.Context, InstanceEvent) error) error
+}
+
+type pubsubInstanceEventReceiver struct {
+	pubsub       *pubsub.Service
+	subscription string
+}
+
+// NewPubSubInstanceEventReceiver returns an InstanceEventReceiver pulling the instance events from a
+// Pub/Sub subscription, in the projects/<project>/subscriptions/<name> format. The topic of the subscription
+// is expected to be the destination of a log sink routing the audit logs of Compute Engine, e.g. with the
+// filter protoPayload.methodName=("compute.instances.preempted" OR "compute.instances.hostError").
+func NewPubSubInstanceEventReceiver(ctx context.Context, subscription string, opts ...option.ClientOption) (InstanceEventReceiver, error) {
+	svc, err := pubsub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp pubsub client: %v", err)
+	}
+
+	return &pubsubInstanceEventReceiver{
+		pubsub:       svc,
+		subscription: subscription,
+	}, nil
+}
+
+// Receive calls the handler with the instance events pulled from the subscription.
+// The messages which aren't instance events are acknowledged and dropped.
+func (r *pubsubInstanceEventReceiver) Receive(ctx context.Context, handler func(context.Context, InstanceEvent) error) error {
+	for ctx.Err() == nil {
+		res, err := r.pubsub.Projects.Subscriptions.Pull(r.subscription, &pubsub.PullRequest{MaxMessages: 100}).Context(ctx).Do()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to pull messages from %s", r.subscription)
+		}
+
+		ackIds := make([]string, 0, len(res.ReceivedMessages))
+		for _, m := range res.ReceivedMessages {
+			if handleMessage(ctx, m.Message, handler) {
+				ackIds = append(ackIds, m.AckId)
+			}
+		}
+		if len(ackIds) == 0 {
+			continue
+		}
+		if _, err := r.pubsub.Projects.Subscriptions.Acknowledge(r.subscription, &pubsub.AcknowledgeRequest{AckIds: ackIds}).Context(ctx).Do(); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return errors.Wrapf(err, "failed to acknowledge messages of %s", r.subscription)
+		}
+	}
+
+	return nil
+}
+
+// handleMessage returns true if the message has been handled and can be acknowledged.
+func handleMessage(ctx context.Context, m *pubsub.PubsubMessage, handler func(context.Context, InstanceEvent) error) bool {
+	if m == nil {
+		return true
+	}
+	data, err := base64.StdEncoding.DecodeString(m.Data)
+	if err != nil {
+		return true
+	}
+	event, err := ParseInstanceEvent(data)
+	if err != nil || event == nil {
+		return true
+	}
+
+	return handler(ctx, *event) == nil
+}
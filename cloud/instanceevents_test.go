@@ -0,0 +1,125 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/option"
+	"google.golang.org/api/pubsub/v1"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+const preemptedLogEntry = `{
+  "protoPayload": {
+    "methodName": "compute.instances.preempted",
+    "resourceName": "projects/my-project/zones/us-central1-a/instances/my-instance"
+  },
+  "timestamp": "2021-07-01T10:00:00Z"
+}`
+
+func TestParseInstanceEvent(t *testing.T) {
+	g := NewWithT(t)
+
+	event, err := cloud.ParseInstanceEvent([]byte(preemptedLogEntry))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(event).To(Equal(&cloud.InstanceEvent{
+		Reason:   cloud.InstanceEventPreempted,
+		Project:  "my-project",
+		Zone:     "us-central1-a",
+		Instance: "my-instance",
+		Time:     time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC),
+	}))
+
+	event, err = cloud.ParseInstanceEvent([]byte(`{"protoPayload": {"methodName": "v1.compute.instances.insert"}}`))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(event).To(BeNil())
+
+	_, err = cloud.ParseInstanceEvent([]byte(`{"protoPayload": {"methodName": "compute.instances.hostError", "resourceName": "my-instance"}}`))
+	g.Expect(err).To(HaveOccurred())
+
+	_, err = cloud.ParseInstanceEvent([]byte(`not json`))
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestPubSubInstanceEventReceiver(t *testing.T) {
+	g := NewWithT(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var mu sync.Mutex
+	var acked []string
+	pulled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case strings.HasSuffix(r.URL.Path, ":pull"):
+			res := &pubsub.PullResponse{}
+			if !pulled {
+				pulled = true
+				res.ReceivedMessages = []*pubsub.ReceivedMessage{
+					{AckId: "1", Message: &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString([]byte(preemptedLogEntry))}},
+					{AckId: "2", Message: &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString([]byte(`{}`))}},
+					{AckId: "3", Message: &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString([]byte(
+						strings.Replace(preemptedLogEntry, "my-instance", "other-instance", 1)))}},
+				}
+			}
+			_ = json.NewEncoder(w).Encode(res)
+		case strings.HasSuffix(r.URL.Path, ":acknowledge"):
+			req := &pubsub.AcknowledgeRequest{}
+			_ = json.NewDecoder(r.Body).Decode(req)
+			acked = append(acked, req.AckIds...)
+			_, _ = w.Write([]byte("{}"))
+			cancel()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	receiver, err := cloud.NewPubSubInstanceEventReceiver(context.Background(), "projects/my-project/subscriptions/my-subscription",
+		option.WithEndpoint(server.URL),
+		option.WithHTTPClient(server.Client()),
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	var events []cloud.InstanceEvent
+	err = receiver.Receive(ctx, func(_ context.Context, event cloud.InstanceEvent) error {
+		events = append(events, event)
+		if event.Instance == "other-instance" {
+			return context.DeadlineExceeded
+		}
+		return nil
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The message which failed to be handled isn't acknowledged.
+	g.Expect(events).To(HaveLen(2))
+	g.Expect(acked).To(ConsistOf("1", "2"))
+}
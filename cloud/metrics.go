@@ -0,0 +1,141 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+)
+
+const (
+	// MetricTypePrefix is the prefix of the types of the custom metrics published to Cloud Monitoring.
+	MetricTypePrefix = "custom.googleapis.com/cluster-api-provider-gcp/"
+
+	// DefaultMetricsExportInterval is the minimum interval between two points of a metric published to
+	// Cloud Monitoring, which rejects the points of a time series written more often than every 5 seconds.
+	DefaultMetricsExportInterval = time.Minute
+)
+
+// MetricPoint is the current value of a gauge metric, e.g. the number of failed machines of a cluster.
+type MetricPoint struct {
+	// Type is the type of the metric, relative to the MetricTypePrefix.
+	Type string
+
+	// Labels are the labels of the time series of the point.
+	Labels map[string]string
+
+	// Value is the value of the gauge.
+	Value int64
+}
+
+// MetricsExporter publishes the metrics of the GCP infrastructure so that GCP alerting can watch it.
+type MetricsExporter interface {
+	// Export writes the points to the time series of the project.
+	Export(ctx context.Context, project string, points []MetricPoint) error
+}
+
+type monitoringExporter struct {
+	monitoring *monitoring.Service
+	interval   time.Duration
+	now        func() time.Time
+
+	mu      sync.Mutex
+	written map[string]time.Time
+}
+
+// NewMonitoringExporter returns a MetricsExporter publishing custom metrics to Cloud Monitoring.
+// The points of a time series are written at most once per DefaultMetricsExportInterval, the points
+// exported in between are dropped.
+func NewMonitoringExporter(ctx context.Context, opts ...option.ClientOption) (MetricsExporter, error) {
+	svc, err := monitoring.NewService(ctx, opts...)
+	if err != nil {
+		return nil, errors.Errorf("failed to create gcp monitoring client: %v", err)
+	}
+
+	return &monitoringExporter{
+		monitoring: svc,
+		interval:   DefaultMetricsExportInterval,
+		now:        time.Now,
+		written:    make(map[string]time.Time),
+	}, nil
+}
+
+// Export writes the points to the time series of the project.
+func (e *monitoringExporter) Export(ctx context.Context, project string, points []MetricPoint) error {
+	now := e.now()
+	series := make([]*monitoring.TimeSeries, 0, len(points))
+
+	e.mu.Lock()
+	for _, p := range points {
+		key := seriesKey(project, p)
+		if last, ok := e.written[key]; ok && now.Sub(last) < e.interval {
+			continue
+		}
+		e.written[key] = now
+		series = append(series, &monitoring.TimeSeries{
+			Metric: &monitoring.Metric{
+				Type:   MetricTypePrefix + p.Type,
+				Labels: p.Labels,
+			},
+			Resource: &monitoring.MonitoredResource{
+				Type:   "global",
+				Labels: map[string]string{"project_id": project},
+			},
+			MetricKind: "GAUGE",
+			ValueType:  "INT64",
+			Points: []*monitoring.Point{{
+				Interval: &monitoring.TimeInterval{EndTime: now.UTC().Format(time.RFC3339)},
+				Value:    &monitoring.TypedValue{Int64Value: &p.Value},
+			}},
+		})
+	}
+	e.mu.Unlock()
+
+	// Cloud Monitoring accepts up to 200 time series per request.
+	for len(series) > 0 {
+		n := len(series)
+		if n > 200 {
+			n = 200
+		}
+		req := &monitoring.CreateTimeSeriesRequest{TimeSeries: series[:n]}
+		if _, err := e.monitoring.Projects.TimeSeries.Create("projects/"+project, req).Context(ctx).Do(); err != nil {
+			return errors.Wrapf(err, "failed to write metrics to project %s", project)
+		}
+		series = series[n:]
+	}
+
+	return nil
+}
+
+// seriesKey identifies the time series of the point in the project.
+func seriesKey(project string, p MetricPoint) string {
+	labels := make([]string, 0, len(p.Labels))
+	for k, v := range p.Labels {
+		labels = append(labels, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(labels)
+
+	return project + "/" + p.Type + "{" + strings.Join(labels, ",") + "}"
+}
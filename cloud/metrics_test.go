@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/monitoring/v3"
+	"google.golang.org/api/option"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestMonitoringExporter(t *testing.T) {
+	g := NewWithT(t)
+
+	var mu sync.Mutex
+	var paths []string
+	var series []*monitoring.TimeSeries
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &monitoring.CreateTimeSeriesRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		series = append(series, req.TimeSeries...)
+		mu.Unlock()
+		_, _ = w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	exporter, err := cloud.NewMonitoringExporter(context.Background(),
+		option.WithEndpoint(server.URL),
+		option.WithHTTPClient(server.Client()),
+	)
+	g.Expect(err).NotTo(HaveOccurred())
+	now := time.Now()
+	cloud.SetExporterClock(exporter, func() time.Time { return now })
+
+	labels := map[string]string{"cluster": "my-cluster", "namespace": "default"}
+	points := []cloud.MetricPoint{
+		{Type: "cluster/ready", Labels: labels, Value: 1},
+		{Type: "cluster/machines_failed", Labels: labels, Value: 2},
+	}
+	g.Expect(exporter.Export(context.Background(), "my-project", points)).To(Succeed())
+	g.Expect(paths).To(ConsistOf("/v3/projects/my-project/timeSeries"))
+	g.Expect(series).To(HaveLen(2))
+	g.Expect(series[0].Metric.Type).To(Equal(cloud.MetricTypePrefix + "cluster/ready"))
+	g.Expect(series[0].Metric.Labels).To(Equal(labels))
+	g.Expect(series[0].Resource.Labels).To(HaveKeyWithValue("project_id", "my-project"))
+	g.Expect(*series[1].Points[0].Value.Int64Value).To(BeEquivalentTo(2))
+
+	// The points of the same time series are dropped until the interval elapsed.
+	other := map[string]string{"cluster": "other-cluster", "namespace": "default"}
+	g.Expect(exporter.Export(context.Background(), "my-project", append(points, cloud.MetricPoint{Type: "cluster/ready", Labels: other}))).To(Succeed())
+	g.Expect(series).To(HaveLen(3))
+	g.Expect(series[2].Metric.Labels).To(Equal(other))
+
+	now = now.Add(cloud.DefaultMetricsExportInterval)
+	g.Expect(exporter.Export(context.Background(), "my-project", points)).To(Succeed())
+	g.Expect(series).To(HaveLen(5))
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package names implements the naming of GCP resources.
+package names
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MaxLength is the maximum length of the name of a GCP resource.
+	MaxLength = 63
+
+	// hashLength is the length of the hash suffixing the truncated names.
+	hashLength = 8
+)
+
+// InstanceData is the data the instance naming templates are rendered with.
+type InstanceData struct {
+	// ClusterName is the name of the Cluster.
+	ClusterName string
+	// Name is the name of the GCPMachine.
+	Name string
+	// Namespace is the namespace of the GCPMachine.
+	Namespace string
+	// Role is the role of the machine, "control-plane" or "node".
+	Role string
+}
+
+// InstanceReference locates an existing instance.
+type InstanceReference struct {
+	// Project is the project of the instance.
+	Project string
+	// Zone is the zone of the instance.
+	Zone string
+	// Name is the name of the instance.
+	Name string
+}
+
+var (
+	invalidCharsRegexp = regexp.MustCompile(`[^a-z0-9-]+`)
+	validNameRegexp    = regexp.MustCompile(`^[a-z]([-a-z0-9]*[a-z0-9])?$`)
+	providerIDRegexp   = regexp.MustCompile(`^gce://([^/]+)/([^/]+)/([^/]+)$`)
+	selfLinkRegexp     = regexp.MustCompile(`^(?:.*/)?projects/([^/]+)/zones/([^/]+)/instances/([^/]+)$`)
+)
+
+// Truncate returns the name if it fits in MaxLength characters. Otherwise the name is truncated
+// and suffixed with a hash of the full name, so that names sharing a long prefix remain distinct,
+// and the same name is always truncated the same way.
+func Truncate(name string) string {
+	return TruncateTo(name, MaxLength)
+}
+
+// TruncateTo truncates the name as Truncate does, to length characters, leaving room for a suffix.
+func TruncateTo(name string, length int) string {
+	if len(name) <= length {
+		return name
+	}
+
+	sum := sha256.Sum256([]byte(name))
+	prefix := strings.TrimRight(name[:length-hashLength-1], "-")
+
+	return prefix + "-" + hex.EncodeToString(sum[:])[:hashLength]
+}
+
+// Format renders the naming template with the data, e.g. "{{ .ClusterName }}-{{ .Name }}",
+// and turns the result into a valid GCP resource name: the invalid characters are replaced
+// with dashes and the name is truncated to MaxLength characters.
+func Format(tmpl string, data interface{}) (string, error) {
+	t, err := template.New("name").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to parse naming template %q", tmpl)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", errors.Wrapf(err, "failed to render naming template %q", tmpl)
+	}
+
+	name := invalidCharsRegexp.ReplaceAllString(strings.ToLower(buf.String()), "-")
+	name = Truncate(strings.Trim(name, "-"))
+	if !validNameRegexp.MatchString(name) {
+		return "", errors.Errorf("naming template %q renders the invalid name %q, names must start with a letter", tmpl, name)
+	}
+
+	return name, nil
+}
+
+// ParseInstanceReference parses the providerID of an instance, e.g. gce://my-project/us-central1-a/my-instance,
+// or its self link, complete or relative to the compute API, e.g. projects/my-project/zones/us-central1-a/instances/my-instance.
+func ParseInstanceReference(ref string) (InstanceReference, error) {
+	m := providerIDRegexp.FindStringSubmatch(ref)
+	if m == nil {
+		m = selfLinkRegexp.FindStringSubmatch(ref)
+	}
+	if m == nil {
+		return InstanceReference{}, errors.Errorf("%q is neither the providerID nor the self link of an instance", ref)
+	}
+
+	return InstanceReference{Project: m[1], Zone: m[2], Name: m[3]}, nil
+}
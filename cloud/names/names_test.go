@@ -0,0 +1,91 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package names
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestTruncate(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(Truncate("my-cluster-apiserver")).To(Equal("my-cluster-apiserver"))
+
+	long := strings.Repeat("a", 60) + "-apiserver-us-central1-a"
+	truncated := Truncate(long)
+	g.Expect(truncated).To(HaveLen(MaxLength))
+	g.Expect(truncated).To(HavePrefix(strings.Repeat("a", 54) + "-"))
+	g.Expect(Truncate(long)).To(Equal(truncated))
+	g.Expect(Truncate(strings.Repeat("a", 60) + "-apiserver-us-central1-b")).NotTo(Equal(truncated))
+}
+
+func TestTruncateTo(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(TruncateTo("my-cluster-my-pool", 51)).To(Equal("my-cluster-my-pool"))
+
+	truncated := TruncateTo(strings.Repeat("a", 60), 51)
+	g.Expect(truncated).To(HaveLen(51))
+	g.Expect(truncated).To(HavePrefix(strings.Repeat("a", 42) + "-"))
+}
+
+func TestFormat(t *testing.T) {
+	g := NewWithT(t)
+
+	data := map[string]string{"ClusterName": "My.Cluster", "Name": "md-0-abcde"}
+	name, err := Format("{{ .ClusterName }}-{{ .Name }}", data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(name).To(Equal("my-cluster-md-0-abcde"))
+
+	name, err = Format("{{ .ClusterName }}-"+strings.Repeat("x", 70), data)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(name).To(HaveLen(MaxLength))
+
+	_, err = Format("{{ .ClusterName", data)
+	g.Expect(err).To(HaveOccurred())
+	_, err = Format("{{ .Unknown }}", data)
+	g.Expect(err).To(HaveOccurred())
+	_, err = Format("0-{{ .Name }}", data)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestParseInstanceReference(t *testing.T) {
+	g := NewWithT(t)
+
+	want := InstanceReference{Project: "my-project", Zone: "us-central1-a", Name: "my-instance"}
+	for _, ref := range []string{
+		"gce://my-project/us-central1-a/my-instance",
+		"https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instances/my-instance",
+		"projects/my-project/zones/us-central1-a/instances/my-instance",
+	} {
+		got, err := ParseInstanceReference(ref)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(got).To(Equal(want))
+	}
+
+	for _, ref := range []string{
+		"my-instance",
+		"gce://my-project/my-instance",
+		"projects/my-project/global/instanceTemplates/my-template",
+	} {
+		_, err := ParseInstanceReference(ref)
+		g.Expect(err).To(HaveOccurred())
+	}
+}
@@ -0,0 +1,358 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// DefaultMaxRetries is the default number of times a throttled API call is retried.
+	DefaultMaxRetries = 3
+
+	// minRetryDelay and maxRetryDelay bound the exponential backoff of the throttled calls,
+	// and the delay requested by the APIs with the Retry-After header.
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+
+	// maxErrorBodySize is the size of the error responses read to tell whether a call was throttled.
+	maxErrorBodySize = 64 << 10
+)
+
+var (
+	apiCalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "capg_gcp_api_calls_total",
+		Help: "Number of GCP API calls, by service, operation and HTTP status code.",
+	}, []string{"service", "operation", "code"})
+
+	apiCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "capg_gcp_api_call_duration_seconds",
+		Help:    "Latency of the GCP API calls, by service and operation.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"service", "operation"})
+
+	apiThrottled = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "capg_gcp_api_throttled_total",
+		Help: "Number of GCP API calls rejected because a rate quota was exceeded, by service and operation.",
+	}, []string{"service", "operation"})
+
+	apiRateLimiterWait = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "capg_gcp_api_rate_limiter_wait_seconds",
+		Help:    "Time the GCP API calls waited for the client-side rate limiter, by service.",
+		Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 10, 30},
+	}, []string{"service"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(apiCalls, apiCallDuration, apiThrottled, apiRateLimiterWait)
+}
+
+// RateLimit is the rate of the calls to a GCP API allowed by the RateLimiter.
+type RateLimit struct {
+	// QPS is the sustained number of calls per second, zero for no limit.
+	QPS float64
+	// Burst is the number of calls which can be made at once, defaults to the QPS.
+	Burst int
+}
+
+// ParseRateLimits parses a comma separated list of rate limits per service, e.g. "compute=20:40,storage=5",
+// in the <service>=<qps>[:<burst>] format. The services are the names of the GCP APIs, e.g. compute, dns,
+// servicenetworking, container or storage.
+func ParseRateLimits(spec string) (map[string]RateLimit, error) {
+	res := map[string]RateLimit{}
+	if spec == "" {
+		return res, nil
+	}
+
+	for _, kv := range strings.Split(spec, ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid rate limit %q, expected <service>=<qps>[:<burst>]", kv)
+		}
+		qps, burst := parts[1], ""
+		if i := strings.Index(qps, ":"); i >= 0 {
+			qps, burst = qps[:i], qps[i+1:]
+		}
+		var limit RateLimit
+		var err error
+		if limit.QPS, err = strconv.ParseFloat(qps, 64); err != nil || limit.QPS < 0 {
+			return nil, errors.Errorf("invalid qps %q of service %q, expected a positive number", qps, parts[0])
+		}
+		if burst != "" {
+			if limit.Burst, err = strconv.Atoi(burst); err != nil || limit.Burst < 1 {
+				return nil, errors.Errorf("invalid burst %q of service %q, expected a positive integer", burst, parts[0])
+			}
+		}
+		res[parts[0]] = limit
+	}
+
+	return res, nil
+}
+
+// RateLimiter limits the rate of the GCP API calls of the process, per service, and retries the calls
+// rejected because a rate quota of the project was exceeded, after the delay requested by the API or
+// with an exponential backoff. It records the Prometheus metrics of the calls.
+type RateLimiter struct {
+	// Default is the rate limit of the services without their own.
+	Default RateLimit
+	// Services are the rate limits by service, e.g. compute.
+	Services map[string]RateLimit
+	// MaxRetries is the number of times a throttled call is retried.
+	MaxRetries int
+
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter
+	// sleep waits for the delay, or until the context is done.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Wrap is a WrapTransportFunc limiting the rate of the API calls.
+func (r *RateLimiter) Wrap(base http.RoundTripper) http.RoundTripper {
+	return &rateLimitTransport{limiter: r, base: base}
+}
+
+// limiter returns the limiter of the service, nil if its calls aren't limited.
+func (r *RateLimiter) limiter(service string) *rate.Limiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if l, ok := r.limiters[service]; ok {
+		return l
+	}
+	limit, ok := r.Services[service]
+	if !ok {
+		limit = r.Default
+	}
+	var l *rate.Limiter
+	if limit.QPS > 0 {
+		burst := limit.Burst
+		if burst < 1 {
+			burst = int(limit.QPS + 0.5)
+		}
+		if burst < 1 {
+			burst = 1
+		}
+		l = rate.NewLimiter(rate.Limit(limit.QPS), burst)
+	}
+	if r.limiters == nil {
+		r.limiters = map[string]*rate.Limiter{}
+	}
+	r.limiters[service] = l
+
+	return l
+}
+
+// wait waits for the delay before retrying a throttled call.
+func (r *RateLimiter) wait(ctx context.Context, d time.Duration) error {
+	if r.sleep != nil {
+		return r.sleep(ctx, d)
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+type rateLimitTransport struct {
+	limiter *RateLimiter
+	base    http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	service, operation := apiMethod(req)
+	limiter := t.limiter.limiter(service)
+	// The requests whose body can't be read again aren't retried, e.g. the media uploads.
+	retryable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		if limiter != nil {
+			start := time.Now()
+			if err := limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+			apiRateLimiterWait.WithLabelValues(service).Observe(time.Since(start).Seconds())
+		}
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		start := time.Now()
+		resp, err := t.base.RoundTrip(req)
+		apiCallDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+		if err != nil {
+			apiCalls.WithLabelValues(service, operation, "error").Inc()
+			return nil, err
+		}
+		apiCalls.WithLabelValues(service, operation, strconv.Itoa(resp.StatusCode)).Inc()
+
+		if !isThrottled(resp) {
+			return resp, nil
+		}
+		apiThrottled.WithLabelValues(service, operation).Inc()
+		if attempt >= t.limiter.MaxRetries || !retryable {
+			return resp, nil
+		}
+
+		delay := retryDelay(resp, attempt)
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if err := t.limiter.wait(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isThrottled returns true if the call was rejected because a rate quota was exceeded: with the 429 status
+// code, or the 403 one and the rateLimitExceeded, userRateLimitExceeded or quotaExceeded reasons. The body of
+// a 403 response is read to tell, and restored.
+func isThrottled(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusForbidden:
+	default:
+		return false
+	}
+
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(data), resp.Body), resp.Body}
+	// The read error is left to the caller of the transport, reading the rest of the body.
+	if err != nil {
+		return false
+	}
+	for _, reason := range []string{"rateLimitExceeded", "userRateLimitExceeded", "quotaExceeded"} {
+		if bytes.Contains(data, []byte(`"`+reason+`"`)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// retryDelay returns the delay before retrying the throttled call: the delay requested by its Retry-After
+// header, or an exponential backoff of the attempts, between minRetryDelay and maxRetryDelay.
+func retryDelay(resp *http.Response, attempt int) time.Duration {
+	delay := minRetryDelay << uint(attempt)
+	if after := resp.Header.Get("Retry-After"); after != "" {
+		if seconds, err := strconv.Atoi(after); err == nil {
+			delay = time.Duration(seconds) * time.Second
+		} else if at, err := http.ParseTime(after); err == nil {
+			delay = time.Until(at)
+		}
+	}
+	switch {
+	case delay < minRetryDelay:
+		return minRetryDelay
+	case delay > maxRetryDelay:
+		return maxRetryDelay
+	}
+
+	return delay
+}
+
+// apiMethod returns the service and the operation of the API call, e.g. compute and instances.setLabels for
+// a POST to /compute/v1/projects/my-project/zones/us-central1-a/instances/my-instance/setLabels. The names of
+// the resources are left out to keep the cardinality of the metrics low.
+func apiMethod(req *http.Request) (string, string) {
+	parts := strings.Split(strings.Trim(req.URL.Path, "/"), "/")
+	if len(parts) > 0 && (parts[0] == "upload" || parts[0] == "batch") {
+		parts = parts[1:]
+	}
+
+	// The path starts with the service, e.g. /compute/v1, unless the host is the one of the service,
+	// e.g. container.googleapis.com/v1 or compute.us-central1.rep.googleapis.com/compute/v1.
+	service := strings.Split(req.URL.Hostname(), ".")[0]
+	if len(parts) > 1 && !isAPIVersion(parts[0]) && isAPIVersion(parts[1]) {
+		service, parts = parts[0], parts[2:]
+	} else if len(parts) > 0 && isAPIVersion(parts[0]) {
+		parts = parts[1:]
+	}
+
+	var collections []string
+	action := ""
+	for i := 0; i < len(parts); i++ {
+		switch {
+		case parts[i] == "projects" && i+1 < len(parts),
+			(parts[i] == "zones" || parts[i] == "regions" || parts[i] == "locations") && i+2 < len(parts):
+			i++
+			continue
+		case parts[i] == "global" || parts[i] == "aggregated":
+			continue
+		}
+		collections = append(collections, parts[i])
+		if i+1 < len(parts) {
+			i++
+			// The custom methods of some APIs follow the name of the resource, e.g. my-pool:setSize.
+			if j := strings.LastIndex(parts[i], ":"); j >= 0 {
+				action = parts[i][j+1:]
+			} else if i+1 == len(parts) {
+				action = map[string]string{
+					http.MethodGet:    "get",
+					http.MethodDelete: "delete",
+					http.MethodPatch:  "patch",
+					http.MethodPut:    "update",
+					http.MethodPost:   "post",
+				}[req.Method]
+			}
+		} else if len(collections) > 1 && req.Method != http.MethodGet {
+			// The custom methods of the other APIs are a last path element, e.g. setLabels.
+			action = collections[len(collections)-1]
+			collections = collections[:len(collections)-1]
+		} else if req.Method == http.MethodPost {
+			action = "insert"
+		} else {
+			action = "list"
+		}
+	}
+	if len(collections) == 0 {
+		return service, "unknown"
+	}
+
+	return service, strings.Join(collections, ".") + "." + action
+}
+
+// isAPIVersion returns true if the path element is the version of an API, e.g. v1 or v1beta1.
+func isAPIVersion(s string) bool {
+	return len(s) > 1 && s[0] == 'v' && s[1] >= '0' && s[1] <= '9'
+}
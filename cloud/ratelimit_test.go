@@ -0,0 +1,144 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cloud_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	g := NewWithT(t)
+
+	limits, err := cloud.ParseRateLimits("")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(limits).To(BeEmpty())
+
+	limits, err = cloud.ParseRateLimits("compute=20:40, dns=0.5")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(limits).To(Equal(map[string]cloud.RateLimit{
+		"compute": {QPS: 20, Burst: 40},
+		"dns":     {QPS: 0.5},
+	}))
+
+	for _, spec := range []string{"compute", "=20", "compute=x", "compute=-1", "compute=20:0", "compute=20:x"} {
+		_, err := cloud.ParseRateLimits(spec)
+		g.Expect(err).To(HaveOccurred(), spec)
+	}
+}
+
+// throttleTransport rejects the first calls with a 429 and the Retry-After header.
+type throttleTransport struct {
+	base       http.RoundTripper
+	throttled  int
+	retryAfter string
+	calls      int
+}
+
+func (t *throttleTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls++
+	if t.calls > t.throttled {
+		return t.base.RoundTrip(req)
+	}
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"code":429,"message":"Rate Limit Exceeded","errors":[{"reason":"rateLimitExceeded"}]}}`)),
+		Request:    req,
+	}
+	if t.retryAfter != "" {
+		resp.Header.Set("Retry-After", t.retryAfter)
+	}
+	return resp, nil
+}
+
+func TestRateLimiter(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	var delays []time.Duration
+	limiter := &cloud.RateLimiter{Default: cloud.RateLimit{QPS: 1000}, MaxRetries: 2}
+	limiter.SetSleep(func(ctx context.Context, d time.Duration) error {
+		delays = append(delays, d)
+		return nil
+	})
+	throttle := &throttleTransport{throttled: 1, retryAfter: "5"}
+	throttled, err := c.WithTransport(context.TODO(), func(base http.RoundTripper) http.RoundTripper {
+		throttle.base = base
+		return limiter.Wrap(throttle)
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	svc := throttled.Compute()
+
+	// The throttled insert is sent again with its body after the Retry-After delay.
+	_, err = svc.Networks.Insert("my-project", &compute.Network{Name: "my-network"}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(throttle.calls).To(Equal(2))
+	g.Expect(delays).To(Equal([]time.Duration{5 * time.Second}))
+	g.Expect(c.Get("projects/my-project/global/networks/my-network", nil)).To(BeTrue())
+
+	// The calls throttled without a Retry-After header are retried with an exponential backoff, and
+	// the last rejection is returned once the retries are spent.
+	throttle.calls, throttle.throttled, throttle.retryAfter, delays = 0, 10, "", nil
+	_, err = svc.Networks.Get("my-project", "my-network").Do()
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(err.(*googleapi.Error).Code).To(Equal(http.StatusTooManyRequests))
+	g.Expect(throttle.calls).To(Equal(3))
+	g.Expect(delays).To(Equal([]time.Duration{time.Second, 2 * time.Second}))
+}
+
+func TestAPIMethod(t *testing.T) {
+	tests := []struct {
+		method, url        string
+		service, operation string
+	}{
+		{http.MethodGet, "https://compute.googleapis.com/compute/v1/projects/p/zones/z/instances/i", "compute", "instances.get"},
+		{http.MethodGet, "https://compute.googleapis.com/compute/v1/projects/p/zones/z/instances", "compute", "instances.list"},
+		{http.MethodPost, "https://compute.googleapis.com/compute/v1/projects/p/zones/z/instances", "compute", "instances.insert"},
+		{http.MethodPost, "https://compute.googleapis.com/compute/v1/projects/p/zones/z/instances/i/setLabels", "compute", "instances.setLabels"},
+		{http.MethodDelete, "https://compute.googleapis.com/compute/v1/projects/p/global/networks/n", "compute", "networks.delete"},
+		{http.MethodGet, "https://compute.us-central1.rep.googleapis.com/compute/v1/projects/p/regions/r/operations/o", "compute", "operations.get"},
+		{http.MethodGet, "https://compute.googleapis.com/compute/v1/projects/p/aggregated/instances", "compute", "instances.list"},
+		{http.MethodPost, "https://container.googleapis.com/v1/projects/p/locations/l/clusters/c/nodePools/n:setSize", "container", "clusters.nodePools.setSize"},
+		{http.MethodGet, "https://storage.googleapis.com/storage/v1/b/b/o", "storage", "b.o.list"},
+		{http.MethodPost, "https://storage.googleapis.com/upload/storage/v1/b/b/o", "storage", "b.o"},
+		{http.MethodGet, "https://compute.googleapis.com/compute/v1/projects/p", "compute", "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.url, func(t *testing.T) {
+			g := NewWithT(t)
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			g.Expect(err).NotTo(HaveOccurred())
+
+			service, operation := cloud.APIMethod(req)
+			g.Expect(service).To(Equal(tt.service))
+			g.Expect(operation).To(Equal(tt.operation))
+		})
+	}
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+const (
+	// AuditConfigMapSuffix is the suffix of the name of the ConfigMaps recording the mutating GCP
+	// API calls of the GCPClusters.
+	AuditConfigMapSuffix = "-gcp-audit"
+
+	// AuditConfigMapKey is the key of the ConfigMaps data holding the calls, one JSON record per line.
+	AuditConfigMapKey = "audit.jsonl"
+
+	// maxAuditRecords is the number of the most recent calls kept in a ConfigMap, well under the
+	// size limit of the ConfigMaps.
+	maxAuditRecords = 500
+)
+
+// ConfigMapAuditSink is a cloud.AuditSink appending the mutating calls to a ConfigMap per GCPCluster,
+// in its namespace. The ConfigMaps are not owned by the GCPClusters, the record of the calls deleting
+// a cluster outlives it.
+type ConfigMapAuditSink struct {
+	// Client reads and writes the ConfigMaps, it should not be backed by a cache.
+	Client client.Client
+	// Logger logs the calls which could not be recorded.
+	Logger logr.Logger
+}
+
+// Record implements cloud.AuditSink.
+func (s *ConfigMapAuditSink) Record(ctx context.Context, cluster types.NamespacedName, record cloud.AuditRecord) {
+	line, err := json.Marshal(record)
+	if err != nil {
+		s.Logger.Error(err, "Failed to encode the GCP API call", "cluster", cluster.String())
+		return
+	}
+
+	key := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name + AuditConfigMapSuffix}
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMap := &corev1.ConfigMap{}
+		if err := s.Client.Get(ctx, key, configMap); apierrors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{
+					Namespace: key.Namespace,
+					Name:      key.Name,
+					Labels:    map[string]string{clusterv1.ClusterLabelName: cluster.Name},
+				},
+				Data: map[string]string{AuditConfigMapKey: string(line) + "\n"},
+			}
+			return s.Client.Create(ctx, configMap)
+		} else if err != nil {
+			return err
+		}
+
+		lines := strings.SplitAfter(configMap.Data[AuditConfigMapKey], "\n")
+		lines = append(lines[:len(lines)-1], string(line)+"\n")
+		if len(lines) > maxAuditRecords {
+			lines = lines[len(lines)-maxAuditRecords:]
+		}
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+		configMap.Data[AuditConfigMapKey] = strings.Join(lines, "")
+		return s.Client.Update(ctx, configMap)
+	})
+	if err != nil {
+		s.Logger.Error(err, "Failed to record the GCP API call", "cluster", cluster.String(), "verb", record.Verb, "resource", record.Resource)
+	}
+}
@@ -17,10 +17,60 @@ limitations under the License.
 package scope
 
 import (
+	"github.com/pkg/errors"
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	computebeta "google.golang.org/api/compute/v0.beta"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/dns/v1"
+	"google.golang.org/api/servicenetworking/v1"
+	"google.golang.org/api/serviceusage/v1"
+	"google.golang.org/api/storage/v1"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/feature"
 )
 
 // GCPClients contains all the gcp clients used by the scopes.
 type GCPClients struct {
 	Compute *compute.Service
+
+	// ComputeBeta is only set if the ComputeBetaAPI feature gate is enabled.
+	ComputeBeta *computebeta.Service
+	// ComputeAlpha is only set if the ComputeAlphaAPI feature gate is enabled.
+	ComputeAlpha *computealpha.Service
+
+	// ServiceNetworking peers the networks with the networks of the Google managed services.
+	ServiceNetworking *servicenetworking.APIService
+
+	// ServiceUsage tells the APIs enabled in the project, checked before the resources of a cluster are created.
+	ServiceUsage *serviceusage.Service
+
+	// DNS manages the DNS policy and the forwarding zones of the networks.
+	DNS *dns.Service
+
+	// Container manages the GKE clusters and node pools, it is only set by the scopes of the GKE managed clusters.
+	Container *container.Service
+
+	// Storage stores the bootstrap data of the instances in the bootstrap data bucket of their GCPMachine.
+	Storage *storage.Service
+}
+
+// BetaCompute returns the compute beta API client, or an error if the ComputeBetaAPI feature gate,
+// which the feature using it depends on, is disabled.
+func (c *GCPClients) BetaCompute() (*computebeta.Service, error) {
+	if c.ComputeBeta == nil {
+		return nil, errors.Errorf("the %s feature gate must be enabled", feature.ComputeBetaAPI)
+	}
+
+	return c.ComputeBeta, nil
+}
+
+// AlphaCompute returns the compute alpha API client, or an error if the ComputeAlphaAPI feature gate,
+// which the feature using it depends on, is disabled.
+func (c *GCPClients) AlphaCompute() (*computealpha.Service, error) {
+	if c.ComputeAlpha == nil {
+		return nil, errors.Errorf("the %s feature gate must be enabled", feature.ComputeAlphaAPI)
+	}
+
+	return c.ComputeAlpha, nil
 }
@@ -18,14 +18,25 @@ package scope
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"path"
+	"sync"
+	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
-	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/pointer"
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+	"sigs.k8s.io/cluster-api-provider-gcp/feature"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -37,6 +48,50 @@ type ClusterScopeParams struct {
 	Logger     logr.Logger
 	Cluster    *clusterv1.Cluster
 	GCPCluster *infrav1.GCPCluster
+
+	// Cloud is the backend used to populate the GCPClients which are not set.
+	// Defaults to the GCP APIs.
+	Cloud cloud.Cloud
+
+	// DryRun, if set, records the mutating GCP calls instead of executing them
+	// and prevents the GCPCluster from being persisted.
+	DryRun *cloud.DryRun
+
+	// Cache, if set, caches the GCP lookups across the reconciles.
+	Cache *cloud.LookupCache
+
+	// Stats, if set, collects the statistics of the GCP API calls.
+	Stats *cloud.ClientStats
+
+	// GoogleAccess is the way the GCP APIs are reached, defaults to the public access. The regional API
+	// endpoints are only used with the public access.
+	GoogleAccess cloud.GoogleAccess
+
+	// FaultInjection, if set, makes the GCP API calls fail at the configured rates. Test only.
+	FaultInjection *cloud.FaultInjection
+
+	// RateLimiter, if set, limits the rate of the GCP API calls and retries the throttled ones.
+	RateLimiter *cloud.RateLimiter
+
+	// Audit, if set, records the mutating GCP API calls made for the cluster.
+	// The calls skipped in dry-run mode are not recorded.
+	Audit cloud.AuditSink
+
+	// FailureDomainRefreshInterval, if set, is the interval at which the zones of the
+	// region are looked up again, instead of the TTL of the Cache.
+	FailureDomainRefreshInterval time.Duration
+
+	// StatusFieldManager, if set, is the field manager applying the status of the GCPCluster
+	// with a server-side apply. The status is patched with the rest of the GCPCluster otherwise.
+	StatusFieldManager string
+
+	// WaitForOperations makes the services wait for the operations they issue on the cluster resources, instead of
+	// recording them in the status of the GCPCluster to be polled by the next reconciles. It is set by the
+	// reconcilers which don't persist the GCPCluster, e.g. the GCPMachine one.
+	WaitForOperations bool
+
+	// Now returns the current time, to check the maintenance window. Defaults to time.Now.
+	Now func() time.Time
 }
 
 // NewClusterScope creates a new Scope from the supplied parameters.
@@ -53,13 +108,85 @@ func NewClusterScope(params ClusterScopeParams) (*ClusterScope, error) {
 		params.Logger = klogr.New()
 	}
 
-	computeSvc, err := compute.NewService(context.TODO())
-	if err != nil {
-		return nil, errors.Errorf("failed to create gcp compute client: %v", err)
-	}
-
 	if params.GCPClients.Compute == nil {
-		params.GCPClients.Compute = computeSvc
+		if params.Cloud == nil {
+			c, err := cloud.NewCloud(context.TODO(), cloud.Options{})
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		// The injected faults look like errors of the GCP APIs to the other transports.
+		if params.FaultInjection != nil {
+			c, err := params.Cloud.WithTransport(context.TODO(), params.FaultInjection.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		// The throttled calls retried by the rate limiter are counted once in the statistics.
+		if params.RateLimiter != nil {
+			c, err := params.Cloud.WithTransport(context.TODO(), params.RateLimiter.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		if params.Stats != nil {
+			c, err := params.Cloud.WithTransport(context.TODO(), params.Stats.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		if params.GCPCluster.Spec.RegionalAPIEndpoint && params.GoogleAccess.Public() {
+			endpoint := &cloud.RegionalEndpoint{Region: params.GCPCluster.Spec.Region}
+			c, err := params.Cloud.WithTransport(context.TODO(), endpoint.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		if reason, ok := params.GCPCluster.Annotations[infrav1.RequestReasonAnnotation]; ok {
+			attribution := &cloud.RequestAttribution{
+				Cluster: params.GCPCluster.Namespace + "/" + params.GCPCluster.Name,
+				Reason:  reason,
+			}
+			c, err := params.Cloud.WithTransport(context.TODO(), attribution.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		if params.Audit != nil {
+			audit := &cloud.Audit{
+				Cluster: types.NamespacedName{Namespace: params.Cluster.Namespace, Name: params.Cluster.Name},
+				Sink:    params.Audit,
+			}
+			c, err := params.Cloud.WithTransport(context.TODO(), audit.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		if params.DryRun != nil {
+			c, err := params.Cloud.WithTransport(context.TODO(), params.DryRun.Wrap)
+			if err != nil {
+				return nil, err
+			}
+			params.Cloud = c
+		}
+		params.GCPClients.Compute = params.Cloud.Compute()
+		params.GCPClients.ServiceNetworking = params.Cloud.ServiceNetworking()
+		params.GCPClients.ServiceUsage = params.Cloud.ServiceUsage()
+		params.GCPClients.DNS = params.Cloud.DNS()
+		params.GCPClients.Storage = params.Cloud.Storage()
+		if feature.Gates.Enabled(feature.ComputeBetaAPI) {
+			params.GCPClients.ComputeBeta = params.Cloud.ComputeBeta()
+		}
+		if feature.Gates.Enabled(feature.ComputeAlphaAPI) {
+			params.GCPClients.ComputeAlpha = params.Cloud.ComputeAlpha()
+		}
 	}
 
 	helper, err := patch.NewHelper(params.GCPCluster, params.Client)
@@ -67,14 +194,33 @@ func NewClusterScope(params ClusterScopeParams) (*ClusterScope, error) {
 		return nil, errors.Wrap(err, "failed to init patch helper")
 	}
 
-	return &ClusterScope{
+	now := params.Now
+	if now == nil {
+		now = time.Now
+	}
+
+	s := &ClusterScope{
 		Logger:      params.Logger,
 		client:      params.Client,
 		GCPClients:  params.GCPClients,
 		Cluster:     params.Cluster,
 		GCPCluster:  params.GCPCluster,
 		patchHelper: helper,
-	}, nil
+		dryRun:      params.DryRun,
+		cache:       params.Cache,
+
+		googleAccess:                 params.GoogleAccess,
+		failureDomainRefreshInterval: params.FailureDomainRefreshInterval,
+		statusFieldManager:           params.StatusFieldManager,
+		waitForOperations:            params.WaitForOperations,
+		initialStatus:                *params.GCPCluster.Status.DeepCopy(),
+		now:                          now,
+	}
+	if err := s.restoreOperations(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
 }
 
 // ClusterScope defines the basic context for an actuator to operate upon.
@@ -82,12 +228,58 @@ type ClusterScope struct {
 	logr.Logger
 	client      client.Client
 	patchHelper *patch.Helper
+	dryRun      *cloud.DryRun
+	cache       *cloud.LookupCache
+
+	failureDomainRefreshInterval time.Duration
+	googleAccess                 cloud.GoogleAccess
+	waitForOperations            bool
+
+	// statusFieldManager is the field manager applying the status, if any.
+	statusFieldManager string
+	// initialStatus is the status of the GCPCluster when the scope was created.
+	initialStatus infrav1.GCPClusterStatus
+
+	// operationsMu guards the operations of the status, which are recorded by concurrent reconciles.
+	operationsMu sync.Mutex
+	// ownedResourcesMu guards the owned resources of the status, which are recorded by concurrent reconciles.
+	ownedResourcesMu sync.Mutex
+	// firewallRulesMu guards the firewall rules of the status, which are deleted concurrently.
+	firewallRulesMu sync.Mutex
+
+	// now returns the current time.
+	now func() time.Time
+	// deferredChanges are the disruptive changes deferred to the next maintenance window by the reconcile.
+	deferredChanges []string
+	// deferredChangesMu guards the deferred changes, which are recorded by concurrent reconciles.
+	deferredChangesMu sync.Mutex
 
 	GCPClients
 	Cluster    *clusterv1.Cluster
 	GCPCluster *infrav1.GCPCluster
 }
 
+// Cache returns the cache of the GCP lookups, nil if the lookups are not cached.
+func (s *ClusterScope) Cache() *cloud.LookupCache {
+	return s.cache
+}
+
+// FailureDomainRefreshInterval returns the interval at which the zones of the region are looked up again,
+// zero if they are cached for the TTL of the Cache.
+func (s *ClusterScope) FailureDomainRefreshInterval() time.Duration {
+	return s.failureDomainRefreshInterval
+}
+
+// GoogleAccess returns the way the GCP APIs are reached.
+func (s *ClusterScope) GoogleAccess() cloud.GoogleAccess {
+	return s.googleAccess
+}
+
+// WaitForOperations returns true if the operations are waited for, rather than polled by the next reconciles.
+func (s *ClusterScope) WaitForOperations() bool {
+	return s.waitForOperations
+}
+
 // Project returns the current project name.
 func (s *ClusterScope) Project() string {
 	return s.GCPCluster.Spec.Project
@@ -102,9 +294,9 @@ func (s *ClusterScope) NetworkName() string {
 	return "default"
 }
 
-// NetworkSelfLink returns the full self link to the network.
+// NetworkSelfLink returns the full self link to the network, empty if the network is not reconciled yet.
 func (s *ClusterScope) NetworkSelfLink() string {
-	return *s.GCPCluster.Status.Network.SelfLink
+	return pointer.StringDeref(s.GCPCluster.Status.Network.SelfLink, "")
 }
 
 // Network returns the cluster network object.
@@ -112,6 +304,140 @@ func (s *ClusterScope) Network() *infrav1.Network {
 	return &s.GCPCluster.Status.Network
 }
 
+// Operation returns the full reference of the operation in progress on the resource, empty if none.
+func (s *ClusterScope) Operation(resource string) string {
+	s.operationsMu.Lock()
+	defer s.operationsMu.Unlock()
+
+	return s.GCPCluster.Status.Operations[resource]
+}
+
+// SetOperation records the full reference of the operation in progress on the resource,
+// the record is removed if the reference is empty.
+func (s *ClusterScope) SetOperation(resource, selfLink string) {
+	s.operationsMu.Lock()
+	defer s.operationsMu.Unlock()
+
+	if selfLink == "" {
+		delete(s.GCPCluster.Status.Operations, resource)
+		return
+	}
+	if s.GCPCluster.Status.Operations == nil {
+		s.GCPCluster.Status.Operations = make(map[string]string)
+	}
+	s.GCPCluster.Status.Operations[resource] = selfLink
+}
+
+// restoreOperations records the operations of the OperationsAnnotation in the status when it has none, e.g. once
+// the GCPCluster has been moved by clusterctl without its status.
+func (s *ClusterScope) restoreOperations() error {
+	value, ok := s.GCPCluster.Annotations[infrav1.OperationsAnnotation]
+	if !ok || len(s.GCPCluster.Status.Operations) > 0 {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), &s.GCPCluster.Status.Operations); err != nil {
+		return errors.Wrapf(err, "failed to parse the %s annotation", infrav1.OperationsAnnotation)
+	}
+
+	return nil
+}
+
+// syncOperations records the operations in progress of the status in the OperationsAnnotation, and blocks
+// clusterctl move while there are some or the cluster is being deleted.
+func (s *ClusterScope) syncOperations() error {
+	s.operationsMu.Lock()
+	defer s.operationsMu.Unlock()
+
+	if len(s.GCPCluster.Status.Operations) == 0 {
+		// The move stays blocked if it was blocked for another reason, e.g. the deletion of the cluster.
+		if _, ok := s.GCPCluster.Annotations[infrav1.OperationsAnnotation]; ok && s.GCPCluster.DeletionTimestamp.IsZero() {
+			delete(s.GCPCluster.Annotations, infrav1.BlockMoveAnnotation)
+		}
+		delete(s.GCPCluster.Annotations, infrav1.OperationsAnnotation)
+		return nil
+	}
+	value, err := json.Marshal(s.GCPCluster.Status.Operations)
+	if err != nil {
+		return errors.Wrap(err, "failed to serialize the operations in progress")
+	}
+	metav1.SetMetaDataAnnotation(&s.GCPCluster.ObjectMeta, infrav1.OperationsAnnotation, string(value))
+	metav1.SetMetaDataAnnotation(&s.GCPCluster.ObjectMeta, infrav1.BlockMoveAnnotation, "")
+
+	return nil
+}
+
+// FirewallRuleNames returns the names of the firewall rules recorded in the status.
+func (s *ClusterScope) FirewallRuleNames() []string {
+	s.firewallRulesMu.Lock()
+	defer s.firewallRulesMu.Unlock()
+
+	res := make([]string, 0, len(s.GCPCluster.Status.Network.FirewallRules))
+	for name := range s.GCPCluster.Status.Network.FirewallRules {
+		res = append(res, name)
+	}
+
+	return res
+}
+
+// HasFirewallRule returns true if the firewall rule is recorded in the status.
+func (s *ClusterScope) HasFirewallRule(name string) bool {
+	s.firewallRulesMu.Lock()
+	defer s.firewallRulesMu.Unlock()
+
+	_, ok := s.GCPCluster.Status.Network.FirewallRules[name]
+
+	return ok
+}
+
+// SetFirewallRule records the self link of the firewall rule in the status,
+// the record is removed if the self link is empty.
+func (s *ClusterScope) SetFirewallRule(name, selfLink string) {
+	s.firewallRulesMu.Lock()
+	defer s.firewallRulesMu.Unlock()
+
+	if selfLink == "" {
+		delete(s.GCPCluster.Status.Network.FirewallRules, name)
+		return
+	}
+	if s.GCPCluster.Status.Network.FirewallRules == nil {
+		s.GCPCluster.Status.Network.FirewallRules = make(map[string]string)
+	}
+	s.GCPCluster.Status.Network.FirewallRules[name] = selfLink
+}
+
+// IsOwnedResource returns true if the resource at the path, e.g. global/firewalls/my-rule,
+// is recorded in the inventory of the resources owned by the cluster.
+func (s *ClusterScope) IsOwnedResource(resource string) bool {
+	s.ownedResourcesMu.Lock()
+	defer s.ownedResourcesMu.Unlock()
+
+	for _, r := range s.GCPCluster.Status.OwnedResources {
+		if r == resource {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetOwnedResource adds the resource at the path to, or removes it from, the inventory
+// of the resources owned by the cluster.
+func (s *ClusterScope) SetOwnedResource(resource string, owned bool) {
+	s.ownedResourcesMu.Lock()
+	defer s.ownedResourcesMu.Unlock()
+
+	resources := sets.NewString(s.GCPCluster.Status.OwnedResources...)
+	if owned {
+		resources.Insert(resource)
+	} else {
+		resources.Delete(resource)
+	}
+	s.GCPCluster.Status.OwnedResources = nil
+	if resources.Len() > 0 {
+		s.GCPCluster.Status.OwnedResources = resources.List()
+	}
+}
+
 // Subnets returns the cluster subnets.
 func (s *ClusterScope) Subnets() infrav1.Subnets {
 	return s.GCPCluster.Spec.Network.Subnets
@@ -122,6 +448,108 @@ func (s *ClusterScope) Name() string {
 	return s.Cluster.Name
 }
 
+// ResourceNamePrefix returns the prefix of the names of the cluster resources,
+// the cluster name unless overridden in the GCPCluster spec.
+func (s *ClusterScope) ResourceNamePrefix() string {
+	return pointer.StringDeref(s.GCPCluster.Spec.ResourceNamePrefix, s.Name())
+}
+
+// SecurityProfile returns the security profile of the cluster instances.
+func (s *ClusterScope) SecurityProfile() infrav1.SecurityProfile {
+	if s.GCPCluster.Spec.SecurityProfile == "" {
+		return infrav1.SecurityProfileDefault
+	}
+
+	return s.GCPCluster.Spec.SecurityProfile
+}
+
+// LoadBalancerType returns the type of the API server load balancer, defaults to a proxy.
+func (s *ClusterScope) LoadBalancerType() infrav1.LoadBalancerType {
+	if s.GCPCluster.Spec.LoadBalancer.Type == "" {
+		return infrav1.LoadBalancerTypeProxy
+	}
+
+	return s.GCPCluster.Spec.LoadBalancer.Type
+}
+
+// FirewallPolicyMode returns the kind of the firewall rules of the cluster, defaults to Classic.
+func (s *ClusterScope) FirewallPolicyMode() infrav1.FirewallPolicyMode {
+	if s.GCPCluster.Spec.Network.FirewallPolicy == nil || s.GCPCluster.Spec.Network.FirewallPolicy.Mode == "" {
+		return infrav1.FirewallPolicyModeClassic
+	}
+
+	return s.GCPCluster.Spec.Network.FirewallPolicy.Mode
+}
+
+// LoadBalancerBackendType returns the type of the backends of the Proxy load balancer, defaults to InstanceGroup.
+func (s *ClusterScope) LoadBalancerBackendType() infrav1.LoadBalancerBackendType {
+	if s.GCPCluster.Spec.LoadBalancer.BackendType == "" {
+		return infrav1.LoadBalancerBackendInstanceGroup
+	}
+
+	return s.GCPCluster.Spec.LoadBalancer.BackendType
+}
+
+// LoadBalancerScheme returns the scheme of the Proxy load balancer, defaults to External.
+func (s *ClusterScope) LoadBalancerScheme() infrav1.LoadBalancerScheme {
+	if s.GCPCluster.Spec.LoadBalancer.Scheme == "" {
+		return infrav1.LoadBalancerSchemeExternal
+	}
+
+	return s.GCPCluster.Spec.LoadBalancer.Scheme
+}
+
+// LoadBalancerHealthCheck returns the tuning of the health check of the API server backends, nil to keep the defaults.
+func (s *ClusterScope) LoadBalancerHealthCheck() *infrav1.LoadBalancerHealthCheckSpec {
+	return s.GCPCluster.Spec.LoadBalancer.HealthCheck
+}
+
+// LoadBalancerSessionAffinity returns the session affinity of the API server backend service, defaults to NONE.
+func (s *ClusterScope) LoadBalancerSessionAffinity() string {
+	return pointer.StringDeref(s.GCPCluster.Spec.LoadBalancer.SessionAffinity, "NONE")
+}
+
+// LoadBalancerConnectionDrainingTimeout returns how long the connections to a removed API server backend are drained
+// for, in seconds, defaults to 0.
+func (s *ClusterScope) LoadBalancerConnectionDrainingTimeout() int64 {
+	return pointer.Int64Deref(s.GCPCluster.Spec.LoadBalancer.ConnectionDrainingTimeoutSec, 0)
+}
+
+// LoadBalancerSecurityPolicy returns the name of the Cloud Armor security policy of the API server backend service,
+// nil to leave it as is.
+func (s *ClusterScope) LoadBalancerSecurityPolicy() *string {
+	return s.GCPCluster.Spec.LoadBalancer.SecurityPolicy
+}
+
+// LoadBalancerStackType returns the IP stack of the frontend of the Proxy load balancer, defaults to IPV4_ONLY.
+func (s *ClusterScope) LoadBalancerStackType() infrav1.StackType {
+	if s.GCPCluster.Spec.LoadBalancer.StackType == "" {
+		return infrav1.StackTypeIPv4Only
+	}
+
+	return s.GCPCluster.Spec.LoadBalancer.StackType
+}
+
+// LoadBalancerSubnet returns the subnetwork the address of an Internal load balancer is reserved in: the
+// InternalLoadBalancer or else the ControlPlane subnetwork of the spec, the default subnetwork of the machines, or else
+// the first subnetwork of the network in the region of the cluster. Without these, it's nil until the subnetworks of
+// the network are recorded in the status.
+func (s *ClusterScope) LoadBalancerSubnet() *string {
+	for _, role := range []infrav1.SubnetRole{infrav1.SubnetRoleInternalLoadBalancer, infrav1.SubnetRoleControlPlane} {
+		if subnet := s.GCPCluster.Spec.Network.Subnets.FindByRole(s.Region(), role); subnet != nil {
+			return &subnet.Name
+		}
+	}
+	if defaults := s.GCPCluster.Spec.MachineDefaults; defaults != nil && defaults.Subnet != nil {
+		return defaults.Subnet
+	}
+	if subnets := s.GCPCluster.Status.Network.Subnets; len(subnets) > 0 {
+		return &subnets[0].Name
+	}
+
+	return nil
+}
+
 // Namespace returns the cluster namespace.
 func (s *ClusterScope) Namespace() string {
 	return s.Cluster.Namespace
@@ -132,10 +560,35 @@ func (s *ClusterScope) Region() string {
 	return s.GCPCluster.Spec.Region
 }
 
+// ControlPlaneRegions returns the regions the control plane runs in, the cluster region first.
+func (s *ClusterScope) ControlPlaneRegions() []string {
+	return append([]string{s.GCPCluster.Spec.Region}, s.GCPCluster.Spec.AdditionalControlPlaneRegions...)
+}
+
+// ShouldAdopt returns true if the pre-existing GCP resources matching the cluster spec must be adopted.
+func (s *ClusterScope) ShouldAdopt() bool {
+	_, ok := s.GCPCluster.Annotations[infrav1.AdoptAnnotation]
+
+	return ok
+}
+
+// ShouldRetain returns true if the GCP resource must be retained on cluster deletion.
+// Invalid values of the retain annotation are rejected by the webhook, they are ignored here.
+func (s *ClusterScope) ShouldRetain(r infrav1.RetainedResource) bool {
+	retained, _ := s.GCPCluster.RetainedResources()
+	for _, res := range retained {
+		if res == r {
+			return true
+		}
+	}
+
+	return false
+}
+
 // LoadBalancerFrontendPort returns the loadbalancer frontend if specified
 // in the cluster resource's network configuration.
 func (s *ClusterScope) LoadBalancerFrontendPort() int64 {
-	if s.Cluster.Spec.ClusterNetwork.APIServerPort != nil {
+	if s.Cluster.Spec.ClusterNetwork != nil && s.Cluster.Spec.ClusterNetwork.APIServerPort != nil {
 		return int64(*s.Cluster.Spec.ClusterNetwork.APIServerPort)
 	}
 
@@ -151,6 +604,27 @@ func (s *ClusterScope) LoadBalancerBackendPort() int64 {
 	return 6443
 }
 
+// BreakGlassSSHUser returns the user the break-glass SSH key is authorized for, defaults to break-glass.
+func (s *ClusterScope) BreakGlassSSHUser() string {
+	if spec := s.GCPCluster.Spec.BreakGlassSSH; spec != nil && spec.User != "" {
+		return spec.User
+	}
+
+	return "break-glass"
+}
+
+// BreakGlassSSHKey returns the ssh-keys metadata entry authorizing the break-glass SSH key on the instances,
+// empty if the cluster has none.
+func (s *ClusterScope) BreakGlassSSHKey() string {
+	status := s.GCPCluster.Status.BreakGlassSSH
+	if s.GCPCluster.Spec.BreakGlassSSH == nil || status == nil {
+		return ""
+	}
+	user := s.BreakGlassSSHUser()
+
+	return fmt.Sprintf("%s:%s %s", user, status.PublicKey, user)
+}
+
 // ControlPlaneConfigMapName returns the name of the ConfigMap used to
 // coordinate the bootstrapping of control plane nodes.
 func (s *ClusterScope) ControlPlaneConfigMapName() string {
@@ -164,9 +638,94 @@ func (s *ClusterScope) ListOptionsLabelSelector() client.ListOption {
 	})
 }
 
+// MachineInstanceNames returns the names of the instances the GCPMachines of the cluster own, or may own once
+// their creation completes: the instance of their provider ID, their existing instance, and the names rendered for
+// either role, the role of a GCPMachine being that of its Machine.
+func (s *ClusterScope) MachineInstanceNames(ctx context.Context) (sets.String, error) {
+	gcpMachines := &infrav1.GCPMachineList{}
+	if err := s.client.List(ctx, gcpMachines, client.InNamespace(s.Namespace()), s.ListOptionsLabelSelector()); err != nil {
+		return nil, errors.Wrap(err, "failed to list the GCPMachines of the cluster")
+	}
+
+	res := sets.NewString()
+	for _, m := range gcpMachines.Items {
+		res.Insert(names.Truncate(m.Name))
+		if m.Spec.ProviderID != nil {
+			res.Insert(path.Base(*m.Spec.ProviderID))
+		}
+		if m.Spec.ExistingInstance != nil {
+			if instance, err := names.ParseInstanceReference(*m.Spec.ExistingInstance); err == nil {
+				res.Insert(instance.Name)
+			}
+		}
+		if m.Spec.InstanceNameTemplate == nil {
+			continue
+		}
+		for _, role := range []string{"control-plane", "node"} {
+			name, err := names.Format(*m.Spec.InstanceNameTemplate, names.InstanceData{
+				ClusterName: s.Name(),
+				Name:        m.Name,
+				Namespace:   m.Namespace,
+				Role:        role,
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to name the instance of GCPMachine %s", m.Name)
+			}
+			res.Insert(name)
+		}
+	}
+
+	return res, nil
+}
+
+// DryRun returns the recorder of the planned GCP operations, nil if the scope is not in dry-run mode.
+func (s *ClusterScope) DryRun() *cloud.DryRun {
+	return s.dryRun
+}
+
 // PatchObject persists the cluster configuration and status.
+// It is a no-op in dry-run mode.
 func (s *ClusterScope) PatchObject() error {
-	return s.patchHelper.Patch(context.TODO(), s.GCPCluster)
+	if s.dryRun != nil {
+		return nil
+	}
+
+	if err := s.syncOperations(); err != nil {
+		return err
+	}
+
+	// The Ready condition summarizes the preflight checks and the state of the network, the firewall rules
+	// and the load balancer.
+	if conditions.Has(s.GCPCluster, infrav1.PreflightChecksPassedCondition) || conditions.Has(s.GCPCluster, infrav1.NetworkReadyCondition) {
+		conditions.SetSummary(s.GCPCluster, conditions.WithConditions(
+			infrav1.PreflightChecksPassedCondition,
+			infrav1.NetworkReadyCondition,
+			infrav1.FirewallsReadyCondition,
+			infrav1.LoadBalancerReadyCondition,
+		))
+	}
+
+	if s.statusFieldManager == "" {
+		return s.patchHelper.Patch(
+			context.TODO(),
+			s.GCPCluster,
+			patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+				clusterv1.ReadyCondition,
+				infrav1.PreflightChecksPassedCondition,
+				infrav1.NetworkReadyCondition,
+				infrav1.FirewallsReadyCondition,
+				infrav1.LoadBalancerReadyCondition,
+			}})
+	}
+
+	// The patch helper only patches the metadata and the spec, the status being left unchanged.
+	gcpCluster := s.GCPCluster.DeepCopy()
+	gcpCluster.Status = s.initialStatus
+	if err := s.patchHelper.Patch(context.TODO(), gcpCluster); err != nil {
+		return err
+	}
+
+	return applyStatus(context.TODO(), s.client, s.GCPCluster, &s.GCPCluster.Status, s.statusFieldManager)
 }
 
 // Close closes the current scope persisting the cluster configuration and status.
@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"fmt"
+	"strings"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+// SetFeaturesAvailable sets the FeaturesAvailable condition of the GCPCluster from the features of its spec
+// unavailable with the Google access.
+func (s *ClusterScope) SetFeaturesAvailable() {
+	var unavailable []string
+	if s.GCPCluster.Spec.RegionalAPIEndpoint {
+		unavailable = append(unavailable, fmt.Sprintf("regionalAPIEndpoint: the regional endpoints aren't served by the %s VIP, the global endpoint is used", s.googleAccess))
+	}
+
+	setFeaturesAvailable(s.GCPCluster, s.googleAccess, unavailable)
+}
+
+// SetFeaturesAvailable sets the FeaturesAvailable condition of the GCPMachine from the features of its spec
+// unavailable with the Google access.
+func (m *MachineScope) SetFeaturesAvailable(access cloud.GoogleAccess, unavailable []string) {
+	setFeaturesAvailable(m.GCPMachine, access, unavailable)
+}
+
+// setFeaturesAvailable sets the FeaturesAvailable condition, which is only set when the GCP APIs aren't
+// reached on their public endpoints.
+func setFeaturesAvailable(obj conditions.Setter, access cloud.GoogleAccess, unavailable []string) {
+	switch {
+	case access.Public():
+		conditions.Delete(obj, infrav1.FeaturesAvailableCondition)
+	case len(unavailable) > 0:
+		conditions.MarkFalse(obj, infrav1.FeaturesAvailableCondition, infrav1.GoogleAccessRestrictedReason, clusterv1.ConditionSeverityWarning,
+			"%s", strings.Join(unavailable, "; "))
+	default:
+		conditions.MarkTrue(obj, infrav1.FeaturesAvailableCondition)
+	}
+}
@@ -19,6 +19,7 @@ package scope
 
 import (
 	"context"
+	"strings"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
@@ -29,11 +30,14 @@ import (
 	"k8s.io/utils/pointer"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/controllers/noderefutil"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -47,6 +51,13 @@ type MachineScopeParams struct {
 	Machine    *clusterv1.Machine
 	GCPCluster *infrav1.GCPCluster
 	GCPMachine *infrav1.GCPMachine
+
+	// DryRun, if set, prevents the GCPMachine from being persisted.
+	DryRun *cloud.DryRun
+
+	// StatusFieldManager, if set, is the field manager applying the status of the GCPMachine
+	// with a server-side apply. The status is patched with the rest of the GCPMachine otherwise.
+	StatusFieldManager string
 }
 
 // NewMachineScope creates a new MachineScope from the supplied parameters.
@@ -77,7 +88,7 @@ func NewMachineScope(params MachineScopeParams) (*MachineScope, error) {
 		return nil, errors.Wrap(err, "failed to init patch helper")
 	}
 
-	return &MachineScope{
+	scope := &MachineScope{
 		client:      params.Client,
 		Cluster:     params.Cluster,
 		Machine:     params.Machine,
@@ -85,14 +96,51 @@ func NewMachineScope(params MachineScopeParams) (*MachineScope, error) {
 		GCPMachine:  params.GCPMachine,
 		Logger:      params.Logger,
 		patchHelper: helper,
-	}, nil
+		dryRun:      params.DryRun,
+
+		statusFieldManager: params.StatusFieldManager,
+		initialStatus:      *params.GCPMachine.Status.DeepCopy(),
+	}
+
+	scope.instanceName = names.Truncate(params.GCPMachine.Name)
+	if params.GCPMachine.Spec.InstanceNameTemplate != nil {
+		scope.instanceName, err = names.Format(*params.GCPMachine.Spec.InstanceNameTemplate, names.InstanceData{
+			ClusterName: params.Cluster.Name,
+			Name:        params.GCPMachine.Name,
+			Namespace:   params.GCPMachine.Namespace,
+			Role:        scope.Role(),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to name the instance")
+		}
+	}
+	if ref := params.GCPMachine.Spec.ExistingInstance; ref != nil {
+		instance, err := names.ParseInstanceReference(*ref)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to locate the existing instance")
+		}
+		scope.instanceName = instance.Name
+		scope.existingInstanceZone = instance.Zone
+	}
+
+	return scope, nil
 }
 
 // MachineScope defines a scope defined around a machine and its cluster.
 type MachineScope struct {
 	logr.Logger
-	client      client.Client
-	patchHelper *patch.Helper
+	client       client.Client
+	patchHelper  *patch.Helper
+	dryRun       *cloud.DryRun
+	instanceName string
+
+	// statusFieldManager is the field manager applying the status, if any.
+	statusFieldManager string
+	// initialStatus is the status of the GCPMachine when the scope was created.
+	initialStatus infrav1.GCPMachineStatus
+
+	// existingInstanceZone is the zone of the existing instance adopted by the GCPMachine.
+	existingInstanceZone string
 
 	Cluster    *clusterv1.Cluster
 	Machine    *clusterv1.Machine
@@ -100,15 +148,23 @@ type MachineScope struct {
 	GCPMachine *infrav1.GCPMachine
 }
 
-// Region returns the GCPMachine region.
+// Region returns the GCPMachine region, the one of its zone, e.g. a control plane machine in an
+// additional control plane region, or the cluster region if the zone isn't chosen yet.
 func (m *MachineScope) Region() string {
+	if zone := m.Zone(); zone != "" {
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			return zone[:i]
+		}
+	}
+
 	return m.GCPCluster.Spec.Region
 }
 
-// Zone returns the FailureDomain for the GCPMachine.
+// Zone returns the FailureDomain for the GCPMachine, or the zone chosen by the
+// ZoneFallback if the Machine has no FailureDomain.
 func (m *MachineScope) Zone() string {
 	if m.Machine.Spec.FailureDomain == nil {
-		return ""
+		return m.GCPMachine.Status.Zone
 	}
 
 	return *m.Machine.Spec.FailureDomain
@@ -119,6 +175,12 @@ func (m *MachineScope) Name() string {
 	return m.GCPMachine.Name
 }
 
+// InstanceName returns the name of the GCE instance, rendered from the GCPMachine
+// InstanceNameTemplate or derived from the GCPMachine name.
+func (m *MachineScope) InstanceName() string {
+	return m.instanceName
+}
+
 // Namespace returns the namespace name.
 func (m *MachineScope) Namespace() string {
 	return m.GCPMachine.Namespace
@@ -138,6 +200,81 @@ func (m *MachineScope) Role() string {
 	return "node"
 }
 
+// machineDefaults returns the machine defaults of the GCPCluster, empty if unset.
+func (m *MachineScope) machineDefaults() infrav1.MachineDefaults {
+	if m.GCPCluster.Spec.MachineDefaults == nil {
+		return infrav1.MachineDefaults{}
+	}
+
+	return *m.GCPCluster.Spec.MachineDefaults
+}
+
+// DefaultImage returns the image, or else the image family, of the machine defaults of the GCPCluster,
+// used when the GCPMachine doesn't set any.
+func (m *MachineScope) DefaultImage() *string {
+	defaults := m.machineDefaults()
+	if defaults.Image != nil {
+		return defaults.Image
+	}
+
+	return defaults.ImageFamily
+}
+
+// ServiceAccount returns the service account of the GCPMachine, or the default of the GCPCluster.
+func (m *MachineScope) ServiceAccount() *infrav1.ServiceAccount {
+	if m.GCPMachine.Spec.ServiceAccount != nil {
+		return m.GCPMachine.Spec.ServiceAccount
+	}
+
+	return m.machineDefaults().ServiceAccount
+}
+
+// AdditionalNetworkTags returns the network tags of the GCPMachine, or the defaults of the GCPCluster.
+func (m *MachineScope) AdditionalNetworkTags() []string {
+	if len(m.GCPMachine.Spec.AdditionalNetworkTags) > 0 {
+		return m.GCPMachine.Spec.AdditionalNetworkTags
+	}
+
+	return m.machineDefaults().AdditionalNetworkTags
+}
+
+// AdditionalLabels returns the labels of the GCPMachine added to the defaults of the GCPCluster.
+func (m *MachineScope) AdditionalLabels() infrav1.Labels {
+	return infrav1.Labels{}.
+		AddLabels(m.machineDefaults().AdditionalLabels).
+		AddLabels(m.GCPMachine.Spec.AdditionalLabels)
+}
+
+// PublicIP returns true if the instance gets a public IP, as set by the GCPMachine or the defaults of the GCPCluster.
+func (m *MachineScope) PublicIP() bool {
+	if m.GCPMachine.Spec.PublicIP != nil {
+		return *m.GCPMachine.Spec.PublicIP
+	}
+
+	return pointer.BoolDeref(m.machineDefaults().PublicIP, false)
+}
+
+// Subnet returns the subnetwork of the GCPMachine, or the default of the GCPCluster, or else the subnetwork of the
+// GCPCluster having the role of the machine, ControlPlane or Worker.
+func (m *MachineScope) Subnet() *string {
+	if m.GCPMachine.Spec.Subnet != nil {
+		return m.GCPMachine.Spec.Subnet
+	}
+	if subnet := m.machineDefaults().Subnet; subnet != nil {
+		return subnet
+	}
+
+	role := infrav1.SubnetRoleWorker
+	if m.IsControlPlane() {
+		role = infrav1.SubnetRoleControlPlane
+	}
+	if subnet := m.GCPCluster.Spec.Network.Subnets.FindByRole(m.Region(), role); subnet != nil {
+		return &subnet.Name
+	}
+
+	return nil
+}
+
 // GetInstanceID returns the GCPMachine instance id by parsing Spec.ProviderID.
 func (m *MachineScope) GetInstanceID() *string {
 	parsed, err := noderefutil.NewProviderID(m.GetProviderID())
@@ -157,6 +294,23 @@ func (m *MachineScope) GetProviderID() string {
 	return ""
 }
 
+// InstanceZone returns the zone of the instance from the GCPMachine providerID,
+// which differs from the FailureDomain if the instance has been moved, and
+// defaults to the zone of the adopted instance, or to the FailureDomain, if the
+// providerID is not set.
+func (m *MachineScope) InstanceZone() string {
+	// The providerID has the format gce://<project>/<zone>/<instance name>.
+	parts := strings.Split(strings.TrimPrefix(m.GetProviderID(), "gce://"), "/")
+	if len(parts) == 3 && parts[1] != "" {
+		return parts[1]
+	}
+	if m.existingInstanceZone != "" {
+		return m.existingInstanceZone
+	}
+
+	return m.Zone()
+}
+
 // SetProviderID sets the GCPMachine providerID in spec.
 func (m *MachineScope) SetProviderID(v string) {
 	m.GCPMachine.Spec.ProviderID = pointer.StringPtr(v)
@@ -177,6 +331,11 @@ func (m *MachineScope) SetReady() {
 	m.GCPMachine.Status.Ready = true
 }
 
+// SetNotReady sets the GCPMachine Ready Status to false.
+func (m *MachineScope) SetNotReady() {
+	m.GCPMachine.Status.Ready = false
+}
+
 // SetFailureMessage sets the GCPMachine status failure message.
 func (m *MachineScope) SetFailureMessage(v error) {
 	m.GCPMachine.Status.FailureMessage = pointer.StringPtr(v.Error())
@@ -221,8 +380,39 @@ func (m *MachineScope) GetBootstrapData() (string, error) {
 }
 
 // PatchObject persists the cluster configuration and status.
+// It is a no-op in dry-run mode.
 func (m *MachineScope) PatchObject() error {
-	return m.patchHelper.Patch(context.TODO(), m.GCPMachine)
+	if m.dryRun != nil {
+		return nil
+	}
+
+	// The Ready condition summarizes the state of the bootstrap data and of the instance, it's mirrored
+	// by the InfrastructureReady condition of the Machine.
+	if conditions.Has(m.GCPMachine, infrav1.BootstrapDataReadyCondition) || conditions.Has(m.GCPMachine, infrav1.InstanceReadyCondition) {
+		conditions.SetSummary(m.GCPMachine, conditions.WithConditions(infrav1.BootstrapDataReadyCondition, infrav1.InstanceReadyCondition))
+	}
+
+	if m.statusFieldManager == "" {
+		return m.patchHelper.Patch(
+			context.TODO(),
+			m.GCPMachine,
+			patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+				clusterv1.ReadyCondition,
+				infrav1.BootstrapDataReadyCondition,
+				infrav1.InstanceReadyCondition,
+				infrav1.BootstrapSucceededCondition,
+				infrav1.APIServerBackendDrainedCondition,
+			}})
+	}
+
+	// The patch helper only patches the metadata and the spec, the status being left unchanged.
+	gcpMachine := m.GCPMachine.DeepCopy()
+	gcpMachine.Status = m.initialStatus
+	if err := m.patchHelper.Patch(context.TODO(), gcpMachine); err != nil {
+		return err
+	}
+
+	return applyStatus(context.TODO(), m.client, m.GCPMachine, &m.GCPMachine.Status, m.statusFieldManager)
 }
 
 // Close closes the current scope persisting the cluster configuration and status.
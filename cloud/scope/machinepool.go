@@ -0,0 +1,253 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+// MachinePoolScopeParams defines the input parameters used to create a new MachinePoolScope.
+type MachinePoolScopeParams struct {
+	Client         client.Client
+	Logger         logr.Logger
+	Cluster        *clusterv1.Cluster
+	MachinePool    *expclusterv1.MachinePool
+	GCPCluster     *infrav1.GCPCluster
+	GCPMachinePool *expinfrav1.GCPMachinePool
+
+	// DryRun, if set, prevents the GCPMachinePool from being persisted.
+	DryRun *cloud.DryRun
+}
+
+// NewMachinePoolScope creates a new MachinePoolScope from the supplied parameters.
+// This is meant to be called for each reconcile iteration.
+func NewMachinePoolScope(params MachinePoolScopeParams) (*MachinePoolScope, error) {
+	if params.Client == nil {
+		return nil, errors.New("client is required when creating a MachinePoolScope")
+	}
+	if params.Cluster == nil {
+		return nil, errors.New("cluster is required when creating a MachinePoolScope")
+	}
+	if params.MachinePool == nil {
+		return nil, errors.New("machine pool is required when creating a MachinePoolScope")
+	}
+	if params.GCPCluster == nil {
+		return nil, errors.New("gcp cluster is required when creating a MachinePoolScope")
+	}
+	if params.GCPMachinePool == nil {
+		return nil, errors.New("gcp machine pool is required when creating a MachinePoolScope")
+	}
+	if params.Logger == nil {
+		params.Logger = klogr.New()
+	}
+
+	helper, err := patch.NewHelper(params.GCPMachinePool, params.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init patch helper")
+	}
+
+	return &MachinePoolScope{
+		Logger:         params.Logger,
+		client:         params.Client,
+		patchHelper:    helper,
+		dryRun:         params.DryRun,
+		Cluster:        params.Cluster,
+		MachinePool:    params.MachinePool,
+		GCPCluster:     params.GCPCluster,
+		GCPMachinePool: params.GCPMachinePool,
+	}, nil
+}
+
+// MachinePoolScope defines a scope defined around a machine pool and its cluster.
+type MachinePoolScope struct {
+	logr.Logger
+	client      client.Client
+	patchHelper *patch.Helper
+	dryRun      *cloud.DryRun
+
+	Cluster        *clusterv1.Cluster
+	MachinePool    *expclusterv1.MachinePool
+	GCPCluster     *infrav1.GCPCluster
+	GCPMachinePool *expinfrav1.GCPMachinePool
+}
+
+// Name returns the GCPMachinePool name.
+func (m *MachinePoolScope) Name() string {
+	return m.GCPMachinePool.Name
+}
+
+// Namespace returns the namespace name.
+func (m *MachinePoolScope) Namespace() string {
+	return m.GCPMachinePool.Namespace
+}
+
+// Replicas returns the number of instances of the MachinePool.
+func (m *MachinePoolScope) Replicas() int64 {
+	return int64(pointer.Int32Deref(m.MachinePool.Spec.Replicas, 1))
+}
+
+// Zones returns the zones the instances are distributed across, the failure domains of the MachinePool,
+// or else the ones of the cluster in its region. GCP picks the zones of the region if there is none.
+func (m *MachinePoolScope) Zones() []string {
+	if len(m.MachinePool.Spec.FailureDomains) > 0 {
+		return m.MachinePool.Spec.FailureDomains
+	}
+
+	zones := make([]string, 0, len(m.GCPCluster.Status.FailureDomains))
+	for zone := range m.GCPCluster.Status.FailureDomains {
+		if strings.HasPrefix(zone, m.GCPCluster.Spec.Region+"-") {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Strings(zones)
+
+	return zones
+}
+
+// machineDefaults returns the machine defaults of the GCPCluster, empty if unset.
+func (m *MachinePoolScope) machineDefaults() infrav1.MachineDefaults {
+	if m.GCPCluster.Spec.MachineDefaults == nil {
+		return infrav1.MachineDefaults{}
+	}
+
+	return *m.GCPCluster.Spec.MachineDefaults
+}
+
+// DefaultImage returns the image, or else the image family, of the machine defaults of the GCPCluster,
+// used when the GCPMachinePool doesn't set any.
+func (m *MachinePoolScope) DefaultImage() *string {
+	defaults := m.machineDefaults()
+	if defaults.Image != nil {
+		return defaults.Image
+	}
+
+	return defaults.ImageFamily
+}
+
+// ServiceAccount returns the service account of the GCPMachinePool, or the default of the GCPCluster.
+func (m *MachinePoolScope) ServiceAccount() *infrav1.ServiceAccount {
+	if m.GCPMachinePool.Spec.ServiceAccount != nil {
+		return m.GCPMachinePool.Spec.ServiceAccount
+	}
+
+	return m.machineDefaults().ServiceAccount
+}
+
+// AdditionalNetworkTags returns the network tags of the GCPMachinePool, or the defaults of the GCPCluster.
+func (m *MachinePoolScope) AdditionalNetworkTags() []string {
+	if len(m.GCPMachinePool.Spec.AdditionalNetworkTags) > 0 {
+		return m.GCPMachinePool.Spec.AdditionalNetworkTags
+	}
+
+	return m.machineDefaults().AdditionalNetworkTags
+}
+
+// AdditionalLabels returns the labels of the GCPMachinePool added to the defaults of the GCPCluster.
+func (m *MachinePoolScope) AdditionalLabels() infrav1.Labels {
+	return infrav1.Labels{}.
+		AddLabels(m.machineDefaults().AdditionalLabels).
+		AddLabels(m.GCPMachinePool.Spec.AdditionalLabels)
+}
+
+// PublicIP returns true if the instances get a public IP, as set by the GCPMachinePool or the defaults of the GCPCluster.
+func (m *MachinePoolScope) PublicIP() bool {
+	if m.GCPMachinePool.Spec.PublicIP != nil {
+		return *m.GCPMachinePool.Spec.PublicIP
+	}
+
+	return pointer.BoolDeref(m.machineDefaults().PublicIP, false)
+}
+
+// Subnet returns the subnetwork of the GCPMachinePool, or the default of the GCPCluster, or else the Worker
+// subnetwork of the GCPCluster.
+func (m *MachinePoolScope) Subnet() *string {
+	if m.GCPMachinePool.Spec.Subnet != nil {
+		return m.GCPMachinePool.Spec.Subnet
+	}
+	if subnet := m.machineDefaults().Subnet; subnet != nil {
+		return subnet
+	}
+	if subnet := m.GCPCluster.Spec.Network.Subnets.FindByRole(m.GCPCluster.Spec.Region, infrav1.SubnetRoleWorker); subnet != nil {
+		return &subnet.Name
+	}
+
+	return nil
+}
+
+// GetBootstrapData returns the bootstrap data from the secret in the MachinePool's bootstrap.dataSecretName.
+func (m *MachinePoolScope) GetBootstrapData() (string, error) {
+	if m.MachinePool.Spec.Template.Spec.Bootstrap.DataSecretName == nil {
+		return "", errors.New("error retrieving bootstrap data: linked MachinePool's bootstrap.dataSecretName is nil")
+	}
+
+	secret := &corev1.Secret{}
+	key := types.NamespacedName{Namespace: m.Namespace(), Name: *m.MachinePool.Spec.Template.Spec.Bootstrap.DataSecretName}
+	if err := m.client.Get(context.TODO(), key, secret); err != nil {
+		return "", errors.Wrapf(err, "failed to retrieve bootstrap data secret for GCPMachinePool %s/%s", m.Namespace(), m.Name())
+	}
+
+	value, ok := secret.Data["value"]
+	if !ok {
+		return "", errors.New("error retrieving bootstrap data: secret value key is missing")
+	}
+
+	return string(value), nil
+}
+
+// PatchObject persists the machine pool configuration and status.
+// It is a no-op in dry-run mode.
+func (m *MachinePoolScope) PatchObject() error {
+	if m.dryRun != nil {
+		return nil
+	}
+
+	// The Ready condition summarizes the state of the managed instance group.
+	if conditions.Has(m.GCPMachinePool, expinfrav1.InstanceGroupReadyCondition) {
+		conditions.SetSummary(m.GCPMachinePool, conditions.WithConditions(expinfrav1.InstanceGroupReadyCondition))
+	}
+
+	return m.patchHelper.Patch(
+		context.TODO(),
+		m.GCPMachinePool,
+		patch.WithOwnedConditions{Conditions: []clusterv1.ConditionType{
+			clusterv1.ReadyCondition,
+			expinfrav1.InstanceGroupReadyCondition,
+		}})
+}
+
+// Close closes the current scope persisting the machine pool configuration and status.
+func (m *MachinePoolScope) Close() error {
+	return m.PatchObject()
+}
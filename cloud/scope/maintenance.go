@@ -0,0 +1,104 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"strings"
+	"time"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+// InMaintenanceWindow returns true if the disruptive changes of the GCP resources can be applied now: the
+// GCPCluster has no maintenance window, or the current time is within it.
+func (s *ClusterScope) InMaintenanceWindow() bool {
+	spec := s.GCPCluster.Spec.MaintenanceWindow
+	if spec == nil {
+		return true
+	}
+	open, _ := maintenanceWindow(spec, s.now())
+
+	return open
+}
+
+// DeferChange records a disruptive change deferred to the next maintenance window, e.g. firewall rule "my-rule":
+// source ranges changed.
+func (s *ClusterScope) DeferChange(change string) {
+	s.deferredChangesMu.Lock()
+	defer s.deferredChangesMu.Unlock()
+
+	s.deferredChanges = append(s.deferredChanges, change)
+}
+
+// SetDisruptiveChangesApplied sets the DisruptiveChangesApplied condition of the GCPCluster from the changes
+// deferred by the reconcile, and returns the time left until the next maintenance window if there are some.
+func (s *ClusterScope) SetDisruptiveChangesApplied() time.Duration {
+	spec := s.GCPCluster.Spec.MaintenanceWindow
+	if spec == nil {
+		conditions.Delete(s.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)
+		return 0
+	}
+
+	s.deferredChangesMu.Lock()
+	defer s.deferredChangesMu.Unlock()
+
+	if len(s.deferredChanges) == 0 {
+		conditions.MarkTrue(s.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)
+		return 0
+	}
+
+	now := s.now()
+	_, next := maintenanceWindow(spec, now)
+	conditions.MarkFalse(s.GCPCluster, infrav1.DisruptiveChangesAppliedCondition, infrav1.WaitingForMaintenanceWindowReason, clusterv1.ConditionSeverityInfo,
+		"Deferred to the maintenance window starting at %s: %s", next.Format(time.RFC3339), strings.Join(s.deferredChanges, "; "))
+
+	return next.Sub(now)
+}
+
+// maintenanceWindow returns true if the time is within the maintenance window, and the start of the next window.
+func maintenanceWindow(spec *infrav1.MaintenanceWindowSpec, now time.Time) (bool, time.Time) {
+	start, err := time.Parse("15:04", spec.StartTime)
+	if err != nil {
+		// The start time is validated by the CRD, a window which can't be parsed is never open.
+		return false, time.Time{}
+	}
+	days := make(map[string]bool, len(spec.Days))
+	for _, day := range spec.Days {
+		days[string(day)] = true
+	}
+
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), start.Hour(), start.Minute(), 0, 0, time.UTC)
+	// The window which started the day before may still be open.
+	for i := -1; i <= 7; i++ {
+		windowStart := today.AddDate(0, 0, i)
+		if len(days) > 0 && !days[windowStart.Weekday().String()] {
+			continue
+		}
+		if !now.Before(windowStart) && now.Before(windowStart.Add(spec.Duration.Duration)) {
+			return true, windowStart
+		}
+		if windowStart.After(now) {
+			return false, windowStart
+		}
+	}
+
+	return false, time.Time{}
+}
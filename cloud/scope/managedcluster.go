@@ -0,0 +1,215 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+// ManagedClusterScopeParams defines the input parameters used to create a new ManagedClusterScope.
+type ManagedClusterScopeParams struct {
+	GCPClients
+	Cloud             cloud.Cloud
+	Client            client.Client
+	Logger            logr.Logger
+	Cluster           *clusterv1.Cluster
+	GCPManagedCluster *expinfrav1.GCPManagedCluster
+	ManagedTransports
+}
+
+// ManagedTransports are the transports of the GCP API calls of the scopes of the GKE managed clusters, applied in the
+// same order as those of the ClusterScope.
+type ManagedTransports struct {
+	// DryRun, if set, records the mutating GCP calls instead of executing them
+	// and prevents the object of the scope from being persisted.
+	DryRun *cloud.DryRun
+
+	// Stats, if set, collects the statistics of the GCP API calls.
+	Stats *cloud.ClientStats
+
+	// FaultInjection, if set, makes the GCP API calls fail at the configured rates. Test only.
+	FaultInjection *cloud.FaultInjection
+
+	// RateLimiter, if set, limits the rate of the GCP API calls and retries the throttled ones.
+	RateLimiter *cloud.RateLimiter
+
+	// Audit, if set, records the mutating GCP API calls made for the cluster.
+	// The calls skipped in dry-run mode are not recorded.
+	Audit cloud.AuditSink
+}
+
+// NewManagedClusterScope creates a new ManagedClusterScope from the supplied parameters.
+// This is meant to be called for each reconcile iteration.
+func NewManagedClusterScope(params ManagedClusterScopeParams) (*ManagedClusterScope, error) {
+	if params.Cluster == nil {
+		return nil, errors.New("failed to generate new scope from nil Cluster")
+	}
+	if params.GCPManagedCluster == nil {
+		return nil, errors.New("failed to generate new scope from nil GCPManagedCluster")
+	}
+	if params.Logger == nil {
+		params.Logger = klogr.New()
+	}
+	if err := params.GCPClients.setManaged(params.Cloud, params.Cluster, params.ManagedTransports); err != nil {
+		return nil, err
+	}
+
+	helper, err := patch.NewHelper(params.GCPManagedCluster, params.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init patch helper")
+	}
+
+	return &ManagedClusterScope{
+		Logger:            params.Logger,
+		client:            params.Client,
+		patchHelper:       helper,
+		dryRun:            params.DryRun,
+		GCPClients:        params.GCPClients,
+		Cluster:           params.Cluster,
+		GCPManagedCluster: params.GCPManagedCluster,
+	}, nil
+}
+
+// ManagedClusterScope defines the basic context for an actuator to operate upon a GCPManagedCluster.
+type ManagedClusterScope struct {
+	logr.Logger
+	client      client.Client
+	patchHelper *patch.Helper
+	dryRun      *cloud.DryRun
+
+	GCPClients
+	Cluster           *clusterv1.Cluster
+	GCPManagedCluster *expinfrav1.GCPManagedCluster
+}
+
+// Project returns the project of the GKE cluster.
+func (s *ManagedClusterScope) Project() string {
+	return s.GCPManagedCluster.Spec.Project
+}
+
+// Region returns the region of the GKE cluster.
+func (s *ManagedClusterScope) Region() string {
+	return s.GCPManagedCluster.Spec.Region
+}
+
+// NetworkName returns the name of the network of the GKE cluster.
+func (s *ManagedClusterScope) NetworkName() string {
+	return managedNetworkName(s.GCPManagedCluster)
+}
+
+// Close closes the current scope persisting the GCPManagedCluster.
+// It is a no-op in dry-run mode.
+func (s *ManagedClusterScope) Close() error {
+	if s.dryRun != nil {
+		return nil
+	}
+
+	return s.patchHelper.Patch(context.TODO(), s.GCPManagedCluster)
+}
+
+// setManaged sets the clients of the GKE managed clusters from the cloud, defaulting to the GCP APIs, unless
+// they are set already. The API calls go through the transports, if any.
+func (c *GCPClients) setManaged(gcp cloud.Cloud, cluster *clusterv1.Cluster, transports ManagedTransports) error {
+	if c.Compute != nil && c.Container != nil {
+		return nil
+	}
+	if gcp == nil {
+		var err error
+		if gcp, err = cloud.NewCloud(context.TODO(), cloud.Options{}); err != nil {
+			return err
+		}
+	}
+	wraps := []cloud.WrapTransportFunc{}
+	if transports.FaultInjection != nil {
+		wraps = append(wraps, transports.FaultInjection.Wrap)
+	}
+	if transports.RateLimiter != nil {
+		wraps = append(wraps, transports.RateLimiter.Wrap)
+	}
+	if transports.Stats != nil {
+		wraps = append(wraps, transports.Stats.Wrap)
+	}
+	if transports.Audit != nil {
+		audit := &cloud.Audit{
+			Cluster: types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Name},
+			Sink:    transports.Audit,
+		}
+		wraps = append(wraps, audit.Wrap)
+	}
+	if transports.DryRun != nil {
+		wraps = append(wraps, transports.DryRun.Wrap)
+	}
+	for _, wrap := range wraps {
+		var err error
+		if gcp, err = gcp.WithTransport(context.TODO(), wrap); err != nil {
+			return err
+		}
+	}
+	c.Compute = gcp.Compute()
+	c.Container = gcp.Container()
+
+	return nil
+}
+
+// managedNetworkName returns the name of the network of the GCPManagedCluster.
+func managedNetworkName(gcpManagedCluster *expinfrav1.GCPManagedCluster) string {
+	return pointer.StringDeref(gcpManagedCluster.Spec.Network, "default")
+}
+
+// gkeLocation returns the location of the GKE cluster, its zone or the region of the GCPManagedCluster.
+func gkeLocation(gcpManagedCluster *expinfrav1.GCPManagedCluster, controlPlane *expinfrav1.GCPManagedControlPlane) string {
+	return pointer.StringDeref(controlPlane.Spec.Location, gcpManagedCluster.Spec.Region)
+}
+
+// gkeClusterPath returns the resource name of the GKE cluster of the control plane, the one recorded in its status
+// once the GKE cluster is created, or else named after the Cluster by default.
+func gkeClusterPath(cluster *clusterv1.Cluster, gcpManagedCluster *expinfrav1.GCPManagedCluster, controlPlane *expinfrav1.GCPManagedControlPlane) string {
+	if controlPlane.Status.ClusterFullName != "" {
+		return controlPlane.Status.ClusterFullName
+	}
+
+	name := controlPlane.Spec.ClusterName
+	if name == "" {
+		name = cluster.Name
+	}
+
+	return fmt.Sprintf("projects/%s/locations/%s/clusters/%s", gcpManagedCluster.Spec.Project, gkeLocation(gcpManagedCluster, controlPlane), name)
+}
+
+// gkeLabels returns the resource labels of the GKE cluster.
+func gkeLabels(cluster *clusterv1.Cluster, gcpManagedCluster *expinfrav1.GCPManagedCluster) infrav1.Labels {
+	return infrav1.Build(infrav1.BuildParams{
+		ClusterName:      cluster.Name,
+		ClusterNamespace: cluster.Namespace,
+		Lifecycle:        infrav1.ResourceLifecycleOwned,
+		Additional:       gcpManagedCluster.Spec.AdditionalLabels,
+	})
+}
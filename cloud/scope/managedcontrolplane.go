@@ -0,0 +1,196 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"path"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+// ManagedControlPlaneScopeParams defines the input parameters used to create a new ManagedControlPlaneScope.
+type ManagedControlPlaneScopeParams struct {
+	GCPClients
+	Cloud                  cloud.Cloud
+	Client                 client.Client
+	Logger                 logr.Logger
+	Cluster                *clusterv1.Cluster
+	GCPManagedCluster      *expinfrav1.GCPManagedCluster
+	GCPManagedControlPlane *expinfrav1.GCPManagedControlPlane
+	ManagedTransports
+}
+
+// NewManagedControlPlaneScope creates a new ManagedControlPlaneScope from the supplied parameters.
+// This is meant to be called for each reconcile iteration.
+func NewManagedControlPlaneScope(params ManagedControlPlaneScopeParams) (*ManagedControlPlaneScope, error) {
+	if params.Cluster == nil {
+		return nil, errors.New("failed to generate new scope from nil Cluster")
+	}
+	if params.GCPManagedCluster == nil {
+		return nil, errors.New("failed to generate new scope from nil GCPManagedCluster")
+	}
+	if params.GCPManagedControlPlane == nil {
+		return nil, errors.New("failed to generate new scope from nil GCPManagedControlPlane")
+	}
+	if params.Logger == nil {
+		params.Logger = klogr.New()
+	}
+	if err := params.GCPClients.setManaged(params.Cloud, params.Cluster, params.ManagedTransports); err != nil {
+		return nil, err
+	}
+
+	helper, err := patch.NewHelper(params.GCPManagedControlPlane, params.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init patch helper")
+	}
+
+	return &ManagedControlPlaneScope{
+		Logger:                 params.Logger,
+		client:                 params.Client,
+		patchHelper:            helper,
+		dryRun:                 params.DryRun,
+		GCPClients:             params.GCPClients,
+		Cluster:                params.Cluster,
+		GCPManagedCluster:      params.GCPManagedCluster,
+		GCPManagedControlPlane: params.GCPManagedControlPlane,
+	}, nil
+}
+
+// ManagedControlPlaneScope defines the basic context for an actuator to operate upon a GCPManagedControlPlane.
+type ManagedControlPlaneScope struct {
+	logr.Logger
+	client      client.Client
+	patchHelper *patch.Helper
+	dryRun      *cloud.DryRun
+
+	GCPClients
+	Cluster                *clusterv1.Cluster
+	GCPManagedCluster      *expinfrav1.GCPManagedCluster
+	GCPManagedControlPlane *expinfrav1.GCPManagedControlPlane
+}
+
+// ManagedMachinePool is a MachinePool of the cluster and its GCPManagedMachinePool.
+type ManagedMachinePool struct {
+	MachinePool           *expclusterv1.MachinePool
+	GCPManagedMachinePool *expinfrav1.GCPManagedMachinePool
+}
+
+// Project returns the project of the GKE cluster.
+func (s *ManagedControlPlaneScope) Project() string {
+	return s.GCPManagedCluster.Spec.Project
+}
+
+// Location returns the location of the GKE cluster, its region or its zone.
+func (s *ManagedControlPlaneScope) Location() string {
+	return gkeLocation(s.GCPManagedCluster, s.GCPManagedControlPlane)
+}
+
+// Zonal returns true if the GKE cluster is zonal.
+func (s *ManagedControlPlaneScope) Zonal() bool {
+	return s.Location() != s.GCPManagedCluster.Spec.Region
+}
+
+// ClusterFullName returns the resource name of the GKE cluster, e.g.
+// projects/my-project/locations/us-central1/clusters/my-cluster.
+func (s *ManagedControlPlaneScope) ClusterFullName() string {
+	return gkeClusterPath(s.Cluster, s.GCPManagedCluster, s.GCPManagedControlPlane)
+}
+
+// ClusterName returns the name of the GKE cluster.
+func (s *ManagedControlPlaneScope) ClusterName() string {
+	return path.Base(s.ClusterFullName())
+}
+
+// LocationFullName returns the resource name of the location of the GKE cluster, its parent.
+func (s *ManagedControlPlaneScope) LocationFullName() string {
+	return path.Dir(path.Dir(s.ClusterFullName()))
+}
+
+// NetworkName returns the name of the network of the GKE cluster.
+func (s *ManagedControlPlaneScope) NetworkName() string {
+	return managedNetworkName(s.GCPManagedCluster)
+}
+
+// SubnetworkName returns the name of the subnetwork of the GKE cluster, empty if picked by GKE.
+func (s *ManagedControlPlaneScope) SubnetworkName() string {
+	return pointer.StringDeref(s.GCPManagedCluster.Spec.Subnetwork, "")
+}
+
+// ResourceLabels returns the resource labels of the GKE cluster.
+func (s *ManagedControlPlaneScope) ResourceLabels() infrav1.Labels {
+	return gkeLabels(s.Cluster, s.GCPManagedCluster)
+}
+
+// ShouldAdopt returns true if the pre-existing GKE cluster of the same name must be adopted.
+func (s *ManagedControlPlaneScope) ShouldAdopt() bool {
+	_, ok := s.GCPManagedControlPlane.Annotations[infrav1.AdoptAnnotation]
+
+	return ok
+}
+
+// MachinePools returns the MachinePools of the cluster whose infrastructure is a GCPManagedMachinePool.
+func (s *ManagedControlPlaneScope) MachinePools(ctx context.Context) ([]ManagedMachinePool, error) {
+	machinePools := &expclusterv1.MachinePoolList{}
+	if err := s.client.List(ctx, machinePools, client.InNamespace(s.Cluster.Namespace),
+		client.MatchingLabels{clusterv1.ClusterLabelName: s.Cluster.Name}); err != nil {
+		return nil, errors.Wrap(err, "failed to list the machine pools of the cluster")
+	}
+
+	res := []ManagedMachinePool{}
+	for i := range machinePools.Items {
+		mp := &machinePools.Items[i]
+		ref := mp.Spec.Template.Spec.InfrastructureRef
+		if ref.Kind != "GCPManagedMachinePool" || !strings.HasPrefix(ref.APIVersion, expinfrav1.GroupVersion.Group+"/") {
+			continue
+		}
+		pool := &expinfrav1.GCPManagedMachinePool{}
+		if err := s.client.Get(ctx, client.ObjectKey{Namespace: mp.Namespace, Name: ref.Name}, pool); err != nil {
+			return nil, errors.Wrapf(err, "failed to get GCPManagedMachinePool %s", ref.Name)
+		}
+		res = append(res, ManagedMachinePool{MachinePool: mp, GCPManagedMachinePool: pool})
+	}
+
+	return res, nil
+}
+
+// DryRun returns the recorder of the planned GCP operations, nil if the scope is not in dry-run mode.
+func (s *ManagedControlPlaneScope) DryRun() *cloud.DryRun {
+	return s.dryRun
+}
+
+// Close closes the current scope persisting the GCPManagedControlPlane.
+// It is a no-op in dry-run mode.
+func (s *ManagedControlPlaneScope) Close() error {
+	if s.dryRun != nil {
+		return nil
+	}
+
+	return s.patchHelper.Patch(context.TODO(), s.GCPManagedControlPlane)
+}
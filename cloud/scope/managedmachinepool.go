@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/klog/v2/klogr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+// ManagedMachinePoolScopeParams defines the input parameters used to create a new ManagedMachinePoolScope.
+type ManagedMachinePoolScopeParams struct {
+	GCPClients
+	Cloud                  cloud.Cloud
+	Client                 client.Client
+	Logger                 logr.Logger
+	Cluster                *clusterv1.Cluster
+	MachinePool            *expclusterv1.MachinePool
+	GCPManagedCluster      *expinfrav1.GCPManagedCluster
+	GCPManagedControlPlane *expinfrav1.GCPManagedControlPlane
+	GCPManagedMachinePool  *expinfrav1.GCPManagedMachinePool
+	ManagedTransports
+}
+
+// NewManagedMachinePoolScope creates a new ManagedMachinePoolScope from the supplied parameters.
+// This is meant to be called for each reconcile iteration.
+func NewManagedMachinePoolScope(params ManagedMachinePoolScopeParams) (*ManagedMachinePoolScope, error) {
+	if params.Cluster == nil {
+		return nil, errors.New("failed to generate new scope from nil Cluster")
+	}
+	if params.MachinePool == nil {
+		return nil, errors.New("failed to generate new scope from nil MachinePool")
+	}
+	if params.GCPManagedCluster == nil {
+		return nil, errors.New("failed to generate new scope from nil GCPManagedCluster")
+	}
+	if params.GCPManagedControlPlane == nil {
+		return nil, errors.New("failed to generate new scope from nil GCPManagedControlPlane")
+	}
+	if params.GCPManagedMachinePool == nil {
+		return nil, errors.New("failed to generate new scope from nil GCPManagedMachinePool")
+	}
+	if params.Logger == nil {
+		params.Logger = klogr.New()
+	}
+	if err := params.GCPClients.setManaged(params.Cloud, params.Cluster, params.ManagedTransports); err != nil {
+		return nil, err
+	}
+
+	helper, err := patch.NewHelper(params.GCPManagedMachinePool, params.Client)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init patch helper")
+	}
+
+	return &ManagedMachinePoolScope{
+		Logger:                 params.Logger,
+		patchHelper:            helper,
+		dryRun:                 params.DryRun,
+		GCPClients:             params.GCPClients,
+		Cluster:                params.Cluster,
+		MachinePool:            params.MachinePool,
+		GCPManagedCluster:      params.GCPManagedCluster,
+		GCPManagedControlPlane: params.GCPManagedControlPlane,
+		GCPManagedMachinePool:  params.GCPManagedMachinePool,
+	}, nil
+}
+
+// ManagedMachinePoolScope defines the basic context for an actuator to operate upon a GCPManagedMachinePool.
+type ManagedMachinePoolScope struct {
+	logr.Logger
+	patchHelper *patch.Helper
+	dryRun      *cloud.DryRun
+
+	GCPClients
+	Cluster                *clusterv1.Cluster
+	MachinePool            *expclusterv1.MachinePool
+	GCPManagedCluster      *expinfrav1.GCPManagedCluster
+	GCPManagedControlPlane *expinfrav1.GCPManagedControlPlane
+	GCPManagedMachinePool  *expinfrav1.GCPManagedMachinePool
+}
+
+// Project returns the project of the GKE cluster.
+func (s *ManagedMachinePoolScope) Project() string {
+	return s.GCPManagedCluster.Spec.Project
+}
+
+// Zonal returns true if the GKE cluster is zonal.
+func (s *ManagedMachinePoolScope) Zonal() bool {
+	return gkeLocation(s.GCPManagedCluster, s.GCPManagedControlPlane) != s.GCPManagedCluster.Spec.Region
+}
+
+// ClusterFullName returns the resource name of the GKE cluster of the node pool.
+func (s *ManagedMachinePoolScope) ClusterFullName() string {
+	return gkeClusterPath(s.Cluster, s.GCPManagedCluster, s.GCPManagedControlPlane)
+}
+
+// NodePoolName returns the name of the node pool.
+func (s *ManagedMachinePoolScope) NodePoolName() string {
+	return NodePoolName(s.GCPManagedMachinePool)
+}
+
+// NodePoolFullName returns the resource name of the node pool, e.g.
+// projects/my-project/locations/us-central1/clusters/my-cluster/nodePools/my-pool.
+func (s *ManagedMachinePoolScope) NodePoolFullName() string {
+	return s.ClusterFullName() + "/nodePools/" + s.NodePoolName()
+}
+
+// Close closes the current scope persisting the GCPManagedMachinePool.
+// It is a no-op in dry-run mode.
+func (s *ManagedMachinePoolScope) Close() error {
+	if s.dryRun != nil {
+		return nil
+	}
+
+	return s.patchHelper.Patch(context.TODO(), s.GCPManagedMachinePool)
+}
+
+// NodePoolName returns the name of the node pool of the GCPManagedMachinePool.
+func NodePoolName(gcpManagedMachinePool *expinfrav1.GCPManagedMachinePool) string {
+	if gcpManagedMachinePool.Spec.NodePoolName != "" {
+		return gcpManagedMachinePool.Spec.NodePoolName
+	}
+
+	return gcpManagedMachinePool.Name
+}
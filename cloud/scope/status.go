@@ -0,0 +1,172 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// applyStatus persists the status of the object with a server-side apply by the field manager. The
+// applied configuration only has the status, the metadata and the spec are left to the patch helper.
+// The field manager forces the ownership of the fields, every field of the status being written by
+// a single controller.
+func applyStatus(ctx context.Context, c client.Client, obj client.Object, status interface{}, fieldManager string) error {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(status)
+	if err != nil {
+		return errors.Wrap(err, "failed to convert the status")
+	}
+	if err := upgradeStatusOwnership(ctx, c, obj, gvk, fieldManager); err != nil {
+		// The object is gone once its finalizer has been removed.
+		if apierrors.IsNotFound(err) && !obj.GetDeletionTimestamp().IsZero() {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to upgrade the ownership of the status of %s %s/%s", gvk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+
+	applied := &unstructured.Unstructured{Object: map[string]interface{}{"status": content}}
+	applied.SetGroupVersionKind(gvk)
+	applied.SetNamespace(obj.GetNamespace())
+	applied.SetName(obj.GetName())
+	if err := c.Status().Patch(ctx, applied, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		// The object is gone once its finalizer has been removed.
+		if apierrors.IsNotFound(err) && !obj.GetDeletionTimestamp().IsZero() {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to apply the status of %s %s/%s", gvk.Kind, obj.GetNamespace(), obj.GetName())
+	}
+	obj.SetResourceVersion(applied.GetResourceVersion())
+
+	return nil
+}
+
+// upgradeStatusOwnership hands the fields of the status owned by the updates over to the field manager, as
+// if they had been applied by it. The status was patched with the rest of the object before it was applied,
+// and the fields only owned by the updates would never be removed by the applies, e.g. the zones dropped
+// from the failure domains.
+func upgradeStatusOwnership(ctx context.Context, c client.Client, obj client.Object, gvk schema.GroupVersionKind, fieldManager string) error {
+	apiVersion := gvk.GroupVersion().String()
+
+	// The managed fields of the cached object tell whether the status was updated, they are read again
+	// from the API server to be rewritten, the typed ones missing the subresource of the entries.
+	updated := false
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Operation == metav1.ManagedFieldsOperationUpdate && entry.APIVersion == apiVersion && entry.FieldsV1 != nil {
+			fields := map[string]interface{}{}
+			if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err == nil && fields["f:status"] != nil {
+				updated = true
+			}
+		}
+	}
+	if !updated {
+		return nil
+	}
+
+	live := &unstructured.Unstructured{}
+	live.SetGroupVersionKind(gvk)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		return err
+	}
+	entries, _, err := unstructured.NestedSlice(live.Object, "metadata", "managedFields")
+	if err != nil {
+		return err
+	}
+
+	var managedFields []interface{}
+	var applied map[string]interface{}
+	status := map[string]interface{}{}
+	for _, e := range entries {
+		entry, ok := e.(map[string]interface{})
+		if !ok || entry["apiVersion"] != apiVersion {
+			managedFields = append(managedFields, e)
+			continue
+		}
+		fields, _ := entry["fieldsV1"].(map[string]interface{})
+
+		switch {
+		case entry["manager"] == fieldManager && entry["operation"] == string(metav1.ManagedFieldsOperationApply):
+			applied = entry
+		case entry["operation"] == string(metav1.ManagedFieldsOperationUpdate) && fields["f:status"] != nil:
+			mergeFields(status, map[string]interface{}{"f:status": fields["f:status"]})
+			delete(fields, "f:status")
+			// The entries which only owned the status are dropped.
+			if len(fields) == 0 {
+				continue
+			}
+		}
+		managedFields = append(managedFields, entry)
+	}
+	if len(status) == 0 {
+		return nil
+	}
+	if applied == nil {
+		applied = map[string]interface{}{
+			"manager":    fieldManager,
+			"operation":  string(metav1.ManagedFieldsOperationApply),
+			"apiVersion": apiVersion,
+			"fieldsType": "FieldsV1",
+		}
+		managedFields = append(managedFields, applied)
+	}
+	fields, _ := applied["fieldsV1"].(map[string]interface{})
+	if fields == nil {
+		fields = map[string]interface{}{}
+	}
+	mergeFields(fields, status)
+	applied["fieldsV1"] = fields
+	applied["subresource"] = "status"
+
+	// The resource version makes the patch fail if the managed fields were changed since they were read.
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"resourceVersion": live.GetResourceVersion(),
+			"managedFields":   managedFields,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.Patch(ctx, live, client.RawPatch(types.MergePatchType, patch))
+}
+
+// mergeFields merges the field set src into dst.
+func mergeFields(dst, src map[string]interface{}) {
+	for key, value := range src {
+		srcFields, srcOK := value.(map[string]interface{})
+		dstFields, dstOK := dst[key].(map[string]interface{})
+		if srcOK && dstOK {
+			mergeFields(dstFields, srcFields)
+			continue
+		}
+		dst[key] = value
+	}
+}
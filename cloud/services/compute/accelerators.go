@@ -0,0 +1,83 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+const (
+	// startupScriptKey is the metadata key of the script run by the guest environment at each boot.
+	startupScriptKey = "startup-script"
+
+	// installNvidiaDriverKey is the metadata key making the Deep Learning VM images install the NVIDIA driver.
+	installNvidiaDriverKey = "install-nvidia-driver"
+
+	// cosGPUDriverScript installs the NVIDIA driver on Container-Optimized OS and makes it executable,
+	// see https://cloud.google.com/container-optimized-os/docs/how-to/run-gpus.
+	cosGPUDriverScript = `#! /bin/bash
+cos-extensions install gpu
+mount --bind /var/lib/nvidia /var/lib/nvidia
+mount -o remount,exec /var/lib/nvidia
+`
+
+	// linuxGPUDriverScript installs the NVIDIA driver with the installation script of GCP,
+	// see https://cloud.google.com/compute/docs/gpus/install-drivers-gpu.
+	linuxGPUDriverScript = `#! /bin/bash
+if ! command -v nvidia-smi > /dev/null; then
+  curl -fsSL https://raw.githubusercontent.com/GoogleCloudPlatform/compute-gpu-installation/main/linux/install_gpu_driver.py --output /tmp/install_gpu_driver.py
+  python3 /tmp/install_gpu_driver.py
+fi
+`
+)
+
+// guestAccelerators returns the accelerator configs of the instance in the zone. The accelerator types are
+// referenced by name in the instance templates, which aren't zonal, with an empty zone.
+func guestAccelerators(zone string, accelerators []infrav1.Accelerator) []*compute.AcceleratorConfig {
+	res := make([]*compute.AcceleratorConfig, 0, len(accelerators))
+	for _, a := range accelerators {
+		acceleratorType := a.Type
+		if zone != "" {
+			acceleratorType = fmt.Sprintf("zones/%s/acceleratorTypes/%s", zone, a.Type)
+		}
+		res = append(res, &compute.AcceleratorConfig{
+			AcceleratorType:  acceleratorType,
+			AcceleratorCount: a.Count,
+		})
+	}
+
+	return res
+}
+
+// gpuDriverMetadata returns the metadata installing the NVIDIA driver on the instances of the image,
+// running Container-Optimized OS if cos is true.
+func gpuDriverMetadata(image string, cos bool) *compute.MetadataItems {
+	switch {
+	case cos:
+		return &compute.MetadataItems{Key: startupScriptKey, Value: pointer.StringPtr(cosGPUDriverScript)}
+	case strings.Contains(image, "projects/deeplearning-platform-release/"):
+		return &compute.MetadataItems{Key: installNvidiaDriverKey, Value: pointer.StringPtr("True")}
+	default:
+		return &compute.MetadataItems{Key: startupScriptKey, Value: pointer.StringPtr(linuxGPUDriverScript)}
+	}
+}
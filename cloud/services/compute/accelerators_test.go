@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestCreateInstanceGPUDriver(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	newGCPMachine := func(name, image string) *infrav1.GCPMachine {
+		return &infrav1.GCPMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec: infrav1.GCPMachineSpec{
+				InstanceType:      "n1-standard-4",
+				Image:             pointer.StringPtr(image),
+				GuestAccelerators: []infrav1.Accelerator{{Type: "nvidia-tesla-t4", Count: 1}},
+				InstallGPUDriver:  true,
+			},
+		}
+	}
+
+	instance := createTestInstance(g, s, newGCPMachine("my-cos-machine", "projects/cos-cloud/global/images/family/cos-stable"))
+	g.Expect(instance.GuestAccelerators).To(Equal([]*compute.AcceleratorConfig{
+		{AcceleratorType: "zones/us-central1-a/acceleratorTypes/nvidia-tesla-t4", AcceleratorCount: 1},
+	}))
+	g.Expect(instance.Scheduling.OnHostMaintenance).To(Equal("TERMINATE"))
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(startupScriptKey, cosGPUDriverScript))
+
+	instance = createTestInstance(g, s, newGCPMachine("my-dlvm-machine", "projects/deeplearning-platform-release/global/images/family/common-cu113"))
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(installNvidiaDriverKey, "True"))
+	g.Expect(instanceMetadata(instance)).NotTo(HaveKey(startupScriptKey))
+
+	instance = createTestInstance(g, s, newGCPMachine("my-ubuntu-machine", "projects/my-project/global/images/family/capi-ubuntu-1804-k8s-v1-21"))
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(startupScriptKey, linuxGPUDriverScript))
+
+	// The startup script of the user is kept.
+	gcpMachine := newGCPMachine("my-scripted-machine", "my-image")
+	gcpMachine.Spec.AdditionalMetadata = []infrav1.MetadataItem{{Key: startupScriptKey, Value: pointer.StringPtr("echo hello")}}
+	instance = createTestInstance(g, s, gcpMachine)
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(startupScriptKey, "echo hello"))
+}
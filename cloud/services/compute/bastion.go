@@ -0,0 +1,274 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+const (
+	// bastionRole is the role of the bastion in its name, network tag and labels.
+	bastionRole = "bastion"
+
+	defaultBastionInstanceType = "e2-micro"
+	defaultBastionImage        = "projects/debian-cloud/global/images/family/debian-11"
+
+	// iapSourceRange is the range of the IAP TCP forwarding connections.
+	// For more information, https://cloud.google.com/iap/docs/using-tcp-forwarding#create-firewall-rule.
+	iapSourceRange = "35.235.240.0/20"
+)
+
+// ReconcileBastion creates the bastion host of the cluster and its firewall rules, and records its
+// addresses in the cluster status. The bastion is deleted if it was removed from the cluster spec.
+// The bastion isn't updated once created, it must be removed and added again to apply changes.
+func (s *Service) ReconcileBastion() error {
+	spec := s.scope.GCPCluster.Spec.Bastion
+	if spec == nil {
+		if s.scope.GCPCluster.Status.Bastion != nil {
+			return s.DeleteBastion()
+		}
+
+		return nil
+	}
+
+	for _, firewallSpec := range s.getBastionFirewallSpecs(spec) {
+		if err := s.reconcileFirewall(firewallSpec); err != nil {
+			return err
+		}
+	}
+
+	zone, err := s.bastionZone(spec)
+	if err != nil {
+		return err
+	}
+
+	name := s.bastionName()
+	instance, err := s.instances.Get(s.scope.Project(), zone, name).Do()
+	if gcperrors.IsNotFound(err) {
+		input := s.getBastionInstanceSpec(spec, zone)
+		if err := s.runInsertOperation(path.Join("zones", zone, "instances", name), func() (*compute.Operation, error) {
+			return s.instances.Insert(s.scope.Project(), zone, input).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create bastion")
+		}
+		instance, err = s.instances.Get(s.scope.Project(), zone, name).Do()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe bastion")
+	}
+
+	status := &infrav1.BastionStatus{
+		SelfLink: instance.SelfLink,
+	}
+	for _, nic := range instance.NetworkInterfaces {
+		status.PrivateIP = nic.NetworkIP
+		if len(nic.AccessConfigs) > 0 {
+			status.PublicIP = nic.AccessConfigs[0].NatIP
+		}
+	}
+	s.scope.GCPCluster.Status.Bastion = status
+
+	return nil
+}
+
+// DeleteBastion deletes the bastion host of the cluster and its firewall rules.
+func (s *Service) DeleteBastion() error {
+	if s.scope.GCPCluster.Status.Bastion != nil || s.scope.GCPCluster.Spec.Bastion != nil {
+		zone, err := s.bastionZone(s.scope.GCPCluster.Spec.Bastion)
+		if err != nil {
+			return err
+		}
+		name := s.bastionName()
+		if err := s.runDeleteOperation(path.Join("zones", zone, "instances", name), func() (*compute.Operation, error) {
+			return s.instances.Delete(s.scope.Project(), zone, name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete bastion")
+		}
+	}
+
+	for _, name := range s.bastionFirewallNames() {
+		if err := s.deleteFirewall(name); err != nil {
+			return err
+		}
+	}
+	s.scope.GCPCluster.Status.Bastion = nil
+
+	return nil
+}
+
+func (s *Service) bastionName() string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), bastionRole))
+}
+
+// bastionZone returns the zone of the existing bastion, or the zone set in its spec, defaulting to the
+// first zone of the region.
+func (s *Service) bastionZone(spec *infrav1.BastionSpec) (string, error) {
+	if status := s.scope.GCPCluster.Status.Bastion; status != nil {
+		// The self link has the format .../zones/<zone>/instances/<name>.
+		return path.Base(path.Dir(path.Dir(status.SelfLink))), nil
+	}
+	if spec != nil && spec.Zone != nil {
+		return *spec.Zone, nil
+	}
+
+	zones, err := s.GetZones()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get zones for the bastion")
+	}
+	if len(zones) == 0 {
+		return "", errors.Errorf("no zone available for the bastion in region %q", s.scope.Region())
+	}
+	sort.Strings(zones)
+
+	return zones[0], nil
+}
+
+func (s *Service) getBastionInstanceSpec(spec *infrav1.BastionSpec, zone string) *compute.Instance {
+	instanceType := spec.InstanceType
+	if instanceType == "" {
+		instanceType = defaultBastionInstanceType
+	}
+
+	// The bastion doesn't use any GCP API, it has no service account.
+	input := &compute.Instance{
+		Name:        s.bastionName(),
+		Zone:        zone,
+		MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", zone, instanceType),
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			Network: s.scope.NetworkSelfLink(),
+		}},
+		Tags: &compute.Tags{
+			Items: []string{
+				s.roleTag(bastionRole),
+			},
+		},
+		Disks: []*compute.AttachedDisk{
+			{
+				AutoDelete: true,
+				Boot:       true,
+				InitializeParams: &compute.AttachedDiskInitializeParams{
+					SourceImage: pointer.StringDeref(spec.Image, defaultBastionImage),
+				},
+			},
+		},
+		Labels: s.ownershipLabels(bastionRole),
+	}
+	input.Disks[0].InitializeParams.Labels = input.Labels
+
+	if !spec.IAPOnly {
+		input.NetworkInterfaces[0].AccessConfigs = []*compute.AccessConfig{
+			{
+				Type: "ONE_TO_ONE_NAT",
+				Name: "External NAT",
+			},
+		}
+	}
+
+	if spec.Subnet != nil {
+		input.NetworkInterfaces[0].Subnetwork = fmt.Sprintf("regions/%s/subnetworks/%s",
+			s.scope.Region(), *spec.Subnet)
+	}
+
+	if s.scope.SecurityProfile() == infrav1.SecurityProfileHardened {
+		input.ShieldedInstanceConfig = &compute.ShieldedInstanceConfig{
+			EnableSecureBoot:          true,
+			EnableVtpm:                true,
+			EnableIntegrityMonitoring: true,
+		}
+		input.Metadata = &compute.Metadata{
+			Items: []*compute.MetadataItems{
+				{
+					Key:   enableOSLoginKey,
+					Value: pointer.StringPtr("TRUE"),
+				},
+			},
+		}
+	}
+
+	return input
+}
+
+func (s *Service) bastionFirewallNames() []string {
+	prefix := fmt.Sprintf("allow-%s-%s", s.scope.ResourceNamePrefix(), bastionRole)
+
+	return []string{
+		names.Truncate(prefix + "-ssh"),
+		names.Truncate(prefix + "-cluster"),
+	}
+}
+
+// getBastionFirewallSpecs returns the rules allowing SSH to the bastion, from the allowed source ranges
+// or from IAP only, and from the bastion to the instances of the cluster.
+func (s *Service) getBastionFirewallSpecs(spec *infrav1.BastionSpec) []*compute.Firewall {
+	sourceRanges := []string{iapSourceRange}
+	if !spec.IAPOnly {
+		sourceRanges = spec.AllowedSourceRanges
+		if len(sourceRanges) == 0 {
+			sourceRanges = []string{"0.0.0.0/0"}
+		}
+	}
+
+	firewallNames := s.bastionFirewallNames()
+
+	return []*compute.Firewall{
+		{
+			Name:        firewallNames[0],
+			Description: s.namespacedOwnershipMarker(),
+			Network:     s.scope.NetworkSelfLink(),
+			Allowed: []*compute.FirewallAllowed{
+				{
+					IPProtocol: "TCP",
+					Ports:      []string{"22"},
+				},
+			},
+			Direction:    "INGRESS",
+			SourceRanges: sourceRanges,
+			TargetTags: []string{
+				s.roleTag(bastionRole),
+			},
+		},
+		{
+			Name:        firewallNames[1],
+			Description: s.namespacedOwnershipMarker(),
+			Network:     s.scope.NetworkSelfLink(),
+			Allowed: []*compute.FirewallAllowed{
+				{
+					IPProtocol: "TCP",
+					Ports:      []string{"22"},
+				},
+			},
+			Direction: "INGRESS",
+			SourceTags: []string{
+				s.roleTag(bastionRole),
+			},
+			TargetTags: []string{
+				s.roleTag("control-plane"),
+				s.roleTag("node"),
+			},
+		},
+	}
+}
@@ -0,0 +1,65 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileBastion(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.Bastion = &infrav1.BastionSpec{IAPOnly: true}
+	clusterScope := newTestClusterScopeFromParams(g, params)
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileBastion()).To(Succeed())
+
+	// The IAP-only bastion is created in the first zone of the region, without an external IP.
+	instance := &compute.Instance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-cluster-bastion", instance)).To(BeTrue())
+	g.Expect(instance.MachineType).To(HaveSuffix("machineTypes/e2-micro"))
+	g.Expect(instance.NetworkInterfaces[0].AccessConfigs).To(BeEmpty())
+	g.Expect(instance.Tags.Items).To(ConsistOf("my-cluster-bastion"))
+	g.Expect(clusterScope.GCPCluster.Status.Bastion).NotTo(BeNil())
+	g.Expect(clusterScope.GCPCluster.Status.Bastion.SelfLink).To(Equal(instance.SelfLink))
+
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-bastion-ssh", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceRanges).To(ConsistOf(iapSourceRange))
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-bastion-cluster", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceTags).To(ConsistOf("my-cluster-bastion"))
+	g.Expect(firewall.TargetTags).To(ConsistOf("my-cluster-control-plane", "my-cluster-node"))
+
+	// Removing the bastion from the spec deletes it.
+	clusterScope.GCPCluster.Spec.Bastion = nil
+	g.Expect(s.ReconcileBastion()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-cluster-bastion", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-bastion-ssh", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-bastion-cluster", nil)).To(BeFalse())
+	g.Expect(clusterScope.GCPCluster.Status.Bastion).To(BeNil())
+	g.Expect(clusterScope.GCPCluster.Status.Network.FirewallRules).To(BeEmpty())
+}
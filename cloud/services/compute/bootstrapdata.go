@@ -0,0 +1,105 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/storage/v1"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+// bootstrapDataFile is the file the bootstrap data shim downloads the bootstrap data to.
+const bootstrapDataFile = "/etc/capg-bootstrap-data"
+
+// bootstrapDataShimBoundary is the boundary of the parts of the bootstrap data shim.
+const bootstrapDataShimBoundary = "==CAPG-BOOTSTRAP-DATA=="
+
+// bootstrapDataObject returns the name of the Cloud Storage object of the bootstrap data of the instance.
+func (s *Service) bootstrapDataObject(scope *scope.MachineScope) string {
+	return path.Join(s.scope.Name(), scope.InstanceName(), "bootstrap-data")
+}
+
+// uploadBootstrapData uploads the bootstrap data of the instance to the bootstrap data bucket of its GCPMachine,
+// and returns the cloud-init shim fetching it at boot, in place of the bootstrap data in the user-data metadata.
+func (s *Service) uploadBootstrapData(scope *scope.MachineScope, bootstrapData string) (string, error) {
+	if s.objects == nil {
+		return "", errors.New("the Cloud Storage client isn't configured")
+	}
+	bucket, name := *scope.GCPMachine.Spec.BootstrapDataBucket, s.bootstrapDataObject(scope)
+
+	object := &storage.Object{
+		Name:        name,
+		ContentType: "text/plain",
+		Metadata:    s.instanceLabels(scope),
+	}
+	// The bootstrap data holds the credentials joining the node to the cluster, the object is only readable
+	// by its owner and the IAM members of the bucket, whatever the default ACL of the objects of the bucket.
+	if _, err := s.objects.Insert(bucket, object).PredefinedAcl("private").Media(strings.NewReader(bootstrapData)).Do(); err != nil {
+		return "", errors.Wrapf(err, "failed to upload bootstrap data to gs://%s/%s", bucket, name)
+	}
+	record.Eventf(scope.GCPMachine, "SuccessfulUploadBootstrapData", "Uploaded bootstrap data to gs://%s/%s", bucket, name)
+
+	return bootstrapDataShim(bucket, name), nil
+}
+
+// bootstrapDataShim returns a cloud-init multipart archive downloading the object with the token of the service
+// account of the instance in a boothook, run before the user data is processed, and including the downloaded file
+// as the actual user data.
+func bootstrapDataShim(bucket, name string) string {
+	objectURL := fmt.Sprintf("https://storage.googleapis.com/storage/v1/b/%s/o/%s?alt=media", bucket, url.PathEscape(name))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Content-Type: multipart/mixed; boundary=\"%s\"\nMIME-Version: 1.0\n\n", bootstrapDataShimBoundary)
+	fmt.Fprintf(&b, "--%s\nContent-Type: text/cloud-boothook; charset=\"us-ascii\"\n\n", bootstrapDataShimBoundary)
+	b.WriteString("#!/bin/bash\nset -o errexit -o pipefail\n")
+	b.WriteString("token=$(curl --silent --fail --retry 10 --header 'Metadata-Flavor: Google' " +
+		"http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token " +
+		"| sed -E 's/.*\"access_token\" *: *\"([^\"]*)\".*/\\1/')\n")
+	fmt.Fprintf(&b, "curl --silent --fail --retry 10 --header \"Authorization: Bearer ${token}\" --output %s '%s'\n", bootstrapDataFile, objectURL)
+	fmt.Fprintf(&b, "chmod 0600 %s\n\n", bootstrapDataFile)
+	fmt.Fprintf(&b, "--%s\nContent-Type: text/x-include-url; charset=\"us-ascii\"\n\n", bootstrapDataShimBoundary)
+	fmt.Fprintf(&b, "#include\nfile://%s\n\n", bootstrapDataFile)
+	fmt.Fprintf(&b, "--%s--\n", bootstrapDataShimBoundary)
+
+	return b.String()
+}
+
+// DeleteBootstrapData deletes the bootstrap data of the instance from the bootstrap data bucket of its GCPMachine, if any.
+func (s *Service) DeleteBootstrapData(scope *scope.MachineScope) error {
+	if scope.GCPMachine.Spec.BootstrapDataBucket == nil || s.objects == nil {
+		return nil
+	}
+	bucket, name := *scope.GCPMachine.Spec.BootstrapDataBucket, s.bootstrapDataObject(scope)
+
+	if err := s.objects.Delete(bucket, name).Do(); err != nil {
+		if gcperrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to delete bootstrap data gs://%s/%s", bucket, name)
+	}
+	record.Eventf(scope.GCPMachine, "SuccessfulDeleteBootstrapData", "Deleted bootstrap data gs://%s/%s", bucket, name)
+
+	return nil
+}
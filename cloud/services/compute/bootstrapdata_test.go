@@ -0,0 +1,109 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/storage/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+func TestBootstrapDataDryRun(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	// The bootstrap data uploaded by a previous reconcile.
+	clusterScope := newTestClusterScope(g, c)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	machineScope.GCPMachine.Spec.BootstrapDataBucket = pointer.StringPtr("my-bucket")
+	_, err := NewService(clusterScope).uploadBootstrapData(machineScope, "#cloud-config")
+	g.Expect(err).NotTo(HaveOccurred())
+
+	dryRun := &cloud.DryRun{}
+	params := newTestClusterScopeParams(g, c)
+	params.DryRun = dryRun
+	dryRunScope, err := scope.NewClusterScope(params)
+	g.Expect(err).NotTo(HaveOccurred())
+	s := NewService(dryRunScope)
+	_, err = s.uploadBootstrapData(machineScope, "#cloud-config\nruncmd: []")
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.DeleteBootstrapData(machineScope)).To(Succeed())
+
+	// The object is neither replaced nor deleted.
+	content, ok := c.GetObject("my-bucket", "my-cluster/my-machine/bootstrap-data", nil)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(content).To(Equal("#cloud-config"))
+	planned := []string{}
+	for _, op := range dryRun.Operations() {
+		planned = append(planned, op.String())
+	}
+	g.Expect(planned).To(ConsistOf(
+		"insert b/my-bucket/o",
+		"delete b/my-bucket/o/my-cluster/my-machine/bootstrap-data",
+	))
+}
+
+func TestCreateInstanceBootstrapDataBucket(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	clusterScope.GCPCluster.Spec.SecurityProfile = infrav1.SecurityProfileHardened
+	s := NewService(clusterScope)
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:        "n1-standard-2",
+			Image:               pointer.StringPtr("my-image"),
+			BootstrapDataBucket: pointer.StringPtr("my-bucket"),
+		},
+	})
+
+	// The bootstrap data is uploaded to the bucket, the user-data only fetches it.
+	var object storage.Object
+	content, ok := c.GetObject("my-bucket", "my-cluster/my-machine/bootstrap-data", &object)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(content).To(Equal("#cloud-config"))
+	g.Expect(object.Metadata).To(Equal(instance.Labels))
+	g.Expect(object.Acl).To(HaveLen(1))
+	g.Expect(object.Acl[0].Role).To(Equal("OWNER"))
+	userData := instanceMetadata(instance)["user-data"]
+	g.Expect(userData).To(HavePrefix("Content-Type: multipart/mixed"))
+	g.Expect(userData).To(ContainSubstring("https://storage.googleapis.com/storage/v1/b/my-bucket/o/my-cluster%2Fmy-machine%2Fbootstrap-data?alt=media"))
+	g.Expect(userData).To(ContainSubstring("#include\nfile:///etc/capg-bootstrap-data"))
+	g.Expect(instance.ServiceAccounts[0].Scopes).To(ContainElement(compute.DevstorageReadOnlyScope))
+
+	// The bootstrap data is deleted with the machine, once.
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	machineScope.GCPMachine.Spec.BootstrapDataBucket = pointer.StringPtr("my-bucket")
+	g.Expect(s.DeleteBootstrapData(machineScope)).To(Succeed())
+	_, ok = c.GetObject("my-bucket", "my-cluster/my-machine/bootstrap-data", &object)
+	g.Expect(ok).To(BeFalse())
+	g.Expect(s.DeleteBootstrapData(machineScope)).To(Succeed())
+}
@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/dns/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+// defaultControlPlaneDNSTTL is the time to live of the control plane record, in seconds, unless set in the spec.
+const defaultControlPlaneDNSTTL = 300
+
+// ReconcileControlPlaneDNS publishes the address of the API server load balancer in the record of the control plane
+// endpoint, creating the managed zone of the record unless the spec names an existing one. The record is restored
+// if it was modified out-of-band, e.g. after the address of a re-created load balancer changed.
+func (s *Service) ReconcileControlPlaneDNS() error {
+	spec := s.scope.GCPCluster.Spec.ControlPlaneDNS
+	if spec == nil || s.scope.Network().APIServerAddress == nil {
+		return nil
+	}
+
+	zone, err := s.reconcileControlPlaneDNSZone(spec)
+	if err != nil {
+		return err
+	}
+
+	rrsetSpec := s.getControlPlaneRecordSpec(spec)
+	rrset, err := s.getControlPlaneRecord(zone, rrsetSpec)
+	if err != nil {
+		return err
+	}
+	if rrset == nil {
+		change := &dns.Change{Additions: []*dns.ResourceRecordSet{rrsetSpec}}
+		if _, err := s.scope.DNS.Changes.Create(s.scope.Project(), zone, change).Do(); err != nil {
+			return errors.Wrapf(err, "failed to create dns record %s", rrsetSpec.Name)
+		}
+		return nil
+	}
+
+	if drift := resourceRecordSetDrift(rrset, rrsetSpec); drift != "" {
+		// The record sets are replaced at once, by a change deleting the current one and adding the desired one.
+		change := &dns.Change{Deletions: []*dns.ResourceRecordSet{rrset}, Additions: []*dns.ResourceRecordSet{rrsetSpec}}
+		if _, err := s.scope.DNS.Changes.Create(s.scope.Project(), zone, change).Do(); err != nil {
+			return errors.Wrapf(err, "failed to update dns record %s", rrsetSpec.Name)
+		}
+		s.recordDriftCorrected("dns record", rrsetSpec.Name, drift, nil)
+	}
+
+	return nil
+}
+
+// reconcileControlPlaneDNSZone returns the managed zone of the control plane record, it gets or creates the zone of
+// the cluster unless the spec names an existing one.
+func (s *Service) reconcileControlPlaneDNSZone(spec *infrav1.ControlPlaneDNSSpec) (string, error) {
+	if spec.ManagedZone != nil {
+		return *spec.ManagedZone, nil
+	}
+
+	zoneSpec := &dns.ManagedZone{
+		Name:        s.controlPlaneDNSZoneName(),
+		Description: s.ownershipMarker(),
+		DnsName:     controlPlaneDNSName(spec),
+		Visibility:  "public",
+	}
+	// The address of an internal load balancer is only reachable from the network of the cluster.
+	if s.scope.LoadBalancerScheme() == infrav1.LoadBalancerSchemeInternal {
+		network := s.scope.GCPCluster.Status.Network.SelfLink
+		if network == nil {
+			return "", errors.New("failed to create dns zone: the network of the cluster isn't ready")
+		}
+		zoneSpec.Visibility = "private"
+		zoneSpec.PrivateVisibilityConfig = &dns.ManagedZonePrivateVisibilityConfig{
+			Networks: []*dns.ManagedZonePrivateVisibilityConfigNetwork{{NetworkUrl: *network}},
+		}
+	}
+
+	if _, err := s.scope.DNS.ManagedZones.Get(s.scope.Project(), zoneSpec.Name).Do(); gcperrors.IsNotFound(err) {
+		if _, err := s.scope.DNS.ManagedZones.Create(s.scope.Project(), zoneSpec).Do(); err != nil {
+			return "", errors.Wrapf(err, "failed to create dns zone")
+		}
+	} else if err != nil {
+		return "", errors.Wrapf(err, "failed to describe dns zone")
+	}
+	s.scope.GCPCluster.Status.Network.ControlPlaneDNSZone = pointer.StringPtr(zoneSpec.Name)
+
+	return zoneSpec.Name, nil
+}
+
+// getControlPlaneRecord returns the record set of the control plane endpoint in the zone, nil if it doesn't exist.
+func (s *Service) getControlPlaneRecord(zone string, spec *dns.ResourceRecordSet) (*dns.ResourceRecordSet, error) {
+	list, err := s.scope.DNS.ResourceRecordSets.List(s.scope.Project(), zone).Name(spec.Name).Type(spec.Type).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe dns record %s", spec.Name)
+	}
+	for _, rrset := range list.Rrsets {
+		if rrset.Name == spec.Name && rrset.Type == spec.Type {
+			return rrset, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// DeleteControlPlaneDNS deletes the record of the control plane endpoint, and the managed zone created for it.
+func (s *Service) DeleteControlPlaneDNS() error {
+	spec := s.scope.GCPCluster.Spec.ControlPlaneDNS
+	if spec == nil {
+		return nil
+	}
+
+	zone := s.scope.GCPCluster.Status.Network.ControlPlaneDNSZone
+	if spec.ManagedZone != nil {
+		zone = spec.ManagedZone
+	}
+	if zone == nil {
+		return nil
+	}
+
+	// The record is an A or an AAAA record, depending on the address it was created for.
+	for _, typ := range []string{"A", "AAAA"} {
+		rrset, err := s.getControlPlaneRecord(*zone, &dns.ResourceRecordSet{Name: controlPlaneDNSName(spec), Type: typ})
+		if gcperrors.IsNotFound(errors.Cause(err)) {
+			// The zone was already deleted.
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if rrset == nil {
+			continue
+		}
+		change := &dns.Change{Deletions: []*dns.ResourceRecordSet{rrset}}
+		if _, err := s.scope.DNS.Changes.Create(s.scope.Project(), *zone, change).Do(); err != nil && !gcperrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete dns record %s", rrset.Name)
+		}
+	}
+
+	if spec.ManagedZone == nil {
+		if err := s.scope.DNS.ManagedZones.Delete(s.scope.Project(), *zone).Do(); err != nil && !gcperrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete dns zone")
+		}
+	}
+	s.scope.GCPCluster.Status.Network.ControlPlaneDNSZone = nil
+
+	return nil
+}
+
+// getControlPlaneRecordSpec returns the desired record of the control plane endpoint, an A or an AAAA record
+// depending on the address of the load balancer.
+func (s *Service) getControlPlaneRecordSpec(spec *infrav1.ControlPlaneDNSSpec) *dns.ResourceRecordSet {
+	address := *s.scope.Network().APIServerAddress
+	rrset := &dns.ResourceRecordSet{
+		Name:    controlPlaneDNSName(spec),
+		Type:    "A",
+		Ttl:     defaultControlPlaneDNSTTL,
+		Rrdatas: []string{address},
+	}
+	if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+		rrset.Type = "AAAA"
+	}
+	if spec.TTL != nil {
+		rrset.Ttl = *spec.TTL
+	}
+
+	return rrset
+}
+
+// controlPlaneDNSZoneName returns the name of the managed zone created for the control plane record.
+func (s *Service) controlPlaneDNSZoneName() string {
+	return names.Truncate(fmt.Sprintf("%s-control-plane", s.scope.ResourceNamePrefix()))
+}
+
+// controlPlaneDNSName returns the fully qualified name of the control plane record.
+func controlPlaneDNSName(spec *infrav1.ControlPlaneDNSSpec) string {
+	return strings.TrimSuffix(spec.Name, ".") + "."
+}
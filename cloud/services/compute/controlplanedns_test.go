@@ -0,0 +1,99 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/dns/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileControlPlaneDNS(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.ControlPlaneDNS = &infrav1.ControlPlaneDNSSpec{Name: "api.my-cluster.example.com"}
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+	g.Expect(s.ReconcileControlPlaneDNS()).To(Succeed())
+
+	zone := &dns.ManagedZone{}
+	g.Expect(c.Get("projects/my-project/managedZones/my-cluster-control-plane", zone)).To(BeTrue())
+	g.Expect(zone.DnsName).To(Equal("api.my-cluster.example.com."))
+	g.Expect(zone.Visibility).To(Equal("public"))
+	g.Expect(s.scope.GCPCluster.Status.Network.ControlPlaneDNSZone).To(Equal(pointer.StringPtr("my-cluster-control-plane")))
+	rrsets := func() []*dns.ResourceRecordSet {
+		list, err := c.DNS().ResourceRecordSets.List("my-project", "my-cluster-control-plane").Do()
+		g.Expect(err).NotTo(HaveOccurred())
+		return list.Rrsets
+	}
+	g.Expect(rrsets()).To(HaveLen(1))
+	g.Expect(rrsets()[0].Name).To(Equal("api.my-cluster.example.com."))
+	g.Expect(rrsets()[0].Type).To(Equal("A"))
+	g.Expect(rrsets()[0].Ttl).To(Equal(int64(300)))
+	g.Expect(rrsets()[0].Rrdatas).To(ConsistOf(*s.scope.Network().APIServerAddress))
+
+	// The record follows the address of a re-created load balancer.
+	s.scope.Network().APIServerAddress = pointer.StringPtr("203.0.113.10")
+	g.Expect(s.ReconcileControlPlaneDNS()).To(Succeed())
+	g.Expect(rrsets()).To(HaveLen(1))
+	g.Expect(rrsets()[0].Rrdatas).To(ConsistOf("203.0.113.10"))
+
+	g.Expect(s.DeleteControlPlaneDNS()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/managedZones/my-cluster-control-plane", nil)).To(BeFalse())
+	g.Expect(s.scope.GCPCluster.Status.Network.ControlPlaneDNSZone).To(BeNil())
+	g.Expect(s.DeleteControlPlaneDNS()).To(Succeed())
+}
+
+func TestReconcileControlPlaneDNSExistingZone(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	c.Put("projects/my-project/managedZones/example", &dns.ManagedZone{Name: "example", DnsName: "example.com."})
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.ControlPlaneDNS = &infrav1.ControlPlaneDNSSpec{
+		Name:        "api.my-cluster.example.com.",
+		ManagedZone: pointer.StringPtr("example"),
+		TTL:         pointer.Int64Ptr(60),
+	}
+	s.scope.Network().APIServerAddress = pointer.StringPtr("2001:db8::1")
+	g.Expect(s.ReconcileControlPlaneDNS()).To(Succeed())
+
+	list, err := c.DNS().ResourceRecordSets.List("my-project", "example").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Rrsets).To(HaveLen(1))
+	g.Expect(list.Rrsets[0].Type).To(Equal("AAAA"))
+	g.Expect(list.Rrsets[0].Ttl).To(Equal(int64(60)))
+	g.Expect(s.scope.GCPCluster.Status.Network.ControlPlaneDNSZone).To(BeNil())
+
+	// The existing zone is kept, only the record is deleted.
+	g.Expect(s.DeleteControlPlaneDNS()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/managedZones/example", nil)).To(BeTrue())
+	list, err = c.DNS().ResourceRecordSets.List("my-project", "example").Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(list.Rrsets).To(BeEmpty())
+}
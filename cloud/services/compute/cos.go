@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+// cosUpdateStrategyKey is the metadata key of the update strategy of Container-Optimized OS,
+// see https://cloud.google.com/container-optimized-os/docs/concepts/auto-update.
+const cosUpdateStrategyKey = "cos-update-strategy"
+
+// isContainerOptimizedOS returns true if the instance of the GCPMachine runs Container-Optimized OS.
+func isContainerOptimizedOS(spec *infrav1.GCPMachineSpec, image string) bool {
+	if spec.ContainerOptimizedOS != nil {
+		return *spec.ContainerOptimizedOS
+	}
+
+	return isCOSImage(image)
+}
+
+// isCOSImage returns true if the image is a Container-Optimized OS image.
+func isCOSImage(image string) bool {
+	return strings.Contains(image, "projects/cos-cloud/") || strings.Contains(image, "/family/cos-")
+}
+
+// validateContainerOptimizedOS returns an error if the GCPMachine can't run on Container-Optimized OS.
+// The cloud-init of Container-Optimized OS only reads a cloud-config from the user-data metadata, it can't
+// run the shim fetching the bootstrap data from a bootstrap data bucket.
+func validateContainerOptimizedOS(spec *infrav1.GCPMachineSpec, bootstrapData string) error {
+	if spec.EnableOSConfig {
+		return errors.New("the OS Config agent isn't supported by Container-Optimized OS")
+	}
+	if spec.BootstrapDataBucket != nil {
+		return errors.New("the bootstrap data of Container-Optimized OS instances can't be fetched from a bootstrap data bucket")
+	}
+	if !strings.HasPrefix(strings.TrimSpace(bootstrapData), "#cloud-config") {
+		return errors.New("the bootstrap data of Container-Optimized OS instances must be a cloud-config")
+	}
+
+	return nil
+}
+
+// cosMetadata returns the metadata of Container-Optimized OS instances. The automatic updates, swapping
+// the read-only root partition at the next reboot, are disabled as the nodes are upgraded by replacing
+// their Machines.
+func cosMetadata() []*compute.MetadataItems {
+	return []*compute.MetadataItems{
+		{Key: cosUpdateStrategyKey, Value: pointer.StringPtr("update_disabled")},
+	}
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestCreateInstanceContainerOptimizedOS(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cos-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			Image:        pointer.StringPtr("projects/cos-cloud/global/images/family/cos-stable"),
+		},
+	})
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue("user-data", "#cloud-config"))
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(cosUpdateStrategyKey, "update_disabled"))
+
+	// A custom image is marked as Container-Optimized OS explicitly.
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-custom-cos-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:         "n1-standard-2",
+			Image:                pointer.StringPtr("projects/my-project/global/images/my-cos"),
+			ContainerOptimizedOS: pointer.BoolPtr(true),
+			InstallOpsAgent:      true,
+		},
+	})
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(cosUpdateStrategyKey, "update_disabled"))
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(cosLoggingEnabledKey, "true"))
+
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-ubuntu-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			Image:        pointer.StringPtr("projects/my-project/global/images/family/capi-ubuntu-1804-k8s-v1-21"),
+		},
+	})
+	g.Expect(instanceMetadata(instance)).NotTo(HaveKey(cosUpdateStrategyKey))
+
+	spec := &infrav1.GCPMachineSpec{}
+	g.Expect(validateContainerOptimizedOS(spec, "#cloud-config\nruncmd: []")).To(Succeed())
+	g.Expect(validateContainerOptimizedOS(spec, `{"ignition": {"version": "2.3.0"}}`)).NotTo(Succeed())
+	spec.EnableOSConfig = true
+	g.Expect(validateContainerOptimizedOS(spec, "#cloud-config")).NotTo(Succeed())
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/dns/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+// reconcileDNS configures the DNS policy and the forwarding zones of the network spec, and deletes those
+// removed from the spec. The Cloud DNS API is synchronous, there is no operation to wait for.
+func (s *Service) reconcileDNS(network *compute.Network) error {
+	spec := s.scope.GCPCluster.Spec.Network.DNS
+	if spec == nil {
+		spec = &infrav1.DNSSpec{}
+	}
+
+	if spec.Policy == nil {
+		if s.scope.GCPCluster.Status.Network.DNSPolicy != nil {
+			if err := s.deleteDNSPolicy(); err != nil {
+				return err
+			}
+		}
+	} else if err := s.reconcileDNSPolicy(network, spec.Policy); err != nil {
+		return err
+	}
+
+	status := make(map[string]string, len(spec.ForwardingZones))
+	for i := range spec.ForwardingZones {
+		zoneSpec := s.getDNSForwardingZoneSpec(network, &spec.ForwardingZones[i])
+		if err := s.reconcileDNSForwardingZone(zoneSpec); err != nil {
+			return err
+		}
+		status[spec.ForwardingZones[i].Name] = zoneSpec.Name
+	}
+	for name, zone := range s.scope.GCPCluster.Status.Network.DNSForwardingZones {
+		if _, ok := status[name]; ok {
+			continue
+		}
+		if err := s.deleteDNSForwardingZone(zone); err != nil {
+			return err
+		}
+	}
+
+	s.scope.GCPCluster.Status.Network.DNSForwardingZones = nil
+	if len(status) > 0 {
+		s.scope.GCPCluster.Status.Network.DNSForwardingZones = status
+	}
+
+	return nil
+}
+
+// reconcileDNSPolicy gets or creates the DNS policy of the network, restores it if it was modified out-of-band,
+// and records it in the cluster status.
+func (s *Service) reconcileDNSPolicy(network *compute.Network, spec *infrav1.DNSPolicySpec) error {
+	policySpec := &dns.Policy{
+		Name:                    s.dnsPolicyName(),
+		Description:             s.ownershipMarker(),
+		EnableInboundForwarding: spec.EnableInboundForwarding,
+		EnableLogging:           spec.EnableLogging,
+		Networks:                []*dns.PolicyNetwork{{NetworkUrl: network.SelfLink}},
+		ForceSendFields:         []string{"EnableInboundForwarding", "EnableLogging"},
+	}
+
+	policy, err := s.scope.DNS.Policies.Get(s.scope.Project(), policySpec.Name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		if _, err := s.scope.DNS.Policies.Create(s.scope.Project(), policySpec).Do(); err != nil {
+			return errors.Wrapf(err, "failed to create dns policy")
+		}
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe dns policy")
+	default:
+		if drift := dnsPolicyDrift(policy, policySpec); drift != "" {
+			if _, err := s.scope.DNS.Policies.Patch(s.scope.Project(), policy.Name, policySpec).Do(); err != nil {
+				return errors.Wrapf(err, "failed to update dns policy")
+			}
+			s.recordDriftCorrected("dns policy", policy.Name, drift, nil)
+		}
+	}
+	s.scope.GCPCluster.Status.Network.DNSPolicy = pointer.StringPtr(policySpec.Name)
+
+	return nil
+}
+
+// reconcileDNSForwardingZone gets or creates the forwarding zone, and restores it if it was modified out-of-band.
+// The zones whose domain changed are recreated, the domain of a zone being immutable.
+func (s *Service) reconcileDNSForwardingZone(zoneSpec *dns.ManagedZone) error {
+	zone, err := s.scope.DNS.ManagedZones.Get(s.scope.Project(), zoneSpec.Name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe dns forwarding zone")
+	case zone.DnsName != zoneSpec.DnsName:
+		if err := s.deleteDNSForwardingZone(zone.Name); err != nil {
+			return err
+		}
+		s.recordDriftCorrected("dns forwarding zone", zone.Name, fmt.Sprintf("domain is %s instead of %s", zone.DnsName, zoneSpec.DnsName), nil)
+	default:
+		if drift := dnsForwardingZoneDrift(zone, zoneSpec); drift != "" {
+			if _, err := s.scope.DNS.ManagedZones.Patch(s.scope.Project(), zone.Name, zoneSpec).Do(); err != nil {
+				return errors.Wrapf(err, "failed to update dns forwarding zone")
+			}
+			s.recordDriftCorrected("dns forwarding zone", zone.Name, drift, nil)
+		}
+		return nil
+	}
+
+	if _, err := s.scope.DNS.ManagedZones.Create(s.scope.Project(), zoneSpec).Do(); err != nil {
+		return errors.Wrapf(err, "failed to create dns forwarding zone")
+	}
+
+	return nil
+}
+
+// deleteDNS deletes the forwarding zones and the DNS policy of the network, which would otherwise prevent
+// the deletion of the network.
+func (s *Service) deleteDNS() error {
+	zones := sets.NewString()
+	if spec := s.scope.GCPCluster.Spec.Network.DNS; spec != nil {
+		for _, zone := range spec.ForwardingZones {
+			zones.Insert(s.dnsForwardingZoneName(zone.Name))
+		}
+	}
+	for _, zone := range s.scope.GCPCluster.Status.Network.DNSForwardingZones {
+		zones.Insert(zone)
+	}
+	for _, zone := range zones.List() {
+		if err := s.deleteDNSForwardingZone(zone); err != nil {
+			return err
+		}
+	}
+	s.scope.GCPCluster.Status.Network.DNSForwardingZones = nil
+
+	return s.deleteDNSPolicy()
+}
+
+// deleteDNSPolicy detaches the DNS policy from the network and deletes it, a policy which doesn't exist is ignored.
+func (s *Service) deleteDNSPolicy() error {
+	name := s.dnsPolicyName()
+	if _, err := s.scope.DNS.Policies.Get(s.scope.Project(), name).Do(); gcperrors.IsNotFound(err) {
+		s.scope.GCPCluster.Status.Network.DNSPolicy = nil
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe dns policy")
+	}
+
+	// A policy can't be deleted while it's attached to networks.
+	detach := &dns.Policy{Networks: []*dns.PolicyNetwork{}, ForceSendFields: []string{"Networks"}}
+	if _, err := s.scope.DNS.Policies.Patch(s.scope.Project(), name, detach).Do(); err != nil && !gcperrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to detach dns policy")
+	}
+	if err := s.scope.DNS.Policies.Delete(s.scope.Project(), name).Do(); err != nil && !gcperrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete dns policy")
+	}
+	s.scope.GCPCluster.Status.Network.DNSPolicy = nil
+
+	return nil
+}
+
+// deleteDNSForwardingZone deletes the forwarding zone, a zone which doesn't exist is ignored.
+func (s *Service) deleteDNSForwardingZone(name string) error {
+	if err := s.scope.DNS.ManagedZones.Delete(s.scope.Project(), name).Do(); err != nil && !gcperrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete dns forwarding zone")
+	}
+
+	return nil
+}
+
+func (s *Service) getDNSForwardingZoneSpec(network *compute.Network, spec *infrav1.DNSForwardingZoneSpec) *dns.ManagedZone {
+	targets := make([]*dns.ManagedZoneForwardingConfigNameServerTarget, 0, len(spec.TargetNameServers))
+	for _, ip := range spec.TargetNameServers {
+		targets = append(targets, &dns.ManagedZoneForwardingConfigNameServerTarget{Ipv4Address: ip})
+	}
+
+	return &dns.ManagedZone{
+		Name:        s.dnsForwardingZoneName(spec.Name),
+		Description: s.ownershipMarker(),
+		// The domains of the zones are fully qualified.
+		DnsName:    strings.TrimSuffix(spec.DNSName, ".") + ".",
+		Visibility: "private",
+		PrivateVisibilityConfig: &dns.ManagedZonePrivateVisibilityConfig{
+			Networks: []*dns.ManagedZonePrivateVisibilityConfigNetwork{{NetworkUrl: network.SelfLink}},
+		},
+		ForwardingConfig: &dns.ManagedZoneForwardingConfig{TargetNameServers: targets},
+	}
+}
+
+// dnsPolicyName returns the name of the DNS policy of the network.
+func (s *Service) dnsPolicyName() string {
+	return names.Truncate(fmt.Sprintf("%s-dns", s.scope.ResourceNamePrefix()))
+}
+
+// dnsForwardingZoneName returns the name of the managed zone of the forwarding zone of the network spec.
+func (s *Service) dnsForwardingZoneName(name string) string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), name))
+}
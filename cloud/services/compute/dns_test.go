@@ -0,0 +1,71 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/dns/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileDNS(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.Network.DNS = &infrav1.DNSSpec{
+		Policy: &infrav1.DNSPolicySpec{EnableInboundForwarding: true},
+		ForwardingZones: []infrav1.DNSForwardingZoneSpec{
+			{Name: "corp", DNSName: "corp.example.com", TargetNameServers: []string{"10.10.0.2", "10.10.0.3"}},
+		},
+	}
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+
+	policy := &dns.Policy{}
+	g.Expect(c.Get("projects/my-project/policies/my-cluster-dns", policy)).To(BeTrue())
+	g.Expect(policy.EnableInboundForwarding).To(BeTrue())
+	g.Expect(policy.EnableLogging).To(BeFalse())
+	g.Expect(policy.Networks).To(HaveLen(1))
+	g.Expect(policy.Networks[0].NetworkUrl).To(Equal(*s.scope.GCPCluster.Status.Network.SelfLink))
+	zone := &dns.ManagedZone{}
+	g.Expect(c.Get("projects/my-project/managedZones/my-cluster-corp", zone)).To(BeTrue())
+	g.Expect(zone.DnsName).To(Equal("corp.example.com."))
+	g.Expect(zone.Visibility).To(Equal("private"))
+	g.Expect(zone.ForwardingConfig.TargetNameServers).To(HaveLen(2))
+	g.Expect(s.scope.GCPCluster.Status.Network.DNSPolicy).To(Equal(pointer.StringPtr("my-cluster-dns")))
+	g.Expect(s.scope.GCPCluster.Status.Network.DNSForwardingZones).To(Equal(map[string]string{"corp": "my-cluster-corp"}))
+
+	// The policy modified out-of-band is restored, the forwarding zones removed from the spec are deleted.
+	policy.EnableLogging = true
+	c.Put("projects/my-project/policies/my-cluster-dns", policy)
+	s.scope.GCPCluster.Spec.Network.DNS.ForwardingZones = nil
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/policies/my-cluster-dns", policy)).To(BeTrue())
+	g.Expect(policy.EnableLogging).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/managedZones/my-cluster-corp", nil)).To(BeFalse())
+	g.Expect(s.scope.GCPCluster.Status.Network.DNSForwardingZones).To(BeNil())
+
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/policies/my-cluster-dns", nil)).To(BeFalse())
+	g.Expect(s.scope.GCPCluster.Status.Network.DNSPolicy).To(BeNil())
+}
@@ -0,0 +1,333 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/dns/v1"
+	"sigs.k8s.io/cluster-api/util/record"
+)
+
+// recordDriftCorrected emits an event on the GCPCluster when a resource modified out-of-band has been repaired
+// by the operation, if any.
+func (s *Service) recordDriftCorrected(kind, name, reason string, op *compute.Operation) {
+	s.scope.Info("Corrected drift of GCP resource", "kind", kind, "name", name, "reason", reason)
+	record.Eventf(s.scope.GCPCluster, "DriftCorrected", "Restored %s %q: %s%s", kind, name, reason, operationDetails(op))
+}
+
+// deferDisruptiveChange returns true if the disruptive change of the resource is deferred to the maintenance
+// window of the cluster, which isn't open. The change is then recorded as pending.
+func (s *Service) deferDisruptiveChange(kind, name, change string) bool {
+	if s.scope.InMaintenanceWindow() {
+		return false
+	}
+	s.scope.Info("Deferred disruptive change of GCP resource to the maintenance window", "kind", kind, "name", name, "change", change)
+	s.scope.DeferChange(fmt.Sprintf("%s %q: %s", kind, name, change))
+
+	return true
+}
+
+// firewallDrift returns why the firewall rule differs from the spec, empty if it does not.
+func firewallDrift(firewall, spec *compute.Firewall) string {
+	switch {
+	case firewall.Disabled:
+		return "rule is disabled"
+	case !strings.EqualFold(firewall.Direction, spec.Direction):
+		return fmt.Sprintf("direction is %s instead of %s", firewall.Direction, spec.Direction)
+	case !equalStringSets(firewallAllowed(firewall.Allowed), firewallAllowed(spec.Allowed)):
+		return "allowed protocols and ports changed"
+	case len(firewall.Denied) > 0:
+		return "denied protocols and ports added"
+	case !equalStringSets(firewall.SourceRanges, spec.SourceRanges):
+		return "source ranges changed"
+	case !equalStringSets(firewall.DestinationRanges, spec.DestinationRanges):
+		return "destination ranges changed"
+	case !equalStringSets(firewall.SourceTags, spec.SourceTags):
+		return "source tags changed"
+	case !equalStringSets(firewall.TargetTags, spec.TargetTags):
+		return "target tags changed"
+	}
+
+	return ""
+}
+
+// firewallAllowed returns the normalized representation of the allowed protocols and ports,
+// GCP stores the protocols lowercased.
+func firewallAllowed(allowed []*compute.FirewallAllowed) []string {
+	res := make([]string, 0, len(allowed))
+	for _, a := range allowed {
+		ports := append([]string{}, a.Ports...)
+		sort.Strings(ports)
+		res = append(res, fmt.Sprintf("%s:%s", strings.ToLower(a.IPProtocol), strings.Join(ports, ",")))
+	}
+
+	return res
+}
+
+// firewallPolicyRuleDrift returns why the rule of the network firewall policy differs from the spec, empty if
+// it does not.
+func firewallPolicyRuleDrift(rule, spec *computealpha.FirewallPolicyRule) string {
+	match := rule.Match
+	if match == nil {
+		match = &computealpha.FirewallPolicyRuleMatcher{}
+	}
+	switch {
+	case rule.Disabled:
+		return "rule is disabled"
+	case rule.Action != spec.Action:
+		return fmt.Sprintf("action is %s instead of %s", rule.Action, spec.Action)
+	case !strings.EqualFold(rule.Direction, spec.Direction):
+		return fmt.Sprintf("direction is %s instead of %s", rule.Direction, spec.Direction)
+	case !equalStringSets(firewallPolicyLayer4Configs(match.Layer4Configs), firewallPolicyLayer4Configs(spec.Match.Layer4Configs)):
+		return "allowed protocols and ports changed"
+	case !equalStringSets(match.SrcIpRanges, spec.Match.SrcIpRanges):
+		return "source ranges changed"
+	case !equalStringSets(match.DestIpRanges, spec.Match.DestIpRanges):
+		return "destination ranges changed"
+	case len(rule.TargetSecureTags) > 0:
+		return "target secure tags added"
+	case len(rule.TargetServiceAccounts) > 0:
+		return "target service accounts added"
+	}
+
+	return ""
+}
+
+// firewallPolicyLayer4Configs returns the normalized representation of the protocols and ports of a rule of a
+// network firewall policy.
+func firewallPolicyLayer4Configs(configs []*computealpha.FirewallPolicyRuleMatcherLayer4Config) []string {
+	allowed := make([]*compute.FirewallAllowed, 0, len(configs))
+	for _, c := range configs {
+		allowed = append(allowed, &compute.FirewallAllowed{IPProtocol: c.IpProtocol, Ports: c.Ports})
+	}
+
+	return firewallAllowed(allowed)
+}
+
+// routerNatDrift returns why the NAT gateway differs from the spec, empty if it does not.
+func routerNatDrift(nat, spec *compute.RouterNat) string {
+	switch {
+	case nat.NatIpAllocateOption != spec.NatIpAllocateOption:
+		return fmt.Sprintf("NAT IP allocation is %s instead of %s", nat.NatIpAllocateOption, spec.NatIpAllocateOption)
+	case nat.SourceSubnetworkIpRangesToNat != spec.SourceSubnetworkIpRangesToNat:
+		return fmt.Sprintf("NAT source ranges are %s instead of %s", nat.SourceSubnetworkIpRangesToNat, spec.SourceSubnetworkIpRangesToNat)
+	case !equalStringSets(natSubnetworks(nat.Subnetworks), natSubnetworks(spec.Subnetworks)):
+		return "NAT subnetworks changed"
+	}
+
+	return ""
+}
+
+// natSubnetworks returns the normalized representation of the subnetworks translated by the NAT gateway,
+// GCP stores the full URL of the subnetworks.
+func natSubnetworks(subnetworks []*compute.RouterNatSubnetworkToNat) []string {
+	res := make([]string, 0, len(subnetworks))
+	for _, s := range subnetworks {
+		ranges := append([]string{}, s.SourceIpRangesToNat...)
+		sort.Strings(ranges)
+		secondary := append([]string{}, s.SecondaryIpRangeNames...)
+		sort.Strings(secondary)
+		res = append(res, fmt.Sprintf("%s:%s:%s", path.Base(s.Name), strings.Join(ranges, ","), strings.Join(secondary, ",")))
+	}
+
+	return res
+}
+
+// healthCheckDrift returns why the health check differs from the spec, empty if it does not.
+func healthCheckDrift(healthCheck, spec *compute.HealthCheck) string {
+	switch {
+	case healthCheck.Type != spec.Type:
+		return fmt.Sprintf("type is %s instead of %s", healthCheck.Type, spec.Type)
+	case healthCheck.SslHealthCheck == nil ||
+		healthCheck.SslHealthCheck.Port != spec.SslHealthCheck.Port ||
+		healthCheck.SslHealthCheck.PortSpecification != spec.SslHealthCheck.PortSpecification:
+		return "port changed"
+	case healthCheck.CheckIntervalSec != spec.CheckIntervalSec ||
+		healthCheck.TimeoutSec != spec.TimeoutSec ||
+		healthCheck.HealthyThreshold != spec.HealthyThreshold ||
+		healthCheck.UnhealthyThreshold != spec.UnhealthyThreshold:
+		return "timings changed"
+	}
+
+	return ""
+}
+
+// backendServiceDrift returns why the backend service differs from the spec, empty if it does not.
+func backendServiceDrift(backendService, spec *compute.BackendService) string {
+	switch {
+	case backendService.Protocol != spec.Protocol:
+		return fmt.Sprintf("protocol is %s instead of %s", backendService.Protocol, spec.Protocol)
+	case backendService.PortName != spec.PortName:
+		return fmt.Sprintf("port name is %s instead of %s", backendService.PortName, spec.PortName)
+	case backendService.TimeoutSec != spec.TimeoutSec:
+		return "timeout changed"
+	case sessionAffinity(backendService) != sessionAffinity(spec):
+		return fmt.Sprintf("session affinity is %s instead of %s", sessionAffinity(backendService), sessionAffinity(spec))
+	case drainingTimeout(backendService) != drainingTimeout(spec):
+		return "connection draining timeout changed"
+	case !equalStringSets(backendService.HealthChecks, spec.HealthChecks):
+		return "health checks changed"
+	case !equalStringSets(backendGroups(backendService.Backends), backendGroups(spec.Backends)):
+		return "backends changed"
+	}
+
+	return ""
+}
+
+// sessionAffinity returns the session affinity of the backend service, GCP omitting the default NONE.
+func sessionAffinity(backendService *compute.BackendService) string {
+	if backendService.SessionAffinity == "" {
+		return "NONE"
+	}
+
+	return backendService.SessionAffinity
+}
+
+// drainingTimeout returns the connection draining timeout of the backend service, 0 if it's omitted.
+func drainingTimeout(backendService *compute.BackendService) int64 {
+	if backendService.ConnectionDraining == nil {
+		return 0
+	}
+
+	return backendService.ConnectionDraining.DrainingTimeoutSec
+}
+
+func backendGroups(backends []*compute.Backend) []string {
+	res := make([]string, 0, len(backends))
+	for _, b := range backends {
+		res = append(res, b.Group)
+	}
+
+	return res
+}
+
+// equalStringSets returns true if both slices hold the same strings, regardless of their order.
+func equalStringSets(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a, b = append([]string{}, a...), append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mergeLabels returns the labels with the spec labels added or restored, and whether any was.
+// The labels missing from the spec, e.g. added by other tools, are kept.
+func mergeLabels(labels, spec map[string]string) (map[string]string, bool) {
+	res := make(map[string]string, len(labels)+len(spec))
+	for k, v := range labels {
+		res[k] = v
+	}
+	drifted := false
+	for k, v := range spec {
+		if current, ok := labels[k]; !ok || current != v {
+			res[k] = v
+			drifted = true
+		}
+	}
+
+	return res, drifted
+}
+
+// routeDrift returns why the route differs from the spec, empty if it does not.
+func routeDrift(route, spec *compute.Route) string {
+	switch {
+	case route.DestRange != spec.DestRange:
+		return fmt.Sprintf("destination range is %s instead of %s", route.DestRange, spec.DestRange)
+	case route.Priority != spec.Priority:
+		return fmt.Sprintf("priority is %d instead of %d", route.Priority, spec.Priority)
+	case route.NextHopIp != spec.NextHopIp,
+		!sameResource(route.NextHopInstance, spec.NextHopInstance),
+		!sameResource(route.NextHopIlb, spec.NextHopIlb),
+		!sameResource(route.NextHopGateway, spec.NextHopGateway):
+		return "next hop changed"
+	case !equalStringSets(route.Tags, spec.Tags):
+		return "tags changed"
+	}
+
+	return ""
+}
+
+// sameResource returns true if the full URL of a resource references the partial URL, e.g. projects/my-project/global/gateways/default-internet-gateway.
+func sameResource(link, ref string) bool {
+	return link == ref || strings.HasSuffix(link, "/"+ref)
+}
+
+// dnsPolicyDrift returns why the DNS policy differs from the spec, empty if it does not.
+func dnsPolicyDrift(policy, spec *dns.Policy) string {
+	networks := make([]string, 0, len(policy.Networks))
+	for _, n := range policy.Networks {
+		networks = append(networks, n.NetworkUrl)
+	}
+
+	switch {
+	case policy.EnableInboundForwarding != spec.EnableInboundForwarding:
+		return fmt.Sprintf("inbound forwarding is %t instead of %t", policy.EnableInboundForwarding, spec.EnableInboundForwarding)
+	case policy.EnableLogging != spec.EnableLogging:
+		return fmt.Sprintf("logging is %t instead of %t", policy.EnableLogging, spec.EnableLogging)
+	case !equalStringSets(networks, []string{spec.Networks[0].NetworkUrl}):
+		return "networks changed"
+	}
+
+	return ""
+}
+
+// dnsForwardingZoneDrift returns why the forwarding zone differs from the spec, empty if it does not.
+func dnsForwardingZoneDrift(zone, spec *dns.ManagedZone) string {
+	switch {
+	case zone.ForwardingConfig == nil || !equalStringSets(nameServers(zone.ForwardingConfig), nameServers(spec.ForwardingConfig)):
+		return "target name servers changed"
+	case zone.PrivateVisibilityConfig == nil || len(zone.PrivateVisibilityConfig.Networks) != 1 ||
+		zone.PrivateVisibilityConfig.Networks[0].NetworkUrl != spec.PrivateVisibilityConfig.Networks[0].NetworkUrl:
+		return "networks changed"
+	}
+
+	return ""
+}
+
+func nameServers(config *dns.ManagedZoneForwardingConfig) []string {
+	res := make([]string, 0, len(config.TargetNameServers))
+	for _, t := range config.TargetNameServers {
+		res = append(res, t.Ipv4Address)
+	}
+
+	return res
+}
+
+func resourceRecordSetDrift(rrset, spec *dns.ResourceRecordSet) string {
+	switch {
+	case !equalStringSets(rrset.Rrdatas, spec.Rrdatas):
+		return fmt.Sprintf("data is %s instead of %s", strings.Join(rrset.Rrdatas, ","), strings.Join(spec.Rrdatas, ","))
+	case rrset.Ttl != spec.Ttl:
+		return fmt.Sprintf("ttl is %d instead of %d", rrset.Ttl, spec.Ttl)
+	}
+
+	return ""
+}
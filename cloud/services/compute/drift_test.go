@@ -0,0 +1,111 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileDrift(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	// Modify the resources out-of-band.
+	firewallPath := "projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster"
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get(firewallPath, firewall)).To(BeTrue())
+	firewall.SourceTags = nil
+	firewall.SourceRanges = []string{"0.0.0.0/0"}
+	c.Put(firewallPath, firewall)
+
+	routerPath := "projects/my-project/regions/us-central1/routers/default-router"
+	router := &compute.Router{}
+	g.Expect(c.Get(routerPath, router)).To(BeTrue())
+	router.Nats = nil
+	c.Put(routerPath, router)
+
+	forwardingRulePath := "projects/my-project/global/forwardingRules/my-cluster-apiserver"
+	forwardingRule := &compute.ForwardingRule{}
+	g.Expect(c.Get(forwardingRulePath, forwardingRule)).To(BeTrue())
+	forwardingRule.Target = "somewhere-else"
+	forwardingRule.Labels = map[string]string{"team": "networking"}
+	c.Put(forwardingRulePath, forwardingRule)
+
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	firewall = &compute.Firewall{}
+	g.Expect(c.Get(firewallPath, firewall)).To(BeTrue())
+	g.Expect(firewall.SourceTags).To(ConsistOf("my-cluster-control-plane", "my-cluster-node"))
+	g.Expect(firewall.SourceRanges).To(BeEmpty())
+	g.Expect(c.Get(routerPath, router)).To(BeTrue())
+	g.Expect(router.Nats).To(HaveLen(1))
+	g.Expect(c.Get(forwardingRulePath, forwardingRule)).To(BeTrue())
+	g.Expect(forwardingRule.Target).To(Equal(*s.scope.Network().APIServerTargetProxy))
+	g.Expect(forwardingRule.Labels).To(HaveKeyWithValue("team", "networking"))
+	g.Expect(forwardingRule.Labels).To(HaveKeyWithValue(infrav1.ClusterTagKey("my-cluster"), string(infrav1.ResourceLifecycleOwned)))
+}
+
+func TestInstanceImmutableDrift(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	instance := &compute.Instance{
+		Name:       "my-machine",
+		Scheduling: &compute.Scheduling{},
+		GuestAccelerators: []*compute.AcceleratorConfig{
+			{AcceleratorType: "nvidia-tesla-t4", AcceleratorCount: 1},
+		},
+	}
+
+	machineScope.GCPMachine.Spec.GuestAccelerators = []infrav1.Accelerator{{Type: "nvidia-tesla-t4", Count: 1}}
+	g.Expect(s.InstanceImmutableDrift(machineScope, instance)).To(BeEmpty())
+
+	machineScope.GCPMachine.Spec.Preemptible = true
+	g.Expect(s.InstanceImmutableDrift(machineScope, instance)).To(Equal("preemptible is false instead of true"))
+
+	machineScope.GCPMachine.Spec.Preemptible = false
+	machineScope.GCPMachine.Spec.ConfidentialCompute = true
+	g.Expect(s.InstanceImmutableDrift(machineScope, instance)).To(Equal("confidential compute is false instead of true"))
+
+	machineScope.GCPMachine.Spec.ConfidentialCompute = false
+	machineScope.GCPMachine.Spec.GuestAccelerators = nil
+	g.Expect(s.InstanceImmutableDrift(machineScope, instance)).To(Equal("1 guest accelerator types instead of 0"))
+
+	// The existing instances adopted by their GCPMachine aren't compared.
+	machineScope.GCPMachine.Spec.ExistingInstance = pointer.StringPtr("my-machine")
+	g.Expect(s.InstanceImmutableDrift(machineScope, instance)).To(BeEmpty())
+}
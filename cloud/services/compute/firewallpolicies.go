@@ -0,0 +1,317 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+// defaultFirewallPolicyPriority is the priority of the first rule of the cluster in the network firewall policy.
+const defaultFirewallPolicyPriority = 1000
+
+// reconcileFirewallPolicyRules creates the network firewall policy of the cluster and associates it with the
+// network, adds the firewall rules of the cluster to it, restores the ones modified out-of-band, and removes the
+// ones no longer needed. The rules are identified by their description, the name of the firewall rule they replace.
+// The policy is only modified if it is owned by the cluster.
+func (s *Service) reconcileFirewallPolicyRules(specs []*compute.Firewall) error {
+	client, err := s.scope.AlphaCompute()
+	if err != nil {
+		return errors.Wrapf(err, "failed to reconcile network firewall policy")
+	}
+
+	policy, err := s.reconcileFirewallPolicy(client)
+	if err != nil {
+		return err
+	}
+
+	rules := map[string]*computealpha.FirewallPolicyRule{}
+	used := sets.NewInt64()
+	for _, rule := range policy.Rules {
+		rules[rule.Description] = rule
+		used.Insert(rule.Priority)
+	}
+
+	priority := s.firewallPolicyPriority()
+	for _, firewallSpec := range specs {
+		spec, err := s.firewallPolicyRule(firewallSpec)
+		if err != nil {
+			return err
+		}
+
+		rule, ok := rules[firewallSpec.Name]
+		if !ok {
+			for used.Has(priority) {
+				priority++
+			}
+			spec.Priority = priority
+			used.Insert(priority)
+			resource := s.firewallPolicyRuleResource(firewallSpec.Name)
+			op, err := s.runOperation(resource, "addRule", func() (*compute.Operation, error) {
+				return computeOperation(client.NetworkFirewallPolicies.AddRule(s.scope.Project(), policy.Name, spec).Do())
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to add rule to network firewall policy")
+			}
+			_ = s.operationCompleted(resource, "insert", op, nil)
+			rule = spec
+		} else if drift := firewallPolicyRuleDrift(rule, spec); drift != "" && !s.deferDisruptiveChange("firewall policy rule", firewallSpec.Name, drift) {
+			spec.Priority = rule.Priority
+			op, err := s.runOperation(s.firewallPolicyRuleResource(firewallSpec.Name), "patchRule", func() (*compute.Operation, error) {
+				return computeOperation(client.NetworkFirewallPolicies.PatchRule(s.scope.Project(), policy.Name, spec).Priority(rule.Priority).Do())
+			})
+			if err != nil {
+				return errors.Wrapf(err, "failed to update rule of network firewall policy")
+			}
+			s.recordDriftCorrected("firewall policy rule", firewallSpec.Name, drift, op)
+		}
+
+		// Store in the Cluster Status.
+		s.scope.SetFirewallRule(firewallSpec.Name, firewallPolicyRuleLink(policy, rule.Priority))
+	}
+
+	for _, name := range s.scope.FirewallRuleNames() {
+		if containsFirewall(specs, name) {
+			continue
+		}
+		if rule, ok := rules[name]; ok {
+			resource := s.firewallPolicyRuleResource(name)
+			op, err := s.runOperation(resource, "removeRule", func() (*compute.Operation, error) {
+				return computeOperation(client.NetworkFirewallPolicies.RemoveRule(s.scope.Project(), policy.Name).Priority(rule.Priority).Do())
+			})
+			if err != nil && !gcperrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to remove rule from network firewall policy")
+			}
+			_ = s.operationCompleted(resource, "delete", op, nil)
+		}
+		s.scope.SetFirewallRule(name, "")
+	}
+
+	return nil
+}
+
+// reconcileFirewallPolicy gets or creates the network firewall policy of the cluster, and associates it with the
+// network of the cluster. A pre-existing policy must be adopted, the policies of other clusters or created
+// out-of-band are never modified.
+func (s *Service) reconcileFirewallPolicy(client *computealpha.Service) (*computealpha.FirewallPolicy, error) {
+	resource := s.firewallPolicyResource()
+	policy, err := client.NetworkFirewallPolicies.Get(s.scope.Project(), s.firewallPolicyName()).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		if err := s.runInsertOperation(resource, func() (*compute.Operation, error) {
+			return computeOperation(client.NetworkFirewallPolicies.Insert(s.scope.Project(), &computealpha.FirewallPolicy{
+				Name:        s.firewallPolicyName(),
+				Description: s.ownershipMarker(),
+			}).Do())
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to create network firewall policy")
+		}
+		policy, err = client.NetworkFirewallPolicies.Get(s.scope.Project(), s.firewallPolicyName()).Do()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe network firewall policy")
+		}
+	case err != nil:
+		return nil, errors.Wrapf(err, "failed to describe network firewall policy")
+	case !s.isOwned(resource, policy.Description) && !s.adopt("network firewall policy", resource, policy.Description):
+		return nil, errors.Errorf("network firewall policy %q isn't owned by the cluster, set the %s annotation to adopt it",
+			policy.Name, infrav1.AdoptAnnotation)
+	}
+
+	// The associations may reference the network with the URL of another version of the API.
+	network := s.scope.NetworkSelfLink()
+	if i := strings.Index(network, "projects/"); i >= 0 {
+		network = network[i:]
+	}
+	for _, association := range policy.Associations {
+		if sameResource(association.AttachmentTarget, network) {
+			return policy, nil
+		}
+	}
+	op, err := s.runOperation(s.firewallPolicyResource(), "addAssociation", func() (*compute.Operation, error) {
+		return computeOperation(client.NetworkFirewallPolicies.AddAssociation(s.scope.Project(), policy.Name, &computealpha.FirewallPolicyAssociation{
+			Name:             s.scope.NetworkName(),
+			AttachmentTarget: s.scope.NetworkSelfLink(),
+		}).Do())
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to associate network firewall policy with network")
+	}
+	s.scope.V(2).Info("Associated network firewall policy with network", "policy", policy.Name, "network", s.scope.NetworkName(), "operation", op.Name)
+
+	return policy, nil
+}
+
+// deleteFirewallPolicyRules deletes the network firewall policy of the cluster, and its rules with it, unless the
+// firewall rules must be retained or the policy isn't owned by the cluster.
+func (s *Service) deleteFirewallPolicyRules(specs []*compute.Firewall) error {
+	names := sets.NewString(s.scope.FirewallRuleNames()...)
+	for _, spec := range specs {
+		names.Insert(spec.Name)
+	}
+
+	client, err := s.scope.AlphaCompute()
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete network firewall policy")
+	}
+
+	resource := s.firewallPolicyResource()
+	policy, err := client.NetworkFirewallPolicies.Get(s.scope.Project(), s.firewallPolicyName()).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		s.scope.SetOwnedResource(resource, false)
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe network firewall policy")
+	case !s.isOwned(resource, policy.Description):
+	case s.scope.ShouldRetain(infrav1.RetainFirewallRules):
+		s.recordRetained("network firewall policy", policy.Name)
+		s.scope.SetOwnedResource(resource, false)
+	default:
+		// The policy can't be deleted while it is associated with a network.
+		for _, association := range policy.Associations {
+			if _, err := s.runOperation(resource, "removeAssociation", func() (*compute.Operation, error) {
+				return computeOperation(client.NetworkFirewallPolicies.RemoveAssociation(s.scope.Project(), policy.Name).Name(association.Name).Do())
+			}); err != nil && !gcperrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to remove association of network firewall policy")
+			}
+		}
+		if err := s.runDeleteOperation(resource, func() (*compute.Operation, error) {
+			return computeOperation(client.NetworkFirewallPolicies.Delete(s.scope.Project(), policy.Name).Do())
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete network firewall policy")
+		}
+	}
+
+	for _, name := range names.List() {
+		s.scope.SetFirewallRule(name, "")
+	}
+
+	return nil
+}
+
+// firewallPolicyRule returns the rule of the network firewall policy allowing the traffic of the firewall rule.
+// The rule applies to the instances of the network the policy is associated with, and the source tags, unsupported
+// by the firewall policies, are replaced with the ranges of the subnetworks of the region of the cluster.
+func (s *Service) firewallPolicyRule(spec *compute.Firewall) (*computealpha.FirewallPolicyRule, error) {
+	rule := &computealpha.FirewallPolicyRule{
+		Action:      "allow",
+		Description: spec.Name,
+		Direction:   spec.Direction,
+		Match: &computealpha.FirewallPolicyRuleMatcher{
+			SrcIpRanges:  append([]string{}, spec.SourceRanges...),
+			DestIpRanges: spec.DestinationRanges,
+		},
+		// The first rule may have the priority 0, which would be omitted otherwise.
+		ForceSendFields: []string{"Priority"},
+	}
+	if len(spec.SourceTags) > 0 {
+		ranges := s.clusterSourceRanges()
+		if len(ranges) == 0 {
+			return nil, errors.Errorf("failed to allow the traffic of firewall rule %q: no subnetwork of the cluster in the region", spec.Name)
+		}
+		rule.Match.SrcIpRanges = append(rule.Match.SrcIpRanges, ranges...)
+	}
+	for _, allowed := range spec.Allowed {
+		rule.Match.Layer4Configs = append(rule.Match.Layer4Configs, &computealpha.FirewallPolicyRuleMatcherLayer4Config{
+			IpProtocol: strings.ToLower(allowed.IPProtocol),
+			Ports:      allowed.Ports,
+		})
+	}
+
+	return rule, nil
+}
+
+// clusterSourceRanges returns the primary and secondary IPv4 ranges of the subnetworks of the network in the region
+// of the cluster, as recorded in its status.
+func (s *Service) clusterSourceRanges() []string {
+	var res []string
+	for _, subnet := range s.scope.GCPCluster.Status.Network.Subnets {
+		if subnet.CidrBlock != "" {
+			res = append(res, subnet.CidrBlock)
+		}
+		secondary := make([]string, 0, len(subnet.SecondaryCidrBlocks))
+		for _, cidr := range subnet.SecondaryCidrBlocks {
+			secondary = append(secondary, cidr)
+		}
+		sort.Strings(secondary)
+		res = append(res, secondary...)
+	}
+
+	return res
+}
+
+// computeOperation converts the operation issued with the compute alpha API, for it to be polled with the GA API.
+func computeOperation(alphaOp *computealpha.Operation, err error) (*compute.Operation, error) {
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(alphaOp)
+	if err != nil {
+		return nil, err
+	}
+	op := &compute.Operation{}
+	if err := json.Unmarshal(data, op); err != nil {
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// firewallPolicyName returns the name of the network firewall policy of the cluster, defaults to
+// <resource name prefix>-firewall-policy.
+func (s *Service) firewallPolicyName() string {
+	if name := s.scope.GCPCluster.Spec.Network.FirewallPolicy.Name; name != "" {
+		return name
+	}
+
+	return names.Truncate(fmt.Sprintf("%s-firewall-policy", s.scope.ResourceNamePrefix()))
+}
+
+// firewallPolicyPriority returns the priority of the first rule of the cluster in the policy, defaults to 1000.
+func (s *Service) firewallPolicyPriority() int64 {
+	if p := s.scope.GCPCluster.Spec.Network.FirewallPolicy.Priority; p != nil {
+		return int64(*p)
+	}
+
+	return defaultFirewallPolicyPriority
+}
+
+// firewallPolicyResource returns the path of the network firewall policy in the inventory of the cluster resources.
+func (s *Service) firewallPolicyResource() string {
+	return path.Join("global", "firewallPolicies", s.firewallPolicyName())
+}
+
+// firewallPolicyRuleResource returns the path of the rule of the cluster referenced in the events.
+func (s *Service) firewallPolicyRuleResource(name string) string {
+	return path.Join(s.firewallPolicyResource(), "rules", name)
+}
+
+// firewallPolicyRuleLink returns the full reference to the rule of the policy, recorded in the cluster status.
+func firewallPolicyRuleLink(policy *computealpha.FirewallPolicy, priority int64) string {
+	return fmt.Sprintf("%s/getRule?priority=%d", policy.SelfLink, priority)
+}
@@ -0,0 +1,127 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/feature"
+)
+
+func TestReconcileFirewallPolicyRules(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ComputeAlphaAPI))).To(Succeed())
+	defer func() {
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ComputeAlphaAPI))).To(Succeed())
+	}()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.Network.FirewallPolicy = &infrav1.FirewallPolicySpec{Mode: infrav1.FirewallPolicyModeNetwork}
+	params.GCPCluster.Spec.Network.AdditionalFirewallRules = []infrav1.FirewallRuleSpec{
+		{Name: "nodeports", Allowed: []infrav1.FirewallAllowedSpec{{Protocol: "tcp", Ports: []string{"30000-32767"}}}},
+	}
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	s.scope.GCPCluster.Status.Network.SelfLink = pointer.StringPtr(c.SelfLink("projects/my-project/global/networks/default"))
+	s.scope.GCPCluster.Status.Network.Subnets = []infrav1.SubnetStatus{
+		{Name: "my-subnet", CidrBlock: "10.0.0.0/20", SecondaryCidrBlocks: map[string]string{"pods": "10.4.0.0/14"}},
+	}
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+
+	// No VPC firewall rule is created, the rules are added to the policy of the cluster associated with its network.
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(BeEmpty())
+	policy := &computealpha.FirewallPolicy{}
+	g.Expect(c.Get("projects/my-project/global/firewallPolicies/my-cluster-firewall-policy", policy)).To(BeTrue())
+	g.Expect(s.scope.IsOwnedResource("global/firewallPolicies/my-cluster-firewall-policy")).To(BeTrue())
+	g.Expect(policy.Associations).To(HaveLen(1))
+	g.Expect(policy.Associations[0].AttachmentTarget).To(Equal(s.scope.NetworkSelfLink()))
+	rules := map[string]*computealpha.FirewallPolicyRule{}
+	for _, rule := range policy.Rules {
+		rules[rule.Description] = rule
+	}
+	g.Expect(rules).To(HaveLen(3))
+	cluster := rules["allow-my-cluster-apiserver-cluster"]
+	g.Expect(cluster).NotTo(BeNil())
+	g.Expect(cluster.Action).To(Equal("allow"))
+	g.Expect(cluster.Match.SrcIpRanges).To(ConsistOf("10.0.0.0/20", "10.4.0.0/14"))
+	g.Expect(rules["my-cluster-nodeports"].Match.Layer4Configs).To(Equal([]*computealpha.FirewallPolicyRuleMatcherLayer4Config{
+		{IpProtocol: "tcp", Ports: []string{"30000-32767"}},
+	}))
+	g.Expect(s.scope.Network().FirewallRules).To(HaveKeyWithValue("allow-my-cluster-apiserver-cluster",
+		fmt.Sprintf("%s/getRule?priority=%d", policy.SelfLink, cluster.Priority)))
+
+	// A rule modified out-of-band is restored, a removed one is removed from the policy.
+	cluster.Disabled = true
+	c.Put("projects/my-project/global/firewallPolicies/my-cluster-firewall-policy", policy)
+	s.scope.GCPCluster.Spec.Network.AdditionalFirewallRules = nil
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	policy = &computealpha.FirewallPolicy{}
+	g.Expect(c.Get("projects/my-project/global/firewallPolicies/my-cluster-firewall-policy", policy)).To(BeTrue())
+	g.Expect(policy.Associations).To(HaveLen(1))
+	g.Expect(policy.Rules).To(HaveLen(2))
+	for _, rule := range policy.Rules {
+		g.Expect(rule.Disabled).To(BeFalse())
+		g.Expect(rule.Description).NotTo(Equal("my-cluster-nodeports"))
+	}
+	g.Expect(s.scope.Network().FirewallRules).NotTo(HaveKey("my-cluster-nodeports"))
+
+	// The policy and its rules are deleted with the cluster.
+	g.Expect(s.DeleteFirewalls()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/firewallPolicies/my-cluster-firewall-policy", nil)).To(BeFalse())
+	g.Expect(s.scope.IsOwnedResource("global/firewallPolicies/my-cluster-firewall-policy")).To(BeFalse())
+	g.Expect(s.scope.Network().FirewallRules).To(BeEmpty())
+}
+
+func TestReconcileFirewallPolicyRulesNotOwned(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ComputeAlphaAPI))).To(Succeed())
+	defer func() {
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ComputeAlphaAPI))).To(Succeed())
+	}()
+
+	// The policy of another cluster, or created out-of-band, with the same name.
+	c.Put("projects/my-project/global/firewallPolicies/shared", &computealpha.FirewallPolicy{
+		Description: "someone else's",
+		Rules: []*computealpha.FirewallPolicyRule{
+			{Priority: 1000, Action: "allow", Direction: "INGRESS", Description: "allow-my-cluster-apiserver-cluster"},
+		},
+	})
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.Network.FirewallPolicy = &infrav1.FirewallPolicySpec{Mode: infrav1.FirewallPolicyModeNetwork, Name: "shared"}
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	s.scope.GCPCluster.Status.Network.SelfLink = pointer.StringPtr(c.SelfLink("projects/my-project/global/networks/default"))
+	s.scope.GCPCluster.Status.Network.Subnets = []infrav1.SubnetStatus{{Name: "my-subnet", CidrBlock: "10.0.0.0/20"}}
+
+	// The policy is neither modified nor deleted.
+	g.Expect(s.ReconcileFirewalls()).NotTo(Succeed())
+	g.Expect(s.DeleteFirewalls()).To(Succeed())
+	policy := &computealpha.FirewallPolicy{}
+	g.Expect(c.Get("projects/my-project/global/firewallPolicies/shared", policy)).To(BeTrue())
+	g.Expect(policy.Associations).To(BeEmpty())
+	g.Expect(policy.Rules).To(HaveLen(1))
+	g.Expect(policy.Rules[0].Match).To(BeNil())
+}
@@ -18,101 +18,364 @@ package compute
 
 import (
 	"fmt"
+	"path"
 	"strconv"
 
 	"github.com/pkg/errors"
 	"google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
-	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
 )
 
 // ReconcileFirewalls reconciles the firewalls and apply changes if needed.
 func (s *Service) ReconcileFirewalls() error {
-	for _, firewallSpec := range s.getFirewallSpecs() {
-		// Get or create the firewall rules.
-		firewall, err := s.firewalls.Get(s.scope.Project(), firewallSpec.Name).Do()
-		if gcperrors.IsNotFound(err) {
-			op, err := s.firewalls.Insert(s.scope.Project(), firewallSpec).Do()
-			if err != nil {
-				return errors.Wrapf(err, "failed to create firewall rule")
-			}
-			if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
-				return errors.Wrapf(err, "failed to create firewall rule")
-			}
-			firewall, err = s.firewalls.Get(s.scope.Project(), firewallSpec.Name).Do()
-			if err != nil {
-				return errors.Wrapf(err, "failed to describe firewall rule")
-			}
-		} else if err != nil {
+	specs := s.getFirewallSpecs()
+	if s.scope.FirewallPolicyMode() == infrav1.FirewallPolicyModeNetwork {
+		return s.reconcileFirewallPolicyRules(specs)
+	}
+
+	for _, firewallSpec := range specs {
+		if err := s.reconcileFirewall(firewallSpec); err != nil {
+			return err
+		}
+	}
+
+	// Delete the rules no longer needed, e.g. once the IAP access is disabled, the health checks of the
+	// load balancer come from other ranges or an additional rule is removed from the spec.
+	for _, name := range s.scope.FirewallRuleNames() {
+		if containsFirewall(specs, name) {
+			continue
+		}
+		if err := s.deleteFirewall(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileFirewall gets or creates the firewall rule, restores it if it was modified out-of-band,
+// and records it in the cluster status. A pre-existing rule must be owned or adopted by the cluster.
+func (s *Service) reconcileFirewall(firewallSpec *compute.Firewall) error {
+	firewall, err := s.firewalls.Get(s.scope.Project(), firewallSpec.Name).Do()
+	if gcperrors.IsNotFound(err) {
+		if err := s.runInsertOperation(path.Join("global", "firewalls", firewallSpec.Name), func() (*compute.Operation, error) {
+			return s.firewalls.Insert(s.scope.Project(), firewallSpec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create firewall rule")
+		}
+		firewall, err = s.firewalls.Get(s.scope.Project(), firewallSpec.Name).Do()
+		if err != nil {
 			return errors.Wrapf(err, "failed to describe firewall rule")
 		}
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe firewall rule")
+	} else if err := s.ensureOwned("firewall rule", path.Join("global", "firewalls", firewall.Name), firewall.Description); err != nil {
+		return err
+	}
 
-		// Store in the Cluster Status.
-		if s.scope.Network().FirewallRules == nil {
-			s.scope.Network().FirewallRules = make(map[string]string)
+	if drift := firewallDrift(firewall, firewallSpec); drift != "" && !s.deferDisruptiveChange("firewall rule", firewall.Name, drift) {
+		// Restore the rule modified out-of-band, the description of an adopted rule is kept.
+		update := *firewallSpec
+		update.Description = firewall.Description
+		op, err := s.runOperation(path.Join("global", "firewalls", firewall.Name), "update", func() (*compute.Operation, error) {
+			return s.firewalls.Update(s.scope.Project(), firewall.Name, &update).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to update firewall rule")
 		}
-		s.scope.Network().FirewallRules[firewall.Name] = firewall.SelfLink
+		s.recordDriftCorrected("firewall rule", firewall.Name, drift, op)
 	}
 
+	// Store in the Cluster Status.
+	s.scope.SetFirewallRule(firewall.Name, firewall.SelfLink)
+
 	return nil
 }
 
 // DeleteFirewalls deletes all Firewall Rules.
+// The rules are deleted by name, so that they are cleaned up even if they are not recorded
+// in the status, e.g. after the cluster was moved by clusterctl which doesn't move the status.
 func (s *Service) DeleteFirewalls() error {
-	for name := range s.scope.Network().FirewallRules {
-		op, err := s.firewalls.Delete(s.scope.Project(), name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete firewalls")
+	if s.scope.FirewallPolicyMode() == infrav1.FirewallPolicyModeNetwork {
+		return s.deleteFirewallPolicyRules(s.getFirewallSpecs())
+	}
+
+	names := sets.NewString(s.scope.FirewallRuleNames()...)
+	for _, spec := range s.getFirewallSpecs() {
+		names.Insert(spec.Name)
+	}
+
+	// The rules don't depend on each other, they are deleted concurrently.
+	fns := make([]func() error, 0, names.Len())
+	for _, name := range names.List() {
+		name := name
+		fns = append(fns, func() error { return s.deleteFirewall(name) })
+	}
+
+	return reconciler.RunParallel(reconciler.DefaultParallelism, fns...)
+}
+
+// deleteFirewall deletes the firewall rule owned by the cluster unless it must be retained, and removes it from the
+// cluster status.
+func (s *Service) deleteFirewall(name string) error {
+	if s.scope.ShouldRetain(infrav1.RetainFirewallRules) {
+		if s.scope.HasFirewallRule(name) {
+			s.recordRetained("firewall rule", name)
+		}
+	} else {
+		if err := s.runOwnedDeleteOperation("firewall rule", path.Join("global", "firewalls", name), func() (string, error) {
+			firewall, err := s.firewalls.Get(s.scope.Project(), name).Do()
+			if err != nil {
+				return "", err
+			}
+
+			return firewall.Description, nil
+		}, func() (*compute.Operation, error) {
+			return s.firewalls.Delete(s.scope.Project(), name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete firewalls")
 		}
-		delete(s.scope.Network().FirewallRules, name)
 	}
+	s.scope.SetFirewallRule(name, "")
 
 	return nil
 }
 
 func (s *Service) getFirewallSpecs() []*compute.Firewall {
-	return []*compute.Firewall{
+	specs := []*compute.Firewall{
 		{
-			Name:    fmt.Sprintf("allow-%s-%s-healthchecks", s.scope.Name(), infrav1.APIServerRoleTagValue),
-			Network: s.scope.NetworkSelfLink(),
+			Name:        names.Truncate(fmt.Sprintf("allow-%s-%s-cluster", s.scope.ResourceNamePrefix(), infrav1.APIServerRoleTagValue)),
+			Description: s.namespacedOwnershipMarker(),
+			Network:     s.scope.NetworkSelfLink(),
 			Allowed: []*compute.FirewallAllowed{
 				{
-					IPProtocol: "TCP",
-					Ports: []string{
-						strconv.FormatInt(s.scope.LoadBalancerBackendPort(), 10),
-					},
+					IPProtocol: "all",
 				},
 			},
 			Direction: "INGRESS",
-			SourceRanges: []string{
-				// Allow Google's internal IP ranges to perform health checks against our registered API servers.
-				// For more information, https://cloud.google.com/load-balancing/docs/health-checks#fw-rule.
-				"35.191.0.0/16",
-				"130.211.0.0/22",
+			SourceTags: []string{
+				s.roleTag("control-plane"),
+				s.roleTag("node"),
 			},
 			TargetTags: []string{
-				fmt.Sprintf("%s-control-plane", s.scope.Name()),
+				s.roleTag("control-plane"),
+				s.roleTag("node"),
 			},
 		},
-		{
-			Name:    fmt.Sprintf("allow-%s-%s-cluster", s.scope.Name(), infrav1.APIServerRoleTagValue),
-			Network: s.scope.NetworkSelfLink(),
+	}
+
+	// The source tags only match the IPv4 traffic, the IPv6 traffic between the dual-stack instances of the cluster
+	// is allowed from the IPv6 ranges of their subnetworks.
+	if sourceRanges := s.clusterIPv6SourceRanges(); len(sourceRanges) > 0 {
+		specs = append(specs, &compute.Firewall{
+			Name:        s.clusterIPv6FirewallName(),
+			Description: s.namespacedOwnershipMarker(),
+			Network:     s.scope.NetworkSelfLink(),
 			Allowed: []*compute.FirewallAllowed{
 				{
 					IPProtocol: "all",
 				},
 			},
-			Direction: "INGRESS",
-			SourceTags: []string{
-				fmt.Sprintf("%s-control-plane", s.scope.Name()),
-				fmt.Sprintf("%s-node", s.scope.Name()),
+			Direction:    "INGRESS",
+			SourceRanges: sourceRanges,
+			TargetTags: []string{
+				s.roleTag("control-plane"),
+				s.roleTag("node"),
+			},
+		})
+	}
+
+	if sourceRanges := s.healthCheckSourceRanges(); len(sourceRanges) > 0 {
+		specs = append(specs, &compute.Firewall{
+			Name:        s.healthCheckFirewallName(),
+			Description: s.namespacedOwnershipMarker(),
+			Network:     s.scope.NetworkSelfLink(),
+			Allowed: []*compute.FirewallAllowed{
+				{
+					IPProtocol: "TCP",
+					Ports: []string{
+						strconv.FormatInt(s.scope.LoadBalancerBackendPort(), 10),
+					},
+				},
 			},
+			Direction:    "INGRESS",
+			SourceRanges: sourceRanges,
 			TargetTags: []string{
-				fmt.Sprintf("%s-control-plane", s.scope.Name()),
-				fmt.Sprintf("%s-node", s.scope.Name()),
+				s.roleTag("control-plane"),
 			},
-		},
+		})
 	}
+
+	// The clients of a TargetInstance or an Internal load balancer reach the API server of the instances directly,
+	// from the internet or from the private ranges of the network respectively.
+	if sourceRanges := s.clientsSourceRanges(); len(sourceRanges) > 0 {
+		specs = append(specs, &compute.Firewall{
+			Name:        s.clientsFirewallName(),
+			Description: s.namespacedOwnershipMarker(),
+			Network:     s.scope.NetworkSelfLink(),
+			Allowed: []*compute.FirewallAllowed{
+				{
+					IPProtocol: "TCP",
+					Ports: []string{
+						strconv.FormatInt(s.scope.LoadBalancerBackendPort(), 10),
+					},
+				},
+			},
+			Direction:    "INGRESS",
+			SourceRanges: sourceRanges,
+			TargetTags: []string{
+				s.roleTag("control-plane"),
+			},
+		})
+	}
+
+	if s.scope.GCPCluster.Spec.IAPAccess {
+		specs = append(specs, &compute.Firewall{
+			Name:        s.iapFirewallName(),
+			Description: s.namespacedOwnershipMarker(),
+			Network:     s.scope.NetworkSelfLink(),
+			Allowed: []*compute.FirewallAllowed{
+				{
+					IPProtocol: "TCP",
+					Ports:      []string{"22"},
+				},
+			},
+			Direction:    "INGRESS",
+			SourceRanges: []string{iapSourceRange},
+			TargetTags: []string{
+				s.iapTag(),
+			},
+		})
+	}
+
+	for i := range s.scope.GCPCluster.Spec.Network.AdditionalFirewallRules {
+		specs = append(specs, s.getAdditionalFirewallSpec(&s.scope.GCPCluster.Spec.Network.AdditionalFirewallRules[i]))
+	}
+
+	return specs
+}
+
+// getAdditionalFirewallSpec returns the firewall rule of the additional rule of the network spec.
+// The rule applies to all the addresses and to the instances of the cluster unless restricted, as
+// GCP would default the ranges to all the addresses.
+func (s *Service) getAdditionalFirewallSpec(rule *infrav1.FirewallRuleSpec) *compute.Firewall {
+	spec := &compute.Firewall{
+		Name:        s.additionalFirewallName(rule.Name),
+		Description: s.namespacedOwnershipMarker(),
+		Network:     s.scope.NetworkSelfLink(),
+		Direction:   string(rule.Direction),
+		TargetTags:  rule.TargetTags,
+	}
+	for _, allowed := range rule.Allowed {
+		spec.Allowed = append(spec.Allowed, &compute.FirewallAllowed{
+			IPProtocol: allowed.Protocol,
+			Ports:      allowed.Ports,
+		})
+	}
+	if len(spec.TargetTags) == 0 {
+		spec.TargetTags = []string{s.roleTag("control-plane"), s.roleTag("node")}
+	}
+
+	if rule.Direction == infrav1.FirewallRuleDirectionEgress {
+		spec.DestinationRanges = rule.DestinationRanges
+		if len(spec.DestinationRanges) == 0 {
+			spec.DestinationRanges = []string{"0.0.0.0/0"}
+		}
+	} else {
+		spec.Direction = string(infrav1.FirewallRuleDirectionIngress)
+		spec.SourceRanges = rule.SourceRanges
+		if len(spec.SourceRanges) == 0 {
+			spec.SourceRanges = []string{"0.0.0.0/0"}
+		}
+	}
+
+	return spec
+}
+
+// containsFirewall returns true if the rule with the name is one of the specs.
+func containsFirewall(specs []*compute.Firewall, name string) bool {
+	for _, spec := range specs {
+		if spec.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// healthCheckSourceRanges returns the ranges the health checks of the API server load balancer are sent from,
+// which depend on its type. The probes of an Internal load balancer are sent from the same ranges as the ones of
+// a proxy. For more information, https://cloud.google.com/load-balancing/docs/health-check-concepts#ip-ranges.
+func (s *Service) healthCheckSourceRanges() []string {
+	switch s.scope.LoadBalancerType() {
+	case infrav1.LoadBalancerTypeTargetInstance, infrav1.LoadBalancerTypeNone:
+		// The target instances aren't health checked, the health checks of a load balancer managed outside of the
+		// provider are allowed with it.
+		return nil
+	default:
+		return []string{"35.191.0.0/16", "130.211.0.0/22"}
+	}
+}
+
+// clientsSourceRanges returns the ranges the clients of the API server load balancer reach the instances from,
+// if the traffic isn't proxied. The addresses of the clients of an Internal load balancer are private. The clients
+// of a control plane endpoint managed outside of the provider are allowed with it, e.g. with an additional rule.
+func (s *Service) clientsSourceRanges() []string {
+	switch {
+	case s.scope.LoadBalancerType() == infrav1.LoadBalancerTypeTargetInstance:
+		return []string{"0.0.0.0/0"}
+	case s.scope.LoadBalancerScheme() == infrav1.LoadBalancerSchemeInternal:
+		return []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"}
+	default:
+		return nil
+	}
+}
+
+// clusterIPv6SourceRanges returns the IPv6 ranges of the dual-stack subnetworks of the network in the region of the
+// cluster, as recorded in its status.
+func (s *Service) clusterIPv6SourceRanges() []string {
+	var res []string
+	for _, subnet := range s.scope.GCPCluster.Status.Network.Subnets {
+		if subnet.IPv6CidrBlock != "" {
+			res = append(res, subnet.IPv6CidrBlock)
+		}
+	}
+
+	return res
+}
+
+func (s *Service) clusterIPv6FirewallName() string {
+	return names.Truncate(fmt.Sprintf("allow-%s-%s-cluster-ipv6", s.scope.ResourceNamePrefix(), infrav1.APIServerRoleTagValue))
+}
+
+func (s *Service) healthCheckFirewallName() string {
+	return names.Truncate(fmt.Sprintf("allow-%s-%s-healthchecks", s.scope.ResourceNamePrefix(), infrav1.APIServerRoleTagValue))
+}
+
+func (s *Service) clientsFirewallName() string {
+	return names.Truncate(fmt.Sprintf("allow-%s-%s-clients", s.scope.ResourceNamePrefix(), infrav1.APIServerRoleTagValue))
+}
+
+// additionalFirewallName returns the name of the additional rule of the network spec.
+func (s *Service) additionalFirewallName(name string) string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), name))
+}
+
+func (s *Service) iapFirewallName() string {
+	return names.Truncate(fmt.Sprintf("allow-%s-iap-ssh", s.scope.ResourceNamePrefix()))
+}
+
+// iapTag returns the network tag of the instances of the cluster reachable through IAP.
+func (s *Service) iapTag() string {
+	return names.Truncate(fmt.Sprintf("%s-iap-ssh", s.scope.Name()))
+}
+
+// roleTag returns the network tag of the instances of the cluster with the role.
+func (s *Service) roleTag(role string) string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.Name(), role))
 }
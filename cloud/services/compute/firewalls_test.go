@@ -0,0 +1,199 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileHealthCheckFirewall(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+
+	// The health checks of a proxy load balancer come from the ranges of the Google front ends.
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceRanges).To(ConsistOf("35.191.0.0/16", "130.211.0.0/22"))
+	g.Expect(firewall.Allowed[0].Ports).To(ConsistOf("6443"))
+
+	// A TargetInstance load balancer has no health check, its rule is replaced by the rule of the clients.
+	s.scope.GCPCluster.Spec.LoadBalancer.Type = infrav1.LoadBalancerTypeTargetInstance
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-clients", nil)).To(BeTrue())
+	g.Expect(s.scope.Network().FirewallRules).To(HaveLen(2))
+
+	s.scope.GCPCluster.Spec.LoadBalancer.Type = infrav1.LoadBalancerTypeProxy
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks", nil)).To(BeTrue())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-clients", nil)).To(BeFalse())
+}
+
+func TestReconcileAdditionalFirewallRules(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.Network.AdditionalFirewallRules = []infrav1.FirewallRuleSpec{
+		{
+			Name:    "nodeports",
+			Allowed: []infrav1.FirewallAllowedSpec{{Protocol: "tcp", Ports: []string{"30000-32767"}}},
+		},
+		{
+			Name:              "vpn",
+			Direction:         infrav1.FirewallRuleDirectionEgress,
+			Allowed:           []infrav1.FirewallAllowedSpec{{Protocol: "udp", Ports: []string{"500", "4500"}}, {Protocol: "esp"}},
+			DestinationRanges: []string{"203.0.113.0/24"},
+			TargetTags:        []string{"vpn"},
+		},
+	}
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+
+	// The rules apply to all the addresses and the instances of the cluster unless restricted.
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/my-cluster-nodeports", firewall)).To(BeTrue())
+	g.Expect(firewall.Direction).To(Equal("INGRESS"))
+	g.Expect(firewall.SourceRanges).To(ConsistOf("0.0.0.0/0"))
+	g.Expect(firewall.TargetTags).To(ConsistOf("my-cluster-control-plane", "my-cluster-node"))
+	g.Expect(firewall.Allowed).To(Equal([]*compute.FirewallAllowed{{IPProtocol: "tcp", Ports: []string{"30000-32767"}}}))
+	firewall = &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/my-cluster-vpn", firewall)).To(BeTrue())
+	g.Expect(firewall.Direction).To(Equal("EGRESS"))
+	g.Expect(firewall.DestinationRanges).To(ConsistOf("203.0.113.0/24"))
+	g.Expect(firewall.SourceRanges).To(BeEmpty())
+	g.Expect(firewall.TargetTags).To(ConsistOf("vpn"))
+	g.Expect(s.scope.Network().FirewallRules).To(HaveKey("my-cluster-vpn"))
+
+	// A changed rule is updated in place, a removed one is deleted.
+	s.scope.GCPCluster.Spec.Network.AdditionalFirewallRules = s.scope.GCPCluster.Spec.Network.AdditionalFirewallRules[:1]
+	s.scope.GCPCluster.Spec.Network.AdditionalFirewallRules[0].SourceRanges = []string{"10.0.0.0/8"}
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	firewall = &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/my-cluster-nodeports", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceRanges).To(ConsistOf("10.0.0.0/8"))
+	g.Expect(c.Get("projects/my-project/global/firewalls/my-cluster-vpn", nil)).To(BeFalse())
+	g.Expect(s.scope.Network().FirewallRules).NotTo(HaveKey("my-cluster-vpn"))
+
+	// The remaining rules are deleted with the cluster.
+	g.Expect(s.DeleteFirewalls()).To(Succeed())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(BeEmpty())
+}
+
+func TestReconcileFirewallsMaintenanceWindow(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	// The window opens on Saturdays at 22:00 UTC for 4h, the reconcile runs on Monday.
+	now := time.Date(2021, time.June, 7, 10, 0, 0, 0, time.UTC)
+	params := newTestClusterScopeParams(g, c)
+	params.Now = func() time.Time { return now }
+	params.GCPCluster.Spec.MaintenanceWindow = &infrav1.MaintenanceWindowSpec{
+		Days:      []infrav1.Weekday{"Saturday"},
+		StartTime: "22:00",
+		Duration:  metav1.Duration{Duration: 4 * time.Hour},
+	}
+	clusterScope := newTestClusterScopeFromParams(g, params)
+	g.Expect(clusterScope.InMaintenanceWindow()).To(BeFalse())
+
+	// The rules are created outside of the window.
+	g.Expect(NewService(clusterScope).ReconcileFirewalls()).To(Succeed())
+	g.Expect(clusterScope.SetDisruptiveChangesApplied()).To(BeZero())
+	g.Expect(conditions.IsTrue(clusterScope.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)).To(BeTrue())
+
+	// Their drift is corrected within the window only.
+	c.Put("projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster", &compute.Firewall{Disabled: true})
+	clusterScope = newTestClusterScopeFromParams(g, params)
+	g.Expect(NewService(clusterScope).ReconcileFirewalls()).To(Succeed())
+	g.Expect(clusterScope.SetDisruptiveChangesApplied()).To(Equal(5*24*time.Hour + 12*time.Hour))
+	g.Expect(conditions.GetReason(clusterScope.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)).To(Equal(infrav1.WaitingForMaintenanceWindowReason))
+	g.Expect(conditions.GetMessage(clusterScope.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)).To(Equal(
+		`Deferred to the maintenance window starting at 2021-06-12T22:00:00Z: firewall rule "allow-my-cluster-apiserver-cluster": rule is disabled`))
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster", firewall)).To(BeTrue())
+	g.Expect(firewall.Disabled).To(BeTrue())
+
+	// The window spans midnight.
+	now = time.Date(2021, time.June, 13, 1, 0, 0, 0, time.UTC)
+	clusterScope = newTestClusterScopeFromParams(g, params)
+	g.Expect(clusterScope.InMaintenanceWindow()).To(BeTrue())
+	g.Expect(NewService(clusterScope).ReconcileFirewalls()).To(Succeed())
+	g.Expect(clusterScope.SetDisruptiveChangesApplied()).To(BeZero())
+	g.Expect(conditions.IsTrue(clusterScope.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)).To(BeTrue())
+	firewall = &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster", firewall)).To(BeTrue())
+	g.Expect(firewall.Disabled).To(BeFalse())
+
+	now = time.Date(2021, time.June, 13, 2, 0, 0, 0, time.UTC)
+	g.Expect(clusterScope.InMaintenanceWindow()).To(BeFalse())
+}
+
+func TestReconcileIAPAccess(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name: "my-machine",
+		Zone: c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Tags: &compute.Tags{Items: []string{"my-cluster-node", "my-cluster"}},
+	})
+
+	// Enabling the IAP access creates the rule allowing the IAP range, and tags the existing instances.
+	clusterScope.GCPCluster.Spec.IAPAccess = true
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-iap-ssh", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceRanges).To(ConsistOf(iapSourceRange))
+	g.Expect(firewall.TargetTags).To(ConsistOf("my-cluster-iap-ssh"))
+
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.ReconcileInstanceTags(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Tags.Items).To(ConsistOf("my-cluster-node", "my-cluster", "my-cluster-iap-ssh"))
+
+	// Disabling it deletes the rule and untags the instances.
+	clusterScope.GCPCluster.Spec.IAPAccess = false
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-iap-ssh", nil)).To(BeFalse())
+	g.Expect(clusterScope.Network().FirewallRules).NotTo(HaveKey("allow-my-cluster-iap-ssh"))
+
+	g.Expect(s.ReconcileInstanceTags(machineScope, instance)).To(Succeed())
+	instance = &compute.Instance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Tags.Items).To(ConsistOf("my-cluster-node", "my-cluster"))
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/blang/semver/v4"
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+// imageLookupData is the data the label values of an image lookup are rendered with.
+type imageLookupData struct {
+	// KubernetesVersion is the version of the Machine, e.g. v1-21-2.
+	KubernetesVersion string
+	// KubernetesMinorVersion is the minor version of the Machine, e.g. v1-21.
+	KubernetesMinorVersion string
+}
+
+// lookupImage returns the newest image of the project in the family and matching the labels of the lookup.
+// The deprecated images are ignored.
+func (s *Service) lookupImage(scope *scope.MachineScope, lookup *infrav1.ImageLookup) (string, error) {
+	var data imageLookupData
+	if version := scope.Machine.Spec.Version; version != nil {
+		parsed, err := semver.ParseTolerant(*version)
+		if err != nil {
+			return "", errors.Wrapf(err, "error parsing Spec.Version on Machine %q in namespace %q, expected valid SemVer string",
+				scope.Name(), scope.Namespace())
+		}
+		data.KubernetesVersion = fmt.Sprintf("v%d-%d-%d", parsed.Major, parsed.Minor, parsed.Patch)
+		data.KubernetesMinorVersion = fmt.Sprintf("v%d-%d", parsed.Major, parsed.Minor)
+	}
+
+	var filters []string
+	if lookup.Family != "" {
+		family, err := renderImageLookup(lookup.Family, data)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to render the image lookup family")
+		}
+		filters = append(filters, fmt.Sprintf("family = %q", family))
+	}
+	keys := make([]string, 0, len(lookup.Labels))
+	for key := range lookup.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, err := renderImageLookup(lookup.Labels[key], data)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to render the image lookup label %q", key)
+		}
+		filters = append(filters, fmt.Sprintf("labels.%s = %q", key, value))
+	}
+
+	project := pointer.StringDeref(lookup.Project, s.scope.Project())
+	var newest *compute.Image
+	err := s.images.List(project).Filter(strings.Join(filters, " AND ")).Pages(context.TODO(), func(images *compute.ImageList) error {
+		for _, image := range images.Items {
+			if image.Deprecated != nil && image.Deprecated.State != "" && image.Deprecated.State != "ACTIVE" {
+				continue
+			}
+			if newest == nil || image.CreationTimestamp > newest.CreationTimestamp {
+				newest = image
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list the images of project %q", project)
+	}
+	if newest == nil {
+		return "", errors.Errorf("no image of project %q matches %s", project, strings.Join(filters, " AND "))
+	}
+
+	return fmt.Sprintf("projects/%s/global/images/%s", project, newest.Name), nil
+}
+
+// renderImageLookup renders the template of a family or of a label value of an image lookup.
+func renderImageLookup(text string, data imageLookupData) (string, error) {
+	t, err := template.New("").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var value bytes.Buffer
+	if err := t.Execute(&value, data); err != nil {
+		return "", err
+	}
+
+	return value.String(), nil
+}
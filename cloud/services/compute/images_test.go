@@ -0,0 +1,116 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestLookupImage(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	machineScope.Machine.Spec.Version = pointer.StringPtr("v1.21.2")
+	machineScope.GCPMachine.Spec.ImageLookup = &infrav1.ImageLookup{
+		Project: pointer.StringPtr("my-images"),
+		Labels:  map[string]string{"os": "ubuntu-2004", "k8s-version": "{{ .KubernetesMinorVersion }}"},
+	}
+
+	_, err := s.rootDiskImage(machineScope)
+	g.Expect(err).To(MatchError(ContainSubstring("no image of project")))
+
+	c.Put("projects/my-images/global/images/capi-ubuntu-2004-v1-21-1", &compute.Image{
+		Name:              "capi-ubuntu-2004-v1-21-1",
+		CreationTimestamp: "2021-06-01T00:00:00.000-07:00",
+		Labels:            map[string]string{"os": "ubuntu-2004", "k8s-version": "v1-21"},
+	})
+	c.Put("projects/my-images/global/images/capi-ubuntu-2004-v1-21-2", &compute.Image{
+		Name:              "capi-ubuntu-2004-v1-21-2",
+		CreationTimestamp: "2021-07-01T00:00:00.000-07:00",
+		Labels:            map[string]string{"os": "ubuntu-2004", "k8s-version": "v1-21"},
+	})
+	c.Put("projects/my-images/global/images/capi-ubuntu-2004-v1-21-3", &compute.Image{
+		Name:              "capi-ubuntu-2004-v1-21-3",
+		CreationTimestamp: "2021-08-01T00:00:00.000-07:00",
+		Labels:            map[string]string{"os": "ubuntu-2004", "k8s-version": "v1-21"},
+		Deprecated:        &compute.DeprecationStatus{State: "DEPRECATED"},
+	})
+	c.Put("projects/my-images/global/images/capi-ubuntu-2004-v1-22-0", &compute.Image{
+		Name:              "capi-ubuntu-2004-v1-22-0",
+		CreationTimestamp: "2021-09-01T00:00:00.000-07:00",
+		Labels:            map[string]string{"os": "ubuntu-2004", "k8s-version": "v1-22"},
+	})
+
+	// The newest image matching the labels is selected, ignoring the deprecated images.
+	image, err := s.rootDiskImage(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(image).To(Equal("projects/my-images/global/images/capi-ubuntu-2004-v1-21-2"))
+
+	// The family, named after the version of the Machine, selects the images with or without the labels.
+	for _, name := range []string{"capi-ubuntu-2004-v1-21-1", "capi-ubuntu-2004-v1-21-2", "capi-ubuntu-2004-v1-22-0"} {
+		var image compute.Image
+		c.Get("projects/my-images/global/images/"+name, &image)
+		image.Family = "capi-ubuntu-2004-k8s-" + strings.Join(strings.Split(name, "-")[3:5], "-")
+		c.Put("projects/my-images/global/images/"+name, &image)
+	}
+	c.Put("projects/my-images/global/images/capi-ubuntu-2004-v1-21-4", &compute.Image{
+		Name:              "capi-ubuntu-2004-v1-21-4",
+		CreationTimestamp: "2021-10-01T00:00:00.000-07:00",
+		Family:            "capi-ubuntu-2004-k8s-v1-21",
+	})
+	machineScope.GCPMachine.Spec.ImageLookup.Family = "capi-ubuntu-2004-k8s-{{ .KubernetesMinorVersion }}"
+	image, err = s.rootDiskImage(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(image).To(Equal("projects/my-images/global/images/capi-ubuntu-2004-v1-21-2"))
+
+	machineScope.GCPMachine.Spec.ImageLookup.Labels = nil
+	image, err = s.rootDiskImage(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(image).To(Equal("projects/my-images/global/images/capi-ubuntu-2004-v1-21-4"))
+
+	// The image family takes precedence over the lookup.
+	machineScope.GCPMachine.Spec.ImageFamily = pointer.StringPtr("projects/my-images/global/images/family/capi")
+	image, err = s.rootDiskImage(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(image).To(Equal("projects/my-images/global/images/family/capi"))
+
+	// The image resolved for the instance is recorded.
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-other-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			ImageLookup:  &infrav1.ImageLookup{Project: pointer.StringPtr("my-images"), Family: "capi-ubuntu-2004-k8s-v1-22"},
+		},
+	}
+	instance := createTestInstance(g, s, gcpMachine)
+	g.Expect(instance.Disks[0].InitializeParams.SourceImage).To(Equal("projects/my-images/global/images/capi-ubuntu-2004-v1-22-0"))
+	g.Expect(gcpMachine.Status.Image).To(Equal("projects/my-images/global/images/capi-ubuntu-2004-v1-22-0"))
+}
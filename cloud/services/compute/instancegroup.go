@@ -25,24 +25,25 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
-	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
 )
 
-// ReconcileInstanceGroups reconciles the instances groups and apply changes if needed.
+// ReconcileInstanceGroups records the API server instance groups of the zones the control plane runs in.
+// The groups are created by the control plane machines, on demand, and deleted once their zone is left.
 func (s *Service) ReconcileInstanceGroups() error {
 	// Get each available zone.
-	zones, err := s.GetZones()
+	zones, err := s.GetControlPlaneZones()
 	if err != nil {
 		return err
 	}
 
 	// Reconcile API Server instance groups and record them.
 	for _, zone := range zones {
-		name := fmt.Sprintf("%s-%s-%s", s.scope.Name(), infrav1.APIServerRoleTagValue, zone)
+		name := s.APIServerInstanceGroupName(zone)
 		group, err := s.instancegroups.Get(s.scope.Project(), zone, name).Do()
 		switch {
 		case gcperrors.IsNotFound(err):
-			continue
+			delete(s.scope.Network().APIServerInstanceGroups, zone)
 		case err != nil:
 			return errors.Wrapf(err, "failed to describe instance group %q", name)
 		default:
@@ -57,18 +58,123 @@ func (s *Service) ReconcileInstanceGroups() error {
 }
 
 // DeleteInstanceGroups deletes a instance group.
+// The groups of all the zones are deleted by name, so that they are cleaned up even if they are not
+// recorded in the status, e.g. after the cluster was moved by clusterctl which doesn't move the status.
 func (s *Service) DeleteInstanceGroups() error {
+	zones, err := s.GetControlPlaneZones()
+	if err != nil {
+		return err
+	}
+
+	groups := make(map[string]string, len(zones))
+	for _, zone := range zones {
+		groups[zone] = s.APIServerInstanceGroupName(zone)
+	}
 	for zone, groupSelfLink := range s.scope.Network().APIServerInstanceGroups {
-		name := path.Base(groupSelfLink)
-		op, err := s.instancegroups.Delete(s.scope.Project(), zone, name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete instance group")
+		groups[zone] = path.Base(groupSelfLink)
+	}
+
+	for zone, name := range groups {
+		if err := s.runDeleteOperation(path.Join("zones", zone, "instanceGroups", name), func() (*compute.Operation, error) {
+			return s.instancegroups.Delete(s.scope.Project(), zone, name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete instance group")
 		}
+		delete(s.scope.Network().APIServerInstanceGroups, zone)
 	}
 
 	return nil
 }
 
+// ReleaseInstanceGroup removes the deleted control plane instance from the API server instance group of the zone.
+// The group of a zone the control plane no longer runs in is removed from the backend service and deleted.
+func (s *Service) ReleaseInstanceGroup(zone string, i *compute.Instance) error {
+	name := s.APIServerInstanceGroupName(zone)
+	group, err := s.instancegroups.Get(s.scope.Project(), zone, name).Do()
+	if gcperrors.IsNotFound(err) {
+		delete(s.scope.Network().APIServerInstanceGroups, zone)
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe instance group %q", name)
+	}
+
+	members, err := s.GetInstanceGroupMembers(zone, name)
+	if err != nil {
+		return err
+	}
+	remaining := 0
+	for _, member := range members {
+		if member.Instance != i.SelfLink {
+			remaining++
+			continue
+		}
+		// The deleted instances are removed from their groups by GCP, unless still being deleted.
+		req := &compute.InstanceGroupsRemoveInstancesRequest{
+			Instances: []*compute.InstanceReference{
+				{
+					Instance: i.SelfLink,
+				},
+			},
+		}
+		if _, err := s.runOperation(path.Join("zones", zone, "instanceGroups", name), "removeInstances", func() (*compute.Operation, error) {
+			return s.instancegroups.RemoveInstances(s.scope.Project(), zone, name, req).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to remove instance from group")
+		}
+	}
+	if remaining > 0 {
+		return nil
+	}
+
+	// A group can't be deleted while used by the backend service.
+	if err := s.removeBackend(group.SelfLink); err != nil {
+		return err
+	}
+
+	if err := s.runDeleteOperation(path.Join("zones", zone, "instanceGroups", name), func() (*compute.Operation, error) {
+		return s.instancegroups.Delete(s.scope.Project(), zone, name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete instance group")
+	}
+	delete(s.scope.Network().APIServerInstanceGroups, zone)
+
+	return nil
+}
+
+// removeBackend removes the group from the backends of the API server backend service, if used.
+func (s *Service) removeBackend(groupSelfLink string) error {
+	backendService, err := s.backendservices.Get(s.scope.Project(), s.apiServerLoadBalancerName()).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe backend service")
+	}
+
+	backends := make([]*compute.Backend, 0, len(backendService.Backends))
+	for _, backend := range backendService.Backends {
+		if backend.Group != groupSelfLink {
+			backends = append(backends, backend)
+		}
+	}
+	if len(backends) == len(backendService.Backends) {
+		return nil
+	}
+	backendService.Backends = backends
+	if _, err := s.runOperation(path.Join("global", "backendServices", backendService.Name), "update", func() (*compute.Operation, error) {
+		return s.backendservices.Update(s.scope.Project(), backendService.Name, backendService).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to update backend service")
+	}
+
+	return nil
+}
+
+// APIServerInstanceGroupName returns the name of the API server instance group in the zone.
+func (s *Service) APIServerInstanceGroupName(zone string) string {
+	return names.Truncate(fmt.Sprintf("%s-%s-%s", s.scope.ResourceNamePrefix(), infrav1.APIServerRoleTagValue, zone))
+}
+
 // GetOrCreateInstanceGroup retrieve an instance group or create it.
 func (s *Service) GetOrCreateInstanceGroup(zone, name string) (*compute.InstanceGroup, error) {
 	group, err := s.instancegroups.Get(s.scope.Project(), zone, name).Do()
@@ -83,11 +189,9 @@ func (s *Service) GetOrCreateInstanceGroup(zone, name string) (*compute.Instance
 				},
 			},
 		}
-		op, err := s.instancegroups.Insert(s.scope.Project(), zone, spec).Do()
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to create instance group")
-		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		if err := s.runInsertOperation(path.Join("zones", zone, "instanceGroups", name), func() (*compute.Operation, error) {
+			return s.instancegroups.Insert(s.scope.Project(), zone, spec).Do()
+		}); err != nil {
 			return nil, errors.Wrapf(err, "failed to create instance group")
 		}
 		group, err = s.instancegroups.Get(s.scope.Project(), zone, name).Do()
@@ -135,12 +239,38 @@ func (s *Service) EnsureInstanceGroupMember(zone, name string, i *compute.Instan
 			},
 		},
 	}
-	op, err := s.instancegroups.AddInstances(s.scope.Project(), zone, name, req).Do()
-	if err != nil {
+	if _, err := s.runOperation(path.Join("zones", zone, "instanceGroups", name), "addInstances", func() (*compute.Operation, error) {
+		return s.instancegroups.AddInstances(s.scope.Project(), zone, name, req).Do()
+	}); err != nil {
 		return errors.Wrapf(err, "failed to add instance to group")
 	}
-	if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
-		return errors.Wrapf(err, "failed to add instance to group")
+
+	return nil
+}
+
+// RemoveInstanceGroupMember removes the instance from the group of the zone, if a member. The group deleted in
+// the meantime is ignored.
+func (s *Service) RemoveInstanceGroupMember(zone, name string, i *compute.Instance) error {
+	members, err := s.GetInstanceGroupMembers(zone, name)
+	switch {
+	case gcperrors.IsNotFound(errors.Cause(err)):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	for _, member := range members {
+		if member.Instance != i.SelfLink {
+			continue
+		}
+		req := &compute.InstanceGroupsRemoveInstancesRequest{
+			Instances: []*compute.InstanceReference{{Instance: i.SelfLink}},
+		}
+		if _, err := s.runOperation(path.Join("zones", zone, "instanceGroups", name), "removeInstances", func() (*compute.Operation, error) {
+			return s.instancegroups.RemoveInstances(s.scope.Project(), zone, name, req).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to remove instance from group %q", name)
+		}
 	}
 
 	return nil
@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReleaseInstanceGroup(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	// The control plane enters zone a with two instances, then zone b with one.
+	instances := map[string]*compute.Instance{}
+	for _, name := range []string{"us-central1-a/my-machine-0", "us-central1-a/my-machine-1", "us-central1-b/my-machine-2"} {
+		zone := path.Dir(name)
+		p := "projects/my-project/zones/" + zone + "/instances/" + path.Base(name)
+		instances[name] = &compute.Instance{Zone: c.SelfLink("projects/my-project/zones/" + zone), SelfLink: c.SelfLink(p)}
+		group, err := s.GetOrCreateInstanceGroup(zone, s.APIServerInstanceGroupName(zone))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(s.EnsureInstanceGroupMember(zone, group.Name, instances[name])).To(Succeed())
+		g.Expect(s.UpdateBackendServices()).To(Succeed())
+	}
+	backendService := &compute.BackendService{}
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.Backends).To(HaveLen(2))
+
+	// The group of zone a is kept while it has instances.
+	g.Expect(s.ReleaseInstanceGroup("us-central1-a", instances["us-central1-a/my-machine-0"])).To(Succeed())
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instanceGroups")).To(HaveLen(1))
+
+	// The group of zone b is removed from the backend service and deleted once the zone is left.
+	g.Expect(s.ReleaseInstanceGroup("us-central1-b", instances["us-central1-b/my-machine-2"])).To(Succeed())
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/instanceGroups")).To(BeEmpty())
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.Backends).To(HaveLen(1))
+	g.Expect(backendService.Backends[0].Group).To(Equal(s.scope.Network().APIServerInstanceGroups["us-central1-a"]))
+	g.Expect(s.scope.Network().APIServerInstanceGroups).To(HaveLen(1))
+
+	// The control plane moves back to zone b.
+	group, err := s.GetOrCreateInstanceGroup("us-central1-b", s.APIServerInstanceGroupName("us-central1-b"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.EnsureInstanceGroupMember("us-central1-b", group.Name, instances["us-central1-b/my-machine-2"])).To(Succeed())
+	g.Expect(s.UpdateBackendServices()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.Backends).To(HaveLen(2))
+}
@@ -17,35 +17,95 @@ limitations under the License.
 package compute
 
 import (
+	"context"
 	"fmt"
+	"hash/fnv"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/blang/semver/v4"
 	"github.com/pkg/errors"
 	"google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/utils/pointer"
 	"sigs.k8s.io/cluster-api/util/record"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
 )
 
 const (
 	defaultDiskSizeGB = 30
+
+	// userDataKey is the metadata key of the bootstrap data of an instance.
+	userDataKey = "user-data"
+
+	// enableGuestAttributesKey is the metadata key enabling the guest attributes of an instance.
+	enableGuestAttributesKey = "enable-guest-attributes"
+
+	// enableOSLoginKey is the metadata key enabling OS Login on an instance.
+	enableOSLoginKey = "enable-oslogin"
+
+	// enableOSConfigKey is the metadata key enabling the OS Config agent of VM Manager on an instance.
+	enableOSConfigKey = "enable-osconfig"
+
+	// sshKeysKey is the metadata key of the SSH keys authorized on an instance, one <user>:<key> per line.
+	sshKeysKey = "ssh-keys"
 )
 
+// hardenedScopes are the scopes of the default compute service account in the hardened security
+// profile, the minimal scopes needed to write logs and metrics and to pull images from GCR.
+var hardenedScopes = []string{
+	"https://www.googleapis.com/auth/logging.write",
+	"https://www.googleapis.com/auth/monitoring.write",
+	compute.DevstorageReadOnlyScope,
+}
+
 // InstanceIfExists returns the existing instance or nothing if it doesn't exist.
+// The instance is looked up in every zone if its zone is unknown, or if it can't
+// be found in the zone of its providerID because it has been moved.
 func (s *Service) InstanceIfExists(scope *scope.MachineScope) (*compute.Instance, error) {
-	log := s.scope.Logger.WithValues("instance-name", scope.Name())
-	log.V(2).Info("Looking for instance by name")
+	log := s.scope.Logger.WithValues("instance-name", scope.InstanceName())
 
-	res, err := s.instances.Get(s.scope.Project(), scope.Zone(), scope.Name()).Do()
-	switch {
-	case gcperrors.IsNotFound(err):
-		return nil, nil
-	case err != nil:
-		return nil, errors.Wrapf(err, "failed to describe instance: %q", scope.Name())
+	if zone := scope.InstanceZone(); zone != "" {
+		log.V(2).Info("Looking for instance by name", "zone", zone)
+		res, err := s.instances.Get(s.scope.Project(), zone, scope.InstanceName()).Do()
+		switch {
+		case err == nil:
+			return res, nil
+		case !gcperrors.IsNotFound(err):
+			return nil, errors.Wrapf(err, "failed to describe instance: %q", scope.InstanceName())
+		case scope.GetProviderID() == "":
+			return nil, nil
+		}
+	}
+
+	log.V(2).Info("Looking for instance by name in all zones")
+	var res *compute.Instance
+	err := s.instances.AggregatedList(s.scope.Project()).
+		Filter(fmt.Sprintf("name = %q", scope.InstanceName())).
+		Pages(context.TODO(), func(list *compute.InstanceAggregatedList) error {
+			for _, scoped := range list.Items {
+				for _, instance := range scoped.Instances {
+					if res == nil && instance.Name == scope.InstanceName() {
+						res = instance
+					}
+				}
+			}
+
+			return nil
+		})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list instances named %q", scope.InstanceName())
+	}
+	if res != nil && path.Base(res.Zone) != scope.InstanceZone() {
+		log.Info("Found instance in another zone", "zone", path.Base(res.Zone))
 	}
 
 	return res, nil
@@ -76,9 +136,18 @@ func (s *Service) CreateInstance(scope *scope.MachineScope) (*compute.Instance,
 	if err != nil {
 		return nil, err
 	}
+	// The image resolved by a lookup is recorded, the newer images published later only apply to the new instances.
+	scope.GCPMachine.Status.Image = sourceImage
+
+	cos := isContainerOptimizedOS(&scope.GCPMachine.Spec, sourceImage)
+	if cos {
+		if err := validateContainerOptimizedOS(&scope.GCPMachine.Spec, bootstrapData); err != nil {
+			return nil, err
+		}
+	}
 
 	input := &compute.Instance{
-		Name:         scope.Name(),
+		Name:         scope.InstanceName(),
 		Zone:         scope.Zone(),
 		MachineType:  fmt.Sprintf("zones/%s/machineTypes/%s", scope.Zone(), scope.GCPMachine.Spec.InstanceType),
 		CanIpForward: true,
@@ -87,9 +156,9 @@ func (s *Service) CreateInstance(scope *scope.MachineScope) (*compute.Instance,
 		}},
 		Tags: &compute.Tags{
 			Items: append(
-				scope.GCPMachine.Spec.AdditionalNetworkTags,
-				fmt.Sprintf("%s-%s", scope.Cluster.Name, scope.Role()),
-				scope.Cluster.Name,
+				scope.AdditionalNetworkTags(),
+				s.roleTag(scope.Role()),
+				names.Truncate(s.scope.Name()),
 			),
 		},
 		Disks: []*compute.AttachedDisk{
@@ -106,7 +175,7 @@ func (s *Service) CreateInstance(scope *scope.MachineScope) (*compute.Instance,
 		Metadata: &compute.Metadata{
 			Items: []*compute.MetadataItems{
 				{
-					Key:   "user-data",
+					Key:   userDataKey,
 					Value: pointer.StringPtr(bootstrapData),
 				},
 			},
@@ -119,40 +188,149 @@ func (s *Service) CreateInstance(scope *scope.MachineScope) (*compute.Instance,
 				},
 			},
 		},
-		Scheduling: &compute.Scheduling{
-			Preemptible: scope.GCPMachine.Spec.Preemptible,
-		},
 	}
+	input.Scheduling = instanceScheduling(scope.GCPMachine.Spec.Preemptible)
 
+	hardened := s.scope.SecurityProfile() == infrav1.SecurityProfileHardened
+
+	metadataKeys := map[string]bool{}
 	for _, m := range scope.GCPMachine.Spec.AdditionalMetadata {
 		input.Metadata.Items = append(input.Metadata.Items, &compute.MetadataItems{
 			Key:   m.Key,
 			Value: m.Value,
 		})
+		metadataKeys[m.Key] = true
 	}
 
-	if scope.GCPMachine.Spec.ServiceAccount != nil {
-		serviceAccount := scope.GCPMachine.Spec.ServiceAccount
-		input.ServiceAccounts = []*compute.ServiceAccount{
-			{
-				Email:  serviceAccount.Email,
-				Scopes: serviceAccount.Scopes,
-			},
+	// Let the bootstrap process report its status through the guest attributes,
+	// unless they have been configured explicitly.
+	if !metadataKeys[enableGuestAttributesKey] {
+		input.Metadata.Items = append(input.Metadata.Items, &compute.MetadataItems{
+			Key:   enableGuestAttributesKey,
+			Value: pointer.StringPtr("TRUE"),
+		})
+	}
+
+	// The break-glass SSH key is authorized along with the keys of the additional metadata.
+	if key := s.scope.BreakGlassSSHKey(); key != "" {
+		appendMetadataItem(input.Metadata, &compute.MetadataItems{Key: sshKeysKey, Value: pointer.StringPtr(key)})
+	}
+
+	if shielded := scope.GCPMachine.Spec.ShieldedInstanceConfig; shielded != nil {
+		vtpm := pointer.BoolDeref(shielded.VirtualizedTrustedPlatformModule, true)
+		input.ShieldedInstanceConfig = &compute.ShieldedInstanceConfig{
+			EnableSecureBoot:          pointer.BoolDeref(shielded.SecureBoot, hardened),
+			EnableVtpm:                vtpm,
+			EnableIntegrityMonitoring: pointer.BoolDeref(shielded.IntegrityMonitoring, vtpm),
+		}
+	} else if hardened {
+		input.ShieldedInstanceConfig = &compute.ShieldedInstanceConfig{
+			EnableSecureBoot:          true,
+			EnableVtpm:                true,
+			EnableIntegrityMonitoring: true,
 		}
 	}
 
-	input.Labels = infrav1.Build(infrav1.BuildParams{
-		ClusterName: s.scope.Name(),
-		Lifecycle:   infrav1.ResourceLifecycleOwned,
-		Role:        pointer.StringPtr(scope.Role()),
-		// TODO(vincepri): Check what needs to be added for the cloud provider label.
-		Additional: s.scope.
-			GCPCluster.Spec.
-			AdditionalLabels.
-			AddLabels(scope.GCPMachine.Spec.AdditionalLabels),
-	})
+	// A Confidential VM can't be live migrated.
+	if scope.GCPMachine.Spec.ConfidentialCompute {
+		input.ConfidentialInstanceConfig = &compute.ConfidentialInstanceConfig{EnableConfidentialCompute: true}
+		input.Scheduling.OnHostMaintenance = "TERMINATE"
+	}
 
-	if scope.GCPMachine.Spec.PublicIP != nil && *scope.GCPMachine.Spec.PublicIP {
+	if hardened {
+		input.ServiceAccounts[0].Scopes = hardenedScopes
+		if !metadataKeys[enableOSLoginKey] {
+			input.Metadata.Items = append(input.Metadata.Items, &compute.MetadataItems{
+				Key:   enableOSLoginKey,
+				Value: pointer.StringPtr("TRUE"),
+			})
+		}
+	}
+
+	if accelerators := scope.GCPMachine.Spec.GuestAccelerators; len(accelerators) > 0 {
+		input.GuestAccelerators = guestAccelerators(scope.Zone(), accelerators)
+		// The instances with accelerators can't be live migrated.
+		input.Scheduling.OnHostMaintenance = "TERMINATE"
+
+		if scope.GCPMachine.Spec.InstallGPUDriver && !metadataKeys[startupScriptKey] && !metadataKeys[installNvidiaDriverKey] {
+			appendMetadataItem(input.Metadata, gpuDriverMetadata(sourceImage, cos))
+		}
+	}
+
+	if serviceAccount := scope.ServiceAccount(); serviceAccount != nil {
+		input.ServiceAccounts = []*compute.ServiceAccount{instanceServiceAccount(serviceAccount)}
+	}
+
+	// The OS Config agent reports the inventory through the guest attributes and needs the
+	// cloud-platform scope to reach the OS Config API.
+	if scope.GCPMachine.Spec.EnableOSConfig {
+		if !metadataKeys[enableOSConfigKey] {
+			input.Metadata.Items = append(input.Metadata.Items, &compute.MetadataItems{
+				Key:   enableOSConfigKey,
+				Value: pointer.StringPtr("TRUE"),
+			})
+		}
+		ensureScopes(input.ServiceAccounts[0], compute.CloudPlatformScope)
+	}
+
+	var unavailable []string
+	switch {
+	case !scope.GCPMachine.Spec.InstallOpsAgent:
+	case !cos && !s.scope.GoogleAccess().Public():
+		// The installation script downloads the agent from the package repositories, out of the VIP.
+		unavailable = append(unavailable, fmt.Sprintf("installOpsAgent: the Ops Agent installation requires a public egress of the instance, unavailable with the %s Google access", s.scope.GoogleAccess()))
+	default:
+		for _, item := range opsAgentMetadata(cos) {
+			if !metadataKeys[item.Key] {
+				appendMetadataItem(input.Metadata, item)
+			}
+		}
+		ensureScopes(input.ServiceAccounts[0], opsAgentScopes...)
+	}
+	scope.SetFeaturesAvailable(s.scope.GoogleAccess(), unavailable)
+
+	if cos {
+		for _, item := range cosMetadata() {
+			if !metadataKeys[item.Key] {
+				appendMetadataItem(input.Metadata, item)
+			}
+		}
+	}
+
+	if reservation := scope.GCPMachine.Spec.Reservation; reservation != nil {
+		input.ReservationAffinity = &compute.ReservationAffinity{
+			ConsumeReservationType: "SPECIFIC_RESERVATION",
+			Key:                    reservationNameKey,
+			Values:                 []string{s.ReservationName(*reservation)},
+		}
+	}
+
+	if nodeGroup := scope.GCPMachine.Spec.SoleTenantNodeGroup; nodeGroup != nil {
+		input.Scheduling.NodeAffinities = []*compute.SchedulingNodeAffinity{{
+			Key:      nodeGroupNameKey,
+			Operator: "IN",
+			Values:   []string{s.SoleTenantNodeGroupName(*nodeGroup)},
+		}}
+	}
+	input.Scheduling.NodeAffinities = append(input.Scheduling.NodeAffinities, schedulingNodeAffinities(scope.GCPMachine.Spec.NodeAffinities)...)
+
+	// The webhook rejects the host maintenance and restart policies the instance doesn't support.
+	if policy := scope.GCPMachine.Spec.OnHostMaintenance; policy != nil {
+		input.Scheduling.OnHostMaintenance = strings.ToUpper(string(*policy))
+	}
+	if restart := scope.GCPMachine.Spec.AutomaticRestart; restart != nil {
+		input.Scheduling.AutomaticRestart = pointer.BoolPtr(*restart)
+	}
+	input.MinCpuPlatform = pointer.StringDeref(scope.GCPMachine.Spec.MinCPUPlatform, "")
+
+	input.Labels = s.instanceLabels(scope)
+
+	if s.scope.GCPCluster.Spec.IAPAccess {
+		input.Tags.Items = append(input.Tags.Items, s.iapTag())
+	}
+
+	// A private instance egresses through the Cloud NAT of the network.
+	if scope.PublicIP() {
 		input.NetworkInterfaces[0].AccessConfigs = []*compute.AccessConfig{
 			{
 				Type: "ONE_TO_ONE_NAT",
@@ -164,17 +342,26 @@ func (s *Service) CreateInstance(scope *scope.MachineScope) (*compute.Instance,
 	if scope.GCPMachine.Spec.RootDeviceSize > 0 {
 		input.Disks[0].InitializeParams.DiskSizeGb = scope.GCPMachine.Spec.RootDeviceSize
 	}
+	if err := s.reuseRootDisk(scope, input.Disks[0]); err != nil {
+		return nil, err
+	}
 	for _, d := range scope.GCPMachine.Spec.AdditionalDisks {
 		ad := &compute.AttachedDisk{
-			AutoDelete: true,
+			AutoDelete: pointer.BoolDeref(d.AutoDelete, true),
+			DeviceName: pointer.StringDeref(d.DeviceName, ""),
 			InitializeParams: &compute.AttachedDiskInitializeParams{
 				DiskSizeGb: pointer.Int64PtrDerefOr(d.Size, defaultDiskSizeGB),
 				DiskType:   diskTypeURL(scope.Zone(), d.DeviceType),
 			},
 		}
+		if d.Interface != nil {
+			ad.Interface = string(*d.Interface)
+		}
 
-		if ad.InitializeParams.DiskType == string(infrav1.LocalSsdDiskType) {
+		if diskTypePtrDerefOrDefault(d.DeviceType) == infrav1.LocalSsdDiskType {
 			ad.Type = "SCRATCH" // Default is PERSISTENT.
+			// Local SSDs can't outlive the instance.
+			ad.AutoDelete = true
 
 			// Override the Disk size
 			ad.InitializeParams.DiskSizeGb = 375
@@ -183,57 +370,775 @@ func (s *Service) CreateInstance(scope *scope.MachineScope) (*compute.Instance,
 			// Most OS images would work with both NVME and SCSI disks but some may work
 			// considerably faster with NVME.
 			// https://cloud.google.com/compute/docs/disks/local-ssd#choose_an_interface
-			ad.Interface = "NVME"
+			if d.Interface == nil {
+				ad.Interface = string(infrav1.NVMEDiskInterface)
+			}
 		}
 
 		input.Disks = append(input.Disks, ad)
 	}
+	if etcd := scope.GCPMachine.Spec.EtcdDisk; etcd != nil && scope.IsControlPlane() {
+		input.Disks = append(input.Disks, etcdDisk(scope.Zone(), input.Name, etcd))
+	}
 
-	if scope.GCPMachine.Spec.Subnet != nil {
+	// The etcd disk keeps its own key, if any.
+	encryptDisks(input.Disks, scope.GCPMachine.Spec.DiskEncryption)
+
+	// Label the persistent disks as owned by the cluster, so that the ones left behind can be garbage collected.
+	for _, d := range input.Disks {
+		if d.Type != "SCRATCH" && d.InitializeParams != nil {
+			d.InitializeParams.Labels = input.Labels
+		}
+	}
+
+	if subnet := scope.Subnet(); subnet != nil {
 		input.NetworkInterfaces[0].Subnetwork = fmt.Sprintf("regions/%s/subnetworks/%s",
-			scope.Region(), *scope.GCPMachine.Spec.Subnet)
+			scope.Region(), *subnet)
+		s.setNetworkInterfaceStack(input.NetworkInterfaces[0], scope.Region(), *subnet)
 	}
 
-	if s.scope.Network().APIServerAddress == nil {
+	if s.scope.Network().APIServerAddress == nil && s.scope.LoadBalancerType() != infrav1.LoadBalancerTypeNone {
 		return nil, errors.New("failed to run controlplane, APIServer address not available")
 	}
 
+	// The bootstrap data exceeding the size limit of a metadata value is fetched from Cloud Storage at boot.
+	if scope.GCPMachine.Spec.BootstrapDataBucket != nil {
+		shim, err := s.uploadBootstrapData(scope, bootstrapData)
+		if err != nil {
+			return nil, err
+		}
+		input.Metadata.Items[0].Value = pointer.StringPtr(shim)
+		ensureScopes(input.ServiceAccounts[0], compute.DevstorageReadOnlyScope)
+	}
+
 	log.Info("Running instance")
-	out, err := s.runInstance(input)
+	out, op, err := s.runInstance(scope, input)
+	for err != nil {
+		// Comply with the org policy denying the instance when it's only a matter of enabling a feature.
+		constraint := gcperrors.ViolatedConstraint(err)
+		if constraint == "" || !complyWithConstraint(input, constraint) {
+			break
+		}
+		log.Info("Running instance again to comply with the org policy", "constraint", constraint)
+		record.Eventf(scope.Machine, "OrgPolicyCompliance", "Creating instance %q again to comply with the org policy constraint %s", input.Name, constraint)
+		out, op, err = s.runInstance(scope, input)
+	}
+	if wait.IsTimeout(err) {
+		log.Info("Waiting for the instance creation", "operation", scope.GCPMachine.Status.Operation)
+
+		return nil, err
+	}
 	if err != nil {
 		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance: %v", err)
 
 		return nil, err
 	}
 
-	record.Eventf(scope.Machine, "SuccessfulCreate", "Created new %s instance with name %q", scope.Role(), out.Name)
+	record.Eventf(scope.Machine, "SuccessfulCreate", "Created new %s instance with name %q%s", scope.Role(), out.Name, operationDetails(op))
+	setManagedMetadataKeys(scope)
 
 	return out, nil
 }
 
-func (s *Service) runInstance(input *compute.Instance) (*compute.Instance, error) {
+// reuseRootDisk sets whether the boot disk is deleted with the instance and names it. An existing disk
+// with the name is attached instead of creating the boot disk from the image.
+func (s *Service) reuseRootDisk(scope *scope.MachineScope, bootDisk *compute.AttachedDisk) error {
+	bootDisk.AutoDelete = pointer.BoolDeref(scope.GCPMachine.Spec.RootDeviceAutoDelete, true)
+	if scope.GCPMachine.Spec.RootDeviceName == nil {
+		return nil
+	}
+
+	name := *scope.GCPMachine.Spec.RootDeviceName
+	disk, err := s.disks.Get(s.scope.Project(), scope.Zone(), name).Do()
+	if gcperrors.IsNotFound(err) {
+		bootDisk.InitializeParams.DiskName = name
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe disk %q", name)
+	}
+	if len(disk.Users) > 0 {
+		return errors.Errorf("failed to attach disk %q, it is in use by %s", name, strings.Join(disk.Users, ", "))
+	}
+
+	bootDisk.Source = disk.SelfLink
+	bootDisk.InitializeParams = nil
+
+	return nil
+}
+
+// etcdDisk returns the dedicated etcd disk of the control plane instance, deleted with it.
+func etcdDisk(zone, instanceName string, spec *infrav1.EtcdDisk) *compute.AttachedDisk {
+	diskType := infrav1.PdSsdDiskType
+	if spec.DeviceType != nil {
+		diskType = *spec.DeviceType
+	}
+	res := &compute.AttachedDisk{
+		AutoDelete: true,
+		DeviceName: infrav1.EtcdDiskDeviceName,
+		InitializeParams: &compute.AttachedDiskInitializeParams{
+			DiskName:   names.Truncate(instanceName + "-" + infrav1.EtcdDiskDeviceName),
+			DiskSizeGb: pointer.Int64PtrDerefOr(spec.Size, defaultDiskSizeGB),
+			DiskType:   diskTypeURL(zone, &diskType),
+		},
+	}
+	if spec.KMSKeyName != nil {
+		res.DiskEncryptionKey = &compute.CustomerEncryptionKey{KmsKeyName: *spec.KMSKeyName}
+	}
+
+	return res
+}
+
+// instanceScheduling returns the scheduling of the instance. A preemptible instance can't be live migrated nor
+// restarted by GCE.
+func instanceScheduling(preemptible bool) *compute.Scheduling {
+	scheduling := &compute.Scheduling{Preemptible: preemptible}
+	if scheduling.Preemptible {
+		scheduling.OnHostMaintenance = "TERMINATE"
+		scheduling.AutomaticRestart = pointer.BoolPtr(false)
+	}
+
+	return scheduling
+}
+
+// encryptDisks encrypts the persistent disks created with the instance with the customer-managed key, if any,
+// unless they set their own key.
+func encryptDisks(disks []*compute.AttachedDisk, encryption *infrav1.DiskEncryption) {
+	if encryption == nil {
+		return
+	}
+	for _, d := range disks {
+		if d.Type != "SCRATCH" && d.InitializeParams != nil && d.DiskEncryptionKey == nil {
+			d.DiskEncryptionKey = diskEncryptionKey(encryption)
+		}
+	}
+}
+
+// diskEncryptionKey returns the customer-managed encryption key of a disk.
+func diskEncryptionKey(spec *infrav1.DiskEncryption) *compute.CustomerEncryptionKey {
+	return &compute.CustomerEncryptionKey{
+		KmsKeyName:           spec.KMSKeyName,
+		KmsKeyServiceAccount: pointer.StringDeref(spec.KMSKeyServiceAccount, ""),
+	}
+}
+
+// instanceLabels returns the labels of the instance and its persistent disks.
+func (s *Service) instanceLabels(scope *scope.MachineScope) infrav1.Labels {
+	return infrav1.Build(infrav1.BuildParams{
+		ClusterName:      s.scope.Name(),
+		ClusterNamespace: s.scope.Namespace(),
+		ClusterUID:       string(s.scope.Cluster.UID),
+		Lifecycle:        infrav1.ResourceLifecycleOwned,
+		Role:             pointer.StringPtr(scope.Role()),
+		// TODO(vincepri): Check what needs to be added for the cloud provider label.
+		Additional: infrav1.Labels{}.
+			AddLabels(s.scope.GCPCluster.Spec.AdditionalLabels).
+			AddLabels(scope.AdditionalLabels()),
+	})
+}
+
+// ReconcileInstanceLabels adds or restores the labels of the instance and of the persistent disks created
+// with it, e.g. after the additional labels of the GCPCluster or the GCPMachine have been changed. The disks
+// are only read once the labels changed since they were last set, as recorded in the status of the GCPMachine,
+// or the ones of the instance drifted.
+func (s *Service) ReconcileInstanceLabels(scope *scope.MachineScope, instance *compute.Instance) error {
+	spec := s.instanceLabels(scope)
+	hash := labelsHash(spec)
+	zone := path.Base(instance.Zone)
+	labels, drifted := mergeLabels(instance.Labels, spec)
+	if !drifted && scope.GCPMachine.Status.LabelsHash == hash {
+		return nil
+	}
+	if drifted {
+		req := &compute.InstancesSetLabelsRequest{Labels: labels, LabelFingerprint: instance.LabelFingerprint}
+		op, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+			return s.instances.SetLabels(s.scope.Project(), zone, instance.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set instance labels")
+		}
+		record.Eventf(scope.GCPMachine, "DriftCorrected", "Restored labels of instance %q%s", instance.Name, operationDetails(op))
+	}
+
+	ownershipKey := infrav1.ClusterTagKey(s.scope.Name())
+	for _, d := range instance.Disks {
+		if d.Type == "SCRATCH" || d.Source == "" {
+			continue
+		}
+		disk, err := s.disks.Get(s.scope.Project(), zone, path.Base(d.Source)).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe disk %q", path.Base(d.Source))
+		}
+		// Leave the disks attached out-of-band alone.
+		if disk.Labels[ownershipKey] != string(infrav1.ResourceLifecycleOwned) {
+			continue
+		}
+		labels, drifted := mergeLabels(disk.Labels, spec)
+		if !drifted {
+			continue
+		}
+		req := &compute.ZoneSetLabelsRequest{Labels: labels, LabelFingerprint: disk.LabelFingerprint}
+		op, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+			return s.disks.SetLabels(s.scope.Project(), zone, disk.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set disk labels")
+		}
+		record.Eventf(scope.GCPMachine, "DriftCorrected", "Restored labels of disk %q%s", disk.Name, operationDetails(op))
+	}
+	scope.GCPMachine.Status.LabelsHash = hash
+
+	return nil
+}
+
+// labelsHash returns the hash of the labels.
+func labelsHash(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	h := fnv.New64a()
+	for _, k := range keys {
+		_, _ = h.Write([]byte(k + "=" + labels[k] + "\n"))
+	}
+
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// ReconcileInstanceTags sets the network tags of the instance to the additional network tags of its GCPMachine
+// and the tags of the cluster and of the role of the instance, e.g. once the additional network tags have been
+// changed or the IAP access of the GCPCluster has been enabled or disabled. The tags of an existing instance
+// adopted by its GCPMachine are kept, the missing ones being added.
+func (s *Service) ReconcileInstanceTags(scope *scope.MachineScope, instance *compute.Instance) error {
+	tags := &compute.Tags{}
+	if instance.Tags != nil {
+		tags.Items = instance.Tags.Items
+		tags.Fingerprint = instance.Tags.Fingerprint
+	}
+
+	required := append(append([]string{}, scope.AdditionalNetworkTags()...), s.roleTag(scope.Role()), names.Truncate(s.scope.Name()))
+	iapTag, changed := s.iapTag(), false
+	if s.scope.GCPCluster.Spec.IAPAccess {
+		required = append(required, iapTag)
+	}
+	adopted := scope.GCPMachine.Spec.ExistingInstance != nil
+	items := make([]string, 0, len(tags.Items)+len(required))
+	for _, tag := range tags.Items {
+		if (tag == iapTag && !s.scope.GCPCluster.Spec.IAPAccess) || (!adopted && !sets.NewString(required...).Has(tag)) {
+			changed = true
+			continue
+		}
+		items = append(items, tag)
+	}
+	for _, tag := range required {
+		if !sets.NewString(items...).Has(tag) {
+			items = append(items, tag)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	tags.Items = items
+
+	op, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+		return s.instances.SetTags(s.scope.Project(), path.Base(instance.Zone), instance.Name, tags).Do()
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to set instance tags")
+	}
+	record.Eventf(scope.GCPMachine, "UpdatedTags", "Updated network tags of instance %q%s", instance.Name, operationDetails(op))
+
+	return nil
+}
+
+// ReconcileRootDiskSize resizes the boot disk of the instance once the RootDeviceSize of the GCPMachine has been
+// increased. The partition and the filesystem are grown by the guest, which the event reminds of.
+func (s *Service) ReconcileRootDiskSize(scope *scope.MachineScope, instance *compute.Instance) error {
+	size := scope.GCPMachine.Spec.RootDeviceSize
+	if size == 0 {
+		return nil
+	}
+
+	zone := path.Base(instance.Zone)
+	for _, d := range instance.Disks {
+		if !d.Boot || d.Source == "" {
+			continue
+		}
+		disk, err := s.disks.Get(s.scope.Project(), zone, path.Base(d.Source)).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe disk %q", path.Base(d.Source))
+		}
+		if disk.SizeGb >= size {
+			return nil
+		}
+		op, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+			return s.disks.Resize(s.scope.Project(), zone, disk.Name, &compute.DisksResizeRequest{SizeGb: size}).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to resize disk %q", disk.Name)
+		}
+		record.Eventf(scope.GCPMachine, "ResizedRootDisk", "Resized root disk %q of instance %q from %d GB to %d GB%s, "+
+			"its partition and filesystem are grown on the next boot by images running growpart, or with growpart and resize2fs or xfs_growfs",
+			disk.Name, instance.Name, disk.SizeGb, size, operationDetails(op))
+	}
+
+	return nil
+}
+
+// ReconcileInstanceType changes the machine type of the instance once the InstanceType of the GCPMachine has been
+// changed. A running instance is stopped for the change and started again, in the maintenance window of the cluster
+// if any, an instance in transition is changed once it's running or terminated. The instance stopped for the change
+// is recorded with the PendingRestartAnnotation until it's started, so that it's restarted by the next reconciles
+// if left stopped, and refreshed once started.
+func (s *Service) ReconcileInstanceType(scope *scope.MachineScope, instance *compute.Instance) error {
+	machineType := scope.GCPMachine.Spec.InstanceType
+	changed := machineType != "" && path.Base(instance.MachineType) != machineType
+	_, restart := scope.GCPMachine.Annotations[infrav1.PendingRestartAnnotation]
+	if !changed && !restart {
+		return nil
+	}
+
+	zone := path.Base(instance.Zone)
+	switch infrav1.InstanceStatus(instance.Status) {
+	case infrav1.InstanceStatusRunning:
+		// The instance was started again by a previous reconcile or out of band.
+		if !changed {
+			delete(scope.GCPMachine.Annotations, infrav1.PendingRestartAnnotation)
+			return nil
+		}
+		if s.deferDisruptiveChange("instance", instance.Name, fmt.Sprintf("machine type is %s instead of %s", path.Base(instance.MachineType), machineType)) {
+			return nil
+		}
+		// The instance is restarted by the next reconciles if it's left stopped, e.g. the start fails.
+		scope.SetAnnotation(infrav1.PendingRestartAnnotation, "")
+		restart = true
+		if _, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+			return s.instances.Stop(s.scope.Project(), zone, instance.Name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to stop instance")
+		}
+	case infrav1.InstanceStatusTerminated:
+	default:
+		return nil
+	}
+
+	if changed {
+		req := &compute.InstancesSetMachineTypeRequest{MachineType: fmt.Sprintf("zones/%s/machineTypes/%s", zone, machineType)}
+		op, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+			return s.instances.SetMachineType(s.scope.Project(), zone, instance.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set instance machine type")
+		}
+		record.Eventf(scope.GCPMachine, "UpdatedMachineType", "Changed machine type of instance %q from %s to %s%s",
+			instance.Name, path.Base(instance.MachineType), machineType, operationDetails(op))
+	}
+
+	if !restart {
+		return nil
+	}
+	if _, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+		return s.instances.Start(s.scope.Project(), zone, instance.Name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to start instance")
+	}
+	delete(scope.GCPMachine.Annotations, infrav1.PendingRestartAnnotation)
+
+	// The instance is refreshed so that it's reconciled as running rather than terminated.
+	started, err := s.instances.Get(s.scope.Project(), zone, instance.Name).Do()
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe instance")
+	}
+	*instance = *started
+
+	return nil
+}
+
+// ReconcileInstanceMetadata sets the AdditionalMetadata of the GCPMachine on the instance once it has been changed,
+// and removes the keys removed from it since they were set, as recorded by the ManagedMetadataAnnotation. The
+// startup-script and ssh-keys, merged with the ones of the provider, are left as is, as are the removed keys the
+// provider sets by default, e.g. enable-guest-attributes, which would otherwise lose their default.
+func (s *Service) ReconcileInstanceMetadata(scope *scope.MachineScope, instance *compute.Instance) error {
+	metadata := &compute.Metadata{}
+	if instance.Metadata != nil {
+		metadata.Items = append(metadata.Items, instance.Metadata.Items...)
+		metadata.Fingerprint = instance.Metadata.Fingerprint
+	}
+
+	var changed []string
+	spec := sets.NewString()
+	for _, m := range scope.GCPMachine.Spec.AdditionalMetadata {
+		spec.Insert(m.Key)
+	}
+	for _, key := range strings.Split(scope.GCPMachine.Annotations[infrav1.ManagedMetadataAnnotation], ",") {
+		if key == "" || spec.Has(key) || providerMetadataKeys.Has(key) {
+			continue
+		}
+		for i, item := range metadata.Items {
+			if item.Key == key {
+				metadata.Items = append(metadata.Items[:i:i], metadata.Items[i+1:]...)
+				changed = append(changed, key)
+				break
+			}
+		}
+	}
+	for _, m := range scope.GCPMachine.Spec.AdditionalMetadata {
+		if m.Key == startupScriptKey || m.Key == sshKeysKey {
+			continue
+		}
+		found := false
+		for i, item := range metadata.Items {
+			if item.Key != m.Key {
+				continue
+			}
+			found = true
+			if pointer.StringDeref(item.Value, "") != pointer.StringDeref(m.Value, "") {
+				metadata.Items[i] = &compute.MetadataItems{Key: m.Key, Value: m.Value}
+				changed = append(changed, m.Key)
+			}
+			break
+		}
+		if !found {
+			metadata.Items = append(metadata.Items, &compute.MetadataItems{Key: m.Key, Value: m.Value})
+			changed = append(changed, m.Key)
+		}
+	}
+	if len(changed) == 0 {
+		setManagedMetadataKeys(scope)
+		return nil
+	}
+
+	op, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+		return s.instances.SetMetadata(s.scope.Project(), path.Base(instance.Zone), instance.Name, metadata).Do()
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to set instance metadata")
+	}
+	record.Eventf(scope.GCPMachine, "UpdatedMetadata", "Updated metadata %s of instance %q%s", strings.Join(changed, ", "), instance.Name, operationDetails(op))
+	setManagedMetadataKeys(scope)
+
+	return nil
+}
+
+// providerMetadataKeys are the metadata keys the provider sets on the instances, unless set by the
+// AdditionalMetadata.
+var providerMetadataKeys = sets.NewString(userDataKey, startupScriptKey, sshKeysKey, enableGuestAttributesKey, enableOSLoginKey,
+	enableOSConfigKey, installNvidiaDriverKey, cosUpdateStrategyKey, cosLoggingEnabledKey, cosMonitoringEnabledKey)
+
+// setManagedMetadataKeys records the keys of the AdditionalMetadata of the GCPMachine set on its instance in the
+// ManagedMetadataAnnotation.
+func setManagedMetadataKeys(scope *scope.MachineScope) {
+	keys := sets.NewString()
+	for _, m := range scope.GCPMachine.Spec.AdditionalMetadata {
+		keys.Insert(m.Key)
+	}
+	if keys.Len() == 0 {
+		delete(scope.GCPMachine.Annotations, infrav1.ManagedMetadataAnnotation)
+		return
+	}
+	if scope.GCPMachine.Annotations == nil {
+		scope.GCPMachine.Annotations = map[string]string{}
+	}
+	scope.GCPMachine.Annotations[infrav1.ManagedMetadataAnnotation] = strings.Join(keys.List(), ",")
+}
+
+// InstanceImmutableDrift returns why the instance differs from its GCPMachine in a way which can't be updated in
+// place, e.g. after the GCPMachine has been changed without its webhook, empty if it doesn't. The existing instances
+// adopted by their GCPMachine aren't compared.
+func (s *Service) InstanceImmutableDrift(scope *scope.MachineScope, instance *compute.Instance) string {
+	spec := &scope.GCPMachine.Spec
+	if spec.ExistingInstance != nil {
+		return ""
+	}
+
+	preemptible := spec.Preemptible
+	confidential := instance.ConfidentialInstanceConfig != nil && instance.ConfidentialInstanceConfig.EnableConfidentialCompute
+	switch {
+	case instance.Scheduling != nil && instance.Scheduling.Preemptible != preemptible:
+		return fmt.Sprintf("preemptible is %t instead of %t", instance.Scheduling.Preemptible, preemptible)
+	case confidential != spec.ConfidentialCompute:
+		return fmt.Sprintf("confidential compute is %t instead of %t", confidential, spec.ConfidentialCompute)
+	case len(instance.GuestAccelerators) != len(spec.GuestAccelerators):
+		return fmt.Sprintf("%d guest accelerator types instead of %d", len(instance.GuestAccelerators), len(spec.GuestAccelerators))
+	}
+
+	return ""
+}
+
+// appendMetadataItem appends the item to the metadata. The startup scripts are run one after the other
+// as the metadata has a single startup-script, and the SSH keys are appended to the lines of the ssh-keys.
+func appendMetadataItem(metadata *compute.Metadata, item *compute.MetadataItems) {
+	switch item.Key {
+	case startupScriptKey:
+		for _, m := range metadata.Items {
+			if m.Key == startupScriptKey {
+				m.Value = pointer.StringPtr(pointer.StringDeref(m.Value, "") + pointer.StringDeref(item.Value, ""))
+				return
+			}
+		}
+	case sshKeysKey:
+		for _, m := range metadata.Items {
+			if m.Key == sshKeysKey {
+				m.Value = pointer.StringPtr(strings.TrimRight(pointer.StringDeref(m.Value, ""), "\n") + "\n" + pointer.StringDeref(item.Value, ""))
+				return
+			}
+		}
+	}
+	metadata.Items = append(metadata.Items, item)
+}
+
+// instanceServiceAccount returns the service account the instances run as, its email defaulting to the default
+// compute service account and its scopes to the cloud-platform scope when the webhooks haven't defaulted them.
+func instanceServiceAccount(serviceAccount *infrav1.ServiceAccount) *compute.ServiceAccount {
+	res := &compute.ServiceAccount{
+		Email:  serviceAccount.Email,
+		Scopes: append([]string{}, serviceAccount.Scopes...),
+	}
+	if res.Email == "" {
+		res.Email = infrav1.DefaultServiceAccountEmail
+	}
+	if len(res.Scopes) == 0 {
+		res.Scopes = []string{compute.CloudPlatformScope}
+	}
+
+	return res
+}
+
+// ensureScopes adds the scopes missing from the service account, unless it has the cloud-platform scope.
+func ensureScopes(serviceAccount *compute.ServiceAccount, scopes ...string) {
+	existing := sets.NewString(serviceAccount.Scopes...)
+	if existing.Has(compute.CloudPlatformScope) {
+		return
+	}
+	// The scopes may be shared with the GCPMachine or the hardened scopes.
+	res := append([]string{}, serviceAccount.Scopes...)
+	for _, scope := range scopes {
+		if !existing.Has(scope) {
+			res = append(res, scope)
+		}
+	}
+	serviceAccount.Scopes = res
+}
+
+// complyWithConstraint adjusts the instance to comply with the org policy constraint, if possible,
+// and returns false if it can't be adjusted or already complies.
+func complyWithConstraint(input *compute.Instance, constraint string) bool {
+	switch constraint {
+	case gcperrors.RequireShieldedVMConstraint:
+		shielded := &compute.ShieldedInstanceConfig{
+			EnableSecureBoot:          true,
+			EnableVtpm:                true,
+			EnableIntegrityMonitoring: true,
+		}
+		if reflect.DeepEqual(input.ShieldedInstanceConfig, shielded) {
+			return false
+		}
+		input.ShieldedInstanceConfig = shielded
+
+		return true
+	case gcperrors.RequireOSLoginConstraint:
+		for _, m := range input.Metadata.Items {
+			if m.Key == enableOSLoginKey {
+				if strings.EqualFold(pointer.StringDeref(m.Value, ""), "TRUE") {
+					return false
+				}
+				m.Value = pointer.StringPtr("TRUE")
+
+				return true
+			}
+		}
+		input.Metadata.Items = append(input.Metadata.Items, &compute.MetadataItems{
+			Key:   enableOSLoginKey,
+			Value: pointer.StringPtr("TRUE"),
+		})
+
+		return true
+	default:
+		// There is no alternative to an external IP or an untrusted image the instance has been configured with.
+		return false
+	}
+}
+
+// runInstance inserts the instance and returns it along with the insert operation. The insert in progress is
+// recorded in the status of the GCPMachine, and a TimeoutError returned, instead of being waited for.
+func (s *Service) runInstance(scope *scope.MachineScope, input *compute.Instance) (*compute.Instance, *compute.Operation, error) {
 	op, err := s.instances.Insert(s.scope.Project(), input.Zone, input).Do()
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to create gcp instance")
+		return nil, nil, errors.Wrap(err, "failed to create gcp instance")
+	}
+
+	if err := wait.PollComputeOperation(op); err != nil {
+		if wait.IsTimeout(err) {
+			scope.GCPMachine.Status.Operation = op.SelfLink
+		}
+		return nil, nil, errors.Wrap(err, "failed to create gcp instance")
+	}
+
+	instance, err := s.instances.Get(s.scope.Project(), input.Zone, input.Name).Do()
+
+	return instance, op, err
+}
+
+// RetainDetachedDisks labels the disks of the instance which aren't deleted with it, e.g. the root volume kept with
+// RootDeviceAutoDelete, as retained, so that they aren't deleted as orphans once detached. The disks attached
+// out-of-band are left alone.
+func (s *Service) RetainDetachedDisks(scope *scope.MachineScope, instance *compute.Instance) error {
+	ownershipKey := infrav1.ClusterTagKey(s.scope.Name())
+	zone := path.Base(instance.Zone)
+	for _, d := range instance.Disks {
+		if d.AutoDelete || d.Type == "SCRATCH" || d.Source == "" {
+			continue
+		}
+		disk, err := s.disks.Get(s.scope.Project(), zone, path.Base(d.Source)).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe disk %q", path.Base(d.Source))
+		}
+		if disk.Labels[ownershipKey] != string(infrav1.ResourceLifecycleOwned) {
+			continue
+		}
+		labels, retained := mergeLabels(disk.Labels, map[string]string{infrav1.NameGCPRetained: "true"})
+		if !retained {
+			continue
+		}
+		req := &compute.ZoneSetLabelsRequest{Labels: labels, LabelFingerprint: disk.LabelFingerprint}
+		if _, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+			return s.disks.SetLabels(s.scope.Project(), zone, disk.Name, req).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to set disk labels")
+		}
+		record.Eventf(scope.GCPMachine, "RetainedDisk", "Retained disk %q of instance %q", disk.Name, instance.Name)
+	}
+
+	return nil
+}
+
+// TerminateInstance deletes the instance of the GCPMachine. The delete in progress is recorded in the status of
+// the GCPMachine, and a TimeoutError is returned until it completes so that the next reconciles poll it.
+func (s *Service) TerminateInstance(scope *scope.MachineScope) error {
+	op, err := s.instances.Delete(s.scope.Project(), scope.InstanceZone(), scope.InstanceName()).Do()
+	if err != nil {
+		if gcperrors.IsNotFound(err) {
+			return nil
+		}
+		return errors.Wrapf(err, "failed to terminate instance")
+	}
+	if err := wait.PollComputeOperation(op); err != nil {
+		if wait.IsTimeout(err) {
+			scope.GCPMachine.Status.Operation = op.SelfLink
+		}
+		return errors.Wrapf(err, "failed to terminate instance")
+	}
+	record.Eventf(scope.GCPMachine, "SuccessfulTerminate", "Terminated instance %q%s", scope.InstanceName(), operationDetails(op))
+
+	return nil
+}
+
+// PollInstanceOperation polls the operation in progress on the instance of the GCPMachine or its disks, if any,
+// which is forgotten once completed. A TimeoutError is returned while it is in progress, the error of the insert
+// or delete operation once it failed. The other operations, e.g. the change of the machine type, are only reported
+// with an event, the change being made again by the next reconciles if it failed.
+func (s *Service) PollInstanceOperation(scope *scope.MachineScope) error {
+	selfLink := scope.GCPMachine.Status.Operation
+	if selfLink == "" {
+		return nil
+	}
+
+	op, err := wait.PollComputeOperationLink(s.scope.Compute, s.scope.Project(), selfLink)
+	switch {
+	case wait.IsTimeout(err):
+		return err
+	case op == nil && gcperrors.IsNotFound(err):
+		// The operation expired, the instance is looked up as is.
+		scope.GCPMachine.Status.Operation = ""
+		return nil
+	case op == nil:
+		return errors.Wrapf(err, "failed to poll instance operation %s", selfLink)
+	}
+	scope.GCPMachine.Status.Operation = ""
+
+	switch op.OperationType {
+	case "insert":
+	case "delete":
+		if err != nil {
+			return errors.Wrapf(err, "failed to terminate instance")
+		}
+		record.Eventf(scope.GCPMachine, "SuccessfulTerminate", "Terminated instance %q%s", scope.InstanceName(), operationDetails(op))
+		return nil
+	default:
+		if err != nil {
+			record.Warnf(scope.GCPMachine, "FailedUpdate", "Failed to %s %s: %v", op.OperationType, path.Base(op.TargetLink), err)
+			return nil
+		}
+		record.Eventf(scope.GCPMachine, "SuccessfulUpdate", "Completed %s of %s%s", op.OperationType, path.Base(op.TargetLink), operationDetails(op))
+		return nil
+	}
+	if err != nil {
+		record.Warnf(scope.Machine, "FailedCreate", "Failed to create instance: %v", err)
+		return errors.Wrap(err, "failed to create gcp instance")
 	}
+	record.Eventf(scope.Machine, "SuccessfulCreate", "Created new %s instance with name %q%s", scope.Role(), path.Base(op.TargetLink), operationDetails(op))
+
+	return nil
+}
 
-	if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
-		return nil, errors.Wrap(err, "failed to create gcp instance")
+// runInstanceOperation issues the operation on the instance of the GCPMachine or its disks, e.g. the change of its
+// labels, and returns it once completed. The operation in progress is recorded in the status of the GCPMachine, and
+// a TimeoutError returned, instead of being waited for, so that the next reconciles poll it with PollInstanceOperation.
+func (s *Service) runInstanceOperation(scope *scope.MachineScope, issue func() (*compute.Operation, error)) (*compute.Operation, error) {
+	op, err := issue()
+	if err != nil {
+		return nil, err
+	}
+	if err := wait.PollComputeOperation(op); err != nil {
+		if wait.IsTimeout(err) {
+			scope.GCPMachine.Status.Operation = op.SelfLink
+		}
+		return nil, err
 	}
 
-	return s.instances.Get(s.scope.Project(), input.Zone, input.Name).Do()
+	return op, nil
 }
 
-// TerminateInstanceAndWait terminates the instance and wait for the termination.
-func (s *Service) TerminateInstanceAndWait(scope *scope.MachineScope) error {
-	op, err := s.instances.Delete(s.scope.Project(), scope.Zone(), scope.Name()).Do()
-	if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-		return errors.Wrapf(opErr, "failed to terminate instance")
+// StartInstance starts the terminated instance of the GCPMachine again.
+func (s *Service) StartInstance(scope *scope.MachineScope) error {
+	op, err := s.runInstanceOperation(scope, func() (*compute.Operation, error) {
+		return s.instances.Start(s.scope.Project(), scope.InstanceZone(), scope.InstanceName()).Do()
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to start instance")
 	}
+	record.Eventf(scope.GCPMachine, "SuccessfulStart", "Started terminated instance %q%s", scope.InstanceName(), operationDetails(op))
 
 	return nil
 }
 
+// GetBootstrapStatus returns the bootstrap status the instance reported in the
+// infrav1.BootstrapStatusGuestAttribute guest attribute, or an empty string if
+// it hasn't been reported yet.
+func (s *Service) GetBootstrapStatus(scope *scope.MachineScope) (string, error) {
+	attr, err := s.instances.GetGuestAttributes(s.scope.Project(), scope.InstanceZone(), scope.InstanceName()).
+		VariableKey(infrav1.BootstrapStatusGuestAttribute).Do()
+	if gcperrors.IsNotFound(err) {
+		return "", nil
+	} else if err != nil {
+		return "", errors.Wrapf(err, "failed to get guest attribute %q of instance %q", infrav1.BootstrapStatusGuestAttribute, scope.InstanceName())
+	}
+
+	return attr.VariableValue, nil
+}
+
+// GetSerialPortOutput returns the output of the first serial port of the instance, i.e. its console, as far as GCE
+// keeps it.
+func (s *Service) GetSerialPortOutput(scope *scope.MachineScope) (string, error) {
+	output, err := s.instances.GetSerialPortOutput(s.scope.Project(), scope.InstanceZone(), scope.InstanceName()).Do()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get serial port output of instance %q", scope.InstanceName())
+	}
+
+	return output.Contents, nil
+}
+
 // rootDiskImage computes the GCE disk image to use as the boot disk.
 func (s *Service) rootDiskImage(scope *scope.MachineScope) (string, error) {
 	if scope.GCPMachine.Spec.Image != nil {
@@ -241,6 +1146,12 @@ func (s *Service) rootDiskImage(scope *scope.MachineScope) (string, error) {
 	} else if scope.GCPMachine.Spec.ImageFamily != nil {
 		return *scope.GCPMachine.Spec.ImageFamily, nil
 	}
+	if lookup := scope.GCPMachine.Spec.ImageLookup; lookup != nil {
+		return s.lookupImage(scope, lookup)
+	}
+	if image := scope.DefaultImage(); image != nil {
+		return *image, nil
+	}
 
 	if scope.Machine.Spec.Version == nil {
 		return "", errors.Errorf("missing required Spec.Version on Machine %q in namespace %q",
@@ -0,0 +1,1004 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+func TestInstanceIfExists(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance).To(BeNil())
+
+	// The instance has been moved to another zone after its creation.
+	c.Put("projects/my-project/zones/us-central1-b/instances/my-machine", &compute.Instance{
+		Name: "my-machine",
+		Zone: c.SelfLink("projects/my-project/zones/us-central1-b"),
+	})
+	machineScope.SetProviderID("gce://my-project/us-central1-a/my-machine")
+	instance, err = s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance).NotTo(BeNil())
+	g.Expect(instance.Zone).To(HaveSuffix("zones/us-central1-b"))
+
+	machineScope.SetProviderID("gce://my-project/us-central1-b/my-machine")
+	g.Expect(machineScope.InstanceZone()).To(Equal("us-central1-b"))
+	g.Expect(s.TerminateInstance(machineScope)).To(Succeed())
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/instances")).To(BeEmpty())
+}
+
+func TestPollInstanceOperation(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	g.Expect(s.PollInstanceOperation(machineScope)).To(Succeed())
+
+	op := &compute.Operation{
+		Name:          "my-insert",
+		OperationType: "insert",
+		Zone:          c.SelfLink("projects/my-project/zones/us-central1-a"),
+		TargetLink:    c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-machine"),
+		Status:        "RUNNING",
+	}
+	c.Put("projects/my-project/zones/us-central1-a/operations/my-insert", op)
+	machineScope.GCPMachine.Status.Operation = c.SelfLink("projects/my-project/zones/us-central1-a/operations/my-insert")
+	g.Expect(wait.IsTimeout(s.PollInstanceOperation(machineScope))).To(BeTrue())
+	g.Expect(machineScope.GCPMachine.Status.Operation).NotTo(BeEmpty())
+
+	// The failed operation is forgotten, its error returned.
+	op.Status = "DONE"
+	op.Error = &compute.OperationError{Errors: []*compute.OperationErrorErrors{{Code: "QUOTA_EXCEEDED", Message: "Quota exceeded"}}}
+	c.Put("projects/my-project/zones/us-central1-a/operations/my-insert", op)
+	g.Expect(wait.HasErrorCode(s.PollInstanceOperation(machineScope), "QUOTA_EXCEEDED")).To(BeTrue())
+	g.Expect(machineScope.GCPMachine.Status.Operation).To(BeEmpty())
+
+	// The expired operation is forgotten.
+	machineScope.GCPMachine.Status.Operation = c.SelfLink("projects/my-project/zones/us-central1-a/operations/expired")
+	g.Expect(s.PollInstanceOperation(machineScope)).To(Succeed())
+	g.Expect(machineScope.GCPMachine.Status.Operation).To(BeEmpty())
+}
+
+func TestGetBootstrapStatus(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{Name: "my-machine"})
+
+	status, err := s.GetBootstrapStatus(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status).To(BeEmpty())
+
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+	status, err = s.GetBootstrapStatus(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(status).To(Equal(infrav1.BootstrapStatusSuccess))
+}
+
+func TestInstanceName(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine-"+strings.Repeat("x", 60), "us-central1-a")
+	g.Expect(machineScope.InstanceName()).To(HaveLen(names.MaxLength))
+	g.Expect(machineScope.InstanceName()).To(HavePrefix("my-machine-"))
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceNameTemplate: pointer.StringPtr("{{ .ClusterName }}-{{ .Role }}-{{ .Name }}")},
+	}
+	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpMachine).Build(),
+		Cluster:    clusterScope.Cluster,
+		Machine:    &clusterv1.Machine{Spec: clusterv1.MachineSpec{FailureDomain: pointer.StringPtr("us-central1-a")}},
+		GCPCluster: clusterScope.GCPCluster,
+		GCPMachine: gcpMachine,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(machineScope.InstanceName()).To(Equal(clusterScope.Name() + "-node-my-machine"))
+
+	c.Put("projects/my-project/zones/us-central1-a/instances/"+machineScope.InstanceName(), &compute.Instance{Name: machineScope.InstanceName()})
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance).NotTo(BeNil())
+}
+
+func createTestInstance(g *WithT, s *Service, gcpMachine *infrav1.GCPMachine) *compute.Instance {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: gcpMachine.Name + "-bootstrap", Namespace: gcpMachine.Namespace},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		Client:  fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpMachine, secret).Build(),
+		Cluster: s.scope.Cluster,
+		Machine: &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{Labels: gcpMachine.Labels},
+			Spec: clusterv1.MachineSpec{
+				FailureDomain: pointer.StringPtr("us-central1-a"),
+				Bootstrap:     clusterv1.Bootstrap{DataSecretName: pointer.StringPtr(secret.Name)},
+			},
+		},
+		GCPCluster: s.scope.GCPCluster,
+		GCPMachine: gcpMachine,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	instance, err := s.CreateInstance(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return instance
+}
+
+func instanceMetadata(instance *compute.Instance) map[string]string {
+	res := map[string]string{}
+	for _, m := range instance.Metadata.Items {
+		res[m.Key] = pointer.StringDeref(m.Value, "")
+	}
+
+	return res
+}
+
+func TestCreateInstanceSecurityProfile(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	createInstance := func(name string) *compute.Instance {
+		return createTestInstance(g, s, &infrav1.GCPMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+		})
+	}
+	metadata := instanceMetadata
+
+	instance := createInstance("my-machine")
+	g.Expect(instance.ShieldedInstanceConfig).To(BeNil())
+	g.Expect(instance.ServiceAccounts[0].Scopes).To(ConsistOf(compute.CloudPlatformScope))
+	g.Expect(metadata(instance)).NotTo(HaveKey(enableOSLoginKey))
+
+	clusterScope.GCPCluster.Spec.SecurityProfile = infrav1.SecurityProfileHardened
+	instance = createInstance("my-hardened-machine")
+	g.Expect(instance.ShieldedInstanceConfig).To(Equal(&compute.ShieldedInstanceConfig{
+		EnableSecureBoot:          true,
+		EnableVtpm:                true,
+		EnableIntegrityMonitoring: true,
+	}))
+	g.Expect(instance.ServiceAccounts[0].Email).To(Equal("default"))
+	g.Expect(instance.ServiceAccounts[0].Scopes).To(Equal(hardenedScopes))
+	g.Expect(instance.NetworkInterfaces[0].AccessConfigs).To(BeEmpty())
+	g.Expect(metadata(instance)).To(HaveKeyWithValue(enableOSLoginKey, "TRUE"))
+}
+
+func TestCreateInstanceBreakGlassSSHKey(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	clusterScope.GCPCluster.Spec.BreakGlassSSH = &infrav1.BreakGlassSSHSpec{}
+	clusterScope.GCPCluster.Status.BreakGlassSSH = &infrav1.BreakGlassSSHStatus{PublicKey: "ecdsa-sha2-nistp256 AAAA"}
+	s := NewService(clusterScope)
+
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	})
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(sshKeysKey, "break-glass:ecdsa-sha2-nistp256 AAAA break-glass"))
+
+	// The key is appended to the keys of the additional metadata.
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-other-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:       "n1-standard-2",
+			Image:              pointer.StringPtr("my-image"),
+			AdditionalMetadata: []infrav1.MetadataItem{{Key: sshKeysKey, Value: pointer.StringPtr("me:ssh-ed25519 BBBB me\n")}},
+		},
+	})
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(sshKeysKey, "me:ssh-ed25519 BBBB me\nbreak-glass:ecdsa-sha2-nistp256 AAAA break-glass"))
+}
+
+func TestCreateInstanceRootDisk(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:         "n1-standard-2",
+			Image:                pointer.StringPtr("my-image"),
+			RootDeviceAutoDelete: pointer.BoolPtr(false),
+			RootDeviceName:       pointer.StringPtr("my-disk"),
+		},
+	}
+
+	// The named disk is created from the image, and kept once the instance is deleted.
+	instance := createTestInstance(g, s, gcpMachine)
+	g.Expect(instance.Disks[0].AutoDelete).To(BeFalse())
+	g.Expect(instance.Disks[0].InitializeParams.DiskName).To(Equal("my-disk"))
+	g.Expect(instance.Disks[0].InitializeParams.SourceImage).To(Equal("my-image"))
+	c.Delete("projects/my-project/zones/us-central1-a/instances/my-machine")
+
+	// The disk left by the previous instance is attached to the next one.
+	c.Put("projects/my-project/zones/us-central1-a/disks/my-disk", &compute.Disk{Name: "my-disk"})
+	instance = createTestInstance(g, s, gcpMachine)
+	g.Expect(instance.Disks[0].Boot).To(BeTrue())
+	g.Expect(instance.Disks[0].Source).To(Equal(c.SelfLink("projects/my-project/zones/us-central1-a/disks/my-disk")))
+	g.Expect(instance.Disks[0].InitializeParams).To(BeNil())
+
+	// The boot disks are deleted with their instance by default.
+	gcpMachine = &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-other-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	}
+	instance = createTestInstance(g, s, gcpMachine)
+	g.Expect(instance.Disks[0].AutoDelete).To(BeTrue())
+	g.Expect(instance.Disks[0].InitializeParams.DiskName).To(BeEmpty())
+}
+
+func TestCreateInstanceShieldedAndConfidential(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:           "n2d-standard-2",
+			Image:                  pointer.StringPtr("my-image"),
+			ShieldedInstanceConfig: &infrav1.ShieldedInstanceConfig{SecureBoot: pointer.BoolPtr(true)},
+			ConfidentialCompute:    true,
+		},
+	})
+	g.Expect(instance.ShieldedInstanceConfig).To(Equal(&compute.ShieldedInstanceConfig{
+		EnableSecureBoot:          true,
+		EnableVtpm:                true,
+		EnableIntegrityMonitoring: true,
+	}))
+	g.Expect(instance.ConfidentialInstanceConfig.EnableConfidentialCompute).To(BeTrue())
+	g.Expect(instance.Scheduling.OnHostMaintenance).To(Equal("TERMINATE"))
+
+	// The configuration of the machine overrides the hardened defaults, the integrity monitoring following the vTPM.
+	clusterScope.GCPCluster.Spec.SecurityProfile = infrav1.SecurityProfileHardened
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-other-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:           "n1-standard-2",
+			Image:                  pointer.StringPtr("my-image"),
+			ShieldedInstanceConfig: &infrav1.ShieldedInstanceConfig{VirtualizedTrustedPlatformModule: pointer.BoolPtr(false)},
+		},
+	})
+	g.Expect(instance.ShieldedInstanceConfig).To(Equal(&compute.ShieldedInstanceConfig{EnableSecureBoot: true}))
+	g.Expect(instance.ConfidentialInstanceConfig).To(BeNil())
+}
+
+func TestCreateInstanceEtcdDisk(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	newGCPMachine := func(name string, labels map[string]string) *infrav1.GCPMachine {
+		return &infrav1.GCPMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec: infrav1.GCPMachineSpec{
+				InstanceType: "n1-standard-2",
+				Image:        pointer.StringPtr("my-image"),
+				EtcdDisk: &infrav1.EtcdDisk{
+					Size:       pointer.Int64Ptr(100),
+					KMSKeyName: pointer.StringPtr("projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-key"),
+				},
+			},
+		}
+	}
+
+	instance := createTestInstance(g, s, newGCPMachine("my-control-plane", map[string]string{clusterv1.MachineControlPlaneLabelName: ""}))
+	g.Expect(instance.Disks).To(HaveLen(2))
+	etcd := instance.Disks[1]
+	g.Expect(etcd.DeviceName).To(Equal(infrav1.EtcdDiskDeviceName))
+	g.Expect(etcd.AutoDelete).To(BeTrue())
+	g.Expect(etcd.InitializeParams.DiskName).To(Equal("my-control-plane-etcd"))
+	g.Expect(etcd.InitializeParams.DiskSizeGb).To(Equal(int64(100)))
+	g.Expect(etcd.InitializeParams.DiskType).To(Equal("zones/us-central1-a/diskTypes/pd-ssd"))
+	g.Expect(etcd.InitializeParams.Labels).To(Equal(instance.Labels))
+	g.Expect(etcd.DiskEncryptionKey.KmsKeyName).To(Equal("projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-key"))
+
+	// The etcd disk is ignored for the other machines.
+	instance = createTestInstance(g, s, newGCPMachine("my-node", nil))
+	g.Expect(instance.Disks).To(HaveLen(1))
+}
+
+func TestCreateInstanceAdditionalDisks(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	pdSSD, localSSD, scsi := infrav1.PdSsdDiskType, infrav1.LocalSsdDiskType, infrav1.SCSIDiskInterface
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			Image:        pointer.StringPtr("my-image"),
+			AdditionalDisks: []infrav1.AttachedDiskSpec{
+				{DeviceType: &pdSSD, Size: pointer.Int64Ptr(100), DeviceName: pointer.StringPtr("data"), AutoDelete: pointer.BoolPtr(false)},
+				{DeviceType: &localSSD, DeviceName: pointer.StringPtr("containerd")},
+				{DeviceType: &localSSD, Interface: &scsi},
+			},
+		},
+	})
+	g.Expect(instance.Disks).To(HaveLen(4))
+
+	data := instance.Disks[1]
+	g.Expect(data.Type).To(BeEmpty())
+	g.Expect(data.DeviceName).To(Equal("data"))
+	g.Expect(data.AutoDelete).To(BeFalse())
+	g.Expect(data.Interface).To(BeEmpty())
+	g.Expect(data.InitializeParams.DiskSizeGb).To(Equal(int64(100)))
+	g.Expect(data.InitializeParams.DiskType).To(Equal("zones/us-central1-a/diskTypes/pd-ssd"))
+	g.Expect(data.InitializeParams.Labels).To(Equal(instance.Labels))
+
+	containerd := instance.Disks[2]
+	g.Expect(containerd.Type).To(Equal("SCRATCH"))
+	g.Expect(containerd.DeviceName).To(Equal("containerd"))
+	g.Expect(containerd.AutoDelete).To(BeTrue())
+	g.Expect(containerd.Interface).To(Equal("NVME"))
+	g.Expect(containerd.InitializeParams.DiskSizeGb).To(Equal(int64(375)))
+	g.Expect(containerd.InitializeParams.Labels).To(BeEmpty())
+
+	g.Expect(instance.Disks[3].Interface).To(Equal("SCSI"))
+}
+
+func TestCreateInstanceDiskEncryption(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	localSSD := infrav1.LocalSsdDiskType
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-control-plane", Namespace: "default", Labels: map[string]string{clusterv1.MachineControlPlaneLabelName: ""}},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			Image:        pointer.StringPtr("my-image"),
+			AdditionalDisks: []infrav1.AttachedDiskSpec{
+				{Size: pointer.Int64Ptr(100)},
+				{DeviceType: &localSSD},
+			},
+			EtcdDisk: &infrav1.EtcdDisk{
+				KMSKeyName: pointer.StringPtr("projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-etcd-key"),
+			},
+			DiskEncryption: &infrav1.DiskEncryption{
+				KMSKeyName:           "projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-key",
+				KMSKeyServiceAccount: pointer.StringPtr("my-kms@my-project.iam.gserviceaccount.com"),
+			},
+		},
+	})
+	g.Expect(instance.Disks).To(HaveLen(4))
+	key := &compute.CustomerEncryptionKey{
+		KmsKeyName:           "projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-key",
+		KmsKeyServiceAccount: "my-kms@my-project.iam.gserviceaccount.com",
+	}
+	g.Expect(instance.Disks[0].DiskEncryptionKey).To(Equal(key))
+	g.Expect(instance.Disks[1].DiskEncryptionKey).To(Equal(key))
+	// Local SSDs can't be encrypted with a customer-managed key.
+	g.Expect(instance.Disks[2].DiskEncryptionKey).To(BeNil())
+	// The etcd disk keeps its own key.
+	g.Expect(instance.Disks[3].DiskEncryptionKey.KmsKeyName).To(Equal("projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-etcd-key"))
+}
+
+func TestCreateInstanceServiceAccount(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			Image:        pointer.StringPtr("my-image"),
+			ServiceAccount: &infrav1.ServiceAccount{
+				Email:  "nodes@my-project.iam.gserviceaccount.com",
+				Scopes: []string{compute.DevstorageReadOnlyScope, "https://www.googleapis.com/auth/logging.write"},
+			},
+		},
+	})
+	g.Expect(instance.ServiceAccounts).To(Equal([]*compute.ServiceAccount{{
+		Email:  "nodes@my-project.iam.gserviceaccount.com",
+		Scopes: []string{compute.DevstorageReadOnlyScope, "https://www.googleapis.com/auth/logging.write"},
+	}}))
+
+	// The omitted email and scopes are defaulted when the webhook hasn't.
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-other-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:   "n1-standard-2",
+			Image:          pointer.StringPtr("my-image"),
+			ServiceAccount: &infrav1.ServiceAccount{},
+		},
+	})
+	g.Expect(instance.ServiceAccounts).To(Equal([]*compute.ServiceAccount{{
+		Email:  "default",
+		Scopes: []string{compute.CloudPlatformScope},
+	}}))
+}
+
+func TestCreateInstanceMachineDefaults(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	clusterScope.GCPCluster.Spec.AdditionalLabels = infrav1.Labels{"team": "infra"}
+	clusterScope.GCPCluster.Spec.MachineDefaults = &infrav1.MachineDefaults{
+		ImageFamily: pointer.StringPtr("projects/my-project/global/images/family/my-family"),
+		ServiceAccount: &infrav1.ServiceAccount{
+			Email:  "nodes@my-project.iam.gserviceaccount.com",
+			Scopes: []string{compute.CloudPlatformScope},
+		},
+		AdditionalNetworkTags: []string{"default-tag"},
+		AdditionalLabels:      infrav1.Labels{"env": "prod", "tier": "default"},
+		Subnet:                pointer.StringPtr("my-subnet"),
+		PublicIP:              pointer.BoolPtr(true),
+	}
+	s := NewService(clusterScope)
+
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-default-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2"},
+	})
+	g.Expect(instance.Disks[0].InitializeParams.SourceImage).To(Equal("projects/my-project/global/images/family/my-family"))
+	g.Expect(instance.ServiceAccounts[0].Email).To(Equal("nodes@my-project.iam.gserviceaccount.com"))
+	g.Expect(instance.Tags.Items).To(ContainElement("default-tag"))
+	g.Expect(instance.Labels).To(HaveKeyWithValue("team", "infra"))
+	g.Expect(instance.Labels).To(HaveKeyWithValue("env", "prod"))
+	g.Expect(instance.NetworkInterfaces[0].Subnetwork).To(HaveSuffix("regions/us-central1/subnetworks/my-subnet"))
+	g.Expect(instance.NetworkInterfaces[0].AccessConfigs).To(HaveLen(1))
+
+	// The GCPMachine overrides the defaults.
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:          "n1-standard-2",
+			Image:                 pointer.StringPtr("my-image"),
+			ServiceAccount:        &infrav1.ServiceAccount{Email: "default", Scopes: []string{compute.CloudPlatformScope}},
+			AdditionalNetworkTags: []string{"my-tag"},
+			AdditionalLabels:      infrav1.Labels{"tier": "frontend"},
+			Subnet:                pointer.StringPtr("my-other-subnet"),
+			PublicIP:              pointer.BoolPtr(false),
+		},
+	})
+	g.Expect(instance.Disks[0].InitializeParams.SourceImage).To(Equal("my-image"))
+	g.Expect(instance.ServiceAccounts[0].Email).To(Equal("default"))
+	g.Expect(instance.Tags.Items).To(ContainElement("my-tag"))
+	g.Expect(instance.Tags.Items).NotTo(ContainElement("default-tag"))
+	g.Expect(instance.Labels).To(HaveKeyWithValue("env", "prod"))
+	g.Expect(instance.Labels).To(HaveKeyWithValue("tier", "frontend"))
+	g.Expect(instance.NetworkInterfaces[0].Subnetwork).To(HaveSuffix("regions/us-central1/subnetworks/my-other-subnet"))
+	g.Expect(instance.NetworkInterfaces[0].AccessConfigs).To(BeEmpty())
+
+	// The labels of the GCPMachine aren't added to the GCPCluster.
+	g.Expect(clusterScope.GCPCluster.Spec.AdditionalLabels).To(Equal(infrav1.Labels{"team": "infra"}))
+}
+
+func TestCreateInstanceOSConfig(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:   "n1-standard-2",
+			Image:          pointer.StringPtr("my-image"),
+			EnableOSConfig: true,
+			ServiceAccount: &infrav1.ServiceAccount{
+				Email:  "sa@my-project.iam.gserviceaccount.com",
+				Scopes: []string{"https://www.googleapis.com/auth/logging.write"},
+			},
+		},
+	})
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(enableOSConfigKey, "TRUE"))
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(enableGuestAttributesKey, "TRUE"))
+	g.Expect(instance.ServiceAccounts[0].Scopes).To(ConsistOf("https://www.googleapis.com/auth/logging.write", compute.CloudPlatformScope))
+
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-other-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	})
+	g.Expect(instanceMetadata(instance)).NotTo(HaveKey(enableOSConfigKey))
+}
+
+func TestComplyWithConstraint(t *testing.T) {
+	g := NewWithT(t)
+
+	input := &compute.Instance{Metadata: &compute.Metadata{Items: []*compute.MetadataItems{
+		{Key: enableOSLoginKey, Value: pointer.StringPtr("FALSE")},
+	}}}
+	g.Expect(complyWithConstraint(input, gcperrors.RequireShieldedVMConstraint)).To(BeTrue())
+	g.Expect(input.ShieldedInstanceConfig.EnableSecureBoot).To(BeTrue())
+	g.Expect(complyWithConstraint(input, gcperrors.RequireShieldedVMConstraint)).To(BeFalse())
+
+	g.Expect(complyWithConstraint(input, gcperrors.RequireOSLoginConstraint)).To(BeTrue())
+	g.Expect(input.Metadata.Items).To(HaveLen(1))
+	g.Expect(*input.Metadata.Items[0].Value).To(Equal("TRUE"))
+	g.Expect(complyWithConstraint(input, gcperrors.RequireOSLoginConstraint)).To(BeFalse())
+
+	g.Expect(complyWithConstraint(input, gcperrors.VMExternalIPAccessConstraint)).To(BeFalse())
+	g.Expect(complyWithConstraint(input, gcperrors.TrustedImageProjectsConstraint)).To(BeFalse())
+}
+
+func TestReconcileInstanceLabels(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	owned := map[string]string{infrav1.ClusterTagKey("my-cluster"): string(infrav1.ResourceLifecycleOwned)}
+	c.Put("projects/my-project/zones/us-central1-a/disks/my-machine", &compute.Disk{Name: "my-machine", Labels: owned})
+	c.Put("projects/my-project/zones/us-central1-a/disks/my-data", &compute.Disk{Name: "my-data"})
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name:   "my-machine",
+		Zone:   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Labels: map[string]string{"team": "platform"},
+		Disks: []*compute.AttachedDisk{
+			{Source: c.SelfLink("projects/my-project/zones/us-central1-a/disks/my-machine"), Type: "PERSISTENT"},
+			{Source: c.SelfLink("projects/my-project/zones/us-central1-a/disks/my-data"), Type: "PERSISTENT"},
+		},
+	})
+
+	clusterScope.GCPCluster.Spec.AdditionalLabels = infrav1.Labels{"cost-center": "1234"}
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.ReconcileInstanceLabels(machineScope, instance)).To(Succeed())
+
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Labels).To(HaveKeyWithValue("team", "platform"))
+	g.Expect(instance.Labels).To(HaveKeyWithValue("cost-center", "1234"))
+	disk := &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-machine", disk)).To(BeTrue())
+	g.Expect(disk.Labels).To(HaveKeyWithValue("cost-center", "1234"))
+	disk = &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-data", disk)).To(BeTrue())
+	g.Expect(disk.Labels).To(BeEmpty())
+
+	g.Expect(machineScope.GCPMachine.Status.LabelsHash).NotTo(BeEmpty())
+
+	// The labels aren't set again while they haven't drifted, nor are the disks read.
+	fingerprint := instance.LabelFingerprint
+	c.SetError("GET", "projects/my-project/zones/us-central1-a/disks/my-machine", &googleapi.Error{Code: http.StatusInternalServerError})
+	g.Expect(s.ReconcileInstanceLabels(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.LabelFingerprint).To(Equal(fingerprint))
+
+	// The disks are read again once the labels of the spec change.
+	hash := machineScope.GCPMachine.Status.LabelsHash
+	machineScope.GCPMachine.Spec.AdditionalLabels = infrav1.Labels{"team": "platform"}
+	g.Expect(s.ReconcileInstanceLabels(machineScope, instance)).NotTo(Succeed())
+	c.SetError("GET", "projects/my-project/zones/us-central1-a/disks/my-machine", nil)
+	g.Expect(s.ReconcileInstanceLabels(machineScope, instance)).To(Succeed())
+	g.Expect(machineScope.GCPMachine.Status.LabelsHash).NotTo(Equal(hash))
+	disk = &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-machine", disk)).To(BeTrue())
+	g.Expect(disk.Labels).To(HaveKeyWithValue("team", "platform"))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	fingerprint = instance.LabelFingerprint
+
+	// The labels changed since the instance was read aren't overwritten.
+	_, err = c.Compute().Instances.SetLabels("my-project", "us-central1-a", "my-machine", &compute.InstancesSetLabelsRequest{
+		Labels:           map[string]string{"team": "storage"},
+		LabelFingerprint: fingerprint,
+	}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	clusterScope.GCPCluster.Spec.AdditionalLabels = infrav1.Labels{"cost-center": "5678"}
+	err = s.ReconcileInstanceLabels(machineScope, instance)
+	g.Expect(gcperrors.IsFingerprintMismatch(err)).To(BeTrue())
+	instance = &compute.Instance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Labels).To(Equal(map[string]string{"team": "storage"}))
+}
+
+func TestRetainDetachedDisks(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	owned := map[string]string{infrav1.ClusterTagKey("my-cluster"): string(infrav1.ResourceLifecycleOwned)}
+	c.Put("projects/my-project/zones/us-central1-a/disks/my-disk", &compute.Disk{Name: "my-disk", Labels: owned})
+	c.Put("projects/my-project/zones/us-central1-a/disks/my-etcd", &compute.Disk{Name: "my-etcd", Labels: owned})
+	c.Put("projects/my-project/zones/us-central1-a/disks/my-data", &compute.Disk{Name: "my-data"})
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name: "my-machine",
+		Zone: c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Disks: []*compute.AttachedDisk{
+			{Boot: true, Source: c.SelfLink("projects/my-project/zones/us-central1-a/disks/my-disk"), Type: "PERSISTENT"},
+			{Source: c.SelfLink("projects/my-project/zones/us-central1-a/disks/my-etcd"), Type: "PERSISTENT", AutoDelete: true},
+			{Source: c.SelfLink("projects/my-project/zones/us-central1-a/disks/my-data"), Type: "PERSISTENT"},
+		},
+	})
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.RetainDetachedDisks(machineScope, instance)).To(Succeed())
+
+	// Only the owned disks kept once the instance is deleted are retained.
+	disk := &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-disk", disk)).To(BeTrue())
+	g.Expect(disk.Labels).To(HaveKeyWithValue(infrav1.NameGCPRetained, "true"))
+	disk = &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-etcd", disk)).To(BeTrue())
+	g.Expect(disk.Labels).NotTo(HaveKey(infrav1.NameGCPRetained))
+	disk = &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-data", disk)).To(BeTrue())
+	g.Expect(disk.Labels).To(BeEmpty())
+}
+
+func TestReconcileRootDiskSize(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/disks/my-machine", &compute.Disk{Name: "my-machine", SizeGb: 30})
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name:  "my-machine",
+		Zone:  c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Disks: []*compute.AttachedDisk{{Boot: true, Source: c.SelfLink("projects/my-project/zones/us-central1-a/disks/my-machine"), Type: "PERSISTENT"}},
+	})
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The disk isn't shrunk.
+	machineScope.GCPMachine.Spec.RootDeviceSize = 20
+	g.Expect(s.ReconcileRootDiskSize(machineScope, instance)).To(Succeed())
+	disk := &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-machine", disk)).To(BeTrue())
+	g.Expect(disk.SizeGb).To(BeEquivalentTo(30))
+
+	testEvents.Messages()
+	machineScope.GCPMachine.Spec.RootDeviceSize = 50
+	g.Expect(s.ReconcileRootDiskSize(machineScope, instance)).To(Succeed())
+	disk = &compute.Disk{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/disks/my-machine", disk)).To(BeTrue())
+	g.Expect(disk.SizeGb).To(BeEquivalentTo(50))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`ResizedRootDisk Resized root disk "my-machine" of instance "my-machine" from 30 GB to 50 GB .*growpart`)))
+}
+
+func TestReconcileInstanceType(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name:        "my-machine",
+		Zone:        c.SelfLink("projects/my-project/zones/us-central1-a"),
+		MachineType: c.SelfLink("projects/my-project/zones/us-central1-a/machineTypes/n1-standard-2"),
+		Status:      "RUNNING",
+	})
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	machineScope.GCPMachine.Spec.InstanceType = "n1-standard-2"
+	g.Expect(s.ReconcileInstanceType(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.MachineType).To(HaveSuffix("machineTypes/n1-standard-2"))
+
+	// The running instance is stopped for the change and started again.
+	testEvents.Messages()
+	machineScope.GCPMachine.Spec.InstanceType = "n1-standard-4"
+	g.Expect(s.ReconcileInstanceType(machineScope, instance)).To(Succeed())
+	instance = &compute.Instance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.MachineType).To(Equal("zones/us-central1-a/machineTypes/n1-standard-4"))
+	g.Expect(instance.Status).To(Equal("RUNNING"))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`UpdatedMachineType Changed machine type of instance "my-machine" from n1-standard-2 to n1-standard-4`)))
+
+	// The instance in transition is changed once it's running or terminated.
+	instance.Status = "STOPPING"
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", instance)
+	machineScope.GCPMachine.Spec.InstanceType = "n1-standard-8"
+	g.Expect(s.ReconcileInstanceType(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.MachineType).To(HaveSuffix("machineTypes/n1-standard-4"))
+
+	// The terminated instance isn't started.
+	instance.Status = "TERMINATED"
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", instance)
+	g.Expect(s.ReconcileInstanceType(machineScope, instance)).To(Succeed())
+	instance = &compute.Instance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.MachineType).To(HaveSuffix("machineTypes/n1-standard-8"))
+	g.Expect(instance.Status).To(Equal("TERMINATED"))
+}
+
+func TestReconcileInstanceTypeFailedStart(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	instancePath := "projects/my-project/zones/us-central1-a/instances/my-machine"
+	c.Put(instancePath, &compute.Instance{
+		Name:        "my-machine",
+		Zone:        c.SelfLink("projects/my-project/zones/us-central1-a"),
+		MachineType: c.SelfLink("projects/my-project/zones/us-central1-a/machineTypes/n1-standard-2"),
+		Status:      "RUNNING",
+	})
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The instance is left stopped by the failed start, pending its restart.
+	c.SetOperationError(http.MethodPost, instancePath+"/start", "ZONE_RESOURCE_POOL_EXHAUSTED")
+	machineScope.GCPMachine.Spec.InstanceType = "n1-standard-4"
+	g.Expect(s.ReconcileInstanceType(machineScope, instance)).To(MatchError(ContainSubstring("failed to start instance")))
+	g.Expect(machineScope.GCPMachine.Annotations).To(HaveKey(infrav1.PendingRestartAnnotation))
+	instance, err = s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instance.Status).To(Equal("TERMINATED"))
+	g.Expect(instance.MachineType).To(HaveSuffix("machineTypes/n1-standard-4"))
+
+	// The next reconcile starts it again, rather than leaving it terminated.
+	c.SetOperationError(http.MethodPost, instancePath+"/start", "")
+	g.Expect(s.ReconcileInstanceType(machineScope, instance)).To(Succeed())
+	g.Expect(machineScope.GCPMachine.Annotations).NotTo(HaveKey(infrav1.PendingRestartAnnotation))
+	g.Expect(instance.Status).To(Equal("RUNNING"))
+	g.Expect(c.Get(instancePath, instance)).To(BeTrue())
+	g.Expect(instance.Status).To(Equal("RUNNING"))
+}
+
+func TestReconcileInstanceTypeInProgress(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name:        "my-machine",
+		Zone:        c.SelfLink("projects/my-project/zones/us-central1-a"),
+		MachineType: c.SelfLink("projects/my-project/zones/us-central1-a/machineTypes/n1-standard-2"),
+		Status:      "RUNNING",
+	})
+	machineScope.GCPMachine.Spec.InstanceType = "n1-standard-4"
+	c.SetOperationsInProgress(true)
+
+	// Each operation in progress is recorded in the status, and polled by the next reconciles instead of being
+	// waited for, the stop, the change of the machine type and the start taking a reconcile each.
+	testEvents.Messages()
+	for _, operationType := range []string{"stop", "setMachineType", "start"} {
+		instance, err := s.InstanceIfExists(machineScope)
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(wait.IsTimeout(s.ReconcileInstanceType(machineScope, instance))).To(BeTrue())
+		g.Expect(machineScope.GCPMachine.Status.Operation).NotTo(BeEmpty())
+		g.Expect(machineScope.GCPMachine.Annotations).To(HaveKey(infrav1.PendingRestartAnnotation))
+		g.Expect(wait.IsTimeout(s.PollInstanceOperation(machineScope))).To(BeTrue())
+
+		c.CompleteOperations()
+		g.Expect(s.PollInstanceOperation(machineScope)).To(Succeed())
+		g.Expect(machineScope.GCPMachine.Status.Operation).To(BeEmpty())
+		g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`^Normal SuccessfulUpdate Completed %s of my-machine `, operationType)))
+	}
+
+	// The instance started by the previous reconcile is no longer pending its restart.
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.ReconcileInstanceType(machineScope, instance)).To(Succeed())
+	g.Expect(machineScope.GCPMachine.Annotations).NotTo(HaveKey(infrav1.PendingRestartAnnotation))
+	g.Expect(instance.Status).To(Equal("RUNNING"))
+	g.Expect(instance.MachineType).To(HaveSuffix("machineTypes/n1-standard-4"))
+
+	// The failed update is only reported, the next reconciles making the change again.
+	machineScope.GCPMachine.Spec.AdditionalMetadata = []infrav1.MetadataItem{{Key: "team", Value: pointer.StringPtr("platform")}}
+	c.SetOperationError(http.MethodPost, "projects/my-project/zones/us-central1-a/instances/my-machine/setMetadata", "RESOURCE_NOT_READY")
+	g.Expect(s.ReconcileInstanceMetadata(machineScope, instance)).To(MatchError(ContainSubstring("RESOURCE_NOT_READY")))
+	c.SetOperationError(http.MethodPost, "projects/my-project/zones/us-central1-a/instances/my-machine/setMetadata", "")
+	g.Expect(wait.IsTimeout(s.ReconcileInstanceMetadata(machineScope, instance))).To(BeTrue())
+	c.CompleteOperations()
+	g.Expect(s.PollInstanceOperation(machineScope)).To(Succeed())
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`^Normal SuccessfulUpdate Completed setMetadata of my-machine `)))
+}
+
+func TestReconcileInstanceMetadata(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name: "my-machine",
+		Zone: c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Metadata: &compute.Metadata{
+			Fingerprint: "1",
+			Items: []*compute.MetadataItems{
+				{Key: "user-data", Value: pointer.StringPtr("#cloud-config")},
+				{Key: "startup-script", Value: pointer.StringPtr("echo provider")},
+				{Key: "team", Value: pointer.StringPtr("platform")},
+			},
+		},
+	})
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	testEvents.Messages()
+	machineScope.GCPMachine.Spec.AdditionalMetadata = []infrav1.MetadataItem{
+		{Key: "team", Value: pointer.StringPtr("storage")},
+		{Key: "enable-oslogin", Value: pointer.StringPtr("TRUE")},
+		{Key: "startup-script", Value: pointer.StringPtr("echo machine")},
+	}
+	g.Expect(s.ReconcileInstanceMetadata(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	items := map[string]string{}
+	for _, item := range instance.Metadata.Items {
+		items[item.Key] = pointer.StringDeref(item.Value, "")
+	}
+	g.Expect(items).To(Equal(map[string]string{
+		"user-data":      "#cloud-config",
+		"startup-script": "echo provider",
+		"team":           "storage",
+		"enable-oslogin": "TRUE",
+	}))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`UpdatedMetadata Updated metadata team, enable-oslogin of instance "my-machine"`)))
+
+	g.Expect(machineScope.GCPMachine.Annotations).To(HaveKeyWithValue(infrav1.ManagedMetadataAnnotation, "enable-oslogin,startup-script,team"))
+
+	// The metadata isn't set again while it hasn't drifted.
+	fingerprint := instance.Metadata.Fingerprint
+	g.Expect(s.ReconcileInstanceMetadata(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Metadata.Fingerprint).To(Equal(fingerprint))
+
+	// The keys removed from the GCPMachine are removed from the instance, except the ones the provider sets.
+	machineScope.GCPMachine.Spec.AdditionalMetadata = []infrav1.MetadataItem{
+		{Key: "startup-script", Value: pointer.StringPtr("echo machine")},
+	}
+	g.Expect(s.ReconcileInstanceMetadata(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	items = map[string]string{}
+	for _, item := range instance.Metadata.Items {
+		items[item.Key] = pointer.StringDeref(item.Value, "")
+	}
+	g.Expect(items).To(Equal(map[string]string{
+		"user-data":      "#cloud-config",
+		"startup-script": "echo provider",
+		"enable-oslogin": "TRUE",
+	}))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`UpdatedMetadata Updated metadata team of instance "my-machine"`)))
+	g.Expect(machineScope.GCPMachine.Annotations).To(HaveKeyWithValue(infrav1.ManagedMetadataAnnotation, "startup-script"))
+
+	// The keys set before they were recorded are left as is.
+	machineScope.GCPMachine.Spec.AdditionalMetadata = nil
+	delete(machineScope.GCPMachine.Annotations, infrav1.ManagedMetadataAnnotation)
+	fingerprint = instance.Metadata.Fingerprint
+	g.Expect(s.ReconcileInstanceMetadata(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Metadata.Fingerprint).To(Equal(fingerprint))
+	g.Expect(machineScope.GCPMachine.Annotations).NotTo(HaveKey(infrav1.ManagedMetadataAnnotation))
+}
+
+func TestReconcileInstanceTags(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machineScope := newTestMachineScope(g, clusterScope, "my-machine", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", &compute.Instance{
+		Name: "my-machine",
+		Zone: c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Tags: &compute.Tags{Items: []string{"my-cluster-node", "my-cluster", "web"}},
+	})
+	instance, err := s.InstanceIfExists(machineScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The tags removed from the GCPMachine are removed from the instance.
+	machineScope.GCPMachine.Spec.AdditionalNetworkTags = []string{"db"}
+	g.Expect(s.ReconcileInstanceTags(machineScope, instance)).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Tags.Items).To(ConsistOf("my-cluster-node", "my-cluster", "db"))
+
+	// The tags of an adopted instance are kept.
+	machineScope.GCPMachine.Spec.ExistingInstance = pointer.StringPtr("my-machine")
+	machineScope.GCPMachine.Spec.AdditionalNetworkTags = []string{"cache"}
+	g.Expect(s.ReconcileInstanceTags(machineScope, instance)).To(Succeed())
+	instance = &compute.Instance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+	g.Expect(instance.Tags.Items).To(ConsistOf("my-cluster-node", "my-cluster", "db", "cache"))
+}
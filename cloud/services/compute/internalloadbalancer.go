@@ -0,0 +1,336 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+// The Internal load balancer is a regional internal TCP load balancer: an internal address in a subnetwork of the
+// region, and a regional forwarding rule to a regional backend service of the API server instance groups. The
+// traffic is forwarded to the instances as is, without proxy, so there is no target proxy nor proxy-only subnetwork.
+
+// APIServerInternalLoadBalancerScheme defines the scheme of the components of an Internal load balancer.
+const APIServerInternalLoadBalancerScheme = "INTERNAL"
+
+// reconcileInternalLoadbalancer reconciles the components of an Internal load balancer.
+// The health check and the internal address don't depend on each other and are reconciled concurrently.
+func (s *Service) reconcileInternalLoadbalancer() error {
+	if err := reconciler.RunParallel(reconciler.DefaultParallelism, s.reconcileHealthCheck, s.reconcileInternalAddress); err != nil {
+		return err
+	}
+
+	if err := s.reconcileRegionalBackendService(); err != nil {
+		return err
+	}
+
+	return s.reconcileInternalForwardingRule()
+}
+
+// reconcileInternalAddress reconciles the internal IP address of the API server in the subnetwork of the load balancer.
+func (s *Service) reconcileInternalAddress() error {
+	name := s.apiServerLoadBalancerName()
+	address, err := s.regionaddresses.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	if gcperrors.IsNotFound(err) {
+		spec := &compute.Address{
+			Name:        name,
+			Description: s.ownershipMarker(),
+			AddressType: APIServerInternalLoadBalancerScheme,
+			Subnetwork:  s.internalLoadBalancerSubnetwork(),
+		}
+		if err := s.runInsertOperation(path.Join("regions", s.scope.Region(), "addresses", name), func() (*compute.Operation, error) {
+			return s.regionaddresses.Insert(s.scope.Project(), s.scope.Region(), spec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create internal address")
+		}
+		address, err = s.regionaddresses.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe internal address")
+	}
+	if err := s.ensureOwned("internal address", path.Join("regions", s.scope.Region(), "addresses", address.Name), address.Description); err != nil {
+		return err
+	}
+
+	s.scope.Network().APIServerAddress = pointer.StringPtr(address.Address)
+	s.scope.Network().APIServerAddressSelfLink = pointer.StringPtr(address.SelfLink)
+
+	return nil
+}
+
+// reconcileRegionalBackendService reconciles the regional backend service of the API server instance groups.
+func (s *Service) reconcileRegionalBackendService() error {
+	backendServiceSpec := s.getAPIServerRegionalBackendServiceSpec()
+	backendService, err := s.regionbackendservices.Get(s.scope.Project(), s.scope.Region(), backendServiceSpec.Name).Do()
+	if gcperrors.IsNotFound(err) {
+		if err := s.runInsertOperation(path.Join("regions", s.scope.Region(), "backendServices", backendServiceSpec.Name), func() (*compute.Operation, error) {
+			return s.regionbackendservices.Insert(s.scope.Project(), s.scope.Region(), backendServiceSpec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create regional backend service")
+		}
+		backendService, err = s.regionbackendservices.Get(s.scope.Project(), s.scope.Region(), backendServiceSpec.Name).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe regional backend service")
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe regional backend service")
+	} else if err := s.ensureOwned("backend service", path.Join("regions", s.scope.Region(), "backendServices", backendService.Name), backendService.Description); err != nil {
+		return err
+	}
+
+	if drift := backendServiceDrift(backendService, backendServiceSpec); drift != "" && !s.deferDisruptiveChange("backend service", backendService.Name, drift) {
+		backendService.Protocol = backendServiceSpec.Protocol
+		backendService.PortName = backendServiceSpec.PortName
+		backendService.TimeoutSec = backendServiceSpec.TimeoutSec
+		backendService.HealthChecks = backendServiceSpec.HealthChecks
+		backendService.Backends = backendServiceSpec.Backends
+		backendService.SessionAffinity = backendServiceSpec.SessionAffinity
+		backendService.ConnectionDraining = backendServiceSpec.ConnectionDraining
+		if err := s.updateRegionalBackendService(backendService); err != nil {
+			return err
+		}
+		s.recordDriftCorrected("backend service", backendService.Name, drift, nil)
+	}
+
+	s.scope.Network().APIServerBackendService = pointer.StringPtr(backendService.SelfLink)
+
+	return nil
+}
+
+// updateRegionalBackendServices updates the backends of the regional backend service when the control plane
+// enters or leaves zones, creating or deleting their instance groups.
+func (s *Service) updateRegionalBackendServices() error {
+	backendServiceSpec := s.getAPIServerRegionalBackendServiceSpec()
+	backendService, err := s.regionbackendservices.Get(s.scope.Project(), s.scope.Region(), backendServiceSpec.Name).Do()
+	if err != nil {
+		return err
+	}
+	if err := s.ensureOwned("backend service", path.Join("regions", s.scope.Region(), "backendServices", backendService.Name), backendService.Description); err != nil {
+		return err
+	}
+
+	if !equalStringSets(backendGroups(backendService.Backends), backendGroups(backendServiceSpec.Backends)) {
+		backendService.Backends = backendServiceSpec.Backends
+		return s.updateRegionalBackendService(backendService)
+	}
+
+	return nil
+}
+
+func (s *Service) updateRegionalBackendService(backendService *compute.BackendService) error {
+	if _, err := s.runOperation(path.Join("regions", s.scope.Region(), "backendServices", backendService.Name), "update", func() (*compute.Operation, error) {
+		return s.regionbackendservices.Update(s.scope.Project(), s.scope.Region(), backendService.Name, backendService).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to update regional backend service")
+	}
+
+	return nil
+}
+
+// reconcileInternalForwardingRule reconciles the regional forwarding rule of the internal address to the backend service.
+func (s *Service) reconcileInternalForwardingRule() error {
+	spec := s.getAPIServerInternalForwardingRuleSpec()
+	forwardingRule, err := s.regionforwardingrules.Get(s.scope.Project(), s.scope.Region(), spec.Name).Do()
+	if err == nil {
+		if err := s.ensureOwnedLabelled("forwarding rule", path.Join("regions", s.scope.Region(), "forwardingRules", forwardingRule.Name), forwardingRule.Labels); err != nil {
+			return err
+		}
+	}
+	if err == nil && (forwardingRule.IPAddress != spec.IPAddress || !equalStringSets(forwardingRule.Ports, spec.Ports) || forwardingRule.BackendService != spec.BackendService) &&
+		!s.deferDisruptiveChange("forwarding rule", forwardingRule.Name, "address, ports or backend service changed") {
+		// The address, the ports and the backend service of a regional forwarding rule can't be updated, recreate it.
+		if err := s.runDeleteOperation(path.Join("regions", s.scope.Region(), "forwardingRules", forwardingRule.Name), func() (*compute.Operation, error) {
+			return s.regionforwardingrules.Delete(s.scope.Project(), s.scope.Region(), forwardingRule.Name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete forwarding rule")
+		}
+		s.recordDriftCorrected("forwarding rule", forwardingRule.Name, "address, ports or backend service changed", nil)
+		forwardingRule, err = s.regionforwardingrules.Get(s.scope.Project(), s.scope.Region(), spec.Name).Do()
+	}
+	if gcperrors.IsNotFound(err) {
+		if err := s.runInsertOperation(path.Join("regions", s.scope.Region(), "forwardingRules", spec.Name), func() (*compute.Operation, error) {
+			return s.regionforwardingrules.Insert(s.scope.Project(), s.scope.Region(), spec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create forwarding rule")
+		}
+		forwardingRule, err = s.regionforwardingrules.Get(s.scope.Project(), s.scope.Region(), spec.Name).Do()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe forwarding rule")
+	}
+	if labels, drifted := mergeLabels(forwardingRule.Labels, spec.Labels); drifted {
+		req := &compute.RegionSetLabelsRequest{Labels: labels, LabelFingerprint: forwardingRule.LabelFingerprint}
+		op, err := s.runOperation(path.Join("regions", s.scope.Region(), "forwardingRules", forwardingRule.Name), "setLabels", func() (*compute.Operation, error) {
+			return s.regionforwardingrules.SetLabels(s.scope.Project(), s.scope.Region(), forwardingRule.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set forwarding rule labels")
+		}
+		s.recordDriftCorrected("forwarding rule", forwardingRule.Name, "labels changed", op)
+	}
+
+	s.scope.Network().APIServerForwardingRule = pointer.StringPtr(forwardingRule.SelfLink)
+
+	return nil
+}
+
+// deleteInternalLoadbalancer deletes the components of an Internal load balancer by name.
+func (s *Service) deleteInternalLoadbalancer() error {
+	name := s.apiServerLoadBalancerName()
+
+	if err := s.runOwnedDeleteOperation("forwarding rule", path.Join("regions", s.scope.Region(), "forwardingRules", name), func() (string, error) {
+		return s.describeRegionalForwardingRule(name)
+	}, func() (*compute.Operation, error) {
+		return s.regionforwardingrules.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete forwarding rule")
+	}
+	s.scope.Network().APIServerForwardingRule = nil
+
+	// The internal address and the backend service were only used by the forwarding rule, delete them concurrently.
+	if err := reconciler.RunParallel(reconciler.DefaultParallelism,
+		func() error {
+			if s.scope.ShouldRetain(infrav1.RetainAPIServerAddress) {
+				s.recordRetained("internal address", name)
+			} else {
+				if err := s.runOwnedDeleteOperation("internal address", path.Join("regions", s.scope.Region(), "addresses", name), func() (string, error) {
+					return s.describeRegionalAddress(name)
+				}, func() (*compute.Operation, error) {
+					return s.regionaddresses.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+				}); err != nil {
+					return errors.Wrapf(err, "failed to delete internal address")
+				}
+			}
+			s.scope.Network().APIServerAddress = nil
+			s.scope.Network().APIServerAddressSelfLink = nil
+
+			return nil
+		},
+		func() error {
+			if err := s.runOwnedDeleteOperation("regional backend service", path.Join("regions", s.scope.Region(), "backendServices", name), func() (string, error) {
+				backendService, err := s.regionbackendservices.Get(s.scope.Project(), s.scope.Region(), name).Do()
+				if err != nil {
+					return "", err
+				}
+
+				return backendService.Description, nil
+			}, func() (*compute.Operation, error) {
+				return s.regionbackendservices.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+			}); err != nil {
+				return errors.Wrapf(err, "failed to delete regional backend service")
+			}
+			s.scope.Network().APIServerBackendService = nil
+
+			return nil
+		},
+	); err != nil {
+		return err
+	}
+
+	// Delete Health Check.
+	if err := s.deleteHealthCheck(name); err != nil {
+		return err
+	}
+	s.scope.Network().APIServerHealthCheck = nil
+
+	return nil
+}
+
+// describeRegionalForwardingRule returns the ownership marker of the regional forwarding rule, whose ownership is
+// marked by its labels.
+func (s *Service) describeRegionalForwardingRule(name string) (string, error) {
+	forwardingRule, err := s.regionforwardingrules.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	if err != nil {
+		return "", err
+	}
+
+	return s.labelledOwnershipMarker(forwardingRule.Labels), nil
+}
+
+// describeRegionalAddress returns the description of the regional address.
+func (s *Service) describeRegionalAddress(name string) (string, error) {
+	address, err := s.regionaddresses.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	if err != nil {
+		return "", err
+	}
+
+	return address.Description, nil
+}
+
+// internalLoadBalancerSubnetwork returns the reference to the subnetwork of the internal address, or an empty string
+// to let GCP pick the subnetwork of the region in an auto mode network.
+func (s *Service) internalLoadBalancerSubnetwork() string {
+	subnet := s.scope.LoadBalancerSubnet()
+	if subnet == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("regions/%s/subnetworks/%s", s.scope.Region(), *subnet)
+}
+
+func (s *Service) getAPIServerRegionalBackendServiceSpec() *compute.BackendService {
+	res := &compute.BackendService{
+		Name:                s.apiServerLoadBalancerName(),
+		Description:         s.ownershipMarker(),
+		LoadBalancingScheme: APIServerInternalLoadBalancerScheme,
+		Protocol:            APIServerLoadBalancerProtocol,
+		HealthChecks: []string{
+			*s.scope.Network().APIServerHealthCheck,
+		},
+		SessionAffinity:    s.scope.LoadBalancerSessionAffinity(),
+		ConnectionDraining: s.getAPIServerConnectionDrainingSpec(),
+	}
+
+	// The connections are balanced across the instance groups, the backends of an internal backend service
+	// can't be balanced by utilization.
+	for _, groupSelfLink := range s.scope.Network().APIServerInstanceGroups {
+		res.Backends = append(res.Backends, &compute.Backend{
+			BalancingMode: "CONNECTION",
+			Group:         groupSelfLink,
+		})
+	}
+
+	return res
+}
+
+func (s *Service) getAPIServerInternalForwardingRuleSpec() *compute.ForwardingRule {
+	return &compute.ForwardingRule{
+		Name:                s.apiServerLoadBalancerName(),
+		Description:         s.ownershipMarker(),
+		IPAddress:           *s.scope.Network().APIServerAddress,
+		IPProtocol:          APIServerLoadBalancerProtocol,
+		LoadBalancingScheme: APIServerInternalLoadBalancerScheme,
+		// The traffic is forwarded as is, the API server port of the instances is exposed.
+		Ports:          []string{strconv.FormatInt(s.scope.LoadBalancerBackendPort(), 10)},
+		Network:        s.scope.NetworkSelfLink(),
+		Subnetwork:     s.internalLoadBalancerSubnetwork(),
+		BackendService: *s.scope.Network().APIServerBackendService,
+		// The clients of the other regions of the network, e.g. the machines of a management cluster, are allowed.
+		AllowGlobalAccess: true,
+		Labels:            s.ownershipLabels(infrav1.APIServerRoleTagValue),
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestInternalLoadBalancer(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.LoadBalancer.Scheme = infrav1.LoadBalancerSchemeInternal
+	params.GCPCluster.Spec.MachineDefaults = &infrav1.MachineDefaults{Subnet: pointer.StringPtr("my-subnet")}
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	// The internal address is reserved in the subnetwork of the machines, there is no global component but the health check.
+	address := &compute.Address{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/addresses/my-cluster-apiserver", address)).To(BeTrue())
+	g.Expect(address.AddressType).To(Equal("INTERNAL"))
+	g.Expect(address.Subnetwork).To(Equal("regions/us-central1/subnetworks/my-subnet"))
+	g.Expect(c.List("projects/my-project/global/backendServices")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/targetTcpProxies")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/healthChecks")).To(HaveLen(1))
+
+	// The traffic is forwarded to the API server port of the instances as is.
+	forwardingRule := &compute.ForwardingRule{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/forwardingRules/my-cluster-apiserver", forwardingRule)).To(BeTrue())
+	g.Expect(forwardingRule.LoadBalancingScheme).To(Equal("INTERNAL"))
+	g.Expect(forwardingRule.Ports).To(ConsistOf("6443"))
+	g.Expect(forwardingRule.BackendService).To(Equal(*s.scope.Network().APIServerBackendService))
+	g.Expect(forwardingRule.IPAddress).To(Equal(*s.scope.Network().APIServerAddress))
+
+	// The health checks and the private clients reach the API server of the instances.
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceRanges).To(ConsistOf("35.191.0.0/16", "130.211.0.0/22"))
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-clients", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceRanges).To(ConsistOf("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16"))
+
+	// The instance groups are the backends of the regional backend service, their health is reported by it.
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine-0", &compute.Instance{})
+	group := s.APIServerInstanceGroupName("us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instanceGroups/"+group, &compute.InstanceGroup{Name: group})
+	g.Expect(s.UpdateBackendServices()).To(Succeed())
+	backendService := &compute.BackendService{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.Backends).To(HaveLen(1))
+	g.Expect(backendService.Backends[0].BalancingMode).To(Equal("CONNECTION"))
+	_, err := s.instancegroups.AddInstances("my-project", "us-central1-a", group, &compute.InstanceGroupsAddInstancesRequest{
+		Instances: []*compute.InstanceReference{
+			{Instance: c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-machine-0")},
+		},
+	}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	healthy, total, err := s.GetAPIServerBackendsHealth()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(healthy).To(Equal(1))
+	g.Expect(total).To(Equal(1))
+
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(c.List("projects/my-project/regions/us-central1/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/regions/us-central1/backendServices")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/regions/us-central1/addresses")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/healthChecks")).To(BeEmpty())
+}
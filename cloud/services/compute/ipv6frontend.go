@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+// The IPv6 frontend of a dual-stack External Proxy load balancer is a global IPv6 address and its forwarding rule to
+// the target proxy of the IPv4 frontend. The proxy terminates the connections of the clients, and reaches the backends
+// over IPv4 from the same ranges, so the firewall rules of the load balancer are unchanged.
+
+// APIServerLoadBalancerIPv6Version defines the IP type of the IPv6 frontend.
+const APIServerLoadBalancerIPv6Version = "IPV6"
+
+// reconcileIPv6Frontend reconciles the IPv6 address and forwarding rule of a dual-stack load balancer, and deletes
+// them once the load balancer is IPv4 only.
+func (s *Service) reconcileIPv6Frontend() error {
+	if s.scope.LoadBalancerStackType() != infrav1.StackTypeIPv4IPv6 {
+		if s.scope.Network().APIServerIPv6Address == nil && s.scope.Network().APIServerIPv6ForwardingRule == nil {
+			return nil
+		}
+		return s.deleteIPv6Frontend(false)
+	}
+
+	name := s.apiServerIPv6FrontendName()
+	address, err := s.addresses.Get(s.scope.Project(), name).Do()
+	if gcperrors.IsNotFound(err) {
+		spec := &compute.Address{
+			Name:        name,
+			Description: s.namespacedOwnershipMarker(),
+			AddressType: APIServerLoadBalancerScheme,
+			IpVersion:   APIServerLoadBalancerIPv6Version,
+		}
+		if err := s.runInsertOperation(path.Join("global", "addresses", name), func() (*compute.Operation, error) {
+			return s.addresses.Insert(s.scope.Project(), spec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create global IPv6 address")
+		}
+		address, err = s.addresses.Get(s.scope.Project(), name).Do()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe global IPv6 address")
+	}
+	if err := s.ensureOwned("global address", path.Join("global", "addresses", name), address.Description); err != nil {
+		return err
+	}
+	s.scope.Network().APIServerIPv6Address = pointer.StringPtr(address.Address)
+
+	forwardingRuleSpec := s.getAPIServerIPv6ForwardingRuleSpec()
+	forwardingRule, err := s.forwardingrules.Get(s.scope.Project(), name).Do()
+	if err == nil {
+		if err := s.ensureOwnedLabelled("forwarding rule", path.Join("global", "forwardingRules", name), forwardingRule.Labels); err != nil {
+			return err
+		}
+	}
+	if err == nil && (forwardingRule.IPAddress != forwardingRuleSpec.IPAddress || forwardingRule.PortRange != forwardingRuleSpec.PortRange) &&
+		!s.deferDisruptiveChange("forwarding rule", forwardingRule.Name, "address or ports changed") {
+		// The address and the ports of a forwarding rule can't be updated, recreate it.
+		if err := s.runDeleteOperation(path.Join("global", "forwardingRules", name), func() (*compute.Operation, error) {
+			return s.forwardingrules.Delete(s.scope.Project(), name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete IPv6 forwarding rule")
+		}
+		s.recordDriftCorrected("forwarding rule", name, "address or ports changed", nil)
+		forwardingRule, err = s.forwardingrules.Get(s.scope.Project(), name).Do()
+	}
+	if gcperrors.IsNotFound(err) {
+		if err := s.runInsertOperation(path.Join("global", "forwardingRules", name), func() (*compute.Operation, error) {
+			return s.forwardingrules.Insert(s.scope.Project(), forwardingRuleSpec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create IPv6 forwarding rule")
+		}
+		forwardingRule, err = s.forwardingrules.Get(s.scope.Project(), name).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to describe IPv6 forwarding rule")
+		}
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe IPv6 forwarding rule")
+	} else if forwardingRule.Target != forwardingRuleSpec.Target {
+		op, err := s.runOperation(path.Join("global", "forwardingRules", name), "setTarget", func() (*compute.Operation, error) {
+			return s.forwardingrules.SetTarget(s.scope.Project(), name, &compute.TargetReference{Target: forwardingRuleSpec.Target}).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set IPv6 forwarding rule target")
+		}
+		s.recordDriftCorrected("forwarding rule", name, "target changed", op)
+	}
+	s.scope.Network().APIServerIPv6ForwardingRule = pointer.StringPtr(forwardingRule.SelfLink)
+
+	return nil
+}
+
+// deleteIPv6Frontend deletes the IPv6 forwarding rule and address of the load balancer, the address being kept if
+// retained. They are deleted by name, and ignored if they don't exist or aren't owned by the cluster.
+func (s *Service) deleteIPv6Frontend(retainAddress bool) error {
+	name := s.apiServerIPv6FrontendName()
+	if err := s.runOwnedDeleteOperation("forwarding rule", path.Join("global", "forwardingRules", name), func() (string, error) {
+		return s.describeForwardingRule(name)
+	}, func() (*compute.Operation, error) {
+		return s.forwardingrules.Delete(s.scope.Project(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete IPv6 forwarding rule")
+	}
+	s.scope.Network().APIServerIPv6ForwardingRule = nil
+
+	if retainAddress {
+		if s.scope.Network().APIServerIPv6Address != nil {
+			s.recordRetained("global address", name)
+		}
+	} else if err := s.runOwnedDeleteOperation("global address", path.Join("global", "addresses", name), func() (string, error) {
+		return s.describeAddress(name)
+	}, func() (*compute.Operation, error) {
+		return s.addresses.Delete(s.scope.Project(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete global IPv6 address")
+	}
+	s.scope.Network().APIServerIPv6Address = nil
+
+	return nil
+}
+
+// apiServerIPv6FrontendName returns the name of the IPv6 address and forwarding rule of the load balancer.
+func (s *Service) apiServerIPv6FrontendName() string {
+	return names.Truncate(fmt.Sprintf("%s-%s-ipv6", s.scope.ResourceNamePrefix(), infrav1.APIServerRoleTagValue))
+}
+
+func (s *Service) getAPIServerIPv6ForwardingRuleSpec() *compute.ForwardingRule {
+	frontendPortRange := fmt.Sprintf("%d-%d", s.scope.LoadBalancerFrontendPort(), s.scope.LoadBalancerFrontendPort())
+
+	return &compute.ForwardingRule{
+		Name:                s.apiServerIPv6FrontendName(),
+		IPAddress:           *s.scope.Network().APIServerIPv6Address,
+		IPProtocol:          APIServerLoadBalancerProtocol,
+		IpVersion:           APIServerLoadBalancerIPv6Version,
+		LoadBalancingScheme: APIServerLoadBalancerScheme,
+		PortRange:           frontendPortRange,
+		Target:              *s.scope.Network().APIServerTargetProxy,
+		Labels:              s.ownershipLabels(infrav1.APIServerRoleTagValue),
+	}
+}
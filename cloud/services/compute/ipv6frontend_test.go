@@ -0,0 +1,112 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestDualStack(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.LoadBalancer.StackType = infrav1.StackTypeIPv4IPv6
+	params.GCPCluster.Spec.Network.AutoCreateSubnetworks = pointer.BoolPtr(false)
+	params.GCPCluster.Spec.Network.Subnets = infrav1.Subnets{
+		{Name: "control-plane", CidrBlock: "10.0.0.0/24", Region: "us-central1", Roles: []infrav1.SubnetRole{infrav1.SubnetRoleControlPlane}},
+		{
+			Name:      "nodes",
+			CidrBlock: "10.1.0.0/16",
+			Region:    "us-central1",
+			Roles:     []infrav1.SubnetRole{infrav1.SubnetRoleWorker},
+			StackType: infrav1.StackTypeIPv4IPv6,
+		},
+	}
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+
+	subnet := &compute.Subnetwork{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/nodes", subnet)).To(BeTrue())
+	g.Expect(subnet.StackType).To(Equal("IPV4_IPV6"))
+	g.Expect(subnet.Ipv6AccessType).To(Equal("EXTERNAL"))
+	subnet = &compute.Subnetwork{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/control-plane", subnet)).To(BeTrue())
+	g.Expect(subnet.StackType).To(BeEmpty())
+	var ipv6Ranges []string
+	for _, subnet := range s.scope.GCPCluster.Status.Network.Subnets {
+		if subnet.IPv6CidrBlock != "" {
+			ipv6Ranges = append(ipv6Ranges, subnet.IPv6CidrBlock)
+		}
+	}
+	g.Expect(ipv6Ranges).To(HaveLen(1))
+
+	// IPv6 is enabled on the existing subnetwork.
+	s.scope.GCPCluster.Spec.Network.Subnets[0].StackType = infrav1.StackTypeIPv4IPv6
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/control-plane", subnet)).To(BeTrue())
+	g.Expect(subnet.StackType).To(Equal("IPV4_IPV6"))
+	g.Expect(subnet.Ipv6AccessType).To(Equal("EXTERNAL"))
+
+	// The IPv6 traffic between the instances is allowed from the IPv6 ranges of their subnetworks.
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster-ipv6", firewall)).To(BeTrue())
+	g.Expect(firewall.SourceRanges).To(ConsistOf(ipv6Ranges))
+
+	// The IPv6 frontend forwards to the target proxy of the IPv4 one.
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+	address := &compute.Address{}
+	g.Expect(c.Get("projects/my-project/global/addresses/my-cluster-apiserver-ipv6", address)).To(BeTrue())
+	g.Expect(address.IpVersion).To(Equal("IPV6"))
+	g.Expect(s.scope.Network().APIServerIPv6Address).To(Equal(pointer.StringPtr(address.Address)))
+	forwardingRule := &compute.ForwardingRule{}
+	g.Expect(c.Get("projects/my-project/global/forwardingRules/my-cluster-apiserver-ipv6", forwardingRule)).To(BeTrue())
+	g.Expect(forwardingRule.IPAddress).To(Equal(address.Address))
+	g.Expect(forwardingRule.Target).To(Equal(*s.scope.Network().APIServerTargetProxy))
+	g.Expect(s.scope.Network().APIServerIPv6ForwardingRule).NotTo(BeNil())
+
+	// The instances of a dual-stack subnetwork get an external IPv6 address.
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-node", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	})
+	g.Expect(instance.NetworkInterfaces[0].StackType).To(Equal("IPV4_IPV6"))
+	g.Expect(instance.NetworkInterfaces[0].Ipv6AccessConfigs).To(HaveLen(1))
+	g.Expect(instance.NetworkInterfaces[0].Ipv6AccessConfigs[0].Type).To(Equal("DIRECT_IPV6"))
+
+	// The IPv6 frontend is deleted once the load balancer is IPv4 only.
+	s.scope.GCPCluster.Spec.LoadBalancer.StackType = ""
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/forwardingRules/my-cluster-apiserver-ipv6", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/global/addresses/my-cluster-apiserver-ipv6", nil)).To(BeFalse())
+	g.Expect(s.scope.Network().APIServerIPv6Address).To(BeNil())
+	g.Expect(c.Get("projects/my-project/global/forwardingRules/my-cluster-apiserver", nil)).To(BeTrue())
+
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+}
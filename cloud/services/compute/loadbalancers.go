@@ -27,7 +27,8 @@ import (
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
-	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
 )
 
 const (
@@ -43,19 +44,51 @@ const (
 	APIServerLoadBalancerIPVersion = "IPV4"
 	// APIServerLoadBalancerBackendPortName defines the LB backend port name.
 	APIServerLoadBalancerBackendPortName = "apiserver"
+	// APIServerLoadBalancerMaxConnectionsPerEndpoint defines the target capacity of a network endpoint,
+	// the connections being balanced across the endpoints rather than by the utilization of the instances.
+	APIServerLoadBalancerMaxConnectionsPerEndpoint = 1000
 )
 
 // ReconcileLoadbalancers reconciles the api server load balancer.
+// The health check and the IP address don't depend on each other and are reconciled concurrently.
 func (s *Service) ReconcileLoadbalancers() error {
-	// Reconcile Health Check.
+	switch s.scope.LoadBalancerType() {
+	case infrav1.LoadBalancerTypeNone:
+		return nil
+	case infrav1.LoadBalancerTypeTargetInstance:
+		return s.reconcileRegionalAddress()
+	}
+	if s.scope.LoadBalancerScheme() == infrav1.LoadBalancerSchemeInternal {
+		return s.reconcileInternalLoadbalancer()
+	}
+
+	if err := reconciler.RunParallel(reconciler.DefaultParallelism, s.reconcileHealthCheck, s.reconcileAddress); err != nil {
+		return err
+	}
+
+	if err := s.reconcileBackendService(); err != nil {
+		return err
+	}
+
+	if err := s.reconcileTargetProxy(); err != nil {
+		return err
+	}
+
+	if err := s.reconcileForwardingRule(); err != nil {
+		return err
+	}
+
+	return s.reconcileIPv6Frontend()
+}
+
+// reconcileHealthCheck reconciles the health check of the API server backends.
+func (s *Service) reconcileHealthCheck() error {
 	healthCheckSpec := s.getAPIServerHealthCheckSpec()
 	healthCheck, err := s.healthchecks.Get(s.scope.Project(), healthCheckSpec.Name).Do()
 	if gcperrors.IsNotFound(err) {
-		op, err := s.healthchecks.Insert(s.scope.Project(), healthCheckSpec).Do()
-		if err != nil {
-			return errors.Wrapf(err, "failed to create health check")
-		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		if err := s.runInsertOperation(path.Join("global", "healthChecks", healthCheckSpec.Name), func() (*compute.Operation, error) {
+			return s.healthchecks.Insert(s.scope.Project(), healthCheckSpec).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to create health check")
 		}
 		healthCheck, err = s.healthchecks.Get(s.scope.Project(), healthCheckSpec.Name).Do()
@@ -64,19 +97,36 @@ func (s *Service) ReconcileLoadbalancers() error {
 		}
 	} else if err != nil {
 		return errors.Wrapf(err, "failed to describe health check")
+	} else if err := s.ensureOwned("health check", path.Join("global", "healthChecks", healthCheck.Name), healthCheck.Description); err != nil {
+		return err
+	}
+
+	if drift := healthCheckDrift(healthCheck, healthCheckSpec); drift != "" && !s.deferDisruptiveChange("health check", healthCheck.Name, drift) {
+		// The description of an adopted health check is kept.
+		update := *healthCheckSpec
+		update.Description = healthCheck.Description
+		op, err := s.runOperation(path.Join("global", "healthChecks", healthCheck.Name), "update", func() (*compute.Operation, error) {
+			return s.healthchecks.Update(s.scope.Project(), healthCheck.Name, &update).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to update health check")
+		}
+		s.recordDriftCorrected("health check", healthCheck.Name, drift, op)
 	}
 
 	s.scope.Network().APIServerHealthCheck = pointer.StringPtr(healthCheck.SelfLink)
 
-	// Reconcile Backend Service.
+	return nil
+}
+
+// reconcileBackendService reconciles the backend service of the API server instance groups.
+func (s *Service) reconcileBackendService() error {
 	backendServiceSpec := s.getAPIServerBackendServiceSpec()
 	backendService, err := s.backendservices.Get(s.scope.Project(), backendServiceSpec.Name).Do()
 	if gcperrors.IsNotFound(err) {
-		op, err := s.backendservices.Insert(s.scope.Project(), backendServiceSpec).Do()
-		if err != nil {
-			return errors.Wrapf(err, "failed to create backend service")
-		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		if err := s.runInsertOperation(path.Join("global", "backendServices", backendServiceSpec.Name), func() (*compute.Operation, error) {
+			return s.backendservices.Insert(s.scope.Project(), backendServiceSpec).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to create backend service")
 		}
 		backendService, err = s.backendservices.Get(s.scope.Project(), backendServiceSpec.Name).Do()
@@ -85,19 +135,67 @@ func (s *Service) ReconcileLoadbalancers() error {
 		}
 	} else if err != nil {
 		return errors.Wrapf(err, "failed to describe backend service")
+	} else if err := s.ensureOwned("backend service", path.Join("global", "backendServices", backendService.Name), backendService.Description); err != nil {
+		return err
+	}
+
+	if drift := backendServiceDrift(backendService, backendServiceSpec); drift != "" && !s.deferDisruptiveChange("backend service", backendService.Name, drift) {
+		backendService.Protocol = backendServiceSpec.Protocol
+		backendService.PortName = backendServiceSpec.PortName
+		backendService.TimeoutSec = backendServiceSpec.TimeoutSec
+		backendService.HealthChecks = backendServiceSpec.HealthChecks
+		backendService.Backends = backendServiceSpec.Backends
+		backendService.SessionAffinity = backendServiceSpec.SessionAffinity
+		backendService.ConnectionDraining = backendServiceSpec.ConnectionDraining
+		op, err := s.runOperation(path.Join("global", "backendServices", backendService.Name), "update", func() (*compute.Operation, error) {
+			return s.backendservices.Update(s.scope.Project(), backendService.Name, backendService).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to update backend service")
+		}
+		s.recordDriftCorrected("backend service", backendService.Name, drift, op)
+	}
+
+	if err := s.reconcileSecurityPolicy(backendService); err != nil {
+		return err
 	}
 
 	s.scope.Network().APIServerBackendService = pointer.StringPtr(backendService.SelfLink)
 
-	// Reconcile Target Proxy.
+	return nil
+}
+
+// reconcileSecurityPolicy attaches the Cloud Armor security policy of the spec, if any, to the backend service.
+func (s *Service) reconcileSecurityPolicy(backendService *compute.BackendService) error {
+	policy := s.scope.LoadBalancerSecurityPolicy()
+	if policy == nil || path.Base(backendService.SecurityPolicy) == *policy {
+		return nil
+	}
+
+	ref := &compute.SecurityPolicyReference{
+		SecurityPolicy: fmt.Sprintf("projects/%s/global/securityPolicies/%s", s.scope.Project(), *policy),
+	}
+	op, err := s.runOperation(path.Join("global", "backendServices", backendService.Name), "setSecurityPolicy", func() (*compute.Operation, error) {
+		return s.backendservices.SetSecurityPolicy(s.scope.Project(), backendService.Name, ref).Do()
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to set security policy of backend service")
+	}
+	if backendService.SecurityPolicy != "" {
+		s.recordDriftCorrected("backend service", backendService.Name, "security policy changed", op)
+	}
+
+	return nil
+}
+
+// reconcileTargetProxy reconciles the TCP proxy in front of the backend service.
+func (s *Service) reconcileTargetProxy() error {
 	targetProxySpec := s.getAPIServerTargetProxySpec()
 	targetProxy, err := s.targetproxies.Get(s.scope.Project(), targetProxySpec.Name).Do()
 	if gcperrors.IsNotFound(err) {
-		op, err := s.targetproxies.Insert(s.scope.Project(), targetProxySpec).Do()
-		if err != nil {
-			return errors.Wrapf(err, "failed to create target proxy")
-		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		if err := s.runInsertOperation(path.Join("global", "targetTcpProxies", targetProxySpec.Name), func() (*compute.Operation, error) {
+			return s.targetproxies.Insert(s.scope.Project(), targetProxySpec).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to create target proxy")
 		}
 		targetProxy, err = s.targetproxies.Get(s.scope.Project(), targetProxySpec.Name).Do()
@@ -106,19 +204,25 @@ func (s *Service) ReconcileLoadbalancers() error {
 		}
 	} else if err != nil {
 		return errors.Wrapf(err, "failed to describe target proxy")
+	} else if err := s.ensureOwned("target proxy", path.Join("global", "targetTcpProxies", targetProxy.Name), targetProxy.Description); err != nil {
+		return err
+	} else if err := s.reconcileTargetProxyDrift(targetProxy, targetProxySpec); err != nil {
+		return err
 	}
 
 	s.scope.Network().APIServerTargetProxy = pointer.StringPtr(targetProxy.SelfLink)
 
-	// Reconcile Global IP Address.
+	return nil
+}
+
+// reconcileAddress reconciles the global IP address of the API server.
+func (s *Service) reconcileAddress() error {
 	addressSpec := s.getAPIServerIPAddressSpec()
 	address, err := s.addresses.Get(s.scope.Project(), addressSpec.Name).Do()
 	if gcperrors.IsNotFound(err) {
-		op, err := s.addresses.Insert(s.scope.Project(), addressSpec).Do()
-		if err != nil {
-			return errors.Wrapf(err, "failed to create global addresses")
-		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		if err := s.runInsertOperation(path.Join("global", "addresses", addressSpec.Name), func() (*compute.Operation, error) {
+			return s.addresses.Insert(s.scope.Project(), addressSpec).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to create global addresses")
 		}
 		address, err = s.addresses.Get(s.scope.Project(), addressSpec.Name).Do()
@@ -127,19 +231,40 @@ func (s *Service) ReconcileLoadbalancers() error {
 		}
 	} else if err != nil {
 		return errors.Wrapf(err, "failed to describe addresses")
+	} else if err := s.ensureOwned("global address", path.Join("global", "addresses", address.Name), address.Description); err != nil {
+		return err
 	}
 
 	s.scope.Network().APIServerAddress = pointer.StringPtr(address.Address)
+	s.scope.Network().APIServerAddressSelfLink = pointer.StringPtr(address.SelfLink)
+
+	return nil
+}
 
-	// Reconcile Forwarding Rules.
+// reconcileForwardingRule reconciles the forwarding rule of the API server address to the target proxy.
+func (s *Service) reconcileForwardingRule() error {
 	forwardingRuleSpec := s.getAPIServerForwardingRuleSpec()
 	forwardingRule, err := s.forwardingrules.Get(s.scope.Project(), forwardingRuleSpec.Name).Do()
-	if gcperrors.IsNotFound(err) {
-		op, err := s.forwardingrules.Insert(s.scope.Project(), forwardingRuleSpec).Do()
-		if err != nil {
-			return errors.Wrapf(err, "failed to create forwarding rules")
+	if err == nil {
+		if err := s.ensureOwnedLabelled("forwarding rule", path.Join("global", "forwardingRules", forwardingRule.Name), forwardingRule.Labels); err != nil {
+			return err
+		}
+	}
+	if err == nil && (forwardingRule.IPAddress != forwardingRuleSpec.IPAddress || forwardingRule.PortRange != forwardingRuleSpec.PortRange) &&
+		!s.deferDisruptiveChange("forwarding rule", forwardingRule.Name, "address or ports changed") {
+		// The address and the ports of a forwarding rule can't be updated, recreate it.
+		if err := s.runDeleteOperation(path.Join("global", "forwardingRules", forwardingRule.Name), func() (*compute.Operation, error) {
+			return s.forwardingrules.Delete(s.scope.Project(), forwardingRule.Name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete forwarding rules")
 		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		s.recordDriftCorrected("forwarding rule", forwardingRule.Name, "address or ports changed", nil)
+		forwardingRule, err = s.forwardingrules.Get(s.scope.Project(), forwardingRuleSpec.Name).Do()
+	}
+	if gcperrors.IsNotFound(err) {
+		if err := s.runInsertOperation(path.Join("global", "forwardingRules", forwardingRuleSpec.Name), func() (*compute.Operation, error) {
+			return s.forwardingrules.Insert(s.scope.Project(), forwardingRuleSpec).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to create forwarding rules")
 		}
 		forwardingRule, err = s.forwardingrules.Get(s.scope.Project(), forwardingRuleSpec.Name).Do()
@@ -148,6 +273,24 @@ func (s *Service) ReconcileLoadbalancers() error {
 		}
 	} else if err != nil {
 		return errors.Wrapf(err, "failed to describe forwarding rules")
+	} else if forwardingRule.Target != forwardingRuleSpec.Target {
+		op, err := s.runOperation(path.Join("global", "forwardingRules", forwardingRule.Name), "setTarget", func() (*compute.Operation, error) {
+			return s.forwardingrules.SetTarget(s.scope.Project(), forwardingRule.Name, &compute.TargetReference{Target: forwardingRuleSpec.Target}).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set forwarding rule target")
+		}
+		s.recordDriftCorrected("forwarding rule", forwardingRule.Name, "target changed", op)
+	}
+	if labels, drifted := mergeLabels(forwardingRule.Labels, forwardingRuleSpec.Labels); drifted {
+		req := &compute.GlobalSetLabelsRequest{Labels: labels, LabelFingerprint: forwardingRule.LabelFingerprint}
+		op, err := s.runOperation(path.Join("global", "forwardingRules", forwardingRule.Name), "setLabels", func() (*compute.Operation, error) {
+			return s.forwardingrules.SetLabels(s.scope.Project(), forwardingRule.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set forwarding rule labels")
+		}
+		s.recordDriftCorrected("forwarding rule", forwardingRule.Name, "labels changed", op)
 	}
 
 	s.scope.Network().APIServerForwardingRule = pointer.StringPtr(forwardingRule.SelfLink)
@@ -155,30 +298,85 @@ func (s *Service) ReconcileLoadbalancers() error {
 	return nil
 }
 
-// UpdateBackendServices updates the backend services for a instance group.
+// reconcileTargetProxyDrift restores the backend service and the proxy header of the target proxy if modified out-of-band.
+func (s *Service) reconcileTargetProxyDrift(targetProxy, spec *compute.TargetTcpProxy) error {
+	if targetProxy.Service != spec.Service {
+		req := &compute.TargetTcpProxiesSetBackendServiceRequest{Service: spec.Service}
+		op, err := s.runOperation(path.Join("global", "targetTcpProxies", targetProxy.Name), "setBackendService", func() (*compute.Operation, error) {
+			return s.targetproxies.SetBackendService(s.scope.Project(), targetProxy.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set target proxy backend service")
+		}
+		s.recordDriftCorrected("target proxy", targetProxy.Name, "backend service changed", op)
+	}
+
+	if targetProxy.ProxyHeader != spec.ProxyHeader {
+		req := &compute.TargetTcpProxiesSetProxyHeaderRequest{ProxyHeader: spec.ProxyHeader}
+		op, err := s.runOperation(path.Join("global", "targetTcpProxies", targetProxy.Name), "setProxyHeader", func() (*compute.Operation, error) {
+			return s.targetproxies.SetProxyHeader(s.scope.Project(), targetProxy.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to set target proxy header")
+		}
+		s.recordDriftCorrected("target proxy", targetProxy.Name, "proxy header changed", op)
+	}
+
+	return nil
+}
+
+// ReconcileBackendGroups records the API server instance groups or network endpoint groups, depending on
+// the backend type of the load balancer. There are no groups without load balancer.
+func (s *Service) ReconcileBackendGroups() error {
+	if s.scope.LoadBalancerType() == infrav1.LoadBalancerTypeNone {
+		return nil
+	}
+	if s.scope.LoadBalancerBackendType() == infrav1.LoadBalancerBackendNetworkEndpointGroup {
+		return s.ReconcileNetworkEndpointGroups()
+	}
+
+	return s.ReconcileInstanceGroups()
+}
+
+// DeleteBackendGroups deletes the API server instance groups or network endpoint groups, depending on
+// the backend type of the load balancer.
+func (s *Service) DeleteBackendGroups() error {
+	if s.scope.LoadBalancerBackendType() == infrav1.LoadBalancerBackendNetworkEndpointGroup {
+		return s.DeleteNetworkEndpointGroups()
+	}
+
+	return s.DeleteInstanceGroups()
+}
+
+// UpdateBackendServices updates the backend services for a instance group or network endpoint group.
 func (s *Service) UpdateBackendServices() error {
-	// Refresh the instance groups available.
-	if err := s.ReconcileInstanceGroups(); err != nil {
+	// Refresh the groups available.
+	if err := s.ReconcileBackendGroups(); err != nil {
 		return err
 	}
 
+	if s.scope.LoadBalancerScheme() == infrav1.LoadBalancerSchemeInternal {
+		return s.updateRegionalBackendServices()
+	}
+
 	// Retrieve the spec and the current backend service.
 	backendServiceSpec := s.getAPIServerBackendServiceSpec()
 	backendService, err := s.backendservices.Get(s.scope.Project(), backendServiceSpec.Name).Do()
 	if err != nil {
 		return err
 	}
+	if err := s.ensureOwned("backend service", path.Join("global", "backendServices", backendService.Name), backendService.Description); err != nil {
+		return err
+	}
 
 	// Update backend service if the list of backends has changed in the spec.
-	// This might happen if new instance groups for the control plane api server
-	// are created in additional zones.
-	if len(backendService.Backends) != len(backendServiceSpec.Backends) {
+	// This happens when the control plane enters or leaves zones, creating or
+	// deleting their groups.
+	if !equalStringSets(backendGroups(backendService.Backends), backendGroups(backendServiceSpec.Backends)) {
 		backendService.Backends = backendServiceSpec.Backends
-		op, err := s.backendservices.Update(s.scope.Project(), backendService.Name, backendService).Do()
-		if err != nil {
-			return errors.Wrapf(err, "failed to update backend service")
-		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		if _, err := s.runOperation(path.Join("global", "backendServices", backendService.Name), "update", func() (*compute.Operation, error) {
+			return s.backendservices.Update(s.scope.Project(), backendService.Name, backendService).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to update backend service")
 		}
 	}
@@ -187,65 +385,222 @@ func (s *Service) UpdateBackendServices() error {
 }
 
 // DeleteLoadbalancers deletes LoadBalancers.
+// The components are deleted by name, so that they are cleaned up even if they are not recorded
+// in the status, e.g. after the cluster was moved by clusterctl which doesn't move the status,
+// as long as their description or labels mark them as owned by the cluster.
 func (s *Service) DeleteLoadbalancers() error {
-	// Delete Forwarding Rules.
-	if s.scope.Network().APIServerForwardingRule != nil {
-		name := path.Base(*s.scope.Network().APIServerForwardingRule)
-		op, err := s.forwardingrules.Delete(s.scope.Project(), name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete forwarding rules")
+	switch s.scope.LoadBalancerType() {
+	case infrav1.LoadBalancerTypeNone:
+		return nil
+	case infrav1.LoadBalancerTypeTargetInstance:
+		return s.deleteTargetInstanceLoadbalancer()
+	}
+	if s.scope.LoadBalancerScheme() == infrav1.LoadBalancerSchemeInternal {
+		return s.deleteInternalLoadbalancer()
+	}
+
+	name := s.apiServerLoadBalancerName()
+
+	// Delete Forwarding Rules, the IPv6 one being deleted whatever the stack type.
+	if err := s.deleteIPv6Frontend(s.scope.ShouldRetain(infrav1.RetainAPIServerAddress)); err != nil {
+		return err
+	}
+	if err := s.runOwnedDeleteOperation("forwarding rule", path.Join("global", "forwardingRules", name), func() (string, error) {
+		return s.describeForwardingRule(name)
+	}, func() (*compute.Operation, error) {
+		return s.forwardingrules.Delete(s.scope.Project(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete forwarding rules")
+	}
+	s.scope.Network().APIServerForwardingRule = nil
+
+	// The global IP and the target proxy were only used by the forwarding rule, delete them concurrently.
+	if err := reconciler.RunParallel(reconciler.DefaultParallelism,
+		func() error {
+			if s.scope.ShouldRetain(infrav1.RetainAPIServerAddress) {
+				s.recordRetained("global address", name)
+			} else {
+				if err := s.runOwnedDeleteOperation("global address", path.Join("global", "addresses", name), func() (string, error) {
+					return s.describeAddress(name)
+				}, func() (*compute.Operation, error) {
+					return s.addresses.Delete(s.scope.Project(), name).Do()
+				}); err != nil {
+					return errors.Wrapf(err, "failed to delete globalAddress resource")
+				}
+			}
+			s.scope.Network().APIServerAddress = nil
+			s.scope.Network().APIServerAddressSelfLink = nil
+
+			return nil
+		},
+		func() error {
+			if err := s.runOwnedDeleteOperation("target proxy", path.Join("global", "targetTcpProxies", name), func() (string, error) {
+				targetProxy, err := s.targetproxies.Get(s.scope.Project(), name).Do()
+				if err != nil {
+					return "", err
+				}
+
+				return targetProxy.Description, nil
+			}, func() (*compute.Operation, error) {
+				return s.targetproxies.Delete(s.scope.Project(), name).Do()
+			}); err != nil {
+				return errors.Wrapf(err, "failed to delete target proxy")
+			}
+			s.scope.Network().APIServerTargetProxy = nil
+
+			return nil
+		},
+	); err != nil {
+		return err
+	}
+
+	// Delete Backend Service.
+	if err := s.runOwnedDeleteOperation("backend service", path.Join("global", "backendServices", name), func() (string, error) {
+		backendService, err := s.backendservices.Get(s.scope.Project(), name).Do()
+		if err != nil {
+			return "", err
 		}
 
-		s.scope.Network().APIServerForwardingRule = nil
+		return backendService.Description, nil
+	}, func() (*compute.Operation, error) {
+		return s.backendservices.Delete(s.scope.Project(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete backend service")
+	}
+	s.scope.Network().APIServerBackendService = nil
+
+	// Delete Health Check.
+	if err := s.deleteHealthCheck(name); err != nil {
+		return err
 	}
+	s.scope.Network().APIServerHealthCheck = nil
 
-	// Delete Global IP.
-	if s.scope.Network().APIServerAddress != nil {
-		name := s.getAPIServerIPAddressSpec().Name
-		op, err := s.addresses.Delete(s.scope.Project(), name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete globalAddress resource")
+	return nil
+}
+
+// deleteHealthCheck deletes the health check of the load balancer if it's owned by the cluster.
+func (s *Service) deleteHealthCheck(name string) error {
+	if err := s.runOwnedDeleteOperation("health check", path.Join("global", "healthChecks", name), func() (string, error) {
+		healthCheck, err := s.healthchecks.Get(s.scope.Project(), name).Do()
+		if err != nil {
+			return "", err
 		}
-		s.scope.Network().APIServerAddress = nil
+
+		return healthCheck.Description, nil
+	}, func() (*compute.Operation, error) {
+		return s.healthchecks.Delete(s.scope.Project(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete health check")
+	}
+
+	return nil
+}
+
+// describeForwardingRule returns the ownership marker of the global forwarding rule, whose ownership is marked by
+// its labels.
+func (s *Service) describeForwardingRule(name string) (string, error) {
+	forwardingRule, err := s.forwardingrules.Get(s.scope.Project(), name).Do()
+	if err != nil {
+		return "", err
+	}
+
+	return s.labelledOwnershipMarker(forwardingRule.Labels), nil
+}
+
+// describeAddress returns the description of the global address.
+func (s *Service) describeAddress(name string) (string, error) {
+	address, err := s.addresses.Get(s.scope.Project(), name).Do()
+	if err != nil {
+		return "", err
 	}
 
-	// Delete Target Proxy.
-	if s.scope.Network().APIServerTargetProxy != nil {
-		name := path.Base(*s.scope.Network().APIServerTargetProxy)
-		op, err := s.targetproxies.Delete(s.scope.Project(), name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete target proxy")
+	return address.Description, nil
+}
+
+// GetAPIServerBackendsHealth returns the number of healthy API server backends of the load balancer,
+// and the total number of backends.
+func (s *Service) GetAPIServerBackendsHealth() (healthy, total int, err error) {
+	for _, group := range s.apiServerBackendGroups() {
+		res, err := s.getBackendServiceHealth(group)
+		if err != nil {
+			return 0, 0, errors.Wrapf(err, "failed to get the health of the API server backends")
+		}
+		for _, status := range res.HealthStatus {
+			total++
+			if status.HealthState == "HEALTHY" {
+				healthy++
+			}
 		}
-		s.scope.Network().APIServerTargetProxy = nil
 	}
 
-	// Delete Backend Service.
-	if s.scope.Network().APIServerBackendService != nil {
-		name := path.Base(*s.scope.Network().APIServerBackendService)
-		op, err := s.backendservices.Delete(s.scope.Project(), name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete backend service")
+	return healthy, total, nil
+}
+
+// CountOtherHealthyAPIServerBackends returns the number of API server backends reported healthy by the load
+// balancer, other than the instance.
+func (s *Service) CountOtherHealthyAPIServerBackends(i *compute.Instance) (int, error) {
+	healthy := 0
+	for _, group := range s.apiServerBackendGroups() {
+		res, err := s.getBackendServiceHealth(group)
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to get the health of the API server backends")
+		}
+		for _, status := range res.HealthStatus {
+			if status.Instance != i.SelfLink && status.HealthState == "HEALTHY" {
+				healthy++
+			}
 		}
-		s.scope.Network().APIServerBackendService = nil
 	}
 
-	// Delete Health Check.
-	if s.scope.Network().APIServerHealthCheck != nil {
-		name := path.Base(*s.scope.Network().APIServerHealthCheck)
-		op, err := s.healthchecks.Delete(s.scope.Project(), name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete health check")
+	return healthy, nil
+}
+
+// GetAPIServerBackendHealth returns the health state of the instance in the API server group,
+// e.g. HEALTHY, as reported by the load balancer, or an empty string if it isn't reported yet.
+func (s *Service) GetAPIServerBackendHealth(group string, i *compute.Instance) (string, error) {
+	res, err := s.getBackendServiceHealth(group)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get the health of the API server backends")
+	}
+	for _, status := range res.HealthStatus {
+		if status.Instance == i.SelfLink {
+			return status.HealthState, nil
 		}
-		s.scope.Network().APIServerHealthCheck = nil
 	}
 
-	return nil
+	return "", nil
+}
+
+// getBackendServiceHealth returns the health of the group reported by the global backend service,
+// or the regional one of an Internal load balancer.
+func (s *Service) getBackendServiceHealth(group string) (*compute.BackendServiceGroupHealth, error) {
+	ref := &compute.ResourceGroupReference{Group: group}
+	if s.scope.LoadBalancerScheme() == infrav1.LoadBalancerSchemeInternal {
+		return s.regionbackendservices.GetHealth(s.scope.Project(), s.scope.Region(), s.apiServerLoadBalancerName(), ref).Do()
+	}
+
+	return s.backendservices.GetHealth(s.scope.Project(), s.apiServerLoadBalancerName(), ref).Do()
+}
+
+// apiServerBackendGroups returns the API server groups of the backend type by zone.
+func (s *Service) apiServerBackendGroups() map[string]string {
+	if s.scope.LoadBalancerBackendType() == infrav1.LoadBalancerBackendNetworkEndpointGroup {
+		return s.scope.Network().APIServerNetworkEndpointGroups
+	}
+
+	return s.scope.Network().APIServerInstanceGroups
+}
+
+// apiServerLoadBalancerName returns the name shared by the components of the API server load balancer.
+func (s *Service) apiServerLoadBalancerName() string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), infrav1.APIServerRoleTagValue))
 }
 
 func (s *Service) getAPIServerHealthCheckSpec() *compute.HealthCheck {
-	return &compute.HealthCheck{
-		Name: fmt.Sprintf("%s-%s", s.scope.Name(), infrav1.APIServerRoleTagValue),
-		Type: APIServerLoadBalancerHealthCheckProtocol,
+	res := &compute.HealthCheck{
+		Name:        s.apiServerLoadBalancerName(),
+		Description: s.ownershipMarker(),
+		Type:        APIServerLoadBalancerHealthCheckProtocol,
 		SslHealthCheck: &compute.SSLHealthCheck{
 			Port:              s.scope.LoadBalancerBackendPort(),
 			PortSpecification: "USE_FIXED_PORT",
@@ -255,11 +610,21 @@ func (s *Service) getAPIServerHealthCheckSpec() *compute.HealthCheck {
 		HealthyThreshold:   5,
 		UnhealthyThreshold: 3,
 	}
+
+	if tuning := s.scope.LoadBalancerHealthCheck(); tuning != nil {
+		res.CheckIntervalSec = pointer.Int64Deref(tuning.CheckIntervalSec, res.CheckIntervalSec)
+		res.TimeoutSec = pointer.Int64Deref(tuning.TimeoutSec, res.TimeoutSec)
+		res.HealthyThreshold = pointer.Int64Deref(tuning.HealthyThreshold, res.HealthyThreshold)
+		res.UnhealthyThreshold = pointer.Int64Deref(tuning.UnhealthyThreshold, res.UnhealthyThreshold)
+	}
+
+	return res
 }
 
 func (s *Service) getAPIServerBackendServiceSpec() *compute.BackendService {
 	res := &compute.BackendService{
-		Name:                fmt.Sprintf("%s-%s", s.scope.Name(), infrav1.APIServerRoleTagValue),
+		Name:                s.apiServerLoadBalancerName(),
+		Description:         s.ownershipMarker(),
 		LoadBalancingScheme: APIServerLoadBalancerScheme,
 		PortName:            APIServerLoadBalancerBackendPortName,
 		Protocol:            APIServerLoadBalancerProtocol,
@@ -267,6 +632,23 @@ func (s *Service) getAPIServerBackendServiceSpec() *compute.BackendService {
 		HealthChecks: []string{
 			*s.scope.Network().APIServerHealthCheck,
 		},
+		SessionAffinity:    s.scope.LoadBalancerSessionAffinity(),
+		ConnectionDraining: s.getAPIServerConnectionDrainingSpec(),
+	}
+
+	// The network endpoints are balanced by connection, their port is the one of the endpoint rather than
+	// a named port of an instance group.
+	if s.scope.LoadBalancerBackendType() == infrav1.LoadBalancerBackendNetworkEndpointGroup {
+		res.PortName = ""
+		for _, groupSelfLink := range s.scope.Network().APIServerNetworkEndpointGroups {
+			res.Backends = append(res.Backends, &compute.Backend{
+				BalancingMode:             "CONNECTION",
+				MaxConnectionsPerEndpoint: APIServerLoadBalancerMaxConnectionsPerEndpoint,
+				Group:                     groupSelfLink,
+			})
+		}
+
+		return res
 	}
 
 	for _, groupSelfLink := range s.scope.Network().APIServerInstanceGroups {
@@ -279,9 +661,19 @@ func (s *Service) getAPIServerBackendServiceSpec() *compute.BackendService {
 	return res
 }
 
+// getAPIServerConnectionDrainingSpec returns the connection draining of the API server backend service, the timeout
+// being sent even when it's 0 to disable the draining.
+func (s *Service) getAPIServerConnectionDrainingSpec() *compute.ConnectionDraining {
+	return &compute.ConnectionDraining{
+		DrainingTimeoutSec: s.scope.LoadBalancerConnectionDrainingTimeout(),
+		ForceSendFields:    []string{"DrainingTimeoutSec"},
+	}
+}
+
 func (s *Service) getAPIServerTargetProxySpec() *compute.TargetTcpProxy {
 	return &compute.TargetTcpProxy{
-		Name:        fmt.Sprintf("%s-%s", s.scope.Name(), infrav1.APIServerRoleTagValue),
+		Name:        s.apiServerLoadBalancerName(),
+		Description: s.ownershipMarker(),
 		ProxyHeader: APIServerLoadBalancerProxyHeader,
 		Service:     *s.scope.Network().APIServerBackendService,
 	}
@@ -289,7 +681,8 @@ func (s *Service) getAPIServerTargetProxySpec() *compute.TargetTcpProxy {
 
 func (s *Service) getAPIServerIPAddressSpec() *compute.Address {
 	return &compute.Address{
-		Name:        fmt.Sprintf("%s-%s", s.scope.Name(), infrav1.APIServerRoleTagValue),
+		Name:        s.apiServerLoadBalancerName(),
+		Description: s.namespacedOwnershipMarker(),
 		AddressType: APIServerLoadBalancerScheme,
 		IpVersion:   APIServerLoadBalancerIPVersion,
 	}
@@ -299,11 +692,12 @@ func (s *Service) getAPIServerForwardingRuleSpec() *compute.ForwardingRule {
 	frontendPortRange := fmt.Sprintf("%d-%d", s.scope.LoadBalancerFrontendPort(), s.scope.LoadBalancerFrontendPort())
 
 	return &compute.ForwardingRule{
-		Name:                fmt.Sprintf("%s-%s", s.scope.Name(), infrav1.APIServerRoleTagValue),
+		Name:                s.apiServerLoadBalancerName(),
 		IPAddress:           *s.scope.Network().APIServerAddress,
 		IPProtocol:          APIServerLoadBalancerProtocol,
 		LoadBalancingScheme: APIServerLoadBalancerScheme,
 		PortRange:           frontendPortRange,
 		Target:              *s.scope.Network().APIServerTargetProxy,
+		Labels:              s.ownershipLabels(infrav1.APIServerRoleTagValue),
 	}
 }
@@ -0,0 +1,290 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileLoadbalancers(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	network := s.scope.Network()
+	g.Expect(network.FirewallRules).To(HaveLen(2))
+	g.Expect(network.APIServerAddress).NotTo(BeNil())
+	g.Expect(network.APIServerForwardingRule).NotTo(BeNil())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(HaveLen(1))
+
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(s.DeleteFirewalls()).To(Succeed())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/backendServices")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(BeEmpty())
+}
+
+func TestReconcileLoadbalancersAfterMove(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup func(spec *infrav1.GCPClusterSpec)
+	}{
+		{
+			name: "dual-stack Proxy",
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.StackType = infrav1.StackTypeIPv4IPv6
+				spec.Network.AutoCreateSubnetworks = pointer.BoolPtr(false)
+				spec.Network.Subnets = infrav1.Subnets{{Name: "my-subnet", CidrBlock: "10.0.0.0/24", Region: "us-central1"}}
+			},
+		},
+		{
+			name: "Internal",
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.Scheme = infrav1.LoadBalancerSchemeInternal
+				spec.MachineDefaults = &infrav1.MachineDefaults{Subnet: pointer.StringPtr("my-subnet")}
+			},
+		},
+		{
+			name: "TargetInstance",
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.Type = infrav1.LoadBalancerTypeTargetInstance
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := fakecloud.NewCloud()
+			defer c.Close()
+
+			params := newTestClusterScopeParams(g, c)
+			tt.setup(&params.GCPCluster.Spec)
+			s := NewService(newTestClusterScopeFromParams(g, params))
+			g.Expect(s.ReconcileNetwork()).To(Succeed())
+			g.Expect(s.ReconcileFirewalls()).To(Succeed())
+			g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+			// clusterctl move doesn't move the status, the reconcile of the moved cluster finds the existing
+			// resources, records them in the status and the inventory again, and creates or deletes none.
+			moved := newTestClusterScopeParams(g, c)
+			moved.GCPCluster.Spec = *params.GCPCluster.Spec.DeepCopy()
+			s = NewService(newTestClusterScopeFromParams(g, moved))
+			testEvents.Messages()
+			g.Expect(s.ReconcileNetwork()).To(Succeed())
+			g.Expect(s.ReconcileFirewalls()).To(Succeed())
+			g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+			g.Expect(testEvents.Messages()).NotTo(ContainElement(MatchRegexp(`SuccessfulCreate|SuccessfulDelete|AdoptedResource`)))
+			g.Expect(moved.GCPCluster.Status).To(Equal(params.GCPCluster.Status))
+		})
+	}
+}
+
+func TestNoLoadBalancer(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.LoadBalancer.Type = infrav1.LoadBalancerTypeNone
+	params.GCPCluster.Spec.ControlPlaneEndpoint.Host = "api.example.com"
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileBackendGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	// Neither the load balancer nor its firewall rules are created.
+	g.Expect(c.List("projects/my-project/global/addresses")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/regions/us-central1/addresses")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/backendServices")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/healthChecks")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instanceGroups")).To(BeEmpty())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-clients", nil)).To(BeFalse())
+	g.Expect(s.scope.Network().APIServerAddress).To(BeNil())
+
+	// The control plane instances are created without the address of a load balancer.
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-control-plane", Namespace: "default", Labels: map[string]string{clusterv1.MachineControlPlaneLabelName: ""}},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	})
+	g.Expect(instance).NotTo(BeNil())
+
+	healthy, total, err := s.GetAPIServerBackendsHealth()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(healthy).To(BeZero())
+	g.Expect(total).To(BeZero())
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(s.DeleteBackendGroups()).To(Succeed())
+}
+
+func TestLoadBalancerTuning(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.LoadBalancer.HealthCheck = &infrav1.LoadBalancerHealthCheckSpec{
+		CheckIntervalSec:   pointer.Int64Ptr(5),
+		UnhealthyThreshold: pointer.Int64Ptr(2),
+	}
+	params.GCPCluster.Spec.LoadBalancer.SessionAffinity = pointer.StringPtr("CLIENT_IP")
+	params.GCPCluster.Spec.LoadBalancer.SecurityPolicy = pointer.StringPtr("my-policy")
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	healthCheck := &compute.HealthCheck{}
+	g.Expect(c.Get("projects/my-project/global/healthChecks/my-cluster-apiserver", healthCheck)).To(BeTrue())
+	g.Expect(healthCheck.CheckIntervalSec).To(Equal(int64(5)))
+	g.Expect(healthCheck.TimeoutSec).To(Equal(int64(5)))
+	g.Expect(healthCheck.HealthyThreshold).To(Equal(int64(5)))
+	g.Expect(healthCheck.UnhealthyThreshold).To(Equal(int64(2)))
+	backendService := &compute.BackendService{}
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.SessionAffinity).To(Equal("CLIENT_IP"))
+	g.Expect(backendService.ConnectionDraining.DrainingTimeoutSec).To(BeZero())
+	g.Expect(backendService.SecurityPolicy).To(Equal("projects/my-project/global/securityPolicies/my-policy"))
+
+	// The changes of the tuning update the existing health check and backend service.
+	s.scope.GCPCluster.Spec.LoadBalancer.HealthCheck.TimeoutSec = pointer.Int64Ptr(2)
+	s.scope.GCPCluster.Spec.LoadBalancer.SessionAffinity = nil
+	s.scope.GCPCluster.Spec.LoadBalancer.ConnectionDrainingTimeoutSec = pointer.Int64Ptr(30)
+	s.scope.GCPCluster.Spec.LoadBalancer.SecurityPolicy = pointer.StringPtr("my-other-policy")
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	g.Expect(c.Get("projects/my-project/global/healthChecks/my-cluster-apiserver", healthCheck)).To(BeTrue())
+	g.Expect(healthCheck.TimeoutSec).To(Equal(int64(2)))
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.SessionAffinity).To(Equal("NONE"))
+	g.Expect(backendService.ConnectionDraining.DrainingTimeoutSec).To(Equal(int64(30)))
+	g.Expect(backendService.SecurityPolicy).To(Equal("projects/my-project/global/securityPolicies/my-other-policy"))
+
+	// The security policy is left as is once removed from the spec.
+	s.scope.GCPCluster.Spec.LoadBalancer.SecurityPolicy = nil
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.SecurityPolicy).To(Equal("projects/my-project/global/securityPolicies/my-other-policy"))
+}
+
+func TestLoadBalancerTuningMaintenanceWindow(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	// The window opens on Saturdays at 22:00 UTC for 4h, the reconcile runs on Monday.
+	now := time.Date(2021, time.June, 7, 10, 0, 0, 0, time.UTC)
+	params := newTestClusterScopeParams(g, c)
+	params.Now = func() time.Time { return now }
+	params.GCPCluster.Spec.MaintenanceWindow = &infrav1.MaintenanceWindowSpec{
+		Days:      []infrav1.Weekday{"Saturday"},
+		StartTime: "22:00",
+		Duration:  metav1.Duration{Duration: 4 * time.Hour},
+	}
+	clusterScope := newTestClusterScopeFromParams(g, params)
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	// The change of the health check, which may mark the backends unhealthy, is deferred to the window.
+	params.GCPCluster.Spec.LoadBalancer.HealthCheck = &infrav1.LoadBalancerHealthCheckSpec{UnhealthyThreshold: pointer.Int64Ptr(1)}
+	clusterScope = newTestClusterScopeFromParams(g, params)
+	g.Expect(NewService(clusterScope).ReconcileLoadbalancers()).To(Succeed())
+	g.Expect(conditions.GetMessage(clusterScope.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)).To(BeEmpty())
+	g.Expect(clusterScope.SetDisruptiveChangesApplied()).NotTo(BeZero())
+	g.Expect(conditions.GetMessage(clusterScope.GCPCluster, infrav1.DisruptiveChangesAppliedCondition)).To(ContainSubstring(
+		`health check "my-cluster-apiserver": timings changed`))
+	healthCheck := &compute.HealthCheck{}
+	g.Expect(c.Get("projects/my-project/global/healthChecks/my-cluster-apiserver", healthCheck)).To(BeTrue())
+	g.Expect(healthCheck.UnhealthyThreshold).To(Equal(int64(3)))
+
+	now = time.Date(2021, time.June, 12, 23, 0, 0, 0, time.UTC)
+	clusterScope = newTestClusterScopeFromParams(g, params)
+	g.Expect(NewService(clusterScope).ReconcileLoadbalancers()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/healthChecks/my-cluster-apiserver", healthCheck)).To(BeTrue())
+	g.Expect(healthCheck.UnhealthyThreshold).To(Equal(int64(1)))
+}
+
+func TestUpdateBackendServicesNotOwned(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	// The backends of a backend service which isn't owned by the cluster are never updated.
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerHealthCheck = pointer.StringPtr("my-cluster-apiserver")
+	s := NewService(clusterScope)
+	c.Put("projects/my-project/global/backendServices/my-cluster-apiserver", &compute.BackendService{Name: "my-cluster-apiserver"})
+	c.Put("projects/my-project/zones/us-central1-a/instanceGroups/my-cluster-apiserver-us-central1-a", &compute.InstanceGroup{Name: "my-cluster-apiserver-us-central1-a"})
+	g.Expect(s.UpdateBackendServices()).To(MatchError(ContainSubstring("isn't owned by the cluster")))
+	backendService := &compute.BackendService{}
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.Backends).To(BeEmpty())
+}
+
+func TestGetAPIServerBackendsHealth(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	healthy, total, err := s.GetAPIServerBackendsHealth()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(healthy).To(BeZero())
+	g.Expect(total).To(BeZero())
+
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine-0", &compute.Instance{})
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine-1", map[string]interface{}{"health": "UNHEALTHY"})
+	group := s.APIServerInstanceGroupName("us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instanceGroups/"+group, &compute.InstanceGroup{Name: group})
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	_, err = s.instancegroups.AddInstances("my-project", "us-central1-a", group, &compute.InstanceGroupsAddInstancesRequest{
+		Instances: []*compute.InstanceReference{
+			{Instance: c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-machine-0")},
+			{Instance: c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-machine-1")},
+		},
+	}).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+
+	healthy, total, err = s.GetAPIServerBackendsHealth()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(healthy).To(Equal(1))
+	g.Expect(total).To(Equal(2))
+}
@@ -0,0 +1,917 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/pkg/errors"
+	computealpha "google.golang.org/api/compute/v0.alpha"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/integer"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+// ReconcileMachinePool creates the instance template and the regional managed instance group of the GCPMachinePool,
+// resizes the group to the replicas of the MachinePool, unless it's autoscaled, and has it replace its instances once
+// the template changed.
+// It records the provider IDs of the instances and returns true once the group runs all of them with the current
+// template, the templates it no longer uses being deleted then.
+func (s *Service) ReconcileMachinePool(scope *scope.MachinePoolScope) (bool, error) {
+	pool := scope.GCPMachinePool
+
+	if err := s.pollMachinePoolOperation(scope); err != nil {
+		return false, err
+	}
+
+	template, err := s.reconcileInstanceTemplate(scope)
+	if err != nil {
+		return false, err
+	}
+	pool.Status.InstanceTemplate = path.Base(template)
+
+	name := s.InstanceGroupManagerName(scope)
+	igm, err := s.regioninstancegroupmanagers.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		if igm, err = s.createInstanceGroupManager(scope, template); err != nil {
+			return false, err
+		}
+	case err != nil:
+		return false, errors.Wrapf(err, "failed to describe managed instance group %q", name)
+	}
+
+	// The instances are replaced by the group, a few at a time, once its template is changed. The bootstrap data
+	// rotates on its own though, the instances having joined the cluster are kept then, only the new ones are
+	// created with the new template.
+	if current := path.Base(igm.InstanceTemplate); current != path.Base(template) {
+		policy := proactiveUpdatePolicy()
+		if sameInstanceConfiguration(current, path.Base(template)) {
+			policy = opportunisticUpdatePolicy()
+		}
+		_, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+			return s.regioninstancegroupmanagers.Patch(s.scope.Project(), s.scope.Region(), name, &compute.InstanceGroupManager{
+				InstanceTemplate: template,
+				UpdatePolicy:     policy,
+			}).Do()
+		})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to update the instance template of managed instance group %q", name)
+		}
+		if policy.Type == "PROACTIVE" {
+			record.Eventf(pool, "RollingUpdate", "Replacing the instances of managed instance group %q with instance template %q",
+				name, path.Base(template))
+		} else {
+			record.Eventf(pool, "BootstrapDataUpdate", "Creating the new instances of managed instance group %q with instance template %q",
+				name, path.Base(template))
+		}
+		igm.InstanceTemplate = template
+		igm.UpdatePolicy = policy
+		igm.Status = nil
+	}
+
+	if pool.Spec.Autoscaling != nil && hasStandbyInstances(pool) {
+		return false, errors.New("the autoscaling of the machine pool can't be combined with its warm pool or suspension")
+	}
+	if err := s.reconcileAutoscaler(scope, igm); err != nil {
+		return false, err
+	}
+
+	// The autoscaler resizes the group on its own, the replicas of the MachinePool are ignored then.
+	replicas, settled := scope.Replicas(), true
+	switch {
+	case hasStandbyInstances(pool):
+		if settled, err = s.reconcileStandbyInstances(scope, igm); err != nil {
+			return false, err
+		}
+	case pool.Spec.Autoscaling == nil && igm.TargetSize != replicas:
+		if err := s.resizeMachinePool(scope, igm, replicas); err != nil {
+			return false, err
+		}
+	}
+
+	running, templates, err := s.reconcileProviderIDs(scope, name)
+	if err != nil {
+		return false, err
+	}
+
+	// The version target is only reached by an opportunistic update once all the instances have been recreated.
+	reached := igm.Status != nil && igm.Status.VersionTarget != nil && igm.Status.VersionTarget.IsReached ||
+		igm.UpdatePolicy != nil && igm.UpdatePolicy.Type == "OPPORTUNISTIC"
+	// The target size of the group counts its stopped and suspended instances.
+	want := igm.TargetSize
+	if hasStandbyInstances(pool) {
+		want = replicas
+	}
+	ready := igm.Status != nil && igm.Status.IsStable && reached && settled && running == want
+	if !ready {
+		conditions.MarkFalse(pool, expinfrav1.InstanceGroupReadyCondition, expinfrav1.InstanceGroupUpdatingReason, clusterv1.ConditionSeverityInfo,
+			"%d of %d instances running", running, want)
+		return false, nil
+	}
+	conditions.MarkTrue(pool, expinfrav1.InstanceGroupReadyCondition)
+
+	return true, s.deleteInstanceTemplates(scope, templates.Insert(path.Base(template)))
+}
+
+// DeleteMachinePool deletes the autoscaler and the managed instance group of the GCPMachinePool along with its
+// instances, then its instance templates.
+func (s *Service) DeleteMachinePool(scope *scope.MachinePoolScope) error {
+	if err := s.pollMachinePoolOperation(scope); err != nil {
+		return err
+	}
+
+	name := s.InstanceGroupManagerName(scope)
+	if err := s.deleteAutoscaler(scope, name); err != nil {
+		return err
+	}
+
+	op, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+		return s.regioninstancegroupmanagers.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+	})
+	if err != nil && !gcperrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete managed instance group %q", name)
+	}
+	if op != nil {
+		record.Eventf(scope.GCPMachinePool, "SuccessfulDelete", "Deleted managed instance group %q%s", name, operationDetails(op))
+	}
+
+	return s.deleteInstanceTemplates(scope, sets.NewString())
+}
+
+// resizeMachinePool resizes the managed instance group of the GCPMachinePool.
+func (s *Service) resizeMachinePool(scope *scope.MachinePoolScope, igm *compute.InstanceGroupManager, size int64) error {
+	if _, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+		return s.regioninstancegroupmanagers.Resize(s.scope.Project(), s.scope.Region(), igm.Name, size).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to resize managed instance group %q", igm.Name)
+	}
+	record.Eventf(scope.GCPMachinePool, "SuccessfulResize", "Resized managed instance group %q from %d to %d instances", igm.Name, igm.TargetSize, size)
+	igm.TargetSize = size
+	igm.Status = nil
+
+	return nil
+}
+
+// stoppedInstanceStatuses and suspendedInstanceStatuses are the statuses of the stopped instances of the warm pools
+// and of the instances suspended on scale down, standbyInstanceStatuses both of them.
+var (
+	stoppedInstanceStatuses   = sets.NewString("STOPPING", "STOPPED", "TERMINATED")
+	suspendedInstanceStatuses = sets.NewString("SUSPENDING", "SUSPENDED")
+	standbyInstanceStatuses   = stoppedInstanceStatuses.Union(suspendedInstanceStatuses)
+)
+
+// hasStandbyInstances returns true if the managed instance group of the GCPMachinePool keeps stopped or suspended
+// instances besides its replicas.
+func hasStandbyInstances(pool *expinfrav1.GCPMachinePool) bool {
+	return pool.Spec.WarmPool != nil || pool.Spec.Suspension != nil
+}
+
+// reconcileStandbyInstances resizes the managed instance group of the GCPMachinePool to the replicas of the
+// MachinePool, the stopped instances of its warm pool and its suspended instances. The suspended, then the stopped
+// instances are resumed or started on scale up before new ones are created, and the instances in excess are stopped
+// into the warm pool, or else suspended, rather than deleted, once all of them joined the cluster. The group is
+// resized to replenish the warm pool, its new instances being stopped once joined, and the suspended instances beyond
+// the maximum or the retention of the suspension are deleted. A single change is made at a time, true being returned
+// once the group runs the replicas, the warm pool is full and no suspended instance is in excess.
+func (s *Service) reconcileStandbyInstances(scope *scope.MachinePoolScope, igm *compute.InstanceGroupManager) (bool, error) {
+	client, err := s.scope.AlphaCompute()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to reconcile the stopped and suspended instances")
+	}
+
+	var running, stopped, suspended []string
+	err = s.regioninstancegroupmanagers.ListManagedInstances(s.scope.Project(), s.scope.Region(), igm.Name).
+		Pages(context.TODO(), func(res *compute.RegionInstanceGroupManagersListInstancesResponse) error {
+			for _, i := range res.ManagedInstances {
+				switch {
+				case stoppedInstanceStatuses.Has(i.InstanceStatus):
+					stopped = append(stopped, i.Instance)
+				case suspendedInstanceStatuses.Has(i.InstanceStatus):
+					suspended = append(suspended, i.Instance)
+				default:
+					running = append(running, i.Instance)
+				}
+			}
+
+			return nil
+		})
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to list the instances of managed instance group %q", igm.Name)
+	}
+
+	pool := scope.GCPMachinePool
+	var size, maxSuspended int64
+	if pool.Spec.WarmPool != nil {
+		size = pool.Spec.WarmPool.Size
+	}
+	if pool.Spec.Suspension != nil {
+		maxSuspended = pool.Spec.Suspension.MaxReplicas
+	}
+	excess, missing := int64(len(running))-scope.Replicas(), size-int64(len(stopped))
+	switch {
+	case excess < 0 && len(suspended) > 0:
+		resumed := suspended[:integer.Int64Min(-excess, int64(len(suspended)))]
+		if _, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+			return computeOperation(client.RegionInstanceGroupManagers.ResumeInstances(s.scope.Project(), s.scope.Region(), igm.Name,
+				&computealpha.RegionInstanceGroupManagersResumeInstancesRequest{Instances: resumed}).Do())
+		}); err != nil {
+			return false, errors.Wrapf(err, "failed to resume the instances of managed instance group %q", igm.Name)
+		}
+		record.Eventf(pool, "SuccessfulResume", "Resumed %d suspended instances of managed instance group %q", len(resumed), igm.Name)
+	case excess < 0 && len(stopped) > 0:
+		started := stopped[:integer.Int64Min(-excess, int64(len(stopped)))]
+		if _, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+			return computeOperation(client.RegionInstanceGroupManagers.StartInstances(s.scope.Project(), s.scope.Region(), igm.Name,
+				&computealpha.RegionInstanceGroupManagersStartInstancesRequest{Instances: started}).Do())
+		}); err != nil {
+			return false, errors.Wrapf(err, "failed to start the instances of the warm pool of managed instance group %q", igm.Name)
+		}
+		record.Eventf(pool, "WarmPoolStart", "Started %d instances of the warm pool of managed instance group %q", len(started), igm.Name)
+	case excess < 0:
+		return false, s.resizeMachinePool(scope, igm, igm.TargetSize-excess)
+	case excess > 0 && (missing > 0 || int64(len(suspended)) < maxSuspended):
+		// The instances are only stopped or suspended once joined, as they would otherwise be started or resumed with
+		// expired bootstrap data.
+		if !s.joinedInstances(scope, running) {
+			return false, nil
+		}
+		if missing > 0 {
+			stopping := running[int64(len(running))-integer.Int64Min(excess, missing):]
+			if _, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+				return computeOperation(client.RegionInstanceGroupManagers.StopInstances(s.scope.Project(), s.scope.Region(), igm.Name,
+					&computealpha.RegionInstanceGroupManagersStopInstancesRequest{Instances: stopping}).Do())
+			}); err != nil {
+				return false, errors.Wrapf(err, "failed to stop the instances of managed instance group %q", igm.Name)
+			}
+			record.Eventf(pool, "WarmPoolStop", "Stopped %d instances of managed instance group %q into its warm pool", len(stopping), igm.Name)
+			break
+		}
+		suspending := running[int64(len(running))-integer.Int64Min(excess, maxSuspended-int64(len(suspended))):]
+		if _, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+			return computeOperation(client.RegionInstanceGroupManagers.SuspendInstances(s.scope.Project(), s.scope.Region(), igm.Name,
+				&computealpha.RegionInstanceGroupManagersSuspendInstancesRequest{Instances: suspending}).Do())
+		}); err != nil {
+			return false, errors.Wrapf(err, "failed to suspend the instances of managed instance group %q", igm.Name)
+		}
+		record.Eventf(pool, "SuccessfulSuspend", "Suspended %d instances of managed instance group %q", len(suspending), igm.Name)
+	case excess > 0:
+		return false, s.deleteManagedInstances(scope, igm, running[int64(len(running))-excess:])
+	case missing > 0:
+		return false, s.resizeMachinePool(scope, igm, igm.TargetSize+missing)
+	case missing < 0:
+		return false, s.deleteManagedInstances(scope, igm, stopped[size:])
+	case int64(len(suspended)) > maxSuspended:
+		return false, s.deleteManagedInstances(scope, igm, suspended[maxSuspended:])
+	default:
+		expired, err := s.expiredInstances(scope, suspended)
+		if err != nil {
+			return false, err
+		}
+		if len(expired) == 0 {
+			return true, nil
+		}
+		return false, s.deleteManagedInstances(scope, igm, expired)
+	}
+	igm.Status = nil
+
+	return false, nil
+}
+
+// expiredInstances returns the suspended instances of the GCPMachinePool suspended for longer than the retention of
+// its suspension, if any.
+func (s *Service) expiredInstances(scope *scope.MachinePoolScope, suspended []string) ([]string, error) {
+	suspension := scope.GCPMachinePool.Spec.Suspension
+	if suspension == nil || suspension.Retention == nil {
+		return nil, nil
+	}
+
+	var expired []string
+	for _, link := range suspended {
+		zone, name := path.Base(path.Dir(path.Dir(link))), path.Base(link)
+		instance, err := s.instances.Get(s.scope.Project(), zone, name).Do()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe instance %q", name)
+		}
+		suspendedAt, err := time.Parse(time.RFC3339, instance.LastSuspendedTimestamp)
+		if err == nil && time.Since(suspendedAt) > suspension.Retention.Duration {
+			expired = append(expired, link)
+		}
+	}
+
+	return expired, nil
+}
+
+// joinedInstances returns true if all the instances have joined the cluster, i.e. the MachinePool references
+// their nodes, which are named after them.
+func (s *Service) joinedInstances(scope *scope.MachinePoolScope, instances []string) bool {
+	nodes := sets.NewString()
+	for _, ref := range scope.MachinePool.Status.NodeRefs {
+		nodes.Insert(ref.Name)
+	}
+	for _, instance := range instances {
+		if !nodes.Has(path.Base(instance)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// deleteManagedInstances deletes the instances of the managed instance group of the GCPMachinePool, decreasing its
+// target size.
+func (s *Service) deleteManagedInstances(scope *scope.MachinePoolScope, igm *compute.InstanceGroupManager, instances []string) error {
+	if _, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+		return s.regioninstancegroupmanagers.DeleteInstances(s.scope.Project(), s.scope.Region(), igm.Name,
+			&compute.RegionInstanceGroupManagersDeleteInstancesRequest{Instances: instances}).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete the instances of managed instance group %q", igm.Name)
+	}
+	record.Eventf(scope.GCPMachinePool, "SuccessfulDelete", "Deleted %d instances of managed instance group %q", len(instances), igm.Name)
+	igm.TargetSize -= int64(len(instances))
+	igm.Status = nil
+
+	return nil
+}
+
+// defaultAutoscalingUtilizationPercent and defaultAutoscalingCooldownPeriod are the defaults of the autoscaling
+// policy of the GCPMachinePools, the ones of GCP, which are set explicitly so that the policy is compared as is.
+const (
+	defaultAutoscalingUtilizationPercent = 60
+	defaultAutoscalingCooldownPeriod     = 60
+)
+
+// reconcileAutoscaler creates the regional autoscaler of the managed instance group of the GCPMachinePool, named
+// after the group, updates its policy once the autoscaling of the GCPMachinePool changed, or deletes it once unset.
+func (s *Service) reconcileAutoscaler(scope *scope.MachinePoolScope, igm *compute.InstanceGroupManager) error {
+	spec := scope.GCPMachinePool.Spec.Autoscaling
+	if spec == nil {
+		return s.deleteAutoscaler(scope, igm.Name)
+	}
+	if spec.MinReplicas > spec.MaxReplicas {
+		return errors.Errorf("the %d minimum replicas of the autoscaling exceed its %d maximum replicas", spec.MinReplicas, spec.MaxReplicas)
+	}
+
+	input := &compute.Autoscaler{
+		Name:              igm.Name,
+		Description:       s.machinePoolMarker(scope),
+		Target:            igm.SelfLink,
+		AutoscalingPolicy: autoscalingPolicy(spec),
+	}
+	autoscaler, err := s.regionautoscalers.Get(s.scope.Project(), s.scope.Region(), igm.Name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		_, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+			return s.regionautoscalers.Insert(s.scope.Project(), s.scope.Region(), input).Do()
+		})
+		if wait.IsTimeout(err) {
+			return err
+		}
+		if err != nil {
+			record.Warnf(scope.GCPMachinePool, "FailedCreate", "Failed to create autoscaler %q: %v", igm.Name, err)
+			return errors.Wrapf(err, "failed to create autoscaler %q", igm.Name)
+		}
+		record.Eventf(scope.GCPMachinePool, "SuccessfulCreate", "Created autoscaler %q of managed instance group %q with %d to %d instances",
+			igm.Name, igm.Name, spec.MinReplicas, spec.MaxReplicas)
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe autoscaler %q", igm.Name)
+	}
+
+	if autoscalingPolicyMatches(autoscaler.AutoscalingPolicy, input.AutoscalingPolicy) {
+		return nil
+	}
+	if _, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+		return s.regionautoscalers.Update(s.scope.Project(), s.scope.Region(), input).Autoscaler(igm.Name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to update autoscaler %q", igm.Name)
+	}
+	record.Eventf(scope.GCPMachinePool, "AutoscalingUpdate", "Updated autoscaler %q of managed instance group %q to %d to %d instances",
+		igm.Name, igm.Name, spec.MinReplicas, spec.MaxReplicas)
+
+	return nil
+}
+
+// deleteAutoscaler deletes the regional autoscaler of the managed instance group of the GCPMachinePool, if any.
+func (s *Service) deleteAutoscaler(scope *scope.MachinePoolScope, name string) error {
+	op, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+		return s.regionautoscalers.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+	})
+	if gcperrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to delete autoscaler %q", name)
+	}
+	record.Eventf(scope.GCPMachinePool, "SuccessfulDelete", "Deleted autoscaler %q%s", name, operationDetails(op))
+
+	return nil
+}
+
+// autoscalingPolicy returns the policy of the autoscaler of the managed instance group of a GCPMachinePool.
+func autoscalingPolicy(spec *expinfrav1.MachinePoolAutoscaling) *compute.AutoscalingPolicy {
+	utilization, cooldown := spec.CPUUtilizationPercent, spec.CooldownPeriodSeconds
+	if utilization == 0 {
+		utilization = defaultAutoscalingUtilizationPercent
+	}
+	if cooldown == 0 {
+		cooldown = defaultAutoscalingCooldownPeriod
+	}
+
+	return &compute.AutoscalingPolicy{
+		Mode:              "ON",
+		MinNumReplicas:    spec.MinReplicas,
+		MaxNumReplicas:    spec.MaxReplicas,
+		CoolDownPeriodSec: cooldown,
+		CpuUtilization:    &compute.AutoscalingPolicyCpuUtilization{UtilizationTarget: float64(utilization) / 100},
+		ForceSendFields:   []string{"MinNumReplicas"},
+	}
+}
+
+// autoscalingPolicyMatches returns true if the policy of the autoscaler is the desired one.
+func autoscalingPolicyMatches(current, desired *compute.AutoscalingPolicy) bool {
+	return current != nil && current.Mode == desired.Mode &&
+		current.MinNumReplicas == desired.MinNumReplicas && current.MaxNumReplicas == desired.MaxNumReplicas &&
+		current.CoolDownPeriodSec == desired.CoolDownPeriodSec &&
+		current.CpuUtilization != nil && current.CpuUtilization.UtilizationTarget == desired.CpuUtilization.UtilizationTarget
+}
+
+// runMachinePoolOperation issues the operation on the managed instance group or the instance templates of the
+// GCPMachinePool, e.g. the resize of the group, and returns it once completed. The operation in progress is recorded
+// in the status of the GCPMachinePool, and a TimeoutError returned, instead of being waited for, so that the next
+// reconciles poll it with pollMachinePoolOperation.
+func (s *Service) runMachinePoolOperation(scope *scope.MachinePoolScope, issue func() (*compute.Operation, error)) (*compute.Operation, error) {
+	op, err := issue()
+	if err != nil {
+		return nil, err
+	}
+	if err := wait.PollComputeOperation(op); err != nil {
+		if wait.IsTimeout(err) {
+			scope.GCPMachinePool.Status.Operation = op.SelfLink
+		}
+		return nil, err
+	}
+
+	return op, nil
+}
+
+// pollMachinePoolOperation polls the operation in progress on the managed instance group or the instance templates
+// of the GCPMachinePool, if any, which is forgotten once completed. A TimeoutError is returned while it is in
+// progress, the error of the operation once it failed, the operation being issued again by the next reconciles.
+func (s *Service) pollMachinePoolOperation(scope *scope.MachinePoolScope) error {
+	pool := scope.GCPMachinePool
+	selfLink := pool.Status.Operation
+	if selfLink == "" {
+		return nil
+	}
+
+	op, err := wait.PollComputeOperationLink(s.scope.Compute, s.scope.Project(), selfLink)
+	switch {
+	case wait.IsTimeout(err):
+		return err
+	case op == nil && gcperrors.IsNotFound(err):
+		// The operation expired, the resources are looked up as is.
+		pool.Status.Operation = ""
+		return nil
+	case op == nil:
+		return errors.Wrapf(err, "failed to poll operation %s", selfLink)
+	}
+	pool.Status.Operation = ""
+
+	if err != nil {
+		record.Warnf(pool, "FailedUpdate", "Failed to %s %s: %v", op.OperationType, path.Base(op.TargetLink), err)
+		return errors.Wrapf(err, "failed to %s %s", op.OperationType, path.Base(op.TargetLink))
+	}
+	record.Eventf(pool, "SuccessfulUpdate", "Completed %s of %s%s", op.OperationType, path.Base(op.TargetLink), operationDetails(op))
+
+	return nil
+}
+
+// InstanceGroupManagerName returns the name of the managed instance group of the GCPMachinePool.
+func (s *Service) InstanceGroupManagerName(scope *scope.MachinePoolScope) string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), scope.Name()))
+}
+
+// machinePoolMarker returns the description of the managed instance group and of the instance templates of the
+// GCPMachinePool, which the templates are listed by.
+func (s *Service) machinePoolMarker(scope *scope.MachinePoolScope) string {
+	return fmt.Sprintf("%s/%s/%s", s.ownershipMarker(), scope.Namespace(), scope.Name())
+}
+
+// createInstanceGroupManager creates the regional managed instance group of the GCPMachinePool.
+func (s *Service) createInstanceGroupManager(scope *scope.MachinePoolScope, template string) (*compute.InstanceGroupManager, error) {
+	name := s.InstanceGroupManagerName(scope)
+	input := &compute.InstanceGroupManager{
+		Name:             name,
+		Description:      s.machinePoolMarker(scope),
+		BaseInstanceName: name,
+		InstanceTemplate: template,
+		TargetSize:       scope.Replicas(),
+		UpdatePolicy:     proactiveUpdatePolicy(),
+	}
+	zones := scope.Zones()
+	if accelerators := scope.GCPMachinePool.Spec.GuestAccelerators; len(accelerators) > 0 {
+		// The instances are only distributed across the zones offering the accelerators.
+		acceleratorZones, err := s.GetAcceleratorZones(accelerators)
+		if err != nil {
+			return nil, err
+		}
+		if len(zones) > 0 {
+			zones = sets.NewString(zones...).Intersection(sets.NewString(acceleratorZones...)).List()
+		} else {
+			zones = acceleratorZones
+		}
+		if len(zones) == 0 {
+			return nil, errors.Errorf("none of the zones %v of the machine pool offers its accelerators", scope.Zones())
+		}
+	}
+	if len(zones) > 0 {
+		input.DistributionPolicy = &compute.DistributionPolicy{}
+		for _, zone := range zones {
+			input.DistributionPolicy.Zones = append(input.DistributionPolicy.Zones, &compute.DistributionPolicyZoneConfiguration{
+				Zone: fmt.Sprintf("projects/%s/zones/%s", s.scope.Project(), zone),
+			})
+		}
+	}
+
+	op, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+		return s.regioninstancegroupmanagers.Insert(s.scope.Project(), s.scope.Region(), input).Do()
+	})
+	if wait.IsTimeout(err) {
+		return nil, err
+	}
+	if err != nil {
+		record.Warnf(scope.GCPMachinePool, "FailedCreate", "Failed to create managed instance group %q: %v", name, err)
+		return nil, errors.Wrapf(err, "failed to create managed instance group %q", name)
+	}
+	record.Eventf(scope.GCPMachinePool, "SuccessfulCreate", "Created managed instance group %q with %d instances%s", name, input.TargetSize, operationDetails(op))
+
+	igm, err := s.regioninstancegroupmanagers.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe managed instance group %q", name)
+	}
+
+	return igm, nil
+}
+
+// proactiveUpdatePolicy has the managed instance group replace its instances as soon as its template is changed.
+func proactiveUpdatePolicy() *compute.InstanceGroupManagerUpdatePolicy {
+	return &compute.InstanceGroupManagerUpdatePolicy{
+		Type:                       "PROACTIVE",
+		MinimalAction:              "REPLACE",
+		InstanceRedistributionType: "PROACTIVE",
+	}
+}
+
+// opportunisticUpdatePolicy has the managed instance group create its new instances with its template, the existing
+// ones being kept.
+func opportunisticUpdatePolicy() *compute.InstanceGroupManagerUpdatePolicy {
+	return &compute.InstanceGroupManagerUpdatePolicy{
+		Type:                       "OPPORTUNISTIC",
+		MinimalAction:              "REPLACE",
+		InstanceRedistributionType: "PROACTIVE",
+	}
+}
+
+// reconcileProviderIDs records the provider IDs of the instances of the managed instance group, but its stopped and
+// suspended instances, and returns the number of running instances along with the instance templates they
+// were created with.
+func (s *Service) reconcileProviderIDs(scope *scope.MachinePoolScope, name string) (int64, sets.String, error) {
+	var providerIDs []string
+	var running int64
+	templates := sets.NewString()
+	err := s.regioninstancegroupmanagers.ListManagedInstances(s.scope.Project(), s.scope.Region(), name).
+		Pages(context.TODO(), func(res *compute.RegionInstanceGroupManagersListInstancesResponse) error {
+			for _, i := range res.ManagedInstances {
+				// The stopped and suspended instances aren't nodes of the cluster.
+				if hasStandbyInstances(scope.GCPMachinePool) && standbyInstanceStatuses.Has(i.InstanceStatus) {
+					continue
+				}
+				zone := path.Base(path.Dir(path.Dir(i.Instance)))
+				providerIDs = append(providerIDs, fmt.Sprintf("gce://%s/%s/%s", s.scope.Project(), zone, path.Base(i.Instance)))
+				if i.InstanceStatus == "RUNNING" {
+					running++
+				}
+				if i.Version != nil && i.Version.InstanceTemplate != "" {
+					templates.Insert(path.Base(i.Version.InstanceTemplate))
+				}
+			}
+
+			return nil
+		})
+	if err != nil {
+		return 0, nil, errors.Wrapf(err, "failed to list the instances of managed instance group %q", name)
+	}
+	scope.GCPMachinePool.Spec.ProviderIDList = providerIDs
+	scope.GCPMachinePool.Status.Replicas = int32(running)
+
+	return running, templates, nil
+}
+
+// instanceTemplateSuffixLength is the length of the hashes suffixing the names of the instance templates.
+const instanceTemplateSuffixLength = len("-123456-1234")
+
+// instanceTemplateName returns the name of the instance template with the properties, suffixed with a hash of the
+// properties but the bootstrap data and a hash of the bootstrap data, so that the templates only differing by their
+// bootstrap data can be told apart.
+func (s *Service) instanceTemplateName(scope *scope.MachinePoolScope, properties *compute.InstanceProperties) (string, error) {
+	config := *properties
+	config.Metadata = &compute.Metadata{}
+	var bootstrapData string
+	for _, item := range properties.Metadata.Items {
+		if item.Key == userDataKey {
+			bootstrapData = pointer.StringDeref(item.Value, "")
+			continue
+		}
+		config.Metadata.Items = append(config.Metadata.Items, item)
+	}
+	data, err := json.Marshal(&config)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to hash the instance template")
+	}
+	configSum, bootstrapSum := sha256.Sum256(data), sha256.Sum256([]byte(bootstrapData))
+
+	return fmt.Sprintf("%s-%s-%s", names.TruncateTo(s.InstanceGroupManagerName(scope), names.MaxLength-instanceTemplateSuffixLength),
+		hex.EncodeToString(configSum[:])[:6], hex.EncodeToString(bootstrapSum[:])[:4]), nil
+}
+
+// sameInstanceConfiguration returns true if the instance templates only differ by their bootstrap data.
+func sameInstanceConfiguration(a, b string) bool {
+	suffix := len("-1234")
+	return len(a) > suffix && len(b) > suffix && a[:len(a)-suffix] == b[:len(b)-suffix]
+}
+
+// reconcileInstanceTemplate creates the instance template of the GCPMachinePool and returns its self link. The
+// templates can't be changed, a new one is created once the GCPMachinePool or the bootstrap data change.
+func (s *Service) reconcileInstanceTemplate(scope *scope.MachinePoolScope) (string, error) {
+	properties, err := s.instanceProperties(scope)
+	if err != nil {
+		return "", err
+	}
+	name, err := s.instanceTemplateName(scope, properties)
+	if err != nil {
+		return "", err
+	}
+
+	template, err := s.instancetemplates.Get(s.scope.Project(), name).Do()
+	if err == nil {
+		return template.SelfLink, nil
+	} else if !gcperrors.IsNotFound(err) {
+		return "", errors.Wrapf(err, "failed to describe instance template %q", name)
+	}
+
+	op, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+		return s.instancetemplates.Insert(s.scope.Project(), &compute.InstanceTemplate{
+			Name:        name,
+			Description: s.machinePoolMarker(scope),
+			Properties:  properties,
+		}).Do()
+	})
+	if wait.IsTimeout(err) {
+		return "", err
+	}
+	if err != nil {
+		record.Warnf(scope.GCPMachinePool, "FailedCreate", "Failed to create instance template %q: %v", name, err)
+		return "", errors.Wrapf(err, "failed to create instance template %q", name)
+	}
+	record.Eventf(scope.GCPMachinePool, "SuccessfulCreate", "Created instance template %q", name)
+
+	return op.TargetLink, nil
+}
+
+// deleteInstanceTemplates deletes the instance templates of the GCPMachinePool but the ones still in use.
+func (s *Service) deleteInstanceTemplates(scope *scope.MachinePoolScope, inUse sets.String) error {
+	var unused []string
+	err := s.instancetemplates.List(s.scope.Project()).Filter(fmt.Sprintf("description = %q", s.machinePoolMarker(scope))).
+		Pages(context.TODO(), func(list *compute.InstanceTemplateList) error {
+			for _, template := range list.Items {
+				if !inUse.Has(template.Name) {
+					unused = append(unused, template.Name)
+				}
+			}
+
+			return nil
+		})
+	if err != nil {
+		return errors.Wrap(err, "failed to list instance templates")
+	}
+
+	for _, name := range unused {
+		op, err := s.runMachinePoolOperation(scope, func() (*compute.Operation, error) {
+			return s.instancetemplates.Delete(s.scope.Project(), name).Do()
+		})
+		if err != nil && !gcperrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete instance template %q", name)
+		}
+		if op != nil {
+			record.Eventf(scope.GCPMachinePool, "SuccessfulDelete", "Deleted instance template %q", name)
+		}
+	}
+
+	return nil
+}
+
+// maxMetadataValueSize is the maximum size of the value of a metadata item of an instance.
+const maxMetadataValueSize = 256 * 1024
+
+// instanceProperties returns the properties of the instance template of the GCPMachinePool. The instances are only
+// configured as the ones of the GCPMachines with the settings the GCPMachinePool shares with them and the ones of the
+// cluster: the bootstrap data bucket, the additional and etcd disks, the shielded and confidential instance settings,
+// the reservations or the sole-tenant node groups of the GCPMachines have no counterpart. The bootstrap data is then
+// always passed in the user-data metadata, and the instances are only shielded by the hardened security profile.
+func (s *Service) instanceProperties(scope *scope.MachinePoolScope) (*compute.InstanceProperties, error) {
+	spec := scope.GCPMachinePool.Spec
+
+	bootstrapData, err := scope.GetBootstrapData()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to retrieve bootstrap data")
+	}
+	if len(bootstrapData) > maxMetadataValueSize {
+		return nil, errors.Errorf("the bootstrap data of %d bytes exceeds the %d bytes of the user-data metadata, the machine pools have no bootstrap data bucket",
+			len(bootstrapData), maxMetadataValueSize)
+	}
+
+	sourceImage, err := s.machinePoolImage(scope)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := infrav1.Build(infrav1.BuildParams{
+		ClusterName: s.scope.Name(),
+		Lifecycle:   infrav1.ResourceLifecycleOwned,
+		Role:        pointer.StringPtr("node"),
+		Additional: infrav1.Labels{}.
+			AddLabels(s.scope.GCPCluster.Spec.AdditionalLabels).
+			AddLabels(scope.AdditionalLabels()),
+	})
+
+	properties := &compute.InstanceProperties{
+		MachineType:  spec.InstanceType,
+		CanIpForward: true,
+		NetworkInterfaces: []*compute.NetworkInterface{{
+			Network: s.scope.NetworkSelfLink(),
+		}},
+		Tags: &compute.Tags{
+			Items: append(
+				append([]string{}, scope.AdditionalNetworkTags()...),
+				s.roleTag("node"),
+				names.Truncate(s.scope.Name()),
+			),
+		},
+		Disks: []*compute.AttachedDisk{
+			{
+				AutoDelete: true,
+				Boot:       true,
+				InitializeParams: &compute.AttachedDiskInitializeParams{
+					DiskSizeGb:  defaultDiskSizeGB,
+					DiskType:    string(diskTypePtrDerefOrDefault(spec.RootDeviceType)),
+					SourceImage: sourceImage,
+					Labels:      labels,
+				},
+			},
+		},
+		Metadata: &compute.Metadata{
+			Items: []*compute.MetadataItems{
+				{
+					Key:   userDataKey,
+					Value: pointer.StringPtr(bootstrapData),
+				},
+			},
+		},
+		ServiceAccounts: []*compute.ServiceAccount{
+			{
+				Email: "default",
+				Scopes: []string{
+					compute.CloudPlatformScope,
+				},
+			},
+		},
+		Labels: labels,
+	}
+	properties.Scheduling = instanceScheduling(spec.Preemptible)
+
+	metadataKeys := map[string]bool{}
+	for _, m := range spec.AdditionalMetadata {
+		properties.Metadata.Items = append(properties.Metadata.Items, &compute.MetadataItems{
+			Key:   m.Key,
+			Value: m.Value,
+		})
+		metadataKeys[m.Key] = true
+	}
+
+	// The break-glass SSH key is authorized along with the keys of the additional metadata.
+	if key := s.scope.BreakGlassSSHKey(); key != "" {
+		appendMetadataItem(properties.Metadata, &compute.MetadataItems{Key: sshKeysKey, Value: pointer.StringPtr(key)})
+	}
+
+	if serviceAccount := scope.ServiceAccount(); serviceAccount != nil {
+		properties.ServiceAccounts = []*compute.ServiceAccount{instanceServiceAccount(serviceAccount)}
+	}
+
+	if s.scope.SecurityProfile() == infrav1.SecurityProfileHardened {
+		properties.ShieldedInstanceConfig = &compute.ShieldedInstanceConfig{
+			EnableSecureBoot:          true,
+			EnableVtpm:                true,
+			EnableIntegrityMonitoring: true,
+		}
+		if scope.ServiceAccount() == nil {
+			properties.ServiceAccounts[0].Scopes = hardenedScopes
+		}
+		if !metadataKeys[enableOSLoginKey] {
+			properties.Metadata.Items = append(properties.Metadata.Items, &compute.MetadataItems{
+				Key:   enableOSLoginKey,
+				Value: pointer.StringPtr("TRUE"),
+			})
+		}
+	}
+
+	if s.scope.GCPCluster.Spec.IAPAccess {
+		properties.Tags.Items = append(properties.Tags.Items, s.iapTag())
+	}
+
+	if scope.PublicIP() {
+		properties.NetworkInterfaces[0].AccessConfigs = []*compute.AccessConfig{
+			{
+				Type: "ONE_TO_ONE_NAT",
+				Name: "External NAT",
+			},
+		}
+	}
+
+	if spec.RootDeviceSize > 0 {
+		properties.Disks[0].InitializeParams.DiskSizeGb = spec.RootDeviceSize
+	}
+
+	encryptDisks(properties.Disks, spec.DiskEncryption)
+
+	if len(spec.GuestAccelerators) > 0 {
+		properties.GuestAccelerators = guestAccelerators("", spec.GuestAccelerators)
+		// The instances with accelerators can't be live migrated.
+		properties.Scheduling.OnHostMaintenance = "TERMINATE"
+	}
+
+	if subnet := scope.Subnet(); subnet != nil {
+		properties.NetworkInterfaces[0].Subnetwork = fmt.Sprintf("regions/%s/subnetworks/%s", s.scope.Region(), *subnet)
+		s.setNetworkInterfaceStack(properties.NetworkInterfaces[0], s.scope.Region(), *subnet)
+	}
+
+	return properties, nil
+}
+
+// machinePoolImage computes the GCE disk image of the instances of the GCPMachinePool, as the one of a GCPMachine.
+func (s *Service) machinePoolImage(scope *scope.MachinePoolScope) (string, error) {
+	if scope.GCPMachinePool.Spec.Image != nil {
+		return *scope.GCPMachinePool.Spec.Image, nil
+	} else if scope.GCPMachinePool.Spec.ImageFamily != nil {
+		return *scope.GCPMachinePool.Spec.ImageFamily, nil
+	}
+	if image := scope.DefaultImage(); image != nil {
+		return *image, nil
+	}
+
+	if scope.MachinePool.Spec.Template.Spec.Version == nil {
+		return "", errors.Errorf("missing required Spec.Template.Spec.Version on MachinePool %q in namespace %q",
+			scope.MachinePool.Name, scope.Namespace())
+	}
+
+	version, err := semver.ParseTolerant(*scope.MachinePool.Spec.Template.Spec.Version)
+	if err != nil {
+		return "", errors.Wrapf(err, "error parsing Spec.Template.Spec.Version on MachinePool %q in namespace %q, expected valid SemVer string",
+			scope.MachinePool.Name, scope.Namespace())
+	}
+
+	return fmt.Sprintf("projects/%s/global/images/family/capi-ubuntu-1804-k8s-v%d-%d",
+		s.scope.Project(), version.Major, version.Minor), nil
+}
@@ -0,0 +1,458 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/feature"
+)
+
+func newTestMachinePoolScope(g *WithT, clusterScope *scope.ClusterScope, name string, replicas int32) *scope.MachinePoolScope {
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expinfrav1.AddToScheme(scheme)).To(Succeed())
+
+	gcpMachinePool := &expinfrav1.GCPMachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       expinfrav1.GCPMachinePoolSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name + "-bootstrap", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	machinePool := &expclusterv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       expclusterv1.MachinePoolSpec{Replicas: pointer.Int32Ptr(replicas)},
+	}
+	machinePool.Spec.Template.Spec.Bootstrap.DataSecretName = pointer.StringPtr(secret.Name)
+	machinePoolScope, err := scope.NewMachinePoolScope(scope.MachinePoolScopeParams{
+		Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpMachinePool, secret).Build(),
+		Cluster:        clusterScope.Cluster,
+		MachinePool:    machinePool,
+		GCPCluster:     clusterScope.GCPCluster,
+		GCPMachinePool: gcpMachinePool,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return machinePoolScope
+}
+
+func TestReconcileMachinePool(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.GCPCluster.Status.FailureDomains = clusterv1.FailureDomains{"us-central1-a": {}, "us-central1-b": {}}
+	s := NewService(clusterScope)
+	machinePoolScope := newTestMachinePoolScope(g, clusterScope, "my-pool", 2)
+	pool := machinePoolScope.GCPMachinePool
+	igmPath := "projects/my-project/regions/us-central1/instanceGroupManagers/my-cluster-my-pool"
+
+	testEvents.Messages()
+	ready, err := s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	igm := &compute.InstanceGroupManager{}
+	g.Expect(c.Get(igmPath, igm)).To(BeTrue())
+	g.Expect(igm.TargetSize).To(BeEquivalentTo(2))
+	g.Expect(igm.UpdatePolicy.Type).To(Equal("PROACTIVE"))
+	g.Expect(igm.DistributionPolicy.Zones).To(HaveLen(2))
+	g.Expect(path.Base(igm.InstanceTemplate)).To(Equal(pool.Status.InstanceTemplate))
+	g.Expect(pool.Spec.ProviderIDList).To(ConsistOf(
+		"gce://my-project/us-central1-a/my-cluster-my-pool-0",
+		"gce://my-project/us-central1-b/my-cluster-my-pool-1",
+	))
+	g.Expect(pool.Status.Replicas).To(BeEquivalentTo(2))
+	g.Expect(conditions.IsTrue(pool, expinfrav1.InstanceGroupReadyCondition)).To(BeTrue())
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`SuccessfulCreate Created managed instance group "my-cluster-my-pool" with 2 instances`)))
+
+	template := &compute.InstanceTemplate{}
+	g.Expect(c.Get("projects/my-project/global/instanceTemplates/"+pool.Status.InstanceTemplate, template)).To(BeTrue())
+	g.Expect(template.Properties.MachineType).To(Equal("n1-standard-2"))
+	g.Expect(template.Properties.Disks[0].InitializeParams.SourceImage).To(Equal("my-image"))
+	g.Expect(template.Properties.Tags.Items).To(ContainElements("my-cluster-node", "my-cluster"))
+
+	// The group is resized with the MachinePool.
+	machinePoolScope.MachinePool.Spec.Replicas = pointer.Int32Ptr(3)
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	igm = &compute.InstanceGroupManager{}
+	g.Expect(c.Get(igmPath, igm)).To(BeTrue())
+	g.Expect(igm.TargetSize).To(BeEquivalentTo(3))
+	g.Expect(pool.Spec.ProviderIDList).To(HaveLen(3))
+	g.Expect(testEvents.Messages()).To(ContainElement(`Normal SuccessfulResize Resized managed instance group "my-cluster-my-pool" from 2 to 3 instances`))
+
+	// The instances are replaced with a new template once the GCPMachinePool is changed, the old one deleted.
+	oldTemplate := pool.Status.InstanceTemplate
+	pool.Spec.InstanceType = "n1-standard-4"
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeFalse())
+	g.Expect(pool.Status.InstanceTemplate).NotTo(Equal(oldTemplate))
+	igm = &compute.InstanceGroupManager{}
+	g.Expect(c.Get(igmPath, igm)).To(BeTrue())
+	g.Expect(path.Base(igm.InstanceTemplate)).To(Equal(pool.Status.InstanceTemplate))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`RollingUpdate Replacing the instances of managed instance group "my-cluster-my-pool"`)))
+	g.Expect(c.List("projects/my-project/global/instanceTemplates")).To(HaveLen(2))
+
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	g.Expect(c.List("projects/my-project/global/instanceTemplates")).To(ConsistOf(
+		"projects/my-project/global/instanceTemplates/" + pool.Status.InstanceTemplate,
+	))
+
+	// The instances are kept once the bootstrap data alone is rotated, only the new ones being created with it.
+	oldTemplate = pool.Status.InstanceTemplate
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expinfrav1.AddToScheme(scheme)).To(Succeed())
+	rotated := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-pool-bootstrap", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config\n# rotated token")},
+	}
+	machinePoolScope, err = scope.NewMachinePoolScope(scope.MachinePoolScopeParams{
+		Client:         fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool, rotated).Build(),
+		Cluster:        clusterScope.Cluster,
+		MachinePool:    machinePoolScope.MachinePool,
+		GCPCluster:     clusterScope.GCPCluster,
+		GCPMachinePool: pool,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pool.Status.InstanceTemplate).NotTo(Equal(oldTemplate))
+	g.Expect(pool.Status.InstanceTemplate[:len(oldTemplate)-4]).To(Equal(oldTemplate[:len(oldTemplate)-4]))
+	igm = &compute.InstanceGroupManager{}
+	g.Expect(c.Get(igmPath, igm)).To(BeTrue())
+	g.Expect(path.Base(igm.InstanceTemplate)).To(Equal(pool.Status.InstanceTemplate))
+	g.Expect(igm.UpdatePolicy.Type).To(Equal("OPPORTUNISTIC"))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`BootstrapDataUpdate Creating the new instances of managed instance group "my-cluster-my-pool"`)))
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+
+	g.Expect(s.DeleteMachinePool(machinePoolScope)).To(Succeed())
+	g.Expect(c.Get(igmPath, &compute.InstanceGroupManager{})).To(BeFalse())
+	g.Expect(c.List("projects/my-project/global/instanceTemplates")).To(BeEmpty())
+}
+
+func TestReconcileMachinePoolAutoscaling(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machinePoolScope := newTestMachinePoolScope(g, clusterScope, "my-pool", 2)
+	pool := machinePoolScope.GCPMachinePool
+	pool.Spec.Autoscaling = &expinfrav1.MachinePoolAutoscaling{MinReplicas: 1, MaxReplicas: 5}
+	igmPath := "projects/my-project/regions/us-central1/instanceGroupManagers/my-cluster-my-pool"
+	autoscalerPath := "projects/my-project/regions/us-central1/autoscalers/my-cluster-my-pool"
+
+	testEvents.Messages()
+	_, err := s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	autoscaler := &compute.Autoscaler{}
+	g.Expect(c.Get(autoscalerPath, autoscaler)).To(BeTrue())
+	g.Expect(autoscaler.Target).To(Equal(c.SelfLink(igmPath)))
+	g.Expect(autoscaler.AutoscalingPolicy.MinNumReplicas).To(BeEquivalentTo(1))
+	g.Expect(autoscaler.AutoscalingPolicy.MaxNumReplicas).To(BeEquivalentTo(5))
+	g.Expect(autoscaler.AutoscalingPolicy.CpuUtilization.UtilizationTarget).To(Equal(0.6))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`SuccessfulCreate Created autoscaler "my-cluster-my-pool"`)))
+
+	// The group isn't resized with the MachinePool, the autoscaler resizes it.
+	machinePoolScope.MachinePool.Spec.Replicas = pointer.Int32Ptr(3)
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	igm := &compute.InstanceGroupManager{}
+	g.Expect(c.Get(igmPath, igm)).To(BeTrue())
+	g.Expect(igm.TargetSize).To(BeEquivalentTo(2))
+	g.Expect(testEvents.Messages()).NotTo(ContainElement(MatchRegexp(`SuccessfulResize|AutoscalingUpdate`)))
+
+	// The policy is updated once the autoscaling changed.
+	pool.Spec.Autoscaling.MaxReplicas = 10
+	pool.Spec.Autoscaling.CPUUtilizationPercent = 80
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	autoscaler = &compute.Autoscaler{}
+	g.Expect(c.Get(autoscalerPath, autoscaler)).To(BeTrue())
+	g.Expect(autoscaler.AutoscalingPolicy.MaxNumReplicas).To(BeEquivalentTo(10))
+	g.Expect(autoscaler.AutoscalingPolicy.CpuUtilization.UtilizationTarget).To(Equal(0.8))
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`AutoscalingUpdate Updated autoscaler "my-cluster-my-pool"`)))
+
+	// The autoscaler is deleted once the autoscaling is unset, the group being resized with the MachinePool again.
+	pool.Spec.Autoscaling = nil
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(autoscalerPath, nil)).To(BeFalse())
+	igm = &compute.InstanceGroupManager{}
+	g.Expect(c.Get(igmPath, igm)).To(BeTrue())
+	g.Expect(igm.TargetSize).To(BeEquivalentTo(3))
+
+	// The autoscaler is deleted along with the group.
+	pool.Spec.Autoscaling = &expinfrav1.MachinePoolAutoscaling{MinReplicas: 1, MaxReplicas: 5}
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(autoscalerPath, nil)).To(BeTrue())
+	g.Expect(s.DeleteMachinePool(machinePoolScope)).To(Succeed())
+	g.Expect(c.Get(autoscalerPath, nil)).To(BeFalse())
+	g.Expect(c.Get(igmPath, nil)).To(BeFalse())
+
+	// The minimum replicas can't exceed the maximum replicas.
+	pool.Spec.Autoscaling = &expinfrav1.MachinePoolAutoscaling{MinReplicas: 5, MaxReplicas: 1}
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).To(MatchError(ContainSubstring("exceed its 1 maximum replicas")))
+}
+
+func TestReconcileMachinePoolWarmPool(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	machinePoolScope := newTestMachinePoolScope(g, clusterScope, "my-pool", 2)
+	pool := machinePoolScope.GCPMachinePool
+	pool.Spec.WarmPool = &expinfrav1.MachinePoolWarmPool{Size: 1}
+	igmPath := "projects/my-project/regions/us-central1/instanceGroupManagers/my-cluster-my-pool"
+	join := func(names ...string) {
+		for _, name := range names {
+			machinePoolScope.MachinePool.Status.NodeRefs = append(machinePoolScope.MachinePool.Status.NodeRefs, corev1.ObjectReference{Name: name})
+		}
+	}
+	instances := func() map[string]string {
+		res, err := s.scope.Compute.RegionInstanceGroupManagers.ListManagedInstances("my-project", "us-central1", "my-cluster-my-pool").Do()
+		g.Expect(err).NotTo(HaveOccurred())
+		statuses := map[string]string{}
+		for _, i := range res.ManagedInstances {
+			statuses[path.Base(i.Instance)] = i.InstanceStatus
+		}
+		return statuses
+	}
+
+	// The warm pool depends on the compute alpha API.
+	_, err := s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).To(MatchError(ContainSubstring("feature gate must be enabled")))
+
+	g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ComputeAlphaAPI))).To(Succeed())
+	defer func() {
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ComputeAlphaAPI))).To(Succeed())
+	}()
+	clusterScope = newTestClusterScope(g, c)
+	s = NewService(clusterScope)
+
+	// The group is resized to replenish the warm pool, the new instance being stopped once joined.
+	testEvents.Messages()
+	ready, err := s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeFalse())
+	igm := &compute.InstanceGroupManager{}
+	g.Expect(c.Get(igmPath, igm)).To(BeTrue())
+	g.Expect(igm.TargetSize).To(BeEquivalentTo(3))
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeFalse())
+	g.Expect(instances()).To(Equal(map[string]string{"my-cluster-my-pool-0": "RUNNING", "my-cluster-my-pool-1": "RUNNING", "my-cluster-my-pool-2": "RUNNING"}))
+
+	join("my-cluster-my-pool-0", "my-cluster-my-pool-1", "my-cluster-my-pool-2")
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(HaveKeyWithValue("my-cluster-my-pool-2", "TERMINATED"))
+	g.Expect(testEvents.Messages()).To(ContainElement(`Normal WarmPoolStop Stopped 1 instances of managed instance group "my-cluster-my-pool" into its warm pool`))
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	g.Expect(pool.Spec.ProviderIDList).To(HaveLen(2))
+	g.Expect(pool.Status.Replicas).To(BeEquivalentTo(2))
+
+	// The stopped instance is started on scale up, the warm pool being replenished.
+	machinePoolScope.MachinePool.Spec.Replicas = pointer.Int32Ptr(3)
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(HaveKeyWithValue("my-cluster-my-pool-2", "RUNNING"))
+	g.Expect(testEvents.Messages()).To(ContainElement(`Normal WarmPoolStart Started 1 instances of the warm pool of managed instance group "my-cluster-my-pool"`))
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(HaveLen(4))
+
+	// The instances in excess are stopped into the warm pool on scale down, the others deleted.
+	machinePoolScope.MachinePool.Spec.Replicas = pointer.Int32Ptr(1)
+	join("my-cluster-my-pool-3")
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(HaveKeyWithValue("my-cluster-my-pool-3", "TERMINATED"))
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(Equal(map[string]string{"my-cluster-my-pool-0": "RUNNING", "my-cluster-my-pool-3": "TERMINATED"}))
+	g.Expect(testEvents.Messages()).To(ContainElement(`Normal SuccessfulDelete Deleted 2 instances of managed instance group "my-cluster-my-pool"`))
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+
+	// The warm pool can't be combined with the autoscaling.
+	pool.Spec.Autoscaling = &expinfrav1.MachinePoolAutoscaling{MinReplicas: 1, MaxReplicas: 5}
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).To(MatchError(ContainSubstring("can't be combined with its warm pool")))
+}
+
+func TestReconcileMachinePoolSuspension(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=true", feature.ComputeAlphaAPI))).To(Succeed())
+	defer func() {
+		g.Expect(feature.MutableGates.Set(fmt.Sprintf("%s=false", feature.ComputeAlphaAPI))).To(Succeed())
+	}()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.GCPCluster.Status.FailureDomains = clusterv1.FailureDomains{"us-central1-a": {}, "us-central1-b": {}}
+	s := NewService(clusterScope)
+	machinePoolScope := newTestMachinePoolScope(g, clusterScope, "my-pool", 3)
+	pool := machinePoolScope.GCPMachinePool
+	pool.Spec.Suspension = &expinfrav1.MachinePoolSuspension{MaxReplicas: 1}
+	for i := 0; i < 3; i++ {
+		machinePoolScope.MachinePool.Status.NodeRefs = append(machinePoolScope.MachinePool.Status.NodeRefs,
+			corev1.ObjectReference{Name: fmt.Sprintf("my-cluster-my-pool-%d", i)})
+	}
+	instances := func() map[string]string {
+		res, err := s.scope.Compute.RegionInstanceGroupManagers.ListManagedInstances("my-project", "us-central1", "my-cluster-my-pool").Do()
+		g.Expect(err).NotTo(HaveOccurred())
+		statuses := map[string]string{}
+		for _, i := range res.ManagedInstances {
+			statuses[path.Base(i.Instance)] = i.InstanceStatus
+		}
+		return statuses
+	}
+
+	testEvents.Messages()
+	ready, err := s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+
+	// The instances in excess are suspended on scale down, up to the maximum, the others deleted.
+	machinePoolScope.MachinePool.Spec.Replicas = pointer.Int32Ptr(1)
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(HaveKeyWithValue("my-cluster-my-pool-2", "SUSPENDED"))
+	g.Expect(testEvents.Messages()).To(ContainElement(`Normal SuccessfulSuspend Suspended 1 instances of managed instance group "my-cluster-my-pool"`))
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(Equal(map[string]string{"my-cluster-my-pool-0": "RUNNING", "my-cluster-my-pool-2": "SUSPENDED"}))
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	g.Expect(pool.Spec.ProviderIDList).To(ConsistOf("gce://my-project/us-central1-a/my-cluster-my-pool-0"))
+
+	// The suspended instance is resumed on scale up.
+	machinePoolScope.MachinePool.Spec.Replicas = pointer.Int32Ptr(2)
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(HaveKeyWithValue("my-cluster-my-pool-2", "RUNNING"))
+	g.Expect(testEvents.Messages()).To(ContainElement(`Normal SuccessfulResume Resumed 1 suspended instances of managed instance group "my-cluster-my-pool"`))
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	g.Expect(pool.Spec.ProviderIDList).To(HaveLen(2))
+
+	// The instances suspended for longer than the retention are deleted.
+	pool.Spec.Suspension.Retention = &metav1.Duration{Duration: time.Hour}
+	machinePoolScope.MachinePool.Spec.Replicas = pointer.Int32Ptr(1)
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(HaveKeyWithValue("my-cluster-my-pool-2", "SUSPENDED"))
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-cluster-my-pool-2", &compute.Instance{
+		Status:                 "SUSPENDED",
+		LastSuspendedTimestamp: time.Now().Add(-30 * time.Minute).Format(time.RFC3339),
+	})
+	ready, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-cluster-my-pool-2", &compute.Instance{
+		Status:                 "SUSPENDED",
+		LastSuspendedTimestamp: time.Now().Add(-2 * time.Hour).Format(time.RFC3339),
+	})
+	_, err = s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(instances()).To(Equal(map[string]string{"my-cluster-my-pool-0": "RUNNING"}))
+}
+
+func TestReconcileMachinePoolInstanceProperties(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b", "us-central1-c")
+	for _, zone := range []string{"us-central1-b", "us-central1-c"} {
+		c.Put("projects/my-project/zones/"+zone+"/acceleratorTypes/nvidia-tesla-t4", &compute.AcceleratorType{
+			Name:                    "nvidia-tesla-t4",
+			Zone:                    c.SelfLink("projects/my-project/zones/" + zone),
+			MaximumCardsPerInstance: 4,
+		})
+	}
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.GCPCluster.Status.FailureDomains = clusterv1.FailureDomains{"us-central1-a": {}, "us-central1-b": {}, "us-central1-c": {}}
+	s := NewService(clusterScope)
+	machinePoolScope := newTestMachinePoolScope(g, clusterScope, "my-pool", 2)
+	pool := machinePoolScope.GCPMachinePool
+	pool.Spec.Preemptible = true
+	pool.Spec.DiskEncryption = &infrav1.DiskEncryption{KMSKeyName: "projects/my-project/locations/us-central1/keyRings/my-ring/cryptoKeys/my-key"}
+	pool.Spec.GuestAccelerators = []infrav1.Accelerator{{Type: "nvidia-tesla-t4", Count: 1}}
+
+	_, err := s.ReconcileMachinePool(machinePoolScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The instances are configured as the ones of the GCPMachines.
+	template := &compute.InstanceTemplate{}
+	g.Expect(c.Get("projects/my-project/global/instanceTemplates/"+pool.Status.InstanceTemplate, template)).To(BeTrue())
+	g.Expect(template.Properties.Scheduling.Preemptible).To(BeTrue())
+	g.Expect(template.Properties.Scheduling.OnHostMaintenance).To(Equal("TERMINATE"))
+	g.Expect(template.Properties.Scheduling.AutomaticRestart).To(Equal(pointer.BoolPtr(false)))
+	g.Expect(template.Properties.Disks[0].DiskEncryptionKey.KmsKeyName).To(Equal(pool.Spec.DiskEncryption.KMSKeyName))
+	g.Expect(template.Properties.GuestAccelerators).To(Equal([]*compute.AcceleratorConfig{
+		{AcceleratorType: "nvidia-tesla-t4", AcceleratorCount: 1},
+	}))
+
+	// The instances are only distributed across the zones offering the accelerators.
+	igm := &compute.InstanceGroupManager{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/instanceGroupManagers/my-cluster-my-pool", igm)).To(BeTrue())
+	zones := []string{}
+	for _, z := range igm.DistributionPolicy.Zones {
+		zones = append(zones, path.Base(z.Zone))
+	}
+	g.Expect(zones).To(ConsistOf("us-central1-b", "us-central1-c"))
+}
@@ -0,0 +1,176 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+// UnavailableInZoneError is returned when the machine type, or an accelerator type, of an instance
+// doesn't exist in its zone, or when its zone doesn't offer as many accelerators of a type per instance.
+type UnavailableInZoneError struct {
+	// Kind is the kind of the type, e.g. machine type.
+	Kind string
+	// Name is the name of the type, e.g. n1-standard-2, or the requested accelerators, e.g. 8 x nvidia-tesla-t4.
+	Name string
+	// Zone is the zone of the instance.
+	Zone string
+}
+
+func (e *UnavailableInZoneError) Error() string {
+	return fmt.Sprintf("%s %q is not available in zone %q", e.Kind, e.Name, e.Zone)
+}
+
+// UnavailableInRegionError is returned when no zone of the region offers the accelerators of an instance.
+type UnavailableInRegionError struct {
+	// Accelerators are the accelerators of the instance, e.g. 2 x nvidia-tesla-t4.
+	Accelerators []string
+	// Region is the region of the cluster.
+	Region string
+}
+
+func (e *UnavailableInRegionError) Error() string {
+	return fmt.Sprintf("no zone of region %q offers the accelerators %s", e.Region, strings.Join(e.Accelerators, ", "))
+}
+
+// CheckInstanceTypes returns an UnavailableInZoneError if the machine type or an accelerator type
+// of the instance doesn't exist in its zone, or if the zone offers fewer accelerators of a type per
+// instance than requested. The lookups are cached.
+func (s *Service) CheckInstanceTypes(scope *scope.MachineScope) error {
+	zone := scope.Zone()
+	if err := s.checkTypeAvailable("machine type", scope.GCPMachine.Spec.InstanceType, zone, func() error {
+		_, err := s.scope.Compute.MachineTypes.Get(s.scope.Project(), zone, scope.GCPMachine.Spec.InstanceType).Do()
+		return err
+	}); err != nil {
+		return err
+	}
+
+	for _, a := range scope.GCPMachine.Spec.GuestAccelerators {
+		a := a
+		if err := s.checkTypeAvailable("accelerator type", a.Type, zone, func() error {
+			_, err := s.scope.Compute.AcceleratorTypes.Get(s.scope.Project(), zone, a.Type).Do()
+			return err
+		}); err != nil {
+			return err
+		}
+
+		maxCards, err := s.getAcceleratorTypeZones(a.Type)
+		if err != nil {
+			return err
+		}
+		if max := maxCards[zone]; max > 0 && a.Count > max {
+			return &UnavailableInZoneError{Kind: "accelerator count", Name: fmt.Sprintf("%d x %s", a.Count, a.Type), Zone: zone}
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) checkTypeAvailable(kind, name, zone string, get func() error) error {
+	key := fmt.Sprintf("%s/%s/%s/%s", kind, s.scope.Project(), zone, name)
+	available, err := s.scope.Cache().Get(key, func() (interface{}, error) {
+		err := get()
+		if gcperrors.IsNotFound(err) {
+			return false, nil
+		}
+
+		return err == nil, err
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe %s %q", kind, name)
+	}
+	if !available.(bool) {
+		return &UnavailableInZoneError{Kind: kind, Name: name, Zone: zone}
+	}
+
+	return nil
+}
+
+// GetAcceleratorZones returns the zones of the region offering all the accelerator types of the instance, with at
+// least their count per instance, or an UnavailableInRegionError if there are none. The listings are cached.
+func (s *Service) GetAcceleratorZones(accelerators []infrav1.Accelerator) ([]string, error) {
+	zones, err := s.GetZones()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]string, 0, len(zones))
+	res = append(res, zones...)
+	for _, a := range accelerators {
+		maxCards, err := s.getAcceleratorTypeZones(a.Type)
+		if err != nil {
+			return nil, err
+		}
+		eligible := res[:0]
+		for _, zone := range res {
+			if max, ok := maxCards[zone]; ok && (max == 0 || a.Count <= max) {
+				eligible = append(eligible, zone)
+			}
+		}
+		res = eligible
+	}
+	if len(res) == 0 {
+		requested := make([]string, 0, len(accelerators))
+		for _, a := range accelerators {
+			requested = append(requested, fmt.Sprintf("%d x %s", a.Count, a.Type))
+		}
+
+		return nil, &UnavailableInRegionError{Accelerators: requested, Region: s.scope.Region()}
+	}
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// getAcceleratorTypeZones returns the cached maximum number of accelerators of the type per instance,
+// by the zones offering the type, which must not be modified.
+func (s *Service) getAcceleratorTypeZones(name string) (map[string]int64, error) {
+	key := fmt.Sprintf("acceleratorTypes/%s/%s", s.scope.Project(), name)
+	res, err := s.scope.Cache().Get(key, func() (interface{}, error) {
+		list, err := s.scope.Compute.AcceleratorTypes.AggregatedList(s.scope.Project()).
+			Filter(fmt.Sprintf("name = %q", name)).
+			Do()
+		if err != nil {
+			return nil, err
+		}
+
+		maxCards := make(map[string]int64)
+		for _, scoped := range list.Items {
+			for _, t := range scoped.AcceleratorTypes {
+				if t.Deprecated == nil || t.Deprecated.State == "" {
+					maxCards[path.Base(t.Zone)] = t.MaximumCardsPerInstance
+				}
+			}
+		}
+
+		return maxCards, nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the zones of accelerator type %q", name)
+	}
+
+	return res.(map[string]int64), nil
+}
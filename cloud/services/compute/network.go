@@ -18,14 +18,19 @@ package compute
 
 import (
 	"fmt"
+	"path"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 	"google.golang.org/api/compute/v1"
 	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/record"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
-	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
 )
 
 // ReconcileNetwork reconciles the network and apply changes if needed.
@@ -33,15 +38,10 @@ func (s *Service) ReconcileNetwork() error {
 	// Create Network
 	spec := s.getNetworkSpec()
 	network, err := s.networks.Get(s.scope.Project(), spec.Name).Do()
-	autoCreateCloudNat := false
 	if gcperrors.IsNotFound(err) {
-		autoCreateCloudNat = true
-		op, err := s.networks.Insert(s.scope.Project(), spec).Do()
-		if err != nil {
-			return errors.Wrapf(err, "failed to create network")
-		}
-
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
+		if err := s.runInsertOperation(path.Join("global", "networks", spec.Name), func() (*compute.Operation, error) {
+			return s.networks.Insert(s.scope.Project(), spec).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to create network")
 		}
 
@@ -53,10 +53,28 @@ func (s *Service) ReconcileNetwork() error {
 		return errors.Wrapf(err, "failed to describe network")
 	}
 
-	if autoCreateCloudNat {
-		if err := s.createCloudNat(network); err != nil {
-			return errors.Wrapf(err, "failed to create cloudnat gateway")
+	// Only manage the subnetworks, the cloud nat gateway, the static routes, the private services access and the DNS
+	// of the networks owned by the cluster.
+	if s.isNetworkOwned(network) {
+		if err := s.reconcileSubnets(network); err != nil {
+			return errors.Wrapf(err, "failed to reconcile subnetworks")
+		}
+		if err := s.reconcileCloudNat(network); err != nil {
+			return errors.Wrapf(err, "failed to reconcile cloudnat gateway")
+		}
+		if err := s.reconcileRoutes(network); err != nil {
+			return errors.Wrapf(err, "failed to reconcile routes")
+		}
+		if err := s.reconcilePrivateServicesAccess(network); err != nil {
+			return errors.Wrapf(err, "failed to reconcile private services access")
 		}
+		if err := s.reconcileDNS(network); err != nil {
+			return errors.Wrapf(err, "failed to reconcile dns")
+		}
+	}
+
+	if err := s.reconcileSubnetsStatus(network); err != nil {
+		return err
 	}
 
 	s.scope.GCPCluster.Spec.Network.Name = pointer.StringPtr(network.Name)
@@ -66,10 +84,45 @@ func (s *Service) ReconcileNetwork() error {
 	return nil
 }
 
+// reconcileSubnetsStatus records the subnetworks of the network in the region of the cluster, and their IP ranges.
+func (s *Service) reconcileSubnetsStatus(network *compute.Network) error {
+	list, err := s.subnetworks.List(s.scope.Project(), s.scope.Region()).
+		Filter(fmt.Sprintf("network = %q", network.SelfLink)).
+		Do()
+	if err != nil {
+		return errors.Wrapf(err, "failed to list subnetworks")
+	}
+
+	subnets := make([]infrav1.SubnetStatus, 0, len(list.Items))
+	for _, subnetwork := range list.Items {
+		subnet := infrav1.SubnetStatus{
+			Name:      subnetwork.Name,
+			SelfLink:  subnetwork.SelfLink,
+			CidrBlock: subnetwork.IpCidrRange,
+		}
+		// The IPv6 range of an EXTERNAL subnetwork is its external prefix.
+		subnet.IPv6CidrBlock = subnetwork.Ipv6CidrRange
+		if subnetwork.ExternalIpv6Prefix != "" {
+			subnet.IPv6CidrBlock = subnetwork.ExternalIpv6Prefix
+		}
+		for _, r := range subnetwork.SecondaryIpRanges {
+			if subnet.SecondaryCidrBlocks == nil {
+				subnet.SecondaryCidrBlocks = map[string]string{}
+			}
+			subnet.SecondaryCidrBlocks[r.RangeName] = r.IpCidrRange
+		}
+		subnets = append(subnets, subnet)
+	}
+	sort.Slice(subnets, func(i, j int) bool { return subnets[i].Name < subnets[j].Name })
+	s.scope.GCPCluster.Status.Network.Subnets = subnets
+
+	return nil
+}
+
 func (s *Service) getNetworkSpec() *compute.Network {
 	res := &compute.Network{
 		Name:                  s.scope.NetworkName(),
-		Description:           infrav1.ClusterTagKey(s.scope.Name()),
+		Description:           s.ownershipMarker(),
 		AutoCreateSubnetworks: true,
 	}
 
@@ -85,28 +138,56 @@ func (s *Service) DeleteNetwork() error {
 	network, err := s.networks.Get(s.scope.Project(), s.scope.NetworkName()).Do()
 	if gcperrors.IsNotFound(err) {
 		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe network")
 	}
 
-	// Return early if the description doesn't match our ownership tag.
-	if network.Description != infrav1.ClusterTagKey(s.scope.Name()) {
+	// Return early if the network wasn't created nor adopted by the cluster.
+	if !s.isNetworkOwned(network) {
+		return nil
+	}
+
+	if s.scope.ShouldRetain(infrav1.RetainNetwork) {
+		s.recordRetained("network", network.Name)
+
 		return nil
 	}
 
 	// Delete Router.
 	router, err := s.routers.Get(s.scope.Project(), s.scope.Region(), getRouterName(s.scope.NetworkName())).Do()
 	if err == nil {
-		op, err := s.routers.Delete(s.scope.Project(), s.scope.Region(), router.Name).Do()
-		if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-			return errors.Wrapf(opErr, "failed to delete router")
+		if err := s.runDeleteOperation(path.Join("regions", s.scope.Region(), "routers", router.Name), func() (*compute.Operation, error) {
+			return s.routers.Delete(s.scope.Project(), s.scope.Region(), router.Name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete router")
 		}
 	} else if !gcperrors.IsNotFound(err) {
 		return errors.Wrapf(err, "failed to get router to delete")
 	}
+	s.scope.GCPCluster.Status.Network.Router = nil
+	s.scope.GCPCluster.Status.Network.RouterNat = nil
+	s.scope.GCPCluster.Status.Network.NATIPs = nil
+
+	// Delete the static routes, the private services access, the DNS and the subnetworks, which would otherwise
+	// prevent the deletion of the network.
+	if err := s.deleteRoutes(); err != nil {
+		return err
+	}
+	if err := s.deletePrivateServicesAccess(network); err != nil {
+		return err
+	}
+	if err := s.deleteDNS(); err != nil {
+		return err
+	}
+	if err := s.deleteSubnets(); err != nil {
+		return err
+	}
 
 	// Delete Network.
-	op, err := s.networks.Delete(s.scope.Project(), network.Name).Do()
-	if opErr := s.checkOrWaitForDeleteOp(op, err); opErr != nil {
-		return errors.Wrapf(opErr, "failed to delete network")
+	if err := s.runDeleteOperation(path.Join("global", "networks", network.Name), func() (*compute.Operation, error) {
+		return s.networks.Delete(s.scope.Project(), network.Name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete network")
 	}
 
 	s.scope.GCPCluster.Spec.Network.Name = nil
@@ -114,59 +195,139 @@ func (s *Service) DeleteNetwork() error {
 	return nil
 }
 
-func (s *Service) createCloudNat(network *compute.Network) error {
+// reconcileCloudNat creates the router and the cloud nat gateway of the network, or restores them if modified out-of-band.
+func (s *Service) reconcileCloudNat(network *compute.Network) error {
 	router, err := s.routers.Get(s.scope.Project(), s.scope.Region(), getRouterName(s.scope.NetworkName())).Do()
 	if gcperrors.IsNotFound(err) {
 		router = s.getRouterSpec(network)
-		op, err := s.routers.Insert(s.scope.Project(), s.scope.Region(), router).Do()
-		if err != nil {
+		if err := s.runInsertOperation(path.Join("regions", s.scope.Region(), "routers", router.Name), func() (*compute.Operation, error) {
+			return s.routers.Insert(s.scope.Project(), s.scope.Region(), router).Do()
+		}); err != nil {
 			return errors.Wrapf(err, "failed to create router")
 		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
-			return errors.Wrapf(err, "failed to wait for create router operation")
-		}
 		router, err = s.routers.Get(s.scope.Project(), s.scope.Region(), router.Name).Do()
 		if err != nil {
 			return errors.Wrapf(err, "failed to get router after create")
 		}
 	} else if err != nil {
 		return errors.Wrapf(err, "failed to get routers")
+	} else if err := s.ensureOwned("router", path.Join("regions", s.scope.Region(), "routers", router.Name), router.Description); err != nil {
+		return err
+	}
+
+	natSpec := s.getRouterNatSpec()
+	var nat *compute.RouterNat
+	for _, n := range router.Nats {
+		if n.Name == natSpec.Name {
+			nat = n
+		}
+	}
+
+	drift := ""
+	switch {
+	case nat == nil:
+		drift = "nat gateway is missing"
+		router.Nats = append(router.Nats, natSpec)
+	case routerNatDrift(nat, natSpec) != "":
+		drift = routerNatDrift(nat, natSpec)
+		nat.NatIpAllocateOption = natSpec.NatIpAllocateOption
+		nat.SourceSubnetworkIpRangesToNat = natSpec.SourceSubnetworkIpRangesToNat
+		nat.Subnetworks = natSpec.Subnetworks
 	}
 
-	if len(router.Nats) == 0 {
-		router.Nats = []*compute.RouterNat{s.getRouterNatSpec()}
-		op, err := s.routers.Patch(s.scope.Project(), s.scope.Region(), router.Name, router).Do()
+	if drift != "" {
+		op, err := s.runOperation(path.Join("regions", s.scope.Region(), "routers", router.Name), "patch", func() (*compute.Operation, error) {
+			return s.routers.Patch(s.scope.Project(), s.scope.Region(), router.Name, router).Do()
+		})
 		if err != nil {
 			return errors.Wrapf(err, "failed to patch router to create nat")
 		}
-		if err := wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op); err != nil {
-			return errors.Wrapf(err, "failed to wait for patch router operation")
-		}
+		s.recordDriftCorrected("router", router.Name, drift, op)
+	}
+
+	natIPs, err := s.getRouterNatIPs(router.Name, natSpec.Name)
+	if err != nil {
+		return err
+	}
+
+	// The allow-lists of the egress of the cluster are updated from the event, the addresses being
+	// allocated as the nodes need them.
+	if !equalStringSets(natIPs, s.scope.GCPCluster.Status.Network.NATIPs) && len(natIPs) > 0 {
+		record.Eventf(s.scope.GCPCluster, "NATIPsChanged", "NAT IPs of %s are %s", natSpec.Name, strings.Join(natIPs, ", "))
 	}
 
 	s.scope.GCPCluster.Status.Network.Router = pointer.StringPtr(router.SelfLink)
+	s.scope.GCPCluster.Status.Network.RouterNat = pointer.StringPtr(natSpec.Name)
+	s.scope.GCPCluster.Status.Network.NATIPs = natIPs
 	return nil
 }
 
+// getRouterNatIPs returns the external IP addresses allocated to the cloud nat gateway of the router.
+// The addresses are allocated as the nodes need them, there are none before the first node is created.
+func (s *Service) getRouterNatIPs(router, nat string) ([]string, error) {
+	res, err := s.routers.GetRouterStatus(s.scope.Project(), s.scope.Region(), router).Do()
+	if gcperrors.IsNotFound(err) {
+		// The router is only planned in dry-run mode.
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to get router status")
+	}
+	if res.Result == nil {
+		return nil, nil
+	}
+
+	var ips []string
+	for _, status := range res.Result.NatStatus {
+		if status.Name != nat {
+			continue
+		}
+		ips = append(ips, status.UserAllocatedNatIps...)
+		ips = append(ips, status.AutoAllocatedNatIps...)
+	}
+	sort.Strings(ips)
+
+	return ips, nil
+}
+
 func (s *Service) getRouterSpec(network *compute.Network) *compute.Router {
 	return &compute.Router{
-		Name:    getRouterName(network.Name),
-		Network: network.SelfLink,
-		Nats:    []*compute.RouterNat{s.getRouterNatSpec()},
+		Name:        getRouterName(network.Name),
+		Description: s.ownershipMarker(),
+		Network:     network.SelfLink,
+		Nats:        []*compute.RouterNat{s.getRouterNatSpec()},
 	}
 }
 
 func (s *Service) getRouterNatSpec() *compute.RouterNat {
-	return &compute.RouterNat{
+	res := &compute.RouterNat{
 		Name:                          getRouterNatName(s.scope.NetworkName()),
 		NatIpAllocateOption:           "AUTO_ONLY",
 		SourceSubnetworkIpRangesToNat: "ALL_SUBNETWORKS_ALL_IP_RANGES",
 	}
+
+	nat := s.scope.GCPCluster.Spec.Network.NAT
+	if nat == nil || len(nat.Subnets) == 0 {
+		return res
+	}
+	res.SourceSubnetworkIpRangesToNat = "LIST_OF_SUBNETWORKS"
+	for _, subnet := range nat.Subnets {
+		subnetwork := &compute.RouterNatSubnetworkToNat{
+			Name:                s.projectResource(path.Join("regions", s.scope.Region(), "subnetworks", subnet.Name)),
+			SourceIpRangesToNat: []string{"ALL_IP_RANGES"},
+		}
+		if len(subnet.SecondaryRangeNames) > 0 {
+			subnetwork.SourceIpRangesToNat = []string{"PRIMARY_IP_RANGE", "LIST_OF_SECONDARY_IP_RANGES"}
+			subnetwork.SecondaryIpRangeNames = subnet.SecondaryRangeNames
+		}
+		res.Subnetworks = append(res.Subnetworks, subnetwork)
+	}
+
+	return res
 }
 
 func getRouterName(network string) string {
-	return fmt.Sprintf("%s-%s", network, "router")
+	return names.Truncate(fmt.Sprintf("%s-%s", network, "router"))
 }
 func getRouterNatName(network string) string {
-	return fmt.Sprintf("%s-%s", network, "nat")
+	return names.Truncate(fmt.Sprintf("%s-%s", network, "nat"))
 }
@@ -0,0 +1,121 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileNetwork(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	testEvents.Messages()
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(`NATIPsChanged NAT IPs of default-nat are 192\.0\.2\.1`)))
+
+	network := &compute.Network{}
+	g.Expect(c.Get("projects/my-project/global/networks/default", network)).To(BeTrue())
+	g.Expect(network.Description).To(Equal(infrav1.ClusterTagKey("my-cluster")))
+	g.Expect(*s.scope.GCPCluster.Status.Network.SelfLink).To(Equal(network.SelfLink))
+
+	router := &compute.Router{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/routers/default-router", router)).To(BeTrue())
+	g.Expect(router.Nats).To(HaveLen(1))
+	g.Expect(s.scope.GCPCluster.Status.Network.Router).To(Equal(pointer.StringPtr(router.SelfLink)))
+	g.Expect(s.scope.GCPCluster.Status.Network.RouterNat).To(Equal(pointer.StringPtr(router.Nats[0].Name)))
+	g.Expect(s.scope.GCPCluster.Status.Network.NATIPs).To(Equal([]string{"192.0.2.1"}))
+	g.Expect(s.scope.GCPCluster.Status.Network.Subnets).To(BeEmpty())
+
+	c.Put("projects/my-project/regions/us-central1/subnetworks/nodes", &compute.Subnetwork{
+		Network:           network.SelfLink,
+		IpCidrRange:       "10.0.0.0/20",
+		SecondaryIpRanges: []*compute.SubnetworkSecondaryRange{{RangeName: "pods", IpCidrRange: "10.4.0.0/14"}},
+	})
+	c.Put("projects/my-project/regions/us-central1/subnetworks/other", &compute.Subnetwork{
+		Network:     c.SelfLink("projects/my-project/global/networks/other"),
+		IpCidrRange: "10.16.0.0/20",
+	})
+
+	// A second pass must be a no-op.
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(testEvents.Messages()).NotTo(ContainElement(ContainSubstring("NATIPsChanged")))
+	g.Expect(s.scope.GCPCluster.Status.Network.Subnets).To(Equal([]infrav1.SubnetStatus{{
+		Name:                "nodes",
+		SelfLink:            c.SelfLink("projects/my-project/regions/us-central1/subnetworks/nodes"),
+		CidrBlock:           "10.0.0.0/20",
+		SecondaryCidrBlocks: map[string]string{"pods": "10.4.0.0/14"},
+	}}))
+
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/networks/default", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/routers/default-router", nil)).To(BeFalse())
+	g.Expect(s.scope.GCPCluster.Status.Network.Router).To(BeNil())
+	g.Expect(s.scope.GCPCluster.Status.Network.RouterNat).To(BeNil())
+	g.Expect(s.scope.GCPCluster.Status.Network.NATIPs).To(BeNil())
+}
+
+func TestReconcileCloudNatSubnets(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.Network.NAT = &infrav1.NATSpec{
+		Subnets: []infrav1.NATSubnetSpec{{Name: "nodes", SecondaryRangeNames: []string{"pods"}}, {Name: "bastion"}},
+	}
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+
+	router := &compute.Router{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/routers/default-router", router)).To(BeTrue())
+	g.Expect(router.Nats).To(HaveLen(1))
+	g.Expect(router.Nats[0].SourceSubnetworkIpRangesToNat).To(Equal("LIST_OF_SUBNETWORKS"))
+	g.Expect(router.Nats[0].Subnetworks).To(HaveLen(2))
+	g.Expect(router.Nats[0].Subnetworks[0].Name).To(Equal("projects/my-project/regions/us-central1/subnetworks/nodes"))
+	g.Expect(router.Nats[0].Subnetworks[0].SourceIpRangesToNat).To(ConsistOf("PRIMARY_IP_RANGE", "LIST_OF_SECONDARY_IP_RANGES"))
+	g.Expect(router.Nats[0].Subnetworks[0].SecondaryIpRangeNames).To(Equal([]string{"pods"}))
+	g.Expect(router.Nats[0].Subnetworks[1].SourceIpRangesToNat).To(Equal([]string{"ALL_IP_RANGES"}))
+
+	// Removing the subnetworks translates all of them again.
+	s.scope.GCPCluster.Spec.Network.NAT = nil
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	router = &compute.Router{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/routers/default-router", router)).To(BeTrue())
+	g.Expect(router.Nats[0].SourceSubnetworkIpRangesToNat).To(Equal("ALL_SUBNETWORKS_ALL_IP_RANGES"))
+	g.Expect(router.Nats[0].Subnetworks).To(BeEmpty())
+}
+
+func TestDeleteNetworkNotOwned(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	c.Put("projects/my-project/global/networks/default", &compute.Network{Description: "someone else's"})
+
+	s := NewService(newTestClusterScope(g, c))
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/networks/default", nil)).To(BeTrue())
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+// APIServerNetworkEndpointType is the type of the API server network endpoint groups, whose endpoints
+// are the IP of an instance and a port.
+const APIServerNetworkEndpointType = "GCE_VM_IP_PORT"
+
+// ReconcileNetworkEndpointGroups records the API server network endpoint groups of the zones the control plane
+// runs in. Like the instance groups, the groups are created by the control plane machines, on demand, and deleted
+// once their zone is left.
+func (s *Service) ReconcileNetworkEndpointGroups() error {
+	zones, err := s.GetControlPlaneZones()
+	if err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		name := s.APIServerNetworkEndpointGroupName(zone)
+		group, err := s.networkendpointgroups.Get(s.scope.Project(), zone, name).Do()
+		switch {
+		case gcperrors.IsNotFound(err):
+			delete(s.scope.Network().APIServerNetworkEndpointGroups, zone)
+		case err != nil:
+			return errors.Wrapf(err, "failed to describe network endpoint group %q", name)
+		default:
+			if s.scope.Network().APIServerNetworkEndpointGroups == nil {
+				s.scope.Network().APIServerNetworkEndpointGroups = make(map[string]string)
+			}
+			s.scope.Network().APIServerNetworkEndpointGroups[zone] = group.SelfLink
+		}
+	}
+
+	return nil
+}
+
+// DeleteNetworkEndpointGroups deletes the API server network endpoint groups of all the zones, by name.
+func (s *Service) DeleteNetworkEndpointGroups() error {
+	zones, err := s.GetControlPlaneZones()
+	if err != nil {
+		return err
+	}
+
+	for _, zone := range zones {
+		name := s.APIServerNetworkEndpointGroupName(zone)
+		if err := s.runDeleteOperation(path.Join("zones", zone, "networkEndpointGroups", name), func() (*compute.Operation, error) {
+			return s.networkendpointgroups.Delete(s.scope.Project(), zone, name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete network endpoint group")
+		}
+		delete(s.scope.Network().APIServerNetworkEndpointGroups, zone)
+	}
+
+	return nil
+}
+
+// ReleaseNetworkEndpointGroup detaches the endpoint of the control plane instance from the API server network
+// endpoint group of the zone, before the instance is deleted so that the load balancer stops sending it
+// connections. The group of a zone the control plane no longer runs in is removed from the backend service
+// and deleted.
+func (s *Service) ReleaseNetworkEndpointGroup(zone string, i *compute.Instance) error {
+	name := s.APIServerNetworkEndpointGroupName(zone)
+	group, err := s.networkendpointgroups.Get(s.scope.Project(), zone, name).Do()
+	if gcperrors.IsNotFound(err) {
+		delete(s.scope.Network().APIServerNetworkEndpointGroups, zone)
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe network endpoint group %q", name)
+	}
+
+	endpoints, err := s.GetNetworkEndpoints(zone, name)
+	if err != nil {
+		return err
+	}
+	var detach []*compute.NetworkEndpoint
+	for _, endpoint := range endpoints {
+		if endpoint.Instance == i.Name {
+			detach = append(detach, endpoint)
+		}
+	}
+	if len(detach) > 0 {
+		req := &compute.NetworkEndpointGroupsDetachEndpointsRequest{NetworkEndpoints: detach}
+		if _, err := s.runOperation(path.Join("zones", zone, "networkEndpointGroups", name), "detachNetworkEndpoints", func() (*compute.Operation, error) {
+			return s.networkendpointgroups.DetachNetworkEndpoints(s.scope.Project(), zone, name, req).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to detach instance from network endpoint group")
+		}
+	}
+	if len(endpoints) > len(detach) {
+		return nil
+	}
+
+	// A group can't be deleted while used by the backend service.
+	if err := s.removeBackend(group.SelfLink); err != nil {
+		return err
+	}
+
+	if err := s.runDeleteOperation(path.Join("zones", zone, "networkEndpointGroups", name), func() (*compute.Operation, error) {
+		return s.networkendpointgroups.Delete(s.scope.Project(), zone, name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete network endpoint group")
+	}
+	delete(s.scope.Network().APIServerNetworkEndpointGroups, zone)
+
+	return nil
+}
+
+// APIServerNetworkEndpointGroupName returns the name of the API server network endpoint group in the zone,
+// which is the name of the instance group of the zone, the resources being of different types.
+func (s *Service) APIServerNetworkEndpointGroupName(zone string) string {
+	return s.APIServerInstanceGroupName(zone)
+}
+
+// GetOrCreateNetworkEndpointGroup retrieves a network endpoint group or creates it.
+func (s *Service) GetOrCreateNetworkEndpointGroup(zone, name string) (*compute.NetworkEndpointGroup, error) {
+	group, err := s.networkendpointgroups.Get(s.scope.Project(), zone, name).Do()
+	if gcperrors.IsNotFound(err) {
+		spec := &compute.NetworkEndpointGroup{
+			Name:                name,
+			Description:         s.ownershipMarker(),
+			Network:             s.scope.NetworkSelfLink(),
+			NetworkEndpointType: APIServerNetworkEndpointType,
+			DefaultPort:         s.scope.LoadBalancerBackendPort(),
+		}
+		if err := s.runInsertOperation(path.Join("zones", zone, "networkEndpointGroups", name), func() (*compute.Operation, error) {
+			return s.networkendpointgroups.Insert(s.scope.Project(), zone, spec).Do()
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to create network endpoint group")
+		}
+		group, err = s.networkendpointgroups.Get(s.scope.Project(), zone, name).Do()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe network endpoint group")
+		}
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe network endpoint group")
+	}
+
+	return group, nil
+}
+
+// GetNetworkEndpoints retrieves the endpoints of a network endpoint group.
+func (s *Service) GetNetworkEndpoints(zone, name string) ([]*compute.NetworkEndpoint, error) {
+	res, err := s.networkendpointgroups.
+		ListNetworkEndpoints(s.scope.Project(), zone, name, &compute.NetworkEndpointGroupsListEndpointsRequest{}).
+		Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not list endpoints in network endpoint group %q", name)
+	}
+
+	endpoints := make([]*compute.NetworkEndpoint, 0, len(res.Items))
+	for _, item := range res.Items {
+		if item.NetworkEndpoint != nil {
+			endpoints = append(endpoints, item.NetworkEndpoint)
+		}
+	}
+
+	return endpoints, nil
+}
+
+// EnsureNetworkEndpoint ensures the API server endpoint of the instance is attached to the network endpoint group.
+// The IP of the endpoint is the primary IP of the instance in the network of the group.
+func (s *Service) EnsureNetworkEndpoint(zone, name string, i *compute.Instance) error {
+	endpoints, err := s.GetNetworkEndpoints(zone, name)
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.Instance == i.Name {
+			return nil
+		}
+	}
+
+	req := &compute.NetworkEndpointGroupsAttachEndpointsRequest{
+		NetworkEndpoints: []*compute.NetworkEndpoint{
+			{
+				Instance: i.Name,
+				Port:     s.scope.LoadBalancerBackendPort(),
+			},
+		},
+	}
+	if _, err := s.runOperation(path.Join("zones", zone, "networkEndpointGroups", name), "attachNetworkEndpoints", func() (*compute.Operation, error) {
+		return s.networkendpointgroups.AttachNetworkEndpoints(s.scope.Project(), zone, name, req).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to attach instance to network endpoint group")
+	}
+
+	return nil
+}
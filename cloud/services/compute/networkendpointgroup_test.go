@@ -0,0 +1,92 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"path"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestNetworkEndpointGroupBackends(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.LoadBalancer.BackendType = infrav1.LoadBalancerBackendNetworkEndpointGroup
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileBackendGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	// The control plane enters zone a with two instances, then zone b with one.
+	instances := map[string]*compute.Instance{}
+	for _, name := range []string{"us-central1-a/my-machine-0", "us-central1-a/my-machine-1", "us-central1-b/my-machine-2"} {
+		zone := path.Dir(name)
+		p := "projects/my-project/zones/" + zone + "/instances/" + path.Base(name)
+		instances[name] = &compute.Instance{Name: path.Base(name), Zone: c.SelfLink("projects/my-project/zones/" + zone), SelfLink: c.SelfLink(p)}
+		group, err := s.GetOrCreateNetworkEndpointGroup(zone, s.APIServerNetworkEndpointGroupName(zone))
+		g.Expect(err).NotTo(HaveOccurred())
+		g.Expect(s.EnsureNetworkEndpoint(zone, group.Name, instances[name])).To(Succeed())
+		g.Expect(s.EnsureNetworkEndpoint(zone, group.Name, instances[name])).To(Succeed())
+		g.Expect(s.UpdateBackendServices()).To(Succeed())
+	}
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instanceGroups")).To(BeEmpty())
+	endpoints, err := s.GetNetworkEndpoints("us-central1-a", s.APIServerNetworkEndpointGroupName("us-central1-a"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(endpoints).To(HaveLen(2))
+	g.Expect(endpoints[0].Port).To(Equal(int64(6443)))
+
+	backendService := &compute.BackendService{}
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.PortName).To(BeEmpty())
+	g.Expect(backendService.Backends).To(HaveLen(2))
+	g.Expect(backendService.Backends[0].BalancingMode).To(Equal("CONNECTION"))
+
+	healthy, total, err := s.GetAPIServerBackendsHealth()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(healthy).To(Equal(3))
+	g.Expect(total).To(Equal(3))
+	health, err := s.GetAPIServerBackendHealth(s.scope.Network().APIServerNetworkEndpointGroups["us-central1-b"], instances["us-central1-b/my-machine-2"])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(health).To(Equal("HEALTHY"))
+
+	// The endpoint is detached, the group of zone a is kept while it has endpoints.
+	g.Expect(s.ReleaseNetworkEndpointGroup("us-central1-a", instances["us-central1-a/my-machine-0"])).To(Succeed())
+	endpoints, err = s.GetNetworkEndpoints("us-central1-a", s.APIServerNetworkEndpointGroupName("us-central1-a"))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(endpoints).To(HaveLen(1))
+	g.Expect(endpoints[0].Instance).To(Equal("my-machine-1"))
+
+	// The group of zone b is removed from the backend service and deleted once the zone is left.
+	g.Expect(s.ReleaseNetworkEndpointGroup("us-central1-b", instances["us-central1-b/my-machine-2"])).To(Succeed())
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/networkEndpointGroups")).To(BeEmpty())
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.Backends).To(HaveLen(1))
+	g.Expect(backendService.Backends[0].Group).To(Equal(s.scope.Network().APIServerNetworkEndpointGroups["us-central1-a"]))
+
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(s.DeleteBackendGroups()).To(Succeed())
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/networkEndpointGroups")).To(BeEmpty())
+	g.Expect(s.scope.Network().APIServerNetworkEndpointGroups).To(BeEmpty())
+}
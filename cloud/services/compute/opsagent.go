@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+)
+
+const (
+	// cosLoggingEnabledKey and cosMonitoringEnabledKey are the metadata keys enabling the logging and
+	// monitoring agents shipped with Container-Optimized OS, which doesn't support the Ops Agent.
+	cosLoggingEnabledKey    = "google-logging-enabled"
+	cosMonitoringEnabledKey = "google-monitoring-enabled"
+
+	// opsAgentScript installs the Ops Agent with its repository script,
+	// see https://cloud.google.com/stackdriver/docs/solutions/agents/ops-agent/installation.
+	opsAgentScript = `#! /bin/bash
+if ! systemctl is-active --quiet google-cloud-ops-agent; then
+  curl -fsSL https://dl.google.com/cloudagents/add-google-cloud-ops-agent-repo.sh --output /tmp/add-google-cloud-ops-agent-repo.sh
+  bash /tmp/add-google-cloud-ops-agent-repo.sh --also-install
+fi
+`
+)
+
+// opsAgentScopes are the scopes needed by the Ops Agent to write logs and metrics.
+var opsAgentScopes = []string{
+	"https://www.googleapis.com/auth/logging.write",
+	"https://www.googleapis.com/auth/monitoring.write",
+}
+
+// opsAgentMetadata returns the metadata sending the system logs and metrics of the instances to Cloud Logging
+// and Cloud Monitoring, with the agents of Container-Optimized OS if cos is true.
+func opsAgentMetadata(cos bool) []*compute.MetadataItems {
+	if cos {
+		return []*compute.MetadataItems{
+			{Key: cosLoggingEnabledKey, Value: pointer.StringPtr("true")},
+			{Key: cosMonitoringEnabledKey, Value: pointer.StringPtr("true")},
+		}
+	}
+
+	return []*compute.MetadataItems{
+		{Key: startupScriptKey, Value: pointer.StringPtr(opsAgentScript)},
+	}
+}
@@ -0,0 +1,93 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestCreateInstanceOpsAgent(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+
+	// The Ops Agent is installed after the GPU driver.
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:      "n1-standard-4",
+			Image:             pointer.StringPtr("my-image"),
+			GuestAccelerators: []infrav1.Accelerator{{Type: "nvidia-tesla-t4", Count: 1}},
+			InstallGPUDriver:  true,
+			InstallOpsAgent:   true,
+			ServiceAccount: &infrav1.ServiceAccount{
+				Email:  "sa@my-project.iam.gserviceaccount.com",
+				Scopes: []string{compute.DevstorageReadOnlyScope},
+			},
+		},
+	})
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(startupScriptKey, linuxGPUDriverScript+opsAgentScript))
+	g.Expect(instance.ServiceAccounts[0].Scopes).To(ConsistOf(append([]string{compute.DevstorageReadOnlyScope}, opsAgentScopes...)))
+
+	// Container-Optimized OS has its own agents.
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cos-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:    "n1-standard-2",
+			Image:           pointer.StringPtr("projects/cos-cloud/global/images/family/cos-stable"),
+			InstallOpsAgent: true,
+		},
+	})
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(cosLoggingEnabledKey, "true"))
+	g.Expect(instanceMetadata(instance)).To(HaveKeyWithValue(cosMonitoringEnabledKey, "true"))
+	g.Expect(instanceMetadata(instance)).NotTo(HaveKey(startupScriptKey))
+	g.Expect(instance.ServiceAccounts[0].Scopes).To(ConsistOf(compute.CloudPlatformScope))
+
+	// The installation script can't be downloaded without public egress.
+	params := newTestClusterScopeParams(g, c)
+	params.GoogleAccess = cloud.RestrictedGoogleAccess
+	clusterScope = newTestClusterScopeFromParams(g, params)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s = NewService(clusterScope)
+
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-restricted-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:    "n1-standard-2",
+			Image:           pointer.StringPtr("my-image"),
+			InstallOpsAgent: true,
+		},
+	}
+	instance = createTestInstance(g, s, gcpMachine)
+	g.Expect(instanceMetadata(instance)).NotTo(HaveKey(startupScriptKey))
+	g.Expect(conditions.IsFalse(gcpMachine, infrav1.FeaturesAvailableCondition)).To(BeTrue())
+	g.Expect(conditions.GetMessage(gcpMachine, infrav1.FeaturesAvailableCondition)).To(HavePrefix("installOpsAgent:"))
+}
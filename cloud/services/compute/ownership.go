@@ -0,0 +1,346 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+// defaultNetworkName is the name of the network GCP creates in every project, it's never adopted.
+const defaultNetworkName = "default"
+
+// createdByKey is the metadata key set by a managed instance group to the URL of the group on its instances.
+const createdByKey = "created-by"
+
+// ownershipLabels returns the labels marking the resources owned by the cluster,
+// used instead of the description for the resources supporting labels.
+func (s *Service) ownershipLabels(role string) infrav1.Labels {
+	return infrav1.Build(infrav1.BuildParams{
+		ClusterName:      s.scope.Name(),
+		ClusterNamespace: s.scope.Namespace(),
+		Lifecycle:        infrav1.ResourceLifecycleOwned,
+		Role:             pointer.StringPtr(role),
+		Additional:       s.scope.GCPCluster.Spec.AdditionalLabels,
+	})
+}
+
+// ownershipFilter returns the filter matching the labelled resources owned by the cluster, and not by a cluster
+// of the same name in another namespace.
+func (s *Service) ownershipFilter() string {
+	return infrav1.Labels{
+		infrav1.ClusterTagKey(s.scope.Name()): string(infrav1.ResourceLifecycleOwned),
+		infrav1.NameGCPClusterNamespace:       s.scope.Namespace(),
+	}.ToComputeFilter()
+}
+
+// ownershipMarker returns the description set on the resources created by the cluster which don't support labels.
+// It lets the ownership of the resources survive a clusterctl move, which doesn't move the inventory of the status,
+// and is never set on existing resources, whose description belongs to their users.
+func (s *Service) ownershipMarker() string {
+	return infrav1.ClusterTagKey(s.scope.Name())
+}
+
+// namespacedOwnershipMarker returns the description set on the firewall rules and global addresses created by the
+// cluster, which unlike the ownershipMarker tells apart the same-named clusters of different namespaces, so that
+// their orphans are found as the ones of the resources supporting labels.
+func (s *Service) namespacedOwnershipMarker() string {
+	return fmt.Sprintf("%s/%s", s.ownershipMarker(), s.scope.Namespace())
+}
+
+// isOwned returns true if the resource at the path is recorded in the inventory of the cluster, or if its
+// description marks it as owned by the cluster, in which case it's recorded in the inventory.
+func (s *Service) isOwned(resource, description string) bool {
+	if s.scope.IsOwnedResource(resource) {
+		return true
+	}
+	if description == s.ownershipMarker() || description == s.namespacedOwnershipMarker() {
+		s.scope.SetOwnedResource(resource, true)
+		return true
+	}
+
+	return false
+}
+
+// adopt records the pre-existing resource at the path as owned by the cluster if it isn't already
+// and the cluster must adopt it, and returns true if it did.
+func (s *Service) adopt(kind, resource, description string) bool {
+	if s.isOwned(resource, description) || !s.scope.ShouldAdopt() {
+		return false
+	}
+	s.scope.SetOwnedResource(resource, true)
+	s.recordAdopted(kind, path.Base(resource))
+
+	return true
+}
+
+// ensureOwned returns an error unless the pre-existing resource at the path is owned by the cluster or adopted by it,
+// so that the same-named resources of other clusters or created out-of-band are never modified.
+func (s *Service) ensureOwned(kind, resource, description string) error {
+	if s.isOwned(resource, description) || s.adopt(kind, resource, description) {
+		return nil
+	}
+
+	return errors.Errorf("%s %q isn't owned by the cluster, set the %s annotation to adopt it", kind, path.Base(resource), infrav1.AdoptAnnotation)
+}
+
+// ensureOwnedLabelled is ensureOwned for the resources whose ownership is marked by their labels instead of their
+// description, e.g. the forwarding rules.
+func (s *Service) ensureOwnedLabelled(kind, resource string, labels map[string]string) error {
+	return s.ensureOwned(kind, resource, s.labelledOwnershipMarker(labels))
+}
+
+// labelledOwnershipMarker returns the ownership marker of the resource if its labels mark it as owned by the cluster,
+// or an empty string.
+func (s *Service) labelledOwnershipMarker(labels map[string]string) string {
+	if labels[infrav1.ClusterTagKey(s.scope.Name())] == string(infrav1.ResourceLifecycleOwned) {
+		return s.ownershipMarker()
+	}
+
+	return ""
+}
+
+// runOwnedDeleteOperation runs the delete operation of the resource at the path unless it's neither recorded in the
+// inventory nor marked as owned by the cluster, so that deleting by name never removes the same-named resources of
+// other clusters or created out-of-band. The description is only looked up for the resources missing from the inventory.
+func (s *Service) runOwnedDeleteOperation(kind, resource string, describe func() (string, error), issue func() (*compute.Operation, error)) error {
+	if !s.scope.IsOwnedResource(resource) {
+		description, err := describe()
+		if gcperrors.IsNotFound(err) {
+			return nil
+		} else if err != nil {
+			return errors.Wrapf(err, "failed to describe %s %q", kind, path.Base(resource))
+		}
+		if !s.isOwned(resource, description) {
+			s.scope.Info("Skipped deletion of GCP resource not owned by the cluster", "kind", kind, "name", path.Base(resource))
+			return nil
+		}
+	}
+
+	return s.runDeleteOperation(resource, issue)
+}
+
+// isNetworkOwned returns true if the network was created or adopted by the cluster.
+// The default network is never adopted.
+func (s *Service) isNetworkOwned(network *compute.Network) bool {
+	resource := path.Join("global", "networks", network.Name)
+	if network.Name == defaultNetworkName {
+		return s.isOwned(resource, network.Description)
+	}
+
+	return s.isOwned(resource, network.Description) || s.adopt("network", resource, network.Description)
+}
+
+// recordRetained emits an event on the GCPCluster when a resource is retained on cluster deletion.
+func (s *Service) recordRetained(kind, name string) {
+	s.scope.Info("Retained GCP resource", "kind", kind, "name", name)
+	record.Eventf(s.scope.GCPCluster, "RetainedResource", "Retained %s %q", kind, name)
+}
+
+// recordAdopted emits an event on the GCPCluster when a pre-existing resource has been adopted.
+func (s *Service) recordAdopted(kind, name string) {
+	s.scope.Info("Adopted GCP resource", "kind", kind, "name", name)
+	record.Eventf(s.scope.GCPCluster, "AdoptedResource", "Adopted pre-existing %s %q", kind, name)
+}
+
+// DeleteOrphanedResources deletes the resources owned by the cluster which were missed by the normal delete flow,
+// e.g. because their creation was not recorded in the status, their GCPMachine was removed without deleting them,
+// or they were detached from a deleted instance. The instances are deleted only if they carry the UID label of the
+// Cluster and no GCPMachine of the cluster owns them, the disks unless they were retained on purpose once detached.
+// The resources supporting labels, i.e. the instances, the forwarding rules and the disks, are found by their
+// ownership and namespace labels, which unlike a description survive their edition, and unlike the name of the
+// cluster tell apart the same-named clusters of different namespaces. The addresses and firewall rules don't support
+// labels and are found by their description, which holds the namespace of the cluster too. The resources created
+// before the namespace was recorded are left to the normal delete flow.
+func (s *Service) DeleteOrphanedResources() error {
+	ctx := context.TODO()
+	filter := s.ownershipFilter()
+
+	instances := []*compute.Instance{}
+	if err := s.instances.AggregatedList(s.scope.Project()).Filter(filter).Pages(ctx, func(list *compute.InstanceAggregatedList) error {
+		for _, scoped := range list.Items {
+			instances = append(instances, scoped.Instances...)
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to list instances")
+	}
+	machineInstances, err := s.scope.MachineInstanceNames(ctx)
+	if err != nil {
+		return err
+	}
+	for _, instance := range instances {
+		// The instances of the machine pools are deleted with their managed instance group.
+		if isManagedInstance(instance) {
+			continue
+		}
+		// Only the instances of this very Cluster, relabelled with its UID once moved, which no GCPMachine owns
+		// are deleted, a live instance being worse to lose than an orphan to leave behind.
+		if s.scope.Cluster.UID == "" || instance.Labels[infrav1.NameGCPClusterUID] != string(s.scope.Cluster.UID) || machineInstances.Has(instance.Name) {
+			continue
+		}
+		zone := path.Base(instance.Zone)
+		if err := s.deleteOrphan("instance", path.Join("zones", zone, "instances", instance.Name), func() (*compute.Operation, error) {
+			return s.instances.Delete(s.scope.Project(), zone, instance.Name).Do()
+		}); err != nil {
+			return err
+		}
+	}
+
+	forwardingRules := []*compute.ForwardingRule{}
+	if err := s.forwardingrules.List(s.scope.Project()).Filter(filter).Pages(ctx, func(list *compute.ForwardingRuleList) error {
+		forwardingRules = append(forwardingRules, list.Items...)
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to list forwarding rules")
+	}
+	for _, forwardingRule := range forwardingRules {
+		if err := s.deleteOrphan("forwarding rule", path.Join("global", "forwardingRules", forwardingRule.Name), func() (*compute.Operation, error) {
+			return s.forwardingrules.Delete(s.scope.Project(), forwardingRule.Name).Do()
+		}); err != nil {
+			return err
+		}
+	}
+
+	regionForwardingRules := []*compute.ForwardingRule{}
+	if err := s.regionforwardingrules.AggregatedList(s.scope.Project()).Filter(filter).Pages(ctx, func(list *compute.ForwardingRuleAggregatedList) error {
+		for _, scoped := range list.Items {
+			regionForwardingRules = append(regionForwardingRules, scoped.ForwardingRules...)
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to list regional forwarding rules")
+	}
+	for _, forwardingRule := range regionForwardingRules {
+		region := path.Base(forwardingRule.Region)
+		if err := s.deleteOrphan("forwarding rule", path.Join("regions", region, "forwardingRules", forwardingRule.Name), func() (*compute.Operation, error) {
+			return s.regionforwardingrules.Delete(s.scope.Project(), region, forwardingRule.Name).Do()
+		}); err != nil {
+			return err
+		}
+	}
+
+	descriptionFilter := fmt.Sprintf("description = %q", s.namespacedOwnershipMarker())
+
+	addresses := []*compute.Address{}
+	if err := s.addresses.List(s.scope.Project()).Filter(descriptionFilter).Pages(ctx, func(list *compute.AddressList) error {
+		addresses = append(addresses, list.Items...)
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to list global addresses")
+	}
+	for _, address := range addresses {
+		if address.Name == s.apiServerLoadBalancerName() && s.scope.ShouldRetain(infrav1.RetainAPIServerAddress) {
+			continue
+		}
+		if address.Name == s.privateServicesRangeName() {
+			// Deleted with the private connection of the network.
+			continue
+		}
+		if err := s.deleteOrphan("global address", path.Join("global", "addresses", address.Name), func() (*compute.Operation, error) {
+			return s.addresses.Delete(s.scope.Project(), address.Name).Do()
+		}); err != nil {
+			return err
+		}
+	}
+
+	disks := []*compute.Disk{}
+	if err := s.disks.AggregatedList(s.scope.Project()).Filter(filter).Pages(ctx, func(list *compute.DiskAggregatedList) error {
+		for _, scoped := range list.Items {
+			disks = append(disks, scoped.Disks...)
+		}
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to list disks")
+	}
+	for _, disk := range disks {
+		// Disks still attached are deleted with their instance, the root volumes kept on purpose are left alone.
+		if len(disk.Users) > 0 || disk.Labels[infrav1.NameGCPRetained] == "true" {
+			continue
+		}
+		zone := path.Base(disk.Zone)
+		if err := s.deleteOrphan("disk", path.Join("zones", zone, "disks", disk.Name), func() (*compute.Operation, error) {
+			return s.disks.Delete(s.scope.Project(), zone, disk.Name).Do()
+		}); err != nil {
+			return err
+		}
+	}
+
+	firewalls := []*compute.Firewall{}
+	if err := s.firewalls.List(s.scope.Project()).Filter(descriptionFilter).Pages(ctx, func(list *compute.FirewallList) error {
+		firewalls = append(firewalls, list.Items...)
+		return nil
+	}); err != nil {
+		return errors.Wrapf(err, "failed to list firewall rules")
+	}
+	for _, firewall := range firewalls {
+		if s.scope.HasFirewallRule(firewall.Name) || s.scope.ShouldRetain(infrav1.RetainFirewallRules) {
+			// Deleted by the normal delete flow.
+			continue
+		}
+		if err := s.deleteOrphan("firewall rule", path.Join("global", "firewalls", firewall.Name), func() (*compute.Operation, error) {
+			return s.firewalls.Delete(s.scope.Project(), firewall.Name).Do()
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isManagedInstance returns true if the instance was created by a managed instance group.
+func isManagedInstance(instance *compute.Instance) bool {
+	if instance.Metadata == nil {
+		return false
+	}
+	for _, item := range instance.Metadata.Items {
+		if item.Key == createdByKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deleteOrphan runs the delete operation of the orphaned resource, a resource which doesn't exist is ignored.
+func (s *Service) deleteOrphan(kind, resource string, issue func() (*compute.Operation, error)) error {
+	op, err := s.runOperation(resource, "delete", issue)
+	switch {
+	case gcperrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to delete orphaned %s %q", kind, path.Base(resource))
+	}
+	s.recordOrphanDeleted(kind, path.Base(resource), op)
+
+	return nil
+}
+
+// recordOrphanDeleted emits an event on the GCPCluster when an orphaned resource has been deleted by the operation.
+func (s *Service) recordOrphanDeleted(kind, name string, op *compute.Operation) {
+	s.scope.Info("Deleted orphaned GCP resource", "kind", kind, "name", name)
+	record.Eventf(s.scope.GCPCluster, "DeletedOrphanedResource", "Deleted orphaned %s %q%s", kind, name, operationDetails(op))
+}
@@ -0,0 +1,537 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+func TestReconcileAdopt(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	c.Put("projects/my-project/global/networks/my-network", &compute.Network{Description: "created by terraform"})
+	c.Put("projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster", &compute.Firewall{Description: "created by terraform"})
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Annotations = map[string]string{infrav1.AdoptAnnotation: ""}
+	params.GCPCluster.Spec.Network.Name = pointer.StringPtr("my-network")
+	clusterScope, err := scope.NewClusterScope(params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+
+	// The router of the adopted network is managed by the cluster.
+	router := &compute.Router{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/routers/my-network-router", router)).To(BeTrue())
+	g.Expect(router.Nats).To(HaveLen(1))
+
+	firewall := &compute.Firewall{}
+	g.Expect(c.Get("projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster", firewall)).To(BeTrue())
+	g.Expect(firewall.Description).To(Equal("created by terraform"))
+	g.Expect(clusterScope.GCPCluster.Status.OwnedResources).To(ContainElements(
+		"global/networks/my-network",
+		"global/firewalls/allow-my-cluster-apiserver-cluster",
+	))
+
+	// The adopted resources stay owned once the cluster doesn't ask for adoption anymore.
+	delete(clusterScope.GCPCluster.Annotations, infrav1.AdoptAnnotation)
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/networks/my-network", nil)).To(BeFalse())
+	g.Expect(clusterScope.GCPCluster.Status.OwnedResources).NotTo(ContainElement("global/networks/my-network"))
+}
+
+func TestReconcileNotOwned(t *testing.T) {
+	// The pre-existing resources of the same name, e.g. of another cluster or created out-of-band, are neither owned
+	// nor adopted: they are left as they are and the reconcile fails, but for the subnetworks which are used as is.
+	reconcileLoadbalancers := func(s *Service) error {
+		if err := s.ReconcileNetwork(); err != nil {
+			return err
+		}
+		if err := s.ReconcileInstanceGroups(); err != nil {
+			return err
+		}
+		return s.ReconcileLoadbalancers()
+	}
+	reconcileNetwork := func(s *Service) error { return s.ReconcileNetwork() }
+	tests := []struct {
+		name      string
+		resource  string
+		object    interface{}
+		setup     func(spec *infrav1.GCPClusterSpec)
+		reconcile func(s *Service) error
+		succeeds  bool
+	}{
+		{
+			name:      "firewall rule",
+			resource:  "global/firewalls/allow-my-cluster-apiserver-cluster",
+			object:    &compute.Firewall{Name: "allow-my-cluster-apiserver-cluster", Disabled: true},
+			reconcile: func(s *Service) error { return s.ReconcileFirewalls() },
+		},
+		{
+			name:      "health check",
+			resource:  "global/healthChecks/my-cluster-apiserver",
+			object:    &compute.HealthCheck{Name: "my-cluster-apiserver", Type: "TCP"},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:      "global address",
+			resource:  "global/addresses/my-cluster-apiserver",
+			object:    &compute.Address{Name: "my-cluster-apiserver", Address: "1.2.3.4"},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:      "backend service",
+			resource:  "global/backendServices/my-cluster-apiserver",
+			object:    &compute.BackendService{Name: "my-cluster-apiserver", Protocol: "HTTP"},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:      "target proxy",
+			resource:  "global/targetTcpProxies/my-cluster-apiserver",
+			object:    &compute.TargetTcpProxy{Name: "my-cluster-apiserver", ProxyHeader: "PROXY_V1"},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:      "forwarding rule",
+			resource:  "global/forwardingRules/my-cluster-apiserver",
+			object:    &compute.ForwardingRule{Name: "my-cluster-apiserver", IPAddress: "1.2.3.4", PortRange: "80-80"},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:     "IPv6 address",
+			resource: "global/addresses/my-cluster-apiserver-ipv6",
+			object:   &compute.Address{Name: "my-cluster-apiserver-ipv6", Address: "2600::1"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.StackType = infrav1.StackTypeIPv4IPv6
+			},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:     "IPv6 forwarding rule",
+			resource: "global/forwardingRules/my-cluster-apiserver-ipv6",
+			object:   &compute.ForwardingRule{Name: "my-cluster-apiserver-ipv6", IPAddress: "2600::1", PortRange: "80-80"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.StackType = infrav1.StackTypeIPv4IPv6
+			},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:     "internal address",
+			resource: "regions/us-central1/addresses/my-cluster-apiserver",
+			object:   &compute.Address{Name: "my-cluster-apiserver", Address: "10.0.0.2"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.Scheme = infrav1.LoadBalancerSchemeInternal
+			},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:     "regional backend service",
+			resource: "regions/us-central1/backendServices/my-cluster-apiserver",
+			object:   &compute.BackendService{Name: "my-cluster-apiserver", Protocol: "UDP"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.Scheme = infrav1.LoadBalancerSchemeInternal
+			},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:     "regional forwarding rule",
+			resource: "regions/us-central1/forwardingRules/my-cluster-apiserver",
+			object:   &compute.ForwardingRule{Name: "my-cluster-apiserver", IPAddress: "10.0.0.2", Ports: []string{"80"}},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.Scheme = infrav1.LoadBalancerSchemeInternal
+			},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:     "regional address",
+			resource: "regions/us-central1/addresses/my-cluster-apiserver",
+			object:   &compute.Address{Name: "my-cluster-apiserver", Address: "1.2.3.4"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.LoadBalancer.Type = infrav1.LoadBalancerTypeTargetInstance
+			},
+			reconcile: reconcileLoadbalancers,
+		},
+		{
+			name:      "router",
+			resource:  "regions/us-central1/routers/default-router",
+			object:    &compute.Router{Name: "default-router"},
+			reconcile: reconcileNetwork,
+		},
+		{
+			name:     "route",
+			resource: "global/routes/my-cluster-pods",
+			object:   &compute.Route{Name: "my-cluster-pods", DestRange: "192.168.0.0/16", NextHopIp: "10.128.0.3"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.Network.Routes = []infrav1.RouteSpec{{Name: "pods", DestRange: "192.168.0.0/16", NextHopIP: pointer.StringPtr("10.128.0.2")}}
+			},
+			reconcile: reconcileNetwork,
+		},
+		{
+			name:     "private services range",
+			resource: "global/addresses/my-cluster-private-services",
+			object:   &compute.Address{Name: "my-cluster-private-services", Purpose: "VPC_PEERING"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.Network.PrivateServicesAccess = &infrav1.PrivateServicesAccessSpec{}
+			},
+			reconcile: reconcileNetwork,
+		},
+		{
+			name:     "subnetwork",
+			resource: "regions/us-central1/subnetworks/nodes",
+			object:   &compute.Subnetwork{Name: "nodes", IpCidrRange: "10.1.0.0/16", Region: "us-central1"},
+			setup: func(spec *infrav1.GCPClusterSpec) {
+				spec.Network.AutoCreateSubnetworks = pointer.BoolPtr(false)
+				spec.Network.Subnets = infrav1.Subnets{{Name: "nodes", CidrBlock: "10.1.0.0/16", PrivateGoogleAccess: pointer.BoolPtr(true)}}
+			},
+			reconcile: reconcileNetwork,
+			succeeds:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := fakecloud.NewCloud()
+			defer c.Close()
+
+			c.Put("projects/my-project/"+tt.resource, tt.object)
+			before := map[string]interface{}{}
+			g.Expect(c.Get("projects/my-project/"+tt.resource, &before)).To(BeTrue())
+
+			params := newTestClusterScopeParams(g, c)
+			if tt.setup != nil {
+				tt.setup(&params.GCPCluster.Spec)
+			}
+			clusterScope := newTestClusterScopeFromParams(g, params)
+			err := tt.reconcile(NewService(clusterScope))
+			if tt.succeeds {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring("isn't owned by the cluster")))
+			}
+
+			after := map[string]interface{}{}
+			g.Expect(c.Get("projects/my-project/"+tt.resource, &after)).To(BeTrue())
+			g.Expect(after).To(Equal(before))
+			g.Expect(clusterScope.GCPCluster.Status.OwnedResources).NotTo(ContainElement(tt.resource))
+		})
+	}
+}
+
+func TestDeleteNotOwned(t *testing.T) {
+	// The resources are deleted by name, the same-named resources which aren't owned by the cluster are skipped.
+	deleteFirewalls := func(s *Service) error { return s.DeleteFirewalls() }
+	deleteLoadbalancers := func(s *Service) error { return s.DeleteLoadbalancers() }
+	internal := func(spec *infrav1.GCPClusterSpec) { spec.LoadBalancer.Scheme = infrav1.LoadBalancerSchemeInternal }
+	targetInstance := func(spec *infrav1.GCPClusterSpec) { spec.LoadBalancer.Type = infrav1.LoadBalancerTypeTargetInstance }
+	tests := []struct {
+		name     string
+		resource string
+		object   interface{}
+		setup    func(spec *infrav1.GCPClusterSpec)
+		delete   func(s *Service) error
+		deleted  bool
+	}{
+		{
+			name:     "firewall rule",
+			resource: "global/firewalls/allow-my-cluster-apiserver-cluster",
+			object:   &compute.Firewall{Name: "allow-my-cluster-apiserver-cluster"},
+			delete:   deleteFirewalls,
+		},
+		{
+			name:     "owned firewall rule",
+			resource: "global/firewalls/allow-my-cluster-apiserver-cluster",
+			object:   &compute.Firewall{Name: "allow-my-cluster-apiserver-cluster", Description: infrav1.ClusterTagKey("my-cluster")},
+			delete:   deleteFirewalls,
+			deleted:  true,
+		},
+		{
+			name:     "forwarding rule",
+			resource: "global/forwardingRules/my-cluster-apiserver",
+			object:   &compute.ForwardingRule{Name: "my-cluster-apiserver"},
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "owned forwarding rule",
+			resource: "global/forwardingRules/my-cluster-apiserver",
+			object:   &compute.ForwardingRule{Name: "my-cluster-apiserver", Labels: map[string]string{infrav1.ClusterTagKey("my-cluster"): "owned"}},
+			delete:   deleteLoadbalancers,
+			deleted:  true,
+		},
+		{
+			name:     "global address",
+			resource: "global/addresses/my-cluster-apiserver",
+			object:   &compute.Address{Name: "my-cluster-apiserver"},
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "target proxy",
+			resource: "global/targetTcpProxies/my-cluster-apiserver",
+			object:   &compute.TargetTcpProxy{Name: "my-cluster-apiserver"},
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "backend service",
+			resource: "global/backendServices/my-cluster-apiserver",
+			object:   &compute.BackendService{Name: "my-cluster-apiserver"},
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "health check",
+			resource: "global/healthChecks/my-cluster-apiserver",
+			object:   &compute.HealthCheck{Name: "my-cluster-apiserver"},
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "IPv6 forwarding rule",
+			resource: "global/forwardingRules/my-cluster-apiserver-ipv6",
+			object:   &compute.ForwardingRule{Name: "my-cluster-apiserver-ipv6"},
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "IPv6 address",
+			resource: "global/addresses/my-cluster-apiserver-ipv6",
+			object:   &compute.Address{Name: "my-cluster-apiserver-ipv6"},
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "regional forwarding rule",
+			resource: "regions/us-central1/forwardingRules/my-cluster-apiserver",
+			object:   &compute.ForwardingRule{Name: "my-cluster-apiserver"},
+			setup:    internal,
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "internal address",
+			resource: "regions/us-central1/addresses/my-cluster-apiserver",
+			object:   &compute.Address{Name: "my-cluster-apiserver"},
+			setup:    internal,
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "regional backend service",
+			resource: "regions/us-central1/backendServices/my-cluster-apiserver",
+			object:   &compute.BackendService{Name: "my-cluster-apiserver"},
+			setup:    internal,
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "target instance",
+			resource: "zones/us-central1-a/targetInstances/my-cluster-apiserver",
+			object:   &compute.TargetInstance{Name: "my-cluster-apiserver"},
+			setup:    targetInstance,
+			delete:   deleteLoadbalancers,
+		},
+		{
+			name:     "regional address",
+			resource: "regions/us-central1/addresses/my-cluster-apiserver",
+			object:   &compute.Address{Name: "my-cluster-apiserver"},
+			setup:    targetInstance,
+			delete:   deleteLoadbalancers,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+			c := fakecloud.NewCloud()
+			defer c.Close()
+
+			c.Put("projects/my-project/"+tt.resource, tt.object)
+
+			params := newTestClusterScopeParams(g, c)
+			if tt.setup != nil {
+				tt.setup(&params.GCPCluster.Spec)
+			}
+			clusterScope := newTestClusterScopeFromParams(g, params)
+			g.Expect(tt.delete(NewService(clusterScope))).To(Succeed())
+			g.Expect(c.Get("projects/my-project/"+tt.resource, nil)).To(Equal(!tt.deleted))
+		})
+	}
+}
+
+func TestOwnershipMigration(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	// The network was created before the inventory, its description marks it as owned.
+	c.Put("projects/my-project/global/networks/my-network", &compute.Network{Name: "my-network", Description: infrav1.ClusterTagKey("my-cluster")})
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.Network.Name = pointer.StringPtr("my-network")
+	clusterScope := newTestClusterScopeFromParams(g, params)
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(clusterScope.GCPCluster.Status.OwnedResources).To(ConsistOf(
+		"global/networks/my-network",
+		"regions/us-central1/routers/my-network-router",
+	))
+}
+
+func TestReconcileAdoptDefaultNetwork(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	c.Put("projects/my-project/global/networks/default", &compute.Network{Description: "Default network for the project"})
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Annotations = map[string]string{infrav1.AdoptAnnotation: ""}
+	clusterScope, err := scope.NewClusterScope(params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(c.List("projects/my-project/regions/us-central1/routers")).To(BeEmpty())
+
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/networks/default", nil)).To(BeTrue())
+}
+
+func TestDeleteOrphanedResources(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	owned := map[string]string{infrav1.ClusterTagKey("my-cluster"): string(infrav1.ResourceLifecycleOwned), infrav1.NameGCPClusterNamespace: "default"}
+	// The same-named cluster of another namespace owns its own resources.
+	otherNamespace := map[string]string{infrav1.ClusterTagKey("my-cluster"): string(infrav1.ResourceLifecycleOwned), infrav1.NameGCPClusterNamespace: "other"}
+	// The instances are only deleted with the UID label of the Cluster.
+	ownedInstance := infrav1.Labels{infrav1.NameGCPClusterUID: "my-uid"}.AddLabels(owned)
+	c.Put("projects/my-project/zones/us-central1-a/disks/orphan", &compute.Disk{Labels: owned, Zone: "us-central1-a"})
+	c.Put("projects/my-project/zones/us-central1-a/disks/other-namespace", &compute.Disk{Labels: otherNamespace, Zone: "us-central1-a"})
+	c.Put("projects/my-project/zones/us-central1-b/disks/attached", &compute.Disk{Labels: owned, Zone: "us-central1-b", Users: []string{"my-instance"}})
+	c.Put("projects/my-project/zones/us-central1-a/disks/not-owned", &compute.Disk{Zone: "us-central1-a"})
+	// The root volumes kept once their instance is deleted aren't orphans.
+	c.Put("projects/my-project/zones/us-central1-a/disks/retained", &compute.Disk{
+		Labels: infrav1.Labels{infrav1.NameGCPRetained: "true"}.AddLabels(owned),
+		Zone:   "us-central1-a",
+	})
+	// The addresses and firewall rules are found by their description, with the namespace of the cluster.
+	c.Put("projects/my-project/global/addresses/orphan", &compute.Address{Description: infrav1.ClusterTagKey("my-cluster") + "/default"})
+	c.Put("projects/my-project/global/addresses/other-namespace", &compute.Address{Description: infrav1.ClusterTagKey("my-cluster") + "/other"})
+	c.Put("projects/my-project/global/addresses/not-owned", &compute.Address{})
+	c.Put("projects/my-project/global/forwardingRules/orphan", &compute.ForwardingRule{Labels: owned})
+	c.Put("projects/my-project/global/firewalls/orphan", &compute.Firewall{Description: infrav1.ClusterTagKey("my-cluster") + "/default"})
+	c.Put("projects/my-project/global/firewalls/other-namespace", &compute.Firewall{Description: infrav1.ClusterTagKey("my-cluster") + "/other"})
+	c.Put("projects/my-project/global/firewalls/not-owned", &compute.Firewall{Description: infrav1.ClusterTagKey("other-cluster") + "/default"})
+	// The ones created before the namespace was recorded are left to the normal delete flow.
+	c.Put("projects/my-project/global/firewalls/unnamespaced", &compute.Firewall{Description: infrav1.ClusterTagKey("my-cluster")})
+	// The resources supporting labels are found by their label, even with another description.
+	c.Put("projects/my-project/regions/us-central1/forwardingRules/orphan", &compute.ForwardingRule{Labels: owned, Region: "us-central1", Description: "edited"})
+	c.Put("projects/my-project/regions/us-central1/forwardingRules/not-owned", &compute.ForwardingRule{Region: "us-central1", Description: infrav1.ClusterTagKey("my-cluster")})
+	c.Put("projects/my-project/zones/us-central1-a/instances/orphan", &compute.Instance{Labels: ownedInstance, Zone: "us-central1-a", Description: "edited"})
+	c.Put("projects/my-project/zones/us-central1-a/instances/managed", &compute.Instance{
+		Labels:   ownedInstance,
+		Zone:     "us-central1-a",
+		Metadata: &compute.Metadata{Items: []*compute.MetadataItems{{Key: "created-by", Value: pointer.StringPtr("projects/my-project/regions/us-central1/instanceGroupManagers/my-pool")}}},
+	})
+	c.Put("projects/my-project/zones/us-central1-a/instances/not-owned", &compute.Instance{Zone: "us-central1-a"})
+	c.Put("projects/my-project/zones/us-central1-b/instances/other-namespace", &compute.Instance{Labels: otherNamespace, Zone: "us-central1-b"})
+	c.Put("projects/my-project/zones/us-central1-c/instances/orphan", &compute.Instance{Labels: ownedInstance, Zone: "us-central1-c"})
+	c.Put("projects/my-project/zones/us-central1-c/instances/previous-cluster", &compute.Instance{
+		Labels: infrav1.Labels{infrav1.NameGCPClusterUID: "previous-uid"}.AddLabels(owned),
+		Zone:   "us-central1-c",
+	})
+	c.Put("projects/my-project/zones/us-central1-c/instances/my-machine", &compute.Instance{Labels: ownedInstance, Zone: "us-central1-c"})
+	// All the pages of the lists are read.
+	c.SetPageSize(1)
+
+	params := newTestClusterScopeParams(g, c)
+	params.Cluster.UID = "my-uid"
+	g.Expect(params.Client.Create(context.TODO(), &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{
+		Name:      "my-machine",
+		Namespace: "default",
+		Labels:    map[string]string{clusterv1.ClusterLabelName: "my-cluster"},
+	}})).To(Succeed())
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	g.Expect(s.DeleteOrphanedResources()).To(Succeed())
+
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/disks")).To(ConsistOf(
+		"projects/my-project/zones/us-central1-a/disks/not-owned",
+		"projects/my-project/zones/us-central1-a/disks/other-namespace",
+		"projects/my-project/zones/us-central1-a/disks/retained",
+	))
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/disks")).To(HaveLen(1))
+	g.Expect(c.List("projects/my-project/global/addresses")).To(ConsistOf(
+		"projects/my-project/global/addresses/not-owned",
+		"projects/my-project/global/addresses/other-namespace",
+	))
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(ConsistOf(
+		"projects/my-project/global/firewalls/not-owned",
+		"projects/my-project/global/firewalls/other-namespace",
+		"projects/my-project/global/firewalls/unnamespaced",
+	))
+	g.Expect(c.List("projects/my-project/regions/us-central1/forwardingRules")).To(ConsistOf("projects/my-project/regions/us-central1/forwardingRules/not-owned"))
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instances")).To(ConsistOf(
+		"projects/my-project/zones/us-central1-a/instances/managed",
+		"projects/my-project/zones/us-central1-a/instances/not-owned",
+	))
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/instances")).To(ConsistOf("projects/my-project/zones/us-central1-b/instances/other-namespace"))
+	g.Expect(c.List("projects/my-project/zones/us-central1-c/instances")).To(ConsistOf(
+		"projects/my-project/zones/us-central1-c/instances/my-machine",
+		"projects/my-project/zones/us-central1-c/instances/previous-cluster",
+	))
+}
+
+func TestDeleteRetained(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Annotations = map[string]string{
+		infrav1.RetainAnnotation: fmt.Sprintf("%s, %s", infrav1.RetainFirewallRules, infrav1.RetainAPIServerAddress),
+	}
+	clusterScope, err := scope.NewClusterScope(params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(s.DeleteInstanceGroups()).To(Succeed())
+	g.Expect(s.DeleteOrphanedResources()).To(Succeed())
+	g.Expect(s.DeleteFirewalls()).To(Succeed())
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/addresses")).To(ConsistOf("projects/my-project/global/addresses/my-cluster-apiserver"))
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(HaveLen(2))
+	g.Expect(c.Get("projects/my-project/global/networks/default", nil)).To(BeTrue())
+	g.Expect(clusterScope.Network().APIServerAddress).To(BeNil())
+	g.Expect(clusterScope.Network().FirewallRules).To(BeEmpty())
+}
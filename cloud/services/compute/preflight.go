@@ -0,0 +1,123 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+// CheckPreflight returns the reasons the resources of the cluster can't be created in its project, so that the
+// reconcile doesn't fail midway with part of them created: the APIs they're managed with must be enabled, and the
+// quotas of the project must have room for the network and the firewall rules of the cluster. The CPUs and the
+// in-use addresses of the region are only checked not to be exhausted, the machines being checked once created.
+func (s *Service) CheckPreflight() ([]string, error) {
+	var failures []string
+
+	for _, api := range s.requiredAPIs() {
+		name := fmt.Sprintf("projects/%s/services/%s", s.scope.Project(), api)
+		service, err := s.scope.ServiceUsage.Services.Get(name).Do()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to describe API %q", api)
+		}
+		if service.State != "ENABLED" {
+			failures = append(failures, fmt.Sprintf("the %s API is not enabled in project %q", api, s.scope.Project()))
+		}
+	}
+	// The quotas can't be looked up without the compute API.
+	if len(failures) > 0 {
+		return failures, nil
+	}
+
+	projectQuotas, err := s.projectQuotaRequests()
+	if err != nil {
+		return nil, err
+	}
+	project, err := s.scope.Compute.Projects.Get(s.scope.Project()).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe project")
+	}
+	failures = append(failures, checkQuotas(project.Quotas, projectQuotas, fmt.Sprintf("project %q", s.scope.Project()))...)
+
+	region, err := s.scope.Compute.Regions.Get(s.scope.Project(), s.scope.Region()).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe region %q", s.scope.Region())
+	}
+	failures = append(failures, checkQuotas(region.Quotas, map[string]float64{"CPUS": 1, "IN_USE_ADDRESSES": 1}, fmt.Sprintf("region %q", s.scope.Region()))...)
+
+	return failures, nil
+}
+
+// requiredAPIs returns the APIs the resources of the cluster are managed with.
+func (s *Service) requiredAPIs() []string {
+	apis := []string{"compute.googleapis.com"}
+	if s.scope.GCPCluster.Spec.ControlPlaneDNS != nil || s.scope.GCPCluster.Spec.Network.DNS != nil {
+		apis = append(apis, "dns.googleapis.com")
+	}
+	if s.scope.GCPCluster.Spec.Network.PrivateServicesAccess != nil {
+		apis = append(apis, "servicenetworking.googleapis.com")
+	}
+
+	return apis
+}
+
+// projectQuotaRequests returns the number of networks and firewall rules the cluster creates, by quota metric.
+func (s *Service) projectQuotaRequests() (map[string]float64, error) {
+	requests := map[string]float64{}
+
+	if s.scope.GCPCluster.Status.Network.SelfLink == nil {
+		_, err := s.networks.Get(s.scope.Project(), s.getNetworkSpec().Name).Do()
+		switch {
+		case gcperrors.IsNotFound(err):
+			requests["NETWORKS"] = 1
+		case err != nil:
+			return nil, errors.Wrapf(err, "failed to describe network")
+		}
+	}
+
+	// The rules of a network firewall policy don't count against the quota of the project.
+	if s.scope.FirewallPolicyMode() == infrav1.FirewallPolicyModeClassic {
+		for _, spec := range s.getFirewallSpecs() {
+			if !s.scope.HasFirewallRule(spec.Name) {
+				requests["FIREWALLS"]++
+			}
+		}
+	}
+
+	return requests, nil
+}
+
+// checkQuotas returns the quotas, by metric, without room for the requested amounts.
+func checkQuotas(quotas []*compute.Quota, requests map[string]float64, scope string) []string {
+	var failures []string
+	for _, quota := range quotas {
+		request, ok := requests[quota.Metric]
+		if !ok || request == 0 {
+			continue
+		}
+		if quota.Usage+request > quota.Limit {
+			failures = append(failures, fmt.Sprintf("the %s quota of %s is exhausted: %g used of %g, %g needed", quota.Metric, scope, quota.Usage, quota.Limit, request))
+		}
+	}
+
+	return failures
+}
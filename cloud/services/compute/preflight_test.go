@@ -0,0 +1,76 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestCheckPreflight(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	c.Put("projects/my-project", &compute.Project{Quotas: []*compute.Quota{
+		{Metric: "NETWORKS", Limit: 5, Usage: 5},
+		{Metric: "FIREWALLS", Limit: 100, Usage: 10},
+	}})
+	params := newTestClusterScopeParams(g, c)
+	region := &compute.Region{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1", region)).To(BeTrue())
+	region.Quotas = []*compute.Quota{
+		{Metric: "CPUS", Limit: 24, Usage: 24},
+		{Metric: "IN_USE_ADDRESSES", Limit: 8, Usage: 2},
+	}
+	c.Put("projects/my-project/regions/us-central1", region)
+	params.GCPCluster.Spec.ControlPlaneDNS = &infrav1.ControlPlaneDNSSpec{Name: "api.example.com."}
+	s := NewService(newTestClusterScopeFromParams(g, params))
+
+	// The APIs of the resources of the cluster must be enabled, the quotas aren't checked without the compute API.
+	c.DisableService("my-project", "compute.googleapis.com")
+	c.DisableService("my-project", "dns.googleapis.com")
+	failures, err := s.CheckPreflight()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(failures).To(ConsistOf(
+		`the compute.googleapis.com API is not enabled in project "my-project"`,
+		`the dns.googleapis.com API is not enabled in project "my-project"`,
+	))
+
+	// The network of the cluster doesn't fit in the quota of the project, and the CPUs of the region are exhausted.
+	c.Delete("projects/my-project/services/compute.googleapis.com")
+	c.Delete("projects/my-project/services/dns.googleapis.com")
+	failures, err = s.CheckPreflight()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(failures).To(ConsistOf(
+		`the NETWORKS quota of project "my-project" is exhausted: 5 used of 5, 1 needed`,
+		`the CPUS quota of region "us-central1" is exhausted: 24 used of 24, 1 needed`,
+	))
+
+	// An existing network needs no quota.
+	c.Put("projects/my-project/global/networks/"+s.scope.NetworkName(), &compute.Network{})
+	region.Quotas[0].Limit = 48
+	c.Put("projects/my-project/regions/us-central1", region)
+	failures, err = s.CheckPreflight()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(failures).To(BeEmpty())
+}
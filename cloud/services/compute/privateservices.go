@@ -0,0 +1,164 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/servicenetworking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/utils/pointer"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+const (
+	// serviceNetworkingService is the service the private connections of the networks are made with.
+	serviceNetworkingService = "services/servicenetworking.googleapis.com"
+	// serviceNetworkingPeering is the name of the peering of the private connection in the network.
+	serviceNetworkingPeering = "servicenetworking-googleapis-com"
+
+	// defaultPrivateServicesPrefixLength is the prefix length of the range allocated to the services.
+	defaultPrivateServicesPrefixLength = 16
+)
+
+// reconcilePrivateServicesAccess allocates the range of the Google managed services in the network, and connects
+// the network to the services with this range. The ranges reserved for the connection by other means are kept.
+// The access isn't torn down when removed from the spec, e.g. while Cloud SQL instances still use it, but with the network.
+func (s *Service) reconcilePrivateServicesAccess(network *compute.Network) error {
+	spec := s.scope.GCPCluster.Spec.Network.PrivateServicesAccess
+	if spec == nil {
+		return nil
+	}
+
+	rangeSpec := &compute.Address{
+		Name:         s.privateServicesRangeName(),
+		Description:  s.namespacedOwnershipMarker(),
+		Purpose:      "VPC_PEERING",
+		AddressType:  "INTERNAL",
+		PrefixLength: defaultPrivateServicesPrefixLength,
+		Network:      network.SelfLink,
+	}
+	if spec.PrefixLength != nil {
+		rangeSpec.PrefixLength = *spec.PrefixLength
+	}
+	if spec.Address != nil {
+		rangeSpec.Address = *spec.Address
+	}
+	allocated, err := s.addresses.Get(s.scope.Project(), rangeSpec.Name).Do()
+	if gcperrors.IsNotFound(err) {
+		if err := s.runInsertOperation(path.Join("global", "addresses", rangeSpec.Name), func() (*compute.Operation, error) {
+			return s.addresses.Insert(s.scope.Project(), rangeSpec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to allocate private services range")
+		}
+		allocated, err = s.addresses.Get(s.scope.Project(), rangeSpec.Name).Do()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe private services range")
+	}
+	if err := s.ensureOwned("private services range", path.Join("global", "addresses", allocated.Name), allocated.Description); err != nil {
+		return err
+	}
+	s.scope.GCPCluster.Status.Network.PrivateServicesAccessRange = pointer.StringPtr(allocated.SelfLink)
+
+	consumerNetwork, err := s.consumerNetwork(network)
+	if err != nil {
+		return err
+	}
+	connections, err := s.scope.ServiceNetworking.Services.Connections.List(serviceNetworkingService).Network(consumerNetwork).Do()
+	if err != nil {
+		return errors.Wrapf(err, "failed to list private service connections")
+	}
+
+	var op *servicenetworking.Operation
+	switch {
+	case len(connections.Connections) == 0:
+		op, err = s.scope.ServiceNetworking.Services.Connections.Create(serviceNetworkingService, &servicenetworking.Connection{
+			Network:               consumerNetwork,
+			ReservedPeeringRanges: []string{allocated.Name},
+		}).Do()
+	case !sets.NewString(connections.Connections[0].ReservedPeeringRanges...).Has(allocated.Name):
+		op, err = s.scope.ServiceNetworking.Services.Connections.Patch(serviceNetworkingService+"/connections/-", &servicenetworking.Connection{
+			Network:               consumerNetwork,
+			ReservedPeeringRanges: append(connections.Connections[0].ReservedPeeringRanges, allocated.Name),
+		}).UpdateMask("reservedPeeringRanges").Force(true).Do()
+	default:
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to connect the network to the private services")
+	}
+	if err := wait.ForServiceNetworkingOperation(s.scope.ServiceNetworking, op); err != nil {
+		return errors.Wrapf(err, "failed to connect the network to the private services")
+	}
+
+	return nil
+}
+
+// deletePrivateServicesAccess deletes the private connection of the network and its allocated range, which would
+// otherwise prevent the deletion of the network.
+func (s *Service) deletePrivateServicesAccess(network *compute.Network) error {
+	if s.scope.GCPCluster.Spec.Network.PrivateServicesAccess == nil && s.scope.GCPCluster.Status.Network.PrivateServicesAccessRange == nil {
+		return nil
+	}
+
+	consumerNetwork, err := s.consumerNetwork(network)
+	if err != nil {
+		return err
+	}
+	op, err := s.scope.ServiceNetworking.Services.Connections.DeleteConnection(
+		path.Join(serviceNetworkingService, "connections", serviceNetworkingPeering),
+		&servicenetworking.DeleteConnectionRequest{ConsumerNetwork: consumerNetwork},
+	).Do()
+	if err == nil {
+		err = wait.ForServiceNetworkingOperation(s.scope.ServiceNetworking, op)
+	}
+	if err != nil && !gcperrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete private service connection")
+	}
+
+	name := s.privateServicesRangeName()
+	if err := s.runDeleteOperation(path.Join("global", "addresses", name), func() (*compute.Operation, error) {
+		return s.addresses.Delete(s.scope.Project(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete private services range")
+	}
+	s.scope.GCPCluster.Status.Network.PrivateServicesAccessRange = nil
+
+	return nil
+}
+
+// consumerNetwork returns the reference of the network in the private connections, which is by project number.
+func (s *Service) consumerNetwork(network *compute.Network) (string, error) {
+	project, err := s.scope.Compute.Projects.Get(s.scope.Project()).Do()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to describe project")
+	}
+
+	return fmt.Sprintf("projects/%d/global/networks/%s", project.Id, network.Name), nil
+}
+
+// privateServicesRangeName returns the name of the global address of the range allocated to the private services.
+func (s *Service) privateServicesRangeName() string {
+	return names.Truncate(fmt.Sprintf("%s-private-services", s.scope.ResourceNamePrefix()))
+}
@@ -0,0 +1,67 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/servicenetworking/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcilePrivateServicesAccess(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	c.Put("projects/my-project", &compute.Project{Id: 123456})
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.Network.PrivateServicesAccess = &infrav1.PrivateServicesAccessSpec{PrefixLength: pointer.Int64Ptr(20)}
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+
+	address := &compute.Address{}
+	g.Expect(c.Get("projects/my-project/global/addresses/my-cluster-private-services", address)).To(BeTrue())
+	g.Expect(address.Purpose).To(Equal("VPC_PEERING"))
+	g.Expect(address.PrefixLength).To(BeEquivalentTo(20))
+	g.Expect(address.Network).To(Equal(*s.scope.GCPCluster.Status.Network.SelfLink))
+	g.Expect(s.scope.GCPCluster.Status.Network.PrivateServicesAccessRange).To(Equal(pointer.StringPtr(address.SelfLink)))
+
+	connection := &servicenetworking.Connection{}
+	g.Expect(c.GetConnection("projects/123456/global/networks/default", connection)).To(BeTrue())
+	g.Expect(connection.ReservedPeeringRanges).To(Equal([]string{"my-cluster-private-services"}))
+
+	// The ranges reserved by other means are kept.
+	connection.ReservedPeeringRanges = []string{"other"}
+	_, err := c.ServiceNetworking().Services.Connections.Patch(serviceNetworkingService+"/connections/-", connection).Do()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(c.GetConnection("projects/123456/global/networks/default", connection)).To(BeTrue())
+	g.Expect(connection.ReservedPeeringRanges).To(Equal([]string{"other", "my-cluster-private-services"}))
+
+	// The range is deleted with the network, not as an orphan.
+	g.Expect(s.DeleteOrphanedResources()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/addresses/my-cluster-private-services", nil)).To(BeTrue())
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.GetConnection("projects/123456/global/networks/default", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/global/addresses/my-cluster-private-services", nil)).To(BeFalse())
+	g.Expect(s.scope.GCPCluster.Status.Network.PrivateServicesAccessRange).To(BeNil())
+}
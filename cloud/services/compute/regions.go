@@ -21,27 +21,99 @@ import (
 	"fmt"
 
 	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
 )
 
-// GetZones retireves GCP regions.
+// GetZones retireves the zones of the GCP region.
+// The zones are cached, they are reused by the reconciles of all the clusters in the region.
 func (s *Service) GetZones() ([]string, error) {
-	region, err := s.scope.Compute.Regions.Get(s.scope.Project(), s.scope.Region()).Do()
+	zones, err := s.getZones(s.scope.Region())
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to describe region %q", s.scope.Region())
+		return nil, err
+	}
+
+	return zoneNames(zones), nil
+}
+
+// GetControlPlaneZones retrieves the zones of the GCP region and of the additional control plane regions,
+// which hold the API server backends.
+func (s *Service) GetControlPlaneZones() ([]string, error) {
+	var res []string
+	for _, region := range s.scope.ControlPlaneRegions() {
+		zones, err := s.getZones(region)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, zoneNames(zones)...)
+	}
+
+	return res, nil
+}
+
+// GetUnavailableZones returns a map from the zones of the control plane regions which are down or deprecated
+// to the reason they are unavailable, as of the last lookup of the zones.
+func (s *Service) GetUnavailableZones() (map[string]string, error) {
+	res := make(map[string]string)
+	for _, region := range s.scope.ControlPlaneRegions() {
+		zones, err := s.getZones(region)
+		if err != nil {
+			return nil, err
+		}
+		for _, zone := range zones {
+			switch {
+			case zone.Status != "" && zone.Status != "UP":
+				res[zone.Name] = fmt.Sprintf("zone is %s", zone.Status)
+			case zone.Deprecated != nil && zone.Deprecated.State != "":
+				res[zone.Name] = fmt.Sprintf("zone is %s", zone.Deprecated.State)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// getZones returns the cached zones of the GCP region, which must not be modified.
+func (s *Service) getZones(region string) ([]*compute.Zone, error) {
+	key := fmt.Sprintf("zones/%s/%s", s.scope.Project(), region)
+	fetch := func() (interface{}, error) {
+		return s.listZones(region)
+	}
+	var res interface{}
+	var err error
+	if interval := s.scope.FailureDomainRefreshInterval(); interval > 0 {
+		res, err = s.scope.Cache().GetWithTTL(key, interval, fetch)
+	} else {
+		res, err = s.scope.Cache().Get(key, fetch)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res.([]*compute.Zone), nil
+}
+
+func (s *Service) listZones(region string) ([]*compute.Zone, error) {
+	r, err := s.scope.Compute.Regions.Get(s.scope.Project(), region).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe region %q", region)
 	}
 
 	zones, err := s.scope.Compute.Zones.
 		List(s.scope.Project()).
-		Filter(fmt.Sprintf("region = %q", region.SelfLink)).
+		Filter(fmt.Sprintf("region = %q", r.SelfLink)).
 		Do()
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to describe zones in region %q", s.scope.Region())
+		return nil, errors.Wrapf(err, "failed to describe zones in region %q", region)
 	}
 
-	res := make([]string, 0, len(zones.Items))
-	for _, x := range zones.Items {
-		res = append(res, x.Name)
+	return zones.Items, nil
+}
+
+func zoneNames(zones []*compute.Zone) []string {
+	res := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		res = append(res, zone.Name)
 	}
 
-	return res, nil
+	return res
 }
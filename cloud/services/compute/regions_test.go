@@ -0,0 +1,103 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestAdditionalControlPlaneRegions(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.AdditionalControlPlaneRegions = []string{"us-east1"}
+	c.AddRegion(testProject, "us-east1", "us-east1-b", "us-east1-c")
+	s := NewService(newTestClusterScopeFromParams(g, params))
+
+	zones, err := s.GetZones()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(zones).To(ConsistOf("us-central1-a", "us-central1-b"))
+	zones, err = s.GetControlPlaneZones()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(zones).To(ConsistOf("us-central1-a", "us-central1-b", "us-east1-b", "us-east1-c"))
+
+	// The global load balancer has a backend in each region of the control plane.
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+	for _, zone := range []string{"us-central1-a", "us-east1-b"} {
+		group, err := s.GetOrCreateInstanceGroup(zone, s.APIServerInstanceGroupName(zone))
+		g.Expect(err).NotTo(HaveOccurred())
+		instance := &compute.Instance{SelfLink: c.SelfLink("projects/my-project/zones/" + zone + "/instances/my-machine-" + zone)}
+		g.Expect(s.EnsureInstanceGroupMember(zone, group.Name, instance)).To(Succeed())
+	}
+	g.Expect(s.UpdateBackendServices()).To(Succeed())
+	g.Expect(s.scope.Network().APIServerInstanceGroups).To(HaveKey("us-east1-b"))
+	backendService := &compute.BackendService{}
+	g.Expect(c.Get("projects/my-project/global/backendServices/my-cluster-apiserver", backendService)).To(BeTrue())
+	g.Expect(backendService.Backends).To(HaveLen(2))
+
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(s.DeleteBackendGroups()).To(Succeed())
+	g.Expect(c.List("projects/my-project/zones/us-east1-b/instanceGroups")).To(BeEmpty())
+}
+
+func TestGetZonesCached(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.Cache = cloud.NewLookupCache(cloud.DefaultLookupCacheTTL)
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	zones, err := s.GetZones()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(zones).To(ConsistOf("us-central1-a", "us-central1-b"))
+
+	c.SetError(http.MethodGet, "projects/my-project/regions/us-central1", &googleapi.Error{Code: http.StatusServiceUnavailable})
+	s = NewService(newTestClusterScopeFromParams(g, params))
+	zones, err = s.GetZones()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(zones).To(ConsistOf("us-central1-a", "us-central1-b"))
+
+	params.Cache = nil
+	s = NewService(newTestClusterScopeFromParams(g, params))
+	_, err = s.GetZones()
+	g.Expect(err).To(HaveOccurred())
+
+	// The zones are looked up again once the failure domain refresh interval expires.
+	c.SetError(http.MethodGet, "projects/my-project/regions/us-central1", nil)
+	params.Cache = cloud.NewLookupCache(cloud.DefaultLookupCacheTTL)
+	params.FailureDomainRefreshInterval = time.Millisecond
+	s = NewService(newTestClusterScopeFromParams(g, params))
+	_, err = s.GetZones()
+	g.Expect(err).NotTo(HaveOccurred())
+	time.Sleep(5 * time.Millisecond)
+	c.SetError(http.MethodGet, "projects/my-project/regions/us-central1", &googleapi.Error{Code: http.StatusServiceUnavailable})
+	_, err = s.GetZones()
+	g.Expect(err).To(HaveOccurred())
+}
@@ -0,0 +1,173 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+// reservationNameKey is the key of the reservation affinity of the instances consuming a reservation by name.
+const reservationNameKey = "compute.googleapis.com/reservation-name"
+
+// ReconcileReservations creates the capacity reservations of the cluster, resizes them when their count
+// changes, and deletes the reservations removed from the cluster spec.
+func (s *Service) ReconcileReservations() error {
+	statuses := make([]infrav1.ReservationStatus, 0, len(s.scope.GCPCluster.Spec.Reservations))
+	for _, spec := range s.scope.GCPCluster.Spec.Reservations {
+		status, err := s.reconcileReservation(spec)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, *status)
+	}
+
+	for _, status := range s.scope.GCPCluster.Status.Reservations {
+		if s.reservationSpec(status.Name) == nil {
+			if err := s.deleteReservation(status); err != nil {
+				return err
+			}
+		}
+	}
+	s.scope.GCPCluster.Status.Reservations = statuses
+
+	return nil
+}
+
+// DeleteReservations deletes the capacity reservations of the cluster.
+func (s *Service) DeleteReservations() error {
+	for _, spec := range s.scope.GCPCluster.Spec.Reservations {
+		if err := s.deleteReservation(infrav1.ReservationStatus{Name: spec.Name, SelfLink: s.reservationSelfLink(spec)}); err != nil {
+			return err
+		}
+	}
+	for _, status := range s.scope.GCPCluster.Status.Reservations {
+		if err := s.deleteReservation(status); err != nil {
+			return err
+		}
+	}
+	s.scope.GCPCluster.Status.Reservations = nil
+
+	return nil
+}
+
+// ReservationName returns the name of the GCP reservation of a reservation of the cluster.
+func (s *Service) ReservationName(name string) string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), name))
+}
+
+func (s *Service) reconcileReservation(spec infrav1.ReservationSpec) (*infrav1.ReservationStatus, error) {
+	name := s.ReservationName(spec.Name)
+	reservation, err := s.reservations.Get(s.scope.Project(), spec.Zone, name).Do()
+	if gcperrors.IsNotFound(err) {
+		input := s.getReservationSpec(spec)
+		if err := s.runInsertOperation(path.Join("zones", spec.Zone, "reservations", name), func() (*compute.Operation, error) {
+			return s.reservations.Insert(s.scope.Project(), spec.Zone, input).Do()
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to create reservation %q", spec.Name)
+		}
+		reservation, err = s.reservations.Get(s.scope.Project(), spec.Zone, name).Do()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe reservation %q", spec.Name)
+	}
+
+	if sku := reservation.SpecificReservation; sku != nil && sku.Count != spec.Count {
+		req := &compute.ReservationsResizeRequest{SpecificSkuCount: spec.Count}
+		op, err := s.runOperation(path.Join("zones", spec.Zone, "reservations", name), "resize", func() (*compute.Operation, error) {
+			return s.reservations.Resize(s.scope.Project(), spec.Zone, name, req).Do()
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resize reservation %q", spec.Name)
+		}
+		record.Eventf(s.scope.GCPCluster, "ReservationResized", "Resized reservation %q from %d to %d instances%s",
+			name, sku.Count, spec.Count, operationDetails(op))
+		sku.Count = spec.Count
+	}
+
+	status := &infrav1.ReservationStatus{
+		Name:     spec.Name,
+		SelfLink: reservation.SelfLink,
+	}
+	if sku := reservation.SpecificReservation; sku != nil {
+		status.Count = sku.Count
+		status.InUseCount = sku.InUseCount
+	}
+
+	return status, nil
+}
+
+func (s *Service) deleteReservation(status infrav1.ReservationStatus) error {
+	// The self link has the format .../zones/<zone>/reservations/<name>.
+	zone := path.Base(path.Dir(path.Dir(status.SelfLink)))
+	name := s.ReservationName(status.Name)
+	if err := s.runDeleteOperation(path.Join("zones", zone, "reservations", name), func() (*compute.Operation, error) {
+		return s.reservations.Delete(s.scope.Project(), zone, name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete reservation %q", status.Name)
+	}
+
+	return nil
+}
+
+func (s *Service) reservationSpec(name string) *infrav1.ReservationSpec {
+	for i := range s.scope.GCPCluster.Spec.Reservations {
+		if s.scope.GCPCluster.Spec.Reservations[i].Name == name {
+			return &s.scope.GCPCluster.Spec.Reservations[i]
+		}
+	}
+
+	return nil
+}
+
+// reservationSelfLink returns the relative self link of the reservation, enough to find its zone.
+func (s *Service) reservationSelfLink(spec infrav1.ReservationSpec) string {
+	return path.Join("projects", s.scope.Project(), "zones", spec.Zone, "reservations", s.ReservationName(spec.Name))
+}
+
+func (s *Service) getReservationSpec(spec infrav1.ReservationSpec) *compute.Reservation {
+	// The reservations don't support labels, their description marks them as owned by the cluster.
+	input := &compute.Reservation{
+		Name:        s.ReservationName(spec.Name),
+		Description: s.ownershipMarker(),
+		Zone:        spec.Zone,
+		SpecificReservation: &compute.AllocationSpecificSKUReservation{
+			Count: spec.Count,
+			InstanceProperties: &compute.AllocationSpecificSKUAllocationReservedInstanceProperties{
+				MachineType: spec.InstanceType,
+			},
+		},
+		SpecificReservationRequired: spec.SpecificReservationRequired,
+	}
+	for _, a := range spec.GuestAccelerators {
+		input.SpecificReservation.InstanceProperties.GuestAccelerators = append(input.SpecificReservation.InstanceProperties.GuestAccelerators,
+			&compute.AcceleratorConfig{
+				AcceleratorType:  a.Type,
+				AcceleratorCount: a.Count,
+			})
+	}
+
+	return input
+}
@@ -0,0 +1,85 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileReservations(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.Reservations = []infrav1.ReservationSpec{
+		{Name: "gpu", Zone: "us-central1-a", InstanceType: "n1-standard-4", Count: 2,
+			GuestAccelerators: []infrav1.Accelerator{{Type: "nvidia-tesla-t4", Count: 1}}, SpecificReservationRequired: true},
+		{Name: "cpu", Zone: "us-central1-b", InstanceType: "n2-standard-2", Count: 3},
+	}
+	clusterScope := newTestClusterScopeFromParams(g, params)
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileReservations()).To(Succeed())
+
+	reservation := &compute.Reservation{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/reservations/my-cluster-gpu", reservation)).To(BeTrue())
+	g.Expect(reservation.SpecificReservationRequired).To(BeTrue())
+	g.Expect(reservation.SpecificReservation.Count).To(BeEquivalentTo(2))
+	g.Expect(reservation.SpecificReservation.InstanceProperties.MachineType).To(Equal("n1-standard-4"))
+	g.Expect(reservation.SpecificReservation.InstanceProperties.GuestAccelerators).To(HaveLen(1))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-b/reservations/my-cluster-cpu", nil)).To(BeTrue())
+	g.Expect(clusterScope.GCPCluster.Status.Reservations).To(HaveLen(2))
+	g.Expect(clusterScope.GCPCluster.Status.Reservations[0].SelfLink).To(Equal(reservation.SelfLink))
+
+	// The reservations are resized, and deleted when removed from the spec.
+	clusterScope.GCPCluster.Spec.Reservations = clusterScope.GCPCluster.Spec.Reservations[:1]
+	clusterScope.GCPCluster.Spec.Reservations[0].Count = 4
+	g.Expect(s.ReconcileReservations()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/reservations/my-cluster-gpu", reservation)).To(BeTrue())
+	g.Expect(reservation.SpecificReservation.Count).To(BeEquivalentTo(4))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-b/reservations/my-cluster-cpu", nil)).To(BeFalse())
+	g.Expect(clusterScope.GCPCluster.Status.Reservations).To(ConsistOf(
+		infrav1.ReservationStatus{Name: "gpu", SelfLink: reservation.SelfLink, Count: 4}))
+
+	// The machines consume the reservations by name.
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-4",
+			Image:        pointer.StringPtr("my-image"),
+			Reservation:  pointer.StringPtr("gpu"),
+		},
+	})
+	g.Expect(instance.ReservationAffinity).To(Equal(&compute.ReservationAffinity{
+		ConsumeReservationType: "SPECIFIC_RESERVATION",
+		Key:                    reservationNameKey,
+		Values:                 []string{"my-cluster-gpu"},
+	}))
+
+	g.Expect(s.DeleteReservations()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/reservations/my-cluster-gpu", nil)).To(BeFalse())
+	g.Expect(clusterScope.GCPCluster.Status.Reservations).To(BeEmpty())
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+// defaultRoutePriority is the priority of the routes which don't set one, the default of GCP.
+const defaultRoutePriority = 1000
+
+// reconcileRoutes creates the static routes of the network spec, recreates those which differ from the spec,
+// the routes being immutable, and deletes those removed from the spec.
+func (s *Service) reconcileRoutes(network *compute.Network) error {
+	status := make(map[string]string, len(s.scope.GCPCluster.Spec.Network.Routes))
+	for i := range s.scope.GCPCluster.Spec.Network.Routes {
+		spec := &s.scope.GCPCluster.Spec.Network.Routes[i]
+		selfLink, err := s.reconcileRoute(s.getRouteSpec(network, spec))
+		if err != nil {
+			return err
+		}
+		status[spec.Name] = selfLink
+	}
+
+	for name := range s.scope.GCPCluster.Status.Network.Routes {
+		if _, ok := status[name]; ok {
+			continue
+		}
+		if err := s.deleteRoute(s.routeName(name)); err != nil {
+			return err
+		}
+	}
+
+	s.scope.GCPCluster.Status.Network.Routes = nil
+	if len(status) > 0 {
+		s.scope.GCPCluster.Status.Network.Routes = status
+	}
+
+	return nil
+}
+
+// reconcileRoute gets or creates the route, recreates it if it differs from the spec, and returns its self link.
+func (s *Service) reconcileRoute(routeSpec *compute.Route) (string, error) {
+	resource := path.Join("global", "routes", routeSpec.Name)
+	var drift string
+	route, err := s.routes.Get(s.scope.Project(), routeSpec.Name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+	case err != nil:
+		return "", errors.Wrapf(err, "failed to describe route")
+	default:
+		if err := s.ensureOwned("route", resource, route.Description); err != nil {
+			return "", err
+		}
+		if drift = routeDrift(route, routeSpec); drift == "" || s.deferDisruptiveChange("route", route.Name, drift) {
+			return route.SelfLink, nil
+		}
+		// The routes can't be updated, the route is recreated.
+		if err := s.deleteRoute(route.Name); err != nil {
+			return "", err
+		}
+	}
+
+	if err := s.runInsertOperation(resource, func() (*compute.Operation, error) {
+		return s.routes.Insert(s.scope.Project(), routeSpec).Do()
+	}); err != nil {
+		return "", errors.Wrapf(err, "failed to create route")
+	}
+	route, err = s.routes.Get(s.scope.Project(), routeSpec.Name).Do()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to describe route")
+	}
+	if drift != "" {
+		s.recordDriftCorrected("route", route.Name, drift, nil)
+	}
+
+	return route.SelfLink, nil
+}
+
+// deleteRoutes deletes the routes of the network spec and those recorded in the status.
+func (s *Service) deleteRoutes() error {
+	names := sets.NewString()
+	for _, spec := range s.scope.GCPCluster.Spec.Network.Routes {
+		names.Insert(spec.Name)
+	}
+	for name := range s.scope.GCPCluster.Status.Network.Routes {
+		names.Insert(name)
+	}
+
+	for _, name := range names.List() {
+		if err := s.deleteRoute(s.routeName(name)); err != nil {
+			return err
+		}
+	}
+	s.scope.GCPCluster.Status.Network.Routes = nil
+
+	return nil
+}
+
+// deleteRoute deletes the route, a route which doesn't exist is ignored.
+func (s *Service) deleteRoute(name string) error {
+	if err := s.runDeleteOperation(path.Join("global", "routes", name), func() (*compute.Operation, error) {
+		return s.routes.Delete(s.scope.Project(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete route")
+	}
+
+	return nil
+}
+
+func (s *Service) getRouteSpec(network *compute.Network, spec *infrav1.RouteSpec) *compute.Route {
+	res := &compute.Route{
+		Name:        s.routeName(spec.Name),
+		Description: s.ownershipMarker(),
+		Network:     network.SelfLink,
+		DestRange:   spec.DestRange,
+		Priority:    defaultRoutePriority,
+		Tags:        spec.Tags,
+	}
+	if spec.Priority != nil {
+		res.Priority = *spec.Priority
+		res.ForceSendFields = []string{"Priority"}
+	}
+
+	switch {
+	case spec.NextHopIP != nil:
+		res.NextHopIp = *spec.NextHopIP
+	case spec.NextHopInstance != nil:
+		res.NextHopInstance = s.projectResource(*spec.NextHopInstance)
+	case spec.NextHopILB != nil:
+		// The forwarding rule may also be referenced by its IP address.
+		res.NextHopIlb = *spec.NextHopILB
+		if strings.Contains(res.NextHopIlb, "/") {
+			res.NextHopIlb = s.projectResource(res.NextHopIlb)
+		}
+	case spec.NextHopGateway != nil:
+		res.NextHopGateway = s.projectResource(path.Join("global", "gateways", *spec.NextHopGateway))
+	}
+
+	return res
+}
+
+// projectResource returns the partial URL of the resource of the project, e.g. zones/us-central1-a/instances/my-vpn,
+// the references which are already partial or full URLs being left unchanged.
+func (s *Service) projectResource(ref string) string {
+	if strings.HasPrefix(ref, "projects/") || strings.HasPrefix(ref, "https://") {
+		return ref
+	}
+
+	return path.Join("projects", s.scope.Project(), ref)
+}
+
+// routeName returns the name of the route of the network spec.
+func (s *Service) routeName(name string) string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), name))
+}
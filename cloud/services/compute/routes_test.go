@@ -0,0 +1,69 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileRoutes(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.Network.Routes = []infrav1.RouteSpec{
+		{Name: "pods", DestRange: "192.168.0.0/16", NextHopIP: pointer.StringPtr("10.128.0.2"), Tags: []string{"nodes"}},
+		{Name: "egress", DestRange: "0.0.0.0/0", NextHopGateway: pointer.StringPtr("default-internet-gateway"), Priority: pointer.Int64Ptr(0)},
+	}
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+
+	route := &compute.Route{}
+	g.Expect(c.Get("projects/my-project/global/routes/my-cluster-pods", route)).To(BeTrue())
+	g.Expect(route.NextHopIp).To(Equal("10.128.0.2"))
+	g.Expect(route.Priority).To(BeEquivalentTo(1000))
+	g.Expect(route.Network).To(Equal(*s.scope.GCPCluster.Status.Network.SelfLink))
+	g.Expect(c.Get("projects/my-project/global/routes/my-cluster-egress", route)).To(BeTrue())
+	g.Expect(route.NextHopGateway).To(Equal("projects/my-project/global/gateways/default-internet-gateway"))
+	g.Expect(route.Priority).To(BeZero())
+	g.Expect(s.scope.GCPCluster.Status.Network.Routes).To(Equal(map[string]string{
+		"pods":   c.SelfLink("projects/my-project/global/routes/my-cluster-pods"),
+		"egress": c.SelfLink("projects/my-project/global/routes/my-cluster-egress"),
+	}))
+
+	// A route modified out-of-band is recreated, those removed from the spec are deleted.
+	c.Get("projects/my-project/global/routes/my-cluster-pods", route)
+	route.NextHopIp = "10.128.0.3"
+	c.Put("projects/my-project/global/routes/my-cluster-pods", route)
+	s.scope.GCPCluster.Spec.Network.Routes = s.scope.GCPCluster.Spec.Network.Routes[:1]
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/global/routes/my-cluster-pods", route)).To(BeTrue())
+	g.Expect(route.NextHopIp).To(Equal("10.128.0.2"))
+	g.Expect(c.Get("projects/my-project/global/routes/my-cluster-egress", nil)).To(BeFalse())
+	g.Expect(s.scope.GCPCluster.Status.Network.Routes).To(HaveLen(1))
+
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.List("projects/my-project/global/routes")).To(BeEmpty())
+	g.Expect(s.scope.GCPCluster.Status.Network.Routes).To(BeNil())
+}
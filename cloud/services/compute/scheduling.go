@@ -0,0 +1,89 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+// The disruptions of an instance are recorded by GCE as system operations on the instance,
+// see https://cloud.google.com/compute/docs/instances/preemptible#detecting_if_an_instance_was_preempted.
+const (
+	preemptedOperationType                  = "compute.instances.preempted"
+	migrateOnHostMaintenanceOperationType   = "compute.instances.migrateOnHostMaintenance"
+	terminateOnHostMaintenanceOperationType = "compute.instances.terminateOnHostMaintenance"
+)
+
+// GetInstanceScheduling returns the scheduling of the instance and the time of its last disruptions, found
+// in the operations of its zone. The last times of the previous status are kept once the operations expire.
+func (s *Service) GetInstanceScheduling(instance *compute.Instance, previous *infrav1.InstanceScheduling) (*infrav1.InstanceScheduling, error) {
+	res := &infrav1.InstanceScheduling{
+		ProvisioningModel: infrav1.ProvisioningModelStandard,
+		AutomaticRestart:  true,
+	}
+	if scheduling := instance.Scheduling; scheduling != nil {
+		if scheduling.Preemptible {
+			res.ProvisioningModel = infrav1.ProvisioningModelPreemptible
+		}
+		res.OnHostMaintenance = scheduling.OnHostMaintenance
+		res.AutomaticRestart = pointer.BoolDeref(scheduling.AutomaticRestart, !scheduling.Preemptible)
+	}
+	if previous != nil {
+		res.LastPreemptionTime = previous.LastPreemptionTime
+		res.LastHostMaintenanceTime = previous.LastHostMaintenanceTime
+	}
+
+	zone := path.Base(instance.Zone)
+	filter := fmt.Sprintf("targetLink = %q", instance.SelfLink)
+	err := s.zoneoperations.List(s.scope.Project(), zone).Filter(filter).Pages(context.TODO(), func(ops *compute.OperationList) error {
+		for _, op := range ops.Items {
+			switch op.OperationType {
+			case preemptedOperationType:
+				res.LastPreemptionTime = laterTime(res.LastPreemptionTime, op)
+			case migrateOnHostMaintenanceOperationType, terminateOnHostMaintenanceOperationType:
+				res.LastHostMaintenanceTime = laterTime(res.LastHostMaintenanceTime, op)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list the operations of instance %q", instance.Name)
+	}
+
+	return res, nil
+}
+
+// laterTime returns the time the operation started at if later than t.
+func laterTime(t *metav1.Time, op *compute.Operation) *metav1.Time {
+	at, err := time.Parse(time.RFC3339, op.InsertTime)
+	if err != nil || (t != nil && !at.After(t.Time)) {
+		return t
+	}
+
+	return &metav1.Time{Time: at}
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestInstanceScheduling(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+
+	// The instances run on the sole-tenant nodes selected by the labels of their node templates.
+	migrate, terminate := infrav1.HostMaintenancePolicyMigrate, infrav1.HostMaintenancePolicyTerminate
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "licensed", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-4",
+			Image:        pointer.StringPtr("my-image"),
+			NodeAffinities: []infrav1.NodeAffinity{
+				{Key: "workload", Operator: infrav1.NodeAffinityOperatorIn, Values: []string{"byol"}},
+				{Key: "compute.googleapis.com/node-name", Operator: infrav1.NodeAffinityOperatorNotIn, Values: []string{"node-1"}},
+			},
+			MinCPUPlatform:    pointer.StringPtr("Intel Cascade Lake"),
+			OnHostMaintenance: &migrate,
+			AutomaticRestart:  pointer.BoolPtr(true),
+		},
+	})
+	g.Expect(instance.Scheduling.NodeAffinities).To(ConsistOf(
+		&compute.SchedulingNodeAffinity{Key: "workload", Operator: "IN", Values: []string{"byol"}},
+		&compute.SchedulingNodeAffinity{Key: "compute.googleapis.com/node-name", Operator: "NOT_IN", Values: []string{"node-1"}},
+	))
+	g.Expect(instance.MinCpuPlatform).To(Equal("Intel Cascade Lake"))
+	g.Expect(instance.Scheduling.OnHostMaintenance).To(Equal("MIGRATE"))
+	g.Expect(instance.Scheduling.AutomaticRestart).To(Equal(pointer.BoolPtr(true)))
+
+	// The host maintenance and restart policies override the defaults.
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "terminated", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:      "n1-standard-4",
+			Image:             pointer.StringPtr("my-image"),
+			OnHostMaintenance: &terminate,
+			AutomaticRestart:  pointer.BoolPtr(false),
+		},
+	})
+	g.Expect(instance.Scheduling.NodeAffinities).To(BeEmpty())
+	g.Expect(instance.MinCpuPlatform).To(BeEmpty())
+	g.Expect(instance.Scheduling.OnHostMaintenance).To(Equal("TERMINATE"))
+	g.Expect(instance.Scheduling.AutomaticRestart).To(Equal(pointer.BoolPtr(false)))
+}
+
+func TestGetInstanceScheduling(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	instance := &compute.Instance{
+		Name:       "my-machine",
+		Zone:       c.SelfLink("projects/my-project/zones/us-central1-a"),
+		SelfLink:   c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-machine"),
+		Scheduling: &compute.Scheduling{Preemptible: true, OnHostMaintenance: "TERMINATE", AutomaticRestart: pointer.BoolPtr(false)},
+	}
+	for i, op := range []*compute.Operation{
+		{OperationType: "compute.instances.preempted", InsertTime: "2021-06-01T10:00:00Z"},
+		{OperationType: "compute.instances.preempted", InsertTime: "2021-06-02T10:00:00Z"},
+		{OperationType: "compute.instances.terminateOnHostMaintenance", InsertTime: "2021-06-03T10:00:00Z"},
+		{OperationType: "compute.instances.setLabels", InsertTime: "2021-06-04T10:00:00Z"},
+	} {
+		op.Name = fmt.Sprintf("operation-system-%d", i)
+		op.TargetLink = instance.SelfLink
+		c.Put("projects/my-project/zones/us-central1-a/operations/"+op.Name, op)
+	}
+	c.Put("projects/my-project/zones/us-central1-a/operations/operation-other", &compute.Operation{
+		OperationType: "compute.instances.preempted",
+		InsertTime:    "2021-06-05T10:00:00Z",
+		TargetLink:    c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-other-machine"),
+	})
+
+	scheduling, err := s.GetInstanceScheduling(instance, nil)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(scheduling.ProvisioningModel).To(Equal(infrav1.ProvisioningModelPreemptible))
+	g.Expect(scheduling.OnHostMaintenance).To(Equal("TERMINATE"))
+	g.Expect(scheduling.AutomaticRestart).To(BeFalse())
+	g.Expect(scheduling.LastPreemptionTime.UTC().Format(time.RFC3339)).To(Equal("2021-06-02T10:00:00Z"))
+	g.Expect(scheduling.LastHostMaintenanceTime.UTC().Format(time.RFC3339)).To(Equal("2021-06-03T10:00:00Z"))
+
+	// The last times are kept once the operations have expired.
+	for i := 0; i < 4; i++ {
+		c.Delete(fmt.Sprintf("projects/my-project/zones/us-central1-a/operations/operation-system-%d", i))
+	}
+	instance.Scheduling = &compute.Scheduling{OnHostMaintenance: "MIGRATE"}
+	scheduling, err = s.GetInstanceScheduling(instance, scheduling)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(scheduling.ProvisioningModel).To(Equal(infrav1.ProvisioningModelStandard))
+	g.Expect(scheduling.AutomaticRestart).To(BeTrue())
+	g.Expect(scheduling.LastPreemptionTime.UTC().Format(time.RFC3339)).To(Equal("2021-06-02T10:00:00Z"))
+}
+
+func TestCreateInstancePreemptible(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	s := NewService(clusterScope)
+
+	// A preemptible instance is neither migrated nor restarted.
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-preemptible-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image"), Preemptible: true},
+	})
+	g.Expect(instance.Scheduling.Preemptible).To(BeTrue())
+	g.Expect(instance.Scheduling.OnHostMaintenance).To(Equal("TERMINATE"))
+	g.Expect(instance.Scheduling.AutomaticRestart).To(Equal(pointer.BoolPtr(false)))
+
+	instance = createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	})
+	g.Expect(instance.Scheduling.Preemptible).To(BeFalse())
+	g.Expect(instance.Scheduling.AutomaticRestart).To(BeNil())
+}
@@ -17,7 +17,11 @@ limitations under the License.
 package compute
 
 import (
+	"fmt"
+
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/storage/v1"
+	"sigs.k8s.io/cluster-api/util/record"
 
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
@@ -42,11 +46,40 @@ type Service struct {
 	forwardingrules *compute.GlobalForwardingRulesService
 	firewalls       *compute.FirewallsService
 	routers         *compute.RoutersService
+	disks           *compute.DisksService
+	reservations    *compute.ReservationsService
+	nodetemplates   *compute.NodeTemplatesService
+	nodegroups      *compute.NodeGroupsService
+	zoneoperations  *compute.ZoneOperationsService
+	images          *compute.ImagesService
+
+	// Regional load balancer components.
+	regionaddresses       *compute.AddressesService
+	regionforwardingrules *compute.ForwardingRulesService
+	regionbackendservices *compute.RegionBackendServicesService
+	targetinstances       *compute.TargetInstancesService
+
+	// Target pools of the load balancers managed outside of Cluster API.
+	targetpools *compute.TargetPoolsService
+
+	// Network endpoint group backends of the load balancer.
+	networkendpointgroups *compute.NetworkEndpointGroupsService
+
+	// Static routes of the network.
+	routes *compute.RoutesService
+
+	// Managed instance groups of the machine pools and their autoscalers.
+	instancetemplates           *compute.InstanceTemplatesService
+	regioninstancegroupmanagers *compute.RegionInstanceGroupManagersService
+	regionautoscalers           *compute.RegionAutoscalersService
+
+	// Objects of the bootstrap data buckets.
+	objects *storage.ObjectsService
 }
 
 // NewService returns a new service given the gcp api client.
 func NewService(scope *scope.ClusterScope) *Service {
-	return &Service{
+	s := &Service{
 		scope:           scope,
 		instances:       scope.Compute.Instances,
 		instancegroups:  scope.Compute.InstanceGroups,
@@ -59,23 +92,115 @@ func NewService(scope *scope.ClusterScope) *Service {
 		forwardingrules: scope.Compute.GlobalForwardingRules,
 		firewalls:       scope.Compute.Firewalls,
 		routers:         scope.Compute.Routers,
+		disks:           scope.Compute.Disks,
+		reservations:    scope.Compute.Reservations,
+		nodetemplates:   scope.Compute.NodeTemplates,
+		nodegroups:      scope.Compute.NodeGroups,
+		zoneoperations:  scope.Compute.ZoneOperations,
+		images:          scope.Compute.Images,
+
+		regionaddresses:       scope.Compute.Addresses,
+		regionforwardingrules: scope.Compute.ForwardingRules,
+		regionbackendservices: scope.Compute.RegionBackendServices,
+		targetinstances:       scope.Compute.TargetInstances,
+
+		targetpools: scope.Compute.TargetPools,
+
+		networkendpointgroups: scope.Compute.NetworkEndpointGroups,
+
+		routes: scope.Compute.Routes,
+
+		instancetemplates:           scope.Compute.InstanceTemplates,
+		regioninstancegroupmanagers: scope.Compute.RegionInstanceGroupManagers,
+		regionautoscalers:           scope.Compute.RegionAutoscalers,
+	}
+	if scope.Storage != nil {
+		s.objects = scope.Storage.Objects
 	}
+
+	return s
 }
 
-// If err == IsNotFound, then return nil
-// If err != nil, then return err
-// Otherwise should wait for operation to finish.
-func (s *Service) checkOrWaitForDeleteOp(op *compute.Operation, err error) error {
-	if err != nil {
-		if gcperrors.IsNotFound(err) {
-			return nil
+// runOperation issues the operation on the resource, e.g. the insert of global/firewalls/my-rule, without
+// waiting for its completion unless the scope waits for the operations, and returns it once completed. The
+// operationType is the one GCE reports, e.g. insert, delete, update or setLabels. The operation is recorded in the
+// GCPCluster status until then, and a TimeoutError is returned so that the reconcile is requeued, and the
+// next reconcile polls it instead of issuing it again.
+func (s *Service) runOperation(resource, operationType string, issue func() (*compute.Operation, error)) (*compute.Operation, error) {
+	if selfLink := s.scope.Operation(resource); selfLink != "" {
+		s.scope.V(2).Info("Polling operation in progress", "resource", resource, "operation", selfLink)
+		op, err := wait.PollComputeOperationLink(s.scope.Compute, s.scope.Project(), selfLink)
+		if wait.IsTimeout(err) {
+			return nil, err
 		}
+		s.scope.SetOperation(resource, "")
+		// Issue the operation unless it is the one that was in progress, the recorded operation may
+		// also have expired or be of another type, e.g. the insert of a resource now being deleted.
+		if op != nil && op.OperationType == operationType {
+			return op, s.operationCompleted(resource, operationType, op, err)
+		}
+	}
+
+	op, err := issue()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.scope.WaitForOperations() {
+		err = wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op)
+	} else {
+		err = wait.PollComputeOperation(op)
+	}
+	if wait.IsTimeout(err) {
+		s.scope.SetOperation(resource, op.SelfLink)
+		return nil, err
+	}
+
+	return op, s.operationCompleted(resource, operationType, op, err)
+}
+
+// operationCompleted records the event of the completed insert or delete operation on the resource,
+// and returns its error. The events of the other operations are recorded by their callers, e.g. as drift corrections.
+func (s *Service) operationCompleted(resource, operationType string, op *compute.Operation, err error) error {
+	if err != nil || s.scope.DryRun() != nil {
 		return err
 	}
+	switch operationType {
+	case "insert":
+		record.Eventf(s.scope.GCPCluster, "SuccessfulCreate", "Created %s%s", resource, operationDetails(op))
+	case "delete":
+		record.Eventf(s.scope.GCPCluster, "SuccessfulDelete", "Deleted %s%s", resource, operationDetails(op))
+	}
+
+	return nil
+}
+
+// runInsertOperation runs the insert operation of the resource, and records the created resource as owned by the cluster.
+func (s *Service) runInsertOperation(resource string, issue func() (*compute.Operation, error)) error {
+	if _, err := s.runOperation(resource, "insert", issue); err != nil {
+		return err
+	}
+	s.scope.SetOwnedResource(resource, true)
+
+	return nil
+}
+
+// runDeleteOperation runs the delete operation of the resource, a resource which doesn't exist is ignored.
+func (s *Service) runDeleteOperation(resource string, issue func() (*compute.Operation, error)) error {
+	if _, err := s.runOperation(resource, "delete", issue); err != nil && !gcperrors.IsNotFound(err) {
+		return err
+	}
+	s.scope.SetOwnedResource(resource, false)
+
+	return nil
+}
 
+// operationDetails references the resource and the operation in the events, so that they can be looked up
+// in the Cloud Console and the audit logs. It is empty if there is no operation.
+func operationDetails(op *compute.Operation) string {
 	if op == nil {
-		return nil
+		return ""
 	}
 
-	return wait.ForComputeOperation(s.scope.Compute, s.scope.Project(), op)
+	return fmt.Sprintf(" (selfLink: %s, operation: %s, operationId: %d)", op.TargetLink, op.Name, op.Id)
 }
@@ -0,0 +1,252 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	capirecord "sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+const (
+	testProject = "my-project"
+	testRegion  = "us-central1"
+)
+
+var testEvents = &eventRecorder{}
+
+func init() {
+	capirecord.InitFromRecorder(testEvents)
+}
+
+// eventRecorder collects the messages of the events.
+type eventRecorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *eventRecorder) Event(_ runtime.Object, eventtype, reason, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, fmt.Sprintf("%s %s %s", eventtype, reason, message))
+}
+
+func (r *eventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *eventRecorder) AnnotatedEventf(object runtime.Object, _ map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+// Messages returns the messages recorded since the last call.
+func (r *eventRecorder) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := r.messages
+	r.messages = nil
+
+	return res
+}
+
+func newTestClusterScope(g *WithT, c *fakecloud.Cloud) *scope.ClusterScope {
+	return newTestClusterScopeFromParams(g, newTestClusterScopeParams(g, c))
+}
+
+func newTestClusterScopeFromParams(g *WithT, params scope.ClusterScopeParams) *scope.ClusterScope {
+	clusterScope, err := scope.NewClusterScope(params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return clusterScope
+}
+
+func newTestClusterScopeParams(g *WithT, c *fakecloud.Cloud) scope.ClusterScopeParams {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	c.AddRegion(testProject, testRegion, testRegion+"-a", testRegion+"-b")
+
+	gcpCluster := &infrav1.GCPCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec: infrav1.GCPClusterSpec{
+			Project: testProject,
+			Region:  testRegion,
+		},
+	}
+
+	return scope.ClusterScopeParams{
+		Cloud:      c,
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build(),
+		Cluster:    &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+		GCPCluster: gcpCluster,
+	}
+}
+
+func TestReconcileDryRun(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	c.Put("projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks", &compute.Firewall{Description: infrav1.ClusterTagKey("my-cluster")})
+
+	dryRun := &cloud.DryRun{}
+	params := newTestClusterScopeParams(g, c)
+	params.DryRun = dryRun
+	clusterScope, err := scope.NewClusterScope(params)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(s.ReconcileInstanceGroups()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+	g.Expect(s.DeleteFirewalls()).To(Succeed())
+
+	// Nothing must have been created nor deleted.
+	g.Expect(c.List("projects/my-project/global/networks")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(HaveLen(1))
+
+	planned := []string{}
+	for _, op := range dryRun.Operations() {
+		planned = append(planned, op.String())
+	}
+	g.Expect(planned).To(ContainElements(
+		"insert projects/my-project/global/networks/default",
+		"insert projects/my-project/regions/us-central1/routers/default-router",
+		"insert projects/my-project/global/forwardingRules/my-cluster-apiserver",
+		"delete projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks",
+	))
+	g.Expect(planned).NotTo(ContainElement("insert projects/my-project/global/firewalls/allow-my-cluster-apiserver-healthchecks"))
+	g.Expect(*clusterScope.Network().SelfLink).To(HaveSuffix("projects/my-project/global/networks/default"))
+}
+
+func newTestMachineScope(g *WithT, clusterScope *scope.ClusterScope, name, failureDomain string) *scope.MachineScope {
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpMachine := &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}
+	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		Client:     fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpMachine).Build(),
+		Cluster:    clusterScope.Cluster,
+		Machine:    &clusterv1.Machine{Spec: clusterv1.MachineSpec{FailureDomain: pointer.StringPtr(failureDomain)}},
+		GCPCluster: clusterScope.GCPCluster,
+		GCPMachine: gcpMachine,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return machineScope
+}
+
+func TestRunOperation(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	clusterScope := newTestClusterScope(g, c)
+	s := NewService(clusterScope)
+	issued := 0
+	issue := func() (*compute.Operation, error) {
+		issued++
+		return s.firewalls.Insert(testProject, &compute.Firewall{Name: "my-rule"}).Do()
+	}
+
+	// The insert in progress during the previous reconcile is polled instead of being issued again.
+	c.Put("projects/my-project/global/operations/my-insert", &compute.Operation{Name: "my-insert", OperationType: "insert", Status: "DONE"})
+	clusterScope.SetOperation("global/firewalls/my-rule", c.SelfLink("projects/my-project/global/operations/my-insert"))
+	g.Expect(s.runInsertOperation("global/firewalls/my-rule", issue)).To(Succeed())
+	g.Expect(issued).To(Equal(0))
+	g.Expect(clusterScope.GCPCluster.Status.Operations).To(BeEmpty())
+
+	// An expired operation is issued again.
+	clusterScope.SetOperation("global/firewalls/my-rule", c.SelfLink("projects/my-project/global/operations/expired"))
+	g.Expect(s.runInsertOperation("global/firewalls/my-rule", issue)).To(Succeed())
+	g.Expect(issued).To(Equal(1))
+	g.Expect(clusterScope.GCPCluster.Status.Operations).To(BeEmpty())
+
+	// An operation of another type doesn't prevent the delete.
+	clusterScope.SetOperation("global/firewalls/my-rule", c.SelfLink("projects/my-project/global/operations/my-insert"))
+	g.Expect(s.runDeleteOperation("global/firewalls/my-rule", func() (*compute.Operation, error) {
+		return s.firewalls.Delete(testProject, "my-rule").Do()
+	})).To(Succeed())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(BeEmpty())
+	g.Expect(clusterScope.GCPCluster.Status.Operations).To(BeEmpty())
+
+	// The operation in progress isn't waited for, it's recorded to be polled by the next reconcile.
+	pending := &compute.Operation{
+		Name:          "my-pending-insert",
+		OperationType: "insert",
+		Status:        "RUNNING",
+		SelfLink:      c.SelfLink("projects/my-project/global/operations/my-pending-insert"),
+	}
+	err := s.runInsertOperation("global/firewalls/my-rule", func() (*compute.Operation, error) {
+		issued++
+		return pending, nil
+	})
+	g.Expect(wait.IsTimeout(err)).To(BeTrue())
+	g.Expect(issued).To(Equal(2))
+	g.Expect(clusterScope.GCPCluster.Status.Operations).To(HaveKeyWithValue("global/firewalls/my-rule", pending.SelfLink))
+
+	c.Put("projects/my-project/global/operations/my-pending-insert", pending)
+	g.Expect(wait.IsTimeout(s.runInsertOperation("global/firewalls/my-rule", issue))).To(BeTrue())
+	pending.Status = "DONE"
+	c.Put("projects/my-project/global/operations/my-pending-insert", pending)
+	g.Expect(s.runInsertOperation("global/firewalls/my-rule", issue)).To(Succeed())
+	g.Expect(issued).To(Equal(2))
+	g.Expect(clusterScope.GCPCluster.Status.Operations).To(BeEmpty())
+	g.Expect(clusterScope.GCPCluster.Status.OwnedResources).To(ContainElement("global/firewalls/my-rule"))
+}
+
+func TestOperationEvents(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	testEvents.Messages()
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(
+		`^Normal SuccessfulCreate Created global/networks/default \(selfLink: \S+/projects/my-project/global/networks/default, operation: operation-\d+, operationId: \d+\)$`,
+	)))
+
+	c.Put("projects/my-project/global/firewalls/allow-my-cluster-apiserver-cluster", &compute.Firewall{Description: infrav1.ClusterTagKey("my-cluster"), Disabled: true})
+	g.Expect(s.ReconcileFirewalls()).To(Succeed())
+	g.Expect(testEvents.Messages()).To(ContainElement(MatchRegexp(
+		`^Normal DriftCorrected Restored firewall rule "allow-my-cluster-apiserver-cluster": rule is disabled \(selfLink: \S+/global/firewalls/allow-my-cluster-apiserver-cluster, operation: operation-\d+, operationId: \d+\)$`,
+	)))
+}
+
+// createTestInstance creates the instance of the GCPMachine with a bootstrap data secret.
+// The Machine has the labels of the GCPMachine, e.g. the control plane label.
+// instanceMetadata returns the metadata of the instance as a map.
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/names"
+)
+
+// nodeGroupNameKey is the key of the node affinity of the instances running on a sole-tenant node group.
+const nodeGroupNameKey = "compute.googleapis.com/node-group-name"
+
+// ReconcileSoleTenantNodeGroups creates the sole-tenant node templates and node groups of the cluster,
+// resizes the node groups when their size changes, and deletes the node groups removed from the cluster spec.
+func (s *Service) ReconcileSoleTenantNodeGroups() error {
+	statuses := make([]infrav1.SoleTenantNodeGroupStatus, 0, len(s.scope.GCPCluster.Spec.SoleTenantNodeGroups))
+	for _, spec := range s.scope.GCPCluster.Spec.SoleTenantNodeGroups {
+		status, err := s.reconcileSoleTenantNodeGroup(spec)
+		if err != nil {
+			return err
+		}
+		statuses = append(statuses, *status)
+	}
+
+	for _, status := range s.scope.GCPCluster.Status.SoleTenantNodeGroups {
+		if s.soleTenantNodeGroupSpec(status.Name) == nil {
+			if err := s.deleteSoleTenantNodeGroup(status); err != nil {
+				return err
+			}
+		}
+	}
+	s.scope.GCPCluster.Status.SoleTenantNodeGroups = statuses
+
+	return nil
+}
+
+// DeleteSoleTenantNodeGroups deletes the sole-tenant node groups of the cluster and their node templates.
+// The node groups can't be deleted while instances run on them.
+func (s *Service) DeleteSoleTenantNodeGroups() error {
+	for _, spec := range s.scope.GCPCluster.Spec.SoleTenantNodeGroups {
+		selfLink := path.Join("projects", s.scope.Project(), "zones", spec.Zone, "nodeGroups", s.SoleTenantNodeGroupName(spec.Name))
+		if err := s.deleteSoleTenantNodeGroup(infrav1.SoleTenantNodeGroupStatus{Name: spec.Name, SelfLink: selfLink}); err != nil {
+			return err
+		}
+	}
+	for _, status := range s.scope.GCPCluster.Status.SoleTenantNodeGroups {
+		if err := s.deleteSoleTenantNodeGroup(status); err != nil {
+			return err
+		}
+	}
+	s.scope.GCPCluster.Status.SoleTenantNodeGroups = nil
+
+	return nil
+}
+
+// SoleTenantNodeGroupName returns the name of the GCP node group, and node template, of a node group of the cluster.
+func (s *Service) SoleTenantNodeGroupName(name string) string {
+	return names.Truncate(fmt.Sprintf("%s-%s", s.scope.ResourceNamePrefix(), name))
+}
+
+func (s *Service) reconcileSoleTenantNodeGroup(spec infrav1.SoleTenantNodeGroupSpec) (*infrav1.SoleTenantNodeGroupStatus, error) {
+	name := s.SoleTenantNodeGroupName(spec.Name)
+
+	template, err := s.nodetemplates.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	if gcperrors.IsNotFound(err) {
+		// The node templates don't support labels, their description marks them as owned by the cluster.
+		input := &compute.NodeTemplate{
+			Name:        name,
+			Description: s.ownershipMarker(),
+			NodeType:    spec.NodeType,
+		}
+		if err := s.runInsertOperation(path.Join("regions", s.scope.Region(), "nodeTemplates", name), func() (*compute.Operation, error) {
+			return s.nodetemplates.Insert(s.scope.Project(), s.scope.Region(), input).Do()
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to create node template %q", spec.Name)
+		}
+		template, err = s.nodetemplates.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe node template %q", spec.Name)
+	}
+
+	group, err := s.nodegroups.Get(s.scope.Project(), spec.Zone, name).Do()
+	if gcperrors.IsNotFound(err) {
+		input := &compute.NodeGroup{
+			Name:              name,
+			Description:       s.ownershipMarker(),
+			NodeTemplate:      template.SelfLink,
+			MaintenancePolicy: pointer.StringDeref(spec.MaintenancePolicy, ""),
+		}
+		if err := s.runInsertOperation(path.Join("zones", spec.Zone, "nodeGroups", name), func() (*compute.Operation, error) {
+			return s.nodegroups.Insert(s.scope.Project(), spec.Zone, spec.Size, input).Do()
+		}); err != nil {
+			return nil, errors.Wrapf(err, "failed to create node group %q", spec.Name)
+		}
+		group, err = s.nodegroups.Get(s.scope.Project(), spec.Zone, name).Do()
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe node group %q", spec.Name)
+	}
+
+	if group.Size != spec.Size {
+		if err := s.resizeSoleTenantNodeGroup(spec, group); err != nil {
+			return nil, err
+		}
+		if group, err = s.nodegroups.Get(s.scope.Project(), spec.Zone, name).Do(); err != nil {
+			return nil, errors.Wrapf(err, "failed to describe node group %q", spec.Name)
+		}
+	}
+
+	return &infrav1.SoleTenantNodeGroupStatus{
+		Name:     spec.Name,
+		SelfLink: group.SelfLink,
+		Size:     group.Size,
+	}, nil
+}
+
+// resizeSoleTenantNodeGroup adds nodes to the group, or deletes its nodes without instances.
+func (s *Service) resizeSoleTenantNodeGroup(spec infrav1.SoleTenantNodeGroupSpec, group *compute.NodeGroup) error {
+	operationType, issue := "addNodes", func() (*compute.Operation, error) {
+		req := &compute.NodeGroupsAddNodesRequest{AdditionalNodeCount: spec.Size - group.Size}
+		return s.nodegroups.AddNodes(s.scope.Project(), spec.Zone, group.Name, req).Do()
+	}
+	if group.Size > spec.Size {
+		nodes, err := s.nodegroups.ListNodes(s.scope.Project(), spec.Zone, group.Name).Do()
+		if err != nil {
+			return errors.Wrapf(err, "failed to list nodes of node group %q", spec.Name)
+		}
+		req := &compute.NodeGroupsDeleteNodesRequest{}
+		for _, node := range nodes.Items {
+			if len(node.Instances) == 0 && int64(len(req.Nodes)) < group.Size-spec.Size {
+				req.Nodes = append(req.Nodes, node.Name)
+			}
+		}
+		if len(req.Nodes) == 0 {
+			s.scope.V(2).Info("No node without instances to delete from node group", "node-group", group.Name)
+			return nil
+		}
+		operationType, issue = "deleteNodes", func() (*compute.Operation, error) {
+			return s.nodegroups.DeleteNodes(s.scope.Project(), spec.Zone, group.Name, req).Do()
+		}
+	}
+	op, err := s.runOperation(path.Join("zones", spec.Zone, "nodeGroups", group.Name), operationType, issue)
+	if err != nil {
+		return errors.Wrapf(err, "failed to resize node group %q", spec.Name)
+	}
+	record.Eventf(s.scope.GCPCluster, "NodeGroupResized", "Resized node group %q from %d to %d nodes%s",
+		group.Name, group.Size, spec.Size, operationDetails(op))
+
+	return nil
+}
+
+func (s *Service) deleteSoleTenantNodeGroup(status infrav1.SoleTenantNodeGroupStatus) error {
+	// The self link has the format .../zones/<zone>/nodeGroups/<name>.
+	zone := path.Base(path.Dir(path.Dir(status.SelfLink)))
+	name := s.SoleTenantNodeGroupName(status.Name)
+	if err := s.runDeleteOperation(path.Join("zones", zone, "nodeGroups", name), func() (*compute.Operation, error) {
+		return s.nodegroups.Delete(s.scope.Project(), zone, name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete node group %q", status.Name)
+	}
+
+	if err := s.runDeleteOperation(path.Join("regions", s.scope.Region(), "nodeTemplates", name), func() (*compute.Operation, error) {
+		return s.nodetemplates.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete node template %q", status.Name)
+	}
+
+	return nil
+}
+
+func (s *Service) soleTenantNodeGroupSpec(name string) *infrav1.SoleTenantNodeGroupSpec {
+	for i := range s.scope.GCPCluster.Spec.SoleTenantNodeGroups {
+		if s.scope.GCPCluster.Spec.SoleTenantNodeGroups[i].Name == name {
+			return &s.scope.GCPCluster.Spec.SoleTenantNodeGroups[i]
+		}
+	}
+
+	return nil
+}
+
+// schedulingNodeAffinities returns the node affinities of the scheduling of an instance.
+func schedulingNodeAffinities(affinities []infrav1.NodeAffinity) []*compute.SchedulingNodeAffinity {
+	result := make([]*compute.SchedulingNodeAffinity, 0, len(affinities))
+	for _, affinity := range affinities {
+		operator := "IN"
+		if affinity.Operator == infrav1.NodeAffinityOperatorNotIn {
+			operator = "NOT_IN"
+		}
+		result = append(result, &compute.SchedulingNodeAffinity{
+			Key:      affinity.Key,
+			Operator: operator,
+			Values:   affinity.Values,
+		})
+	}
+
+	return result
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileSoleTenantNodeGroups(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.SoleTenantNodeGroups = []infrav1.SoleTenantNodeGroupSpec{
+		{Name: "dedicated", Zone: "us-central1-a", NodeType: "n1-node-96-624", Size: 2},
+	}
+	clusterScope := newTestClusterScopeFromParams(g, params)
+	s := NewService(clusterScope)
+	g.Expect(s.ReconcileSoleTenantNodeGroups()).To(Succeed())
+
+	template := &compute.NodeTemplate{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/nodeTemplates/my-cluster-dedicated", template)).To(BeTrue())
+	g.Expect(template.NodeType).To(Equal("n1-node-96-624"))
+	group := &compute.NodeGroup{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/nodeGroups/my-cluster-dedicated", group)).To(BeTrue())
+	g.Expect(group.NodeTemplate).To(Equal(template.SelfLink))
+	g.Expect(group.Size).To(BeEquivalentTo(2))
+	g.Expect(clusterScope.GCPCluster.Status.SoleTenantNodeGroups).To(ConsistOf(
+		infrav1.SoleTenantNodeGroupStatus{Name: "dedicated", SelfLink: group.SelfLink, Size: 2}))
+
+	// The node groups are resized.
+	clusterScope.GCPCluster.Spec.SoleTenantNodeGroups[0].Size = 3
+	g.Expect(s.ReconcileSoleTenantNodeGroups()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/nodeGroups/my-cluster-dedicated", group)).To(BeTrue())
+	g.Expect(group.Size).To(BeEquivalentTo(3))
+	clusterScope.GCPCluster.Spec.SoleTenantNodeGroups[0].Size = 1
+	g.Expect(s.ReconcileSoleTenantNodeGroups()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/nodeGroups/my-cluster-dedicated", group)).To(BeTrue())
+	g.Expect(group.Size).To(BeEquivalentTo(1))
+	g.Expect(clusterScope.GCPCluster.Status.SoleTenantNodeGroups[0].Size).To(BeEquivalentTo(1))
+
+	// The machines run on the node groups by name.
+	clusterScope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	instance := createTestInstance(g, s, &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:        "n1-standard-4",
+			Image:               pointer.StringPtr("my-image"),
+			SoleTenantNodeGroup: pointer.StringPtr("dedicated"),
+		},
+	})
+	g.Expect(instance.Scheduling.NodeAffinities).To(ConsistOf(&compute.SchedulingNodeAffinity{
+		Key:      nodeGroupNameKey,
+		Operator: "IN",
+		Values:   []string{"my-cluster-dedicated"},
+	}))
+
+	// Removing the node group from the spec deletes it with its template.
+	clusterScope.GCPCluster.Spec.SoleTenantNodeGroups = nil
+	g.Expect(s.ReconcileSoleTenantNodeGroups()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/nodeGroups/my-cluster-dedicated", nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/nodeTemplates/my-cluster-dedicated", nil)).To(BeFalse())
+	g.Expect(clusterScope.GCPCluster.Status.SoleTenantNodeGroups).To(BeEmpty())
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+	"sort"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+// reconcileSubnets creates the subnetworks of the network spec, and restores the private Google access of those
+// modified out-of-band. The subnetworks without a primary range, e.g. those of an auto mode network only referenced
+// for their role, must exist.
+func (s *Service) reconcileSubnets(network *compute.Network) error {
+	for _, spec := range s.scope.GCPCluster.Spec.Network.Subnets {
+		if spec == nil {
+			continue
+		}
+		if err := s.reconcileSubnet(s.getSubnetSpec(network, spec)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// reconcileSubnet gets or creates the subnetwork, restores its private Google access if it differs from the spec, and
+// enables IPv6 on the existing subnetwork of a dual-stack spec, if the subnetwork is owned by the cluster.
+func (s *Service) reconcileSubnet(subnetSpec *compute.Subnetwork) error {
+	resource := path.Join("regions", subnetSpec.Region, "subnetworks", subnetSpec.Name)
+	subnet, err := s.subnetworks.Get(s.scope.Project(), subnetSpec.Region, subnetSpec.Name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		if subnetSpec.IpCidrRange == "" {
+			return errors.Errorf("subnetwork %s doesn't exist, its cidrBlock must be set to create it", subnetSpec.Name)
+		}
+		if err := s.runInsertOperation(resource, func() (*compute.Operation, error) {
+			return s.subnetworks.Insert(s.scope.Project(), subnetSpec.Region, subnetSpec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create subnetwork %s", subnetSpec.Name)
+		}
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe subnetwork %s", subnetSpec.Name)
+	}
+	// The subnetworks referenced for their role only aren't deleted with the cluster, they aren't adopted. The
+	// pre-existing subnetworks neither owned nor adopted by the cluster are used as they are.
+	if subnetSpec.IpCidrRange == "" || !s.isOwned(resource, subnet.Description) && !s.adopt("subnetwork", resource, subnet.Description) {
+		s.scope.V(2).Info("Subnetwork isn't owned by the cluster, leaving it as is", "name", subnet.Name)
+		return nil
+	}
+
+	if subnet.PrivateIpGoogleAccess != subnetSpec.PrivateIpGoogleAccess {
+		req := &compute.SubnetworksSetPrivateIpGoogleAccessRequest{
+			PrivateIpGoogleAccess: subnetSpec.PrivateIpGoogleAccess,
+			ForceSendFields:       []string{"PrivateIpGoogleAccess"},
+		}
+		op, err := s.runOperation(resource, "setPrivateIpGoogleAccess", func() (*compute.Operation, error) {
+			return s.subnetworks.SetPrivateIpGoogleAccess(s.scope.Project(), subnetSpec.Region, subnet.Name, req).Do()
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed to update subnetwork %s", subnet.Name)
+		}
+		s.recordDriftCorrected("subnetwork", subnet.Name,
+			fmt.Sprintf("private google access is %t instead of %t", subnet.PrivateIpGoogleAccess, subnetSpec.PrivateIpGoogleAccess), op)
+	}
+
+	if subnetSpec.StackType == string(infrav1.StackTypeIPv4IPv6) && subnet.StackType != subnetSpec.StackType {
+		patch := &compute.Subnetwork{
+			StackType:      subnetSpec.StackType,
+			Ipv6AccessType: subnetSpec.Ipv6AccessType,
+			Fingerprint:    subnet.Fingerprint,
+		}
+		if _, err := s.runOperation(resource, "patch", func() (*compute.Operation, error) {
+			return s.subnetworks.Patch(s.scope.Project(), subnetSpec.Region, subnet.Name, patch).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to enable IPv6 on subnetwork %s", subnet.Name)
+		}
+		s.scope.Info("Enabled IPv6 on subnetwork", "name", subnet.Name, "ipv6AccessType", subnetSpec.Ipv6AccessType)
+	}
+
+	return nil
+}
+
+// deleteSubnets deletes the subnetworks of the network spec created by the cluster, those with a primary range,
+// which would otherwise prevent the deletion of the network. A subnetwork which doesn't exist is ignored.
+func (s *Service) deleteSubnets() error {
+	for _, spec := range s.scope.GCPCluster.Spec.Network.Subnets {
+		if spec == nil || spec.CidrBlock == "" {
+			continue
+		}
+		region := s.subnetRegion(spec)
+		if err := s.runDeleteOperation(path.Join("regions", region, "subnetworks", spec.Name), func() (*compute.Operation, error) {
+			return s.subnetworks.Delete(s.scope.Project(), region, spec.Name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete subnetwork %s", spec.Name)
+		}
+	}
+
+	return nil
+}
+
+func (s *Service) getSubnetSpec(network *compute.Network, spec *infrav1.SubnetSpec) *compute.Subnetwork {
+	res := &compute.Subnetwork{
+		Name:                  spec.Name,
+		Description:           s.ownershipMarker(),
+		IpCidrRange:           spec.CidrBlock,
+		Network:               network.SelfLink,
+		Region:                s.subnetRegion(spec),
+		PrivateIpGoogleAccess: pointer.BoolDeref(spec.PrivateGoogleAccess, false),
+	}
+	if spec.Description != nil {
+		res.Description = *spec.Description
+	}
+	for name, cidr := range spec.SecondaryCidrBlocks {
+		res.SecondaryIpRanges = append(res.SecondaryIpRanges, &compute.SubnetworkSecondaryRange{RangeName: name, IpCidrRange: cidr})
+	}
+	sort.Slice(res.SecondaryIpRanges, func(i, j int) bool { return res.SecondaryIpRanges[i].RangeName < res.SecondaryIpRanges[j].RangeName })
+	if pointer.BoolDeref(spec.EnableFlowLogs, false) {
+		res.LogConfig = &compute.SubnetworkLogConfig{Enable: true}
+	}
+	if spec.StackType == infrav1.StackTypeIPv4IPv6 {
+		res.StackType = string(infrav1.StackTypeIPv4IPv6)
+		res.Ipv6AccessType = string(subnetIPv6AccessType(spec))
+	}
+
+	return res
+}
+
+// subnetIPv6AccessType returns the access type of the IPv6 range of a dual-stack subnetwork, which defaults to EXTERNAL.
+func subnetIPv6AccessType(spec *infrav1.SubnetSpec) infrav1.IPv6AccessType {
+	if spec.IPv6AccessType == "" {
+		return infrav1.IPv6AccessTypeExternal
+	}
+
+	return spec.IPv6AccessType
+}
+
+// setNetworkInterfaceStack makes the network interface dual-stack if its subnetwork is a dual-stack subnetwork of the
+// network spec. The external IPv6 address of an EXTERNAL subnetwork is assigned through an IPv6 access config.
+func (s *Service) setNetworkInterfaceStack(nic *compute.NetworkInterface, region, subnet string) {
+	for _, spec := range s.scope.GCPCluster.Spec.Network.Subnets {
+		if spec == nil || spec.Name != subnet || s.subnetRegion(spec) != region || spec.StackType != infrav1.StackTypeIPv4IPv6 {
+			continue
+		}
+		nic.StackType = string(infrav1.StackTypeIPv4IPv6)
+		if subnetIPv6AccessType(spec) == infrav1.IPv6AccessTypeExternal {
+			nic.Ipv6AccessConfigs = []*compute.AccessConfig{
+				{
+					Type:        "DIRECT_IPV6",
+					Name:        "External IPv6",
+					NetworkTier: "PREMIUM",
+				},
+			}
+		}
+	}
+}
+
+// subnetRegion returns the region of the subnetwork, which defaults to the region of the cluster.
+func (s *Service) subnetRegion(spec *infrav1.SubnetSpec) string {
+	if spec.Region != "" {
+		return spec.Region
+	}
+
+	return s.scope.Region()
+}
@@ -0,0 +1,97 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestReconcileSubnets(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := NewService(newTestClusterScope(g, c))
+	s.scope.GCPCluster.Spec.Network.AutoCreateSubnetworks = pointer.BoolPtr(false)
+	s.scope.GCPCluster.Spec.Network.Subnets = infrav1.Subnets{
+		{
+			Name:                "control-plane",
+			CidrBlock:           "10.0.0.0/24",
+			Region:              "us-central1",
+			Roles:               []infrav1.SubnetRole{infrav1.SubnetRoleControlPlane},
+			PrivateGoogleAccess: pointer.BoolPtr(true),
+		},
+		{
+			Name:                "nodes",
+			CidrBlock:           "10.1.0.0/16",
+			Region:              "us-central1",
+			SecondaryCidrBlocks: map[string]string{"pods": "192.168.0.0/16"},
+			Roles:               []infrav1.SubnetRole{infrav1.SubnetRoleWorker},
+		},
+		{Name: "other", CidrBlock: "10.2.0.0/24"},
+	}
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+
+	subnet := &compute.Subnetwork{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/control-plane", subnet)).To(BeTrue())
+	g.Expect(subnet.IpCidrRange).To(Equal("10.0.0.0/24"))
+	g.Expect(subnet.PrivateIpGoogleAccess).To(BeTrue())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/nodes", subnet)).To(BeTrue())
+	g.Expect(subnet.SecondaryIpRanges).To(HaveLen(1))
+	g.Expect(subnet.SecondaryIpRanges[0].RangeName).To(Equal("pods"))
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/other", nil)).To(BeTrue())
+	g.Expect(s.scope.GCPCluster.Status.Network.Subnets).To(HaveLen(3))
+
+	// The machines are placed in the subnetwork of their role, the load balancer in the control plane one.
+	g.Expect(s.scope.LoadBalancerSubnet()).To(Equal(pointer.StringPtr("control-plane")))
+	s.scope.Network().APIServerAddress = pointer.StringPtr("10.0.0.1")
+	newGCPMachine := func(name string, labels map[string]string) *infrav1.GCPMachine {
+		return &infrav1.GCPMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: labels},
+			Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+		}
+	}
+	instance := createTestInstance(g, s, newGCPMachine("my-control-plane", map[string]string{clusterv1.MachineControlPlaneLabelName: ""}))
+	g.Expect(instance.NetworkInterfaces[0].Subnetwork).To(HaveSuffix("regions/us-central1/subnetworks/control-plane"))
+	instance = createTestInstance(g, s, newGCPMachine("my-node", nil))
+	g.Expect(instance.NetworkInterfaces[0].Subnetwork).To(HaveSuffix("regions/us-central1/subnetworks/nodes"))
+
+	// The private Google access modified out-of-band is restored.
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/control-plane", subnet)).To(BeTrue())
+	subnet.PrivateIpGoogleAccess = false
+	c.Put("projects/my-project/regions/us-central1/subnetworks/control-plane", subnet)
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/subnetworks/control-plane", subnet)).To(BeTrue())
+	g.Expect(subnet.PrivateIpGoogleAccess).To(BeTrue())
+
+	// A subnetwork without primary range must exist.
+	s.scope.GCPCluster.Spec.Network.Subnets = append(s.scope.GCPCluster.Spec.Network.Subnets, &infrav1.SubnetSpec{Name: "missing"})
+	g.Expect(s.ReconcileNetwork()).NotTo(Succeed())
+	s.scope.GCPCluster.Spec.Network.Subnets = s.scope.GCPCluster.Spec.Network.Subnets[:3]
+
+	g.Expect(s.DeleteNetwork()).To(Succeed())
+	g.Expect(c.List("projects/my-project/regions/us-central1/subnetworks")).To(BeEmpty())
+}
@@ -0,0 +1,221 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+// The TargetInstance load balancer is a regional address, reserved by the cluster, and a regional forwarding
+// rule to a target instance of a control plane instance, created by the first control plane machine.
+
+// reconcileRegionalAddress reconciles the regional IP address of the API server of a TargetInstance load balancer.
+func (s *Service) reconcileRegionalAddress() error {
+	name := s.apiServerLoadBalancerName()
+	address, err := s.regionaddresses.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	if gcperrors.IsNotFound(err) {
+		spec := &compute.Address{
+			Name:        name,
+			Description: s.ownershipMarker(),
+			AddressType: APIServerLoadBalancerScheme,
+		}
+		if err := s.runInsertOperation(path.Join("regions", s.scope.Region(), "addresses", name), func() (*compute.Operation, error) {
+			return s.regionaddresses.Insert(s.scope.Project(), s.scope.Region(), spec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create regional address")
+		}
+		address, err = s.regionaddresses.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe regional address")
+	}
+	if err := s.ensureOwned("regional address", path.Join("regions", s.scope.Region(), "addresses", name), address.Description); err != nil {
+		return err
+	}
+
+	s.scope.Network().APIServerAddress = pointer.StringPtr(address.Address)
+	s.scope.Network().APIServerAddressSelfLink = pointer.StringPtr(address.SelfLink)
+
+	// The forwarding rule is created by the first control plane machine.
+	forwardingRule, err := s.regionforwardingrules.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		s.scope.Network().APIServerForwardingRule = nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe forwarding rule")
+	default:
+		if err := s.ensureOwnedLabelled("forwarding rule", path.Join("regions", s.scope.Region(), "forwardingRules", name), forwardingRule.Labels); err != nil {
+			return err
+		}
+		s.scope.Network().APIServerForwardingRule = pointer.StringPtr(forwardingRule.SelfLink)
+	}
+
+	return nil
+}
+
+// RegisterTargetInstance points the forwarding rule of a TargetInstance load balancer to the control plane
+// instance, unless it already points to another one.
+func (s *Service) RegisterTargetInstance(instance *compute.Instance) error {
+	name := s.apiServerLoadBalancerName()
+	zone := path.Base(instance.Zone)
+
+	_, err := s.regionforwardingrules.Get(s.scope.Project(), s.scope.Region(), name).Do()
+	if err == nil {
+		return nil
+	} else if !gcperrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to describe forwarding rule")
+	}
+
+	if s.scope.Network().APIServerAddress == nil {
+		return errors.New("failed to register target instance, APIServer address not available")
+	}
+
+	targetInstance, err := s.targetinstances.Get(s.scope.Project(), zone, name).Do()
+	if err == nil && targetInstance.Instance != instance.SelfLink {
+		// The target instance of a deleted instance can't be updated, recreate it.
+		if err := s.runDeleteOperation(path.Join("zones", zone, "targetInstances", name), func() (*compute.Operation, error) {
+			return s.targetinstances.Delete(s.scope.Project(), zone, name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete target instance")
+		}
+		targetInstance, err = s.targetinstances.Get(s.scope.Project(), zone, name).Do()
+	}
+	if gcperrors.IsNotFound(err) {
+		spec := &compute.TargetInstance{
+			Name:        name,
+			Description: s.ownershipMarker(),
+			Instance:    instance.SelfLink,
+		}
+		if err := s.runInsertOperation(path.Join("zones", zone, "targetInstances", name), func() (*compute.Operation, error) {
+			return s.targetinstances.Insert(s.scope.Project(), zone, spec).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to create target instance")
+		}
+		targetInstance, err = s.targetinstances.Get(s.scope.Project(), zone, name).Do()
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe target instance")
+	}
+
+	// The traffic is forwarded as is, the API server port of the instance is exposed.
+	port := s.scope.LoadBalancerBackendPort()
+	spec := &compute.ForwardingRule{
+		Name:                name,
+		IPAddress:           *s.scope.Network().APIServerAddress,
+		IPProtocol:          APIServerLoadBalancerProtocol,
+		LoadBalancingScheme: APIServerLoadBalancerScheme,
+		PortRange:           fmt.Sprintf("%d-%d", port, port),
+		Target:              targetInstance.SelfLink,
+		Labels:              s.ownershipLabels(infrav1.APIServerRoleTagValue),
+	}
+	if err := s.runInsertOperation(path.Join("regions", s.scope.Region(), "forwardingRules", name), func() (*compute.Operation, error) {
+		return s.regionforwardingrules.Insert(s.scope.Project(), s.scope.Region(), spec).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to create forwarding rule")
+	}
+
+	return nil
+}
+
+// DeregisterTargetInstance deletes the forwarding rule and the target instance of a TargetInstance load balancer
+// pointing to the control plane instance, so that the next control plane instance registers itself.
+func (s *Service) DeregisterTargetInstance(instance *compute.Instance) error {
+	name := s.apiServerLoadBalancerName()
+	zone := path.Base(instance.Zone)
+
+	targetInstance, err := s.targetinstances.Get(s.scope.Project(), zone, name).Do()
+	if gcperrors.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return errors.Wrapf(err, "failed to describe target instance")
+	}
+	if targetInstance.Instance != instance.SelfLink {
+		return nil
+	}
+
+	if err := s.runDeleteOperation(path.Join("regions", s.scope.Region(), "forwardingRules", name), func() (*compute.Operation, error) {
+		return s.regionforwardingrules.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete forwarding rule")
+	}
+
+	if err := s.runDeleteOperation(path.Join("zones", zone, "targetInstances", name), func() (*compute.Operation, error) {
+		return s.targetinstances.Delete(s.scope.Project(), zone, name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete target instance")
+	}
+
+	return nil
+}
+
+// deleteTargetInstanceLoadbalancer deletes the components of a TargetInstance load balancer by name.
+func (s *Service) deleteTargetInstanceLoadbalancer() error {
+	name := s.apiServerLoadBalancerName()
+
+	if err := s.runOwnedDeleteOperation("forwarding rule", path.Join("regions", s.scope.Region(), "forwardingRules", name), func() (string, error) {
+		return s.describeRegionalForwardingRule(name)
+	}, func() (*compute.Operation, error) {
+		return s.regionforwardingrules.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to delete forwarding rule")
+	}
+	s.scope.Network().APIServerForwardingRule = nil
+
+	zones, err := s.GetZones()
+	if err != nil {
+		return err
+	}
+	for _, zone := range zones {
+		zone := zone
+		if err := s.runOwnedDeleteOperation("target instance", path.Join("zones", zone, "targetInstances", name), func() (string, error) {
+			targetInstance, err := s.targetinstances.Get(s.scope.Project(), zone, name).Do()
+			if err != nil {
+				return "", err
+			}
+
+			return targetInstance.Description, nil
+		}, func() (*compute.Operation, error) {
+			return s.targetinstances.Delete(s.scope.Project(), zone, name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete target instance")
+		}
+	}
+
+	if s.scope.ShouldRetain(infrav1.RetainAPIServerAddress) {
+		s.recordRetained("regional address", name)
+	} else {
+		if err := s.runOwnedDeleteOperation("regional address", path.Join("regions", s.scope.Region(), "addresses", name), func() (string, error) {
+			return s.describeRegionalAddress(name)
+		}, func() (*compute.Operation, error) {
+			return s.regionaddresses.Delete(s.scope.Project(), s.scope.Region(), name).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to delete regional address")
+		}
+	}
+	s.scope.Network().APIServerAddress = nil
+	s.scope.Network().APIServerAddressSelfLink = nil
+
+	return nil
+}
@@ -0,0 +1,77 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+)
+
+func TestTargetInstanceLoadBalancer(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	params := newTestClusterScopeParams(g, c)
+	params.GCPCluster.Spec.LoadBalancer.Type = infrav1.LoadBalancerTypeTargetInstance
+	s := NewService(newTestClusterScopeFromParams(g, params))
+	g.Expect(s.ReconcileNetwork()).To(Succeed())
+	g.Expect(s.ReconcileLoadbalancers()).To(Succeed())
+
+	// Only the regional address is reserved by the cluster.
+	g.Expect(c.List("projects/my-project/regions/us-central1/addresses")).To(ConsistOf("projects/my-project/regions/us-central1/addresses/my-cluster-apiserver"))
+	g.Expect(c.List("projects/my-project/global/backendServices")).To(BeEmpty())
+	g.Expect(s.scope.Network().APIServerAddress).NotTo(BeNil())
+
+	instances := make([]*compute.Instance, 2)
+	for i := range instances {
+		p := fmt.Sprintf("projects/my-project/zones/us-central1-a/instances/my-machine-%d", i)
+		instances[i] = &compute.Instance{Zone: c.SelfLink("projects/my-project/zones/us-central1-a"), SelfLink: c.SelfLink(p)}
+	}
+
+	// The forwarding rule points to the first control plane instance.
+	g.Expect(s.RegisterTargetInstance(instances[0])).To(Succeed())
+	g.Expect(s.RegisterTargetInstance(instances[1])).To(Succeed())
+	targetInstance := &compute.TargetInstance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/targetInstances/my-cluster-apiserver", targetInstance)).To(BeTrue())
+	g.Expect(targetInstance.Instance).To(Equal(instances[0].SelfLink))
+	forwardingRule := &compute.ForwardingRule{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/forwardingRules/my-cluster-apiserver", forwardingRule)).To(BeTrue())
+	g.Expect(forwardingRule.Target).To(Equal(targetInstance.SelfLink))
+	g.Expect(forwardingRule.PortRange).To(Equal("6443-6443"))
+	g.Expect(forwardingRule.IPAddress).To(Equal(*s.scope.Network().APIServerAddress))
+
+	// The next control plane instance takes over once the first one is deregistered.
+	g.Expect(s.DeregisterTargetInstance(instances[1])).To(Succeed())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/forwardingRules/my-cluster-apiserver", nil)).To(BeTrue())
+	g.Expect(s.DeregisterTargetInstance(instances[0])).To(Succeed())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/forwardingRules/my-cluster-apiserver", nil)).To(BeFalse())
+	g.Expect(s.RegisterTargetInstance(instances[1])).To(Succeed())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/targetInstances/my-cluster-apiserver", targetInstance)).To(BeTrue())
+	g.Expect(targetInstance.Instance).To(Equal(instances[1].SelfLink))
+
+	g.Expect(s.DeleteLoadbalancers()).To(Succeed())
+	g.Expect(c.List("projects/my-project/regions/us-central1/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/targetInstances")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/regions/us-central1/addresses")).To(BeEmpty())
+}
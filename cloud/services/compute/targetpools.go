@@ -0,0 +1,78 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package compute
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+// EnsureTargetPoolMember adds the instance to the target pool of the region, unless already added.
+func (s *Service) EnsureTargetPoolMember(region, name string, i *compute.Instance) error {
+	pool, err := s.targetpools.Get(s.scope.Project(), region, name).Do()
+	if err != nil {
+		return errors.Wrapf(err, "failed to describe target pool %q", name)
+	}
+	for _, instance := range pool.Instances {
+		if instance == i.SelfLink {
+			return nil
+		}
+	}
+
+	req := &compute.TargetPoolsAddInstanceRequest{
+		Instances: []*compute.InstanceReference{{Instance: i.SelfLink}},
+	}
+	if _, err := s.runOperation(path.Join("regions", region, "targetPools", name), "addInstance", func() (*compute.Operation, error) {
+		return s.targetpools.AddInstance(s.scope.Project(), region, name, req).Do()
+	}); err != nil {
+		return errors.Wrapf(err, "failed to add instance to target pool %q", name)
+	}
+
+	return nil
+}
+
+// RemoveTargetPoolMember removes the instance from the target pool of the region, if added. The target pool
+// deleted in the meantime is ignored.
+func (s *Service) RemoveTargetPoolMember(region, name string, i *compute.Instance) error {
+	pool, err := s.targetpools.Get(s.scope.Project(), region, name).Do()
+	switch {
+	case gcperrors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return errors.Wrapf(err, "failed to describe target pool %q", name)
+	}
+
+	for _, instance := range pool.Instances {
+		if instance != i.SelfLink {
+			continue
+		}
+		req := &compute.TargetPoolsRemoveInstanceRequest{
+			Instances: []*compute.InstanceReference{{Instance: i.SelfLink}},
+		}
+		if _, err := s.runOperation(path.Join("regions", region, "targetPools", name), "removeInstance", func() (*compute.Operation, error) {
+			return s.targetpools.RemoveInstance(s.scope.Project(), region, name, req).Do()
+		}); err != nil {
+			return errors.Wrapf(err, "failed to remove instance from target pool %q", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,286 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+// ReconcileCluster creates the GKE cluster of the GCPManagedControlPlane, with the node pools of the machine pools
+// of the cluster, and upgrades its control plane. It returns the GKE cluster once it can be reached, nil while it's
+// being created.
+func (s *ClusterService) ReconcileCluster(ctx context.Context) (*container.Cluster, error) {
+	controlPlane := s.scope.GCPManagedControlPlane
+	name := s.scope.ClusterFullName()
+
+	cluster, err := s.clusters.Get(name).Do()
+	if gcperrors.IsNotFound(err) {
+		return nil, s.createCluster(ctx)
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe GKE cluster %q", name)
+	}
+	if !s.isOwned(cluster) {
+		if !s.scope.ShouldAdopt() {
+			conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneNotOwnedReason, clusterv1.ConditionSeverityError,
+				"GKE cluster %q isn't owned by the cluster", s.scope.ClusterName())
+			return nil, errors.Errorf("GKE cluster %q exists and isn't owned by the cluster, set the %s annotation to adopt it",
+				name, infrav1.AdoptAnnotation)
+		}
+		return nil, s.adopt(cluster)
+	}
+
+	switch cluster.Status {
+	case "PROVISIONING":
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneProvisioningReason, clusterv1.ConditionSeverityInfo, "")
+		return nil, nil
+	case "STOPPING":
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneDeletingReason, clusterv1.ConditionSeverityWarning, "")
+		return nil, nil
+	case "ERROR":
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneErrorReason, clusterv1.ConditionSeverityError, cluster.StatusMessage)
+		return nil, nil
+	case "RECONCILING":
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneReconcilingReason, clusterv1.ConditionSeverityInfo, cluster.StatusMessage)
+	case "DEGRADED":
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneErrorReason, clusterv1.ConditionSeverityWarning, cluster.StatusMessage)
+	default:
+		conditions.MarkTrue(controlPlane, expinfrav1.GKEControlPlaneReadyCondition)
+	}
+	controlPlane.Status.CurrentVersion = cluster.CurrentMasterVersion
+
+	// The control plane can only be upgraded while no other operation is running on the cluster.
+	version := pointer.StringDeref(controlPlane.Spec.ControlPlaneVersion, "")
+	if cluster.Status == "RUNNING" && version != "" && !versionMatches(cluster.CurrentMasterVersion, version) {
+		req := &container.UpdateClusterRequest{Update: &container.ClusterUpdate{DesiredMasterVersion: strings.TrimPrefix(version, "v")}}
+		if _, err := s.clusters.Update(name, req).Do(); err != nil {
+			return nil, errors.Wrapf(err, "failed to upgrade GKE cluster %q", name)
+		}
+		record.Eventf(controlPlane, "UpgradingGKEControlPlane", "Upgrading control plane of GKE cluster %q from %s to %s",
+			s.scope.ClusterName(), cluster.CurrentMasterVersion, version)
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneReconcilingReason, clusterv1.ConditionSeverityInfo,
+			"Upgrading to %s", version)
+		return cluster, nil
+	}
+
+	// The node auto-provisioning is updated once the control plane is upgraded, one operation at a time.
+	if desired := clusterAutoscaling(controlPlane.Spec.ClusterAutoscaling); cluster.Status == "RUNNING" && !controlPlane.Spec.EnableAutopilot &&
+		!clusterAutoscalingMatches(cluster.Autoscaling, desired) {
+		req := &container.UpdateClusterRequest{Update: &container.ClusterUpdate{DesiredClusterAutoscaling: desired}}
+		if _, err := s.clusters.Update(name, req).Do(); err != nil {
+			return nil, errors.Wrapf(err, "failed to update autoscaling of GKE cluster %q", name)
+		}
+		record.Eventf(controlPlane, "UpdatedGKEClusterAutoscaling", "Updated node auto-provisioning of GKE cluster %q", s.scope.ClusterName())
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneReconcilingReason, clusterv1.ConditionSeverityInfo,
+			"Updating node auto-provisioning")
+	}
+
+	return cluster, nil
+}
+
+// clusterAutoscaling returns the node auto-provisioning of the GKE cluster, disabled if unset.
+func clusterAutoscaling(autoscaling *expinfrav1.ClusterAutoscaling) *container.ClusterAutoscaling {
+	if autoscaling == nil {
+		return &container.ClusterAutoscaling{ForceSendFields: []string{"EnableNodeAutoprovisioning"}}
+	}
+	res := &container.ClusterAutoscaling{EnableNodeAutoprovisioning: true}
+	for _, limit := range autoscaling.ResourceLimits {
+		res.ResourceLimits = append(res.ResourceLimits, &container.ResourceLimit{
+			ResourceType: limit.ResourceType,
+			Minimum:      limit.Minimum,
+			Maximum:      limit.Maximum,
+		})
+	}
+
+	return res
+}
+
+// clusterAutoscalingMatches returns true if the node auto-provisioning of the GKE cluster is the desired one, the
+// resource limits being compared once it's enabled.
+func clusterAutoscalingMatches(current, desired *container.ClusterAutoscaling) bool {
+	if current == nil {
+		current = &container.ClusterAutoscaling{}
+	}
+	if current.EnableNodeAutoprovisioning != desired.EnableNodeAutoprovisioning {
+		return false
+	}
+	if !desired.EnableNodeAutoprovisioning {
+		return true
+	}
+
+	limits := map[string][2]int64{}
+	for _, limit := range current.ResourceLimits {
+		limits[limit.ResourceType] = [2]int64{limit.Minimum, limit.Maximum}
+	}
+	if len(limits) != len(desired.ResourceLimits) {
+		return false
+	}
+	for _, limit := range desired.ResourceLimits {
+		if current, ok := limits[limit.ResourceType]; !ok || current != [2]int64{limit.Minimum, limit.Maximum} {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isOwned returns true if the GKE cluster is labelled as owned by the cluster, in its namespace.
+func (s *ClusterService) isOwned(cluster *container.Cluster) bool {
+	labels := infrav1.Labels(cluster.ResourceLabels)
+
+	return labels.HasOwned(s.scope.Cluster.Name) && labels[infrav1.NameGCPClusterNamespace] == s.scope.Cluster.Namespace
+}
+
+// adopt labels the pre-existing GKE cluster as owned by the cluster. The labels can only be set while no other
+// operation is running on the cluster, the cluster is requeued until then.
+func (s *ClusterService) adopt(cluster *container.Cluster) error {
+	if cluster.Status != "RUNNING" {
+		return nil
+	}
+	name := s.scope.ClusterFullName()
+	labels := s.scope.ResourceLabels()
+	for k, v := range cluster.ResourceLabels {
+		if _, ok := labels[k]; !ok {
+			labels[k] = v
+		}
+	}
+	req := &container.SetLabelsRequest{ResourceLabels: labels, LabelFingerprint: cluster.LabelFingerprint}
+	if _, err := s.clusters.SetResourceLabels(name, req).Do(); err != nil {
+		return errors.Wrapf(err, "failed to label GKE cluster %q", name)
+	}
+	record.Eventf(s.scope.GCPManagedControlPlane, "AdoptedResource", "Adopted pre-existing GKE cluster %q", s.scope.ClusterName())
+
+	return nil
+}
+
+// retain removes the labels marking the GKE cluster as owned by the cluster, which keeps it for another cluster to
+// adopt. The cluster is deleted from the next reconcile on, once the labels are removed.
+func (s *ClusterService) retain(cluster *container.Cluster) error {
+	name := s.scope.ClusterFullName()
+	labels := map[string]string{}
+	for k, v := range cluster.ResourceLabels {
+		if k != infrav1.ClusterTagKey(s.scope.Cluster.Name) && k != infrav1.NameGCPClusterNamespace {
+			labels[k] = v
+		}
+	}
+	req := &container.SetLabelsRequest{ResourceLabels: labels, LabelFingerprint: cluster.LabelFingerprint}
+	if _, err := s.clusters.SetResourceLabels(name, req).Do(); err != nil {
+		return errors.Wrapf(err, "failed to unlabel GKE cluster %q", name)
+	}
+	record.Eventf(s.scope.GCPManagedControlPlane, "RetainedResource", "Retained GKE cluster %q, no longer owned by the cluster", s.scope.ClusterName())
+
+	return nil
+}
+
+// createCluster creates the GKE cluster, a standard cluster being created once it has machine pools.
+func (s *ClusterService) createCluster(ctx context.Context) error {
+	controlPlane := s.scope.GCPManagedControlPlane
+	cluster := &container.Cluster{
+		Name:                  s.scope.ClusterName(),
+		Network:               s.scope.NetworkName(),
+		Subnetwork:            s.scope.SubnetworkName(),
+		ResourceLabels:        s.scope.ResourceLabels(),
+		InitialClusterVersion: strings.TrimPrefix(pointer.StringDeref(controlPlane.Spec.ControlPlaneVersion, ""), "v"),
+	}
+	if policy := controlPlane.Spec.IPAllocationPolicy; policy != nil {
+		cluster.IpAllocationPolicy = &container.IPAllocationPolicy{
+			UseIpAliases:               true,
+			ClusterSecondaryRangeName:  policy.ClusterSecondaryRangeName,
+			ServicesSecondaryRangeName: policy.ServicesSecondaryRangeName,
+			ClusterIpv4CidrBlock:       policy.ClusterCidrBlock,
+			ServicesIpv4CidrBlock:      policy.ServicesCidrBlock,
+		}
+	}
+	if controlPlane.Spec.ReleaseChannel != nil {
+		cluster.ReleaseChannel = &container.ReleaseChannel{Channel: strings.ToUpper(string(*controlPlane.Spec.ReleaseChannel))}
+	}
+	if controlPlane.Spec.EnableAutopilot {
+		cluster.Autopilot = &container.Autopilot{Enabled: true}
+	} else {
+		cluster.Autoscaling = clusterAutoscaling(controlPlane.Spec.ClusterAutoscaling)
+		machinePools, err := s.scope.MachinePools(ctx)
+		if err != nil {
+			return err
+		}
+		if len(machinePools) == 0 {
+			conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.WaitingForMachinePoolsReason, clusterv1.ConditionSeverityInfo,
+				"A standard GKE cluster is created with its node pools")
+			return nil
+		}
+		for _, mp := range machinePools {
+			cluster.NodePools = append(cluster.NodePools, NodePool(mp.MachinePool, mp.GCPManagedMachinePool, nodeLocations(s.scope.Zonal())))
+		}
+	}
+
+	if _, err := s.clusters.Create(s.scope.LocationFullName(), &container.CreateClusterRequest{Cluster: cluster}).Do(); err != nil {
+		return errors.Wrapf(err, "failed to create GKE cluster %q", s.scope.ClusterFullName())
+	}
+	record.Eventf(controlPlane, "SuccessfulCreate", "Created GKE cluster %q in %s", cluster.Name, s.scope.Location())
+	conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneProvisioningReason, clusterv1.ConditionSeverityInfo, "")
+
+	return nil
+}
+
+// DeleteCluster deletes the GKE cluster and its node pools. It returns true once the cluster is gone.
+func (s *ClusterService) DeleteCluster() (bool, error) {
+	controlPlane := s.scope.GCPManagedControlPlane
+	name := s.scope.ClusterFullName()
+
+	cluster, err := s.clusters.Get(name).Do()
+	if gcperrors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to describe GKE cluster %q", name)
+	}
+	// A cluster which was never created nor adopted by the cluster is left alone, as is a retained cluster once
+	// unlabelled.
+	if !s.isOwned(cluster) {
+		if controlPlane.Spec.DeletionPolicy != expinfrav1.DeletionPolicyRetain {
+			record.Eventf(controlPlane, "RetainedResource", "Retained GKE cluster %q which isn't owned by the cluster", s.scope.ClusterName())
+		}
+		return true, nil
+	}
+
+	conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.GKEControlPlaneDeletingReason, clusterv1.ConditionSeverityInfo, "")
+	// The cluster is being deleted, or another operation is running which the deletion has to wait for.
+	if cluster.Status == "STOPPING" || cluster.Status == "PROVISIONING" || cluster.Status == "RECONCILING" {
+		return false, nil
+	}
+	if controlPlane.Spec.DeletionPolicy == expinfrav1.DeletionPolicyRetain {
+		return false, s.retain(cluster)
+	}
+	if _, err := s.clusters.Delete(name).Do(); err != nil {
+		if gcperrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, errors.Wrapf(err, "failed to delete GKE cluster %q", name)
+	}
+	record.Eventf(controlPlane, "SuccessfulDelete", "Deleting GKE cluster %q", s.scope.ClusterName())
+
+	return false, nil
+}
@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+)
+
+// ReconcileNetwork checks that the network of the GCPManagedCluster exists, and returns the zones of its region as
+// the failure domains of the cluster. It returns an error if the network doesn't exist, GKE doesn't create it.
+func (s *NetworkService) ReconcileNetwork() (clusterv1.FailureDomains, error) {
+	name := s.scope.NetworkName()
+	if _, err := s.networks.Get(s.scope.Project(), name).Do(); err != nil {
+		if gcperrors.IsNotFound(err) {
+			return nil, errors.Errorf("network %q of GKE cluster not found in project %q", name, s.scope.Project())
+		}
+		return nil, errors.Wrapf(err, "failed to describe network %q", name)
+	}
+
+	region, err := s.regions.Get(s.scope.Project(), s.scope.Region()).Do()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to describe region %q", s.scope.Region())
+	}
+
+	failureDomains := make(clusterv1.FailureDomains, len(region.Zones))
+	for _, zone := range region.Zones {
+		failureDomains[path.Base(zone)] = clusterv1.FailureDomainSpec{ControlPlane: false}
+	}
+
+	return failureDomains, nil
+}
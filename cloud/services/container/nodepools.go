@@ -0,0 +1,287 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+// defaultRegionalNodeLocations is the number of zones the nodes of the regional GKE clusters are spread across
+// by default.
+const defaultRegionalNodeLocations = 3
+
+// taintEffects are the GKE effects of the taints.
+var taintEffects = map[corev1.TaintEffect]string{
+	corev1.TaintEffectNoSchedule:       "NO_SCHEDULE",
+	corev1.TaintEffectPreferNoSchedule: "PREFER_NO_SCHEDULE",
+	corev1.TaintEffectNoExecute:        "NO_EXECUTE",
+}
+
+// NodePool returns the GKE node pool of the GCPManagedMachinePool, the replicas of the MachinePool being spread
+// across the zones of the node pool: GKE sizes the node pools per zone.
+func NodePool(machinePool *expclusterv1.MachinePool, gcpManagedMachinePool *expinfrav1.GCPManagedMachinePool, zones int) *container.NodePool {
+	spec := gcpManagedMachinePool.Spec
+	nodePool := &container.NodePool{
+		Name:             scope.NodePoolName(gcpManagedMachinePool),
+		InitialNodeCount: nodeCount(machinePool, zones),
+		Version:          strings.TrimPrefix(pointer.StringDeref(machinePool.Spec.Template.Spec.Version, ""), "v"),
+		Config: &container.NodeConfig{
+			MachineType: pointer.StringDeref(spec.MachineType, ""),
+			DiskSizeGb:  pointer.Int64Deref(spec.DiskSizeGB, 0),
+			Preemptible: spec.Preemptible,
+			Labels:      spec.KubernetesLabels,
+		},
+		Autoscaling: autoscaling(spec.Scaling),
+	}
+	if config := spec.KubeletConfig; config != nil {
+		nodePool.Config.KubeletConfig = &container.NodeKubeletConfig{
+			CpuManagerPolicy:  config.CPUManagerPolicy,
+			CpuCfsQuota:       pointer.BoolDeref(config.CPUCFSQuota, true),
+			CpuCfsQuotaPeriod: config.CPUCFSQuotaPeriod,
+			// The CFS quota is enforced by default, disabling it has to be sent.
+			ForceSendFields: []string{"CpuCfsQuota"},
+		}
+	}
+	if len(spec.LinuxSysctls) > 0 {
+		nodePool.Config.LinuxNodeConfig = &container.LinuxNodeConfig{Sysctls: spec.LinuxSysctls}
+	}
+	for _, taint := range spec.KubernetesTaints {
+		nodePool.Config.Taints = append(nodePool.Config.Taints, &container.NodeTaint{
+			Key:    taint.Key,
+			Value:  taint.Value,
+			Effect: taintEffects[taint.Effect],
+		})
+	}
+
+	return nodePool
+}
+
+// nodeLocations returns the number of zones of the node pools of a new GKE cluster.
+func nodeLocations(zonal bool) int {
+	if zonal {
+		return 1
+	}
+
+	return defaultRegionalNodeLocations
+}
+
+// nodeCount returns the number of nodes per zone of the node pool of the MachinePool, rounded up.
+func nodeCount(machinePool *expclusterv1.MachinePool, zones int) int64 {
+	replicas := int64(pointer.Int32Deref(machinePool.Spec.Replicas, 1))
+	if zones <= 1 {
+		return replicas
+	}
+
+	return (replicas + int64(zones) - 1) / int64(zones)
+}
+
+func autoscaling(scaling *expinfrav1.NodePoolAutoscaling) *container.NodePoolAutoscaling {
+	if scaling == nil {
+		return nil
+	}
+
+	return &container.NodePoolAutoscaling{Enabled: true, MinNodeCount: int64(scaling.MinCount), MaxNodeCount: int64(scaling.MaxCount)}
+}
+
+// ReconcileNodePool creates the node pool of the GCPManagedMachinePool, then upgrades it to the version of the
+// MachinePool, updates its autoscaling and resizes it to the replicas of the MachinePool, one operation at a time.
+// It sets the provider IDs of the instances of the node pool, and returns true once the node pool is running.
+func (s *NodePoolService) ReconcileNodePool() (bool, error) {
+	pool := s.scope.GCPManagedMachinePool
+	name := s.scope.NodePoolFullName()
+
+	nodePool, err := s.nodepools.Get(name).Do()
+	if gcperrors.IsNotFound(err) {
+		nodePool = NodePool(s.scope.MachinePool, pool, nodeLocations(s.scope.Zonal()))
+		if _, err := s.nodepools.Create(s.scope.ClusterFullName(), &container.CreateNodePoolRequest{NodePool: nodePool}).Do(); err != nil {
+			return false, errors.Wrapf(err, "failed to create GKE node pool %q", name)
+		}
+		record.Eventf(pool, "SuccessfulCreate", "Created GKE node pool %q", nodePool.Name)
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolProvisioningReason, clusterv1.ConditionSeverityInfo, "")
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to describe GKE node pool %q", name)
+	}
+
+	switch nodePool.Status {
+	case "PROVISIONING":
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolProvisioningReason, clusterv1.ConditionSeverityInfo, "")
+		return false, nil
+	case "STOPPING":
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolDeletingReason, clusterv1.ConditionSeverityWarning, "")
+		return false, nil
+	case "ERROR":
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolErrorReason, clusterv1.ConditionSeverityError, nodePool.StatusMessage)
+		return false, nil
+	case "RECONCILING":
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolReconcilingReason, clusterv1.ConditionSeverityInfo, nodePool.StatusMessage)
+	case "RUNNING_WITH_ERROR":
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolErrorReason, clusterv1.ConditionSeverityWarning, nodePool.StatusMessage)
+	default:
+		conditions.MarkTrue(pool, expinfrav1.GKEMachinePoolReadyCondition)
+	}
+
+	sizes, providerIDs, err := s.instances(nodePool)
+	if err != nil {
+		return false, err
+	}
+	pool.Spec.ProviderIDList = providerIDs
+	pool.Status.Replicas = int32(len(providerIDs))
+
+	if nodePool.Status == "RUNNING" {
+		if err := s.updateNodePool(nodePool, sizes); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// instances returns the target sizes of the instance groups of the node pool and the provider IDs of their instances.
+func (s *NodePoolService) instances(nodePool *container.NodePool) ([]int64, []string, error) {
+	sizes := []int64{}
+	providerIDs := []string{}
+	for _, u := range nodePool.InstanceGroupUrls {
+		// e.g. https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/instanceGroupManagers/gke-my-cluster-my-pool-grp
+		parts := strings.Split(u, "/")
+		if len(parts) < 6 {
+			return nil, nil, errors.Errorf("unexpected instance group %q of GKE node pool %q", u, nodePool.Name)
+		}
+		project, zone, name := parts[len(parts)-5], parts[len(parts)-3], parts[len(parts)-1]
+		igm, err := s.instancegroupmanagers.Get(project, zone, name).Do()
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to describe instance group manager %q", name)
+		}
+		sizes = append(sizes, igm.TargetSize)
+		instances, err := s.instancegroupmanagers.ListManagedInstances(project, zone, name).Do()
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "failed to list instances of instance group manager %q", name)
+		}
+		for _, i := range instances.ManagedInstances {
+			providerIDs = append(providerIDs, fmt.Sprintf("gce://%s/%s/%s", project, zone, path.Base(i.Instance)))
+		}
+	}
+
+	return sizes, providerIDs, nil
+}
+
+// updateNodePool performs the first update the running node pool needs, GKE running one operation at a time.
+func (s *NodePoolService) updateNodePool(nodePool *container.NodePool, sizes []int64) error {
+	pool := s.scope.GCPManagedMachinePool
+	name := s.scope.NodePoolFullName()
+
+	// GKE doesn't run the nodes at a version newer than the control plane, which is upgraded first.
+	version := strings.TrimPrefix(pointer.StringDeref(s.scope.MachinePool.Spec.Template.Spec.Version, ""), "v")
+	upgrade := version != "" && !versionMatches(nodePool.Version, version)
+	if upgrade && !versionMatches(s.scope.GCPManagedControlPlane.Status.CurrentVersion, version) {
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.WaitingForGKEControlPlaneReason, clusterv1.ConditionSeverityInfo,
+			"Waiting for the control plane to run %s before upgrading", version)
+	} else if upgrade {
+		req := &container.UpdateNodePoolRequest{NodeVersion: version}
+		if nodePool.Config != nil {
+			req.ImageType = nodePool.Config.ImageType
+		}
+		if _, err := s.nodepools.Update(name, req).Do(); err != nil {
+			return errors.Wrapf(err, "failed to upgrade GKE node pool %q", name)
+		}
+		record.Eventf(pool, "UpgradingGKENodePool", "Upgrading GKE node pool %q from %s to %s", nodePool.Name, nodePool.Version, version)
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolReconcilingReason, clusterv1.ConditionSeverityInfo,
+			"Upgrading to %s", version)
+		return nil
+	}
+
+	desired := autoscaling(pool.Spec.Scaling)
+	if desired == nil {
+		desired = &container.NodePoolAutoscaling{}
+	}
+	current := nodePool.Autoscaling
+	if current == nil {
+		current = &container.NodePoolAutoscaling{}
+	}
+	if desired.Enabled != current.Enabled || desired.MinNodeCount != current.MinNodeCount || desired.MaxNodeCount != current.MaxNodeCount {
+		req := &container.SetNodePoolAutoscalingRequest{Autoscaling: desired}
+		if _, err := s.nodepools.SetAutoscaling(name, req).Do(); err != nil {
+			return errors.Wrapf(err, "failed to set autoscaling of GKE node pool %q", name)
+		}
+		record.Eventf(pool, "UpdatedGKENodePoolAutoscaling", "Updated autoscaling of GKE node pool %q", nodePool.Name)
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolReconcilingReason, clusterv1.ConditionSeverityInfo,
+			"Updating autoscaling")
+		return nil
+	}
+
+	// The autoscaled node pools are sized by the cluster autoscaler.
+	if pool.Spec.Scaling != nil || len(sizes) == 0 {
+		return nil
+	}
+	count := nodeCount(s.scope.MachinePool, len(sizes))
+	for _, size := range sizes {
+		if size == count {
+			continue
+		}
+		if _, err := s.nodepools.SetSize(name, &container.SetNodePoolSizeRequest{NodeCount: count}).Do(); err != nil {
+			return errors.Wrapf(err, "failed to resize GKE node pool %q", name)
+		}
+		record.Eventf(pool, "ResizingGKENodePool", "Resizing GKE node pool %q to %d nodes per zone in %d zones", nodePool.Name, count, len(sizes))
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolReconcilingReason, clusterv1.ConditionSeverityInfo,
+			"Resizing to %d nodes per zone", count)
+		return nil
+	}
+
+	return nil
+}
+
+// DeleteNodePool deletes the node pool. It returns true once the node pool is gone.
+func (s *NodePoolService) DeleteNodePool() (bool, error) {
+	pool := s.scope.GCPManagedMachinePool
+	name := s.scope.NodePoolFullName()
+
+	nodePool, err := s.nodepools.Get(name).Do()
+	if gcperrors.IsNotFound(err) {
+		return true, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "failed to describe GKE node pool %q", name)
+	}
+
+	conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.GKEMachinePoolDeletingReason, clusterv1.ConditionSeverityInfo, "")
+	if nodePool.Status == "STOPPING" || nodePool.Status == "PROVISIONING" || nodePool.Status == "RECONCILING" {
+		return false, nil
+	}
+	if _, err := s.nodepools.Delete(name).Do(); err != nil {
+		if gcperrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, errors.Wrapf(err, "failed to delete GKE node pool %q", name)
+	}
+	record.Eventf(pool, "SuccessfulDelete", "Deleting GKE node pool %q", nodePool.Name)
+
+	return false, nil
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package container implements the reconciliation of the GKE clusters and node pools of the GKE managed clusters.
+// The long running operations of GKE are not waited for: the clusters and node pools are requeued until their
+// status is reconciled.
+package container
+
+import (
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+// ClusterService reconciles the GKE cluster of a GCPManagedControlPlane.
+type ClusterService struct {
+	scope *scope.ManagedControlPlaneScope
+
+	clusters *container.ProjectsLocationsClustersService
+}
+
+// NewClusterService returns a ClusterService for the scope.
+func NewClusterService(scope *scope.ManagedControlPlaneScope) *ClusterService {
+	return &ClusterService{
+		scope:    scope,
+		clusters: scope.Container.Projects.Locations.Clusters,
+	}
+}
+
+// NodePoolService reconciles the GKE node pool of a GCPManagedMachinePool.
+type NodePoolService struct {
+	scope *scope.ManagedMachinePoolScope
+
+	nodepools             *container.ProjectsLocationsClustersNodePoolsService
+	instancegroupmanagers *compute.InstanceGroupManagersService
+}
+
+// NewNodePoolService returns a NodePoolService for the scope.
+func NewNodePoolService(scope *scope.ManagedMachinePoolScope) *NodePoolService {
+	return &NodePoolService{
+		scope:                 scope,
+		nodepools:             scope.Container.Projects.Locations.Clusters.NodePools,
+		instancegroupmanagers: scope.Compute.InstanceGroupManagers,
+	}
+}
+
+// versionMatches returns true if the version of GKE, e.g. 1.21.5-gke.1302, is the desired version or one of its
+// patches, e.g. 1.21 or v1.21.5.
+func versionMatches(version, desired string) bool {
+	desired = strings.TrimPrefix(desired, "v")
+
+	return version == desired || strings.HasPrefix(version, desired+".") || strings.HasPrefix(version, desired+"-")
+}
+
+// NetworkService checks the network of a GCPManagedCluster, which GKE doesn't create.
+type NetworkService struct {
+	scope *scope.ManagedClusterScope
+
+	networks *compute.NetworksService
+	regions  *compute.RegionsService
+}
+
+// NewNetworkService returns a NetworkService for the scope.
+func NewNetworkService(scope *scope.ManagedClusterScope) *NetworkService {
+	return &NetworkService{
+		scope:    scope,
+		networks: scope.Compute.Networks,
+		regions:  scope.Compute.Regions,
+	}
+}
@@ -0,0 +1,558 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	capirecord "sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+const (
+	testProject = "my-project"
+	testRegion  = "us-central1"
+	testCluster = "projects/my-project/locations/us-central1/clusters/my-cluster"
+)
+
+var testEvents = &eventRecorder{}
+
+func init() {
+	capirecord.InitFromRecorder(testEvents)
+}
+
+// eventRecorder collects the messages of the events.
+type eventRecorder struct {
+	mu       sync.Mutex
+	messages []string
+}
+
+func (r *eventRecorder) Event(_ runtime.Object, eventtype, reason, message string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages = append(r.messages, fmt.Sprintf("%s %s %s", eventtype, reason, message))
+}
+
+func (r *eventRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+func (r *eventRecorder) AnnotatedEventf(object runtime.Object, _ map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Eventf(object, eventtype, reason, messageFmt, args...)
+}
+
+// Messages returns the messages recorded since the last call.
+func (r *eventRecorder) Messages() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	res := r.messages
+	r.messages = nil
+
+	return res
+}
+
+func newTestClient(g *WithT, objs ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expclusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expinfrav1.AddToScheme(scheme)).To(Succeed())
+
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+}
+
+func newTestCluster() (*clusterv1.Cluster, *expinfrav1.GCPManagedCluster, *expinfrav1.GCPManagedControlPlane) {
+	return &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}},
+		&expinfrav1.GCPManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+			Spec:       expinfrav1.GCPManagedClusterSpec{Project: testProject, Region: testRegion},
+		},
+		&expinfrav1.GCPManagedControlPlane{ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"}}
+}
+
+func newTestMachinePool(replicas int32) (*expclusterv1.MachinePool, *expinfrav1.GCPManagedMachinePool) {
+	return &expclusterv1.MachinePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-pool",
+			Namespace: "default",
+			Labels:    map[string]string{clusterv1.ClusterLabelName: "my-cluster"},
+		},
+		Spec: expclusterv1.MachinePoolSpec{
+			ClusterName: "my-cluster",
+			Replicas:    pointer.Int32Ptr(replicas),
+			Template: clusterv1.MachineTemplateSpec{Spec: clusterv1.MachineSpec{
+				ClusterName: "my-cluster",
+				InfrastructureRef: corev1.ObjectReference{
+					APIVersion: expinfrav1.GroupVersion.String(),
+					Kind:       "GCPManagedMachinePool",
+					Name:       "my-pool",
+				},
+			}},
+		},
+	}, &expinfrav1.GCPManagedMachinePool{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-pool", Namespace: "default"},
+	}
+}
+
+func newTestClusterService(g *WithT, c *fakecloud.Cloud, objs ...client.Object) *ClusterService {
+	cluster, gcpManagedCluster, controlPlane := newTestCluster()
+	controlPlaneScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
+		Cloud:                  c,
+		Client:                 newTestClient(g, objs...),
+		Cluster:                cluster,
+		GCPManagedCluster:      gcpManagedCluster,
+		GCPManagedControlPlane: controlPlane,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return NewClusterService(controlPlaneScope)
+}
+
+func newTestNodePoolService(g *WithT, c *fakecloud.Cloud, mp *expclusterv1.MachinePool, pool *expinfrav1.GCPManagedMachinePool) *NodePoolService {
+	cluster, gcpManagedCluster, controlPlane := newTestCluster()
+	controlPlane.Status.CurrentVersion = fakecloud.DefaultGKEVersion
+	machinePoolScope, err := scope.NewManagedMachinePoolScope(scope.ManagedMachinePoolScopeParams{
+		Cloud:                  c,
+		Client:                 newTestClient(g),
+		Cluster:                cluster,
+		MachinePool:            mp,
+		GCPManagedCluster:      gcpManagedCluster,
+		GCPManagedControlPlane: controlPlane,
+		GCPManagedMachinePool:  pool,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return NewNodePoolService(machinePoolScope)
+}
+
+func TestReconcileNetwork(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion(testProject, testRegion, testRegion+"-a", testRegion+"-b")
+
+	cluster, gcpManagedCluster, _ := newTestCluster()
+	clusterScope, err := scope.NewManagedClusterScope(scope.ManagedClusterScopeParams{
+		Cloud:             c,
+		Client:            newTestClient(g),
+		Cluster:           cluster,
+		GCPManagedCluster: gcpManagedCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	s := NewNetworkService(clusterScope)
+
+	// GKE doesn't create the network.
+	_, err = s.ReconcileNetwork()
+	g.Expect(err).To(MatchError(ContainSubstring(`network "default" of GKE cluster not found`)))
+
+	c.Put("projects/my-project/global/networks/default", &compute.Network{Name: "default"})
+	failureDomains, err := s.ReconcileNetwork()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(failureDomains).To(Equal(clusterv1.FailureDomains{
+		"us-central1-a": clusterv1.FailureDomainSpec{},
+		"us-central1-b": clusterv1.FailureDomainSpec{},
+	}))
+}
+
+func TestReconcileCluster(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	// A standard cluster waits for its first machine pool.
+	s := newTestClusterService(g, c)
+	testEvents.Messages()
+	gkeCluster, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gkeCluster).To(BeNil())
+	g.Expect(c.Get(testCluster, nil)).To(BeFalse())
+	g.Expect(conditions.GetReason(s.scope.GCPManagedControlPlane, expinfrav1.GKEControlPlaneReadyCondition)).To(Equal(expinfrav1.WaitingForMachinePoolsReason))
+
+	mp, pool := newTestMachinePool(4)
+	s = newTestClusterService(g, c, mp, pool)
+	gkeCluster, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gkeCluster).To(BeNil())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal SuccessfulCreate Created GKE cluster "my-cluster" in us-central1`))
+
+	created := &container.Cluster{}
+	g.Expect(c.Get(testCluster, created)).To(BeTrue())
+	g.Expect(created.Network).To(Equal("default"))
+	g.Expect(created.IpAllocationPolicy).To(BeNil())
+	g.Expect(created.ResourceLabels).To(HaveKeyWithValue("capg-cluster-my-cluster", "owned"))
+	g.Expect(created.ResourceLabels).To(HaveKeyWithValue("capg-namespace", "default"))
+	nodePool := &container.NodePool{}
+	g.Expect(c.Get(testCluster+"/nodePools/my-pool", nodePool)).To(BeTrue())
+	// The replicas are spread across the 3 zones of the regional cluster, rounded up.
+	g.Expect(nodePool.InitialNodeCount).To(BeEquivalentTo(2))
+	g.Expect(nodePool.InstanceGroupUrls).To(HaveLen(3))
+
+	gkeCluster, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gkeCluster.Endpoint).To(Equal(fakecloud.GKEEndpoint))
+	g.Expect(conditions.IsTrue(s.scope.GCPManagedControlPlane, expinfrav1.GKEControlPlaneReadyCondition)).To(BeTrue())
+	g.Expect(s.scope.GCPManagedControlPlane.Status.CurrentVersion).To(Equal(fakecloud.DefaultGKEVersion))
+
+	// The control plane is upgraded to a new version.
+	s.scope.GCPManagedControlPlane.Spec.ControlPlaneVersion = pointer.StringPtr("v1.22")
+	_, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal UpgradingGKEControlPlane Upgrading control plane of GKE cluster "my-cluster" from 1.21.5-gke.1302 to v1.22`))
+	g.Expect(c.Get(testCluster, created)).To(BeTrue())
+	g.Expect(created.CurrentMasterVersion).To(Equal("1.22"))
+
+	// The cluster runs the version, a second pass must be a no-op.
+	_, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(BeEmpty())
+	g.Expect(s.scope.GCPManagedControlPlane.Status.CurrentVersion).To(Equal("1.22"))
+}
+
+func TestReconcileClusterDryRun(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	mp, pool := newTestMachinePool(1)
+	cluster, gcpManagedCluster, controlPlane := newTestCluster()
+	dryRun := &cloud.DryRun{}
+	controlPlaneScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
+		Cloud:                  c,
+		Client:                 newTestClient(g, mp, pool),
+		Cluster:                cluster,
+		GCPManagedCluster:      gcpManagedCluster,
+		GCPManagedControlPlane: controlPlane,
+		ManagedTransports:      scope.ManagedTransports{DryRun: dryRun},
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+	s := NewClusterService(controlPlaneScope)
+
+	gkeCluster, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gkeCluster).To(BeNil())
+	g.Expect(c.Get(testCluster, nil)).To(BeFalse())
+
+	// The planned cluster is never ready, the real one isn't deleted.
+	_, err = newTestClusterService(g, c, mp, pool).ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	_, err = s.DeleteCluster()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(testCluster, nil)).To(BeTrue())
+
+	var planned []string
+	for _, op := range dryRun.Operations() {
+		planned = append(planned, op.String())
+	}
+	g.Expect(planned).To(Equal([]string{"insert " + testCluster, "delete " + testCluster}))
+}
+
+func TestReconcileClusterAutopilot(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	s := newTestClusterService(g, c)
+	s.scope.GCPManagedControlPlane.Spec.EnableAutopilot = true
+	s.scope.GCPManagedControlPlane.Spec.ReleaseChannel = func(c expinfrav1.ReleaseChannel) *expinfrav1.ReleaseChannel { return &c }(expinfrav1.StableReleaseChannel)
+	_, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	created := &container.Cluster{}
+	g.Expect(c.Get(testCluster, created)).To(BeTrue())
+	g.Expect(created.Autopilot.Enabled).To(BeTrue())
+	g.Expect(created.ReleaseChannel.Channel).To(Equal("STABLE"))
+	g.Expect(created.NodePools).To(BeEmpty())
+}
+
+func TestReconcileClusterIPAllocationPolicy(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	mp, pool := newTestMachinePool(1)
+	s := newTestClusterService(g, c, mp, pool)
+	s.scope.GCPManagedControlPlane.Spec.IPAllocationPolicy = &expinfrav1.IPAllocationPolicy{
+		ClusterSecondaryRangeName: "pods",
+		ServicesCidrBlock:         "/20",
+	}
+	_, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	created := &container.Cluster{}
+	g.Expect(c.Get(testCluster, created)).To(BeTrue())
+	g.Expect(created.IpAllocationPolicy).To(Equal(&container.IPAllocationPolicy{
+		UseIpAliases:              true,
+		ClusterSecondaryRangeName: "pods",
+		ServicesIpv4CidrBlock:     "/20",
+	}))
+}
+
+func TestReconcileClusterAutoscaling(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	mp, pool := newTestMachinePool(1)
+	s := newTestClusterService(g, c, mp, pool)
+	s.scope.GCPManagedControlPlane.Spec.ClusterAutoscaling = &expinfrav1.ClusterAutoscaling{
+		ResourceLimits: []expinfrav1.ResourceLimit{{ResourceType: "cpu", Maximum: 64}, {ResourceType: "memory", Maximum: 256}},
+	}
+	_, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	created := &container.Cluster{}
+	g.Expect(c.Get(testCluster, created)).To(BeTrue())
+	g.Expect(created.Autoscaling.EnableNodeAutoprovisioning).To(BeTrue())
+	g.Expect(created.Autoscaling.ResourceLimits).To(HaveLen(2))
+
+	// The cluster is created as desired, a second pass must be a no-op.
+	testEvents.Messages()
+	_, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(BeEmpty())
+
+	// The limits are raised.
+	s.scope.GCPManagedControlPlane.Spec.ClusterAutoscaling.ResourceLimits[0].Maximum = 128
+	_, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal UpdatedGKEClusterAutoscaling Updated node auto-provisioning of GKE cluster "my-cluster"`))
+	g.Expect(c.Get(testCluster, created)).To(BeTrue())
+	g.Expect(created.Autoscaling.ResourceLimits[0].Maximum).To(BeEquivalentTo(128))
+
+	// The node auto-provisioning is disabled once unset.
+	s.scope.GCPManagedControlPlane.Spec.ClusterAutoscaling = nil
+	_, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(testCluster, created)).To(BeTrue())
+	g.Expect(created.Autoscaling.EnableNodeAutoprovisioning).To(BeFalse())
+	_, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(HaveLen(1))
+}
+
+func TestDeleteCluster(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	mp, pool := newTestMachinePool(1)
+	s := newTestClusterService(g, c, mp, pool)
+	_, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	testEvents.Messages()
+
+	deleted, err := s.DeleteCluster()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeFalse())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal SuccessfulDelete Deleting GKE cluster "my-cluster"`))
+	g.Expect(c.Get(testCluster, nil)).To(BeFalse())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instanceGroupManagers/gke-my-cluster-my-pool-grp", nil)).To(BeFalse())
+
+	deleted, err = s.DeleteCluster()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeTrue())
+}
+
+func TestDeleteClusterRetain(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	mp, pool := newTestMachinePool(1)
+	s := newTestClusterService(g, c, mp, pool)
+	s.scope.GCPManagedCluster.Spec.AdditionalLabels = map[string]string{"team": "platform"}
+	_, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	testEvents.Messages()
+
+	// The retained cluster is only unlabelled, for another cluster to adopt it.
+	s.scope.GCPManagedControlPlane.Spec.DeletionPolicy = expinfrav1.DeletionPolicyRetain
+	deleted, err := s.DeleteCluster()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeFalse())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal RetainedResource Retained GKE cluster "my-cluster", no longer owned by the cluster`))
+
+	retained := &container.Cluster{}
+	g.Expect(c.Get(testCluster, retained)).To(BeTrue())
+	g.Expect(retained.ResourceLabels).To(Equal(map[string]string{"team": "platform"}))
+	g.Expect(c.Get(testCluster+"/nodePools/my-pool", nil)).To(BeTrue())
+
+	// Not owned anymore, it's left alone by a second pass.
+	deleted, err = s.DeleteCluster()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeTrue())
+	g.Expect(c.Get(testCluster, nil)).To(BeTrue())
+	g.Expect(testEvents.Messages()).To(BeEmpty())
+}
+
+func TestReconcileClusterNotOwned(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	// A cluster of the same name, e.g. created by another management cluster or namespace.
+	c.Put(testCluster, &container.Cluster{
+		Name:           "my-cluster",
+		Status:         "RUNNING",
+		ResourceLabels: map[string]string{"capg-cluster-my-cluster": "owned", "capg-namespace": "other", "team": "a"},
+	})
+	s := newTestClusterService(g, c)
+	testEvents.Messages()
+	_, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).To(MatchError(ContainSubstring("isn't owned by the cluster")))
+	g.Expect(conditions.GetReason(s.scope.GCPManagedControlPlane, expinfrav1.GKEControlPlaneReadyCondition)).To(Equal(expinfrav1.GKEControlPlaneNotOwnedReason))
+
+	// It isn't deleted with the cluster.
+	deleted, err := s.DeleteCluster()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeTrue())
+	g.Expect(c.Get(testCluster, nil)).To(BeTrue())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal RetainedResource Retained GKE cluster "my-cluster" which isn't owned by the cluster`))
+
+	// Once adopted, it's labelled as owned by the cluster and reconciled.
+	s.scope.GCPManagedControlPlane.Annotations = map[string]string{infrav1.AdoptAnnotation: ""}
+	gkeCluster, err := s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gkeCluster).To(BeNil())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal AdoptedResource Adopted pre-existing GKE cluster "my-cluster"`))
+	adopted := &container.Cluster{}
+	g.Expect(c.Get(testCluster, adopted)).To(BeTrue())
+	g.Expect(adopted.ResourceLabels).To(Equal(map[string]string{"capg-cluster-my-cluster": "owned", "capg-namespace": "default", "team": "a"}))
+
+	delete(s.scope.GCPManagedControlPlane.Annotations, infrav1.AdoptAnnotation)
+	gkeCluster, err = s.ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gkeCluster).NotTo(BeNil())
+	g.Expect(conditions.IsTrue(s.scope.GCPManagedControlPlane, expinfrav1.GKEControlPlaneReadyCondition)).To(BeTrue())
+}
+
+func TestReconcileNodePool(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	first, firstPool := newTestMachinePool(1)
+	_, err := newTestClusterService(g, c, first, firstPool).ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(testCluster+"/nodePools/my-pool", nil)).To(BeTrue())
+
+	// A second node pool is created on the running cluster.
+	mp, pool := newTestMachinePool(4)
+	mp.Name, pool.Name = "other-pool", "other-pool"
+	pool.Spec.KubernetesTaints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+	pool.Spec.Preemptible = true
+	pool.Spec.KubeletConfig = &expinfrav1.NodeKubeletConfig{CPUManagerPolicy: "static", CPUCFSQuota: pointer.BoolPtr(false)}
+	pool.Spec.LinuxSysctls = map[string]string{"net.core.somaxconn": "4096"}
+	s := newTestNodePoolService(g, c, mp, pool)
+	testEvents.Messages()
+	ready, err := s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeFalse())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal SuccessfulCreate Created GKE node pool "other-pool"`))
+
+	nodePool := &container.NodePool{}
+	g.Expect(c.Get(testCluster+"/nodePools/other-pool", nodePool)).To(BeTrue())
+	g.Expect(nodePool.Config.Taints).To(ConsistOf(&container.NodeTaint{Key: "dedicated", Value: "gpu", Effect: "NO_SCHEDULE"}))
+	g.Expect(nodePool.Config.Preemptible).To(BeTrue())
+	g.Expect(nodePool.Config.KubeletConfig.CpuManagerPolicy).To(Equal("static"))
+	g.Expect(nodePool.Config.KubeletConfig.CpuCfsQuota).To(BeFalse())
+	g.Expect(nodePool.Config.LinuxNodeConfig.Sysctls).To(HaveKeyWithValue("net.core.somaxconn", "4096"))
+
+	ready, err = s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(ready).To(BeTrue())
+	g.Expect(pool.Spec.ProviderIDList).To(HaveLen(6))
+	g.Expect(pool.Spec.ProviderIDList).To(ContainElement("gce://my-project/us-central1-a/gke-my-cluster-other-pool-0"))
+	g.Expect(pool.Status.Replicas).To(BeEquivalentTo(6))
+	g.Expect(testEvents.Messages()).To(BeEmpty())
+
+	// The node pool is resized per zone.
+	mp.Spec.Replicas = pointer.Int32Ptr(7)
+	_, err = s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal ResizingGKENodePool Resizing GKE node pool "other-pool" to 3 nodes per zone in 3 zones`))
+	_, err = s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pool.Status.Replicas).To(BeEquivalentTo(9))
+	g.Expect(testEvents.Messages()).To(BeEmpty())
+
+	// The node pool isn't upgraded before the control plane.
+	mp.Spec.Template.Spec.Version = pointer.StringPtr("v1.22.1")
+	_, err = s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(BeEmpty())
+	g.Expect(conditions.GetReason(pool, expinfrav1.GKEMachinePoolReadyCondition)).To(Equal(expinfrav1.WaitingForGKEControlPlaneReason))
+
+	s.scope.GCPManagedControlPlane.Status.CurrentVersion = "1.22.1-gke.100"
+	_, err = s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal UpgradingGKENodePool Upgrading GKE node pool "other-pool" from 1.21.5-gke.1302 to 1.22.1`))
+	g.Expect(c.Get(testCluster+"/nodePools/other-pool", nodePool)).To(BeTrue())
+	g.Expect(nodePool.Version).To(Equal("1.22.1"))
+
+	// The autoscaled node pool isn't resized.
+	pool.Spec.Scaling = &expinfrav1.NodePoolAutoscaling{MinCount: 1, MaxCount: 5}
+	mp.Spec.Replicas = pointer.Int32Ptr(1)
+	_, err = s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal UpdatedGKENodePoolAutoscaling Updated autoscaling of GKE node pool "other-pool"`))
+	_, err = s.ReconcileNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(testEvents.Messages()).To(BeEmpty())
+	g.Expect(pool.Status.Replicas).To(BeEquivalentTo(9))
+}
+
+func TestDeleteNodePool(t *testing.T) {
+	g := NewWithT(t)
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	mp, pool := newTestMachinePool(1)
+	_, err := newTestClusterService(g, c, mp, pool).ReconcileCluster(context.TODO())
+	g.Expect(err).NotTo(HaveOccurred())
+
+	s := newTestNodePoolService(g, c, mp, pool)
+	testEvents.Messages()
+	deleted, err := s.DeleteNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeFalse())
+	g.Expect(testEvents.Messages()).To(ConsistOf(`Normal SuccessfulDelete Deleting GKE node pool "my-pool"`))
+	g.Expect(c.Get(testCluster+"/nodePools/my-pool", nil)).To(BeFalse())
+
+	deleted, err = s.DeleteNodePool()
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(deleted).To(BeTrue())
+}
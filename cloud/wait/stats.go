@@ -0,0 +1,73 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wait
+
+import (
+	"sync"
+	"time"
+)
+
+// OperationStats are the statistics of the compute operations waited for in a project.
+type OperationStats struct {
+	// Pending is the number of operations being waited for.
+	Pending int `json:"pending"`
+	// Waited is the number of operations waited for until they completed or the wait timed out.
+	Waited int64 `json:"waited"`
+	// WaitSeconds is the cumulated time spent waiting for the operations.
+	WaitSeconds float64 `json:"waitSeconds"`
+}
+
+// operationStats are the statistics of the operations waited for by the process, per project.
+var operationStats = struct {
+	sync.Mutex
+	projects map[string]*OperationStats
+}{projects: map[string]*OperationStats{}}
+
+// Stats returns the statistics of the compute operations waited for, per project.
+func Stats() map[string]OperationStats {
+	operationStats.Lock()
+	defer operationStats.Unlock()
+
+	res := make(map[string]OperationStats, len(operationStats.projects))
+	for project, stats := range operationStats.projects {
+		res[project] = *stats
+	}
+
+	return res
+}
+
+// trackWait records the start of the wait for an operation of the project, and returns the function
+// recording its end.
+func trackWait(project string) func() {
+	start := time.Now()
+	operationStats.Lock()
+	defer operationStats.Unlock()
+	stats, ok := operationStats.projects[project]
+	if !ok {
+		stats = &OperationStats{}
+		operationStats.projects[project] = stats
+	}
+	stats.Pending++
+
+	return func() {
+		operationStats.Lock()
+		defer operationStats.Unlock()
+		stats.Pending--
+		stats.Waited++
+		stats.WaitSeconds += time.Since(start).Seconds()
+	}
+}
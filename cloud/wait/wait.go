@@ -22,39 +22,204 @@ import (
 	"context"
 	"fmt"
 	"path"
+	"strings"
 	"time"
 
 	"github.com/pkg/errors"
 	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/servicenetworking/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
 	"k8s.io/klog/v2"
 )
 
 const (
-	gceTimeout   = time.Minute * 10
-	gceWaitSleep = time.Second * 5
+	gceTimeout = time.Minute * 10
+
+	// minWaitSleep and maxWaitSleep bound the interval at which an operation in progress is polled.
+	minWaitSleep = time.Second * 2
+	maxWaitSleep = time.Second * 30
 )
 
 // ForComputeOperation wait when a compute operation is in progress.
 func ForComputeOperation(client *compute.Service, project string, op *compute.Operation) error {
+	_, err := forComputeOperation(client, project, op)
+	return err
+}
+
+// PollComputeOperation returns the error of the completed compute operation without waiting for it. A TimeoutError
+// is returned while it is in progress, with the delay after which it is expected to have progressed, so that the
+// caller polls it again later instead of blocking on it.
+func PollComputeOperation(op *compute.Operation) error {
+	if err := checkComputeOperation(op, nil); err != nil || op.Status == "DONE" {
+		return err
+	}
+
+	return &TimeoutError{
+		msg:        fmt.Sprintf("gce operation %v %q is in progress (%d%%)", op.OperationType, op.Name, op.Progress),
+		RetryAfter: pollInterval(op, time.Now()),
+	}
+}
+
+// PollComputeOperationLink returns the compute operation with the given full reference, and polls it
+// like PollComputeOperation.
+func PollComputeOperationLink(client *compute.Service, project, selfLink string) (*compute.Operation, error) {
+	op, err := getComputeOperation(client, project, operationFromLink(selfLink))
+	if err != nil {
+		return nil, err
+	}
+
+	return op, PollComputeOperation(op)
+}
+
+func forComputeOperation(client *compute.Service, project string, op *compute.Operation) (*compute.Operation, error) {
 	start := time.Now()
 	ctx, cf := context.WithTimeout(context.Background(), gceTimeout)
 	defer cf()
 
+	// The operations already completed aren't waited for.
+	if err := checkComputeOperation(op, nil); err != nil || op.Status == "DONE" {
+		return op, err
+	}
+	defer trackWait(project)()
+
 	var err error
 	for {
 		if err = checkComputeOperation(op, err); err != nil || op.Status == "DONE" {
-			return err
+			return op, err
 		}
 		klog.V(1).Infof("Wait for %v %q: %v (%d%%): %v", op.OperationType, op.Name, op.Status, op.Progress, op.StatusMessage)
+		sleep := pollInterval(op, time.Now())
 		select {
 		case <-ctx.Done():
-			return fmt.Errorf("gce operation %v %q timed out after %v", op.OperationType, op.Name, time.Since(start))
-		case <-time.After(gceWaitSleep):
+			return op, &TimeoutError{
+				msg:        fmt.Sprintf("gce operation %v %q timed out after %v", op.OperationType, op.Name, time.Since(start)),
+				RetryAfter: sleep,
+			}
+		case <-time.After(sleep):
 		}
 		op, err = getComputeOperation(client, project, op)
 	}
 }
 
+// ForServiceNetworkingOperation waits for the long-running operation of the service networking API,
+// which doesn't report its progress, e.g. the creation of a private service connection.
+func ForServiceNetworkingOperation(client *servicenetworking.APIService, op *servicenetworking.Operation) error {
+	start := time.Now()
+	ctx, cf := context.WithTimeout(context.Background(), gceTimeout)
+	defer cf()
+
+	var err error
+	for !op.Done {
+		klog.V(1).Infof("Wait for service networking operation %q", op.Name)
+		select {
+		case <-ctx.Done():
+			return &TimeoutError{
+				msg:        fmt.Sprintf("service networking operation %q timed out after %v", op.Name, time.Since(start)),
+				RetryAfter: maxWaitSleep,
+			}
+		case <-time.After(minWaitSleep):
+		}
+		if op, err = client.Operations.Get(op.Name).Do(); err != nil {
+			return err
+		}
+	}
+	if op.Error != nil {
+		return errors.Errorf("service networking operation %q failed: %s", op.Name, op.Error.Message)
+	}
+
+	return nil
+}
+
+// pollInterval returns the interval at which the operation in progress is polled, derived from its
+// expected remaining time: the time left at its current pace if it reports its progress, a quarter of
+// its elapsed time otherwise. The short operations, e.g. on firewall rules, are polled frequently,
+// and the slow ones, e.g. the updates of regional managed instance groups, rarely.
+func pollInterval(op *compute.Operation, now time.Time) time.Duration {
+	started, err := time.Parse(time.RFC3339, op.StartTime)
+	if err != nil {
+		started, err = time.Parse(time.RFC3339, op.InsertTime)
+	}
+	if err != nil {
+		return minWaitSleep
+	}
+	elapsed := now.Sub(started)
+
+	var interval time.Duration
+	if op.Progress > 0 && op.Progress < 100 {
+		interval = elapsed * time.Duration(100-op.Progress) / time.Duration(op.Progress)
+	} else {
+		interval = elapsed / 4
+	}
+	switch {
+	case interval < minWaitSleep:
+		return minWaitSleep
+	case interval > maxWaitSleep:
+		return maxWaitSleep
+	}
+
+	return interval
+}
+
+// TimeoutError is returned when a compute operation is still in progress after the wait timeout,
+// or when it is polled without waiting.
+type TimeoutError struct {
+	// RetryAfter is the delay after which the operation is expected to have progressed.
+	RetryAfter time.Duration
+
+	msg string
+}
+
+func (e *TimeoutError) Error() string {
+	return e.msg
+}
+
+// IsTimeout returns true if the error is a TimeoutError.
+func IsTimeout(err error) bool {
+	_, ok := errors.Cause(err).(*TimeoutError)
+	return ok
+}
+
+// RetryAfter returns the delay after which the operation timed out is expected to have progressed,
+// if the error is a TimeoutError.
+// The longest delay is returned for the errors aggregating several of them, e.g. from concurrent reconciles.
+func RetryAfter(err error) (time.Duration, bool) {
+	switch cause := errors.Cause(err).(type) {
+	case *TimeoutError:
+		return cause.RetryAfter, true
+	case kerrors.Aggregate:
+		var res time.Duration
+		found := false
+		for _, e := range cause.Errors() {
+			if retryAfter, ok := RetryAfter(e); ok {
+				found = true
+				if retryAfter > res {
+					res = retryAfter
+				}
+			}
+		}
+		return res, found
+	}
+
+	return 0, false
+}
+
+// operationFromLink returns the operation identified by the full reference,
+// e.g. https://www.googleapis.com/compute/v1/projects/my-project/zones/us-central1-a/operations/my-op.
+func operationFromLink(selfLink string) *compute.Operation {
+	op := &compute.Operation{Name: path.Base(selfLink)}
+	parts := strings.Split(strings.TrimSuffix(selfLink, "/operations/"+op.Name), "/")
+	if n := len(parts); n >= 2 {
+		switch parts[n-2] {
+		case "zones":
+			op.Zone = parts[n-1]
+		case "regions":
+			op.Region = parts[n-1]
+		}
+	}
+
+	return op
+}
+
 // getComputeOperation returns an updated operation.
 func getComputeOperation(client *compute.Service, project string, op *compute.Operation) (*compute.Operation, error) {
 	switch {
@@ -67,15 +232,43 @@ func getComputeOperation(client *compute.Service, project string, op *compute.Op
 	}
 }
 
+// OperationError is returned when a compute operation completed with errors.
+type OperationError struct {
+	// Codes are the error codes of the operation, e.g. ZONE_RESOURCE_POOL_EXHAUSTED.
+	Codes []string
+	msg   string
+}
+
+func (e *OperationError) Error() string {
+	return e.msg
+}
+
+// HasErrorCode returns true if the error is an OperationError with the given error code.
+func HasErrorCode(err error, code string) bool {
+	opErr, ok := errors.Cause(err).(*OperationError)
+	if !ok {
+		return false
+	}
+	for _, c := range opErr.Codes {
+		if c == code {
+			return true
+		}
+	}
+
+	return false
+}
+
 func checkComputeOperation(op *compute.Operation, err error) error {
 	if err != nil || op.Error == nil || len(op.Error.Errors) == 0 {
 		return err
 	}
 	var errs bytes.Buffer
+	codes := make([]string, 0, len(op.Error.Errors))
 	for _, v := range op.Error.Errors {
 		errs.WriteString(v.Message)
 		errs.WriteByte('\n')
+		codes = append(codes, v.Code)
 	}
 
-	return errors.New(errs.String())
+	return &OperationError{Codes: codes, msg: errs.String()}
 }
@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wait
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"google.golang.org/api/compute/v1"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+func TestPollInterval(t *testing.T) {
+	g := NewWithT(t)
+
+	// The times of the operations have a precision of a second.
+	now := time.Now().Truncate(time.Second)
+	started := func(ago time.Duration) string {
+		return now.Add(-ago).Format(time.RFC3339)
+	}
+
+	// The operations just started are polled frequently.
+	g.Expect(pollInterval(&compute.Operation{StartTime: started(time.Second)}, now)).To(Equal(minWaitSleep))
+	// The operations without a start time too.
+	g.Expect(pollInterval(&compute.Operation{}, now)).To(Equal(minWaitSleep))
+	// The operations without progress are polled at a quarter of their elapsed time.
+	g.Expect(pollInterval(&compute.Operation{InsertTime: started(time.Minute)}, now)).To(Equal(15 * time.Second))
+	// The operations reporting their progress are polled at their expected remaining time.
+	g.Expect(pollInterval(&compute.Operation{StartTime: started(20 * time.Second), Progress: 80}, now)).To(Equal(5 * time.Second))
+	// The slow operations are still polled regularly.
+	g.Expect(pollInterval(&compute.Operation{StartTime: started(10 * time.Minute), Progress: 10}, now)).To(Equal(maxWaitSleep))
+}
+
+func TestRetryAfter(t *testing.T) {
+	g := NewWithT(t)
+
+	_, ok := RetryAfter(errors.New("failed"))
+	g.Expect(ok).To(BeFalse())
+
+	retryAfter, ok := RetryAfter(errors.Wrap(&TimeoutError{RetryAfter: 10 * time.Second}, "failed to create firewall rule"))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(retryAfter).To(Equal(10 * time.Second))
+
+	retryAfter, ok = RetryAfter(kerrors.NewAggregate([]error{
+		errors.New("failed"),
+		errors.Wrap(&TimeoutError{RetryAfter: 10 * time.Second}, "failed to create firewall rule"),
+		errors.Wrap(&TimeoutError{RetryAfter: 20 * time.Second}, "failed to create health check"),
+	}))
+	g.Expect(ok).To(BeTrue())
+	g.Expect(retryAfter).To(Equal(20 * time.Second))
+}
+
+func TestPollComputeOperation(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(PollComputeOperation(&compute.Operation{Status: "DONE"})).To(Succeed())
+
+	err := PollComputeOperation(&compute.Operation{Status: "DONE", Error: &compute.OperationError{
+		Errors: []*compute.OperationErrorErrors{{Code: "QUOTA_EXCEEDED", Message: "Quota exceeded"}},
+	}})
+	g.Expect(HasErrorCode(err, "QUOTA_EXCEEDED")).To(BeTrue())
+
+	// The operation in progress isn't waited for.
+	err = PollComputeOperation(&compute.Operation{Name: "my-op", OperationType: "insert", Status: "RUNNING", Progress: 40})
+	g.Expect(IsTimeout(err)).To(BeTrue())
+	g.Expect(err.Error()).To(Equal(`gce operation insert "my-op" is in progress (40%)`))
+	retryAfter, ok := RetryAfter(err)
+	g.Expect(ok).To(BeTrue())
+	g.Expect(retryAfter).To(Equal(minWaitSleep))
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"strings"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+// isDryRun returns true if any of the objects has the dry-run annotation.
+func isDryRun(objs ...metav1.Object) bool {
+	for _, o := range objs {
+		if _, ok := o.GetAnnotations()[infrav1.DryRunAnnotation]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reportDryRun logs the GCP operations planned during a dry-run reconcile and records them as an event.
+func reportDryRun(log logr.Logger, obj runtime.Object, dryRun *cloud.DryRun) {
+	ops := dryRun.Operations()
+	if len(ops) == 0 {
+		log.Info("Dry-run: no GCP operations would be performed")
+		record.Event(obj, "DryRun", "No GCP operations would be performed")
+
+		return
+	}
+
+	planned := make([]string, 0, len(ops))
+	for _, op := range ops {
+		log.Info("Dry-run: GCP operation would be performed", "verb", op.Verb, "resource", op.Resource)
+		planned = append(planned, op.String())
+	}
+	record.Eventf(obj, "DryRun", "Would perform %d GCP operations: %s", len(ops), strings.Join(planned, ", "))
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+const (
+	// breakGlassSSHSecretSuffix is the suffix of the name of the Secrets holding the break-glass SSH keys.
+	breakGlassSSHSecretSuffix = "-break-glass-ssh"
+
+	// breakGlassSSHPublicKey is the key of the Secrets data holding the public key.
+	breakGlassSSHPublicKey = "ssh-publickey"
+
+	// sshKeyType is the type of the break-glass SSH keys, supported by all the OpenSSH versions of the images.
+	sshKeyType = "ecdsa-sha2-nistp256"
+)
+
+// reconcileBreakGlassSSH generates the break-glass SSH key pair of the GCPCluster, stores it in a Secret owned
+// by the GCPCluster and publishes its public key in the status. The Secret is deleted once the key pair is
+// disabled, the instances it was added to keep authorizing it until they are replaced.
+func (r *GCPClusterReconciler) reconcileBreakGlassSSH(ctx context.Context, clusterScope *scope.ClusterScope) error {
+	gcpCluster := clusterScope.GCPCluster
+	key := types.NamespacedName{Namespace: gcpCluster.Namespace, Name: gcpCluster.Name + breakGlassSSHSecretSuffix}
+
+	if gcpCluster.Spec.BreakGlassSSH == nil {
+		if gcpCluster.Status.BreakGlassSSH == nil || clusterScope.DryRun() != nil {
+			return nil
+		}
+		secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+		if err := r.Client.Delete(ctx, secret); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete break-glass SSH key Secret %s", key)
+		}
+		record.Eventf(gcpCluster, "BreakGlassSSHKeyDeleted", "Deleted the break-glass SSH key %s of Secret %s",
+			gcpCluster.Status.BreakGlassSSH.Fingerprint, key.Name)
+		gcpCluster.Status.BreakGlassSSH = nil
+
+		return nil
+	}
+
+	secret := &corev1.Secret{}
+	created := false
+	err := r.Client.Get(ctx, key, secret)
+	switch {
+	case apierrors.IsNotFound(err):
+		if clusterScope.DryRun() != nil {
+			return nil
+		}
+		if secret, err = newBreakGlassSSHSecret(key, gcpCluster); err != nil {
+			return err
+		}
+		if err := r.Client.Create(ctx, secret); err != nil {
+			return errors.Wrapf(err, "failed to create break-glass SSH key Secret %s", key)
+		}
+		created = true
+	case err != nil:
+		return errors.Wrapf(err, "failed to get break-glass SSH key Secret %s", key)
+	}
+
+	publicKey := strings.TrimSpace(string(secret.Data[breakGlassSSHPublicKey]))
+	fingerprint, err := sshFingerprint(publicKey)
+	if err != nil {
+		return errors.Wrapf(err, "invalid public key in break-glass SSH key Secret %s", key)
+	}
+	if created {
+		record.Eventf(gcpCluster, "BreakGlassSSHKeyCreated", "Created the break-glass SSH key %s of user %s in Secret %s",
+			fingerprint, clusterScope.BreakGlassSSHUser(), key.Name)
+	}
+	gcpCluster.Status.BreakGlassSSH = &infrav1.BreakGlassSSHStatus{
+		SecretName:  key.Name,
+		PublicKey:   publicKey,
+		Fingerprint: fingerprint,
+	}
+
+	return nil
+}
+
+// newBreakGlassSSHSecret generates a key pair and returns the SSH auth Secret holding it, owned by the GCPCluster.
+func newBreakGlassSSHSecret(key types.NamespacedName, gcpCluster *infrav1.GCPCluster) (*corev1.Secret, error) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate break-glass SSH key")
+	}
+	der, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode break-glass SSH key")
+	}
+
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: key.Namespace,
+			Name:      key.Name,
+			Labels:    map[string]string{clusterv1.ClusterLabelName: gcpCluster.Labels[clusterv1.ClusterLabelName]},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(gcpCluster, infrav1.GroupVersion.WithKind("GCPCluster")),
+			},
+		},
+		Type: corev1.SecretTypeSSHAuth,
+		Data: map[string][]byte{
+			corev1.SSHAuthPrivateKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}),
+			breakGlassSSHPublicKey:   []byte(sshKeyType + " " + base64.StdEncoding.EncodeToString(sshPublicKey(&privateKey.PublicKey))),
+		},
+	}, nil
+}
+
+// sshPublicKey returns the public key in the SSH wire format, see RFC 5656.
+func sshPublicKey(key *ecdsa.PublicKey) []byte {
+	var res []byte
+	for _, field := range [][]byte{
+		[]byte(sshKeyType),
+		[]byte("nistp256"),
+		elliptic.Marshal(key.Curve, key.X, key.Y),
+	} {
+		length := make([]byte, 4)
+		binary.BigEndian.PutUint32(length, uint32(len(field)))
+		res = append(append(res, length...), field...)
+	}
+
+	return res
+}
+
+// sshFingerprint returns the SHA256 fingerprint of the public key in the authorized_keys format.
+func sshFingerprint(publicKey string) (string, error) {
+	fields := strings.Fields(publicKey)
+	if len(fields) < 2 {
+		return "", errors.New("expected a key type and a base64 encoded key")
+	}
+	wire, err := base64.StdEncoding.DecodeString(fields[1])
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decode key")
+	}
+	sum := sha256.Sum256(wire)
+
+	return "SHA256:" + base64.RawStdEncoding.EncodeToString(sum[:]), nil
+}
@@ -18,16 +18,23 @@ package controllers
 
 import (
 	"context"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
+	"sigs.k8s.io/cluster-api/util/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
@@ -37,17 +44,70 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/compute"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/metrics"
 	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
 )
 
+// natIPsRefreshInterval is the interval at which the NAT IPs are looked up until the first ones are allocated.
+const natIPsRefreshInterval = time.Minute
+
+// preflightRetryInterval is the interval at which the failed preflight checks are run again, e.g. once an API is
+// enabled or a quota increased.
+const preflightRetryInterval = 5 * time.Minute
+
 // GCPClusterReconciler reconciles a GCPCluster object.
 type GCPClusterReconciler struct {
 	client.Client
 	Log              logr.Logger
 	ReconcileTimeout time.Duration
 	WatchFilterValue string
+
+	ReconcilerOptions
+
+	// Cache caches the GCP lookups across the reconciles, nothing is cached if nil.
+	Cache *cloud.LookupCache
+
+	// GoogleAccess is the way the GCP APIs are reached, defaults to the public access.
+	GoogleAccess cloud.GoogleAccess
+
+	// ZoneIncidents are the recent incidents in the zones, their failure domains are ineligible for the control plane.
+	ZoneIncidents *cloud.ZoneIncidents
+
+	// FailureDomainRefreshInterval, if set, is the interval at which the ready clusters are requeued
+	// to refresh their failure domains. The zones of the regions are then cached for this interval.
+	FailureDomainRefreshInterval time.Duration
+
+	// ResyncInterval, if set, is the interval at which the ready clusters are requeued to correct the
+	// drift of their GCP resources, instead of on the resync of the manager only.
+	ResyncInterval time.Duration
+
+	// PreflightChecks, if true, checks that the APIs the resources of the clusters are managed with are enabled,
+	// and that the quotas of their project aren't exhausted, before they're created.
+	PreflightChecks bool
+
+	// Metrics, if set, publishes the health of the clusters to Cloud Monitoring after each reconcile.
+	Metrics cloud.MetricsExporter
+
+	// LookupHost resolves the DNS names set as control plane endpoints, defaults to the system resolver.
+	LookupHost func(ctx context.Context, host string) ([]string, error)
+
+	// StatusFieldManager, if set, is the field manager applying the status of the GCPClusters with a
+	// server-side apply, instead of patching it with their spec.
+	StatusFieldManager string
+
+	// IdleInterval, if set, is the interval within which the ready clusters are not reconciled again
+	// after a complete reconcile, unless their spec, the spec of their Cluster or their control plane
+	// GCPMachines changed.
+	IdleInterval time.Duration
+
+	// Now returns the current time, defaults to time.Now.
+	Now func() time.Time
+
+	// synced are the states of the GCPClusters at their last complete reconcile, by their key.
+	synced sync.Map
 }
 
 func (r *GCPClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
@@ -99,6 +159,8 @@ func (r *GCPClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpclusters,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpclusters/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;delete
 
 func (r *GCPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
 	ctx, cancel := context.WithTimeout(ctx, reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
@@ -110,6 +172,7 @@ func (r *GCPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 	err := r.Get(ctx, req.NamespacedName, gcpCluster)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			r.synced.Delete(req.NamespacedName)
 			return ctrl.Result{}, nil
 		}
 
@@ -126,6 +189,11 @@ func (r *GCPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
+	// The cluster is reconciled by another replica.
+	if !r.Shard.Owns(cluster.Namespace, cluster.Name) {
+		return ctrl.Result{}, nil
+	}
+
 	if annotations.IsPaused(cluster, gcpCluster) {
 		log.Info("GCPCluster of linked Cluster is marked as paused. Won't reconcile")
 		return ctrl.Result{}, nil
@@ -133,31 +201,125 @@ func (r *GCPClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	log = log.WithValues("cluster", cluster.Name)
 
+	var dryRun *cloud.DryRun
+	var observed *clusterSync
+	if r.DryRun || isDryRun(gcpCluster) {
+		dryRun = &cloud.DryRun{}
+		defer reportDryRun(log, gcpCluster, dryRun)
+	} else if observed, err = r.observe(ctx, gcpCluster, cluster); err != nil {
+		return ctrl.Result{}, err
+	} else if left, ok := r.idle(gcpCluster, observed); ok {
+		log.V(4).Info("GCPCluster is idle, skipping the reconcile", "left", left)
+		return ctrl.Result{RequeueAfter: left}, nil
+	}
+
 	// Create the scope.
 	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		Cloud:      r.Cloud,
+		Cache:      r.Cache,
+		Stats:      r.Stats,
+		Audit:      r.Audit,
+		DryRun:     dryRun,
 		Client:     r.Client,
 		Logger:     log,
 		Cluster:    cluster,
 		GCPCluster: gcpCluster,
+
+		FailureDomainRefreshInterval: r.FailureDomainRefreshInterval,
+		StatusFieldManager:           r.StatusFieldManager,
+		FaultInjection:               r.FaultInjection,
+		RateLimiter:                  r.RateLimiter,
+		GoogleAccess:                 r.GoogleAccess,
+		Now:                          r.Now,
 	})
 	if err != nil {
 		return ctrl.Result{}, errors.Errorf("failed to create scope: %+v", err)
 	}
 
 	// Always close the scope when exiting this function so we can persist any GCPMachine changes.
+	// The GCPCluster is only marked as synced once its status is persisted.
+	synced := false
 	defer func() {
 		if err := clusterScope.Close(); err != nil && reterr == nil {
 			reterr = err
 		}
+		if synced && reterr == nil {
+			r.markSynced(gcpCluster, observed)
+		}
 	}()
 
 	// Handle deleted clusters
 	if !gcpCluster.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(clusterScope)
+		res, err := r.reconcileDelete(clusterScope)
+		return requeueOnOperationTimeout(log, res, err, r.RequeueJitter)
 	}
 
 	// Handle non-deleted clusters
-	return r.reconcile(clusterScope)
+	defer r.exportMetrics(ctx, clusterScope)
+	if dryRun == nil {
+		defer func() {
+			metrics.SetOwnedResources(gcpCluster.Namespace, gcpCluster.Name, gcpCluster.Status.OwnedResources)
+		}()
+	}
+
+	res, err := r.reconcile(clusterScope)
+	synced = err == nil && gcpCluster.Status.Ready && dryRun == nil
+
+	return requeueOnOperationTimeout(log, res, err, r.RequeueJitter)
+}
+
+// exportMetrics publishes whether the cluster is ready, the number of its provisioned and failed machines,
+// and of the healthy API server backends of its load balancer. The failures are only logged.
+func (r *GCPClusterReconciler) exportMetrics(ctx context.Context, clusterScope *scope.ClusterScope) {
+	if r.Metrics == nil || clusterScope.DryRun() != nil {
+		return
+	}
+
+	gcpCluster := clusterScope.GCPCluster
+	labels := map[string]string{"cluster": gcpCluster.Name, "namespace": gcpCluster.Namespace}
+	points := []cloud.MetricPoint{
+		{Type: "cluster/ready", Labels: labels, Value: boolToInt64(gcpCluster.Status.Ready)},
+	}
+
+	gcpMachines := &infrav1.GCPMachineList{}
+	if err := r.List(ctx, gcpMachines, client.InNamespace(gcpCluster.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: clusterScope.Name()}); err != nil {
+		clusterScope.Error(err, "Failed to list the GCPMachines of the cluster for the metrics")
+	} else {
+		var provisioned, failed int64
+		for _, m := range gcpMachines.Items {
+			if m.Status.Ready {
+				provisioned++
+			}
+			if m.Status.FailureReason != nil {
+				failed++
+			}
+		}
+		points = append(points,
+			cloud.MetricPoint{Type: "cluster/machines_provisioned", Labels: labels, Value: provisioned},
+			cloud.MetricPoint{Type: "cluster/machines_failed", Labels: labels, Value: failed},
+		)
+	}
+
+	if healthy, total, err := compute.NewService(clusterScope).GetAPIServerBackendsHealth(); err != nil {
+		clusterScope.Error(err, "Failed to get the health of the load balancer for the metrics")
+	} else {
+		points = append(points,
+			cloud.MetricPoint{Type: "cluster/apiserver_backends_healthy", Labels: labels, Value: int64(healthy)},
+			cloud.MetricPoint{Type: "cluster/apiserver_backends", Labels: labels, Value: int64(total)},
+		)
+	}
+
+	if err := r.Metrics.Export(ctx, clusterScope.Project(), points); err != nil {
+		clusterScope.Error(err, "Failed to export the metrics of the cluster")
+	}
+}
+
+func boolToInt64(b bool) int64 {
+	if b {
+		return 1
+	}
+
+	return 0
 }
 
 func (r *GCPClusterReconciler) reconcile(clusterScope *scope.ClusterScope) (ctrl.Result, error) {
@@ -165,101 +327,357 @@ func (r *GCPClusterReconciler) reconcile(clusterScope *scope.ClusterScope) (ctrl
 
 	gcpCluster := clusterScope.GCPCluster
 
-	// If the GCPCluster doesn't have our finalizer, add it.
+	// If the GCPCluster doesn't have our finalizers, add them.
 	controllerutil.AddFinalizer(gcpCluster, infrav1.ClusterFinalizer)
+	controllerutil.AddFinalizer(gcpCluster, infrav1.LoadBalancerFinalizer)
+	controllerutil.AddFinalizer(gcpCluster, infrav1.FirewallFinalizer)
+	controllerutil.AddFinalizer(gcpCluster, infrav1.NetworkFinalizer)
 	// Register the finalizer immediately to avoid orphaning AWS resources on delete
 	if err := clusterScope.PatchObject(); err != nil {
 		return ctrl.Result{}, err
 	}
 
+	clusterScope.SetFeaturesAvailable()
+
 	computeSvc := compute.NewService(clusterScope)
 
-	if err := computeSvc.ReconcileNetwork(); err != nil {
+	if r.PreflightChecks && !gcpCluster.Status.Ready && !conditions.IsTrue(gcpCluster, infrav1.PreflightChecksPassedCondition) {
+		failures, err := computeSvc.CheckPreflight()
+		if err != nil {
+			conditions.MarkFalse(gcpCluster, infrav1.PreflightChecksPassedCondition, infrav1.PreflightCheckFailedReason, clusterv1.ConditionSeverityWarning, "%v", err)
+
+			return ctrl.Result{}, errors.Wrapf(err, "failed to run preflight checks for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+		}
+		if len(failures) > 0 {
+			message := strings.Join(failures, "; ")
+			conditions.MarkFalse(gcpCluster, infrav1.PreflightChecksPassedCondition, infrav1.PreflightCheckFailedReason, clusterv1.ConditionSeverityError, "%s", message)
+			record.Warnf(gcpCluster, infrav1.PreflightCheckFailedReason, "Preflight checks failed: %s", message)
+
+			return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(preflightRetryInterval, r.RequeueJitter)}, nil
+		}
+		conditions.MarkTrue(gcpCluster, infrav1.PreflightChecksPassedCondition)
+	}
+
+	if err := metrics.ObserveReconcile(gcpCluster.Namespace, gcpCluster.Name, metrics.SubsystemNetwork, computeSvc.ReconcileNetwork); err != nil {
+		conditions.MarkFalse(gcpCluster, infrav1.NetworkReadyCondition, infrav1.NetworkReconciliationFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+
 		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile network for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
+	conditions.MarkTrue(gcpCluster, infrav1.NetworkReadyCondition)
+
+	// The firewall rules don't depend on the load balancer, reconcile them concurrently.
+	// Their conditions are set once both are reconciled, the GCPCluster not being safe for concurrent updates.
+	var firewallsErr, loadBalancerErr error
+	err := reconciler.RunParallel(reconciler.DefaultParallelism,
+		func() error {
+			if firewallsErr = metrics.ObserveReconcile(gcpCluster.Namespace, gcpCluster.Name, metrics.SubsystemFirewall, computeSvc.ReconcileFirewalls); firewallsErr != nil {
+				return errors.Wrapf(firewallsErr, "failed to reconcile firewalls for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
 
-	if err := computeSvc.ReconcileFirewalls(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile firewalls for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			return nil
+		},
+		func() error {
+			return metrics.ObserveReconcile(gcpCluster.Namespace, gcpCluster.Name, metrics.SubsystemLoadBalancer, func() error {
+				if loadBalancerErr = computeSvc.ReconcileBackendGroups(); loadBalancerErr != nil {
+					return errors.Wrapf(loadBalancerErr, "failed to reconcile backend groups for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+				}
+
+				if loadBalancerErr = computeSvc.ReconcileLoadbalancers(); loadBalancerErr != nil {
+					return errors.Wrapf(loadBalancerErr, "failed to reconcile load balancers for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+				}
+
+				return nil
+			})
+		},
+	)
+	if firewallsErr != nil {
+		conditions.MarkFalse(gcpCluster, infrav1.FirewallsReadyCondition, infrav1.FirewallsReconciliationFailedReason, clusterv1.ConditionSeverityError, "%v", firewallsErr)
+	} else {
+		conditions.MarkTrue(gcpCluster, infrav1.FirewallsReadyCondition)
+	}
+	if loadBalancerErr != nil {
+		conditions.MarkFalse(gcpCluster, infrav1.LoadBalancerReadyCondition, infrav1.LoadBalancerReconciliationFailedReason, clusterv1.ConditionSeverityError, "%v", loadBalancerErr)
+	}
+	if err != nil {
+
+		return ctrl.Result{}, err
+	}
+
+	if err := computeSvc.ReconcileBastion(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile bastion for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	}
+
+	if err := computeSvc.ReconcileReservations(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile reservations for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
 
-	if err := computeSvc.ReconcileInstanceGroups(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile instance groups for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	if err := computeSvc.ReconcileSoleTenantNodeGroups(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile sole-tenant node groups for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
 
-	if err := computeSvc.ReconcileLoadbalancers(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile load balancers for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	if err := r.reconcileBreakGlassSSH(context.TODO(), clusterScope); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile break-glass SSH key for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
 
-	if gcpCluster.Status.Network.APIServerAddress == nil {
+	if err := r.reconcileExport(context.TODO(), clusterScope); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to export GCP resources of GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	}
+
+	// All the resources are reconciled, the operations left in the status completed in the meantime.
+	gcpCluster.Status.Operations = nil
+
+	// The disruptive changes deferred by the reconcile are applied once the maintenance window opens.
+	untilMaintenanceWindow := clusterScope.SetDisruptiveChangesApplied()
+
+	if gcpCluster.Status.Network.APIServerAddress == nil && clusterScope.LoadBalancerType() != infrav1.LoadBalancerTypeNone {
 		clusterScope.Info("Waiting on API server Global IP Address")
+		conditions.MarkFalse(gcpCluster, infrav1.LoadBalancerReadyCondition, infrav1.WaitingForAPIServerAddressReason, clusterv1.ConditionSeverityInfo, "")
 
-		return ctrl.Result{RequeueAfter: 15 * time.Second}, nil
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(15*time.Second, r.RequeueJitter)}, nil
 	}
 
-	// Set APIEndpoints so the Cluster API Cluster Controller can pull them
-	gcpCluster.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{
-		Host: *gcpCluster.Status.Network.APIServerAddress,
-		Port: 443,
+	conditions.MarkTrue(gcpCluster, infrav1.LoadBalancerReadyCondition)
+
+	if err := computeSvc.ReconcileControlPlaneDNS(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile control plane dns record for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	}
+
+	// Set APIEndpoints so the Cluster API Cluster Controller can pull them, unless set to a DNS name.
+	// The name of the control plane record is managed with the load balancer, and the endpoint of a cluster
+	// without load balancer is managed outside of the provider, they need no check.
+	switch {
+	case gcpCluster.Spec.ControlPlaneEndpoint.Host == "" && gcpCluster.Spec.ControlPlaneDNS != nil:
+		gcpCluster.Spec.ControlPlaneEndpoint.Host = strings.TrimSuffix(gcpCluster.Spec.ControlPlaneDNS.Name, ".")
+	case gcpCluster.Spec.ControlPlaneEndpoint.Host == "":
+		gcpCluster.Spec.ControlPlaneEndpoint.Host = *gcpCluster.Status.Network.APIServerAddress
+	case gcpCluster.Spec.ControlPlaneDNS == nil && clusterScope.LoadBalancerType() != infrav1.LoadBalancerTypeNone:
+		r.checkControlPlaneEndpoint(clusterScope)
+	}
+	if gcpCluster.Spec.ControlPlaneEndpoint.Port == 0 {
+		gcpCluster.Spec.ControlPlaneEndpoint.Port = 443
+		// A TargetInstance or an Internal load balancer forwards the traffic to the API server port as is, and
+		// the endpoint managed outside of the provider defaults to it.
+		if clusterScope.LoadBalancerType() != infrav1.LoadBalancerTypeProxy || clusterScope.LoadBalancerScheme() == infrav1.LoadBalancerSchemeInternal {
+			gcpCluster.Spec.ControlPlaneEndpoint.Port = int32(clusterScope.LoadBalancerBackendPort())
+		}
 	}
 
 	// Set FailureDomains on the GCPCluster Status
-	zones, err := computeSvc.GetZones()
+	zones, err := computeSvc.GetControlPlaneZones()
 	if err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "failed to get available zones for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
 
-	// FailureDomains list should be empty by default.
-	gcpCluster.Status.FailureDomains = make(clusterv1.FailureDomains, len(zones))
-
-	// Iterate through all zones
-	for _, zone := range zones {
-		// If we have failuredomains in spec, see if this zone is in valid zone
-		// Add to the status _only_ if it's mentioned in the gcpCluster spec
-		if len(gcpCluster.Spec.FailureDomains) > 0 {
-			for _, fd := range gcpCluster.Spec.FailureDomains {
-				if fd == zone {
-					gcpCluster.Status.FailureDomains[zone] = clusterv1.FailureDomainSpec{
-						ControlPlane: true,
-					}
-				}
-			}
-		} else {
-			gcpCluster.Status.FailureDomains[zone] = clusterv1.FailureDomainSpec{
-				ControlPlane: true,
-			}
-		}
+	unavailableZones, err := computeSvc.GetUnavailableZones()
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get unavailable zones for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
 
+	previous := gcpCluster.Status.FailureDomains
+	gcpCluster.Status.FailureDomains = failureDomains(zones, gcpCluster.Spec.FailureDomains, gcpCluster.Spec.ExcludedFailureDomains)
+	ineligible := r.markIneligibleFailureDomains(clusterScope, previous, unavailableZones)
+
 	// No errors, so mark us ready so the Cluster API Cluster Controller can pull it
 	gcpCluster.Status.Ready = true
+	gcpCluster.Status.ObservedGeneration = gcpCluster.Generation
+
+	// Refresh the failure domains periodically if asked to, instead of on resync only,
+	// and while some are ineligible to restore them once the incidents are over.
+	var requeueAfter time.Duration
+	switch {
+	case r.FailureDomainRefreshInterval > 0:
+		requeueAfter = r.FailureDomainRefreshInterval
+	case ineligible:
+		requeueAfter = 5 * time.Minute
+	}
+	// Refresh the NAT IPs until the first ones are allocated, once the first nodes egress.
+	if gcpCluster.Status.Network.RouterNat != nil && len(gcpCluster.Status.Network.NATIPs) == 0 &&
+		(requeueAfter == 0 || natIPsRefreshInterval < requeueAfter) {
+		requeueAfter = natIPsRefreshInterval
+	}
+	if untilMaintenanceWindow > 0 && (requeueAfter == 0 || untilMaintenanceWindow < requeueAfter) {
+		requeueAfter = untilMaintenanceWindow
+	}
+	// Correct the drift of the GCP resources at the resync interval if it's shorter.
+	if r.ResyncInterval > 0 && (requeueAfter == 0 || r.ResyncInterval < requeueAfter) {
+		requeueAfter = r.ResyncInterval
+	}
+	if requeueAfter > 0 {
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(requeueAfter, r.RequeueJitter)}, nil
+	}
 
 	return ctrl.Result{}, nil
 }
 
+// checkControlPlaneEndpoint warns when the control plane endpoint set by the user doesn't point to the
+// API server load balancer. The cluster is still ready, the DNS records are usually created from the
+// load balancer address once known, and may take time to propagate.
+func (r *GCPClusterReconciler) checkControlPlaneEndpoint(clusterScope *scope.ClusterScope) {
+	gcpCluster := clusterScope.GCPCluster
+	host, address := gcpCluster.Spec.ControlPlaneEndpoint.Host, *gcpCluster.Status.Network.APIServerAddress
+	if host == address {
+		return
+	}
+	if net.ParseIP(host) != nil {
+		record.Warnf(gcpCluster, "ControlPlaneEndpointMismatch", "Control plane endpoint %s is not the API server load balancer address %s", host, address)
+		return
+	}
+
+	lookupHost := r.LookupHost
+	if lookupHost == nil {
+		lookupHost = net.DefaultResolver.LookupHost
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	addresses, err := lookupHost(ctx, host)
+	if err != nil {
+		record.Warnf(gcpCluster, "ControlPlaneEndpointUnresolved", "Failed to resolve control plane endpoint %s, it should resolve to the API server load balancer address %s: %v", host, address, err)
+		return
+	}
+	for _, a := range addresses {
+		if a == address {
+			return
+		}
+	}
+	record.Warnf(gcpCluster, "ControlPlaneEndpointMismatch", "Control plane endpoint %s resolves to %v instead of the API server load balancer address %s", host, addresses, address)
+}
+
+// markIneligibleFailureDomains makes the failure domains of the zones which are unavailable, or had a recent incident,
+// ineligible for the control plane so that no control plane machine is created there. It returns true if any is ineligible.
+func (r *GCPClusterReconciler) markIneligibleFailureDomains(clusterScope *scope.ClusterScope, previous clusterv1.FailureDomains, unavailableZones map[string]string) bool {
+	ineligible := false
+	for zone, fd := range clusterScope.GCPCluster.Status.FailureDomains {
+		reason, ok := unavailableZones[zone]
+		if !ok {
+			reason, ok = r.ZoneIncidents.Recent(clusterScope.Project(), zone)
+		}
+		if !ok {
+			continue
+		}
+
+		ineligible = true
+		fd.ControlPlane = false
+		fd.Attributes = map[string]string{infrav1.IneligibleReasonAttribute: reason}
+		clusterScope.GCPCluster.Status.FailureDomains[zone] = fd
+		if prev, ok := previous[zone]; !ok || prev.ControlPlane {
+			record.Warnf(clusterScope.GCPCluster, "FailureDomainIneligible", "Failure domain %q is ineligible for the control plane: %s", zone, reason)
+		}
+	}
+
+	return ineligible
+}
+
+// failureDomains returns the failure domains of the zones which are allowed, all if the allow list is empty,
+// and not excluded.
+func failureDomains(zones, allowed, excluded []string) clusterv1.FailureDomains {
+	allowedSet := sets.NewString(allowed...)
+	excludedSet := sets.NewString(excluded...)
+
+	res := make(clusterv1.FailureDomains, len(zones))
+	for _, zone := range zones {
+		if (allowedSet.Len() > 0 && !allowedSet.Has(zone)) || excludedSet.Has(zone) {
+			continue
+		}
+		res[zone] = clusterv1.FailureDomainSpec{
+			ControlPlane: true,
+		}
+	}
+
+	return res
+}
+
 func (r *GCPClusterReconciler) reconcileDelete(clusterScope *scope.ClusterScope) (ctrl.Result, error) {
 	clusterScope.Info("Reconciling GCPCluster delete")
 
 	computeSvc := compute.NewService(clusterScope)
 	gcpCluster := clusterScope.GCPCluster
 
-	if err := computeSvc.DeleteLoadbalancers(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "error deleting load balancer for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	// The webhook rejects the deletion of a protected GCPCluster, which may have been annotated since.
+	if _, ok := gcpCluster.Annotations[infrav1.DeletionProtectionAnnotation]; ok {
+		conditions.MarkFalse(gcpCluster, infrav1.NetworkReadyCondition, infrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+			"Remove the %s annotation to delete the GCP resources of the cluster", infrav1.DeletionProtectionAnnotation)
+		clusterScope.Info("GCPCluster is protected from deletion")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)}, nil
 	}
 
-	if err := computeSvc.DeleteInstanceGroups(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "error deleting instance groups for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	// The finalizers of the subsystems are removed as they are deleted, the GCPCluster being persisted
+	// on close even if the deletion fails, so that the blocking subsystem shows in the finalizers left.
+
+	// Block clusterctl move while the resources are being deleted, and report the infrastructure as not ready anymore.
+	if _, ok := gcpCluster.Annotations[infrav1.BlockMoveAnnotation]; !ok {
+		metav1.SetMetaDataAnnotation(&gcpCluster.ObjectMeta, infrav1.BlockMoveAnnotation, "")
+		gcpCluster.Status.Ready = false
+		if err := clusterScope.PatchObject(); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// The compute capacity, the load balancer and the firewall rules don't depend on each other, they are
+	// deleted concurrently, the finalizers of the subsystems deleted being removed even if another failed.
+	var loadBalancerDeleted, firewallsDeleted bool
+	err := reconciler.RunParallel(reconciler.DefaultParallelism,
+		func() error {
+			if err := computeSvc.DeleteBastion(); err != nil {
+				return errors.Wrapf(err, "error deleting bastion for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
+
+			if err := computeSvc.DeleteReservations(); err != nil {
+				return errors.Wrapf(err, "error deleting reservations for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
+
+			if err := computeSvc.DeleteSoleTenantNodeGroups(); err != nil {
+				return errors.Wrapf(err, "error deleting sole-tenant node groups for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
+
+			return nil
+		},
+		func() error {
+			if err := computeSvc.DeleteControlPlaneDNS(); err != nil {
+				return errors.Wrapf(err, "error deleting control plane dns record for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
+
+			if err := computeSvc.DeleteLoadbalancers(); err != nil {
+				return errors.Wrapf(err, "error deleting load balancer for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
+
+			if err := computeSvc.DeleteBackendGroups(); err != nil {
+				return errors.Wrapf(err, "error deleting backend groups for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
+			loadBalancerDeleted = true
+
+			return nil
+		},
+		func() error {
+			if err := computeSvc.DeleteFirewalls(); err != nil {
+				return errors.Wrapf(err, "error deleting firewall rules for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+			}
+			firewallsDeleted = true
+
+			return nil
+		},
+	)
+	if loadBalancerDeleted {
+		controllerutil.RemoveFinalizer(gcpCluster, infrav1.LoadBalancerFinalizer)
+	}
+	if firewallsDeleted {
+		controllerutil.RemoveFinalizer(gcpCluster, infrav1.FirewallFinalizer)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
 	}
 
-	if err := computeSvc.DeleteFirewalls(); err != nil {
-		return ctrl.Result{}, errors.Wrapf(err, "error deleting firewall rules for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
+	if err := computeSvc.DeleteOrphanedResources(); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "error deleting orphaned resources for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
 
 	if err := computeSvc.DeleteNetwork(); err != nil {
 		return ctrl.Result{}, errors.Wrapf(err, "error deleting network for GCPCluster %s/%s", gcpCluster.Namespace, gcpCluster.Name)
 	}
+	controllerutil.RemoveFinalizer(gcpCluster, infrav1.NetworkFinalizer)
 
 	// Cluster is deleted so remove the finalizer.
 	controllerutil.RemoveFinalizer(clusterScope.GCPCluster, infrav1.ClusterFinalizer)
+	metrics.DeleteCluster(gcpCluster.Namespace, gcpCluster.Name)
 
 	return ctrl.Result{}, nil
 }
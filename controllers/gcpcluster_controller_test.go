@@ -24,6 +24,9 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
@@ -62,4 +65,52 @@ var _ = Describe("GCPClusterReconciler", func() {
 			Expect(result.Requeue).To(BeFalse())
 		})
 	})
+
+	Context("Apply the status of a GCPCluster", func() {
+		It("should remove the failure domains dropped from the status patched before", func() {
+			ctx := context.Background()
+
+			c := fakecloud.NewCloud()
+			defer c.Close()
+
+			instance := &infrav1.GCPCluster{ObjectMeta: metav1.ObjectMeta{Name: "status", Namespace: "default"}}
+			Expect(k8sClient.Create(ctx, instance)).To(Succeed())
+			defer func() {
+				Expect(k8sClient.Delete(ctx, instance)).To(Succeed())
+			}()
+
+			// The status is written by an update, like the patch helper did before the status was applied.
+			instance.Status.FailureDomains = clusterv1.FailureDomains{
+				"us-central1-a": clusterv1.FailureDomainSpec{ControlPlane: true},
+				"us-central1-b": clusterv1.FailureDomainSpec{ControlPlane: true},
+			}
+			Expect(k8sClient.Status().Update(ctx, instance)).To(Succeed())
+
+			for _, zones := range [][]string{{"us-central1-a"}, {}} {
+				gcpCluster := &infrav1.GCPCluster{}
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), gcpCluster)).To(Succeed())
+
+				clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+					Cloud:              c,
+					Client:             k8sClient,
+					Cluster:            &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Name: "status", Namespace: "default"}},
+					GCPCluster:         gcpCluster,
+					StatusFieldManager: "capg-gcpcluster-controller",
+				})
+				Expect(err).NotTo(HaveOccurred())
+				gcpCluster.Status.FailureDomains = clusterv1.FailureDomains{}
+				for _, zone := range zones {
+					gcpCluster.Status.FailureDomains[zone] = clusterv1.FailureDomainSpec{ControlPlane: true}
+				}
+				Expect(clusterScope.Close()).To(Succeed())
+
+				applied := &infrav1.GCPCluster{}
+				Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(instance), applied)).To(Succeed())
+				Expect(applied.Status.FailureDomains).To(HaveLen(len(zones)))
+				for _, zone := range zones {
+					Expect(applied.Status.FailureDomains).To(HaveKey(zone))
+				}
+			}
+		})
+	})
 })
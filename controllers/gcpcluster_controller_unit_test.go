@@ -0,0 +1,789 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"google.golang.org/api/googleapi"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	capierrors "sigs.k8s.io/cluster-api/errors"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+func newGCPCluster(name string) *infrav1.GCPCluster {
+	return &infrav1.GCPCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "default",
+		},
+		Spec: infrav1.GCPClusterSpec{
+			Project: "my-project",
+			Region:  "us-central1",
+		},
+	}
+}
+
+func newTestClusterScope(g *WithT, c *fakecloud.Cloud, k8sClient client.Client, gcpCluster *infrav1.GCPCluster) *scope.ClusterScope {
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		Cloud:      c,
+		Client:     k8sClient,
+		Cluster:    newCluster(gcpCluster.Name),
+		GCPCluster: gcpCluster,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return clusterScope
+}
+
+func TestGCPClusterReconciler_reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b", "us-central1-c")
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Spec.FailureDomains = []string{"us-central1-a", "us-central1-c"}
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+
+	_, err := reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpCluster.Status.Ready).To(BeTrue())
+	g.Expect(gcpCluster.Spec.ControlPlaneEndpoint.Host).To(Equal(*gcpCluster.Status.Network.APIServerAddress))
+	g.Expect(gcpCluster.Status.FailureDomains).To(HaveLen(2))
+	g.Expect(gcpCluster.Status.FailureDomains).To(HaveKey("us-central1-a"))
+	g.Expect(gcpCluster.Status.FailureDomains).To(HaveKey("us-central1-c"))
+	g.Expect(gcpCluster.Status.Network.FirewallRules).To(HaveLen(2))
+	g.Expect(gcpCluster.Status.Network.APIServerHealthCheck).NotTo(BeNil())
+	g.Expect(gcpCluster.Status.Network.APIServerBackendService).NotTo(BeNil())
+	g.Expect(gcpCluster.Status.Network.APIServerTargetProxy).NotTo(BeNil())
+	g.Expect(gcpCluster.Status.Network.APIServerAddressSelfLink).To(Equal(pointer.StringPtr(c.SelfLink("projects/my-project/global/addresses/my-cluster-apiserver"))))
+	g.Expect(gcpCluster.Status.Network.APIServerForwardingRule).NotTo(BeNil())
+	g.Expect(gcpCluster.Finalizers).To(ConsistOf(infrav1.ClusterFinalizer, infrav1.LoadBalancerFinalizer, infrav1.FirewallFinalizer, infrav1.NetworkFinalizer))
+	g.Expect(conditions.IsTrue(gcpCluster, infrav1.NetworkReadyCondition)).To(BeTrue())
+	g.Expect(conditions.IsTrue(gcpCluster, infrav1.FirewallsReadyCondition)).To(BeTrue())
+	g.Expect(conditions.IsTrue(gcpCluster, infrav1.LoadBalancerReadyCondition)).To(BeTrue())
+
+	_, err = reconciler.reconcileDelete(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpCluster.Finalizers).To(BeEmpty())
+	g.Expect(gcpCluster.Annotations).To(HaveKey(infrav1.BlockMoveAnnotation))
+	g.Expect(gcpCluster.Status.Ready).To(BeFalse())
+	g.Expect(gcpCluster.Status.Network.APIServerAddressSelfLink).To(BeNil())
+	g.Expect(c.List("projects/my-project/global/networks")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+}
+
+func TestGCPClusterReconciler_reconcileConditions(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b", "us-central1-c")
+	c.SetError(http.MethodPost, "projects/my-project/global/firewalls", &googleapi.Error{Code: http.StatusForbidden, Message: "permission denied"})
+
+	gcpCluster := newGCPCluster("my-cluster")
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+
+	_, err := reconciler.reconcile(clusterScope)
+	g.Expect(err).To(HaveOccurred())
+	g.Expect(conditions.IsTrue(gcpCluster, infrav1.NetworkReadyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(gcpCluster, infrav1.FirewallsReadyCondition)).To(Equal(infrav1.FirewallsReconciliationFailedReason))
+	g.Expect(*conditions.GetSeverity(gcpCluster, infrav1.FirewallsReadyCondition)).To(Equal(clusterv1.ConditionSeverityError))
+	g.Expect(conditions.GetMessage(gcpCluster, infrav1.FirewallsReadyCondition)).To(ContainSubstring("permission denied"))
+
+	// The Ready condition summarizes the conditions of the subsystems once the GCPCluster is patched.
+	g.Expect(clusterScope.PatchObject()).To(Succeed())
+	g.Expect(conditions.GetReason(gcpCluster, clusterv1.ReadyCondition)).To(Equal(infrav1.FirewallsReconciliationFailedReason))
+
+	c.SetError(http.MethodPost, "projects/my-project/global/firewalls", nil)
+	_, err = reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(clusterScope.PatchObject()).To(Succeed())
+	g.Expect(conditions.IsTrue(gcpCluster, clusterv1.ReadyCondition)).To(BeTrue())
+}
+
+func TestGCPClusterReconciler_reconcilePreflightChecks(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b", "us-central1-c")
+	c.Put("projects/my-project", map[string]interface{}{"name": "my-project"})
+	c.DisableService("my-project", "compute.googleapis.com")
+
+	gcpCluster := newGCPCluster("my-cluster")
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+		PreflightChecks:   true,
+	}
+
+	// No resource is created while the preflight checks fail, they're run again later.
+	result, err := reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">=", preflightRetryInterval))
+	g.Expect(conditions.GetReason(gcpCluster, infrav1.PreflightChecksPassedCondition)).To(Equal(infrav1.PreflightCheckFailedReason))
+	g.Expect(conditions.GetMessage(gcpCluster, infrav1.PreflightChecksPassedCondition)).To(ContainSubstring("compute.googleapis.com"))
+	g.Expect(c.List("projects/my-project/global/networks")).To(BeEmpty())
+	g.Expect(clusterScope.PatchObject()).To(Succeed())
+	g.Expect(conditions.GetReason(gcpCluster, clusterv1.ReadyCondition)).To(Equal(infrav1.PreflightCheckFailedReason))
+
+	c.Delete("projects/my-project/services/compute.googleapis.com")
+	_, err = reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.IsTrue(gcpCluster, infrav1.PreflightChecksPassedCondition)).To(BeTrue())
+	g.Expect(gcpCluster.Status.Ready).To(BeTrue())
+
+	// The checks aren't run again once passed.
+	c.DisableService("my-project", "compute.googleapis.com")
+	_, err = reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.IsTrue(gcpCluster, infrav1.PreflightChecksPassedCondition)).To(BeTrue())
+}
+
+func TestGCPClusterReconciler_operationsAfterMove(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	// The operations in progress block the move and are recorded in an annotation, which is moved.
+	gcpCluster := newGCPCluster("my-cluster")
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	op := "https://www.googleapis.com/compute/v1/projects/my-project/global/operations/operation-1"
+	clusterScope.SetOperation("global/firewalls/my-rule", op)
+	g.Expect(clusterScope.PatchObject()).To(Succeed())
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(gcpCluster), gcpCluster)).To(Succeed())
+	g.Expect(gcpCluster.Annotations).To(HaveKey(infrav1.BlockMoveAnnotation))
+	g.Expect(gcpCluster.Annotations).To(HaveKeyWithValue(infrav1.OperationsAnnotation, `{"global/firewalls/my-rule":"`+op+`"}`))
+
+	// clusterctl move doesn't move the status, the operations are waited for from the annotation.
+	moved := newGCPCluster("my-cluster")
+	moved.Annotations = gcpCluster.Annotations
+	k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(moved).Build()
+	clusterScope = newTestClusterScope(g, c, k8sClient, moved)
+	g.Expect(clusterScope.Operation("global/firewalls/my-rule")).To(Equal(op))
+
+	// The move is unblocked once the operations complete.
+	clusterScope.SetOperation("global/firewalls/my-rule", "")
+	g.Expect(clusterScope.PatchObject()).To(Succeed())
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(moved), moved)).To(Succeed())
+	g.Expect(moved.Annotations).NotTo(HaveKey(infrav1.BlockMoveAnnotation))
+	g.Expect(moved.Annotations).NotTo(HaveKey(infrav1.OperationsAnnotation))
+}
+
+func TestGCPClusterReconciler_operationsWhileDeleting(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	// The move of a cluster being deleted stays blocked once its operations complete.
+	gcpCluster := newGCPCluster("my-cluster")
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.SetOperation("global/firewalls/my-rule", "https://www.googleapis.com/compute/v1/projects/my-project/global/operations/operation-1")
+	g.Expect(clusterScope.PatchObject()).To(Succeed())
+	now := metav1.Now()
+	gcpCluster.DeletionTimestamp = &now
+	clusterScope.SetOperation("global/firewalls/my-rule", "")
+	g.Expect(clusterScope.PatchObject()).To(Succeed())
+	g.Expect(gcpCluster.Annotations).To(HaveKey(infrav1.BlockMoveAnnotation))
+	g.Expect(gcpCluster.Annotations).NotTo(HaveKey(infrav1.OperationsAnnotation))
+}
+
+func TestGCPClusterReconciler_reconcileDeleteAfterMove(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	_, err := reconciler.reconcile(newTestClusterScope(g, c, k8sClient, gcpCluster))
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// clusterctl move doesn't move the status, the resources must be found by name.
+	moved := newGCPCluster("my-cluster")
+	moved.Finalizers = []string{infrav1.ClusterFinalizer}
+	k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(moved).Build()
+	_, err = reconciler.reconcileDelete(newTestClusterScope(g, c, k8sClient, moved))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(moved.Finalizers).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/healthChecks")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/instanceGroups")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/networks")).To(BeEmpty())
+}
+
+func TestGCPClusterReconciler_reconcileDeleteProtected(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	_, err := reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The GCP resources are kept while the GCPCluster is protected, even if annotated once deleted.
+	gcpCluster.Annotations = map[string]string{infrav1.DeletionProtectionAnnotation: ""}
+	result, err := reconciler.reconcileDelete(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+	g.Expect(gcpCluster.Finalizers).To(ContainElement(infrav1.ClusterFinalizer))
+	g.Expect(conditions.GetReason(gcpCluster, infrav1.NetworkReadyCondition)).To(Equal(infrav1.DeletionProtectedReason))
+	g.Expect(c.List("projects/my-project/global/networks")).NotTo(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/firewalls")).NotTo(BeEmpty())
+
+	delete(gcpCluster.Annotations, infrav1.DeletionProtectionAnnotation)
+	_, err = reconciler.reconcileDelete(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpCluster.Finalizers).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/networks")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(BeEmpty())
+}
+
+func TestGCPClusterReconciler_reconcileAfterMove(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	_, err := reconciler.reconcile(newTestClusterScope(g, c, k8sClient, gcpCluster))
+	g.Expect(err).NotTo(HaveOccurred())
+	resources := map[string][]string{}
+	for _, collection := range []string{"global/networks", "global/firewalls", "global/addresses", "global/forwardingRules", "zones/us-central1-a/instanceGroups"} {
+		resources[collection] = c.List("projects/my-project/" + collection)
+	}
+
+	// clusterctl move creates the objects without their status, paused until all of them are moved. The endpoint of
+	// an object recreated by hand may be missing too.
+	cluster := newCluster("my-cluster")
+	cluster.Spec.Paused = true
+	moved := newGCPCluster("my-cluster")
+	moved.OwnerReferences = []metav1.OwnerReference{{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: cluster.Name}}
+	k8sClient = fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, moved).Build()
+	reconciler.Client = k8sClient
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(moved)}
+	_, err = reconciler.Reconcile(context.Background(), req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(k8sClient.Get(context.Background(), req.NamespacedName, moved)).To(Succeed())
+	g.Expect(moved.Finalizers).To(BeEmpty())
+	g.Expect(moved.Status.Network.SelfLink).To(BeNil())
+
+	// Once unpaused, the existing resources are found and adopted again, without creating or deleting any of them.
+	cluster.Spec.Paused = false
+	g.Expect(k8sClient.Update(context.Background(), cluster)).To(Succeed())
+	_, err = reconciler.Reconcile(context.Background(), req)
+	g.Expect(err).NotTo(HaveOccurred())
+	moved = &infrav1.GCPCluster{}
+	g.Expect(k8sClient.Get(context.Background(), req.NamespacedName, moved)).To(Succeed())
+	for collection, names := range resources {
+		g.Expect(c.List("projects/my-project/"+collection)).To(Equal(names), collection)
+	}
+	g.Expect(moved.Status.Ready).To(BeTrue())
+	g.Expect(moved.Spec.ControlPlaneEndpoint).To(Equal(gcpCluster.Spec.ControlPlaneEndpoint))
+	g.Expect(moved.Status.Network.SelfLink).To(Equal(gcpCluster.Status.Network.SelfLink))
+	g.Expect(moved.Status.Network.FirewallRules).To(Equal(gcpCluster.Status.Network.FirewallRules))
+	g.Expect(moved.Status.Network.APIServerAddressSelfLink).To(Equal(gcpCluster.Status.Network.APIServerAddressSelfLink))
+	g.Expect(moved.Status.Network.APIServerForwardingRule).To(Equal(gcpCluster.Status.Network.APIServerForwardingRule))
+	g.Expect(moved.Status.Network.APIServerInstanceGroups).To(Equal(gcpCluster.Status.Network.APIServerInstanceGroups))
+	g.Expect(moved.Status.OwnedResources).To(Equal(gcpCluster.Status.OwnedResources))
+}
+
+func TestGCPClusterReconciler_reconcileWithResourceNamePrefix(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Spec.ResourceNamePrefix = pointer.StringPtr("corp-k8s")
+	gcpCluster.Spec.FailureDomains = []string{"us-central1-a"}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+
+	_, err := reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(ConsistOf("projects/my-project/global/forwardingRules/corp-k8s-apiserver"))
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(ConsistOf(
+		"projects/my-project/global/firewalls/allow-corp-k8s-apiserver-healthchecks",
+		"projects/my-project/global/firewalls/allow-corp-k8s-apiserver-cluster",
+	))
+	g.Expect(c.List("projects/my-project/global/backendServices")).To(ConsistOf("projects/my-project/global/backendServices/corp-k8s-apiserver"))
+
+	_, err = reconciler.reconcileDelete(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.List("projects/my-project/global/forwardingRules")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/firewalls")).To(BeEmpty())
+	g.Expect(c.List("projects/my-project/global/backendServices")).To(BeEmpty())
+}
+
+func TestGCPClusterReconciler_reconcileWithDNSEndpoint(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Spec.ControlPlaneEndpoint.Host = "api.my-cluster.example.com"
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+
+	var lookups []string
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+		LookupHost: func(_ context.Context, host string) ([]string, error) {
+			lookups = append(lookups, host)
+			return []string{*gcpCluster.Status.Network.APIServerAddress}, nil
+		},
+	}
+
+	// The DNS name is kept as the endpoint, and checked against the load balancer address.
+	_, err := reconciler.reconcile(clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpCluster.Status.Ready).To(BeTrue())
+	g.Expect(gcpCluster.Spec.ControlPlaneEndpoint).To(Equal(clusterv1.APIEndpoint{Host: "api.my-cluster.example.com", Port: 443}))
+	g.Expect(lookups).To(ConsistOf("api.my-cluster.example.com"))
+}
+
+func TestFailureDomains(t *testing.T) {
+	g := NewWithT(t)
+
+	zones := []string{"us-central1-a", "us-central1-b", "us-central1-c"}
+	g.Expect(failureDomains(zones, nil, nil)).To(HaveLen(3))
+	g.Expect(failureDomains(zones, []string{"us-central1-a", "us-central1-c"}, nil)).To(SatisfyAll(HaveLen(2), HaveKey("us-central1-a"), HaveKey("us-central1-c")))
+	g.Expect(failureDomains(zones, nil, []string{"us-central1-b"})).To(SatisfyAll(HaveLen(2), Not(HaveKey("us-central1-b"))))
+	g.Expect(failureDomains(zones, []string{"us-central1-a", "us-central1-b"}, []string{"us-central1-b"})).To(SatisfyAll(HaveLen(1), HaveKey("us-central1-a")))
+}
+
+func TestGCPClusterReconciler_reconcileResyncInterval(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+
+	// The ready clusters are only reconciled again on resync by default.
+	result, err := reconciler.reconcile(newTestClusterScope(g, c, k8sClient, gcpCluster))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpCluster.Status.Ready).To(BeTrue())
+	g.Expect(result.RequeueAfter).To(BeZero())
+
+	// The shortest of the resync and failure domain refresh intervals wins.
+	reconciler.ResyncInterval = 5 * time.Minute
+	reconciler.FailureDomainRefreshInterval = time.Hour
+	result, err = reconciler.reconcile(newTestClusterScope(g, c, k8sClient, gcpCluster))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(5 * time.Minute))
+}
+
+func TestGCPClusterReconciler_idle(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).Build()
+
+	now := time.Now().Add(-time.Hour)
+	reconciler := &GCPClusterReconciler{
+		Client:        k8sClient,
+		IdleInterval:  5 * time.Minute,
+		ZoneIncidents: cloud.NewZoneIncidents(cloud.DefaultZoneIncidentWindow),
+		Now:           func() time.Time { return now },
+	}
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Generation = 2
+	gcpCluster.Status.Ready = true
+	gcpCluster.Status.ObservedGeneration = 2
+	cluster := &clusterv1.Cluster{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "my-cluster", Generation: 1}}
+	observe := func() *clusterSync {
+		observed, err := reconciler.observe(context.TODO(), gcpCluster, cluster)
+		g.Expect(err).NotTo(HaveOccurred())
+		return observed
+	}
+
+	// The clusters not reconciled since the start of the manager aren't idle.
+	_, ok := reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+
+	reconciler.markSynced(gcpCluster, observe())
+	now = now.Add(time.Minute)
+	left, ok := reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeTrue())
+	g.Expect(left).To(Equal(4 * time.Minute))
+
+	// The changes of the spec of the GCPCluster or of the Cluster end the idle interval.
+	gcpCluster.Generation = 3
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+	gcpCluster.Generation = 2
+	cluster.Generation = 2
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+	cluster.Generation = 1
+
+	// So do the changes of the annotations changing the reconcile.
+	gcpCluster.Annotations = map[string]string{infrav1.RetainAnnotation: "network"}
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+	reconciler.markSynced(gcpCluster, observe())
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeTrue())
+	gcpCluster.Annotations[infrav1.ExportAnnotation] = ""
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+	delete(gcpCluster.Annotations, infrav1.ExportAnnotation)
+	gcpCluster.Annotations["example.com/owner"] = "team"
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeTrue())
+
+	// So do the changes of the control plane GCPMachines, whose zones have API server instance groups.
+	controlPlane := &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "my-control-plane",
+		Labels:    map[string]string{clusterv1.ClusterLabelName: "my-cluster", clusterv1.MachineControlPlaneLabelName: ""},
+	}}
+	g.Expect(k8sClient.Create(context.TODO(), controlPlane)).To(Succeed())
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+	reconciler.markSynced(gcpCluster, observe())
+	controlPlane.Spec.ProviderID = pointer.StringPtr("gce://my-project/us-central1-a/my-control-plane")
+	g.Expect(k8sClient.Update(context.TODO(), controlPlane)).To(Succeed())
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+	reconciler.markSynced(gcpCluster, observe())
+	g.Expect(k8sClient.Create(context.TODO(), &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "my-node",
+		Labels:    map[string]string{clusterv1.ClusterLabelName: "my-cluster"},
+	}})).To(Succeed())
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeTrue())
+
+	// So do the zone incidents.
+	reconciler.ZoneIncidents.Record("my-project", "us-central1-a", "ZONE_RESOURCE_POOL_EXHAUSTED")
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+
+	now = time.Now().Add(time.Minute)
+	reconciler.markSynced(gcpCluster, observe())
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeTrue())
+	now = now.Add(5 * time.Minute)
+	_, ok = reconciler.idle(gcpCluster, observe())
+	g.Expect(ok).To(BeFalse())
+}
+
+func TestGCPClusterReconciler_reconcileIneligibleFailureDomains(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b", "us-central1-c")
+	c.Put("projects/my-project/zones/us-central1-b", map[string]interface{}{
+		"name":   "us-central1-b",
+		"region": c.SelfLink("projects/my-project/regions/us-central1"),
+		"status": "DOWN",
+	})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+		ZoneIncidents:     cloud.NewZoneIncidents(cloud.DefaultZoneIncidentWindow),
+	}
+	reconciler.ZoneIncidents.Record("my-project", "us-central1-c", "ZONE_RESOURCE_POOL_EXHAUSTED")
+
+	result, err := reconciler.reconcile(newTestClusterScope(g, c, k8sClient, gcpCluster))
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).NotTo(BeZero())
+	g.Expect(gcpCluster.Status.FailureDomains).To(HaveLen(3))
+	g.Expect(gcpCluster.Status.FailureDomains["us-central1-a"].ControlPlane).To(BeTrue())
+	g.Expect(gcpCluster.Status.FailureDomains["us-central1-b"].ControlPlane).To(BeFalse())
+	g.Expect(gcpCluster.Status.FailureDomains["us-central1-b"].Attributes).To(HaveKeyWithValue(infrav1.IneligibleReasonAttribute, "zone is DOWN"))
+	g.Expect(gcpCluster.Status.FailureDomains["us-central1-c"].ControlPlane).To(BeFalse())
+	g.Expect(gcpCluster.Status.FailureDomains["us-central1-c"].Attributes).To(HaveKeyWithValue(infrav1.IneligibleReasonAttribute, "ZONE_RESOURCE_POOL_EXHAUSTED"))
+}
+
+// recordingExporter records the exported metric points.
+type recordingExporter struct {
+	points map[string]int64
+}
+
+func (e *recordingExporter) Export(_ context.Context, project string, points []cloud.MetricPoint) error {
+	for _, p := range points {
+		e.points[p.Type] = p.Value
+	}
+
+	return nil
+}
+
+func TestGCPClusterReconciler_exportMetrics(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a")
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Status.Ready = true
+	failureReason := capierrors.CreateMachineError
+	provisioned := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine-0", Namespace: "default", Labels: map[string]string{clusterv1.ClusterLabelName: "my-cluster"}},
+		Status:     infrav1.GCPMachineStatus{Ready: true},
+	}
+	failed := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine-1", Namespace: "default", Labels: map[string]string{clusterv1.ClusterLabelName: "my-cluster"}},
+		Status:     infrav1.GCPMachineStatus{FailureReason: &failureReason},
+	}
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, provisioned, failed).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+
+	exporter := &recordingExporter{points: map[string]int64{}}
+	reconciler := &GCPClusterReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+		Metrics:           exporter,
+	}
+	reconciler.exportMetrics(context.Background(), clusterScope)
+	g.Expect(exporter.points).To(Equal(map[string]int64{
+		"cluster/ready":                      1,
+		"cluster/machines_provisioned":       1,
+		"cluster/machines_failed":            1,
+		"cluster/apiserver_backends_healthy": 0,
+		"cluster/apiserver_backends":         0,
+	}))
+}
+
+func TestGCPClusterReconciler_reconcileBreakGlassSSH(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Spec.BreakGlassSSH = &infrav1.BreakGlassSSHSpec{User: "oncall"}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	reconciler := &GCPClusterReconciler{Client: k8sClient, Log: klogr.New(), ReconcilerOptions: ReconcilerOptions{Cloud: c}}
+
+	g.Expect(reconciler.reconcileBreakGlassSSH(context.TODO(), clusterScope)).To(Succeed())
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Namespace: "default", Name: "my-cluster-break-glass-ssh"}
+	g.Expect(k8sClient.Get(context.TODO(), key, secret)).To(Succeed())
+	g.Expect(secret.Type).To(Equal(corev1.SecretTypeSSHAuth))
+	g.Expect(secret.OwnerReferences).To(HaveLen(1))
+	g.Expect(secret.OwnerReferences[0].Name).To(Equal("my-cluster"))
+
+	// The public key in the status is the one of the private key of the Secret.
+	block, _ := pem.Decode(secret.Data[corev1.SSHAuthPrivateKey])
+	g.Expect(block).NotTo(BeNil())
+	privateKey, err := x509.ParseECPrivateKey(block.Bytes)
+	g.Expect(err).NotTo(HaveOccurred())
+	status := gcpCluster.Status.BreakGlassSSH
+	g.Expect(status).NotTo(BeNil())
+	g.Expect(status.SecretName).To(Equal(key.Name))
+	g.Expect(status.PublicKey).To(Equal("ecdsa-sha2-nistp256 " + base64.StdEncoding.EncodeToString(sshPublicKey(&privateKey.PublicKey))))
+	g.Expect(status.Fingerprint).To(HavePrefix("SHA256:"))
+	g.Expect(clusterScope.BreakGlassSSHKey()).To(Equal("oncall:" + status.PublicKey + " oncall"))
+
+	// The key pair is generated once.
+	g.Expect(reconciler.reconcileBreakGlassSSH(context.TODO(), clusterScope)).To(Succeed())
+	g.Expect(gcpCluster.Status.BreakGlassSSH).To(Equal(status))
+
+	gcpCluster.Spec.BreakGlassSSH = nil
+	g.Expect(reconciler.reconcileBreakGlassSSH(context.TODO(), clusterScope)).To(Succeed())
+	g.Expect(gcpCluster.Status.BreakGlassSSH).To(BeNil())
+	g.Expect(apierrors.IsNotFound(k8sClient.Get(context.TODO(), key, &corev1.Secret{}))).To(BeTrue())
+}
+
+func TestGCPClusterReconciler_reconcileExport(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Annotations = map[string]string{infrav1.ExportAnnotation: "terraform"}
+	gcpCluster.Status.OwnedResources = []string{"global/networks/my-cluster"}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	reconciler := &GCPClusterReconciler{Client: k8sClient, Log: klogr.New(), ReconcilerOptions: ReconcilerOptions{Cloud: c}}
+
+	g.Expect(reconciler.reconcileExport(context.TODO(), clusterScope)).To(Succeed())
+	configMap := &corev1.ConfigMap{}
+	key := client.ObjectKey{Namespace: "default", Name: "my-cluster-gcp-export"}
+	g.Expect(k8sClient.Get(context.TODO(), key, configMap)).To(Succeed())
+	g.Expect(configMap.OwnerReferences).To(HaveLen(1))
+	g.Expect(configMap.Data).To(HaveKeyWithValue("import.tf", ContainSubstring(`to = google_compute_network.my_cluster`)))
+
+	gcpCluster.Annotations[infrav1.ExportAnnotation] = "config-connector"
+	g.Expect(reconciler.reconcileExport(context.TODO(), clusterScope)).To(Succeed())
+	configMap = &corev1.ConfigMap{}
+	g.Expect(k8sClient.Get(context.TODO(), key, configMap)).To(Succeed())
+	g.Expect(configMap.Data).To(HaveLen(1))
+	g.Expect(configMap.Data).To(HaveKeyWithValue("export.sh", ContainSubstring("config-connector export //compute.googleapis.com/projects/")))
+
+	gcpCluster.Annotations[infrav1.ExportAnnotation] = "pulumi"
+	g.Expect(reconciler.reconcileExport(context.TODO(), clusterScope)).To(Succeed())
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+)
+
+// exportConfigMapSuffix is the suffix of the name of the ConfigMaps holding the exports of the GCP resources.
+const exportConfigMapSuffix = "-gcp-export"
+
+// reconcileExport writes the export of the owned GCP resources of the GCPCluster to its export ConfigMap, in the
+// format requested by the ExportAnnotation. The ConfigMap is left in place once the annotation is removed.
+func (r *GCPClusterReconciler) reconcileExport(ctx context.Context, clusterScope *scope.ClusterScope) error {
+	gcpCluster := clusterScope.GCPCluster
+	value, ok := gcpCluster.Annotations[infrav1.ExportAnnotation]
+	if !ok || clusterScope.DryRun() != nil {
+		return nil
+	}
+	format, err := cloud.ParseExportFormat(value)
+	if err != nil {
+		record.Warnf(gcpCluster, "InvalidExportFormat", "Invalid %s annotation: %v", infrav1.ExportAnnotation, err)
+		return nil
+	}
+	export := cloud.Export(format, clusterScope.Project(), gcpCluster.Status.OwnedResources)
+
+	key := types.NamespacedName{Namespace: gcpCluster.Namespace, Name: gcpCluster.Name + exportConfigMapSuffix}
+	configMap := &corev1.ConfigMap{}
+	err = r.Client.Get(ctx, key, configMap)
+	switch {
+	case apierrors.IsNotFound(err):
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: gcpCluster.Labels[clusterv1.ClusterLabelName]},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(gcpCluster, infrav1.GroupVersion.WithKind("GCPCluster")),
+				},
+			},
+			Data: map[string]string{format.Key(): export},
+		}
+		if err := r.Client.Create(ctx, configMap); err != nil {
+			return errors.Wrapf(err, "failed to create export ConfigMap %s", key)
+		}
+	case err != nil:
+		return errors.Wrapf(err, "failed to get export ConfigMap %s", key)
+	default:
+		if len(configMap.Data) == 1 && configMap.Data[format.Key()] == export {
+			return nil
+		}
+		configMap.Data = map[string]string{format.Key(): export}
+		if err := r.Client.Update(ctx, configMap); err != nil {
+			return errors.Wrapf(err, "failed to update export ConfigMap %s", key)
+		}
+	}
+
+	record.Eventf(gcpCluster, "GCPResourcesExported", "Exported the owned GCP resources as %s to ConfigMap %s", format, key.Name)
+
+	return nil
+}
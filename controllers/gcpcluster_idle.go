@@ -0,0 +1,148 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+// idleAnnotations are the annotations of a GCPCluster changing its reconcile, which don't bump its generation.
+var idleAnnotations = []string{
+	infrav1.AdoptAnnotation,
+	infrav1.DryRunAnnotation,
+	infrav1.ExportAnnotation,
+	infrav1.RequestReasonAnnotation,
+	infrav1.RetainAnnotation,
+}
+
+// clusterSync is the state of a GCPCluster at its last complete reconcile.
+type clusterSync struct {
+	// clusterGeneration is the generation of the Cluster.
+	clusterGeneration int64
+	// annotations is the hash of the idleAnnotations of the GCPCluster.
+	annotations uint64
+	// controlPlane is the hash of the control plane GCPMachines of the cluster and of their instances.
+	controlPlane uint64
+	// at is the time of the reconcile.
+	at time.Time
+}
+
+// observe returns the state of the GCPCluster before its reconcile, nil without idle interval. The control plane
+// GCPMachines are part of it as the API server instance groups of their zones are recorded by the reconcile.
+func (r *GCPClusterReconciler) observe(ctx context.Context, gcpCluster *infrav1.GCPCluster, cluster *clusterv1.Cluster) (*clusterSync, error) {
+	if r.IdleInterval <= 0 {
+		return nil, nil
+	}
+
+	gcpMachines := &infrav1.GCPMachineList{}
+	if err := r.List(ctx, gcpMachines, client.InNamespace(cluster.Namespace), client.MatchingLabels{clusterv1.ClusterLabelName: cluster.Name}); err != nil {
+		return nil, errors.Wrap(err, "failed to list the GCPMachines of the cluster")
+	}
+	var members []string
+	for _, m := range gcpMachines.Items {
+		if _, ok := m.Labels[clusterv1.MachineControlPlaneLabelName]; !ok {
+			continue
+		}
+		member := m.Name + "=" + m.Status.Zone
+		if m.Spec.ProviderID != nil {
+			member += "," + *m.Spec.ProviderID
+		}
+		if !m.DeletionTimestamp.IsZero() {
+			member += ",deleting"
+		}
+		members = append(members, member)
+	}
+	sort.Strings(members)
+	h := fnv.New64a()
+	for _, member := range members {
+		_, _ = h.Write([]byte(member + "\n"))
+	}
+
+	return &clusterSync{
+		clusterGeneration: cluster.Generation,
+		annotations:       annotationsHash(gcpCluster),
+		controlPlane:      h.Sum64(),
+		at:                r.now(),
+	}, nil
+}
+
+// idle returns true, and the time left until the end of the idle interval, if the GCPCluster was
+// completely reconciled within the idle interval and nothing it observed changed since: neither its spec, nor its
+// idleAnnotations, nor the spec of its Cluster, nor its control plane GCPMachines, nor the incidents in the zones.
+// The updates of the status of the GCPCluster, of its Cluster or of its other GCPMachines then don't trigger new
+// reads of the GCP resources.
+func (r *GCPClusterReconciler) idle(gcpCluster *infrav1.GCPCluster, observed *clusterSync) (time.Duration, bool) {
+	if observed == nil || !gcpCluster.DeletionTimestamp.IsZero() || !gcpCluster.Status.Ready ||
+		gcpCluster.Status.ObservedGeneration != gcpCluster.Generation {
+		return 0, false
+	}
+
+	v, ok := r.synced.Load(client.ObjectKeyFromObject(gcpCluster))
+	if !ok {
+		return 0, false
+	}
+	last := v.(clusterSync)
+	if last.clusterGeneration != observed.clusterGeneration || last.annotations != observed.annotations ||
+		last.controlPlane != observed.controlPlane || r.ZoneIncidents.Last().After(last.at) {
+		return 0, false
+	}
+
+	left := r.IdleInterval - r.now().Sub(last.at)
+	if left <= 0 {
+		return 0, false
+	}
+
+	return left, true
+}
+
+// markSynced records the complete reconcile of the GCPCluster, once persisted, with the state it observed
+// before the reconcile, so that the changes made during the reconcile end the idle interval.
+func (r *GCPClusterReconciler) markSynced(gcpCluster *infrav1.GCPCluster, observed *clusterSync) {
+	if observed == nil {
+		return
+	}
+	r.synced.Store(client.ObjectKeyFromObject(gcpCluster), *observed)
+}
+
+// annotationsHash returns the hash of the idleAnnotations of the GCPCluster, telling an empty one from a missing one.
+func annotationsHash(gcpCluster *infrav1.GCPCluster) uint64 {
+	h := fnv.New64a()
+	for _, key := range idleAnnotations {
+		if value, ok := gcpCluster.Annotations[key]; ok {
+			_, _ = h.Write([]byte(key + "=" + value + "\n"))
+		}
+	}
+
+	return h.Sum64()
+}
+
+func (r *GCPClusterReconciler) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+
+	return time.Now()
+}
@@ -20,6 +20,9 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -28,10 +31,12 @@ import (
 
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
 	capierrors "sigs.k8s.io/cluster-api/errors"
 	"sigs.k8s.io/cluster-api/util"
 	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/predicates"
 	"sigs.k8s.io/cluster-api/util/record"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -39,11 +44,16 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/gcperrors"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/compute"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/metrics"
 	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
 )
 
@@ -53,12 +63,68 @@ type GCPMachineReconciler struct {
 	Log              logr.Logger
 	ReconcileTimeout time.Duration
 	WatchFilterValue string
+
+	ReconcilerOptions
+
+	// ProvisioningTimeout is the time an instance can take to start running before the
+	// GCPMachine is failed, there is no timeout if zero.
+	ProvisioningTimeout time.Duration
+
+	// BootstrapTimeout is the time an instance can take to report its bootstrap status
+	// before the GCPMachine is failed, there is no timeout if zero.
+	BootstrapTimeout time.Duration
+
+	// CaptureSerialConsole makes the reconciler capture the serial console output of the instances which fail to
+	// bootstrap, or don't report their bootstrap status within the bootstrap timeout, to a ConfigMap per GCPMachine.
+	CaptureSerialConsole bool
+
+	// InstanceResyncInterval is the interval at which the instances of the ready GCPMachines are
+	// checked, to fail the GCPMachines whose instance has been deleted outside of Cluster API
+	// without waiting for the sync period. The ready GCPMachines are not requeued if zero.
+	InstanceResyncInterval time.Duration
+
+	// RequireExplicitServiceAccount prevents the creation of instances running as the default compute
+	// service account, the GCPMachines have to set the service account of their instance.
+	RequireExplicitServiceAccount bool
+
+	// Cache caches the GCP lookups across the reconciles, nothing is cached if nil.
+	Cache *cloud.LookupCache
+
+	// GoogleAccess is the way the GCP APIs are reached, defaults to the public access.
+	GoogleAccess cloud.GoogleAccess
+
+	// ZoneIncidents records the instance creations failing because their zone is out of resources.
+	ZoneIncidents *cloud.ZoneIncidents
+
+	// PriorityConcurrency, if positive, is the number of control plane and deleting GCPMachines reconciled
+	// concurrently from a dedicated queue, so that they aren't held up behind the routine reconciles of the
+	// other GCPMachines under backlog. All the GCPMachines share a single queue if zero.
+	PriorityConcurrency int
+
+	// StatusFieldManager, if set, is the field manager applying the status of the GCPMachines with a
+	// server-side apply, instead of patching it with their spec.
+	StatusFieldManager string
 }
 
 func (r *GCPMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
-	log := r.Log.WithValues("controller", "GCPMachine")
+	if r.PriorityConcurrency <= 0 {
+		return r.setupController(ctx, mgr, "gcpmachine", options, r)
+	}
+
+	if err := r.setupController(ctx, mgr, "gcpmachine", options, &machineQueue{GCPMachineReconciler: r}); err != nil {
+		return err
+	}
+	options.MaxConcurrentReconciles = r.PriorityConcurrency
+
+	return r.setupController(ctx, mgr, "gcpmachine-priority", options, &machineQueue{GCPMachineReconciler: r, priority: true})
+}
+
+// setupController sets up a controller reconciling the GCPMachines with the reconciler.
+func (r *GCPMachineReconciler) setupController(ctx context.Context, mgr ctrl.Manager, name string, options controller.Options, rec reconcile.Reconciler) error {
+	log := r.Log.WithValues("controller", name)
 
 	c, err := ctrl.NewControllerManagedBy(mgr).
+		Named(name).
 		WithOptions(options).
 		For(&infrav1.GCPMachine{}).
 		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
@@ -70,7 +136,7 @@ func (r *GCPMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 			&source.Kind{Type: &infrav1.GCPCluster{}},
 			handler.EnqueueRequestsFromMapFunc(r.GCPClusterToGCPMachines),
 		).
-		Build(r)
+		Build(rec)
 	if err != nil {
 		return errors.Wrap(err, "error creating controller")
 	}
@@ -96,6 +162,7 @@ func (r *GCPMachineReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Ma
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmachines/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines;machines/status,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;create;update
 // +kubebuilder:rbac:groups="",resources=secrets;,verbs=get;list;watch
 
 func (r *GCPMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
@@ -135,6 +202,11 @@ func (r *GCPMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 		return ctrl.Result{}, nil
 	}
 
+	// The cluster is reconciled by another replica.
+	if !r.Shard.Owns(cluster.Namespace, cluster.Name) {
+		return ctrl.Result{}, nil
+	}
+
 	if annotations.IsPaused(cluster, gcpMachine) {
 		logger.Info("GCPMachine or linked Cluster is marked as paused. Won't reconcile")
 		return ctrl.Result{}, nil
@@ -156,12 +228,30 @@ func (r *GCPMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	logger = logger.WithValues("gcpCluster", gcpCluster.Name)
 
+	var dryRun *cloud.DryRun
+	if r.DryRun || isDryRun(gcpMachine, gcpCluster) {
+		dryRun = &cloud.DryRun{}
+		defer reportDryRun(logger, gcpMachine, dryRun)
+	}
+
 	// Create the cluster scope
 	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		Cloud:      r.Cloud,
+		Cache:      r.Cache,
+		Stats:      r.Stats,
+		Audit:      r.Audit,
+		DryRun:     dryRun,
 		Client:     r.Client,
 		Logger:     logger,
 		Cluster:    cluster,
 		GCPCluster: gcpCluster,
+
+		FaultInjection: r.FaultInjection,
+		RateLimiter:    r.RateLimiter,
+		GoogleAccess:   r.GoogleAccess,
+
+		// The GCPCluster isn't persisted, the operations recorded in its status would be lost.
+		WaitForOperations: true,
 	})
 	if err != nil {
 		return ctrl.Result{}, err
@@ -169,12 +259,15 @@ func (r *GCPMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	// Create the machine scope
 	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		DryRun:     dryRun,
 		Logger:     logger,
 		Client:     r.Client,
 		Cluster:    cluster,
 		Machine:    machine,
 		GCPCluster: gcpCluster,
 		GCPMachine: gcpMachine,
+
+		StatusFieldManager: r.StatusFieldManager,
 	})
 	if err != nil {
 		return ctrl.Result{}, errors.Errorf("failed to create scope: %+v", err)
@@ -189,14 +282,16 @@ func (r *GCPMachineReconciler) Reconcile(ctx context.Context, req ctrl.Request)
 
 	// Handle deleted machines
 	if !gcpMachine.ObjectMeta.DeletionTimestamp.IsZero() {
-		return r.reconcileDelete(machineScope, clusterScope)
+		res, err := r.reconcileDelete(machineScope, clusterScope)
+		return requeueOnOperationTimeout(logger, res, err, r.RequeueJitter)
 	}
 
 	// Handle non-deleted machines
-	return r.reconcile(ctx, machineScope, clusterScope)
+	res, err := r.reconcile(ctx, machineScope, clusterScope)
+	return requeueOnOperationTimeout(logger, res, err, r.RequeueJitter)
 }
 
-func (r *GCPMachineReconciler) reconcile(_ context.Context, machineScope *scope.MachineScope, clusterScope *scope.ClusterScope) (ctrl.Result, error) {
+func (r *GCPMachineReconciler) reconcile(ctx context.Context, machineScope *scope.MachineScope, clusterScope *scope.ClusterScope) (ctrl.Result, error) {
 	machineScope.Info("Reconciling GCPMachine")
 	// If the GCPMachine is in an error state, return early.
 	if machineScope.GCPMachine.Status.FailureReason != nil || machineScope.GCPMachine.Status.FailureMessage != nil {
@@ -217,60 +312,365 @@ func (r *GCPMachineReconciler) reconcile(_ context.Context, machineScope *scope.
 		return ctrl.Result{}, nil
 	}
 
-	// Make sure bootstrap data is available and populated.
-	if machineScope.Machine.Spec.Bootstrap.DataSecretName == nil {
+	// Make sure bootstrap data is available and populated, unless the instance is adopted.
+	if machineScope.Machine.Spec.Bootstrap.DataSecretName == nil && machineScope.GCPMachine.Spec.ExistingInstance == nil {
 		machineScope.Info("Bootstrap data secret reference is not yet available")
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.BootstrapDataReadyCondition, infrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
 
 		return ctrl.Result{}, nil
 	}
+	conditions.MarkTrue(machineScope.GCPMachine, infrav1.BootstrapDataReadyCondition)
 
 	computeSvc := compute.NewService(clusterScope)
 
 	// Get or create the instance.
-	instance, err := r.getOrCreate(machineScope, computeSvc)
+	var instance *gcompute.Instance
+	err := metrics.ObserveReconcile(clusterScope.GCPCluster.Namespace, clusterScope.GCPCluster.Name, metrics.SubsystemInstance, func() (err error) {
+		instance, err = r.getOrCreate(machineScope, computeSvc)
+
+		return err
+	})
+	if gcperrors.IsZoneResourcePoolExhausted(err) {
+		// Let the cluster steer the control plane away from the zone.
+		r.ZoneIncidents.Record(clusterScope.Project(), machineScope.Zone(), gcperrors.ZoneResourcePoolExhausted)
+		record.Warnf(machineScope.GCPMachine, "ZoneResourcePoolExhausted", "Zone %q is out of resources to create instance %q", machineScope.Zone(), machineScope.InstanceName())
+	}
+	if errors.Cause(err) == errExistingInstanceNotFound {
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.ExistingInstanceNotFoundReason, clusterv1.ConditionSeverityError,
+			"%v", err)
+		machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+		machineScope.SetFailureMessage(err)
+
+		return ctrl.Result{}, nil
+	}
+	if errors.Cause(err) == errDefaultServiceAccount {
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.DefaultServiceAccountNotAllowedReason, clusterv1.ConditionSeverityError,
+			"%v", err)
+		machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+		machineScope.SetFailureMessage(err)
+
+		return ctrl.Result{}, nil
+	}
+	var unavailable *compute.UnavailableInZoneError
+	if errors.As(err, &unavailable) {
+		// The instance can't be created until the GCPMachine, or its failure domain, is changed.
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceTypeUnavailableReason, clusterv1.ConditionSeverityError,
+			"%v", err)
+		machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+		machineScope.SetFailureMessage(err)
+		record.Warnf(machineScope.GCPMachine, "InstanceTypeUnavailable", "Instance %q can't be created: %v", machineScope.InstanceName(), err)
+
+		return ctrl.Result{}, nil
+	}
+	var unavailableInRegion *compute.UnavailableInRegionError
+	if errors.As(err, &unavailableInRegion) {
+		// The instance can't be created until the accelerators of the GCPMachine, or their offering, are changed.
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.AcceleratorsUnavailableReason, clusterv1.ConditionSeverityError,
+			"%v", err)
+		machineScope.SetFailureReason(capierrors.InvalidConfigurationMachineError)
+		machineScope.SetFailureMessage(err)
+		record.Warnf(machineScope.GCPMachine, "AcceleratorsUnavailable", "Instance %q can't be created: %v", machineScope.InstanceName(), err)
+
+		return ctrl.Result{}, nil
+	}
+	if constraint := gcperrors.ViolatedConstraint(err); constraint != "" {
+		// The instance can't be created until the GCPMachine, or the org policy, is changed.
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceOrgPolicyViolationReason, clusterv1.ConditionSeverityError,
+			"Instance creation denied by the org policy constraint %s", constraint)
+		machineScope.SetFailureReason(capierrors.CreateMachineError)
+		machineScope.SetFailureMessage(errors.Errorf("GCE instance creation denied by the org policy constraint %s", constraint))
+		record.Warnf(machineScope.GCPMachine, "OrgPolicyViolation", "Instance %q violates the org policy constraint %s: %v", machineScope.InstanceName(), constraint, err)
+
+		return ctrl.Result{}, nil
+	}
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
-	// Set a failure message if we couldn't find the instance.
+	// Set a failure message if the instance has been deleted, so the Machine can be remediated.
 	if instance == nil {
+		machineScope.Info("Machine instance has been deleted outside of Cluster API", "instance-id", machineScope.GetProviderID())
+		machineScope.SetNotReady()
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceDeletedReason, clusterv1.ConditionSeverityError,
+			"Instance has been deleted outside of Cluster API")
 		machineScope.SetFailureReason(capierrors.UpdateMachineError)
-		machineScope.SetFailureMessage(errors.New("GCE instance cannot be found"))
+		machineScope.SetFailureMessage(errors.Errorf("GCE instance %q cannot be found", machineScope.InstanceName()))
+		record.Warnf(machineScope.GCPMachine, "InstanceDeleted", "Instance %q has been deleted outside of Cluster API", machineScope.InstanceName())
 
 		return ctrl.Result{}, nil
 	}
 
 	// Make sure Spec.ProviderID is always set.
-	machineScope.SetProviderID(fmt.Sprintf("gce://%s/%s/%s", clusterScope.Project(), machineScope.Zone(), instance.Name))
+	setProviderID(machineScope, clusterScope, instance)
 
 	// Proceed to reconcile the GCPMachine state.
 	machineScope.SetInstanceStatus(infrav1.InstanceStatus(instance.Status))
 
 	machineScope.SetAddresses(r.getAddresses(instance))
 
+	// Set a failure message if the instance can't be updated to the GCPMachine, so the Machine can be remediated.
+	if drift := computeSvc.InstanceImmutableDrift(machineScope, instance); drift != "" {
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceImmutableFieldChangedReason, clusterv1.ConditionSeverityError,
+			"Instance can't be updated in place: %s", drift)
+		machineScope.SetFailureReason(capierrors.UpdateMachineError)
+		machineScope.SetFailureMessage(errors.Errorf("GCE instance can't be updated in place: %s", drift))
+		record.Warnf(machineScope.GCPMachine, "InstanceImmutableFieldChanged", "Instance %q can't be updated in place: %s", instance.Name, drift)
+
+		return ctrl.Result{}, nil
+	}
+
+	if err := computeSvc.ReconcileInstanceLabels(machineScope, instance); err != nil {
+		return r.requeueOnFingerprintMismatch(machineScope, err)
+	}
+
+	if err := computeSvc.ReconcileInstanceTags(machineScope, instance); err != nil {
+		return r.requeueOnFingerprintMismatch(machineScope, err)
+	}
+
+	if err := computeSvc.ReconcileInstanceMetadata(machineScope, instance); err != nil {
+		return r.requeueOnFingerprintMismatch(machineScope, err)
+	}
+
+	if err := computeSvc.ReconcileRootDiskSize(machineScope, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err := computeSvc.ReconcileInstanceType(machineScope, instance); err != nil {
+		if wait.IsTimeout(err) {
+			return ctrl.Result{}, err
+		}
+		record.Warnf(machineScope.GCPMachine, "FailedUpdateMachineType", "Failed to change machine type of instance %q: %v", instance.Name, err)
+		return ctrl.Result{}, err
+	}
+
+	scheduling, err := computeSvc.GetInstanceScheduling(instance, machineScope.GCPMachine.Status.Scheduling)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	machineScope.GCPMachine.Status.Scheduling = scheduling
+
+	result := ctrl.Result{}
 	switch infrav1.InstanceStatus(instance.Status) {
 	case infrav1.InstanceStatusRunning:
 		machineScope.Info("Machine instance is running", "instance-id", *machineScope.GetInstanceID())
 		machineScope.SetReady()
+		conditions.MarkTrue(machineScope.GCPMachine, infrav1.InstanceReadyCondition)
+		pending, err := r.reconcileBootstrapStatus(ctx, machineScope, computeSvc, instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		switch {
+		case pending:
+			result.RequeueAfter = reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)
+		case r.InstanceResyncInterval > 0:
+			result.RequeueAfter = reconciler.JitteredRequeueAfter(r.InstanceResyncInterval, r.RequeueJitter)
+		}
 	case infrav1.InstanceStatusProvisioning, infrav1.InstanceStatusStaging:
 		machineScope.Info("Machine instance is pending", "instance-id", *machineScope.GetInstanceID())
+		if timedOut(instance, r.ProvisioningTimeout) {
+			conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceProvisioningTimedOutReason, clusterv1.ConditionSeverityError,
+				"Instance has not started running within %s", r.ProvisioningTimeout)
+			machineScope.SetFailureReason(capierrors.CreateMachineError)
+			machineScope.SetFailureMessage(errors.Errorf("GCE instance has not started running within %s", r.ProvisioningTimeout))
+			record.Warnf(machineScope.GCPMachine, "ProvisioningTimedOut", "Instance %q has not started running within %s", instance.Name, r.ProvisioningTimeout)
+			break
+		}
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceProvisioningReason, clusterv1.ConditionSeverityInfo, "")
+		result.RequeueAfter = reconciler.JitteredRequeueAfter(15*time.Second, r.RequeueJitter)
+	case infrav1.InstanceStatusRepairing:
+		// The instance is being recreated by GCE after a host failure, it's expected to run again.
+		machineScope.Info("Machine instance is being repaired", "instance-id", *machineScope.GetInstanceID())
+		machineScope.SetNotReady()
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceRepairingReason, clusterv1.ConditionSeverityWarning,
+			"Instance is being repaired by GCE")
+		result.RequeueAfter = reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)
+	case infrav1.InstanceStatusStopping, infrav1.InstanceStatusStopped, infrav1.InstanceStatusSuspending, infrav1.InstanceStatusSuspended:
+		// The instance has been stopped or suspended out-of-band, it can be started or resumed the same way.
+		reason := infrav1.InstanceStoppedReason
+		if s := infrav1.InstanceStatus(instance.Status); s == infrav1.InstanceStatusSuspending || s == infrav1.InstanceStatusSuspended {
+			reason = infrav1.InstanceSuspendedReason
+		}
+		machineScope.Info("Machine instance is not running", "instance-id", *machineScope.GetInstanceID(), "state", instance.Status)
+		machineScope.SetNotReady()
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, reason, clusterv1.ConditionSeverityError,
+			"Instance state is %q", instance.Status)
+		result.RequeueAfter = reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)
+	case infrav1.InstanceStatusTerminated:
+		if machineScope.GCPMachine.Spec.RepairPolicy == infrav1.RepairPolicyRestart {
+			machineScope.Info("Restarting terminated machine instance", "instance-id", *machineScope.GetInstanceID())
+			machineScope.SetNotReady()
+			// The start in progress is polled by the next reconciles.
+			if err := computeSvc.StartInstance(machineScope); err != nil && !wait.IsTimeout(err) {
+				record.Warnf(machineScope.GCPMachine, "FailedStart", "Failed to start instance %q: %v", instance.Name, err)
+				return ctrl.Result{}, err
+			}
+			conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceRestartingReason, clusterv1.ConditionSeverityWarning,
+				"Terminated instance is being restarted")
+			result.RequeueAfter = reconciler.JitteredRequeueAfter(15*time.Second, r.RequeueJitter)
+			break
+		}
+		// A preempted instance fails its GCPMachine like any terminated instance, so that the Machine is
+		// replaced, but the reason tells the capacity was reclaimed by GCE rather than the instance broke.
+		if preemptedSinceStart(instance, scheduling) {
+			at := scheduling.LastPreemptionTime.UTC().Format(time.RFC3339)
+			conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstancePreemptedReason, clusterv1.ConditionSeverityError,
+				"Instance has been preempted at %s", at)
+			machineScope.SetFailureReason(capierrors.UpdateMachineError)
+			machineScope.SetFailureMessage(errors.Errorf("GCE instance has been preempted at %s", at))
+			record.Warnf(machineScope.GCPMachine, "InstancePreempted", "Instance %q has been preempted at %s", instance.Name, at)
+			break
+		}
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceTerminatedReason, clusterv1.ConditionSeverityError,
+			"Instance has been terminated")
+		machineScope.SetFailureReason(capierrors.UpdateMachineError)
+		machineScope.SetFailureMessage(errors.Errorf("GCE instance state %q is unexpected", instance.Status))
 	default:
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.InstanceNotRunningReason, clusterv1.ConditionSeverityError,
+			"Instance state %q is unexpected", instance.Status)
 		machineScope.SetFailureReason(capierrors.UpdateMachineError)
 		machineScope.SetFailureMessage(errors.Errorf("GCE instance state %q is unexpected", instance.Status))
 	}
 
 	if err := r.reconcileLBAttachment(machineScope, clusterScope, instance); err != nil {
+		if gcperrors.IsFingerprintMismatch(err) {
+			return r.requeueOnFingerprintMismatch(machineScope, err)
+		}
 		return ctrl.Result{}, errors.Errorf("failed to reconcile LB attachment: %+v", err)
 	}
 
-	return ctrl.Result{}, nil
+	for _, group := range machineScope.GCPMachine.Spec.AdditionalInstanceGroups {
+		if err := computeSvc.EnsureInstanceGroupMember(path.Base(instance.Zone), group, instance); err != nil {
+			record.Warnf(machineScope.GCPMachine, "FailedAddToInstanceGroup", "Failed to add instance %q to instance group %q: %v", instance.Name, group, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	for _, pool := range machineScope.GCPMachine.Spec.TargetPools {
+		if err := computeSvc.EnsureTargetPoolMember(machineScope.Region(), pool, instance); err != nil {
+			record.Warnf(machineScope.GCPMachine, "FailedAddToTargetPool", "Failed to add instance %q to target pool %q: %v", instance.Name, pool, err)
+			return ctrl.Result{}, err
+		}
+	}
+
+	return result, nil
+}
+
+// bootstrapStatusWindow is the time since the creation of an instance within which its bootstrap status is polled
+// without bootstrap timeout, the instances which don't report it being then only checked by the later reconciles.
+const bootstrapStatusWindow = time.Hour
+
+// reconcileBootstrapStatus sets the BootstrapSucceeded condition from the bootstrap status
+// reported by the instance, and returns true while the instance hasn't reported it yet.
+// The GCPMachine is failed if the instance doesn't report it within the bootstrap timeout. Without timeout, the
+// condition is unknown once the instance hasn't reported it within the bootstrap status window, as the instances
+// whose bootstrap data doesn't report it. The serial console of an instance failing to bootstrap is captured if enabled.
+func (r *GCPMachineReconciler) reconcileBootstrapStatus(ctx context.Context, machineScope *scope.MachineScope, computeSvc *compute.Service, instance *gcompute.Instance) (bool, error) {
+	// The adopted instances have been bootstrapped outside of Cluster API.
+	if machineScope.GCPMachine.Spec.ExistingInstance != nil {
+		conditions.MarkTrue(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition)
+		return false, nil
+	}
+
+	reason := conditions.GetReason(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition)
+	if conditions.Has(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition) &&
+		reason != infrav1.WaitingForBootstrapStatusReason && reason != infrav1.BootstrapStatusUnreportedReason {
+		return false, nil
+	}
+
+	status, err := computeSvc.GetBootstrapStatus(machineScope)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get the bootstrap status")
+	}
+
+	switch status {
+	case "":
+		if timedOut(instance, r.BootstrapTimeout) {
+			conditions.MarkFalse(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrapTimedOutReason, clusterv1.ConditionSeverityError,
+				"Instance has not reported its bootstrap status within %s", r.BootstrapTimeout)
+			machineScope.SetFailureReason(capierrors.CreateMachineError)
+			machineScope.SetFailureMessage(errors.Errorf("GCE instance has not reported its bootstrap status within %s", r.BootstrapTimeout))
+			record.Warnf(machineScope.GCPMachine, "BootstrapTimedOut", "Instance %q has not reported its bootstrap status within %s", instance.Name, r.BootstrapTimeout)
+			if r.CaptureSerialConsole {
+				r.captureSerialConsole(ctx, machineScope, computeSvc)
+			}
+			return false, nil
+		}
+		if r.BootstrapTimeout <= 0 && timedOut(instance, bootstrapStatusWindow) {
+			conditions.MarkUnknown(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrapStatusUnreportedReason,
+				"Instance has not reported its bootstrap status within %s", bootstrapStatusWindow)
+			return false, nil
+		}
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition, infrav1.WaitingForBootstrapStatusReason, clusterv1.ConditionSeverityInfo, "")
+		return true, nil
+	case infrav1.BootstrapStatusSuccess:
+		conditions.MarkTrue(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition)
+	default:
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.BootstrapSucceededCondition, infrav1.BootstrapFailedReason, clusterv1.ConditionSeverityError, "Instance reported bootstrap status %q", status)
+		record.Warnf(machineScope.GCPMachine, "FailedBootstrap", "Instance %q reported bootstrap status %q", machineScope.InstanceName(), status)
+		if r.CaptureSerialConsole {
+			r.captureSerialConsole(ctx, machineScope, computeSvc)
+		}
+	}
+
+	return false, nil
+}
+
+// timedOut returns true if the timeout is set and has elapsed since the creation of the instance.
+func timedOut(instance *gcompute.Instance, timeout time.Duration) bool {
+	if timeout <= 0 {
+		return false
+	}
+	created, err := time.Parse(time.RFC3339, instance.CreationTimestamp)
+	if err != nil {
+		return false
+	}
+
+	return time.Since(created) > timeout
+}
+
+// preemptedSinceStart returns true if the instance has been preempted since it was last started.
+func preemptedSinceStart(instance *gcompute.Instance, scheduling *infrav1.InstanceScheduling) bool {
+	if scheduling.ProvisioningModel == infrav1.ProvisioningModelStandard || scheduling.LastPreemptionTime == nil {
+		return false
+	}
+	started, err := time.Parse(time.RFC3339, instance.LastStartTimestamp)
+	if err != nil {
+		return true
+	}
+
+	return !scheduling.LastPreemptionTime.Time.Before(started)
 }
 
 func (r *GCPMachineReconciler) reconcileDelete(machineScope *scope.MachineScope, clusterScope *scope.ClusterScope) (_ ctrl.Result, reterr error) {
 	machineScope.Info("Handling deleted GCPMachine")
 
+	// The instances are kept along with the GCP resources of a protected cluster while the Cluster is deleted, a
+	// single Machine being deleted with its instance.
+	if _, ok := clusterScope.GCPCluster.Annotations[infrav1.DeletionProtectionAnnotation]; ok && !machineScope.Cluster.DeletionTimestamp.IsZero() {
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.InstanceReadyCondition, infrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+			"Remove the %s annotation of the GCPCluster to delete the instance", infrav1.DeletionProtectionAnnotation)
+		machineScope.Info("GCPCluster is protected from deletion")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)}, nil
+	}
+
+	// Block clusterctl move while the instance is being deleted.
+	if _, ok := machineScope.GCPMachine.Annotations[infrav1.BlockMoveAnnotation]; !ok {
+		metav1.SetMetaDataAnnotation(&machineScope.GCPMachine.ObjectMeta, infrav1.BlockMoveAnnotation, "")
+		machineScope.SetNotReady()
+		if err := machineScope.PatchObject(); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	computeSvc := compute.NewService(clusterScope)
 
+	// The instance is looked up once the operation in progress on it, e.g. its delete, completed.
+	if err := computeSvc.PollInstanceOperation(machineScope); wait.IsTimeout(err) {
+		return ctrl.Result{}, err
+	} else if err != nil {
+		machineScope.Info("Operation on the instance failed", "reason", err.Error())
+	}
+
 	instance, err := r.findInstance(machineScope, computeSvc)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -280,10 +680,48 @@ func (r *GCPMachineReconciler) reconcileDelete(machineScope *scope.MachineScope,
 		// The machine was never created or was deleted by some other entity
 		machineScope.V(3).Info("Unable to locate instance by ID or tags")
 
+		// The instance deleted by a previous reconcile may have been the last one of the group of its zone.
+		if machineScope.GetProviderID() != "" {
+			if err := r.releaseInstanceGroup(machineScope, clusterScope, computeSvc, machineScope.InstanceZone(), &gcompute.Instance{}); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		if err := computeSvc.DeleteBootstrapData(machineScope); err != nil {
+			return ctrl.Result{}, err
+		}
 		controllerutil.RemoveFinalizer(machineScope.GCPMachine, infrav1.MachineFinalizer)
 		return ctrl.Result{}, nil
 	}
 
+	// Point the providerID to the zone the instance has been found in, to delete it from there.
+	setProviderID(machineScope, clusterScope, instance)
+
+	// Let the next control plane instance take over the TargetInstance load balancer.
+	if machineScope.IsControlPlane() && clusterScope.LoadBalancerType() == infrav1.LoadBalancerTypeTargetInstance {
+		if err := computeSvc.DeregisterTargetInstance(instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// Stop sending connections to the API server endpoint of the instance before deleting it.
+	if machineScope.IsControlPlane() && clusterScope.LoadBalancerType() == infrav1.LoadBalancerTypeProxy {
+		if res, err := r.drainAPIServerBackend(machineScope, clusterScope, computeSvc, instance); err != nil || !res.IsZero() {
+			return res, err
+		}
+	}
+
+	// Stop sending the traffic of the load balancers managed outside of Cluster API to the instance before deleting it.
+	for _, group := range machineScope.GCPMachine.Spec.AdditionalInstanceGroups {
+		if err := computeSvc.RemoveInstanceGroupMember(path.Base(instance.Zone), group, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	for _, pool := range machineScope.GCPMachine.Spec.TargetPools {
+		if err := computeSvc.RemoveTargetPoolMember(machineScope.Region(), pool, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// Check the instance state. If it's already shutting down or terminated,
 	// do nothing. Otherwise attempt to delete it.
 	switch infrav1.InstanceStatus(instance.Status) {
@@ -291,12 +729,30 @@ func (r *GCPMachineReconciler) reconcileDelete(machineScope *scope.MachineScope,
 		machineScope.Info("Instance is shutting down or already terminated")
 	default:
 		machineScope.Info("Terminating instance")
-		if err := computeSvc.TerminateInstanceAndWait(machineScope); err != nil {
+		if err := computeSvc.RetainDetachedDisks(machineScope, instance); err != nil {
+			if wait.IsTimeout(err) {
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, errors.Wrapf(err, "failed to retain the disks of instance %q", instance.Name)
+		}
+		if err := computeSvc.TerminateInstance(machineScope); err != nil {
+			if wait.IsTimeout(err) {
+				return ctrl.Result{}, err
+			}
 			record.Warnf(machineScope.GCPMachine, "FailedTerminate", "Failed to terminate instance %q: %v", instance.Name, err)
 
 			return ctrl.Result{}, errors.Errorf("failed to terminate instance: %+v", err)
 		}
-		record.Eventf(machineScope.GCPMachine, "SuccessfulTerminate", "Terminated instance %q", instance.Name)
+	}
+
+	// Delete the instance group of the zone if the control plane left it.
+	if err := r.releaseInstanceGroup(machineScope, clusterScope, computeSvc, path.Base(instance.Zone), instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The bootstrap data uploaded to the bootstrap data bucket isn't needed anymore.
+	if err := computeSvc.DeleteBootstrapData(machineScope); err != nil {
+		return ctrl.Result{}, err
 	}
 
 	// Instance is deleted so remove the finalizer.
@@ -305,6 +761,69 @@ func (r *GCPMachineReconciler) reconcileDelete(machineScope *scope.MachineScope,
 	return ctrl.Result{}, nil
 }
 
+// drainAPIServerBackend removes the running instance of a deleted control plane machine from the API server load
+// balancer once another control plane instance is reported healthy by it, so that the API server stays reachable,
+// and waits for the connection draining timeout of the backend service before the instance is deleted. The progress
+// is reported by the APIServerBackendDrained condition, whose last transition time starts the draining. The instances
+// which aren't running, and those of a deleted cluster, leave the load balancer right away.
+func (r *GCPMachineReconciler) drainAPIServerBackend(machineScope *scope.MachineScope, clusterScope *scope.ClusterScope, computeSvc *compute.Service, instance *gcompute.Instance) (ctrl.Result, error) {
+	gcpMachine := machineScope.GCPMachine
+	if conditions.IsTrue(gcpMachine, infrav1.APIServerBackendDrainedCondition) {
+		return ctrl.Result{}, nil
+	}
+
+	drain := infrav1.InstanceStatus(instance.Status) == infrav1.InstanceStatusRunning && clusterScope.Cluster.DeletionTimestamp.IsZero()
+	draining := conditions.GetReason(gcpMachine, infrav1.APIServerBackendDrainedCondition) == infrav1.DrainingAPIServerConnectionsReason
+	if drain && !draining {
+		healthy, err := computeSvc.CountOtherHealthyAPIServerBackends(instance)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if healthy == 0 {
+			machineScope.Info("Waiting for another healthy API server backend before deleting the instance")
+			conditions.MarkFalse(gcpMachine, infrav1.APIServerBackendDrainedCondition, infrav1.WaitingForHealthyAPIServerBackendReason, clusterv1.ConditionSeverityWarning,
+				"no other control plane instance is reported healthy by the API server load balancer")
+
+			return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(15*time.Second, r.RequeueJitter)}, nil
+		}
+	}
+
+	zone := path.Base(instance.Zone)
+	if clusterScope.LoadBalancerBackendType() == infrav1.LoadBalancerBackendNetworkEndpointGroup {
+		if err := computeSvc.ReleaseNetworkEndpointGroup(zone, instance); err != nil {
+			return ctrl.Result{}, err
+		}
+	} else if err := r.releaseInstanceGroup(machineScope, clusterScope, computeSvc, zone, instance); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if timeout := time.Duration(clusterScope.LoadBalancerConnectionDrainingTimeout()) * time.Second; drain && timeout > 0 {
+		if !draining {
+			conditions.MarkFalse(gcpMachine, infrav1.APIServerBackendDrainedCondition, infrav1.DrainingAPIServerConnectionsReason, clusterv1.ConditionSeverityInfo,
+				"draining the connections of the API server load balancer for %s", timeout)
+
+			return ctrl.Result{RequeueAfter: timeout}, nil
+		}
+		if left := timeout - time.Since(conditions.GetLastTransitionTime(gcpMachine, infrav1.APIServerBackendDrainedCondition).Time); left > 0 {
+			return ctrl.Result{RequeueAfter: left}, nil
+		}
+	}
+	conditions.MarkTrue(gcpMachine, infrav1.APIServerBackendDrainedCondition)
+
+	return ctrl.Result{}, nil
+}
+
+// releaseInstanceGroup removes the deleted control plane instance from the API server instance group of the zone,
+// which is deleted if the control plane left it.
+func (r *GCPMachineReconciler) releaseInstanceGroup(machineScope *scope.MachineScope, clusterScope *scope.ClusterScope, computeSvc *compute.Service, zone string, instance *gcompute.Instance) error {
+	if !machineScope.IsControlPlane() || clusterScope.LoadBalancerType() != infrav1.LoadBalancerTypeProxy ||
+		clusterScope.LoadBalancerBackendType() != infrav1.LoadBalancerBackendInstanceGroup {
+		return nil
+	}
+
+	return computeSvc.ReleaseInstanceGroup(zone, instance)
+}
+
 // findInstance queries the GCP apis and retrieves the instance if it exists, returns nil otherwise.
 func (r *GCPMachineReconciler) findInstance(scope *scope.MachineScope, computeSvc *compute.Service) (*gcompute.Instance, error) {
 	instance, err := computeSvc.InstanceIfExists(scope)
@@ -316,22 +835,186 @@ func (r *GCPMachineReconciler) findInstance(scope *scope.MachineScope, computeSv
 }
 
 func (r *GCPMachineReconciler) getOrCreate(scope *scope.MachineScope, computeSvc *compute.Service) (*gcompute.Instance, error) {
+	// The instance is looked up once the operation in progress on it, e.g. its insert, completed.
+	if err := computeSvc.PollInstanceOperation(scope); err != nil {
+		if !gcperrors.IsZoneResourcePoolExhausted(err) || scope.Machine.Spec.FailureDomain != nil || !scope.GCPMachine.Spec.ZoneFallback {
+			return nil, err
+		}
+		// The instance is created in the next zones, the one it was tried in going last.
+		r.ZoneIncidents.Record(scope.GCPCluster.Spec.Project, scope.GCPMachine.Status.Zone, gcperrors.ZoneResourcePoolExhausted)
+		record.Warnf(scope.GCPMachine, "ZoneResourcePoolExhausted", "Zone %q is out of resources to create instance %q, falling back to another zone",
+			scope.GCPMachine.Status.Zone, scope.InstanceName())
+		scope.GCPMachine.Status.Zone = ""
+	}
+
 	instance, err := r.findInstance(scope, computeSvc)
 	if err != nil {
 		return nil, err
 	}
 
 	if instance == nil {
-		// Create a new GCPMachine instance if we couldn't find a running instance.
-		instance, err = computeSvc.CreateInstance(scope)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to create GCPMachine instance")
+		// An instance which has been created before must not be recreated behind the Machine's back,
+		// the Machine is failed instead.
+		if scope.GetProviderID() != "" {
+			return nil, nil
 		}
+
+		// The existing instance is adopted, never created.
+		if ref := scope.GCPMachine.Spec.ExistingInstance; ref != nil {
+			return nil, errors.Wrapf(errExistingInstanceNotFound, "instance %q in project %q", *ref, scope.GCPCluster.Spec.Project)
+		}
+
+		if r.RequireExplicitServiceAccount {
+			if err := checkExplicitServiceAccount(scope.ServiceAccount()); err != nil {
+				return nil, err
+			}
+		}
+
+		// The zones offering the accelerators of the instance are the only eligible ones.
+		var acceleratorZones []string
+		if accelerators := scope.GCPMachine.Spec.GuestAccelerators; len(accelerators) > 0 {
+			if acceleratorZones, err = computeSvc.GetAcceleratorZones(accelerators); err != nil {
+				return nil, err
+			}
+		}
+
+		if scope.Machine.Spec.FailureDomain == nil && scope.GCPMachine.Spec.ZoneFallback {
+			return r.createInFallbackZones(scope, computeSvc, acceleratorZones)
+		}
+
+		return r.create(scope, computeSvc)
+	}
+
+	return instance, nil
+}
+
+// create creates the instance of the GCPMachine in its zone.
+func (r *GCPMachineReconciler) create(scope *scope.MachineScope, computeSvc *compute.Service) (*gcompute.Instance, error) {
+	if err := computeSvc.CheckInstanceTypes(scope); err != nil {
+		return nil, err
+	}
+
+	// Create a new GCPMachine instance if we couldn't find a running instance.
+	instance, err := computeSvc.CreateInstance(scope)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to create GCPMachine instance")
 	}
 
 	return instance, nil
 }
 
+// createInFallbackZones creates the instance of a GCPMachine without failure domain in the first of the
+// fallbackZones which has the capacity and the types of the instance. The zone is recorded in the status.
+// If the instance has accelerators, only the acceleratorZones offering them are tried.
+func (r *GCPMachineReconciler) createInFallbackZones(scope *scope.MachineScope, computeSvc *compute.Service, acceleratorZones []string) (*gcompute.Instance, error) {
+	zones := r.fallbackZones(scope)
+	if acceleratorZones != nil {
+		zones = filterZones(zones, acceleratorZones)
+		if len(zones) == 0 {
+			return nil, errors.Errorf("failed to create GCPMachine instance, the cluster has no failure domain offering its accelerators, only zones %s do",
+				strings.Join(acceleratorZones, ", "))
+		}
+	}
+	if len(zones) == 0 {
+		return nil, errors.New("failed to create GCPMachine instance, the cluster has no failure domain to fall back to")
+	}
+
+	// The error of the last zone is returned as is.
+	var unavailable *compute.UnavailableInZoneError
+	for i, zone := range zones[:len(zones)-1] {
+		scope.GCPMachine.Status.Zone = zone
+		instance, err := r.create(scope, computeSvc)
+		switch {
+		case gcperrors.IsZoneResourcePoolExhausted(err):
+			r.ZoneIncidents.Record(scope.GCPCluster.Spec.Project, zone, gcperrors.ZoneResourcePoolExhausted)
+			record.Warnf(scope.GCPMachine, "ZoneResourcePoolExhausted", "Zone %q is out of resources to create instance %q, falling back to zone %q",
+				zone, scope.InstanceName(), zones[i+1])
+		case errors.As(err, &unavailable):
+			record.Warnf(scope.GCPMachine, "InstanceTypeUnavailable", "Instance %q can't be created: %v, falling back to zone %q",
+				scope.InstanceName(), err, zones[i+1])
+		default:
+			return instance, err
+		}
+	}
+	scope.GCPMachine.Status.Zone = zones[len(zones)-1]
+
+	return r.create(scope, computeSvc)
+}
+
+// fallbackZones returns the zones the instance of a GCPMachine without failure domain is tried in: the zone
+// it was last tried in, then the eligible failure domains of the cluster without recent incidents, then the others.
+func (r *GCPMachineReconciler) fallbackZones(scope *scope.MachineScope) []string {
+	zones := make([]string, 0, len(scope.GCPCluster.Status.FailureDomains))
+	for zone, fd := range scope.GCPCluster.Status.FailureDomains {
+		if scope.IsControlPlane() && !fd.ControlPlane {
+			continue
+		}
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+
+	res := make([]string, 0, len(zones))
+	if scope.GCPMachine.Status.Zone != "" {
+		res = append(res, scope.GCPMachine.Status.Zone)
+	}
+	var incidents []string
+	for _, zone := range zones {
+		switch _, recent := r.ZoneIncidents.Recent(scope.GCPCluster.Spec.Project, zone); {
+		case zone == scope.GCPMachine.Status.Zone:
+		case recent:
+			incidents = append(incidents, zone)
+		default:
+			res = append(res, zone)
+		}
+	}
+
+	return append(res, incidents...)
+}
+
+// filterZones returns the zones which are eligible, in their order.
+func filterZones(zones, eligible []string) []string {
+	res := make([]string, 0, len(zones))
+	for _, zone := range zones {
+		for _, e := range eligible {
+			if zone == e {
+				res = append(res, zone)
+				break
+			}
+		}
+	}
+
+	return res
+}
+
+// errExistingInstanceNotFound is returned when the existing instance adopted by a GCPMachine doesn't exist.
+var errExistingInstanceNotFound = errors.New("the existing instance to adopt is not found")
+
+// errDefaultServiceAccount is returned when an instance would run as the default compute service account
+// while explicit service accounts are required.
+var errDefaultServiceAccount = errors.New("the default compute service account is not allowed, set the service account of the GCPMachine or GCPMachinePool")
+
+// checkExplicitServiceAccount returns errDefaultServiceAccount if the service account is unset or
+// is the default compute service account, e.g. 123456789-compute@developer.gserviceaccount.com.
+func checkExplicitServiceAccount(serviceAccount *infrav1.ServiceAccount) error {
+	switch {
+	case serviceAccount == nil:
+		return errDefaultServiceAccount
+	case serviceAccount.Email == "", serviceAccount.Email == "default", strings.HasSuffix(serviceAccount.Email, "-compute@developer.gserviceaccount.com"):
+		return errors.Wrapf(errDefaultServiceAccount, "service account %q", serviceAccount.Email)
+	}
+
+	return nil
+}
+
+// setProviderID sets the providerID of the GCPMachine to the instance, in the zone it actually runs in.
+func setProviderID(machineScope *scope.MachineScope, clusterScope *scope.ClusterScope, instance *gcompute.Instance) {
+	zone := path.Base(instance.Zone)
+	if zone == "." {
+		zone = machineScope.Zone()
+	}
+	machineScope.SetProviderID(fmt.Sprintf("gce://%s/%s/%s", clusterScope.Project(), zone, instance.Name))
+}
+
 func (r *GCPMachineReconciler) getAddresses(instance *gcompute.Instance) []corev1.NodeAddress {
 	addresses := make([]corev1.NodeAddress, 0, len(instance.NetworkInterfaces))
 	for _, nic := range instance.NetworkInterfaces {
@@ -359,21 +1042,95 @@ func (r *GCPMachineReconciler) reconcileLBAttachment(machineScope *scope.Machine
 		return nil
 	}
 	computeSvc := compute.NewService(clusterScope)
-	groupName := fmt.Sprintf("%s-%s-%s", clusterScope.Name(), infrav1.APIServerRoleTagValue, machineScope.Zone())
+	switch clusterScope.LoadBalancerType() {
+	case infrav1.LoadBalancerTypeNone:
+		// The instances are attached to the load balancer managed outside of the provider, if any, by the user.
+		conditions.Delete(machineScope.GCPMachine, infrav1.APIServerBackendHealthyCondition)
+		return nil
+	case infrav1.LoadBalancerTypeTargetInstance:
+		conditions.Delete(machineScope.GCPMachine, infrav1.APIServerBackendHealthyCondition)
+		return computeSvc.RegisterTargetInstance(i)
+	}
 
-	// Get the instance group, or create if necessary.
-	group, err := computeSvc.GetOrCreateInstanceGroup(machineScope.Zone(), groupName)
+	// The group is the one of the zone the instance runs in, e.g. the zone of an adopted instance.
+	zone := path.Base(i.Zone)
+	group, err := registerAPIServerBackend(machineScope, clusterScope, computeSvc, zone, i)
 	if err != nil {
 		return err
 	}
 
-	// Make sure the instance is registered.
-	if err := computeSvc.EnsureInstanceGroupMember(machineScope.Zone(), group.Name, i); err != nil {
+	// Update the backend service.
+	if err := computeSvc.UpdateBackendServices(); err != nil {
 		return err
 	}
 
-	// Update the backend service.
-	return computeSvc.UpdateBackendServices()
+	health, err := computeSvc.GetAPIServerBackendHealth(group.selfLink, i)
+	if err != nil {
+		return err
+	}
+	switch health {
+	case "HEALTHY":
+		conditions.MarkTrue(machineScope.GCPMachine, infrav1.APIServerBackendHealthyCondition)
+	case "":
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.APIServerBackendHealthyCondition, infrav1.WaitingForBackendHealthReason, clusterv1.ConditionSeverityInfo, "")
+	default:
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.APIServerBackendHealthyCondition, infrav1.APIServerBackendUnhealthyReason, clusterv1.ConditionSeverityWarning,
+			"Instance health state is %q in %s %q", health, group.kind, group.name)
+	}
+
+	return nil
+}
+
+// apiServerBackend is the API server group of the zone an instance is registered in.
+type apiServerBackend struct {
+	kind     string
+	name     string
+	selfLink string
+}
+
+// registerAPIServerBackend registers the control plane instance in the API server instance group or network
+// endpoint group of the zone, creating the group if necessary.
+func registerAPIServerBackend(machineScope *scope.MachineScope, clusterScope *scope.ClusterScope, computeSvc *compute.Service, zone string, i *gcompute.Instance) (*apiServerBackend, error) {
+	if clusterScope.LoadBalancerBackendType() == infrav1.LoadBalancerBackendNetworkEndpointGroup {
+		group, err := computeSvc.GetOrCreateNetworkEndpointGroup(zone, computeSvc.APIServerNetworkEndpointGroupName(zone))
+		if err != nil {
+			return nil, err
+		}
+		if err := computeSvc.EnsureNetworkEndpoint(zone, group.Name, i); err != nil {
+			conditions.MarkFalse(machineScope.GCPMachine, infrav1.APIServerBackendHealthyCondition, infrav1.NetworkEndpointRegistrationFailedReason, clusterv1.ConditionSeverityWarning,
+				"Instance can't be attached to network endpoint group %q: %v", group.Name, err)
+			return nil, err
+		}
+
+		return &apiServerBackend{kind: "network endpoint group", name: group.Name, selfLink: group.SelfLink}, nil
+	}
+
+	// Get the instance group, or create if necessary.
+	group, err := computeSvc.GetOrCreateInstanceGroup(zone, computeSvc.APIServerInstanceGroupName(zone))
+	if err != nil {
+		return nil, err
+	}
+
+	// Make sure the instance is registered.
+	if err := computeSvc.EnsureInstanceGroupMember(zone, group.Name, i); err != nil {
+		conditions.MarkFalse(machineScope.GCPMachine, infrav1.APIServerBackendHealthyCondition, infrav1.InstanceGroupRegistrationFailedReason, clusterv1.ConditionSeverityWarning,
+			"Instance can't be registered in instance group %q: %v", group.Name, err)
+		return nil, err
+	}
+
+	return &apiServerBackend{kind: "instance group", name: group.Name, selfLink: group.SelfLink}, nil
+}
+
+// requeueOnFingerprintMismatch requeues the GCPMachine shortly if err is an update rejected because
+// the resource has changed since it was read, e.g. the backend service updated by another control plane
+// Machine, so that the update is retried on top of the current state instead of overwriting it.
+func (r *GCPMachineReconciler) requeueOnFingerprintMismatch(machineScope *scope.MachineScope, err error) (ctrl.Result, error) {
+	if !gcperrors.IsFingerprintMismatch(err) {
+		return ctrl.Result{}, err
+	}
+	machineScope.Info("Resource changed concurrently, retrying", "reason", err.Error())
+
+	return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(5*time.Second, r.RequeueJitter)}, nil
 }
 
 // GCPClusterToGCPMachines is a handler.ToRequestsFunc to be used to enqeue requests for reconciliation of GCPMachines.
@@ -17,18 +17,33 @@ limitations under the License.
 package controllers
 
 import (
+	"context"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/gomega"
+	gcompute "google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/pointer"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/compute"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
 )
 
 func newMachine(clusterName, machineName string) *clusterv1.Machine {
@@ -101,3 +116,1061 @@ func TestGCPMachineReconciler_GCPClusterToGCPMachines(t *testing.T) {
 	})
 	g.Expect(requests).To(HaveLen(2))
 }
+
+func newTestMachineScope(g *WithT, k8sClient client.Client, clusterScope *scope.ClusterScope, gcpMachine *infrav1.GCPMachine) *scope.MachineScope {
+	machine := newMachine(clusterScope.Name(), gcpMachine.Name)
+	machine.Spec.FailureDomain = pointer.StringPtr("us-central1-a")
+	machine.Spec.Bootstrap.DataSecretName = pointer.StringPtr("bootstrap-data")
+	machineScope, err := scope.NewMachineScope(scope.MachineScopeParams{
+		Client:     k8sClient,
+		Cluster:    clusterScope.Cluster,
+		Machine:    machine,
+		GCPCluster: clusterScope.GCPCluster,
+		GCPMachine: gcpMachine,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	return machineScope
+}
+
+func TestGCPMachineReconciler_reconcileInstanceState(t *testing.T) {
+	tests := []struct {
+		name      string
+		state     string
+		policy    infrav1.RepairPolicy
+		preempted bool
+		restart   bool
+		ready     bool
+		reason    string
+		failed    bool
+		requeue   bool
+	}{
+		{state: "RUNNING", ready: true},
+		{state: "STAGING", reason: infrav1.InstanceProvisioningReason, requeue: true},
+		{state: "REPAIRING", reason: infrav1.InstanceRepairingReason, requeue: true},
+		{state: "STOPPED", reason: infrav1.InstanceStoppedReason, requeue: true},
+		{state: "SUSPENDED", reason: infrav1.InstanceSuspendedReason, requeue: true},
+		{state: "TERMINATED", reason: infrav1.InstanceTerminatedReason, failed: true},
+		{name: "TERMINATED with Restart policy", state: "TERMINATED", policy: infrav1.RepairPolicyRestart, reason: infrav1.InstanceRestartingReason, requeue: true},
+		{name: "TERMINATED after preemption", state: "TERMINATED", preempted: true, reason: infrav1.InstancePreemptedReason, failed: true},
+		{name: "TERMINATED pending restart", state: "TERMINATED", restart: true, ready: true},
+	}
+	for _, tt := range tests {
+		name := tt.name
+		if name == "" {
+			name = tt.state
+		}
+		t.Run(name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fakecloud.NewCloud()
+			defer c.Close()
+			c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+				"name":   "my-machine",
+				"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+				"status": tt.state,
+			})
+			c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+			if tt.preempted {
+				c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+					"name":               "my-machine",
+					"zone":               c.SelfLink("projects/my-project/zones/us-central1-a"),
+					"status":             tt.state,
+					"scheduling":         map[string]interface{}{"preemptible": true},
+					"lastStartTimestamp": "2021-06-01T10:00:00Z",
+				})
+				c.Put("projects/my-project/zones/us-central1-a/operations/operation-system", &gcompute.Operation{
+					OperationType: "compute.instances.preempted",
+					InsertTime:    "2021-06-02T10:00:00Z",
+					TargetLink:    c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-machine"),
+				})
+			}
+
+			scheme := runtime.NewScheme()
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+			gcpCluster := newGCPCluster("my-cluster")
+			gcpMachine := &infrav1.GCPMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+				Spec:       infrav1.GCPMachineSpec{RepairPolicy: tt.policy, Preemptible: tt.preempted},
+			}
+			if tt.restart {
+				gcpMachine.Annotations = map[string]string{infrav1.PendingRestartAnnotation: ""}
+			}
+			k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+			clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+			clusterScope.Cluster.Status.InfrastructureReady = true
+			machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+			reconciler := &GCPMachineReconciler{
+				Client:            k8sClient,
+				Log:               klogr.New(),
+				ReconcilerOptions: ReconcilerOptions{Cloud: c},
+			}
+			result, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(gcpMachine.Status.Ready).To(Equal(tt.ready))
+			g.Expect(result.RequeueAfter > 0).To(Equal(tt.requeue))
+			g.Expect(gcpMachine.Status.FailureReason != nil).To(Equal(tt.failed))
+			if tt.ready {
+				g.Expect(conditions.IsTrue(gcpMachine, infrav1.InstanceReadyCondition)).To(BeTrue())
+			} else {
+				g.Expect(conditions.GetReason(gcpMachine, infrav1.InstanceReadyCondition)).To(Equal(tt.reason))
+			}
+			if tt.policy == infrav1.RepairPolicyRestart || tt.restart {
+				instance := &gcompute.Instance{}
+				g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", instance)).To(BeTrue())
+				g.Expect(instance.Status).To(Equal("RUNNING"))
+			}
+		})
+	}
+}
+
+func TestGCPMachineReconciler_reconcileDeletedInstance(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+		"name":   "my-machine",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		"status": "RUNNING",
+	})
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+	reconciler := &GCPMachineReconciler{
+		Client:                 k8sClient,
+		Log:                    klogr.New(),
+		ReconcilerOptions:      ReconcilerOptions{Cloud: c},
+		InstanceResyncInterval: time.Minute,
+	}
+
+	// The running instance is checked again after the resync interval.
+	result, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">=", time.Minute))
+	g.Expect(gcpMachine.Spec.ProviderID).To(Equal(pointer.StringPtr("gce://my-project/us-central1-a/my-machine")))
+
+	// The instance deleted outside of Cluster API fails the machine instead of being recreated.
+	c.Delete("projects/my-project/zones/us-central1-a/instances/my-machine")
+	result, err = reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeZero())
+	g.Expect(gcpMachine.Status.Ready).To(BeFalse())
+	g.Expect(gcpMachine.Status.FailureReason).NotTo(BeNil())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.InstanceReadyCondition)).To(Equal(infrav1.InstanceDeletedReason))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", nil)).To(BeFalse())
+}
+
+func TestGCPMachineReconciler_reconcileOrgPolicyViolation(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.Put("projects/my-project/zones/us-central1-a/machineTypes/n1-standard-2", &gcompute.MachineType{Name: "n1-standard-2"})
+	c.SetError(http.MethodPost, "projects/my-project/zones/us-central1-a/instances", &googleapi.Error{
+		Code:    http.StatusPreconditionFailed,
+		Message: "Constraint constraints/compute.trustedImageProjects violated for project my-project. Use of images from project my-project is prohibited.",
+	})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Status.Network.APIServerAddress = pointer.StringPtr("10.0.0.1")
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-data", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine, secret).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	result, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeZero())
+	g.Expect(gcpMachine.Status.FailureReason).NotTo(BeNil())
+	g.Expect(*gcpMachine.Status.FailureMessage).To(ContainSubstring("compute.trustedImageProjects"))
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.InstanceReadyCondition)).To(Equal(infrav1.InstanceOrgPolicyViolationReason))
+}
+
+func TestGCPMachineReconciler_reconcileUnavailableInstanceType(t *testing.T) {
+	tests := []struct {
+		name         string
+		accelerators []infrav1.Accelerator
+		// zoneMaxCards, if set, offers the accelerator type in the zone of the machine with this maximum per instance.
+		zoneMaxCards int64
+		message      string
+		reason       string
+	}{
+		{
+			name:    "machine type",
+			message: `machine type "n2-standard-2" is not available in zone "us-central1-a"`,
+			reason:  infrav1.InstanceTypeUnavailableReason,
+		},
+		{
+			name:         "accelerator type",
+			accelerators: []infrav1.Accelerator{{Type: "nvidia-tesla-a100", Count: 1}},
+			message:      `accelerator type "nvidia-tesla-a100" is not available in zone "us-central1-a"`,
+			reason:       infrav1.InstanceTypeUnavailableReason,
+		},
+		{
+			name:         "accelerator count in zone",
+			accelerators: []infrav1.Accelerator{{Type: "nvidia-tesla-a100", Count: 8}},
+			zoneMaxCards: 4,
+			message:      `accelerator count "8 x nvidia-tesla-a100" is not available in zone "us-central1-a"`,
+			reason:       infrav1.InstanceTypeUnavailableReason,
+		},
+		{
+			name:         "accelerator count",
+			accelerators: []infrav1.Accelerator{{Type: "nvidia-tesla-a100", Count: 16}},
+			message:      `no zone of region "us-central1" offers the accelerators 16 x nvidia-tesla-a100`,
+			reason:       infrav1.AcceleratorsUnavailableReason,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fakecloud.NewCloud()
+			defer c.Close()
+			if tt.accelerators != nil {
+				c.Put("projects/my-project/zones/us-central1-a/machineTypes/n2-standard-2", &gcompute.MachineType{Name: "n2-standard-2"})
+				// The accelerator type is only offered in another zone of the region.
+				c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b")
+				c.Put("projects/my-project/zones/us-central1-b/acceleratorTypes/nvidia-tesla-a100", &gcompute.AcceleratorType{
+					Name:                    "nvidia-tesla-a100",
+					Zone:                    c.SelfLink("projects/my-project/zones/us-central1-b"),
+					MaximumCardsPerInstance: 8,
+				})
+			}
+			if tt.zoneMaxCards > 0 {
+				c.Put("projects/my-project/zones/us-central1-a/acceleratorTypes/nvidia-tesla-a100", &gcompute.AcceleratorType{
+					Name:                    "nvidia-tesla-a100",
+					Zone:                    c.SelfLink("projects/my-project/zones/us-central1-a"),
+					MaximumCardsPerInstance: tt.zoneMaxCards,
+				})
+			}
+
+			scheme := runtime.NewScheme()
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+			gcpCluster := newGCPCluster("my-cluster")
+			gcpCluster.Status.Network.APIServerAddress = pointer.StringPtr("10.0.0.1")
+			gcpMachine := &infrav1.GCPMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+				Spec: infrav1.GCPMachineSpec{
+					InstanceType:      "n2-standard-2",
+					Image:             pointer.StringPtr("my-image"),
+					GuestAccelerators: tt.accelerators,
+				},
+			}
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-data", Namespace: "default"},
+				Data:       map[string][]byte{"value": []byte("#cloud-config")},
+			}
+			k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine, secret).Build()
+			clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+			clusterScope.Cluster.Status.InfrastructureReady = true
+			machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+			reconciler := &GCPMachineReconciler{
+				Client:            k8sClient,
+				Log:               klogr.New(),
+				ReconcilerOptions: ReconcilerOptions{Cloud: c},
+			}
+			result, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(result.RequeueAfter).To(BeZero())
+			g.Expect(gcpMachine.Status.FailureReason).NotTo(BeNil())
+			g.Expect(*gcpMachine.Status.FailureMessage).To(Equal(tt.message))
+			g.Expect(conditions.GetReason(gcpMachine, infrav1.InstanceReadyCondition)).To(Equal(tt.reason))
+			g.Expect(c.List("projects/my-project/zones/us-central1-a/instances")).To(BeEmpty())
+		})
+	}
+}
+
+func TestGCPMachineReconciler_reconcileZoneFallback(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	for _, zone := range []string{"us-central1-a", "us-central1-b", "us-central1-c"} {
+		c.Put("projects/my-project/zones/"+zone+"/machineTypes/n1-standard-2", &gcompute.MachineType{Name: "n1-standard-2"})
+	}
+	c.SetOperationError(http.MethodPost, "projects/my-project/zones/us-central1-a/instances", "ZONE_RESOURCE_POOL_EXHAUSTED")
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Status.Network.APIServerAddress = pointer.StringPtr("10.0.0.1")
+	gcpCluster.Status.FailureDomains = clusterv1.FailureDomains{
+		"us-central1-a": clusterv1.FailureDomainSpec{},
+		"us-central1-b": clusterv1.FailureDomainSpec{},
+		"us-central1-c": clusterv1.FailureDomainSpec{},
+	}
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			Image:        pointer.StringPtr("my-image"),
+			ZoneFallback: true,
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-data", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine, secret).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	machineScope.Machine.Spec.FailureDomain = nil
+
+	// The zone with a recent incident is tried last.
+	incidents := cloud.NewZoneIncidents(time.Hour)
+	incidents.Record("my-project", "us-central1-b", "ZONE_RESOURCE_POOL_EXHAUSTED")
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+		ZoneIncidents:     incidents,
+	}
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpMachine.Status.FailureReason).To(BeNil())
+	g.Expect(gcpMachine.Status.Zone).To(Equal("us-central1-c"))
+	g.Expect(machineScope.Zone()).To(Equal("us-central1-c"))
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instances")).To(BeEmpty())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-c/instances/my-machine", nil)).To(BeTrue())
+
+	_, recent := incidents.Recent("my-project", "us-central1-a")
+	g.Expect(recent).To(BeTrue())
+}
+
+func TestGCPMachineReconciler_reconcileInstanceOperation(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	for _, zone := range []string{"us-central1-a", "us-central1-b"} {
+		c.Put("projects/my-project/zones/"+zone+"/machineTypes/n1-standard-2", &gcompute.MachineType{Name: "n1-standard-2"})
+	}
+	c.Put("projects/my-project/zones/us-central1-a/operations/my-insert", &gcompute.Operation{
+		Name:          "my-insert",
+		OperationType: "insert",
+		Zone:          c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Status:        "RUNNING",
+	})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Status.Network.APIServerAddress = pointer.StringPtr("10.0.0.1")
+	gcpCluster.Status.FailureDomains = clusterv1.FailureDomains{
+		"us-central1-a": clusterv1.FailureDomainSpec{},
+		"us-central1-b": clusterv1.FailureDomainSpec{},
+	}
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType: "n1-standard-2",
+			Image:        pointer.StringPtr("my-image"),
+			ZoneFallback: true,
+		},
+		Status: infrav1.GCPMachineStatus{
+			Zone:      "us-central1-a",
+			Operation: c.SelfLink("projects/my-project/zones/us-central1-a/operations/my-insert"),
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-data", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine, secret).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	machineScope.Machine.Spec.FailureDomain = nil
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+		ZoneIncidents:     cloud.NewZoneIncidents(time.Hour),
+	}
+
+	// The insert in progress is polled by the next reconciles instead of being waited for.
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(wait.IsTimeout(err)).To(BeTrue())
+	g.Expect(gcpMachine.Status.Operation).NotTo(BeEmpty())
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/instances")).To(BeEmpty())
+
+	// The instance is created in the next zone once the insert failed for lack of resources.
+	c.Put("projects/my-project/zones/us-central1-a/operations/my-insert", &gcompute.Operation{
+		Name:          "my-insert",
+		OperationType: "insert",
+		Zone:          c.SelfLink("projects/my-project/zones/us-central1-a"),
+		Status:        "DONE",
+		Error: &gcompute.OperationError{Errors: []*gcompute.OperationErrorErrors{
+			{Code: "ZONE_RESOURCE_POOL_EXHAUSTED", Message: "The zone does not have enough resources"},
+		}},
+	})
+	_, err = reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpMachine.Status.Operation).To(BeEmpty())
+	g.Expect(gcpMachine.Status.Zone).To(Equal("us-central1-b"))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-b/instances/my-machine", nil)).To(BeTrue())
+
+	_, recent := reconciler.ZoneIncidents.Recent("my-project", "us-central1-a")
+	g.Expect(recent).To(BeTrue())
+}
+
+func TestGCPMachineReconciler_reconcileAcceleratorZoneFallback(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a", "us-central1-b", "us-central1-c")
+	for _, zone := range []string{"us-central1-a", "us-central1-b", "us-central1-c"} {
+		c.Put("projects/my-project/zones/"+zone+"/machineTypes/n1-standard-2", &gcompute.MachineType{Name: "n1-standard-2"})
+	}
+	c.Put("projects/my-project/zones/us-central1-c/acceleratorTypes/nvidia-tesla-t4", &gcompute.AcceleratorType{
+		Name:                    "nvidia-tesla-t4",
+		Zone:                    c.SelfLink("projects/my-project/zones/us-central1-c"),
+		MaximumCardsPerInstance: 4,
+	})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Status.Network.APIServerAddress = pointer.StringPtr("10.0.0.1")
+	gcpCluster.Status.FailureDomains = clusterv1.FailureDomains{
+		"us-central1-a": clusterv1.FailureDomainSpec{},
+		"us-central1-b": clusterv1.FailureDomainSpec{},
+		"us-central1-c": clusterv1.FailureDomainSpec{},
+	}
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			InstanceType:      "n1-standard-2",
+			Image:             pointer.StringPtr("my-image"),
+			ZoneFallback:      true,
+			GuestAccelerators: []infrav1.Accelerator{{Type: "nvidia-tesla-t4", Count: 2}},
+		},
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bootstrap-data", Namespace: "default"},
+		Data:       map[string][]byte{"value": []byte("#cloud-config")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine, secret).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	machineScope.Machine.Spec.FailureDomain = nil
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+		ZoneIncidents:     cloud.NewZoneIncidents(time.Hour),
+	}
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpMachine.Status.FailureReason).To(BeNil())
+	g.Expect(gcpMachine.Status.Zone).To(Equal("us-central1-c"))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-c/instances/my-machine", nil)).To(BeTrue())
+}
+
+func TestGCPMachineReconciler_reconcileAdditionalInstanceGroups(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+		"name":   "my-machine",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		"status": "RUNNING",
+	})
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+	c.Put("projects/my-project/zones/us-central1-a/instanceGroups/my-ingress", &gcompute.InstanceGroup{Name: "my-ingress"})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{AdditionalInstanceGroups: []string{"my-ingress"}},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	group := &gcompute.InstanceGroup{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instanceGroups/my-ingress", group)).To(BeTrue())
+	g.Expect(group.Size).To(Equal(int64(1)))
+
+	// The instance is added once.
+	_, err = reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instanceGroups/my-ingress", group)).To(BeTrue())
+	g.Expect(group.Size).To(Equal(int64(1)))
+
+	// The instance groups must exist.
+	gcpMachine.Spec.AdditionalInstanceGroups = []string{"my-missing-group"}
+	_, err = reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).To(HaveOccurred())
+}
+
+func TestGCPMachineReconciler_reconcileTargetPools(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+		"name":   "my-machine",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		"status": "RUNNING",
+	})
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+	c.Put("projects/my-project/zones/us-central1-a/instanceGroups/my-ingress", &gcompute.InstanceGroup{Name: "my-ingress"})
+	c.Put("projects/my-project/regions/us-central1/targetPools/my-pool", &gcompute.TargetPool{Name: "my-pool"})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec: infrav1.GCPMachineSpec{
+			AdditionalInstanceGroups: []string{"my-ingress"},
+			TargetPools:              []string{"my-pool", "my-deleted-pool"},
+		},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+
+	// The target pools must exist.
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).To(HaveOccurred())
+
+	gcpMachine.Spec.TargetPools = []string{"my-pool"}
+	_, err = reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	pool := &gcompute.TargetPool{}
+	g.Expect(c.Get("projects/my-project/regions/us-central1/targetPools/my-pool", pool)).To(BeTrue())
+	g.Expect(pool.Instances).To(ConsistOf(c.SelfLink("projects/my-project/zones/us-central1-a/instances/my-machine")))
+
+	// The instance is removed from the target pools and the instance groups before it's deleted, the ones
+	// deleted in the meantime being ignored.
+	gcpMachine.Spec.TargetPools = []string{"my-pool", "my-deleted-pool"}
+	_, err = reconciler.reconcileDelete(machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get("projects/my-project/regions/us-central1/targetPools/my-pool", pool)).To(BeTrue())
+	g.Expect(pool.Instances).To(BeEmpty())
+	group := &gcompute.InstanceGroup{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instanceGroups/my-ingress", group)).To(BeTrue())
+	g.Expect(group.Size).To(Equal(int64(0)))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", nil)).To(BeFalse())
+}
+
+func TestGCPMachineReconciler_reconcileAPIServerBackendHealth(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a")
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+		"name":   "my-machine",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		"status": "RUNNING",
+		"health": "UNHEALTHY",
+	})
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	computeSvc := compute.NewService(clusterScope)
+	g.Expect(computeSvc.ReconcileNetwork()).To(Succeed())
+	g.Expect(computeSvc.ReconcileLoadbalancers()).To(Succeed())
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	machineScope.Machine.Labels[clusterv1.MachineControlPlaneLabelName] = ""
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.IsFalse(gcpMachine, infrav1.APIServerBackendHealthyCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.APIServerBackendHealthyCondition)).To(Equal(infrav1.APIServerBackendUnhealthyReason))
+
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+		"name":   "my-machine",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		"status": "RUNNING",
+	})
+	_, err = reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.IsTrue(gcpMachine, infrav1.APIServerBackendHealthyCondition)).To(BeTrue())
+}
+
+func TestGCPMachineReconciler_reconcileDeleteDrainsAPIServerBackend(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.AddRegion("my-project", "us-central1", "us-central1-a")
+	for _, name := range []string{"my-machine", "other-machine"} {
+		c.Put("projects/my-project/zones/us-central1-a/instances/"+name, map[string]interface{}{
+			"name":   name,
+			"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+			"status": "RUNNING",
+		})
+	}
+	c.Put("projects/my-project/zones/us-central1-a/instances/other-machine", map[string]interface{}{
+		"name":   "other-machine",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		"status": "RUNNING",
+		"health": "UNHEALTHY",
+	})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpCluster.Spec.LoadBalancer.ConnectionDrainingTimeoutSec = pointer.Int64Ptr(30)
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default", Finalizers: []string{infrav1.MachineFinalizer}},
+		Spec:       infrav1.GCPMachineSpec{ProviderID: pointer.StringPtr("gce://my-project/us-central1-a/my-machine")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	computeSvc := compute.NewService(clusterScope)
+	g.Expect(computeSvc.ReconcileNetwork()).To(Succeed())
+	g.Expect(computeSvc.ReconcileLoadbalancers()).To(Succeed())
+	group, err := computeSvc.GetOrCreateInstanceGroup("us-central1-a", computeSvc.APIServerInstanceGroupName("us-central1-a"))
+	g.Expect(err).NotTo(HaveOccurred())
+	for _, name := range []string{"my-machine", "other-machine"} {
+		instance := &gcompute.Instance{SelfLink: c.SelfLink("projects/my-project/zones/us-central1-a/instances/" + name)}
+		g.Expect(computeSvc.EnsureInstanceGroupMember("us-central1-a", group.Name, instance)).To(Succeed())
+	}
+	g.Expect(computeSvc.UpdateBackendServices()).To(Succeed())
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	machineScope.Machine.Labels[clusterv1.MachineControlPlaneLabelName] = ""
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+
+	// The instance stays in the load balancer while no other control plane instance is healthy.
+	result, err := reconciler.reconcileDelete(machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(BeNumerically(">", 0))
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.APIServerBackendDrainedCondition)).To(Equal(infrav1.WaitingForHealthyAPIServerBackendReason))
+	members, err := computeSvc.GetInstanceGroupMembers("us-central1-a", group.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(members).To(HaveLen(2))
+
+	// Once another instance is healthy, the instance leaves the load balancer and its connections are drained.
+	c.Put("projects/my-project/zones/us-central1-a/instances/other-machine", map[string]interface{}{
+		"name":   "other-machine",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+		"status": "RUNNING",
+	})
+	result, err = reconciler.reconcileDelete(machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.RequeueAfter).To(Equal(30 * time.Second))
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.APIServerBackendDrainedCondition)).To(Equal(infrav1.DrainingAPIServerConnectionsReason))
+	members, err = computeSvc.GetInstanceGroupMembers("us-central1-a", group.Name)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(members).To(HaveLen(1))
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", nil)).To(BeTrue())
+
+	// The instance is deleted once the connection draining timeout elapsed.
+	for i := range gcpMachine.Status.Conditions {
+		if gcpMachine.Status.Conditions[i].Type == infrav1.APIServerBackendDrainedCondition {
+			gcpMachine.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-time.Minute))
+		}
+	}
+	result, err = reconciler.reconcileDelete(machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(result.IsZero()).To(BeTrue())
+	g.Expect(conditions.IsTrue(gcpMachine, infrav1.APIServerBackendDrainedCondition)).To(BeTrue())
+	g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", nil)).To(BeFalse())
+	g.Expect(gcpMachine.Finalizers).To(BeEmpty())
+}
+
+func TestGCPMachineReconciler_reconcileDeleteProtected(t *testing.T) {
+	tests := []struct {
+		name           string
+		clusterDeleted bool
+		protected      bool
+		wantInstance   bool
+	}{
+		{name: "machine deleted"},
+		{name: "protected machine deleted", protected: true},
+		{name: "cluster deleted", clusterDeleted: true},
+		{name: "protected cluster deleted", clusterDeleted: true, protected: true, wantInstance: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fakecloud.NewCloud()
+			defer c.Close()
+			c.AddRegion("my-project", "us-central1", "us-central1-a")
+			c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{
+				"name":   "my-machine",
+				"zone":   c.SelfLink("projects/my-project/zones/us-central1-a"),
+				"status": "RUNNING",
+			})
+
+			scheme := runtime.NewScheme()
+			g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+			gcpCluster := newGCPCluster("my-cluster")
+			if tt.protected {
+				gcpCluster.Annotations = map[string]string{infrav1.DeletionProtectionAnnotation: ""}
+			}
+			gcpMachine := &infrav1.GCPMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default", Finalizers: []string{infrav1.MachineFinalizer}},
+				Spec:       infrav1.GCPMachineSpec{ProviderID: pointer.StringPtr("gce://my-project/us-central1-a/my-machine")},
+			}
+			k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+			clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+			if tt.clusterDeleted {
+				now := metav1.Now()
+				clusterScope.Cluster.DeletionTimestamp = &now
+			}
+			machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+			reconciler := &GCPMachineReconciler{
+				Client:            k8sClient,
+				Log:               klogr.New(),
+				ReconcilerOptions: ReconcilerOptions{Cloud: c},
+			}
+			_, err := reconciler.reconcileDelete(machineScope, clusterScope)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(c.Get("projects/my-project/zones/us-central1-a/instances/my-machine", nil)).To(Equal(tt.wantInstance))
+			if tt.wantInstance {
+				g.Expect(gcpMachine.Finalizers).To(ContainElement(infrav1.MachineFinalizer))
+				g.Expect(conditions.GetReason(gcpMachine, infrav1.InstanceReadyCondition)).To(Equal(infrav1.DeletionProtectedReason))
+			}
+		})
+	}
+}
+
+func TestGCPMachineReconciler_reconcileWaitingForBootstrapData(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{InstanceType: "n1-standard-2", Image: pointer.StringPtr("my-image")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	machineScope.Machine.Spec.Bootstrap.DataSecretName = nil
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.BootstrapDataReadyCondition)).To(Equal(infrav1.WaitingForBootstrapDataReason))
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instances")).To(BeEmpty())
+
+	// The Ready condition summarizes the bootstrap data before the instance is created.
+	g.Expect(machineScope.PatchObject()).To(Succeed())
+	g.Expect(conditions.GetReason(gcpMachine, clusterv1.ReadyCondition)).To(Equal(infrav1.WaitingForBootstrapDataReason))
+}
+
+func TestGCPMachineReconciler_reconcileExistingInstance(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.Put("projects/my-project/zones/us-central1-b/instances/hand-built", map[string]interface{}{
+		"name":   "hand-built",
+		"zone":   c.SelfLink("projects/my-project/zones/us-central1-b"),
+		"status": "RUNNING",
+		"tags":   map[string]interface{}{"items": []interface{}{"legacy"}},
+	})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{ExistingInstance: pointer.StringPtr("gce://my-project/us-central1-b/hand-built")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	clusterScope.Cluster.Status.InfrastructureReady = true
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	machineScope.Machine.Spec.Bootstrap.DataSecretName = nil
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+
+	// The instance is adopted, with the network tags and labels of the GCPMachine, without checking its bootstrap.
+	_, err := reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpMachine.Spec.ProviderID).To(Equal(pointer.StringPtr("gce://my-project/us-central1-b/hand-built")))
+	g.Expect(gcpMachine.Status.Ready).To(BeTrue())
+	g.Expect(conditions.IsTrue(gcpMachine, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+	g.Expect(conditions.IsTrue(gcpMachine, infrav1.BootstrapDataReadyCondition)).To(BeTrue())
+	instance := &gcompute.Instance{}
+	g.Expect(c.Get("projects/my-project/zones/us-central1-b/instances/hand-built", instance)).To(BeTrue())
+	g.Expect(instance.Tags.Items).To(ConsistOf("legacy", "my-cluster-node", "my-cluster"))
+	g.Expect(instance.Labels).To(HaveKeyWithValue(infrav1.ClusterTagKey("my-cluster"), string(infrav1.ResourceLifecycleOwned)))
+	g.Expect(c.List("projects/my-project/zones/us-central1-a/instances")).To(BeEmpty())
+
+	// A missing instance isn't created, the Machine is failed.
+	gcpMachine = &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-other-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{ExistingInstance: pointer.StringPtr("projects/my-project/zones/us-central1-b/instances/missing")},
+	}
+	g.Expect(k8sClient.Create(context.TODO(), gcpMachine)).To(Succeed())
+	machineScope = newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+	_, err = reconciler.reconcile(context.TODO(), machineScope, clusterScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(gcpMachine.Status.FailureReason).NotTo(BeNil())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.InstanceReadyCondition)).To(Equal(infrav1.ExistingInstanceNotFoundReason))
+	g.Expect(c.List("projects/my-project/zones/us-central1-b/instances")).To(HaveLen(1))
+}
+
+func TestCheckExplicitServiceAccount(t *testing.T) {
+	g := NewWithT(t)
+
+	g.Expect(checkExplicitServiceAccount(nil)).To(MatchError(errDefaultServiceAccount))
+	g.Expect(checkExplicitServiceAccount(&infrav1.ServiceAccount{Email: "default"})).To(HaveOccurred())
+	g.Expect(checkExplicitServiceAccount(&infrav1.ServiceAccount{Email: "123456789-compute@developer.gserviceaccount.com"})).To(HaveOccurred())
+	g.Expect(checkExplicitServiceAccount(&infrav1.ServiceAccount{Email: "my-nodes@my-project.iam.gserviceaccount.com"})).To(Succeed())
+}
+
+func TestGCPMachineReconciler_reconcileBootstrapStatus(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{"name": "my-machine"})
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+	reconciler := &GCPMachineReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	computeSvc := compute.NewService(clusterScope)
+	instance := &gcompute.Instance{Name: "my-machine", CreationTimestamp: time.Now().Add(-45 * time.Minute).Format(time.RFC3339)}
+
+	pending, err := reconciler.reconcileBootstrapStatus(context.TODO(), machineScope, computeSvc, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeTrue())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.WaitingForBootstrapStatusReason))
+
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, "failed")
+	pending, err = reconciler.reconcileBootstrapStatus(context.TODO(), machineScope, computeSvc, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(conditions.IsFalse(gcpMachine, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapFailedReason))
+
+	gcpMachine.Status.Conditions = nil
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+	pending, err = reconciler.reconcileBootstrapStatus(context.TODO(), machineScope, computeSvc, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(conditions.IsTrue(gcpMachine, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+
+	// The instance doesn't report its bootstrap status within the timeout.
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{"name": "my-machine"})
+	gcpMachine.Status.Conditions = nil
+	reconciler.BootstrapTimeout = 30 * time.Minute
+	pending, err = reconciler.reconcileBootstrapStatus(context.TODO(), machineScope, computeSvc, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapTimedOutReason))
+	g.Expect(gcpMachine.Status.FailureReason).NotTo(BeNil())
+	g.Expect(gcpMachine.Status.FailureMessage).NotTo(BeNil())
+
+	// Without timeout, the bootstrap status is no longer polled once the window has elapsed.
+	gcpMachine.Status.Conditions = nil
+	gcpMachine.Status.FailureReason = nil
+	gcpMachine.Status.FailureMessage = nil
+	reconciler.BootstrapTimeout = 0
+	instance.CreationTimestamp = time.Now().Add(-2 * time.Hour).Format(time.RFC3339)
+	pending, err = reconciler.reconcileBootstrapStatus(context.TODO(), machineScope, computeSvc, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(conditions.IsUnknown(gcpMachine, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.BootstrapSucceededCondition)).To(Equal(infrav1.BootstrapStatusUnreportedReason))
+	g.Expect(gcpMachine.Status.FailureReason).To(BeNil())
+
+	// The status reported later is still picked up by the next reconciles.
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, infrav1.BootstrapStatusSuccess)
+	pending, err = reconciler.reconcileBootstrapStatus(context.TODO(), machineScope, computeSvc, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(pending).To(BeFalse())
+	g.Expect(conditions.IsTrue(gcpMachine, infrav1.BootstrapSucceededCondition)).To(BeTrue())
+}
+
+func TestGCPMachineReconciler_captureSerialConsole(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	c.Put("projects/my-project/zones/us-central1-a/instances/my-machine", map[string]interface{}{"name": "my-machine"})
+	c.SetSerialPortOutput("projects/my-project/zones/us-central1-a/instances/my-machine", "booting\n"+strings.Repeat("cloud-init: running\n", maxSerialConsoleBytes/10))
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+
+	gcpCluster := newGCPCluster("my-cluster")
+	gcpMachine := &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"}}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(gcpCluster, gcpMachine).Build()
+	clusterScope := newTestClusterScope(g, c, k8sClient, gcpCluster)
+	machineScope := newTestMachineScope(g, k8sClient, clusterScope, gcpMachine)
+
+	reconciler := &GCPMachineReconciler{
+		Client:               k8sClient,
+		Log:                  klogr.New(),
+		ReconcilerOptions:    ReconcilerOptions{Cloud: c},
+		CaptureSerialConsole: true,
+	}
+	computeSvc := compute.NewService(clusterScope)
+	instance := &gcompute.Instance{Name: "my-machine", CreationTimestamp: time.Now().Add(-time.Hour).Format(time.RFC3339)}
+	c.SetGuestAttribute("projects/my-project/zones/us-central1-a/instances/my-machine", infrav1.BootstrapStatusGuestAttribute, "failed")
+	_, err := reconciler.reconcileBootstrapStatus(context.TODO(), machineScope, computeSvc, instance)
+	g.Expect(err).NotTo(HaveOccurred())
+
+	// The tail of the output is kept, starting at a line.
+	configMap := &corev1.ConfigMap{}
+	g.Expect(k8sClient.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "my-machine-serial-console"}, configMap)).To(Succeed())
+	output := configMap.Data[serialConsoleConfigMapKey]
+	g.Expect(len(output)).To(BeNumerically("<=", maxSerialConsoleBytes))
+	g.Expect(output).To(HavePrefix("cloud-init: running\n"))
+	g.Expect(output).NotTo(ContainSubstring("booting"))
+	g.Expect(configMap.OwnerReferences).To(HaveLen(1))
+	g.Expect(configMap.OwnerReferences[0].Name).To(Equal("my-machine"))
+}
+
+func TestTimedOut(t *testing.T) {
+	g := NewWithT(t)
+
+	instance := &gcompute.Instance{CreationTimestamp: time.Now().Add(-10 * time.Minute).Format(time.RFC3339)}
+	g.Expect(timedOut(instance, 0)).To(BeFalse())
+	g.Expect(timedOut(instance, 5*time.Minute)).To(BeTrue())
+	g.Expect(timedOut(instance, 15*time.Minute)).To(BeFalse())
+	g.Expect(timedOut(&gcompute.Instance{}, 5*time.Minute)).To(BeFalse())
+}
+
+func TestIsPriorityGCPMachine(t *testing.T) {
+	g := NewWithT(t)
+
+	gcpMachine := &infrav1.GCPMachine{ObjectMeta: metav1.ObjectMeta{Name: "my-machine", Namespace: "default"}}
+	g.Expect(isPriorityGCPMachine(gcpMachine)).To(BeFalse())
+
+	gcpMachine.Labels = map[string]string{clusterv1.MachineControlPlaneLabelName: ""}
+	g.Expect(isPriorityGCPMachine(gcpMachine)).To(BeTrue())
+
+	gcpMachine.Labels = nil
+	gcpMachine.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	g.Expect(isPriorityGCPMachine(gcpMachine)).To(BeTrue())
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/record"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/compute"
+)
+
+const (
+	// serialConsoleConfigMapSuffix is the suffix of the name of the ConfigMaps holding the serial console
+	// output of the instances which failed to bootstrap.
+	serialConsoleConfigMapSuffix = "-serial-console"
+
+	// serialConsoleConfigMapKey is the key of the ConfigMaps data holding the serial console output.
+	serialConsoleConfigMapKey = "serial-console.log"
+
+	// maxSerialConsoleBytes is the size of the tail of the serial console output kept in a ConfigMap, enough
+	// for the cloud-init and kubeadm logs of the end of the bootstrap, well under the size limit of the ConfigMaps.
+	maxSerialConsoleBytes = 64 * 1024
+)
+
+// captureSerialConsole writes the tail of the serial console output of the instance which failed to bootstrap to
+// the serial console ConfigMap of the GCPMachine, owned by it, so that the bootstrap can be debugged without access
+// to the GCP project. The capture is best effort, its failure is only reported by an event.
+func (r *GCPMachineReconciler) captureSerialConsole(ctx context.Context, machineScope *scope.MachineScope, computeSvc *compute.Service) {
+	gcpMachine := machineScope.GCPMachine
+	output, err := computeSvc.GetSerialPortOutput(machineScope)
+	if err == nil {
+		err = r.writeSerialConsole(ctx, gcpMachine, serialConsoleTail(output))
+	}
+	if err != nil {
+		record.Warnf(gcpMachine, "FailedSerialConsoleCapture", "Failed to capture the serial console of instance %q: %v", machineScope.InstanceName(), err)
+		return
+	}
+
+	record.Eventf(gcpMachine, "SerialConsoleCaptured", "Captured the serial console of instance %q to ConfigMap %s", machineScope.InstanceName(), gcpMachine.Name+serialConsoleConfigMapSuffix)
+}
+
+// writeSerialConsole creates or updates the serial console ConfigMap of the GCPMachine.
+func (r *GCPMachineReconciler) writeSerialConsole(ctx context.Context, gcpMachine *infrav1.GCPMachine, output string) error {
+	key := types.NamespacedName{Namespace: gcpMachine.Namespace, Name: gcpMachine.Name + serialConsoleConfigMapSuffix}
+	configMap := &corev1.ConfigMap{}
+	err := r.Client.Get(ctx, key, configMap)
+	switch {
+	case apierrors.IsNotFound(err):
+		configMap = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: key.Namespace,
+				Name:      key.Name,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: gcpMachine.Labels[clusterv1.ClusterLabelName]},
+				OwnerReferences: []metav1.OwnerReference{
+					*metav1.NewControllerRef(gcpMachine, infrav1.GroupVersion.WithKind("GCPMachine")),
+				},
+			},
+			Data: map[string]string{serialConsoleConfigMapKey: output},
+		}
+		if err := r.Client.Create(ctx, configMap); err != nil {
+			return errors.Wrapf(err, "failed to create serial console ConfigMap %s", key)
+		}
+	case err != nil:
+		return errors.Wrapf(err, "failed to get serial console ConfigMap %s", key)
+	default:
+		configMap.Data = map[string]string{serialConsoleConfigMapKey: output}
+		if err := r.Client.Update(ctx, configMap); err != nil {
+			return errors.Wrapf(err, "failed to update serial console ConfigMap %s", key)
+		}
+	}
+
+	return nil
+}
+
+// serialConsoleTail returns the last maxSerialConsoleBytes of the serial console output, starting at a line.
+func serialConsoleTail(output string) string {
+	if len(output) <= maxSerialConsoleBytes {
+		return output
+	}
+	tail := output[len(output)-maxSerialConsoleBytes:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	}
+
+	return tail
+}
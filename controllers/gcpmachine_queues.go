@@ -0,0 +1,59 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+// machineQueue reconciles the GCPMachines of one of the queues of the GCPMachine controller. Both queues
+// receive every event, the GCPMachines being dispatched when they are reconciled, so that a GCPMachine
+// moves to the priority queue as soon as it's being deleted.
+type machineQueue struct {
+	*GCPMachineReconciler
+
+	// priority is true for the queue of the control plane and deleting GCPMachines.
+	priority bool
+}
+
+// Reconcile reconciles the GCPMachine if it belongs to the queue.
+func (q *machineQueue) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	gcpMachine := &infrav1.GCPMachine{}
+	if err := q.Get(ctx, req.NamespacedName, gcpMachine); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if isPriorityGCPMachine(gcpMachine) != q.priority {
+		return ctrl.Result{}, nil
+	}
+
+	return q.GCPMachineReconciler.Reconcile(ctx, req)
+}
+
+// isPriorityGCPMachine returns true if the GCPMachine is reconciled from the priority queue, i.e. it's
+// being deleted or belongs to the control plane, whose recovery matters most to the cluster. The control
+// plane GCPMachines are recognized by the control plane label the control plane providers set on them.
+func isPriorityGCPMachine(gcpMachine *infrav1.GCPMachine) bool {
+	_, controlPlane := gcpMachine.Labels[clusterv1.MachineControlPlaneLabelName]
+
+	return !gcpMachine.DeletionTimestamp.IsZero() || controlPlane
+}
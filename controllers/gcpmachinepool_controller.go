@@ -0,0 +1,281 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	exputil "sigs.k8s.io/cluster-api/exp/util"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/compute"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+// GCPMachinePoolReconciler reconciles a GCPMachinePool object.
+type GCPMachinePoolReconciler struct {
+	client.Client
+	Log              logr.Logger
+	ReconcileTimeout time.Duration
+	WatchFilterValue string
+
+	ReconcilerOptions
+
+	// Cache caches the GCP lookups across the reconciles, nothing is cached if nil.
+	Cache *cloud.LookupCache
+
+	// RequireExplicitServiceAccount prevents the creation of instances running as the default compute
+	// service account, the GCPMachinePools have to set the service account of their instances.
+	RequireExplicitServiceAccount bool
+
+	// GoogleAccess is the way the GCP APIs are reached, defaults to the public access.
+	GoogleAccess cloud.GoogleAccess
+}
+
+func (r *GCPMachinePoolReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	log := r.Log.WithValues("controller", "GCPMachinePool")
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&expinfrav1.GCPMachinePool{}).
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Watches(
+			&source.Kind{Type: &expclusterv1.MachinePool{}},
+			handler.EnqueueRequestsFromMapFunc(exputil.MachinePoolToInfrastructureMapFunc(expinfrav1.GroupVersion.WithKind("GCPMachinePool"), log)),
+		).
+		Build(r)
+	if err != nil {
+		return errors.Wrap(err, "error creating controller")
+	}
+
+	gcpMachinePoolMapper, err := util.ClusterToObjectsMapper(r.Client, &expinfrav1.GCPMachinePoolList{}, mgr.GetScheme())
+	if err != nil {
+		return errors.Wrap(err, "failed to create mapper for Cluster to GCPMachinePools")
+	}
+
+	// Add a watch on clusterv1.Cluster object for unpause & ready notifications.
+	if err := c.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		handler.EnqueueRequestsFromMapFunc(gcpMachinePoolMapper),
+		predicates.ClusterUnpausedAndInfrastructureReady(log),
+	); err != nil {
+		return errors.Wrap(err, "failed adding a watch for ready clusters")
+	}
+
+	return nil
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmachinepools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmachinepools/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch
+
+func (r *GCPMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	ctx, cancel := context.WithTimeout(ctx, reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
+	defer cancel()
+	log := r.Log.WithValues("namespace", req.Namespace, "gcpMachinePool", req.Name)
+
+	pool := &expinfrav1.GCPMachinePool{}
+	if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	machinePool, err := exputil.GetOwnerMachinePool(ctx, r.Client, pool.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if machinePool == nil {
+		log.Info("MachinePool Controller has not yet set OwnerRef")
+		return ctrl.Result{}, nil
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machinePool.ObjectMeta)
+	if err != nil {
+		log.Info("MachinePool is missing cluster label or cluster does not exist")
+		return ctrl.Result{}, nil
+	}
+
+	// The cluster is reconciled by another replica.
+	if !r.Shard.Owns(cluster.Namespace, cluster.Name) {
+		return ctrl.Result{}, nil
+	}
+
+	if annotations.IsPaused(cluster, pool) {
+		log.Info("GCPMachinePool or linked Cluster is marked as paused. Won't reconcile")
+		return ctrl.Result{}, nil
+	}
+
+	log = log.WithValues("cluster", cluster.Name, "machinePool", machinePool.Name)
+
+	if cluster.Spec.InfrastructureRef == nil {
+		log.Info("Cluster has no GCPCluster yet")
+		return ctrl.Result{}, nil
+	}
+	gcpCluster := &infrav1.GCPCluster{}
+	if err := r.Get(ctx, client.ObjectKey{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}, gcpCluster); err != nil {
+		log.Info("GCPCluster is not available yet")
+		return ctrl.Result{}, nil
+	}
+
+	var dryRun *cloud.DryRun
+	if r.DryRun || isDryRun(pool, gcpCluster) {
+		dryRun = &cloud.DryRun{}
+		defer reportDryRun(log, pool, dryRun)
+	}
+
+	clusterScope, err := scope.NewClusterScope(scope.ClusterScopeParams{
+		Cloud:      r.Cloud,
+		Cache:      r.Cache,
+		Stats:      r.Stats,
+		Audit:      r.Audit,
+		DryRun:     dryRun,
+		Client:     r.Client,
+		Logger:     log,
+		Cluster:    cluster,
+		GCPCluster: gcpCluster,
+
+		FaultInjection: r.FaultInjection,
+		RateLimiter:    r.RateLimiter,
+		GoogleAccess:   r.GoogleAccess,
+
+		// The GCPCluster isn't persisted, the operations recorded in its status would be lost. The operations
+		// on the managed instance group are recorded in the status of the GCPMachinePool instead.
+		WaitForOperations: true,
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	machinePoolScope, err := scope.NewMachinePoolScope(scope.MachinePoolScopeParams{
+		DryRun:         dryRun,
+		Client:         r.Client,
+		Logger:         log,
+		Cluster:        cluster,
+		MachinePool:    machinePool,
+		GCPCluster:     gcpCluster,
+		GCPMachinePool: pool,
+	})
+	if err != nil {
+		return ctrl.Result{}, errors.Errorf("failed to create scope: %+v", err)
+	}
+
+	// Always close the scope when exiting this function so we can persist any GCPMachinePool changes.
+	defer func() {
+		if err := machinePoolScope.Close(); err != nil && reterr == nil {
+			reterr = err
+		}
+	}()
+
+	if !pool.DeletionTimestamp.IsZero() {
+		res, err := r.reconcileDelete(machinePoolScope, clusterScope)
+		return requeueOnOperationTimeout(log, res, err, r.RequeueJitter)
+	}
+
+	res, err := r.reconcile(machinePoolScope, clusterScope)
+	return requeueOnOperationTimeout(log, res, err, r.RequeueJitter)
+}
+
+func (r *GCPMachinePoolReconciler) reconcile(machinePoolScope *scope.MachinePoolScope, clusterScope *scope.ClusterScope) (ctrl.Result, error) {
+	machinePoolScope.Info("Reconciling GCPMachinePool")
+
+	pool := machinePoolScope.GCPMachinePool
+
+	// Register the finalizer immediately to avoid orphaning the managed instance group on delete.
+	controllerutil.AddFinalizer(pool, expinfrav1.MachinePoolFinalizer)
+
+	if !machinePoolScope.Cluster.Status.InfrastructureReady {
+		machinePoolScope.Info("Cluster infrastructure is not ready yet")
+		return ctrl.Result{}, nil
+	}
+
+	if machinePoolScope.MachinePool.Spec.Template.Spec.Bootstrap.DataSecretName == nil {
+		conditions.MarkFalse(pool, expinfrav1.InstanceGroupReadyCondition, expinfrav1.WaitingForBootstrapDataReason, clusterv1.ConditionSeverityInfo, "")
+		machinePoolScope.Info("Bootstrap data secret reference is not yet available")
+		return ctrl.Result{}, nil
+	}
+
+	if r.RequireExplicitServiceAccount {
+		if err := checkExplicitServiceAccount(machinePoolScope.ServiceAccount()); err != nil {
+			conditions.MarkFalse(pool, expinfrav1.InstanceGroupReadyCondition, infrav1.DefaultServiceAccountNotAllowedReason, clusterv1.ConditionSeverityError,
+				"%v", err)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	ready, err := compute.NewService(clusterScope).ReconcileMachinePool(machinePoolScope)
+	if wait.IsTimeout(err) {
+		return ctrl.Result{}, err
+	}
+	if err != nil {
+		conditions.MarkFalse(pool, expinfrav1.InstanceGroupReadyCondition, expinfrav1.InstanceGroupProvisionFailedReason, clusterv1.ConditionSeverityError, "%v", err)
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile managed instance group for GCPMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+	pool.Status.Ready = ready
+	if !ready {
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)}, nil
+	}
+
+	// The instances of the group change on their own, e.g. when they are repaired or preempted.
+	return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)}, nil
+}
+
+func (r *GCPMachinePoolReconciler) reconcileDelete(machinePoolScope *scope.MachinePoolScope, clusterScope *scope.ClusterScope) (ctrl.Result, error) {
+	machinePoolScope.Info("Reconciling Delete GCPMachinePool")
+
+	pool := machinePoolScope.GCPMachinePool
+
+	// The managed instance groups are kept along with the GCP resources of a protected cluster while the Cluster is
+	// deleted, a single MachinePool being deleted with its group.
+	if _, ok := clusterScope.GCPCluster.Annotations[infrav1.DeletionProtectionAnnotation]; ok && !machinePoolScope.Cluster.DeletionTimestamp.IsZero() {
+		conditions.MarkFalse(pool, expinfrav1.InstanceGroupReadyCondition, infrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+			"Remove the %s annotation of the GCPCluster to delete the managed instance group", infrav1.DeletionProtectionAnnotation)
+		machinePoolScope.Info("GCPCluster is protected from deletion")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)}, nil
+	}
+
+	pool.Status.Ready = false
+	if err := compute.NewService(clusterScope).DeleteMachinePool(machinePoolScope); err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to delete managed instance group for GCPMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+
+	controllerutil.RemoveFinalizer(pool, expinfrav1.MachinePoolFinalizer)
+
+	return ctrl.Result{}, nil
+}
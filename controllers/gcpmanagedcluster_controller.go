@@ -0,0 +1,208 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/container"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+// GCPManagedClusterReconciler reconciles a GCPManagedCluster object.
+type GCPManagedClusterReconciler struct {
+	client.Client
+	Log              logr.Logger
+	ReconcileTimeout time.Duration
+	WatchFilterValue string
+
+	ReconcilerOptions
+}
+
+func (r *GCPManagedClusterReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	log := r.Log.WithValues("controller", "GCPManagedCluster")
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&expinfrav1.GCPManagedCluster{}).
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Watches(
+			&source.Kind{Type: &expinfrav1.GCPManagedControlPlane{}},
+			handler.EnqueueRequestsFromMapFunc(r.GCPManagedControlPlaneToGCPManagedCluster),
+		).
+		Build(r)
+	if err != nil {
+		return errors.Wrap(err, "error creating controller")
+	}
+
+	if err = c.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		handler.EnqueueRequestsFromMapFunc(util.ClusterToInfrastructureMapFunc(expinfrav1.GroupVersion.WithKind("GCPManagedCluster"))),
+		predicates.ClusterUnpaused(log),
+	); err != nil {
+		return errors.Wrap(err, "failed adding a watch for ready clusters")
+	}
+
+	return nil
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedclusters,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedclusters/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status,verbs=get;list;watch
+
+func (r *GCPManagedClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	ctx, cancel := context.WithTimeout(ctx, reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
+	defer cancel()
+	log := r.Log.WithValues("namespace", req.Namespace, "gcpManagedCluster", req.Name)
+
+	gcpManagedCluster := &expinfrav1.GCPManagedCluster{}
+	if err := r.Get(ctx, req.NamespacedName, gcpManagedCluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	cluster, err := util.GetOwnerCluster(ctx, r.Client, gcpManagedCluster.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cluster == nil {
+		log.Info("Cluster Controller has not yet set OwnerRef")
+		return ctrl.Result{}, nil
+	}
+
+	// The cluster is reconciled by another replica.
+	if !r.Shard.Owns(cluster.Namespace, cluster.Name) {
+		return ctrl.Result{}, nil
+	}
+
+	if annotations.IsPaused(cluster, gcpManagedCluster) {
+		log.Info("GCPManagedCluster or linked Cluster is marked as paused. Won't reconcile")
+		return ctrl.Result{}, nil
+	}
+
+	log = log.WithValues("cluster", cluster.Name)
+
+	var dryRun *cloud.DryRun
+	if r.DryRun || isDryRun(gcpManagedCluster) {
+		dryRun = &cloud.DryRun{}
+		defer reportDryRun(log, gcpManagedCluster, dryRun)
+	}
+
+	clusterScope, err := scope.NewManagedClusterScope(scope.ManagedClusterScopeParams{
+		Cloud:             r.Cloud,
+		Client:            r.Client,
+		Logger:            log,
+		Cluster:           cluster,
+		GCPManagedCluster: gcpManagedCluster,
+		ManagedTransports: scope.ManagedTransports{
+			DryRun:         dryRun,
+			Stats:          r.Stats,
+			Audit:          r.Audit,
+			FaultInjection: r.FaultInjection,
+			RateLimiter:    r.RateLimiter,
+		},
+	})
+	if err != nil {
+		return ctrl.Result{}, errors.Errorf("failed to create scope: %+v", err)
+	}
+
+	// Always close the scope when exiting this function so we can persist any GCPManagedCluster changes.
+	defer func() {
+		if err := clusterScope.Close(); err != nil && reterr == nil {
+			reterr = err
+		}
+	}()
+
+	// The GKE cluster is deleted with the GCPManagedControlPlane, the network isn't owned by the cluster.
+	if !gcpManagedCluster.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	return r.reconcile(ctx, clusterScope)
+}
+
+func (r *GCPManagedClusterReconciler) reconcile(ctx context.Context, clusterScope *scope.ManagedClusterScope) (ctrl.Result, error) {
+	clusterScope.Info("Reconciling GCPManagedCluster")
+
+	gcpManagedCluster := clusterScope.GCPManagedCluster
+
+	failureDomains, err := container.NewNetworkService(clusterScope).ReconcileNetwork()
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile network for GCPManagedCluster %s/%s", gcpManagedCluster.Namespace, gcpManagedCluster.Name)
+	}
+	gcpManagedCluster.Status.FailureDomains = failureDomains
+	gcpManagedCluster.Status.Ready = true
+
+	// The endpoint of the cluster is the endpoint of the GKE cluster, known once the control plane is created.
+	ref := clusterScope.Cluster.Spec.ControlPlaneRef
+	if ref == nil || ref.Kind != "GCPManagedControlPlane" {
+		return ctrl.Result{}, nil
+	}
+	controlPlane := &expinfrav1.GCPManagedControlPlane{}
+	if err := r.Get(ctx, types.NamespacedName{Namespace: gcpManagedCluster.Namespace, Name: ref.Name}, controlPlane); err != nil {
+		if apierrors.IsNotFound(err) {
+			clusterScope.Info("Waiting for GCPManagedControlPlane")
+			return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(15*time.Second, r.RequeueJitter)}, nil
+		}
+		return ctrl.Result{}, errors.Wrapf(err, "failed to get GCPManagedControlPlane %s", ref.Name)
+	}
+	if !controlPlane.Spec.ControlPlaneEndpoint.IsValid() {
+		clusterScope.Info("Waiting for the endpoint of the GKE cluster")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)}, nil
+	}
+	gcpManagedCluster.Spec.ControlPlaneEndpoint = controlPlane.Spec.ControlPlaneEndpoint
+
+	return ctrl.Result{}, nil
+}
+
+// GCPManagedControlPlaneToGCPManagedCluster is a handler.ToRequestsFunc to be used to enqueue requests for
+// the GCPManagedCluster of the cluster of a GCPManagedControlPlane, once its endpoint is known.
+func (r *GCPManagedClusterReconciler) GCPManagedControlPlaneToGCPManagedCluster(o client.Object) []ctrl.Request {
+	controlPlane, ok := o.(*expinfrav1.GCPManagedControlPlane)
+	if !ok {
+		r.Log.Error(errors.Errorf("expected a GCPManagedControlPlane but got a %T", o), "failed to get GCPManagedCluster for GCPManagedControlPlane")
+		return nil
+	}
+
+	cluster, err := util.GetOwnerCluster(context.TODO(), r.Client, controlPlane.ObjectMeta)
+	if err != nil || cluster == nil || cluster.Spec.InfrastructureRef == nil {
+		return nil
+	}
+
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}}}
+}
@@ -0,0 +1,430 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+	gke "google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientcmdv1 "k8s.io/client-go/tools/clientcmd/api/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/kubeconfig"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	"sigs.k8s.io/cluster-api/util/secret"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/container"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+// minKubeconfigRefreshInterval is the minimum interval at which the kubeconfig of the GKE clusters is regenerated,
+// once the access token it holds, if any, is renewed.
+const minKubeconfigRefreshInterval = 30 * time.Second
+
+// GCPManagedControlPlaneReconciler reconciles a GCPManagedControlPlane object.
+type GCPManagedControlPlaneReconciler struct {
+	client.Client
+	Log              logr.Logger
+	ReconcileTimeout time.Duration
+	WatchFilterValue string
+
+	ReconcilerOptions
+
+	// NewTokenSource returns the token source of the access tokens of the kubeconfigServiceAccount of a GKE cluster,
+	// e.g. cloud.GKETokenSource. The kubeconfigs are written again once the tokens expire, which the token source is
+	// expected to report before they actually do. Without it, no kubeconfig is written and the control planes are
+	// never ready, the Cluster API controllers having no credentials to reach the clusters.
+	NewTokenSource func(serviceAccount string) (oauth2.TokenSource, error)
+
+	// ManagerServiceAccount is the service account of the manager, if known, whose credentials are never written
+	// in the kubeconfigs, readable by the users of the clusters.
+	ManagerServiceAccount string
+
+	// tokenSources caches the token sources by service account, so that their tokens are reused until renewed.
+	tokenSources   map[string]oauth2.TokenSource
+	tokenSourcesMu sync.Mutex
+}
+
+func (r *GCPManagedControlPlaneReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	log := r.Log.WithValues("controller", "GCPManagedControlPlane")
+
+	c, err := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&expinfrav1.GCPManagedControlPlane{}).
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Watches(
+			&source.Kind{Type: &expinfrav1.GCPManagedMachinePool{}},
+			handler.EnqueueRequestsFromMapFunc(r.GCPManagedMachinePoolToGCPManagedControlPlane),
+		).
+		Build(r)
+	if err != nil {
+		return errors.Wrap(err, "error creating controller")
+	}
+
+	if err = c.Watch(
+		&source.Kind{Type: &clusterv1.Cluster{}},
+		handler.EnqueueRequestsFromMapFunc(clusterToControlPlane),
+		predicates.ClusterUnpausedAndInfrastructureReady(log),
+	); err != nil {
+		return errors.Wrap(err, "failed adding a watch for ready clusters")
+	}
+
+	return nil
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedcontrolplanes,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedcontrolplanes/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=clusters;clusters/status;machinepools,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update
+
+func (r *GCPManagedControlPlaneReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	ctx, cancel := context.WithTimeout(ctx, reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
+	defer cancel()
+	log := r.Log.WithValues("namespace", req.Namespace, "gcpManagedControlPlane", req.Name)
+
+	controlPlane := &expinfrav1.GCPManagedControlPlane{}
+	if err := r.Get(ctx, req.NamespacedName, controlPlane); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	cluster, err := util.GetOwnerCluster(ctx, r.Client, controlPlane.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if cluster == nil {
+		log.Info("Cluster Controller has not yet set OwnerRef")
+		return ctrl.Result{}, nil
+	}
+
+	// The cluster is reconciled by another replica.
+	if !r.Shard.Owns(cluster.Namespace, cluster.Name) {
+		return ctrl.Result{}, nil
+	}
+
+	if annotations.IsPaused(cluster, controlPlane) {
+		log.Info("GCPManagedControlPlane or linked Cluster is marked as paused. Won't reconcile")
+		return ctrl.Result{}, nil
+	}
+
+	log = log.WithValues("cluster", cluster.Name)
+
+	if cluster.Spec.InfrastructureRef == nil {
+		log.Info("Cluster has no infrastructure yet")
+		return ctrl.Result{}, nil
+	}
+	gcpManagedCluster := &expinfrav1.GCPManagedCluster{}
+	key := types.NamespacedName{Namespace: cluster.Namespace, Name: cluster.Spec.InfrastructureRef.Name}
+	if err := r.Get(ctx, key, gcpManagedCluster); err != nil {
+		if !apierrors.IsNotFound(err) || controlPlane.DeletionTimestamp.IsZero() {
+			return ctrl.Result{}, errors.Wrapf(err, "failed to get GCPManagedCluster %s", key)
+		}
+		// The GKE cluster is still deleted, in the project and the location it was created in.
+		if gcpManagedCluster = deletedManagedCluster(controlPlane); gcpManagedCluster == nil {
+			log.Info("GCPManagedCluster is gone and no GKE cluster was created, removing the finalizer")
+			controllerutil.RemoveFinalizer(controlPlane, expinfrav1.ManagedControlPlaneFinalizer)
+			return ctrl.Result{}, r.Update(ctx, controlPlane)
+		}
+	}
+
+	var dryRun *cloud.DryRun
+	if r.DryRun || isDryRun(controlPlane, gcpManagedCluster) {
+		dryRun = &cloud.DryRun{}
+		defer reportDryRun(log, controlPlane, dryRun)
+	}
+
+	controlPlaneScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
+		Cloud:                  r.Cloud,
+		Client:                 r.Client,
+		Logger:                 log,
+		Cluster:                cluster,
+		GCPManagedCluster:      gcpManagedCluster,
+		GCPManagedControlPlane: controlPlane,
+		ManagedTransports: scope.ManagedTransports{
+			DryRun:         dryRun,
+			Stats:          r.Stats,
+			Audit:          r.Audit,
+			FaultInjection: r.FaultInjection,
+			RateLimiter:    r.RateLimiter,
+		},
+	})
+	if err != nil {
+		return ctrl.Result{}, errors.Errorf("failed to create scope: %+v", err)
+	}
+
+	// Always close the scope when exiting this function so we can persist any GCPManagedControlPlane changes.
+	defer func() {
+		if err := controlPlaneScope.Close(); err != nil && reterr == nil {
+			reterr = err
+		}
+	}()
+
+	if !controlPlane.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(controlPlaneScope)
+	}
+
+	return r.reconcile(ctx, controlPlaneScope)
+}
+
+func (r *GCPManagedControlPlaneReconciler) reconcile(ctx context.Context, controlPlaneScope *scope.ManagedControlPlaneScope) (ctrl.Result, error) {
+	controlPlaneScope.Info("Reconciling GCPManagedControlPlane")
+
+	controlPlane := controlPlaneScope.GCPManagedControlPlane
+
+	// Register the finalizer immediately to avoid orphaning the GKE cluster on delete.
+	controllerutil.AddFinalizer(controlPlane, expinfrav1.ManagedControlPlaneFinalizer)
+
+	if !controlPlaneScope.Cluster.Status.InfrastructureReady {
+		controlPlaneScope.Info("Cluster infrastructure is not ready yet")
+		return ctrl.Result{}, nil
+	}
+
+	// The name is recorded once, the GKE cluster keeps it even if the GCPManagedCluster is changed afterwards.
+	if controlPlane.Status.ClusterFullName == "" {
+		controlPlane.Status.ClusterFullName = controlPlaneScope.ClusterFullName()
+	}
+	gkeCluster, err := container.NewClusterService(controlPlaneScope).ReconcileCluster(ctx)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile GKE cluster for GCPManagedControlPlane %s/%s", controlPlane.Namespace, controlPlane.Name)
+	}
+	if gkeCluster == nil {
+		controlPlaneScope.Info("Waiting for the GKE cluster")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)}, nil
+	}
+
+	controlPlane.Spec.ControlPlaneEndpoint = clusterv1.APIEndpoint{Host: gkeCluster.Endpoint, Port: 443}
+	// The kubeconfig Secret isn't written in dry-run mode.
+	if controlPlaneScope.DryRun() != nil {
+		return ctrl.Result{}, nil
+	}
+	serviceAccount := controlPlane.Spec.KubeconfigServiceAccount
+	switch {
+	case serviceAccount == "" || r.NewTokenSource == nil:
+		conditions.MarkFalse(controlPlane, expinfrav1.KubeconfigReadyCondition, expinfrav1.KubeconfigCredentialsMissingReason, clusterv1.ConditionSeverityError,
+			"The GKE cluster has no service account for its kubeconfig, set spec.kubeconfigServiceAccount")
+		controlPlaneScope.Info("No kubeconfig credentials, set spec.kubeconfigServiceAccount")
+		return ctrl.Result{}, nil
+	case serviceAccount == r.ManagerServiceAccount:
+		conditions.MarkFalse(controlPlane, expinfrav1.KubeconfigReadyCondition, expinfrav1.KubeconfigCredentialsMissingReason, clusterv1.ConditionSeverityError,
+			"The kubeconfig service account %s is the service account of the manager", serviceAccount)
+		controlPlaneScope.Info("The kubeconfig service account is the service account of the manager", "serviceAccount", serviceAccount)
+		return ctrl.Result{}, nil
+	}
+	tokenSource, err := r.tokenSource(serviceAccount)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	expiry, err := r.reconcileKubeconfig(ctx, controlPlaneScope, gkeCluster, tokenSource)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile kubeconfig for GCPManagedControlPlane %s/%s", controlPlane.Namespace, controlPlane.Name)
+	}
+	conditions.MarkTrue(controlPlane, expinfrav1.KubeconfigReadyCondition)
+	controlPlane.Status.Initialized = true
+	controlPlane.Status.Ready = true
+
+	// The access token of the kubeconfig is replaced once the token source renews it, before it actually expires.
+	requeueAfter := time.Until(expiry)
+	if requeueAfter < minKubeconfigRefreshInterval {
+		requeueAfter = minKubeconfigRefreshInterval
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// tokenSource returns the token source of the service account, created once.
+func (r *GCPManagedControlPlaneReconciler) tokenSource(serviceAccount string) (oauth2.TokenSource, error) {
+	r.tokenSourcesMu.Lock()
+	defer r.tokenSourcesMu.Unlock()
+
+	if ts, ok := r.tokenSources[serviceAccount]; ok {
+		return ts, nil
+	}
+	ts, err := r.NewTokenSource(serviceAccount)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to impersonate the kubeconfig service account %s", serviceAccount)
+	}
+	if r.tokenSources == nil {
+		r.tokenSources = map[string]oauth2.TokenSource{}
+	}
+	r.tokenSources[serviceAccount] = ts
+
+	return ts, nil
+}
+
+// reconcileKubeconfig writes the kubeconfig Secret of the cluster, authenticating with an access token of the
+// token source, and returns when the token is to be renewed. The Secret is only updated when its content changes,
+// i.e. once the token is renewed.
+func (r *GCPManagedControlPlaneReconciler) reconcileKubeconfig(ctx context.Context, controlPlaneScope *scope.ManagedControlPlaneScope, gkeCluster *gke.Cluster, tokenSource oauth2.TokenSource) (time.Time, error) {
+	cluster := controlPlaneScope.Cluster
+
+	if gkeCluster.MasterAuth == nil {
+		return time.Time{}, errors.New("the GKE cluster has no CA certificate yet")
+	}
+	ca, err := base64.StdEncoding.DecodeString(gkeCluster.MasterAuth.ClusterCaCertificate)
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to decode the CA certificate of the GKE cluster")
+	}
+	token, err := tokenSource.Token()
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to get an access token")
+	}
+
+	// The kubeconfig is written in JSON, a subset of YAML.
+	name := cluster.Name
+	data, err := json.Marshal(clientcmdv1.Config{
+		Kind:       "Config",
+		APIVersion: clientcmdv1.SchemeGroupVersion.Version,
+		Clusters: []clientcmdv1.NamedCluster{
+			{Name: name, Cluster: clientcmdv1.Cluster{Server: "https://" + gkeCluster.Endpoint, CertificateAuthorityData: ca}},
+		},
+		AuthInfos: []clientcmdv1.NamedAuthInfo{
+			{Name: name, AuthInfo: clientcmdv1.AuthInfo{Token: token.AccessToken}},
+		},
+		Contexts: []clientcmdv1.NamedContext{
+			{Name: name, Context: clientcmdv1.Context{Cluster: name, AuthInfo: name}},
+		},
+		CurrentContext: name,
+	})
+	if err != nil {
+		return time.Time{}, errors.Wrap(err, "failed to serialize the kubeconfig")
+	}
+
+	controlPlane := controlPlaneScope.GCPManagedControlPlane
+	owner := metav1.OwnerReference{
+		APIVersion: expinfrav1.GroupVersion.String(),
+		Kind:       "GCPManagedControlPlane",
+		Name:       controlPlane.Name,
+		UID:        controlPlane.UID,
+	}
+	desired := kubeconfig.GenerateSecretWithOwner(util.ObjectKey(cluster), data, owner)
+
+	existing := &corev1.Secret{}
+	err = r.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: secret.Name(cluster.Name, secret.Kubeconfig)}, existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		return token.Expiry, r.Create(ctx, desired)
+	case err != nil:
+		return time.Time{}, err
+	case reflect.DeepEqual(existing.Data, desired.Data):
+		return token.Expiry, nil
+	}
+	existing.Data = desired.Data
+
+	return token.Expiry, r.Update(ctx, existing)
+}
+
+func (r *GCPManagedControlPlaneReconciler) reconcileDelete(controlPlaneScope *scope.ManagedControlPlaneScope) (ctrl.Result, error) {
+	controlPlaneScope.Info("Reconciling Delete GCPManagedControlPlane")
+
+	controlPlane := controlPlaneScope.GCPManagedControlPlane
+	controlPlane.Status.Ready = false
+
+	// The webhook rejects the deletion of a protected GKE cluster, which may have been annotated since.
+	if _, ok := controlPlane.Annotations[infrav1.DeletionProtectionAnnotation]; ok {
+		conditions.MarkFalse(controlPlane, expinfrav1.GKEControlPlaneReadyCondition, expinfrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+			"Remove the %s annotation to delete the GKE cluster", infrav1.DeletionProtectionAnnotation)
+		controlPlaneScope.Info("GKE cluster is protected from deletion")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)}, nil
+	}
+
+	deleted, err := container.NewClusterService(controlPlaneScope).DeleteCluster()
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to delete GKE cluster for GCPManagedControlPlane %s/%s", controlPlane.Namespace, controlPlane.Name)
+	}
+	if !deleted {
+		controlPlaneScope.Info("Waiting for the GKE cluster to be deleted")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)}, nil
+	}
+
+	controllerutil.RemoveFinalizer(controlPlane, expinfrav1.ManagedControlPlaneFinalizer)
+
+	return ctrl.Result{}, nil
+}
+
+// deletedManagedCluster returns a stand-in for the deleted GCPManagedCluster of the control plane, with the project
+// and the location of its GKE cluster, or nil if no GKE cluster was created.
+func deletedManagedCluster(controlPlane *expinfrav1.GCPManagedControlPlane) *expinfrav1.GCPManagedCluster {
+	parts := strings.Split(controlPlane.Status.ClusterFullName, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "clusters" {
+		return nil
+	}
+
+	return &expinfrav1.GCPManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Namespace: controlPlane.Namespace},
+		Spec:       expinfrav1.GCPManagedClusterSpec{Project: parts[1], Region: parts[3]},
+	}
+}
+
+// GCPManagedMachinePoolToGCPManagedControlPlane is a handler.ToRequestsFunc to be used to enqueue requests for
+// the GCPManagedControlPlane of the cluster of a GCPManagedMachinePool, a standard GKE cluster being created with
+// its node pools.
+func (r *GCPManagedControlPlaneReconciler) GCPManagedMachinePoolToGCPManagedControlPlane(o client.Object) []ctrl.Request {
+	clusterName, ok := o.GetLabels()[clusterv1.ClusterLabelName]
+	if !ok {
+		return nil
+	}
+
+	cluster, err := util.GetClusterByName(context.TODO(), r.Client, o.GetNamespace(), clusterName)
+	if err != nil {
+		return nil
+	}
+
+	return clusterToControlPlane(cluster)
+}
+
+// clusterToControlPlane is a handler.ToRequestsFunc to be used to enqueue requests for the GCPManagedControlPlane
+// of a Cluster.
+func clusterToControlPlane(o client.Object) []ctrl.Request {
+	cluster, ok := o.(*clusterv1.Cluster)
+	if !ok {
+		return nil
+	}
+	ref := cluster.Spec.ControlPlaneRef
+	if ref == nil || ref.Kind != "GCPManagedControlPlane" {
+		return nil
+	}
+
+	return []ctrl.Request{{NamespacedName: types.NamespacedName{Namespace: cluster.Namespace, Name: ref.Name}}}
+}
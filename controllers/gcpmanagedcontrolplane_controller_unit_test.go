@@ -0,0 +1,287 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+	"golang.org/x/oauth2"
+	gke "google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog/v2/klogr"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+func TestGCPManagedControlPlaneReconciler_reconcile(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+
+	cluster := newCluster("my-cluster")
+	cluster.Status.InfrastructureReady = true
+	gcpManagedCluster := &expinfrav1.GCPManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+		Spec:       expinfrav1.GCPManagedClusterSpec{Project: "my-project", Region: "us-central1"},
+	}
+	controlPlane := &expinfrav1.GCPManagedControlPlane{
+		ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default", UID: "uid"},
+		Spec:       expinfrav1.GCPManagedControlPlaneSpec{EnableAutopilot: true},
+	}
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expclusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expinfrav1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(controlPlane).Build()
+
+	controlPlaneScope, err := scope.NewManagedControlPlaneScope(scope.ManagedControlPlaneScopeParams{
+		Cloud:                  c,
+		Client:                 k8sClient,
+		Cluster:                cluster,
+		GCPManagedCluster:      gcpManagedCluster,
+		GCPManagedControlPlane: controlPlane,
+	})
+	g.Expect(err).NotTo(HaveOccurred())
+
+	r := &GCPManagedControlPlaneReconciler{
+		Client: k8sClient,
+		Log:    klogr.New(),
+	}
+
+	// The GKE cluster is being created.
+	res, err := r.reconcile(context.TODO(), controlPlaneScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(Equal(30 * time.Second))
+	g.Expect(controlPlane.Status.Ready).To(BeFalse())
+	g.Expect(controlPlane.Finalizers).To(ConsistOf(expinfrav1.ManagedControlPlaneFinalizer))
+	g.Expect(controlPlane.Status.ClusterFullName).To(Equal("projects/my-project/locations/us-central1/clusters/my-cluster"))
+
+	// Without a service account for the kubeconfig, the control plane isn't ready. The GKE cluster keeps the name
+	// recorded in the status, even if the spec is changed bypassing the webhook.
+	controlPlane.Spec.ClusterName = "renamed"
+	res, err = r.reconcile(context.TODO(), controlPlaneScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(BeZero())
+	g.Expect(controlPlane.Status.ClusterFullName).To(Equal("projects/my-project/locations/us-central1/clusters/my-cluster"))
+	g.Expect(c.Get("projects/my-project/locations/us-central1/clusters/renamed", nil)).To(BeFalse())
+	g.Expect(controlPlane.Status.Ready).To(BeFalse())
+	g.Expect(conditions.GetReason(controlPlane, expinfrav1.KubeconfigReadyCondition)).To(Equal(expinfrav1.KubeconfigCredentialsMissingReason))
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "my-cluster-kubeconfig"}, &corev1.Secret{})).NotTo(Succeed())
+
+	// The service account of the manager is never written in the kubeconfig.
+	tokens := &testTokenSource{token: &oauth2.Token{AccessToken: "my-token", Expiry: time.Now().Add(50 * time.Minute)}}
+	var impersonated []string
+	r.NewTokenSource = func(serviceAccount string) (oauth2.TokenSource, error) {
+		impersonated = append(impersonated, serviceAccount)
+		return tokens, nil
+	}
+	r.ManagerServiceAccount = "capg@my-project.iam.gserviceaccount.com"
+	controlPlane.Spec.KubeconfigServiceAccount = "capg@my-project.iam.gserviceaccount.com"
+	res, err = r.reconcile(context.TODO(), controlPlaneScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(BeZero())
+	g.Expect(controlPlane.Status.Ready).To(BeFalse())
+	g.Expect(conditions.GetReason(controlPlane, expinfrav1.KubeconfigReadyCondition)).To(Equal(expinfrav1.KubeconfigCredentialsMissingReason))
+	g.Expect(impersonated).To(BeEmpty())
+
+	// The kubeconfig authenticates with the tokens of the service account of the cluster, refreshed before they expire.
+	controlPlane.Spec.KubeconfigServiceAccount = "my-cluster@my-project.iam.gserviceaccount.com"
+	res, err = r.reconcile(context.TODO(), controlPlaneScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(BeNumerically("~", 50*time.Minute, time.Minute))
+	g.Expect(controlPlane.Status.Ready).To(BeTrue())
+	g.Expect(controlPlane.Status.Initialized).To(BeTrue())
+	g.Expect(conditions.IsTrue(controlPlane, expinfrav1.KubeconfigReadyCondition)).To(BeTrue())
+	g.Expect(controlPlane.Spec.ControlPlaneEndpoint).To(Equal(clusterv1.APIEndpoint{Host: fakecloud.GKEEndpoint, Port: 443}))
+
+	secret := &corev1.Secret{}
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "my-cluster-kubeconfig"}, secret)).To(Succeed())
+	g.Expect(secret.OwnerReferences).To(HaveLen(1))
+	g.Expect(secret.OwnerReferences[0].Kind).To(Equal("GCPManagedControlPlane"))
+	config, err := clientcmd.Load(secret.Data["value"])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config.Clusters["my-cluster"].Server).To(Equal("https://" + fakecloud.GKEEndpoint))
+	g.Expect(string(config.Clusters["my-cluster"].CertificateAuthorityData)).To(Equal(fakecloud.GKECACertificate))
+	g.Expect(config.AuthInfos["my-cluster"].Token).To(Equal("my-token"))
+	g.Expect(config.AuthInfos["my-cluster"].Exec).To(BeNil())
+
+	// The Secret is left untouched while the token is current.
+	res, err = r.reconcile(context.TODO(), controlPlaneScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	unchanged := &corev1.Secret{}
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "my-cluster-kubeconfig"}, unchanged)).To(Succeed())
+	g.Expect(unchanged.ResourceVersion).To(Equal(secret.ResourceVersion))
+
+	// A token about to be renewed is replaced shortly, the service account being impersonated once.
+	tokens.token = &oauth2.Token{AccessToken: "my-new-token", Expiry: time.Now().Add(time.Second)}
+	res, err = r.reconcile(context.TODO(), controlPlaneScope)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).To(Equal(minKubeconfigRefreshInterval))
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "my-cluster-kubeconfig"}, secret)).To(Succeed())
+	config, err = clientcmd.Load(secret.Data["value"])
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(config.AuthInfos["my-cluster"].Token).To(Equal("my-new-token"))
+	g.Expect(impersonated).To(ConsistOf("my-cluster@my-project.iam.gserviceaccount.com"))
+}
+
+// testTokenSource returns its current token.
+type testTokenSource struct {
+	token *oauth2.Token
+}
+
+func (ts *testTokenSource) Token() (*oauth2.Token, error) {
+	return ts.token, nil
+}
+
+func TestGCPManagedControlPlaneReconciler_deleteWithoutGCPManagedCluster(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	gkePath := "projects/my-project/locations/us-central1/clusters/my-cluster"
+	c.Put(gkePath, &gke.Cluster{
+		Name:           "my-cluster",
+		Status:         "RUNNING",
+		ResourceLabels: map[string]string{"capg-cluster-my-cluster": "owned", "capg-namespace": "default"},
+	})
+
+	cluster := newCluster("my-cluster")
+	cluster.Spec.InfrastructureRef = &corev1.ObjectReference{Kind: "GCPManagedCluster", Name: "my-cluster"}
+	now := metav1.Now()
+	controlPlane := &expinfrav1.GCPManagedControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "my-cluster",
+			Namespace:         "default",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{expinfrav1.ManagedControlPlaneFinalizer},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: "my-cluster"},
+			},
+		},
+		Status: expinfrav1.GCPManagedControlPlaneStatus{ClusterFullName: gkePath},
+	}
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expinfrav1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, controlPlane).Build()
+
+	r := &GCPManagedControlPlaneReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(controlPlane)}
+
+	// The GKE cluster is deleted in the project and the location it was created in, the GCPManagedCluster being gone.
+	res, err := r.Reconcile(context.TODO(), req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).NotTo(BeZero())
+	g.Expect(c.Get(gkePath, nil)).To(BeFalse())
+	g.Expect(k8sClient.Get(context.TODO(), req.NamespacedName, controlPlane)).To(Succeed())
+	g.Expect(controlPlane.Finalizers).To(ConsistOf(expinfrav1.ManagedControlPlaneFinalizer))
+
+	// The finalizer is removed once the GKE cluster is gone, letting the control plane go.
+	_, err = r.Reconcile(context.TODO(), req)
+	g.Expect(err).NotTo(HaveOccurred())
+	err = k8sClient.Get(context.TODO(), req.NamespacedName, &expinfrav1.GCPManagedControlPlane{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
+
+func TestGCPManagedControlPlaneReconciler_deleteProtected(t *testing.T) {
+	g := NewWithT(t)
+
+	c := fakecloud.NewCloud()
+	defer c.Close()
+	gkePath := "projects/my-project/locations/us-central1/clusters/my-cluster"
+	c.Put(gkePath, &gke.Cluster{
+		Name:           "my-cluster",
+		Status:         "RUNNING",
+		ResourceLabels: map[string]string{"capg-cluster-my-cluster": "owned", "capg-namespace": "default"},
+	})
+
+	cluster := newCluster("my-cluster")
+	cluster.Spec.InfrastructureRef = &corev1.ObjectReference{Kind: "GCPManagedCluster", Name: "my-cluster"}
+	now := metav1.Now()
+	controlPlane := &expinfrav1.GCPManagedControlPlane{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "my-cluster",
+			Namespace:         "default",
+			DeletionTimestamp: &now,
+			Finalizers:        []string{expinfrav1.ManagedControlPlaneFinalizer},
+			Annotations:       map[string]string{infrav1.DeletionProtectionAnnotation: ""},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: clusterv1.GroupVersion.String(), Kind: "Cluster", Name: "my-cluster"},
+			},
+		},
+		Spec:   expinfrav1.GCPManagedControlPlaneSpec{DeletionPolicy: expinfrav1.DeletionPolicyRetain},
+		Status: expinfrav1.GCPManagedControlPlaneStatus{ClusterFullName: gkePath},
+	}
+	scheme := runtime.NewScheme()
+	g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(expinfrav1.AddToScheme(scheme)).To(Succeed())
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(cluster, controlPlane).Build()
+
+	r := &GCPManagedControlPlaneReconciler{
+		Client:            k8sClient,
+		Log:               klogr.New(),
+		ReconcilerOptions: ReconcilerOptions{Cloud: c},
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(controlPlane)}
+
+	// The protected GKE cluster is kept, along with the finalizer.
+	res, err := r.Reconcile(context.TODO(), req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(res.RequeueAfter).NotTo(BeZero())
+	g.Expect(c.Get(gkePath, nil)).To(BeTrue())
+	g.Expect(k8sClient.Get(context.TODO(), req.NamespacedName, controlPlane)).To(Succeed())
+	g.Expect(controlPlane.Finalizers).To(ConsistOf(expinfrav1.ManagedControlPlaneFinalizer))
+	g.Expect(conditions.GetReason(controlPlane, expinfrav1.GKEControlPlaneReadyCondition)).To(Equal(expinfrav1.DeletionProtectedReason))
+
+	// Once unprotected, the retained GKE cluster is unlabelled and the control plane goes.
+	controlPlane.Annotations = nil
+	g.Expect(k8sClient.Update(context.TODO(), controlPlane)).To(Succeed())
+	_, err = r.Reconcile(context.TODO(), req)
+	g.Expect(err).NotTo(HaveOccurred())
+	retained := &gke.Cluster{}
+	g.Expect(c.Get(gkePath, retained)).To(BeTrue())
+	g.Expect(retained.ResourceLabels).To(BeEmpty())
+	_, err = r.Reconcile(context.TODO(), req)
+	g.Expect(err).NotTo(HaveOccurred())
+	g.Expect(c.Get(gkePath, nil)).To(BeTrue())
+	err = k8sClient.Get(context.TODO(), req.NamespacedName, &expinfrav1.GCPManagedControlPlane{})
+	g.Expect(apierrors.IsNotFound(err)).To(BeTrue())
+}
@@ -0,0 +1,243 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	exputil "sigs.k8s.io/cluster-api/exp/util"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/predicates"
+	"sigs.k8s.io/cluster-api/util/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/services/container"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+// GCPManagedMachinePoolReconciler reconciles a GCPManagedMachinePool object.
+type GCPManagedMachinePoolReconciler struct {
+	client.Client
+	Log              logr.Logger
+	ReconcileTimeout time.Duration
+	WatchFilterValue string
+
+	ReconcilerOptions
+}
+
+func (r *GCPManagedMachinePoolReconciler) SetupWithManager(ctx context.Context, mgr ctrl.Manager, options controller.Options) error {
+	log := r.Log.WithValues("controller", "GCPManagedMachinePool")
+
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&expinfrav1.GCPManagedMachinePool{}).
+		WithEventFilter(predicates.ResourceNotPausedAndHasFilterLabel(ctrl.LoggerFrom(ctx), r.WatchFilterValue)).
+		Watches(
+			&source.Kind{Type: &expclusterv1.MachinePool{}},
+			handler.EnqueueRequestsFromMapFunc(exputil.MachinePoolToInfrastructureMapFunc(expinfrav1.GroupVersion.WithKind("GCPManagedMachinePool"), log)),
+		).
+		Complete(r)
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedmachinepools,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedmachinepools/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machinepools;machinepools/status,verbs=get;list;watch
+
+func (r *GCPManagedMachinePoolReconciler) Reconcile(ctx context.Context, req ctrl.Request) (_ ctrl.Result, reterr error) {
+	ctx, cancel := context.WithTimeout(ctx, reconciler.DefaultedLoopTimeout(r.ReconcileTimeout))
+	defer cancel()
+	log := r.Log.WithValues("namespace", req.Namespace, "gcpManagedMachinePool", req.Name)
+
+	pool := &expinfrav1.GCPManagedMachinePool{}
+	if err := r.Get(ctx, req.NamespacedName, pool); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	machinePool, err := exputil.GetOwnerMachinePool(ctx, r.Client, pool.ObjectMeta)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if machinePool == nil {
+		log.Info("MachinePool Controller has not yet set OwnerRef")
+		return ctrl.Result{}, nil
+	}
+
+	cluster, err := util.GetClusterFromMetadata(ctx, r.Client, machinePool.ObjectMeta)
+	if err != nil {
+		log.Info("MachinePool is missing cluster label or cluster does not exist")
+		return ctrl.Result{}, nil
+	}
+
+	// The cluster is reconciled by another replica.
+	if !r.Shard.Owns(cluster.Namespace, cluster.Name) {
+		return ctrl.Result{}, nil
+	}
+
+	if annotations.IsPaused(cluster, pool) {
+		log.Info("GCPManagedMachinePool or linked Cluster is marked as paused. Won't reconcile")
+		return ctrl.Result{}, nil
+	}
+
+	log = log.WithValues("cluster", cluster.Name, "machinePool", machinePool.Name)
+
+	if cluster.Spec.InfrastructureRef == nil || cluster.Spec.ControlPlaneRef == nil {
+		log.Info("Cluster has no GCPManagedCluster or GCPManagedControlPlane yet")
+		return ctrl.Result{}, nil
+	}
+	gcpManagedCluster := &expinfrav1.GCPManagedCluster{}
+	controlPlane := &expinfrav1.GCPManagedControlPlane{}
+	for _, ref := range []struct {
+		name string
+		obj  client.Object
+	}{{cluster.Spec.InfrastructureRef.Name, gcpManagedCluster}, {cluster.Spec.ControlPlaneRef.Name, controlPlane}} {
+		name, obj := ref.name, ref.obj
+		if err := r.Get(ctx, types.NamespacedName{Namespace: cluster.Namespace, Name: name}, obj); err != nil {
+			if apierrors.IsNotFound(err) && !pool.DeletionTimestamp.IsZero() {
+				// The GKE cluster was deleted with its node pools, let the machine pool go.
+				controllerutil.RemoveFinalizer(pool, expinfrav1.ManagedMachinePoolFinalizer)
+				return ctrl.Result{}, r.Update(ctx, pool)
+			}
+			return ctrl.Result{}, errors.Wrapf(err, "failed to get %T %s", obj, name)
+		}
+	}
+
+	var dryRun *cloud.DryRun
+	if r.DryRun || isDryRun(pool, gcpManagedCluster, controlPlane) {
+		dryRun = &cloud.DryRun{}
+		defer reportDryRun(log, pool, dryRun)
+	}
+
+	machinePoolScope, err := scope.NewManagedMachinePoolScope(scope.ManagedMachinePoolScopeParams{
+		Cloud:                  r.Cloud,
+		Client:                 r.Client,
+		Logger:                 log,
+		Cluster:                cluster,
+		MachinePool:            machinePool,
+		GCPManagedCluster:      gcpManagedCluster,
+		GCPManagedControlPlane: controlPlane,
+		GCPManagedMachinePool:  pool,
+		ManagedTransports: scope.ManagedTransports{
+			DryRun:         dryRun,
+			Stats:          r.Stats,
+			Audit:          r.Audit,
+			FaultInjection: r.FaultInjection,
+			RateLimiter:    r.RateLimiter,
+		},
+	})
+	if err != nil {
+		return ctrl.Result{}, errors.Errorf("failed to create scope: %+v", err)
+	}
+
+	// Always close the scope when exiting this function so we can persist any GCPManagedMachinePool changes.
+	defer func() {
+		if err := machinePoolScope.Close(); err != nil && reterr == nil {
+			reterr = err
+		}
+	}()
+
+	if !pool.DeletionTimestamp.IsZero() {
+		return r.reconcileDelete(machinePoolScope)
+	}
+
+	return r.reconcile(machinePoolScope)
+}
+
+func (r *GCPManagedMachinePoolReconciler) reconcile(machinePoolScope *scope.ManagedMachinePoolScope) (ctrl.Result, error) {
+	machinePoolScope.Info("Reconciling GCPManagedMachinePool")
+
+	pool := machinePoolScope.GCPManagedMachinePool
+
+	// Register the finalizer immediately to avoid orphaning the GKE node pool on delete.
+	controllerutil.AddFinalizer(pool, expinfrav1.ManagedMachinePoolFinalizer)
+
+	// A standard GKE cluster is created with the node pools of its machine pools.
+	if !machinePoolScope.GCPManagedControlPlane.Status.Ready {
+		conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.WaitingForGKEControlPlaneReason, clusterv1.ConditionSeverityInfo, "")
+		machinePoolScope.Info("Waiting for the GKE control plane")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)}, nil
+	}
+
+	ready, err := container.NewNodePoolService(machinePoolScope).ReconcileNodePool()
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to reconcile GKE node pool for GCPManagedMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+	pool.Status.Ready = ready
+	if !ready {
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)}, nil
+	}
+
+	// The instances of the node pool change on their own with the autoscaling and the repairs of GKE.
+	return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)}, nil
+}
+
+func (r *GCPManagedMachinePoolReconciler) reconcileDelete(machinePoolScope *scope.ManagedMachinePoolScope) (ctrl.Result, error) {
+	machinePoolScope.Info("Reconciling Delete GCPManagedMachinePool")
+
+	pool := machinePoolScope.GCPManagedMachinePool
+
+	// The node pools are kept along with the GKE cluster while the Cluster is deleted, a single MachinePool being
+	// deleted with its node pool.
+	if controlPlane := machinePoolScope.GCPManagedControlPlane; !machinePoolScope.Cluster.DeletionTimestamp.IsZero() {
+		if _, ok := controlPlane.Annotations[infrav1.DeletionProtectionAnnotation]; ok {
+			conditions.MarkFalse(pool, expinfrav1.GKEMachinePoolReadyCondition, expinfrav1.DeletionProtectedReason, clusterv1.ConditionSeverityWarning,
+				"Remove the %s annotation of the GCPManagedControlPlane to delete the GKE node pool", infrav1.DeletionProtectionAnnotation)
+			machinePoolScope.Info("GKE cluster is protected from deletion")
+			return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(time.Minute, r.RequeueJitter)}, nil
+		}
+		if controlPlane.Spec.DeletionPolicy == expinfrav1.DeletionPolicyRetain {
+			record.Eventf(pool, "RetainedResource", "Retained GKE node pool %q along with its cluster", scope.NodePoolName(pool))
+			controllerutil.RemoveFinalizer(pool, expinfrav1.ManagedMachinePoolFinalizer)
+			return ctrl.Result{}, nil
+		}
+	}
+
+	pool.Status.Ready = false
+	deleted, err := container.NewNodePoolService(machinePoolScope).DeleteNodePool()
+	if err != nil {
+		return ctrl.Result{}, errors.Wrapf(err, "failed to delete GKE node pool for GCPManagedMachinePool %s/%s", pool.Namespace, pool.Name)
+	}
+	if !deleted {
+		machinePoolScope.Info("Waiting for the GKE node pool to be deleted")
+		return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(30*time.Second, r.RequeueJitter)}, nil
+	}
+
+	controllerutil.RemoveFinalizer(pool, expinfrav1.ManagedMachinePoolFinalizer)
+
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	gke "google.golang.org/api/container/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/klogr"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	fakecloud "sigs.k8s.io/cluster-api-provider-gcp/cloud/fake"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+)
+
+func TestGCPManagedMachinePoolReconciler_reconcileDelete(t *testing.T) {
+	gkePath := "projects/my-project/locations/us-central1/clusters/my-cluster/nodePools/my-pool"
+	tests := []struct {
+		name            string
+		clusterDeleted  bool
+		protected       bool
+		deletionPolicy  expinfrav1.DeletionPolicy
+		wantFinalizer   bool
+		wantNodePool    bool
+		wantReadyReason string
+	}{
+		{
+			name:            "machine pool deleted",
+			protected:       true,
+			deletionPolicy:  expinfrav1.DeletionPolicyRetain,
+			wantFinalizer:   true,
+			wantReadyReason: expinfrav1.GKEMachinePoolDeletingReason,
+		},
+		{
+			name:            "cluster deleted",
+			clusterDeleted:  true,
+			wantFinalizer:   true,
+			wantReadyReason: expinfrav1.GKEMachinePoolDeletingReason,
+		},
+		{
+			name:            "protected cluster deleted",
+			clusterDeleted:  true,
+			protected:       true,
+			wantFinalizer:   true,
+			wantNodePool:    true,
+			wantReadyReason: expinfrav1.DeletionProtectedReason,
+		},
+		{
+			name:           "retained cluster deleted",
+			clusterDeleted: true,
+			deletionPolicy: expinfrav1.DeletionPolicyRetain,
+			wantNodePool:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			c := fakecloud.NewCloud()
+			defer c.Close()
+			c.Put(gkePath, &gke.NodePool{Name: "my-pool", Status: "RUNNING"})
+
+			cluster := newCluster("my-cluster")
+			if tt.clusterDeleted {
+				now := metav1.Now()
+				cluster.DeletionTimestamp = &now
+			}
+			controlPlane := &expinfrav1.GCPManagedControlPlane{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+				Spec:       expinfrav1.GCPManagedControlPlaneSpec{DeletionPolicy: tt.deletionPolicy},
+			}
+			if tt.protected {
+				controlPlane.Annotations = map[string]string{infrav1.DeletionProtectionAnnotation: ""}
+			}
+			pool := &expinfrav1.GCPManagedMachinePool{
+				ObjectMeta: metav1.ObjectMeta{Name: "my-pool", Namespace: "default", Finalizers: []string{expinfrav1.ManagedMachinePoolFinalizer}},
+			}
+			scheme := runtime.NewScheme()
+			g.Expect(corev1.AddToScheme(scheme)).To(Succeed())
+			g.Expect(expinfrav1.AddToScheme(scheme)).To(Succeed())
+			machinePoolScope, err := scope.NewManagedMachinePoolScope(scope.ManagedMachinePoolScopeParams{
+				Cloud:       c,
+				Client:      fake.NewClientBuilder().WithScheme(scheme).WithObjects(pool).Build(),
+				Cluster:     cluster,
+				MachinePool: &expclusterv1.MachinePool{ObjectMeta: metav1.ObjectMeta{Name: "my-pool", Namespace: "default"}},
+				GCPManagedCluster: &expinfrav1.GCPManagedCluster{
+					ObjectMeta: metav1.ObjectMeta{Name: "my-cluster", Namespace: "default"},
+					Spec:       expinfrav1.GCPManagedClusterSpec{Project: "my-project", Region: "us-central1"},
+				},
+				GCPManagedControlPlane: controlPlane,
+				GCPManagedMachinePool:  pool,
+			})
+			g.Expect(err).NotTo(HaveOccurred())
+
+			r := &GCPManagedMachinePoolReconciler{Log: klogr.New()}
+			_, err = r.reconcileDelete(machinePoolScope)
+			g.Expect(err).NotTo(HaveOccurred())
+			g.Expect(c.Get(gkePath, nil)).To(Equal(tt.wantNodePool))
+			if tt.wantFinalizer {
+				g.Expect(pool.Finalizers).To(ConsistOf(expinfrav1.ManagedMachinePoolFinalizer))
+			} else {
+				g.Expect(pool.Finalizers).To(BeEmpty())
+			}
+			if tt.wantReadyReason != "" {
+				g.Expect(conditions.GetReason(pool, expinfrav1.GKEMachinePoolReadyCondition)).To(Equal(tt.wantReadyReason))
+			}
+		})
+	}
+}
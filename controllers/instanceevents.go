@@ -0,0 +1,150 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	"sigs.k8s.io/cluster-api/util/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+// instanceEventRetryInterval is the interval at which the instance events are received again after a failure.
+const instanceEventRetryInterval = 30 * time.Second
+
+// instanceEventConditionReasons are the reasons of the InstanceUndisruptedCondition by instance event reason.
+var instanceEventConditionReasons = map[string]string{
+	cloud.InstanceEventPreempted:       infrav1.InstancePreemptedReason,
+	cloud.InstanceEventHostMaintenance: infrav1.InstanceHostMaintenanceReason,
+	cloud.InstanceEventHostError:       infrav1.InstanceHostErrorReason,
+	cloud.InstanceEventAutohealing:     infrav1.InstanceAutohealingReason,
+}
+
+// InstanceEventWatcher reports the disruptions of the GCE instances, e.g. their preemptions, received from
+// the instance events subscription on their GCPMachine and Machine, for remediation to act on them proactively.
+// It runs in the leader only.
+type InstanceEventWatcher struct {
+	Client           client.Client
+	Log              logr.Logger
+	WatchFilterValue string
+
+	// Events are the instance events.
+	Events cloud.InstanceEventReceiver
+}
+
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch;patch
+
+// Start receives the instance events until the context is done.
+func (w *InstanceEventWatcher) Start(ctx context.Context) error {
+	wait.UntilWithContext(ctx, func(ctx context.Context) {
+		if err := w.Events.Receive(ctx, w.handle); err != nil {
+			w.Log.Error(err, "Failed to receive instance events")
+		}
+	}, instanceEventRetryInterval)
+
+	return nil
+}
+
+// NeedLeaderElection makes the watcher run in the leader only.
+func (w *InstanceEventWatcher) NeedLeaderElection() bool {
+	return true
+}
+
+// handle reports the event on the GCPMachine of the instance, if any.
+func (w *InstanceEventWatcher) handle(ctx context.Context, event cloud.InstanceEvent) error {
+	log := w.Log.WithValues("instance", event.Instance, "zone", event.Zone, "reason", event.Reason)
+
+	opts := []client.ListOption{}
+	if w.WatchFilterValue != "" {
+		opts = append(opts, client.MatchingLabels{clusterv1.WatchLabel: w.WatchFilterValue})
+	}
+	gcpMachines := &infrav1.GCPMachineList{}
+	if err := w.Client.List(ctx, gcpMachines, opts...); err != nil {
+		log.Error(err, "Failed to list GCPMachines")
+		return err
+	}
+
+	providerID := fmt.Sprintf("gce://%s/%s/%s", event.Project, event.Zone, event.Instance)
+	for i := range gcpMachines.Items {
+		gcpMachine := &gcpMachines.Items[i]
+		if pointer.StringDeref(gcpMachine.Spec.ProviderID, "") != providerID {
+			continue
+		}
+		if err := w.report(ctx, gcpMachine, event); err != nil {
+			log.Error(err, "Failed to report instance event", "gcpMachine", gcpMachine.Name, "namespace", gcpMachine.Namespace)
+			return err
+		}
+		log.Info("Reported instance event", "gcpMachine", gcpMachine.Name, "namespace", gcpMachine.Namespace)
+	}
+
+	return nil
+}
+
+// report sets the InstanceUndisruptedCondition of the GCPMachine and the InstanceEventAnnotation of its Machine.
+func (w *InstanceEventWatcher) report(ctx context.Context, gcpMachine *infrav1.GCPMachine, event cloud.InstanceEvent) error {
+	eventTime := event.Time.UTC().Format(time.RFC3339)
+
+	helper, err := patch.NewHelper(gcpMachine, w.Client)
+	if err != nil {
+		return errors.Wrap(err, "failed to init patch helper")
+	}
+	conditions.MarkFalse(gcpMachine, infrav1.InstanceUndisruptedCondition, instanceEventConditionReasons[event.Reason],
+		clusterv1.ConditionSeverityWarning, "%s event at %s", event.Reason, eventTime)
+	if err := helper.Patch(ctx, gcpMachine); err != nil {
+		return errors.Wrapf(err, "failed to patch GCPMachine")
+	}
+	record.Warnf(gcpMachine, event.Reason, "Instance %s: %s event at %s", event.Instance, event.Reason, eventTime)
+
+	machine, err := util.GetOwnerMachine(ctx, w.Client, gcpMachine.ObjectMeta)
+	if err != nil {
+		return err
+	}
+	if machine == nil {
+		return nil
+	}
+
+	value := fmt.Sprintf("%s %s", event.Reason, eventTime)
+	if machine.Annotations[infrav1.InstanceEventAnnotation] == value {
+		return nil
+	}
+	helper, err = patch.NewHelper(machine, w.Client)
+	if err != nil {
+		return errors.Wrap(err, "failed to init patch helper")
+	}
+	if machine.Annotations == nil {
+		machine.Annotations = map[string]string{}
+	}
+	machine.Annotations[infrav1.InstanceEventAnnotation] = value
+	if err := helper.Patch(ctx, machine); err != nil {
+		return errors.Wrapf(err, "failed to patch Machine")
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog/v2/klogr"
+	"k8s.io/utils/pointer"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+)
+
+func TestInstanceEventWatcher_handle(t *testing.T) {
+	g := NewWithT(t)
+
+	scheme := runtime.NewScheme()
+	g.Expect(infrav1.AddToScheme(scheme)).To(Succeed())
+	g.Expect(clusterv1.AddToScheme(scheme)).To(Succeed())
+
+	machine := newMachine("my-cluster", "my-machine")
+	gcpMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gcpmy-machine",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: clusterv1.GroupVersion.String(),
+				Kind:       "Machine",
+				Name:       machine.Name,
+			}},
+		},
+		Spec: infrav1.GCPMachineSpec{ProviderID: pointer.StringPtr("gce://my-project/us-central1-a/my-machine")},
+	}
+	otherGCPMachine := &infrav1.GCPMachine{
+		ObjectMeta: metav1.ObjectMeta{Name: "gcpother-machine", Namespace: "default"},
+		Spec:       infrav1.GCPMachineSpec{ProviderID: pointer.StringPtr("gce://my-project/us-central1-a/other-machine")},
+	}
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(machine, gcpMachine, otherGCPMachine).Build()
+
+	w := &InstanceEventWatcher{
+		Client: k8sClient,
+		Log:    klogr.New(),
+	}
+	event := cloud.InstanceEvent{
+		Reason:   cloud.InstanceEventPreempted,
+		Project:  "my-project",
+		Zone:     "us-central1-a",
+		Instance: "my-machine",
+		Time:     time.Date(2021, 7, 1, 10, 0, 0, 0, time.UTC),
+	}
+	g.Expect(w.handle(context.TODO(), event)).To(Succeed())
+
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(gcpMachine), gcpMachine)).To(Succeed())
+	g.Expect(conditions.IsFalse(gcpMachine, infrav1.InstanceUndisruptedCondition)).To(BeTrue())
+	g.Expect(conditions.GetReason(gcpMachine, infrav1.InstanceUndisruptedCondition)).To(Equal(infrav1.InstancePreemptedReason))
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(machine), machine)).To(Succeed())
+	g.Expect(machine.Annotations).To(HaveKeyWithValue(infrav1.InstanceEventAnnotation, "Preempted 2021-07-01T10:00:00Z"))
+
+	g.Expect(k8sClient.Get(context.TODO(), client.ObjectKeyFromObject(otherGCPMachine), otherGCPMachine)).To(Succeed())
+	g.Expect(conditions.Has(otherGCPMachine, infrav1.InstanceUndisruptedCondition)).To(BeFalse())
+
+	// The events of unknown instances are dropped.
+	event.Instance = "unknown-machine"
+	g.Expect(w.handle(context.TODO(), event)).To(Succeed())
+}
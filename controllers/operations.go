@@ -0,0 +1,38 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+// requeueOnOperationTimeout requeues the reconcile once the GCP operation in progress, polled or whose wait
+// timed out, is expected to have progressed, instead of retrying it with the exponential backoff of the failed
+// reconciles. The next reconcile polls the operation again.
+func requeueOnOperationTimeout(log logr.Logger, result ctrl.Result, err error, jitter float64) (ctrl.Result, error) {
+	retryAfter, ok := wait.RetryAfter(err)
+	if !ok {
+		return result, err
+	}
+	log.Info("Waiting for a GCP operation in progress", "reason", err.Error(), "retryAfter", retryAfter)
+
+	return ctrl.Result{RequeueAfter: reconciler.JitteredRequeueAfter(retryAfter, jitter)}, nil
+}
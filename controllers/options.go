@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+// ReconcilerOptions are the GCP backend, the transports of the GCP API calls and the scheduling options shared by
+// all the reconcilers.
+type ReconcilerOptions struct {
+	// Cloud is the GCP backend used by the reconcilers, defaults to the GCP APIs.
+	Cloud cloud.Cloud
+
+	// Stats collects the statistics of the GCP API calls, nothing is collected if nil.
+	Stats *cloud.ClientStats
+
+	// Audit records the mutating GCP API calls, nothing is recorded if nil.
+	Audit cloud.AuditSink
+
+	// FaultInjection makes the GCP API calls fail at the configured rates, for resilience testing only.
+	FaultInjection *cloud.FaultInjection
+
+	// RateLimiter limits the rate of the GCP API calls and retries the throttled ones, nothing is limited if nil.
+	RateLimiter *cloud.RateLimiter
+
+	// DryRun makes the reconcilers record the GCP operations they would perform without executing them.
+	// It can be enabled for a single object, or all the objects of a cluster when set on its infrastructure,
+	// with the infrav1.DryRunAnnotation.
+	DryRun bool
+
+	// Shard is the partition of the clusters whose objects are reconciled, all the clusters if unset.
+	Shard reconciler.Shard
+
+	// RequeueJitter is the maximum factor by which the requeue intervals are randomly extended.
+	RequeueJitter float64
+}
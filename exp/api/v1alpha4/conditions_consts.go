@@ -0,0 +1,82 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+const (
+	// GKEControlPlaneReadyCondition reports on the status of the GKE cluster. Ready indicates the cluster is running.
+	GKEControlPlaneReadyCondition clusterv1.ConditionType = "GKEControlPlaneReady"
+
+	// GKEControlPlaneProvisioningReason used when the GKE cluster is being created.
+	GKEControlPlaneProvisioningReason = "GKEControlPlaneProvisioning"
+	// GKEControlPlaneReconcilingReason used when the GKE cluster is being updated, e.g. upgraded.
+	GKEControlPlaneReconcilingReason = "GKEControlPlaneReconciling"
+	// GKEControlPlaneDeletingReason used when the GKE cluster is being deleted.
+	GKEControlPlaneDeletingReason = "GKEControlPlaneDeleting"
+	// GKEControlPlaneErrorReason used when the GKE cluster is in error or degraded.
+	GKEControlPlaneErrorReason = "GKEControlPlaneError"
+	// GKEControlPlaneNotOwnedReason used when a GKE cluster of the same name exists which isn't labelled as owned
+	// by the cluster, and isn't adopted.
+	GKEControlPlaneNotOwnedReason = "GKEControlPlaneNotOwned"
+	// DeletionProtectedReason used when the GKE cluster, or the node pool, isn't deleted as the
+	// GCPManagedControlPlane has the deletion protection annotation.
+	DeletionProtectedReason = "DeletionProtected"
+	// WaitingForMachinePoolsReason used when a standard GKE cluster waits for its first GCPManagedMachinePool,
+	// GKE creating the clusters with their node pools.
+	WaitingForMachinePoolsReason = "WaitingForMachinePools"
+)
+
+const (
+	// KubeconfigReadyCondition reports on the kubeconfig Secret of the GKE cluster. Ready indicates it holds a current
+	// access token.
+	KubeconfigReadyCondition clusterv1.ConditionType = "KubeconfigReady"
+
+	// KubeconfigCredentialsMissingReason used when the GKE cluster has no service account to authenticate the
+	// kubeconfig with, i.e. the kubeconfigServiceAccount is unset, or it's the service account of the manager.
+	KubeconfigCredentialsMissingReason = "KubeconfigCredentialsMissing"
+)
+
+const (
+	// GKEMachinePoolReadyCondition reports on the status of the GKE node pool. Ready indicates the node pool is running.
+	GKEMachinePoolReadyCondition clusterv1.ConditionType = "GKEMachinePoolReady"
+
+	// GKEMachinePoolProvisioningReason used when the node pool is being created.
+	GKEMachinePoolProvisioningReason = "GKEMachinePoolProvisioning"
+	// GKEMachinePoolReconcilingReason used when the node pool is being updated, e.g. resized.
+	GKEMachinePoolReconcilingReason = "GKEMachinePoolReconciling"
+	// GKEMachinePoolDeletingReason used when the node pool is being deleted.
+	GKEMachinePoolDeletingReason = "GKEMachinePoolDeleting"
+	// GKEMachinePoolErrorReason used when the node pool is in error.
+	GKEMachinePoolErrorReason = "GKEMachinePoolError"
+	// WaitingForGKEControlPlaneReason used when the node pool waits for the GKE cluster to be running, or for its
+	// control plane to run the version of the node pool before upgrading it.
+	WaitingForGKEControlPlaneReason = "WaitingForGKEControlPlane"
+)
+
+const (
+	// InstanceGroupReadyCondition reports on the status of the managed instance group of a GCPMachinePool. Ready
+	// indicates the group runs all its instances with the current instance template.
+	InstanceGroupReadyCondition clusterv1.ConditionType = "InstanceGroupReady"
+
+	// WaitingForBootstrapDataReason used when the instance template waits for the bootstrap data of the MachinePool.
+	WaitingForBootstrapDataReason = "WaitingForBootstrapData"
+	// InstanceGroupUpdatingReason used when the instances of the group are being created, deleted or replaced.
+	InstanceGroupUpdatingReason = "InstanceGroupUpdating"
+	// InstanceGroupProvisionFailedReason used when the instance template or the group failed to be created or updated.
+	InstanceGroupProvisionFailedReason = "InstanceGroupProvisionFailed"
+)
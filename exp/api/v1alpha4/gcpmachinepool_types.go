@@ -0,0 +1,236 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+const (
+	// MachinePoolFinalizer allows ReconcileGCPMachinePool to delete the managed instance group and its instance
+	// templates before removing the GCPMachinePool from the apiserver.
+	MachinePoolFinalizer = "gcpmachinepool.infrastructure.cluster.x-k8s.io"
+)
+
+// GCPMachinePoolSpec defines the desired state of GCPMachinePool. The instances of the pool are created by a
+// regional managed instance group from an instance template, the instances being replaced once it's changed.
+type GCPMachinePoolSpec struct {
+	// InstanceType is the type of instance to create. Example: n1.standard-2
+	InstanceType string `json:"instanceType"`
+
+	// Subnet is a reference to the subnetwork to use for the instances. If not specified,
+	// the first subnetwork retrieved from the Cluster Region and Network is picked.
+	// +optional
+	Subnet *string `json:"subnet,omitempty"`
+
+	// Image is the full reference to a valid image to be used for the instances.
+	// Takes precedence over ImageFamily.
+	// +optional
+	Image *string `json:"image,omitempty"`
+
+	// ImageFamily is the full reference to a valid image family to be used for the instances.
+	// +optional
+	ImageFamily *string `json:"imageFamily,omitempty"`
+
+	// AdditionalLabels is an optional set of tags to add to the instances, in addition to the ones added by
+	// default by the GCP provider.
+	// +optional
+	AdditionalLabels infrav1.Labels `json:"additionalLabels,omitempty"`
+
+	// AdditionalMetadata is an optional set of metadata to add to the instances, in addition to the ones added
+	// by default by the GCP provider.
+	// +listType=map
+	// +listMapKey=key
+	// +optional
+	AdditionalMetadata []infrav1.MetadataItem `json:"additionalMetadata,omitempty"`
+
+	// PublicIP specifies whether the instances should get a public IP.
+	// Defaults to the PublicIP of the MachineDefaults of the GCPCluster, or false.
+	// +optional
+	PublicIP *bool `json:"publicIP,omitempty"`
+
+	// AdditionalNetworkTags is a list of network tags that should be applied to the instances.
+	// These tags are set in addition to any network tags defined at the cluster level or in the actuator.
+	// +optional
+	AdditionalNetworkTags []string `json:"additionalNetworkTags,omitempty"`
+
+	// RootDeviceSize is the size of the root volume in GB.
+	// Defaults to 30.
+	// +optional
+	RootDeviceSize int64 `json:"rootDeviceSize,omitempty"`
+
+	// RootDeviceType is the type of the root volume.
+	// Supported types of root volumes:
+	// 1. "pd-standard" - Standard (HDD) persistent disk
+	// 2. "pd-ssd" - SSD persistent disk
+	// Default is "pd-standard".
+	// +optional
+	RootDeviceType *infrav1.DiskType `json:"rootDeviceType,omitempty"`
+
+	// ServiceAccount specifies the service account email and which scopes to assign to the instances.
+	// Defaults to: email: "default", scope: []{compute.CloudPlatformScope}
+	// +optional
+	ServiceAccount *infrav1.ServiceAccount `json:"serviceAccounts,omitempty"`
+
+	// DiskEncryption encrypts the root volumes of the instances with a customer-managed key instead of a
+	// Google-managed key.
+	// +optional
+	DiskEncryption *infrav1.DiskEncryption `json:"diskEncryption,omitempty"`
+
+	// Preemptible defines if the instances are preemptible.
+	// +optional
+	Preemptible bool `json:"preemptible,omitempty"`
+
+	// GuestAccelerators are the accelerators, e.g. GPUs, attached to the instances. The instances are
+	// distributed across the zones of the region offering them, and terminated on host maintenance.
+	// +optional
+	GuestAccelerators []infrav1.Accelerator `json:"guestAccelerators,omitempty"`
+
+	// Autoscaling has a regional autoscaler resize the managed instance group with the CPU utilization of its
+	// instances, between its minimum and maximum replicas, instead of the replicas of the MachinePool.
+	// +optional
+	Autoscaling *MachinePoolAutoscaling `json:"autoscaling,omitempty"`
+
+	// WarmPool keeps stopped instances in the managed instance group, which are started on scale up instead of
+	// new instances being created, after the suspended instances. It can't be combined with Autoscaling, and
+	// requires the ComputeAlphaAPI feature gate.
+	// +optional
+	WarmPool *MachinePoolWarmPool `json:"warmPool,omitempty"`
+
+	// Suspension suspends the instances in excess on scale down instead of deleting them, the suspended instances
+	// being resumed on scale up before new instances are created. It can't be combined with Autoscaling, and
+	// requires the ComputeAlphaAPI feature gate.
+	// +optional
+	Suspension *MachinePoolSuspension `json:"suspension,omitempty"`
+
+	// ProviderIDList are the provider IDs of the instances of the managed instance group.
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
+}
+
+// MachinePoolAutoscaling is the autoscaling policy of the managed instance group of a GCPMachinePool.
+type MachinePoolAutoscaling struct {
+	// MinReplicas is the minimum number of instances of the group.
+	// +kubebuilder:validation:Minimum=0
+	MinReplicas int64 `json:"minReplicas"`
+
+	// MaxReplicas is the maximum number of instances of the group, not less than MinReplicas.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int64 `json:"maxReplicas"`
+
+	// CPUUtilizationPercent is the average CPU utilization of the instances the group is resized to keep.
+	// Defaults to 60.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	// +optional
+	CPUUtilizationPercent int64 `json:"cpuUtilizationPercent,omitempty"`
+
+	// CooldownPeriodSeconds is the time the new instances are given to initialize before their utilization is
+	// taken into account. Defaults to 60.
+	// +kubebuilder:validation:Minimum=15
+	// +optional
+	CooldownPeriodSeconds int64 `json:"cooldownPeriodSeconds,omitempty"`
+}
+
+// MachinePoolWarmPool is the warm pool of the managed instance group of a GCPMachinePool.
+type MachinePoolWarmPool struct {
+	// Size is the number of stopped instances of the warm pool. The instances are only stopped once they joined
+	// the cluster, so that they are started already joined.
+	// +kubebuilder:validation:Minimum=1
+	Size int64 `json:"size"`
+}
+
+// MachinePoolSuspension is the suspension of the instances of the managed instance group of a GCPMachinePool on
+// scale down.
+type MachinePoolSuspension struct {
+	// MaxReplicas is the maximum number of suspended instances, the instances in excess being deleted.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int64 `json:"maxReplicas"`
+
+	// Retention is how long the instances are kept suspended before they are deleted. The instances are kept
+	// suspended until the next scale up if not set.
+	// +optional
+	Retention *metav1.Duration `json:"retention,omitempty"`
+}
+
+// GCPMachinePoolStatus defines the observed state of GCPMachinePool.
+type GCPMachinePoolStatus struct {
+	// Ready is true when the managed instance group runs all its instances with the current instance template.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Replicas is the number of instances of the managed instance group.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// InstanceTemplate is the name of the current instance template of the managed instance group.
+	// +optional
+	InstanceTemplate string `json:"instanceTemplate,omitempty"`
+
+	// Operation is the full reference of the operation in progress on the managed instance group or the instance
+	// templates, e.g. the resize of the group, which is polled by the next reconciles instead of being waited for.
+	// +optional
+	Operation string `json:"operation,omitempty"`
+
+	// Conditions defines current service state of the GCPMachinePool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=gcpmachinepools,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this GCPMachinePool belongs"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="The managed instance group runs all its instances"
+// +kubebuilder:printcolumn:name="Replicas",type="string",JSONPath=".status.replicas",description="Number of instances of the managed instance group"
+// +kubebuilder:printcolumn:name="Template",type="string",JSONPath=".status.instanceTemplate",description="Current instance template",priority=1
+
+// GCPMachinePool is the Schema for the gcpmachinepools API, the managed instance group of a MachinePool.
+type GCPMachinePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GCPMachinePoolSpec   `json:"spec,omitempty"`
+	Status GCPMachinePoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GCPMachinePoolList contains a list of GCPMachinePool.
+type GCPMachinePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GCPMachinePool `json:"items"`
+}
+
+// GetConditions returns the observations of the operational state of the GCPMachinePool resource.
+func (r *GCPMachinePool) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the GCPMachinePool to the predescribed clusterv1.Conditions.
+func (r *GCPMachinePool) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&GCPMachinePool{}, &GCPMachinePoolList{})
+}
@@ -0,0 +1,108 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+// GCPManagedClusterSpec defines the desired state of GCPManagedCluster.
+type GCPManagedClusterSpec struct {
+	// Project is the name of the project to deploy the cluster to.
+	Project string `json:"project"`
+
+	// Region is the GCP Region the cluster lives in.
+	Region string `json:"region"`
+
+	// Network is the name of the existing VPC network of the GKE cluster, defaults to the default network.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	Network *string `json:"network,omitempty"`
+
+	// Subnetwork is the name of the existing subnetwork of the network in the region of the GKE cluster,
+	// defaults to the subnetwork GKE picks, the one named after the network in auto mode networks.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +optional
+	Subnetwork *string `json:"subnetwork,omitempty"`
+
+	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane, it is set
+	// from the endpoint of the GKE cluster.
+	// +optional
+	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
+
+	// AdditionalLabels is an optional set of tags to add to the GKE cluster, and by GKE to the instances of
+	// its node pools, in addition to the ones added by default.
+	// +optional
+	AdditionalLabels infrav1.Labels `json:"additionalLabels,omitempty"`
+}
+
+// GCPManagedClusterStatus defines the observed state of GCPManagedCluster.
+type GCPManagedClusterStatus struct {
+	// FailureDomains are the zones of the region of the cluster.
+	FailureDomains clusterv1.FailureDomains `json:"failureDomains,omitempty"`
+
+	// Ready is true once the network of the cluster exists.
+	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the GCPManagedCluster.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=gcpmanagedclusters,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this GCPManagedCluster belongs"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="Cluster infrastructure is ready for the GKE cluster"
+// +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.controlPlaneEndpoint.host",description="API Endpoint",priority=1
+
+// GCPManagedCluster is the Schema for the gcpmanagedclusters API, the infrastructure of the clusters whose
+// control plane is a GCPManagedControlPlane.
+type GCPManagedCluster struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GCPManagedClusterSpec   `json:"spec,omitempty"`
+	Status GCPManagedClusterStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GCPManagedClusterList contains a list of GCPManagedCluster.
+type GCPManagedClusterList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GCPManagedCluster `json:"items"`
+}
+
+// GetConditions returns the observations of the operational state of the GCPManagedCluster resource.
+func (r *GCPManagedCluster) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the GCPManagedCluster to the predescribed clusterv1.Conditions.
+func (r *GCPManagedCluster) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&GCPManagedCluster{}, &GCPManagedClusterList{})
+}
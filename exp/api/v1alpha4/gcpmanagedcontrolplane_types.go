@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+)
+
+const (
+	// ManagedControlPlaneFinalizer allows ReconcileGCPManagedControlPlane to delete the GKE cluster before
+	// removing the GCPManagedControlPlane from the apiserver.
+	ManagedControlPlaneFinalizer = "gcpmanagedcontrolplane.infrastructure.cluster.x-k8s.io"
+)
+
+// ReleaseChannel is the release channel of a GKE cluster, see https://cloud.google.com/kubernetes-engine/docs/concepts/release-channels.
+// +kubebuilder:validation:Enum=rapid;regular;stable
+type ReleaseChannel string
+
+const (
+	// RapidReleaseChannel gets the latest Kubernetes releases as early as possible.
+	RapidReleaseChannel ReleaseChannel = "rapid"
+	// RegularReleaseChannel balances feature availability and release stability.
+	RegularReleaseChannel ReleaseChannel = "regular"
+	// StableReleaseChannel prioritizes stability over new functionality.
+	StableReleaseChannel ReleaseChannel = "stable"
+)
+
+// DeletionPolicy is what happens to the GKE cluster once its Cluster is deleted.
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes the GKE cluster and its node pools along with the Cluster.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	// DeletionPolicyRetain keeps the GKE cluster and its node pools, no longer labelled as owned by the Cluster, for
+	// another Cluster to adopt.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// ClusterAutoscaling configures the node auto-provisioning of a GKE cluster: GKE creates and deletes node pools on its
+// own, within the resource limits of the cluster, in addition to the node pools of the machine pools.
+type ClusterAutoscaling struct {
+	// ResourceLimits are the minimum and maximum amounts of the resources of the nodes of the cluster, the cpu and
+	// memory limits being required.
+	// +kubebuilder:validation:MinItems=2
+	ResourceLimits []ResourceLimit `json:"resourceLimits"`
+}
+
+// ResourceLimit is the minimum and maximum amount of a resource of the nodes of a GKE cluster.
+type ResourceLimit struct {
+	// ResourceType is the type of the resource: cpu, in cores, memory, in GB, or the type of a GPU, e.g.
+	// nvidia-tesla-t4.
+	ResourceType string `json:"resourceType"`
+
+	// Minimum is the minimum amount of the resource in the cluster.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Minimum int64 `json:"minimum,omitempty"`
+
+	// Maximum is the maximum amount of the resource in the cluster.
+	// +kubebuilder:validation:Minimum=1
+	Maximum int64 `json:"maximum"`
+}
+
+// IPAllocationPolicy configures the IP ranges of the pods and services of a VPC-native GKE cluster, either existing
+// secondary ranges of its subnetwork or secondary ranges GKE creates.
+type IPAllocationPolicy struct {
+	// ClusterSecondaryRangeName is the name of the existing secondary range of the subnetwork the IPs of the pods
+	// are allocated from.
+	// +optional
+	ClusterSecondaryRangeName string `json:"clusterSecondaryRangeName,omitempty"`
+
+	// ServicesSecondaryRangeName is the name of the existing secondary range of the subnetwork the IPs of the
+	// services are allocated from.
+	// +optional
+	ServicesSecondaryRangeName string `json:"servicesSecondaryRangeName,omitempty"`
+
+	// ClusterCidrBlock is the range, e.g. 10.96.0.0/14, or only the size, e.g. /14, of the secondary range GKE
+	// creates for the pods if there is no ClusterSecondaryRangeName. GKE picks it if unset.
+	// +optional
+	ClusterCidrBlock string `json:"clusterCidrBlock,omitempty"`
+
+	// ServicesCidrBlock is the range, e.g. 10.100.0.0/20, or only the size, e.g. /20, of the secondary range GKE
+	// creates for the services if there is no ServicesSecondaryRangeName. GKE picks it if unset.
+	// +optional
+	ServicesCidrBlock string `json:"servicesCidrBlock,omitempty"`
+}
+
+// GCPManagedControlPlaneSpec defines the desired state of GCPManagedControlPlane.
+type GCPManagedControlPlaneSpec struct {
+	// ClusterName is the name of the GKE cluster, defaults to the name of the Cluster. It can't be changed.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=40
+	// +optional
+	ClusterName string `json:"clusterName,omitempty"`
+
+	// Location is the zone of a zonal GKE cluster, the GKE cluster is regional, in the region of the
+	// GCPManagedCluster, if unset. It can't be changed.
+	// +optional
+	Location *string `json:"location,omitempty"`
+
+	// EnableAutopilot creates an Autopilot GKE cluster, whose nodes are managed by GKE: the cluster has no
+	// GCPManagedMachinePool. It can't be changed.
+	// +optional
+	EnableAutopilot bool `json:"enableAutopilot,omitempty"`
+
+	// ReleaseChannel is the release channel the GKE cluster is enrolled in, it is upgraded automatically.
+	// +optional
+	ReleaseChannel *ReleaseChannel `json:"releaseChannel,omitempty"`
+
+	// KubeconfigServiceAccount is the email of the service account whose access tokens, limited to its identity,
+	// authenticate the kubeconfig Secret of the GKE cluster. It should be dedicated to the cluster: the manager
+	// impersonates it, with the Service Account Token Creator role, and it needs access to the GKE cluster. No
+	// kubeconfig is written, and the control plane is never ready, if unset.
+	// +optional
+	KubeconfigServiceAccount string `json:"kubeconfigServiceAccount,omitempty"`
+
+	// ControlPlaneVersion is the Kubernetes version of the control plane, e.g. 1.21 or 1.21.5-gke.1302, the
+	// default version of GKE, or of the release channel, if unset. The control plane is upgraded once it's
+	// increased.
+	// +optional
+	ControlPlaneVersion *string `json:"controlPlaneVersion,omitempty"`
+
+	// ClusterAutoscaling, if set, enables the node auto-provisioning of a standard GKE cluster, within its resource
+	// limits. It's disabled once unset.
+	// +optional
+	ClusterAutoscaling *ClusterAutoscaling `json:"clusterAutoscaling,omitempty"`
+
+	// IPAllocationPolicy, if set, configures the IP ranges of the pods and services of the VPC-native GKE cluster,
+	// GKE picks them otherwise. It can't be changed.
+	// +optional
+	IPAllocationPolicy *IPAllocationPolicy `json:"ipAllocationPolicy,omitempty"`
+
+	// DeletionPolicy is what happens to the GKE cluster and its node pools once the Cluster is deleted: Delete, the
+	// default, deletes them, Retain keeps them for another Cluster to adopt.
+	// +kubebuilder:validation:Enum=Delete;Retain
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// ControlPlaneEndpoint represents the endpoint used to communicate with the control plane, it is set
+	// from the endpoint of the GKE cluster.
+	// +optional
+	ControlPlaneEndpoint clusterv1.APIEndpoint `json:"controlPlaneEndpoint"`
+}
+
+// GCPManagedControlPlaneStatus defines the observed state of GCPManagedControlPlane.
+type GCPManagedControlPlaneStatus struct {
+	// Ready denotes that the GKE cluster is running.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Initialized denotes that the GKE cluster has been created and can be reached, its kubeconfig Secret
+	// written.
+	// +optional
+	Initialized bool `json:"initialized,omitempty"`
+
+	// CurrentVersion is the current Kubernetes version of the control plane.
+	// +optional
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// ClusterFullName is the resource name of the GKE cluster, e.g.
+	// projects/my-project/locations/us-central1/clusters/my-cluster, recorded before it's created so that it's
+	// deleted along with the GCPManagedControlPlane even once the GCPManagedCluster is gone.
+	// +optional
+	ClusterFullName string `json:"clusterFullName,omitempty"`
+
+	// Conditions defines current service state of the GCPManagedControlPlane.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=gcpmanagedcontrolplanes,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this GCPManagedControlPlane belongs"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="The GKE cluster is running"
+// +kubebuilder:printcolumn:name="Version",type="string",JSONPath=".status.currentVersion",description="Kubernetes version of the control plane"
+// +kubebuilder:printcolumn:name="Endpoint",type="string",JSONPath=".spec.controlPlaneEndpoint.host",description="API Endpoint",priority=1
+
+// GCPManagedControlPlane is the Schema for the gcpmanagedcontrolplanes API, the GKE cluster of a Cluster.
+type GCPManagedControlPlane struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GCPManagedControlPlaneSpec   `json:"spec,omitempty"`
+	Status GCPManagedControlPlaneStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GCPManagedControlPlaneList contains a list of GCPManagedControlPlane.
+type GCPManagedControlPlaneList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GCPManagedControlPlane `json:"items"`
+}
+
+// GetConditions returns the observations of the operational state of the GCPManagedControlPlane resource.
+func (r *GCPManagedControlPlane) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the GCPManagedControlPlane to the predescribed clusterv1.Conditions.
+func (r *GCPManagedControlPlane) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&GCPManagedControlPlane{}, &GCPManagedControlPlaneList{})
+}
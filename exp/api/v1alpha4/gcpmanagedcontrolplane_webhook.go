@@ -0,0 +1,225 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+// controlplanelog is for logging in this package.
+var controlplanelog = logf.Log.WithName("gcpmanagedcontrolplane-resource")
+
+// SetupWebhookWithManager sets up and registers the webhook with the manager.
+func (r *GCPManagedControlPlane) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update;delete,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha4-gcpmanagedcontrolplane,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedcontrolplanes,versions=v1alpha4,name=validation.gcpmanagedcontrolplane.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &GCPManagedControlPlane{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPManagedControlPlane) ValidateCreate() error {
+	controlplanelog.Info("validate create", "name", r.Name)
+
+	if allErrs := r.validate(); len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPManagedControlPlane").GroupKind(), r.Name, allErrs)
+	}
+
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPManagedControlPlane) ValidateUpdate(oldRaw runtime.Object) error {
+	controlplanelog.Info("validate update", "name", r.Name)
+	old := oldRaw.(*GCPManagedControlPlane)
+
+	// The GKE cluster is looked up by its name and location, changing them would orphan it and create another one.
+	allErrs := r.validate()
+	fldPath := field.NewPath("spec")
+	if r.Spec.ClusterName != old.Spec.ClusterName {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("clusterName"), r.Spec.ClusterName, "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.Location, old.Spec.Location) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("location"), r.Spec.Location, "field is immutable"))
+	}
+	if r.Spec.EnableAutopilot != old.Spec.EnableAutopilot {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("enableAutopilot"), r.Spec.EnableAutopilot, "field is immutable"))
+	}
+	// GKE upgrades the control plane one minor version at a time, and never downgrades it.
+	if r.Spec.ControlPlaneVersion != nil && !reflect.DeepEqual(r.Spec.ControlPlaneVersion, old.Spec.ControlPlaneVersion) {
+		current := old.Status.CurrentVersion
+		if current == "" && old.Spec.ControlPlaneVersion != nil {
+			current = *old.Spec.ControlPlaneVersion
+		}
+		if err := validateVersionSkew(current, *r.Spec.ControlPlaneVersion); err != "" {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("controlPlaneVersion"), *r.Spec.ControlPlaneVersion, err))
+		}
+	}
+	// GKE can't change the IP ranges of the pods and services of a cluster.
+	if !reflect.DeepEqual(r.Spec.IPAllocationPolicy, old.Spec.IPAllocationPolicy) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("ipAllocationPolicy"), r.Spec.IPAllocationPolicy, "field is immutable"))
+	}
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPManagedControlPlane").GroupKind(), r.Name, allErrs)
+	}
+
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPManagedControlPlane) ValidateDelete() error {
+	controlplanelog.Info("validate delete", "name", r.Name)
+
+	if _, ok := r.Annotations[infrav1.DeletionProtectionAnnotation]; ok {
+		return apierrors.NewForbidden(GroupVersion.WithResource("gcpmanagedcontrolplanes").GroupResource(), r.Name,
+			errors.Errorf("deletion protection is enabled, remove the %s annotation to delete the GKE cluster", infrav1.DeletionProtectionAnnotation))
+	}
+
+	return nil
+}
+
+// validate returns the errors of the spec GKE would reject.
+func (r *GCPManagedControlPlane) validate() field.ErrorList {
+	allErrs := r.validateClusterAutoscaling(field.NewPath("spec", "clusterAutoscaling"))
+	allErrs = append(allErrs, r.validateIPAllocationPolicy(field.NewPath("spec", "ipAllocationPolicy"))...)
+
+	return allErrs
+}
+
+// validateIPAllocationPolicy returns the errors of the IP ranges of the pods and services: each is either an existing
+// secondary range or one GKE creates, whose range is a CIDR block or only its size.
+func (r *GCPManagedControlPlane) validateIPAllocationPolicy(fldPath *field.Path) field.ErrorList {
+	policy := r.Spec.IPAllocationPolicy
+	if policy == nil {
+		return nil
+	}
+
+	var allErrs field.ErrorList
+	for _, ranges := range []struct{ name, nameField, cidr, cidrField string }{
+		{policy.ClusterSecondaryRangeName, "clusterSecondaryRangeName", policy.ClusterCidrBlock, "clusterCidrBlock"},
+		{policy.ServicesSecondaryRangeName, "servicesSecondaryRangeName", policy.ServicesCidrBlock, "servicesCidrBlock"},
+	} {
+		if ranges.cidr == "" {
+			continue
+		}
+		if ranges.name != "" {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child(ranges.cidrField), "can't be set along with "+ranges.nameField))
+		} else if !validCidrBlock(ranges.cidr) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(ranges.cidrField), ranges.cidr, "must be an IPv4 CIDR block or its size, e.g. /14"))
+		}
+	}
+
+	return allErrs
+}
+
+// validCidrBlock returns true if the range is an IPv4 CIDR block, or only the size of one.
+func validCidrBlock(cidr string) bool {
+	if strings.HasPrefix(cidr, "/") {
+		size, err := strconv.Atoi(cidr[1:])
+		return err == nil && size > 0 && size <= 32
+	}
+	ip, _, err := net.ParseCIDR(cidr)
+
+	return err == nil && ip.To4() != nil
+}
+
+// validateClusterAutoscaling returns the errors of the node auto-provisioning: the nodes of the Autopilot clusters are
+// provisioned by GKE, and the cpu and memory limits are required.
+func (r *GCPManagedControlPlane) validateClusterAutoscaling(fldPath *field.Path) field.ErrorList {
+	autoscaling := r.Spec.ClusterAutoscaling
+	if autoscaling == nil {
+		return nil
+	}
+	if r.Spec.EnableAutopilot {
+		return field.ErrorList{field.Forbidden(fldPath, "the nodes of an Autopilot cluster are provisioned by GKE")}
+	}
+
+	var allErrs field.ErrorList
+	types := map[string]bool{}
+	for i, limit := range autoscaling.ResourceLimits {
+		if types[limit.ResourceType] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Child("resourceLimits").Index(i).Child("resourceType"), limit.ResourceType))
+		}
+		types[limit.ResourceType] = true
+		if limit.Minimum > limit.Maximum {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("resourceLimits").Index(i).Child("minimum"), limit.Minimum, "must not be greater than the maximum"))
+		}
+	}
+	for _, resourceType := range []string{"cpu", "memory"} {
+		if !types[resourceType] {
+			allErrs = append(allErrs, field.Required(fldPath.Child("resourceLimits"), "the limits of the "+resourceType+" are required"))
+		}
+	}
+
+	return allErrs
+}
+
+// validateVersionSkew returns why GKE can't upgrade the control plane from the current version to the desired one,
+// the versions without a minor version, e.g. latest, being left to GKE.
+func validateVersionSkew(current, desired string) string {
+	currentMajor, currentMinor, ok := minorVersion(current)
+	if !ok {
+		return ""
+	}
+	desiredMajor, desiredMinor, ok := minorVersion(desired)
+	if !ok {
+		return ""
+	}
+	switch {
+	case desiredMajor < currentMajor || desiredMajor == currentMajor && desiredMinor < currentMinor:
+		return "the control plane can't be downgraded from " + current
+	case desiredMajor > currentMajor || desiredMinor > currentMinor+1:
+		return "the control plane is upgraded one minor version at a time from " + current
+	}
+
+	return ""
+}
+
+// minorVersion returns the major and minor versions of a Kubernetes version, e.g. 1.21 or v1.21.5-gke.1302.
+func minorVersion(version string) (int, int, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return major, minor, true
+}
@@ -0,0 +1,227 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	"k8s.io/utils/pointer"
+
+	infrav1 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+)
+
+func TestGCPManagedControlPlaneValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(spec *GCPManagedControlPlaneSpec)
+		wantErr string
+	}{
+		{
+			name: "release channel",
+			update: func(spec *GCPManagedControlPlaneSpec) {
+				spec.ReleaseChannel = (*ReleaseChannel)(pointer.StringPtr("stable"))
+			},
+		},
+		{
+			name:   "control plane version",
+			update: func(spec *GCPManagedControlPlaneSpec) { spec.ControlPlaneVersion = pointer.StringPtr("1.21") },
+		},
+		{
+			name: "control plane minor upgrade",
+			update: func(spec *GCPManagedControlPlaneSpec) {
+				spec.ControlPlaneVersion = pointer.StringPtr("v1.22.2-gke.1300")
+			},
+		},
+		{
+			name:    "control plane upgrade skipping a minor version",
+			update:  func(spec *GCPManagedControlPlaneSpec) { spec.ControlPlaneVersion = pointer.StringPtr("1.23") },
+			wantErr: "spec.controlPlaneVersion",
+		},
+		{
+			name:    "control plane downgrade",
+			update:  func(spec *GCPManagedControlPlaneSpec) { spec.ControlPlaneVersion = pointer.StringPtr("1.20") },
+			wantErr: "spec.controlPlaneVersion",
+		},
+		{
+			name:    "cluster name",
+			update:  func(spec *GCPManagedControlPlaneSpec) { spec.ClusterName = "other-cluster" },
+			wantErr: "spec.clusterName",
+		},
+		{
+			name:    "location",
+			update:  func(spec *GCPManagedControlPlaneSpec) { spec.Location = pointer.StringPtr("us-central1-b") },
+			wantErr: "spec.location",
+		},
+		{
+			name:    "unset location",
+			update:  func(spec *GCPManagedControlPlaneSpec) { spec.Location = nil },
+			wantErr: "spec.location",
+		},
+		{
+			name:    "autopilot",
+			update:  func(spec *GCPManagedControlPlaneSpec) { spec.EnableAutopilot = true },
+			wantErr: "spec.enableAutopilot",
+		},
+		{
+			name: "ip allocation policy",
+			update: func(spec *GCPManagedControlPlaneSpec) {
+				spec.IPAllocationPolicy = &IPAllocationPolicy{ClusterCidrBlock: "/14"}
+			},
+			wantErr: "spec.ipAllocationPolicy",
+		},
+		{
+			name: "cluster autoscaling",
+			update: func(spec *GCPManagedControlPlaneSpec) {
+				spec.ClusterAutoscaling = &ClusterAutoscaling{
+					ResourceLimits: []ResourceLimit{{ResourceType: "cpu", Maximum: 8}, {ResourceType: "memory", Maximum: 32}},
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			old := &GCPManagedControlPlane{
+				Spec:   GCPManagedControlPlaneSpec{ClusterName: "my-cluster", Location: pointer.StringPtr("us-central1-a")},
+				Status: GCPManagedControlPlaneStatus{CurrentVersion: "1.21.5-gke.1302"},
+			}
+			controlPlane := old.DeepCopy()
+			tt.update(&controlPlane.Spec)
+			err := controlPlane.ValidateUpdate(old)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+			}
+		})
+	}
+}
+
+func TestGCPManagedControlPlaneValidateClusterAutoscaling(t *testing.T) {
+	tests := []struct {
+		name        string
+		autopilot   bool
+		autoscaling *ClusterAutoscaling
+		wantErr     string
+	}{
+		{
+			name: "cpu and memory limits",
+			autoscaling: &ClusterAutoscaling{
+				ResourceLimits: []ResourceLimit{{ResourceType: "cpu", Minimum: 2, Maximum: 8}, {ResourceType: "memory", Maximum: 32}},
+			},
+		},
+		{
+			name: "missing memory limit",
+			autoscaling: &ClusterAutoscaling{
+				ResourceLimits: []ResourceLimit{{ResourceType: "cpu", Maximum: 8}, {ResourceType: "nvidia-tesla-t4", Maximum: 2}},
+			},
+			wantErr: "the limits of the memory are required",
+		},
+		{
+			name: "duplicate limit",
+			autoscaling: &ClusterAutoscaling{
+				ResourceLimits: []ResourceLimit{{ResourceType: "cpu", Maximum: 8}, {ResourceType: "memory", Maximum: 32}, {ResourceType: "cpu", Maximum: 4}},
+			},
+			wantErr: "spec.clusterAutoscaling.resourceLimits[2].resourceType",
+		},
+		{
+			name: "minimum greater than maximum",
+			autoscaling: &ClusterAutoscaling{
+				ResourceLimits: []ResourceLimit{{ResourceType: "cpu", Minimum: 16, Maximum: 8}, {ResourceType: "memory", Maximum: 32}},
+			},
+			wantErr: "spec.clusterAutoscaling.resourceLimits[0].minimum",
+		},
+		{
+			name:      "autopilot",
+			autopilot: true,
+			autoscaling: &ClusterAutoscaling{
+				ResourceLimits: []ResourceLimit{{ResourceType: "cpu", Maximum: 8}, {ResourceType: "memory", Maximum: 32}},
+			},
+			wantErr: "spec.clusterAutoscaling",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			controlPlane := &GCPManagedControlPlane{Spec: GCPManagedControlPlaneSpec{EnableAutopilot: tt.autopilot, ClusterAutoscaling: tt.autoscaling}}
+			err := controlPlane.ValidateCreate()
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+			}
+		})
+	}
+}
+
+func TestGCPManagedControlPlaneValidateIPAllocationPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  *IPAllocationPolicy
+		wantErr string
+	}{
+		{
+			name:   "secondary range names",
+			policy: &IPAllocationPolicy{ClusterSecondaryRangeName: "pods", ServicesSecondaryRangeName: "services"},
+		},
+		{
+			name:   "cidr blocks",
+			policy: &IPAllocationPolicy{ClusterCidrBlock: "10.96.0.0/14", ServicesCidrBlock: "/20"},
+		},
+		{
+			name:    "secondary range name and cidr block",
+			policy:  &IPAllocationPolicy{ClusterSecondaryRangeName: "pods", ClusterCidrBlock: "/14"},
+			wantErr: "spec.ipAllocationPolicy.clusterCidrBlock",
+		},
+		{
+			name:    "invalid cidr block",
+			policy:  &IPAllocationPolicy{ServicesCidrBlock: "10.100.0.0"},
+			wantErr: "spec.ipAllocationPolicy.servicesCidrBlock",
+		},
+		{
+			name:    "invalid size",
+			policy:  &IPAllocationPolicy{ClusterCidrBlock: "/33"},
+			wantErr: "spec.ipAllocationPolicy.clusterCidrBlock",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			controlPlane := &GCPManagedControlPlane{Spec: GCPManagedControlPlaneSpec{IPAllocationPolicy: tt.policy}}
+			err := controlPlane.ValidateCreate()
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+			}
+		})
+	}
+}
+
+func TestGCPManagedControlPlaneValidateDelete(t *testing.T) {
+	g := NewWithT(t)
+
+	controlPlane := &GCPManagedControlPlane{}
+	g.Expect(controlPlane.ValidateDelete()).To(Succeed())
+
+	controlPlane.Annotations = map[string]string{infrav1.DeletionProtectionAnnotation: ""}
+	g.Expect(controlPlane.ValidateDelete()).To(MatchError(ContainSubstring("deletion protection is enabled")))
+}
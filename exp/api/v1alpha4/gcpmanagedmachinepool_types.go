@@ -0,0 +1,162 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+)
+
+const (
+	// ManagedMachinePoolFinalizer allows ReconcileGCPManagedMachinePool to delete the GKE node pool before
+	// removing the GCPManagedMachinePool from the apiserver.
+	ManagedMachinePoolFinalizer = "gcpmanagedmachinepool.infrastructure.cluster.x-k8s.io"
+)
+
+// NodePoolAutoscaling has the GKE cluster autoscaler scale the node pool between its minimum and maximum number
+// of nodes per zone, the replicas of the MachinePool being left to the autoscaler.
+type NodePoolAutoscaling struct {
+	// MinCount is the minimum number of nodes of the node pool per zone.
+	// +kubebuilder:validation:Minimum=0
+	MinCount int32 `json:"minCount"`
+
+	// MaxCount is the maximum number of nodes of the node pool per zone.
+	// +kubebuilder:validation:Minimum=1
+	MaxCount int32 `json:"maxCount"`
+}
+
+// NodeKubeletConfig is the kubelet configuration of the nodes of a GKE node pool.
+type NodeKubeletConfig struct {
+	// CPUManagerPolicy is the CPU management policy of the kubelet: none, the default, or static, which gives
+	// exclusive CPUs to the containers of the Guaranteed pods with integer CPU requests.
+	// +kubebuilder:validation:Enum=none;static
+	// +optional
+	CPUManagerPolicy string `json:"cpuManagerPolicy,omitempty"`
+
+	// CPUCFSQuota enforces the CPU limits of the containers with the CFS quota, defaults to true.
+	// +optional
+	CPUCFSQuota *bool `json:"cpuCFSQuota,omitempty"`
+
+	// CPUCFSQuotaPeriod is the period of the CFS quota, e.g. 100ms, the default, between 1ms and 1s.
+	// +optional
+	CPUCFSQuotaPeriod string `json:"cpuCFSQuotaPeriod,omitempty"`
+}
+
+// GCPManagedMachinePoolSpec defines the desired state of GCPManagedMachinePool.
+type GCPManagedMachinePoolSpec struct {
+	// NodePoolName is the name of the GKE node pool, defaults to the name of the GCPManagedMachinePool. It can't
+	// be changed.
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=40
+	// +optional
+	NodePoolName string `json:"nodePoolName,omitempty"`
+
+	// MachineType is the machine type of the nodes, defaults to e2-medium. It can't be changed.
+	// +optional
+	MachineType *string `json:"machineType,omitempty"`
+
+	// DiskSizeGB is the size of the boot disk of the nodes in GB, defaults to 100. It can't be changed.
+	// +kubebuilder:validation:Minimum=10
+	// +optional
+	DiskSizeGB *int64 `json:"diskSizeGB,omitempty"`
+
+	// Preemptible creates preemptible nodes, which GCE can stop at any time and at the latest after 24 hours, at a
+	// lower price. It can't be changed.
+	// +optional
+	Preemptible bool `json:"preemptible,omitempty"`
+
+	// KubeletConfig, if set, tunes the kubelet of the nodes. It can't be changed.
+	// +optional
+	KubeletConfig *NodeKubeletConfig `json:"kubeletConfig,omitempty"`
+
+	// LinuxSysctls are the sysctls of the kernel of the nodes, e.g. net.core.somaxconn, among the ones GKE
+	// allows. They can't be changed.
+	// +optional
+	LinuxSysctls map[string]string `json:"linuxSysctls,omitempty"`
+
+	// KubernetesLabels are the labels of the nodes. They can't be changed.
+	// +optional
+	KubernetesLabels map[string]string `json:"kubernetesLabels,omitempty"`
+
+	// KubernetesTaints are the taints of the nodes. They can't be changed.
+	// +optional
+	KubernetesTaints []corev1.Taint `json:"kubernetesTaints,omitempty"`
+
+	// Scaling, if set, has the GKE cluster autoscaler scale the node pool instead of the MachinePool.
+	// +optional
+	Scaling *NodePoolAutoscaling `json:"scaling,omitempty"`
+
+	// ProviderIDList are the provider IDs of the instances of the node pool.
+	// +optional
+	ProviderIDList []string `json:"providerIDList,omitempty"`
+}
+
+// GCPManagedMachinePoolStatus defines the observed state of GCPManagedMachinePool.
+type GCPManagedMachinePoolStatus struct {
+	// Ready denotes that the node pool is running.
+	// +optional
+	Ready bool `json:"ready"`
+
+	// Replicas is the number of instances of the node pool.
+	// +optional
+	Replicas int32 `json:"replicas"`
+
+	// Conditions defines current service state of the GCPManagedMachinePool.
+	// +optional
+	Conditions clusterv1.Conditions `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:path=gcpmanagedmachinepools,scope=Namespaced,categories=cluster-api
+// +kubebuilder:storageversion
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Cluster",type="string",JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this GCPManagedMachinePool belongs"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="The node pool is running"
+// +kubebuilder:printcolumn:name="Replicas",type="string",JSONPath=".status.replicas",description="Number of instances of the node pool"
+
+// GCPManagedMachinePool is the Schema for the gcpmanagedmachinepools API, a GKE node pool of a MachinePool.
+type GCPManagedMachinePool struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GCPManagedMachinePoolSpec   `json:"spec,omitempty"`
+	Status GCPManagedMachinePoolStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// GCPManagedMachinePoolList contains a list of GCPManagedMachinePool.
+type GCPManagedMachinePoolList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []GCPManagedMachinePool `json:"items"`
+}
+
+// GetConditions returns the observations of the operational state of the GCPManagedMachinePool resource.
+func (r *GCPManagedMachinePool) GetConditions() clusterv1.Conditions {
+	return r.Status.Conditions
+}
+
+// SetConditions sets the underlying service state of the GCPManagedMachinePool to the predescribed clusterv1.Conditions.
+func (r *GCPManagedMachinePool) SetConditions(conditions clusterv1.Conditions) {
+	r.Status.Conditions = conditions
+}
+
+func init() {
+	SchemeBuilder.Register(&GCPManagedMachinePool{}, &GCPManagedMachinePoolList{})
+}
@@ -0,0 +1,124 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"reflect"
+	"regexp"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// machinepoollog is for logging in this package.
+var machinepoollog = logf.Log.WithName("gcpmanagedmachinepool-resource")
+
+// SetupWebhookWithManager sets up and registers the webhook with the manager.
+func (r *GCPManagedMachinePool) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-infrastructure-cluster-x-k8s-io-v1alpha4-gcpmanagedmachinepool,mutating=false,failurePolicy=fail,matchPolicy=Equivalent,groups=infrastructure.cluster.x-k8s.io,resources=gcpmanagedmachinepools,versions=v1alpha4,name=validation.gcpmanagedmachinepool.infrastructure.cluster.x-k8s.io,sideEffects=None,admissionReviewVersions=v1beta1
+
+var _ webhook.Validator = &GCPManagedMachinePool{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPManagedMachinePool) ValidateCreate() error {
+	machinepoollog.Info("validate create", "name", r.Name)
+
+	if allErrs := r.validate(); len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPManagedMachinePool").GroupKind(), r.Name, allErrs)
+	}
+
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPManagedMachinePool) ValidateUpdate(oldRaw runtime.Object) error {
+	machinepoollog.Info("validate update", "name", r.Name)
+	old := oldRaw.(*GCPManagedMachinePool)
+
+	// The node pool is looked up by its name, and the configuration of its nodes isn't updated by the controller:
+	// a new GCPManagedMachinePool rolls out the change.
+	allErrs := r.validate()
+	fldPath := field.NewPath("spec")
+	if r.Spec.NodePoolName != old.Spec.NodePoolName {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("nodePoolName"), r.Spec.NodePoolName, "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.MachineType, old.Spec.MachineType) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("machineType"), r.Spec.MachineType, "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.DiskSizeGB, old.Spec.DiskSizeGB) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("diskSizeGB"), r.Spec.DiskSizeGB, "field is immutable"))
+	}
+	if r.Spec.Preemptible != old.Spec.Preemptible {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("preemptible"), r.Spec.Preemptible, "field is immutable"))
+	}
+	if !reflect.DeepEqual(r.Spec.KubeletConfig, old.Spec.KubeletConfig) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("kubeletConfig"), r.Spec.KubeletConfig, "field is immutable"))
+	}
+	if (len(r.Spec.LinuxSysctls) > 0 || len(old.Spec.LinuxSysctls) > 0) && !reflect.DeepEqual(r.Spec.LinuxSysctls, old.Spec.LinuxSysctls) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("linuxSysctls"), r.Spec.LinuxSysctls, "field is immutable"))
+	}
+	if (len(r.Spec.KubernetesLabels) > 0 || len(old.Spec.KubernetesLabels) > 0) && !reflect.DeepEqual(r.Spec.KubernetesLabels, old.Spec.KubernetesLabels) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("kubernetesLabels"), r.Spec.KubernetesLabels, "field is immutable"))
+	}
+	if (len(r.Spec.KubernetesTaints) > 0 || len(old.Spec.KubernetesTaints) > 0) && !reflect.DeepEqual(r.Spec.KubernetesTaints, old.Spec.KubernetesTaints) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("kubernetesTaints"), r.Spec.KubernetesTaints, "field is immutable"))
+	}
+
+	if len(allErrs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("GCPManagedMachinePool").GroupKind(), r.Name, allErrs)
+	}
+
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type.
+func (r *GCPManagedMachinePool) ValidateDelete() error {
+	return nil
+}
+
+// sysctlName matches the names of the sysctls, e.g. net.core.somaxconn.
+var sysctlName = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)+$`)
+
+// validate returns the errors of the node configuration GKE would reject.
+func (r *GCPManagedMachinePool) validate() field.ErrorList {
+	var allErrs field.ErrorList
+	fldPath := field.NewPath("spec")
+	if config := r.Spec.KubeletConfig; config != nil && config.CPUCFSQuotaPeriod != "" {
+		period, err := time.ParseDuration(config.CPUCFSQuotaPeriod)
+		if err != nil || period < time.Millisecond || period > time.Second {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("kubeletConfig", "cpuCFSQuotaPeriod"), config.CPUCFSQuotaPeriod,
+				"must be a duration between 1ms and 1s"))
+		}
+	}
+	for name := range r.Spec.LinuxSysctls {
+		if !sysctlName.MatchString(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("linuxSysctls").Key(name), name, "must be the name of a sysctl, e.g. net.core.somaxconn"))
+		}
+	}
+
+	return allErrs
+}
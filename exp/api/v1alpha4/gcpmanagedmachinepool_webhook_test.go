@@ -0,0 +1,146 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha4
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/pointer"
+)
+
+func TestGCPManagedMachinePoolValidateUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		update  func(spec *GCPManagedMachinePoolSpec)
+		wantErr string
+	}{
+		{
+			name:   "scaling",
+			update: func(spec *GCPManagedMachinePoolSpec) { spec.Scaling = &NodePoolAutoscaling{MinCount: 1, MaxCount: 3} },
+		},
+		{
+			name:   "empty taints",
+			update: func(spec *GCPManagedMachinePoolSpec) { spec.KubernetesTaints = []corev1.Taint{} },
+		},
+		{
+			name:    "node pool name",
+			update:  func(spec *GCPManagedMachinePoolSpec) { spec.NodePoolName = "other-pool" },
+			wantErr: "spec.nodePoolName",
+		},
+		{
+			name:    "machine type",
+			update:  func(spec *GCPManagedMachinePoolSpec) { spec.MachineType = pointer.StringPtr("e2-standard-4") },
+			wantErr: "spec.machineType",
+		},
+		{
+			name:    "disk size",
+			update:  func(spec *GCPManagedMachinePoolSpec) { spec.DiskSizeGB = pointer.Int64Ptr(200) },
+			wantErr: "spec.diskSizeGB",
+		},
+		{
+			name:    "preemptible",
+			update:  func(spec *GCPManagedMachinePoolSpec) { spec.Preemptible = true },
+			wantErr: "spec.preemptible",
+		},
+		{
+			name: "kubelet config",
+			update: func(spec *GCPManagedMachinePoolSpec) {
+				spec.KubeletConfig = &NodeKubeletConfig{CPUManagerPolicy: "static"}
+			},
+			wantErr: "spec.kubeletConfig",
+		},
+		{
+			name: "sysctls",
+			update: func(spec *GCPManagedMachinePoolSpec) {
+				spec.LinuxSysctls = map[string]string{"net.core.somaxconn": "4096"}
+			},
+			wantErr: "spec.linuxSysctls",
+		},
+		{
+			name:    "labels",
+			update:  func(spec *GCPManagedMachinePoolSpec) { spec.KubernetesLabels["tier"] = "backend" },
+			wantErr: "spec.kubernetesLabels",
+		},
+		{
+			name: "taints",
+			update: func(spec *GCPManagedMachinePoolSpec) {
+				spec.KubernetesTaints = []corev1.Taint{{Key: "dedicated", Value: "gpu", Effect: corev1.TaintEffectNoSchedule}}
+			},
+			wantErr: "spec.kubernetesTaints",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			old := &GCPManagedMachinePool{Spec: GCPManagedMachinePoolSpec{
+				NodePoolName:     "my-pool",
+				MachineType:      pointer.StringPtr("e2-medium"),
+				KubernetesLabels: map[string]string{"tier": "frontend"},
+			}}
+			pool := old.DeepCopy()
+			tt.update(&pool.Spec)
+			err := pool.ValidateUpdate(old)
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+			}
+		})
+	}
+}
+
+func TestGCPManagedMachinePoolValidateCreate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    GCPManagedMachinePoolSpec
+		wantErr string
+	}{
+		{
+			name: "node system config",
+			spec: GCPManagedMachinePoolSpec{
+				KubeletConfig: &NodeKubeletConfig{CPUManagerPolicy: "static", CPUCFSQuota: pointer.BoolPtr(false), CPUCFSQuotaPeriod: "50ms"},
+				LinuxSysctls:  map[string]string{"net.core.somaxconn": "4096"},
+			},
+		},
+		{
+			name:    "cfs quota period",
+			spec:    GCPManagedMachinePoolSpec{KubeletConfig: &NodeKubeletConfig{CPUCFSQuotaPeriod: "2s"}},
+			wantErr: "spec.kubeletConfig.cpuCFSQuotaPeriod",
+		},
+		{
+			name:    "sysctl name",
+			spec:    GCPManagedMachinePoolSpec{LinuxSysctls: map[string]string{"somaxconn": "4096"}},
+			wantErr: "spec.linuxSysctls[somaxconn]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := NewWithT(t)
+
+			pool := &GCPManagedMachinePool{Spec: tt.spec}
+			err := pool.ValidateCreate()
+			if tt.wantErr == "" {
+				g.Expect(err).NotTo(HaveOccurred())
+			} else {
+				g.Expect(err).To(MatchError(ContainSubstring(tt.wantErr)))
+			}
+		})
+	}
+}
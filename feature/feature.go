@@ -0,0 +1,70 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package feature implements the feature gates of the provider.
+package feature
+
+import (
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/component-base/featuregate"
+)
+
+const (
+	// ComputeBetaAPI enables the features relying on the compute beta API. When disabled, the provider
+	// only talks to the GA API and the features requiring the beta API are rejected.
+	//
+	// alpha: v0.4
+	ComputeBetaAPI featuregate.Feature = "ComputeBetaAPI"
+
+	// ComputeAlphaAPI enables the features relying on the compute alpha API, which is only available to
+	// the projects allowlisted by Google.
+	//
+	// alpha: v0.4
+	ComputeAlphaAPI featuregate.Feature = "ComputeAlphaAPI"
+
+	// GKE enables the GCPManagedCluster, GCPManagedControlPlane and GCPManagedMachinePool controllers, which
+	// provision GKE clusters and node pools instead of instances.
+	//
+	// alpha: v0.4
+	GKE featuregate.Feature = "GKE"
+
+	// MachinePool enables the GCPMachinePool controller, which provisions the instances of the MachinePools with
+	// regional managed instance groups.
+	//
+	// alpha: v0.4
+	MachinePool featuregate.Feature = "MachinePool"
+)
+
+var (
+	// MutableGates is a mutable version of Gates, set from the --feature-gates flag.
+	MutableGates featuregate.MutableFeatureGate = featuregate.NewFeatureGate()
+
+	// Gates is a shared global FeatureGate.
+	Gates featuregate.FeatureGate = MutableGates
+)
+
+func init() {
+	runtime.Must(MutableGates.Add(defaultGCPFeatureGates))
+}
+
+// defaultGCPFeatureGates consists of all known GCP-specific feature keys.
+// To add a new feature, define a key for it above and add it here.
+var defaultGCPFeatureGates = map[featuregate.Feature]featuregate.FeatureSpec{
+	ComputeBetaAPI:  {Default: false, PreRelease: featuregate.Alpha},
+	ComputeAlphaAPI: {Default: false, PreRelease: featuregate.Alpha},
+	GKE:             {Default: false, PreRelease: featuregate.Alpha},
+	MachinePool:     {Default: false, PreRelease: featuregate.Alpha},
+}
@@ -3,13 +3,17 @@ module sigs.k8s.io/cluster-api-provider-gcp
 go 1.16
 
 require (
+	cloud.google.com/go v0.83.0
 	github.com/blang/semver/v4 v4.0.0
 	github.com/go-logr/logr v0.4.0
 	github.com/onsi/ginkgo v1.16.4
 	github.com/onsi/gomega v1.13.0
 	github.com/pkg/errors v0.9.1
+	github.com/prometheus/client_golang v1.11.0
 	github.com/spf13/pflag v1.0.5
 	golang.org/x/net v0.0.0-20210614182718-04defd469f4e
+	golang.org/x/oauth2 v0.0.0-20210514164344-f6687ab2804c
+	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba
 	google.golang.org/api v0.48.0
 	k8s.io/api v0.21.2
 	k8s.io/apimachinery v0.21.2
@@ -17,32 +17,43 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
+	"strings"
 	"time"
 
 	// +kubebuilder:scaffold:imports
 
 	"github.com/spf13/pflag"
+	"golang.org/x/oauth2"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 	cgrecord "k8s.io/client-go/tools/record"
 	"k8s.io/component-base/version"
 	"k8s.io/klog/v2"
 	"k8s.io/klog/v2/klogr"
 
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha4"
+	expclusterv1 "sigs.k8s.io/cluster-api/exp/api/v1alpha4"
 	"sigs.k8s.io/cluster-api/util/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 
 	infrav1alpha3 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha3"
 	infrav1alpha4 "sigs.k8s.io/cluster-api-provider-gcp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud"
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/scope"
 	"sigs.k8s.io/cluster-api-provider-gcp/controllers"
+	expinfrav1 "sigs.k8s.io/cluster-api-provider-gcp/exp/api/v1alpha4"
+	"sigs.k8s.io/cluster-api-provider-gcp/feature"
 	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
 )
 
@@ -58,23 +69,61 @@ func init() {
 	_ = infrav1alpha3.AddToScheme(scheme)
 	_ = infrav1alpha4.AddToScheme(scheme)
 	_ = clusterv1.AddToScheme(scheme)
+	_ = expinfrav1.AddToScheme(scheme)
+	_ = expclusterv1.AddToScheme(scheme)
 	// +kubebuilder:scaffold:scheme
 }
 
 var (
 	enableLeaderElection        bool
+	dryRun                      bool
+	requireServiceAccount       bool
+	captureSerialConsole        bool
+	checkCredentials            bool
+	preflightChecks             bool
+	exportMetrics               bool
 	metricsAddr                 string
 	leaderElectionNamespace     string
-	watchNamespace              string
+	leaderElectionResourceLock  string
+	watchNamespaces             []string
+	impersonateDelegates        []string
 	profilerAddress             string
 	healthAddr                  string
+	healthCheckProject          string
+	instanceEventsSubscription  string
 	watchFilterValue            string
+	auditSinkName               string
+	faultInjection              string
+	gcpAPIEndpoints             string
+	gcpUserAgent                string
+	impersonateServiceAccount   string
+	gcpAPIRateLimits            string
+	caBundle                    string
+	googleAccess                string
 	webhookCertDir              string
 	gcpClusterConcurrency       int
 	gcpMachineConcurrency       int
+	gcpMachinePriority          int
+	shardCount                  int
+	shardIndex                  int
 	webhookPort                 int
+	eventBurst                  int
+	eventQPS                    float32
+	gcpAPIBurst                 int
+	gcpAPIMaxRetries            int
+	gcpAPIQPS                   float64
+	requeueJitter               float64
+	provisioningTimeout         time.Duration
+	bootstrapTimeout            time.Duration
+	instanceResyncInterval      time.Duration
+	clusterResyncInterval       time.Duration
+	clusterIdleInterval         time.Duration
 	reconcileTimeout            time.Duration
 	syncPeriod                  time.Duration
+	lookupCacheTTL              time.Duration
+	failureDomainRefresh        time.Duration
+	zoneIncidentWindow          time.Duration
+	eventDedupWindow            time.Duration
 	leaderElectionLeaseDuration time.Duration
 	leaderElectionRenewDeadline time.Duration
 	leaderElectionRetryPeriod   time.Duration
@@ -85,11 +134,33 @@ func main() {
 	pflag.CommandLine.AddGoFlagSet(flag.CommandLine)
 	pflag.Parse()
 
-	if watchNamespace != "" {
+	var watchNamespace string
+	var newCache cache.NewCacheFunc
+	switch len(watchNamespaces) {
+	case 0:
+	case 1:
+		watchNamespace = watchNamespaces[0]
 		setupLog.Info("Watching cluster-api objects only in namespace for reconciliation", "namespace", watchNamespace)
+	default:
+		newCache = cache.MultiNamespacedCacheBuilder(watchNamespaces)
+		setupLog.Info("Watching cluster-api objects only in namespaces for reconciliation", "namespaces", watchNamespaces)
 	}
 
+	shard := reconciler.Shard{Index: shardIndex, Count: shardCount}
+	if err := shard.Validate(); err != nil {
+		setupLog.Error(err, "invalid shard")
+		os.Exit(1)
+	}
+	// The replicas of a shard elect their leader among themselves.
+	leaderElectionID := "controller-leader-election-capg"
+	if shard.Count > 1 {
+		leaderElectionID = fmt.Sprintf("%s-shard-%d", leaderElectionID, shard.Index)
+		setupLog.Info("Reconciling a shard of the clusters", "shard-index", shard.Index, "shard-count", shard.Count)
+	}
+
+	clientStats := &cloud.ClientStats{}
 	if profilerAddress != "" {
+		http.Handle("/debug/gcp", clientStats)
 		setupLog.Info("Profiler listening for requests", "profiler-address", profilerAddress)
 		go func() {
 			setupLog.Error(http.ListenAndServe(profilerAddress, nil), "listen and serve error")
@@ -98,27 +169,69 @@ func main() {
 
 	ctrl.SetLogger(klogr.New())
 
+	if caBundle != "" {
+		if err := cloud.TrustCABundle(caBundle); err != nil {
+			setupLog.Error(err, "unable to trust the gcp CA bundle")
+			os.Exit(1)
+		}
+	}
+
+	access, err := cloud.ParseGoogleAccess(googleAccess)
+	if err != nil {
+		setupLog.Error(err, "invalid google access")
+		os.Exit(1)
+	}
+	if err := cloud.UseGoogleAccess(context.Background(), access); err != nil {
+		setupLog.Error(err, "unable to use the google access", "google-api-access", access)
+		os.Exit(1)
+	}
+
+	// A manager deployed without a key, e.g. with GKE Workload Identity, still mounts the empty credentials Secret.
+	if unset, err := cloud.IgnoreEmptyCredentialsFile(); err != nil {
+		setupLog.Error(err, "unable to ignore the empty gcp credentials file")
+		os.Exit(1)
+	} else if unset {
+		setupLog.Info("The gcp credentials file is empty, using the credentials of the metadata server")
+	}
+	var tokenSource oauth2.TokenSource
+	if impersonateServiceAccount != "" {
+		if tokenSource, err = cloud.ImpersonatedTokenSource(context.Background(), impersonateServiceAccount, impersonateDelegates); err != nil {
+			setupLog.Error(err, "unable to impersonate the gcp service account")
+			os.Exit(1)
+		}
+		setupLog.Info("Impersonating the gcp service account", "service-account", impersonateServiceAccount)
+	}
+	if checkCredentials {
+		if err := checkGCPCredentials(tokenSource); err != nil {
+			setupLog.Error(err, "invalid gcp credentials")
+			os.Exit(1)
+		}
+	}
+
 	// Machine and cluster operations can create enough events to trigger the event recorder spam filter
 	// Setting the burst size higher ensures all events will be recorded and submitted to the API
 	broadcaster := cgrecord.NewBroadcasterWithCorrelatorOptions(cgrecord.CorrelatorOptions{
-		BurstSize: 100,
+		BurstSize: eventBurst,
+		QPS:       eventQPS,
 	})
 
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
-		Scheme:                  scheme,
-		MetricsBindAddress:      metricsAddr,
-		LeaderElection:          enableLeaderElection,
-		LeaderElectionID:        "controller-leader-election-capg",
-		LeaderElectionNamespace: leaderElectionNamespace,
-		LeaseDuration:           &leaderElectionLeaseDuration,
-		RenewDeadline:           &leaderElectionRenewDeadline,
-		RetryPeriod:             &leaderElectionRetryPeriod,
-		SyncPeriod:              &syncPeriod,
-		Namespace:               watchNamespace,
-		Port:                    webhookPort,
-		CertDir:                 webhookCertDir,
-		HealthProbeBindAddress:  healthAddr,
-		EventBroadcaster:        broadcaster,
+		Scheme:                     scheme,
+		MetricsBindAddress:         metricsAddr,
+		LeaderElection:             enableLeaderElection,
+		LeaderElectionID:           leaderElectionID,
+		LeaderElectionNamespace:    leaderElectionNamespace,
+		LeaderElectionResourceLock: leaderElectionResourceLock,
+		LeaseDuration:              &leaderElectionLeaseDuration,
+		RenewDeadline:              &leaderElectionRenewDeadline,
+		RetryPeriod:                &leaderElectionRetryPeriod,
+		SyncPeriod:                 &syncPeriod,
+		Namespace:                  watchNamespace,
+		NewCache:                   newCache,
+		Port:                       webhookPort,
+		CertDir:                    webhookCertDir,
+		HealthProbeBindAddress:     healthAddr,
+		EventBroadcaster:           broadcaster,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
@@ -126,30 +239,163 @@ func main() {
 	}
 
 	// Initialize event recorder.
-	record.InitFromRecorder(mgr.GetEventRecorderFor("gcp-controller"))
+	// The events repeated by the reconciles failing persistently are dropped within the dedup window.
+	recorder := mgr.GetEventRecorderFor("gcp-controller")
+	if eventDedupWindow > 0 {
+		recorder = reconciler.NewDeduplicatingRecorder(recorder, eventDedupWindow)
+	}
+	record.InitFromRecorder(recorder)
 
 	// Setup the context that's going to be used in controllers and for the manager.
 	ctx := ctrl.SetupSignalHandler()
 
+	faults, err := cloud.ParseFaultInjection(faultInjection)
+	if err != nil {
+		setupLog.Error(err, "invalid fault injection")
+		os.Exit(1)
+	}
+	if faults != nil {
+		setupLog.Info("Injecting faults in the GCP API calls, for testing only", "fault-injection", faultInjection)
+	}
+
+	rateLimits, err := cloud.ParseRateLimits(gcpAPIRateLimits)
+	if err != nil {
+		setupLog.Error(err, "invalid GCP API rate limits")
+		os.Exit(1)
+	}
+	rateLimiter := &cloud.RateLimiter{
+		Default:    cloud.RateLimit{QPS: gcpAPIQPS, Burst: gcpAPIBurst},
+		Services:   rateLimits,
+		MaxRetries: gcpAPIMaxRetries,
+	}
+
+	var auditSink cloud.AuditSink
+	switch auditSinkName {
+	case "":
+	case "log":
+		auditSink = &cloud.LogAuditSink{Logger: ctrl.Log.WithName("audit")}
+	case "configmap":
+		// The audit ConfigMaps are read and written directly, they are not worth a cache.
+		auditClient, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+		if err != nil {
+			setupLog.Error(err, "unable to create the audit client")
+			os.Exit(1)
+		}
+		auditSink = &scope.ConfigMapAuditSink{Client: auditClient, Logger: ctrl.Log.WithName("audit")}
+	default:
+		setupLog.Error(fmt.Errorf("unknown audit sink %q", auditSinkName), "invalid audit sink")
+		os.Exit(1)
+	}
+
+	endpoints, err := cloud.ParseEndpoints(gcpAPIEndpoints)
+	if err != nil {
+		setupLog.Error(err, "invalid GCP API endpoints")
+		os.Exit(1)
+	}
+	gcp, err := cloud.NewCloud(ctx, cloud.Options{Endpoints: endpoints, UserAgent: gcpUserAgent, TokenSource: tokenSource})
+	if err != nil {
+		setupLog.Error(err, "unable to create the gcp clients")
+		os.Exit(1)
+	}
+
+	options := controllers.ReconcilerOptions{
+		Cloud:          gcp,
+		Stats:          clientStats,
+		Audit:          auditSink,
+		FaultInjection: faults,
+		RateLimiter:    rateLimiter,
+		DryRun:         dryRun,
+		Shard:          shard,
+		RequeueJitter:  requeueJitter,
+	}
+	lookupCache := cloud.NewLookupCache(lookupCacheTTL)
+	zoneIncidents := cloud.NewZoneIncidents(zoneIncidentWindow)
 	if err = (&controllers.GCPMachineReconciler{
-		Client:           mgr.GetClient(),
-		Log:              ctrl.Log.WithName("controllers").WithName("GCPMachine"),
-		ReconcileTimeout: reconcileTimeout,
-		WatchFilterValue: watchFilterValue,
+		Client:              mgr.GetClient(),
+		Log:                 ctrl.Log.WithName("controllers").WithName("GCPMachine"),
+		ReconcileTimeout:    reconcileTimeout,
+		WatchFilterValue:    watchFilterValue,
+		ReconcilerOptions:   options,
+		ProvisioningTimeout: provisioningTimeout,
+		BootstrapTimeout:    bootstrapTimeout,
+		Cache:               lookupCache,
+		ZoneIncidents:       zoneIncidents,
+		GoogleAccess:        access,
+
+		InstanceResyncInterval:        instanceResyncInterval,
+		RequireExplicitServiceAccount: requireServiceAccount,
+		CaptureSerialConsole:          captureSerialConsole,
+		PriorityConcurrency:           gcpMachinePriority,
+		StatusFieldManager:            "capg-gcpmachine-controller",
 	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: gcpMachineConcurrency}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GCPMachine")
 		os.Exit(1)
 	}
+	var metricsExporter cloud.MetricsExporter
+	if exportMetrics {
+		if metricsExporter, err = cloud.NewMonitoringExporter(ctx); err != nil {
+			setupLog.Error(err, "unable to create the cloud monitoring exporter")
+			os.Exit(1)
+		}
+	}
 	if err = (&controllers.GCPClusterReconciler{
-		Client:           mgr.GetClient(),
-		Log:              ctrl.Log.WithName("controllers").WithName("GCPCluster"),
-		ReconcileTimeout: reconcileTimeout,
-		WatchFilterValue: watchFilterValue,
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("GCPCluster"),
+		ReconcileTimeout:  reconcileTimeout,
+		WatchFilterValue:  watchFilterValue,
+		ReconcilerOptions: options,
+		Cache:             lookupCache,
+		ZoneIncidents:     zoneIncidents,
+		PreflightChecks:   preflightChecks,
+		GoogleAccess:      access,
+		Metrics:           metricsExporter,
+
+		FailureDomainRefreshInterval: failureDomainRefresh,
+		ResyncInterval:               clusterResyncInterval,
+		IdleInterval:                 clusterIdleInterval,
+		StatusFieldManager:           "capg-gcpcluster-controller",
 	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: gcpClusterConcurrency}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "GCPCluster")
 		os.Exit(1)
 	}
 
+	if feature.Gates.Enabled(feature.GKE) {
+		setupGKEReconcilers(ctx, mgr, options)
+	}
+	if feature.Gates.Enabled(feature.MachinePool) {
+		if err := (&controllers.GCPMachinePoolReconciler{
+			Client:            mgr.GetClient(),
+			Log:               ctrl.Log.WithName("controllers").WithName("GCPMachinePool"),
+			ReconcileTimeout:  reconcileTimeout,
+			WatchFilterValue:  watchFilterValue,
+			ReconcilerOptions: options,
+			Cache:             lookupCache,
+			GoogleAccess:      access,
+
+			RequireExplicitServiceAccount: requireServiceAccount,
+		}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: gcpMachineConcurrency}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "GCPMachinePool")
+			os.Exit(1)
+		}
+	}
+
+	if instanceEventsSubscription != "" {
+		events, err := cloud.NewPubSubInstanceEventReceiver(ctx, instanceEventsSubscription)
+		if err != nil {
+			setupLog.Error(err, "unable to create the instance events receiver")
+			os.Exit(1)
+		}
+		if err := mgr.Add(&controllers.InstanceEventWatcher{
+			Client:           mgr.GetClient(),
+			Log:              ctrl.Log.WithName("controllers").WithName("InstanceEvents"),
+			WatchFilterValue: watchFilterValue,
+			Events:           events,
+		}); err != nil {
+			setupLog.Error(err, "unable to add the instance events watcher")
+			os.Exit(1)
+		}
+	}
+
 	if err = (&infrav1alpha4.GCPCluster{}).SetupWebhookWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create webhook", "webhook", "GCPCluster")
 		os.Exit(1)
@@ -162,12 +408,25 @@ func main() {
 		setupLog.Error(err, "unable to create webhook", "webhook", "GCPMachineTemplate")
 		os.Exit(1)
 	}
+	if err = (&expinfrav1.GCPManagedControlPlane{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "GCPManagedControlPlane")
+		os.Exit(1)
+	}
+	if err = (&expinfrav1.GCPManagedMachinePool{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "GCPManagedMachinePool")
+		os.Exit(1)
+	}
 
 	if err := mgr.AddReadyzCheck("ping", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to create ready check")
 		os.Exit(1)
 	}
 
+	if err := addGCPReadyzCheck(ctx, mgr, gcp); err != nil {
+		setupLog.Error(err, "unable to create gcp ready check")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to create health check")
 		os.Exit(1)
@@ -181,6 +440,97 @@ func main() {
 	}
 }
 
+// setupGKEReconcilers sets up the reconcilers of the GKE managed clusters, whose kubeconfigs hold the access tokens of
+// their own service account, impersonated by the manager.
+func setupGKEReconcilers(ctx context.Context, mgr ctrl.Manager, options controllers.ReconcilerOptions) {
+	if err := (&controllers.GCPManagedClusterReconciler{
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("GCPManagedCluster"),
+		ReconcileTimeout:  reconcileTimeout,
+		WatchFilterValue:  watchFilterValue,
+		ReconcilerOptions: options,
+	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: gcpClusterConcurrency}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GCPManagedCluster")
+		os.Exit(1)
+	}
+	if err := (&controllers.GCPManagedControlPlaneReconciler{
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("GCPManagedControlPlane"),
+		ReconcileTimeout:  reconcileTimeout,
+		WatchFilterValue:  watchFilterValue,
+		ReconcilerOptions: options,
+		NewTokenSource: func(serviceAccount string) (oauth2.TokenSource, error) {
+			return cloud.GKETokenSource(ctx, serviceAccount)
+		},
+		// The kubeconfig Secrets are readable by the users of the clusters, they mustn't hold the credentials of the manager.
+		ManagerServiceAccount: impersonateServiceAccount,
+	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: gcpClusterConcurrency}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GCPManagedControlPlane")
+		os.Exit(1)
+	}
+	if err := (&controllers.GCPManagedMachinePoolReconciler{
+		Client:            mgr.GetClient(),
+		Log:               ctrl.Log.WithName("controllers").WithName("GCPManagedMachinePool"),
+		ReconcileTimeout:  reconcileTimeout,
+		WatchFilterValue:  watchFilterValue,
+		ReconcilerOptions: options,
+	}).SetupWithManager(ctx, mgr, controller.Options{MaxConcurrentReconciles: gcpMachineConcurrency}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "GCPManagedMachinePool")
+		os.Exit(1)
+	}
+}
+
+// defaultUserAgent returns the user agent of the GCP API calls, with the version of the manager if known.
+func defaultUserAgent() string {
+	if v := version.Get().String(); v != "" {
+		return "cluster-api-provider-gcp/" + v
+	}
+
+	return "cluster-api-provider-gcp"
+}
+
+// checkGCPCredentials verifies the scopes and the permissions of the credentials of the metadata server, or the
+// permissions of the impersonated service account if the token source is set.
+func checkGCPCredentials(tokenSource oauth2.TokenSource) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	project := healthCheckProject
+	if project == "" {
+		var err error
+		if project, err = cloud.DefaultProject(ctx); err != nil {
+			return err
+		}
+	}
+	if project == "" {
+		setupLog.Info("Skipping the gcp credentials check, no project found in the credentials nor set with --health-check-project")
+		return nil
+	}
+
+	if tokenSource != nil {
+		return cloud.CheckTokenSource(ctx, project, tokenSource)
+	}
+
+	return cloud.CheckCredentials(ctx, project)
+}
+
+// addGCPReadyzCheck reports the manager as not ready while the GCP credentials can't call the compute API.
+func addGCPReadyzCheck(ctx context.Context, mgr ctrl.Manager, gcp cloud.Cloud) error {
+	project := healthCheckProject
+	if project == "" {
+		var err error
+		if project, err = cloud.DefaultProject(ctx); err != nil {
+			return mgr.AddReadyzCheck("gcp", func(*http.Request) error { return err })
+		}
+	}
+	if project == "" {
+		setupLog.Info("Skipping the gcp ready check, no project found in the credentials nor set with --health-check-project")
+		return nil
+	}
+
+	return mgr.AddReadyzCheck("gcp", cloud.NewHealthChecker(gcp, project).Check)
+}
+
 func initFlags(fs *pflag.FlagSet) {
 	fs.StringVar(
 		&metricsAddr,
@@ -218,10 +568,17 @@ func initFlags(fs *pflag.FlagSet) {
 	)
 
 	fs.StringVar(
-		&watchNamespace,
+		&leaderElectionResourceLock,
+		"leader-elect-resource-lock",
+		resourcelock.ConfigMapsLeasesResourceLock,
+		"The resource used as leader election lock: leases, configmapsleases or configmaps. Switching from configmaps to leases must go through configmapsleases, so that the old and new managers contend for a common lock.",
+	)
+
+	fs.StringSliceVar(
+		&watchNamespaces,
 		"namespace",
-		"",
-		"Namespace that the controller watches to reconcile cluster-api objects. If unspecified, the controller watches for cluster-api objects across all namespaces.",
+		nil,
+		"Comma-separated list of namespaces that the controller watches to reconcile cluster-api objects, the flag can be repeated. If unspecified, the controller watches for cluster-api objects across all namespaces.",
 	)
 
 	fs.StringVar(
@@ -231,11 +588,92 @@ func initFlags(fs *pflag.FlagSet) {
 		"Namespace that the controller performs leader election in. If unspecified, the controller will discover which namespace it is running in.",
 	)
 
+	fs.StringVar(
+		&auditSinkName,
+		"audit-sink",
+		"",
+		"Where to record the mutating GCP API calls: log, to the audit logger, or configmap, to a <cluster>-gcp-audit ConfigMap per cluster. The calls are not recorded if empty",
+	)
+
+	fs.StringVar(
+		&caBundle,
+		"gcp-ca-bundle",
+		"",
+		"Path to a PEM bundle of CA certificates trusted by the GCP API clients besides the system ones, e.g. the CA of a TLS-inspecting proxy. The proxy is set with the HTTPS_PROXY and NO_PROXY environment variables",
+	)
+
+	fs.StringVar(
+		&googleAccess,
+		"google-api-access",
+		string(cloud.PublicGoogleAccess),
+		"How the GCP APIs are reached: public, on their public endpoints, private, through the private.googleapis.com VIP, or restricted, through the restricted.googleapis.com VIP of VPC Service Controls. The features unavailable without public access are reported in the FeaturesAvailable condition",
+	)
+
+	fs.StringVar(
+		&faultInjection,
+		"fault-injection",
+		os.Getenv("CAPG_FAULT_INJECTION"),
+		"Test only. Comma separated rates of the faults injected in the GCP API calls, e.g. quota=0.05,server-error=0.01,not-found-after-insert=0.1. Defaults to the CAPG_FAULT_INJECTION environment variable",
+	)
+
+	fs.Float64Var(&gcpAPIQPS,
+		"gcp-api-qps",
+		0,
+		"The rate, in calls per second, at which each GCP API is called by the manager, 0 for no limit",
+	)
+
+	fs.IntVar(&gcpAPIBurst,
+		"gcp-api-burst",
+		0,
+		"The number of calls which can be made at once to each GCP API before they are rate limited, defaults to the --gcp-api-qps",
+	)
+
+	fs.StringVar(
+		&gcpAPIRateLimits,
+		"gcp-api-rate-limits",
+		"",
+		"Comma separated rate limits of the GCP APIs overriding the --gcp-api-qps and --gcp-api-burst, in the <service>=<qps>[:<burst>] format, e.g. compute=20:40,dns=5",
+	)
+
+	fs.IntVar(&gcpAPIMaxRetries,
+		"gcp-api-max-retries",
+		cloud.DefaultMaxRetries,
+		"The number of times the GCP API calls rejected because a rate quota was exceeded are retried, after the Retry-After delay or with an exponential backoff",
+	)
+
+	fs.StringVar(
+		&gcpAPIEndpoints,
+		"gcp-api-endpoints",
+		"",
+		"Comma separated root URLs of the GCP APIs, in the <service>=<url> format, e.g. compute=https://compute-myendpoint.p.googleapis.com/, to reach them through Private Service Connect endpoints, the endpoints of a sovereign cloud or an emulator. The services are "+strings.Join(cloud.Services, ", "),
+	)
+
+	fs.StringVar(
+		&impersonateServiceAccount,
+		"impersonate-service-account",
+		"",
+		"The email of the service account impersonated for the GCP API calls, instead of using the application default credentials, e.g. those of GKE Workload Identity, directly. The credentials need the Service Account Token Creator role on the service account, or on the first of the --impersonate-delegates",
+	)
+
+	fs.StringSliceVar(
+		&impersonateDelegates,
+		"impersonate-delegates",
+		nil,
+		"Comma separated emails of the service accounts of the delegation chain to the --impersonate-service-account, each one impersonating the next",
+	)
+
+	fs.StringVar(
+		&gcpUserAgent,
+		"gcp-user-agent",
+		defaultUserAgent(),
+		"The user agent appended to the one of the GCP API calls, e.g. to tell the calls of the manager apart in the audit logs of the projects",
+	)
+
 	fs.StringVar(
 		&profilerAddress,
 		"profiler-address",
 		"",
-		"Bind address to expose the pprof profiler (e.g. localhost:6060)",
+		"Bind address to expose the pprof profiler, and the statistics of the GCP API calls at /debug/gcp (e.g. localhost:6060)",
 	)
 
 	fs.StringVar(
@@ -257,12 +695,138 @@ func initFlags(fs *pflag.FlagSet) {
 		"Number of GCPMachines to process simultaneously",
 	)
 
+	fs.IntVar(&gcpMachinePriority,
+		"gcpmachine-priority-concurrency",
+		0,
+		"Number of control plane and deleting GCPMachines to process simultaneously from a dedicated queue, so that they aren't delayed by the reconciles of the other GCPMachines under backlog. All the GCPMachines share a single queue if 0",
+	)
+
+	fs.IntVar(&shardCount,
+		"shard-count",
+		0,
+		"Number of shards the clusters are partitioned into, by the hash of their namespaced name, each shard being reconciled by the replicas started with its --shard-index. The clusters aren't sharded if 0 or 1",
+	)
+
+	fs.IntVar(&shardIndex,
+		"shard-index",
+		0,
+		"Index of the shard of the clusters reconciled by the replica, from 0 to --shard-count minus 1",
+	)
+
+	fs.Float64Var(&requeueJitter,
+		"requeue-jitter",
+		reconciler.DefaultRequeueJitter,
+		"Maximum factor by which the requeue intervals are randomly extended, to spread the reconciles of objects requeued together (e.g. 0.2 adds up to 20%)",
+	)
+
+	fs.DurationVar(&provisioningTimeout,
+		"instance-provisioning-timeout",
+		reconciler.DefaultInstanceProvisioningTimeout,
+		"Time a GCE instance can take to start running before its GCPMachine is failed, 0 disables the timeout",
+	)
+
+	fs.DurationVar(&bootstrapTimeout,
+		"bootstrap-timeout",
+		0,
+		"Time a GCE instance can take to report its bootstrap status in the capi/bootstrap guest attribute before its GCPMachine is failed, 0 disables the timeout, the status being then polled for an hour after the creation of the instance",
+	)
+
+	fs.BoolVar(&captureSerialConsole,
+		"capture-serial-console",
+		false,
+		"Capture the tail of the serial console output of the GCE instances failing to bootstrap, or not reporting their bootstrap status within the bootstrap timeout, to the <gcpmachine>-serial-console ConfigMap",
+	)
+
+	fs.DurationVar(&instanceResyncInterval,
+		"instance-resync-interval",
+		reconciler.DefaultInstanceResyncInterval,
+		"The interval at which the GCE instances of the ready GCPMachines are checked, to fail the GCPMachines whose instance has been deleted outside of Cluster API (e.g. 2m), 0 disables the resync",
+	)
+
+	fs.BoolVar(&requireServiceAccount,
+		"require-explicit-service-account",
+		false,
+		"Refuse to create instances running as the default compute service account, the GCPMachines and GCPMachinePools have to set their service account.",
+	)
+
+	fs.BoolVar(&checkCredentials,
+		"check-credentials",
+		true,
+		"Exit at startup if the GCP credentials of the metadata server, e.g. with GKE Workload Identity, lack the cloud-platform or compute scope, or the permissions to manage the clusters in the project of --health-check-project. The credentials of a key file are not checked.",
+	)
+
+	fs.BoolVar(&preflightChecks,
+		"preflight-checks",
+		true,
+		"Check that the APIs the resources of the GCPClusters are managed with are enabled, and that the quotas of their project aren't exhausted, before creating them. The failed checks are reported by the PreflightChecksPassed condition of the GCPClusters.",
+	)
+
+	fs.BoolVar(&exportMetrics,
+		"export-cloud-monitoring-metrics",
+		false,
+		"Publish the health of the clusters (ready, provisioned and failed machines, healthy API server backends) as custom metrics to the Cloud Monitoring of their project, so that GCP alerting can watch them.",
+	)
+
+	fs.StringVar(&instanceEventsSubscription,
+		"instance-events-subscription",
+		"",
+		"Pub/Sub subscription, in the projects/<project>/subscriptions/<name> format, receiving the Compute Engine audit logs of the instance disruptions (preemptions, host maintenance terminations, host errors, autohealing) routed by a log sink, to report them on the GCPMachines and Machines. Disabled if empty.",
+	)
+
 	fs.DurationVar(&syncPeriod,
 		"sync-period",
 		10*time.Minute,
 		"The minimum interval at which watched resources are reconciled (e.g. 15m)",
 	)
 
+	fs.DurationVar(&clusterResyncInterval,
+		"gcpcluster-resync-interval",
+		0,
+		"The interval at which the ready GCPClusters are reconciled to correct the drift of their GCP resources (e.g. 5m), trading API quota for a faster drift correction. Defaults to the sync period",
+	)
+
+	fs.DurationVar(&clusterIdleInterval,
+		"gcpcluster-idle-interval",
+		0,
+		"The interval within which the ready GCPClusters aren't reconciled again, unless their spec, their adopt, dry-run, export, request-reason or retain annotations, the spec of their Cluster or their control plane machines changed (e.g. 1m), to skip the GCP reads triggered by the status updates of the clusters and of their other machines. The resync and failure domain refresh intervals shorter than the idle interval are extended to it. Disabled if 0",
+	)
+
+	fs.DurationVar(&lookupCacheTTL,
+		"lookup-cache-ttl",
+		cloud.DefaultLookupCacheTTL,
+		"The duration the GCP lookups which rarely change, e.g. the zones of a region, are cached for (e.g. 10m)",
+	)
+
+	fs.DurationVar(&failureDomainRefresh,
+		"failure-domain-refresh-interval",
+		0,
+		"The interval at which the failure domains of the ready clusters are refreshed from the zones of their region (e.g. 1h), defaults to the sync period",
+	)
+
+	fs.DurationVar(&zoneIncidentWindow,
+		"zone-incident-window",
+		cloud.DefaultZoneIncidentWindow,
+		"The duration the failure domain of a zone stays ineligible for the control plane after an instance creation failed because the zone was out of resources (e.g. 30m)",
+	)
+
+	fs.DurationVar(&eventDedupWindow,
+		"event-dedup-window",
+		5*time.Minute,
+		"The duration an event identical to an event recorded for the same object is dropped for (e.g. 10m), not to flood the event storage when reconciles fail persistently. Disabled if 0",
+	)
+
+	fs.IntVar(&eventBurst,
+		"event-burst",
+		100,
+		"The number of events which can be recorded at once for an object before they are rate limited",
+	)
+
+	fs.Float32Var(&eventQPS,
+		"event-qps",
+		1.0/300,
+		"The rate, in events per second, at which the events of an object are recorded once their burst is spent",
+	)
+
 	fs.IntVar(&webhookPort,
 		"webhook-port",
 		9443,
@@ -281,9 +845,23 @@ func initFlags(fs *pflag.FlagSet) {
 		"The address the health endpoint binds to.",
 	)
 
+	fs.StringVar(&healthCheckProject,
+		"health-check-project",
+		"",
+		"GCP project used by the ready check to validate the credentials against the compute API. If unspecified, the project of the credentials is used.",
+	)
+
 	fs.DurationVar(&reconcileTimeout,
 		"reconcile-timeout",
 		reconciler.DefaultLoopTimeout,
 		"The maximum duration a reconcile loop can run (e.g. 90m)",
 	)
+
+	fs.BoolVar(&dryRun,
+		"dry-run",
+		false,
+		"Record the GCP operations the controllers would perform as logs and events, without executing them nor updating the GCP resources status.",
+	)
+
+	feature.MutableGates.AddFlag(fs)
 }
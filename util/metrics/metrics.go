@@ -0,0 +1,178 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics implements the Prometheus metrics of the reconciles of the clusters, served on the metrics
+// endpoint of the manager: the duration of the reconciles by subsystem, their errors by GCE error code, and
+// the number of GCP resources owned by the clusters.
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/api/googleapi"
+	"k8s.io/apimachinery/pkg/util/sets"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+// The subsystems of the reconciles of a cluster.
+const (
+	SubsystemNetwork      = "network"
+	SubsystemFirewall     = "firewall"
+	SubsystemLoadBalancer = "loadbalancer"
+	SubsystemInstance     = "instance"
+)
+
+const (
+	// ErrorCodeUnknown is the code of the errors which aren't GCP API errors, e.g. the ones of the Kubernetes API.
+	ErrorCodeUnknown = "unknown"
+
+	// ErrorCodeTimeout is the code of the compute operations still in progress when the reconcile stopped waiting.
+	ErrorCodeTimeout = "timeout"
+)
+
+var (
+	reconcileDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "capg_reconcile_duration_seconds",
+		Help:    "Duration of the reconciles of the GCP resources of the clusters, by namespace, cluster and subsystem.",
+		Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"namespace", "cluster", "subsystem"})
+
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "capg_reconcile_errors_total",
+		Help: "Number of the failed reconciles of the GCP resources of the clusters, by namespace, cluster, subsystem and GCE error code.",
+	}, []string{"namespace", "cluster", "subsystem", "code"})
+
+	ownedResources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "capg_cluster_gcp_resources",
+		Help: "Number of the GCP resources owned by the clusters, by namespace, cluster and resource type, e.g. firewalls.",
+	}, []string{"namespace", "cluster", "resource"})
+)
+
+// series are the label values of the series of the clusters, by namespace/name, so that the series of the
+// resource types no longer owned by a cluster, and all the series of a deleted cluster, are removed.
+var series = struct {
+	sync.Mutex
+	resources map[string]sets.String
+	errors    map[string]map[[2]string]bool
+}{resources: map[string]sets.String{}, errors: map[string]map[[2]string]bool{}}
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(reconcileDuration, reconcileErrors, ownedResources)
+}
+
+// ObserveReconcile runs the reconcile of the subsystem of the cluster, recording its duration and,
+// if it fails, its error code. The error of the reconcile is returned as is.
+func ObserveReconcile(namespace, cluster, subsystem string, reconcile func() error) error {
+	start := time.Now()
+	err := reconcile()
+	reconcileDuration.WithLabelValues(namespace, cluster, subsystem).Observe(time.Since(start).Seconds())
+	if err != nil {
+		code := ErrorCode(err)
+		reconcileErrors.WithLabelValues(namespace, cluster, subsystem, code).Inc()
+
+		series.Lock()
+		key := namespace + "/" + cluster
+		if series.errors[key] == nil {
+			series.errors[key] = map[[2]string]bool{}
+		}
+		series.errors[key][[2]string{subsystem, code}] = true
+		series.Unlock()
+	}
+
+	return err
+}
+
+// ErrorCode returns the GCE error code of err, so that e.g. the quota exhaustion can be alerted on: the code
+// of a failed compute operation, e.g. QUOTA_EXCEEDED, the reason of a Google API error, e.g. rateLimitExceeded,
+// or its HTTP status code if it has none.
+func ErrorCode(err error) string {
+	if wait.IsTimeout(err) {
+		return ErrorCodeTimeout
+	}
+
+	switch err := errors.Cause(err).(type) {
+	case *wait.OperationError:
+		if len(err.Codes) > 0 {
+			return err.Codes[0]
+		}
+	case *googleapi.Error:
+		for _, item := range err.Errors {
+			if item.Reason != "" {
+				return item.Reason
+			}
+		}
+
+		return strconv.Itoa(err.Code)
+	}
+
+	return ErrorCodeUnknown
+}
+
+// SetOwnedResources records the number of the GCP resources owned by the cluster, by resource type, from
+// their paths, e.g. global/firewalls/my-rule.
+func SetOwnedResources(namespace, cluster string, paths []string) {
+	counts := map[string]int{}
+	for _, p := range paths {
+		parts := strings.Split(p, "/")
+		if len(parts) < 2 {
+			continue
+		}
+		counts[parts[len(parts)-2]]++
+	}
+
+	series.Lock()
+	defer series.Unlock()
+
+	key := namespace + "/" + cluster
+	for resource := range series.resources[key] {
+		if _, ok := counts[resource]; !ok {
+			ownedResources.DeleteLabelValues(namespace, cluster, resource)
+		}
+	}
+	resources := sets.NewString()
+	for resource, count := range counts {
+		ownedResources.WithLabelValues(namespace, cluster, resource).Set(float64(count))
+		resources.Insert(resource)
+	}
+	series.resources[key] = resources
+}
+
+// DeleteCluster removes the series of the deleted cluster.
+func DeleteCluster(namespace, cluster string) {
+	for _, subsystem := range []string{SubsystemNetwork, SubsystemFirewall, SubsystemLoadBalancer, SubsystemInstance} {
+		reconcileDuration.DeleteLabelValues(namespace, cluster, subsystem)
+	}
+
+	series.Lock()
+	defer series.Unlock()
+
+	key := namespace + "/" + cluster
+	for labels := range series.errors[key] {
+		reconcileErrors.DeleteLabelValues(namespace, cluster, labels[0], labels[1])
+	}
+	for resource := range series.resources[key] {
+		ownedResources.DeleteLabelValues(namespace, cluster, resource)
+	}
+	delete(series.errors, key)
+	delete(series.resources, key)
+}
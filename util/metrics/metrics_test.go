@@ -0,0 +1,86 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metrics
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/googleapi"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/cloud/wait"
+)
+
+func TestErrorCode(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	opErr := wait.PollComputeOperation(&compute.Operation{Status: "DONE", Error: &compute.OperationError{
+		Errors: []*compute.OperationErrorErrors{{Code: "QUOTA_EXCEEDED", Message: "Quota 'CPUS' exceeded"}},
+	}})
+	g.Expect(ErrorCode(errors.Wrap(opErr, "failed to create instance"))).To(gomega.Equal("QUOTA_EXCEEDED"))
+
+	apiErr := &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "rateLimitExceeded"}}}
+	g.Expect(ErrorCode(errors.Wrap(apiErr, "failed to describe network"))).To(gomega.Equal("rateLimitExceeded"))
+	g.Expect(ErrorCode(&googleapi.Error{Code: http.StatusNotFound})).To(gomega.Equal("404"))
+
+	timeout := wait.PollComputeOperation(&compute.Operation{Name: "my-op", OperationType: "insert", Status: "RUNNING"})
+	g.Expect(ErrorCode(timeout)).To(gomega.Equal(ErrorCodeTimeout))
+
+	g.Expect(ErrorCode(errors.New("boom"))).To(gomega.Equal(ErrorCodeUnknown))
+}
+
+func TestObserveReconcile(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	err := ObserveReconcile("default", "my-cluster", SubsystemNetwork, func() error { return nil })
+	g.Expect(err).NotTo(gomega.HaveOccurred())
+	g.Expect(testutil.CollectAndCount(reconcileDuration)).To(gomega.Equal(1))
+	g.Expect(testutil.CollectAndCount(reconcileErrors)).To(gomega.Equal(0))
+
+	quotaErr := &googleapi.Error{Code: http.StatusForbidden, Errors: []googleapi.ErrorItem{{Reason: "quotaExceeded"}}}
+	err = ObserveReconcile("default", "my-cluster", SubsystemFirewall, func() error { return quotaErr })
+	g.Expect(err).To(gomega.Equal(quotaErr))
+	g.Expect(testutil.ToFloat64(reconcileErrors.WithLabelValues("default", "my-cluster", SubsystemFirewall, "quotaExceeded"))).To(gomega.Equal(1.0))
+
+	DeleteCluster("default", "my-cluster")
+	g.Expect(testutil.CollectAndCount(reconcileDuration)).To(gomega.Equal(0))
+	g.Expect(testutil.CollectAndCount(reconcileErrors)).To(gomega.Equal(0))
+}
+
+func TestSetOwnedResources(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	SetOwnedResources("default", "my-cluster", []string{
+		"global/networks/my-network",
+		"global/firewalls/my-rule",
+		"global/firewalls/my-other-rule",
+		"regions/us-central1/subnetworks/my-subnet",
+	})
+	g.Expect(testutil.CollectAndCount(ownedResources)).To(gomega.Equal(3))
+	g.Expect(testutil.ToFloat64(ownedResources.WithLabelValues("default", "my-cluster", "firewalls"))).To(gomega.Equal(2.0))
+
+	// The resource types no longer owned are removed.
+	SetOwnedResources("default", "my-cluster", []string{"global/networks/my-network"})
+	g.Expect(testutil.CollectAndCount(ownedResources)).To(gomega.Equal(1))
+
+	DeleteCluster("default", "my-cluster")
+	g.Expect(testutil.CollectAndCount(ownedResources)).To(gomega.Equal(0))
+}
@@ -19,6 +19,8 @@ package reconciler
 
 import (
 	"time"
+
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
@@ -26,6 +28,12 @@ const (
 	DefaultLoopTimeout = 90 * time.Minute
 	// DefaultMappingTimeout is the default timeout for a controller request mapping func.
 	DefaultMappingTimeout = 60 * time.Second
+	// DefaultRequeueJitter is the default maximum factor by which requeue intervals are randomly extended.
+	DefaultRequeueJitter = 0.2
+	// DefaultInstanceProvisioningTimeout is the default time an instance can take to start running.
+	DefaultInstanceProvisioningTimeout = 20 * time.Minute
+	// DefaultInstanceResyncInterval is the default interval at which the instances of the ready machines are checked.
+	DefaultInstanceResyncInterval = 5 * time.Minute
 )
 
 // DefaultedLoopTimeout will default the timeout if it is zero valued.
@@ -36,3 +44,13 @@ func DefaultedLoopTimeout(timeout time.Duration) time.Duration {
 
 	return timeout
 }
+
+// JitteredRequeueAfter randomly extends the requeue interval by up to jitter times its value,
+// so that objects requeued together, e.g. after a controller restart, are spread over time.
+func JitteredRequeueAfter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+
+	return wait.Jitter(d, jitter)
+}
@@ -57,3 +57,14 @@ func TestDefaultedTimeout(t *testing.T) {
 		})
 	}
 }
+
+func TestJitteredRequeueAfter(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(reconciler.JitteredRequeueAfter(15*time.Second, 0)).To(gomega.Equal(15 * time.Second))
+	for i := 0; i < 100; i++ {
+		d := reconciler.JitteredRequeueAfter(15*time.Second, 0.2)
+		g.Expect(d).To(gomega.BeNumerically(">=", 15*time.Second))
+		g.Expect(d).To(gomega.BeNumerically("<=", 18*time.Second))
+	}
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/cache"
+	"k8s.io/client-go/tools/record"
+)
+
+// maxRecordedEvents is the number of the recent events remembered to deduplicate the events.
+const maxRecordedEvents = 4096
+
+// DeduplicatingRecorder is an EventRecorder dropping the events identical, i.e. with the same
+// type, reason and message, to an event recorded for the same object within a window. The
+// reconciles failing persistently emit the same events on every retry, each of them patching
+// the count of the event in the API server even after the event correlator aggregated them.
+type DeduplicatingRecorder struct {
+	recorder record.EventRecorder
+	window   time.Duration
+	recent   *cache.LRUExpireCache
+}
+
+// NewDeduplicatingRecorder returns a DeduplicatingRecorder recording the events with the recorder.
+func NewDeduplicatingRecorder(recorder record.EventRecorder, window time.Duration) *DeduplicatingRecorder {
+	return &DeduplicatingRecorder{
+		recorder: recorder,
+		window:   window,
+		recent:   cache.NewLRUExpireCache(maxRecordedEvents),
+	}
+}
+
+// Event implements record.EventRecorder.
+func (r *DeduplicatingRecorder) Event(object runtime.Object, eventtype, reason, message string) {
+	if r.duplicate(object, eventtype, reason, message) {
+		return
+	}
+	r.recorder.Event(object, eventtype, reason, message)
+}
+
+// Eventf implements record.EventRecorder.
+func (r *DeduplicatingRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+	r.Event(object, eventtype, reason, fmt.Sprintf(messageFmt, args...))
+}
+
+// AnnotatedEventf implements record.EventRecorder.
+func (r *DeduplicatingRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+	message := fmt.Sprintf(messageFmt, args...)
+	if r.duplicate(object, eventtype, reason, message) {
+		return
+	}
+	r.recorder.AnnotatedEventf(object, annotations, eventtype, reason, "%s", message)
+}
+
+// duplicate returns true if the event has been recorded for the object within the window, and
+// remembers it otherwise.
+func (r *DeduplicatingRecorder) duplicate(object runtime.Object, eventtype, reason, message string) bool {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return false
+	}
+	key := fmt.Sprintf("%s/%s/%s/%s/%s/%s", accessor.GetUID(), accessor.GetNamespace(), accessor.GetName(), eventtype, reason, message)
+	if _, ok := r.recent.Get(key); ok {
+		return true
+	}
+	r.recent.Add(key, struct{}{}, r.window)
+
+	return false
+}
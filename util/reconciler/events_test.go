@@ -0,0 +1,50 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+func TestDeduplicatingRecorder(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	fake := record.NewFakeRecorder(10)
+	recorder := reconciler.NewDeduplicatingRecorder(fake, time.Minute)
+	foo := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "foo", UID: "foo"}}
+	bar := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "bar", UID: "bar"}}
+
+	recorder.Eventf(foo, corev1.EventTypeWarning, "FailedCreate", "Failed to create %q", "foo")
+	recorder.Eventf(foo, corev1.EventTypeWarning, "FailedCreate", "Failed to create %q", "foo")
+	recorder.Event(foo, corev1.EventTypeWarning, "FailedCreate", `Failed to create "foo"`)
+	recorder.Eventf(foo, corev1.EventTypeWarning, "FailedCreate", "Failed to create %q: quota exceeded", "foo")
+	recorder.Eventf(bar, corev1.EventTypeWarning, "FailedCreate", "Failed to create %q", "foo")
+	recorder.AnnotatedEventf(bar, nil, corev1.EventTypeWarning, "FailedCreate", "Failed to create %q", "foo")
+
+	g.Expect(fake.Events).To(gomega.HaveLen(3))
+	g.Expect(<-fake.Events).To(gomega.Equal(`Warning FailedCreate Failed to create "foo"`))
+	g.Expect(<-fake.Events).To(gomega.Equal(`Warning FailedCreate Failed to create "foo": quota exceeded`))
+	g.Expect(<-fake.Events).To(gomega.Equal(`Warning FailedCreate Failed to create "foo"`))
+}
@@ -0,0 +1,60 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"sync"
+
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+)
+
+// DefaultParallelism is the default maximum number of independent resources reconciled concurrently.
+const DefaultParallelism = 4
+
+// RunParallel runs the functions concurrently, at most parallelism of them at a time,
+// waits for all of them to return and aggregates their errors.
+func RunParallel(parallelism int, fns ...func() error) error {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	sem := make(chan struct{}, parallelism)
+	for _, fn := range fns {
+		fn := fn
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := fn(); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return kerrors.NewAggregate(errs)
+}
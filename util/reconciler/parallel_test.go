@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+func TestRunParallel(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	var running, maxRunning int32
+	fn := func() error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		return nil
+	}
+	g.Expect(reconciler.RunParallel(2, fn, fn, fn, fn, fn)).To(gomega.Succeed())
+	g.Expect(maxRunning).To(gomega.BeNumerically("<=", 2))
+
+	err := reconciler.RunParallel(2, fn, func() error { return errors.New("boom") })
+	g.Expect(err).To(gomega.MatchError("boom"))
+}
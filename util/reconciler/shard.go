@@ -0,0 +1,52 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler
+
+import (
+	"fmt"
+	"hash/fnv"
+)
+
+// Shard is the partition of the clusters reconciled by a replica of the manager, when the clusters are
+// sharded across several replicas.
+type Shard struct {
+	// Index is the index of the shard, from 0 to Count-1.
+	Index int
+	// Count is the number of shards, the clusters aren't sharded if 0 or 1.
+	Count int
+}
+
+// Validate returns an error if the index isn't one of the shards.
+func (s Shard) Validate() error {
+	if s.Count > 1 && (s.Index < 0 || s.Index >= s.Count) {
+		return fmt.Errorf("shard index %d is out of range, there are %d shards", s.Index, s.Count)
+	}
+
+	return nil
+}
+
+// Owns returns true if the cluster belongs to the shard. The clusters are assigned to the shards by the
+// hash of their namespaced name, so that all the objects of a cluster are reconciled by the same replica.
+func (s Shard) Owns(namespace, clusterName string) bool {
+	if s.Count <= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(namespace + "/" + clusterName))
+
+	return int(h.Sum32()%uint32(s.Count)) == s.Index
+}
@@ -0,0 +1,51 @@
+/*
+Copyright 2021 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package reconciler_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/onsi/gomega"
+
+	"sigs.k8s.io/cluster-api-provider-gcp/util/reconciler"
+)
+
+func TestShard(t *testing.T) {
+	g := gomega.NewWithT(t)
+
+	g.Expect(reconciler.Shard{}.Owns("default", "my-cluster")).To(gomega.BeTrue())
+	g.Expect(reconciler.Shard{}.Validate()).To(gomega.Succeed())
+	g.Expect(reconciler.Shard{Index: 3, Count: 3}.Validate()).NotTo(gomega.Succeed())
+
+	// Every cluster belongs to exactly one of the shards.
+	owned := make([]int, 3)
+	for i := 0; i < 300; i++ {
+		name := fmt.Sprintf("cluster-%d", i)
+		owners := 0
+		for index := range owned {
+			if (reconciler.Shard{Index: index, Count: len(owned)}).Owns("default", name) {
+				owned[index]++
+				owners++
+			}
+		}
+		g.Expect(owners).To(gomega.Equal(1))
+	}
+	for _, n := range owned {
+		g.Expect(n).To(gomega.BeNumerically(">", 50))
+	}
+}